@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 自适应码率：根据客户端带宽选择转码配置
+//
+// 带宽来源按优先级：请求参数 bw（kbps，播放页根据navigator.connection提供）、
+// 浏览器的Downlink请求头（Mbps）、服务器在转码流上测得的吞吐量。
+// 测量只统计发生阻塞的写入（发送缓冲区已满，说明受网络限制），
+// 转码速度跟不上时写入不会阻塞，不会被误判为网络慢。
+//
+// 播放页在频繁缓冲时从当前位置以低一档的配置重新请求转码流（?start=秒数）。
+
+// 从高到低的清晰度阶梯，需要的带宽按配置的最大码率乘以余量计算
+var abrLadder = []string{"1080p", "720p", "480p"}
+
+const (
+	abrHeadroom        = 1.5              // 带宽需要达到最大码率的倍数
+	abrFallbackProfile = "720p"           // 没有任何带宽信息时使用
+	abrEstimateTTL     = 10 * time.Minute // 测量结果的有效期
+	abrMinBlocked      = 2 * time.Millisecond
+	abrSampleWindow    = time.Second // 累计阻塞时间达到此值时记录一次
+	abrSmoothing       = 0.3         // 指数加权平均中新样本的权重
+)
+
+type bandwidthEstimate struct {
+	bps         float64
+	updated     time.Time
+	lastProfile string // 最近一次自动选择的配置
+}
+
+var (
+	bandwidthEstimates = make(map[string]*bandwidthEstimate) // 客户端IP → 带宽估计
+	bandwidthMutex     sync.Mutex
+)
+
+// 按IP区分客户端，同一设备的多个连接共享带宽估计
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func recordThroughput(key string, bytes int64, blocked time.Duration) {
+	if bytes <= 0 || blocked <= 0 {
+		return
+	}
+	sample := float64(bytes) * 8 / blocked.Seconds()
+
+	bandwidthMutex.Lock()
+	defer bandwidthMutex.Unlock()
+
+	est, ok := bandwidthEstimates[key]
+	if !ok || time.Since(est.updated) > abrEstimateTTL || est.bps == 0 {
+		if !ok {
+			est = &bandwidthEstimate{}
+			bandwidthEstimates[key] = est
+		}
+		est.bps = sample
+	} else {
+		est.bps = est.bps*(1-abrSmoothing) + sample*abrSmoothing
+	}
+	est.updated = time.Now()
+}
+
+// 获取客户端的带宽（bps），没有有效估计时返回false
+func measuredBandwidth(key string) (float64, bool) {
+	bandwidthMutex.Lock()
+	defer bandwidthMutex.Unlock()
+
+	est, ok := bandwidthEstimates[key]
+	if !ok || est.bps == 0 || time.Since(est.updated) > abrEstimateTTL {
+		return 0, false
+	}
+	return est.bps, true
+}
+
+func rememberProfile(key, profile string) {
+	bandwidthMutex.Lock()
+	defer bandwidthMutex.Unlock()
+
+	est, ok := bandwidthEstimates[key]
+	if !ok {
+		est = &bandwidthEstimate{}
+		bandwidthEstimates[key] = est
+	}
+	est.lastProfile = profile
+}
+
+func lastProfile(key string) string {
+	bandwidthMutex.Lock()
+	defer bandwidthMutex.Unlock()
+
+	if est, ok := bandwidthEstimates[key]; ok {
+		return est.lastProfile
+	}
+	return ""
+}
+
+// 客户端带宽（bps）及来源：hint、downlink、measured，都没有时返回0
+func clientBandwidth(r *http.Request) (float64, string) {
+	if kbps, err := strconv.ParseFloat(r.URL.Query().Get("bw"), 64); err == nil && kbps > 0 {
+		return kbps * 1000, "hint"
+	}
+	if mbps, err := strconv.ParseFloat(r.Header.Get("Downlink"), 64); err == nil && mbps > 0 {
+		return mbps * 1000000, "downlink"
+	}
+	if bps, ok := measuredBandwidth(clientKey(r)); ok {
+		return bps, "measured"
+	}
+	return 0, ""
+}
+
+// 解析码率字符串，如 2M、2500k、800000
+func parseBitrate(s string) float64 {
+	s = strings.TrimSpace(strings.ToLower(s))
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "m"):
+		multiplier, s = 1000000, strings.TrimSuffix(s, "m")
+	case strings.HasSuffix(s, "k"):
+		multiplier, s = 1000, strings.TrimSuffix(s, "k")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return v * multiplier
+}
+
+// 按带宽在阶梯中选择能流畅播放的最高一档
+func selectAdaptiveProfile(r *http.Request) (string, float64, string) {
+	bps, source := clientBandwidth(r)
+	if bps == 0 {
+		return abrFallbackProfile, 0, ""
+	}
+
+	profiles := transcodeProfiles()
+	for _, name := range abrLadder {
+		p, ok := profiles[name]
+		if !ok {
+			continue
+		}
+		if required := parseBitrate(p.MaxRate) * abrHeadroom; bps >= required {
+			return name, bps, source
+		}
+	}
+	return abrLadder[len(abrLadder)-1], bps, source
+}
+
+// 统计阻塞写入的ResponseWriter，用于测量转码流的网络吞吐量
+type meteredWriter struct {
+	http.ResponseWriter
+	key     string
+	bytes   int64
+	blocked time.Duration
+}
+
+func newMeteredWriter(w http.ResponseWriter, r *http.Request) *meteredWriter {
+	return &meteredWriter{ResponseWriter: w, key: clientKey(r)}
+}
+
+func (m *meteredWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := m.ResponseWriter.Write(p)
+	if d := time.Since(start); d >= abrMinBlocked {
+		m.bytes += int64(n)
+		m.blocked += d
+		if m.blocked >= abrSampleWindow {
+			recordThroughput(m.key, m.bytes, m.blocked)
+			m.bytes, m.blocked = 0, 0
+		}
+	}
+	return n, err
+}
+
+func (m *meteredWriter) Flush() {
+	if flusher, ok := m.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (m *meteredWriter) Unwrap() http.ResponseWriter {
+	return m.ResponseWriter
+}
+
+// 自适应码率API处理器
+// GET ?bw= 返回当前带宽估计、自动选择的配置、上次使用的配置和清晰度阶梯
+func apiABRHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, bps, source := selectAdaptiveProfile(r)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"profile":       profile,
+		"lastProfile":   lastProfile(clientKey(r)),
+		"bandwidthKbps": int(bps / 1000),
+		"source":        source,
+		"ladder":        abrLadder,
+	})
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 按文件类型执行的外部命令
+//
+//   GET  /api/actions?path=文件                  适用于该文件的操作（不带path时列出全部）
+//   POST /api/actions {"id": "extract", "path": "文件"}   在主机上执行，返回退出码和输出
+// 操作在config.json的actions中定义，如用7z解压到当前文件夹、用LibreOffice转换为PDF。
+// 命令参数中的 {path}、{dir}、{name}、{stem}、{ext} 替换为文件的完整路径、所在文件夹、文件名、
+// 不含扩展名的文件名和扩展名；默认在文件所在文件夹中执行。执行属于管理操作，同时最多执行两个。
+
+const (
+	defaultActionTimeout = 5 * time.Minute
+	maxActionOutput      = 1 << 20
+	maxActionJobs        = 2
+)
+
+type FileAction struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	// 适用的扩展名，如 [".zip", ".7z"]，为空时适用于所有文件
+	Extensions []string `json:"extensions,omitempty"`
+	// 程序和参数，如 ["C:\\Program Files\\7-Zip\\7z.exe", "x", "{path}", "-o{dir}\\{stem}"]
+	Command []string `json:"command"`
+	// 工作文件夹，默认为文件所在文件夹
+	WorkDir string `json:"workDir,omitempty"`
+	// 超时时间（秒），默认300秒
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+type ActionResult struct {
+	ID        string  `json:"id"`
+	Label     string  `json:"label"`
+	Path      string  `json:"path"`
+	Success   bool    `json:"success"`
+	ExitCode  int     `json:"exitCode"`
+	Output    string  `json:"output"`
+	Truncated bool    `json:"truncated,omitempty"`
+	Elapsed   float64 `json:"elapsed"` // 秒
+	Error     string  `json:"error,omitempty"`
+}
+
+// 页面和列表接口中显示的操作，不包含命令行
+type actionInfo struct {
+	ID         string   `json:"id"`
+	Label      string   `json:"label"`
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+var actionJobSlots = make(chan struct{}, maxActionJobs)
+
+// 输出超过上限后丢弃多余的部分
+type actionOutput struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (b *actionOutput) Write(p []byte) (int, error) {
+	if room := maxActionOutput - b.Len(); len(p) > room {
+		b.Buffer.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// 操作是否适用于该扩展名
+func (a FileAction) appliesTo(ext string) bool {
+	if len(a.Extensions) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(a.Extensions, func(e string) bool {
+		return strings.EqualFold("."+strings.TrimPrefix(e, "."), ext)
+	})
+}
+
+// 配置的有效操作，filePath不为空时只返回适用于该文件的
+func fileActions(filePath string) []FileAction {
+	actions := []FileAction{}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, a := range serverConfig.Actions {
+		if a.ID == "" || len(a.Command) == 0 {
+			continue
+		}
+		if filePath == "" || a.appliesTo(ext) {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+func actionInfos(filePath string) []actionInfo {
+	infos := []actionInfo{}
+	for _, a := range fileActions(filePath) {
+		infos = append(infos, actionInfo{ID: a.ID, Label: a.Label, Extensions: a.Extensions})
+	}
+	return infos
+}
+
+// 页面上显示的操作，只有可以执行管理操作时才显示
+func adminActionInfos(r *http.Request) []actionInfo {
+	if !canAdmin(r) {
+		return []actionInfo{}
+	}
+	return actionInfos("")
+}
+
+func findFileAction(id string) (FileAction, bool) {
+	for _, a := range fileActions("") {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return FileAction{}, false
+}
+
+// 替换命令参数中的占位符
+func expandActionArgs(args []string, path string) []string {
+	ext := filepath.Ext(path)
+	name := filepath.Base(path)
+	replacer := strings.NewReplacer(
+		"{path}", path,
+		"{dir}", filepath.Dir(path),
+		"{name}", name,
+		"{stem}", strings.TrimSuffix(name, ext),
+		"{ext}", ext,
+	)
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = replacer.Replace(arg)
+	}
+	return expanded
+}
+
+// 执行操作，不随请求取消（解压等操作中途停止会留下不完整的文件）
+func runFileAction(action FileAction, path string) ActionResult {
+	result := ActionResult{ID: action.ID, Label: action.Label, Path: clientPath(path), ExitCode: -1}
+
+	actionJobSlots <- struct{}{}
+	defer func() { <-actionJobSlots }()
+
+	timeout := defaultActionTimeout
+	if action.TimeoutSeconds > 0 {
+		timeout = time.Duration(action.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	args := expandActionArgs(action.Command, path)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = filepath.Dir(path)
+	if action.WorkDir != "" {
+		cmd.Dir = expandActionArgs([]string{action.WorkDir}, path)[0]
+	}
+	var output actionOutput
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+	result.Elapsed = time.Since(start).Seconds()
+	// 命令行程序通常按系统代码页输出
+	if out := output.Bytes(); utf8.Valid(out) {
+		result.Output = string(out)
+	} else {
+		result.Output = decodeANSI(out)
+	}
+	result.Truncated = output.truncated
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.Error = fmt.Sprintf("超时（%v）", timeout)
+	case err != nil && cmd.ProcessState == nil:
+		result.Error = err.Error()
+	default:
+		result.ExitCode = cmd.ProcessState.ExitCode()
+		result.Success = err == nil
+	}
+	return result
+}
+
+// 文件操作API处理器
+func apiActionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		path := r.URL.Query().Get("path")
+		if path != "" {
+			path = resolveClientPath(path)
+		}
+		actions := actionInfos(path)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"actions": actions,
+			"count":   len(actions),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			ID   string `json:"id"`
+			Path string `json:"path"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" || req.Path == "" {
+			http.Error(w, "请求格式错误，需要id和path参数", http.StatusBadRequest)
+			return
+		}
+		action, ok := findFileAction(req.ID)
+		if !ok {
+			http.Error(w, "没有定义该操作: "+req.ID, http.StatusNotFound)
+			return
+		}
+		if remoteEverythingEnabled() {
+			http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+			return
+		}
+		path := resolveClientPath(req.Path)
+		info, err := statPath(path)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if os.IsNotExist(err) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, "访问文件失败: "+err.Error(), status)
+			return
+		}
+		if info.IsDir() || !action.appliesTo(strings.ToLower(filepath.Ext(path))) {
+			http.Error(w, fmt.Sprintf("操作“%s”不适用于该文件", action.Label), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("执行操作: %s, 文件: %s, 来源IP: %s", action.ID, path, r.RemoteAddr)
+		result := runFileAction(action, path)
+		// 命令最长运行defaultActionTimeout，超过了请求开始时设置的写超时
+		restartWriteDeadline(w, apiWriteTimeout)
+		if result.Error != "" {
+			log.Printf("操作失败: %s, 文件: %s, 错误: %s", action.ID, path, result.Error)
+		} else {
+			log.Printf("操作完成: %s, 文件: %s, 退出码%d, 用时%.1f秒", action.ID, path, result.ExitCode, result.Elapsed)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 作为外部命令运行：输出参数和工作文件夹，参数为fail时以退出码2结束
+func TestActionHelperProcess(t *testing.T) {
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return
+	}
+	if args[1] == "fail" {
+		fmt.Fprintln(os.Stderr, "failed")
+		os.Exit(2)
+	}
+	wd, _ := os.Getwd()
+	fmt.Println(strings.Join(args[1:], "|"), wd)
+	os.Exit(0)
+}
+
+func TestFileActions(t *testing.T) {
+	dir := t.TempDir()
+	paths := createTestFiles(t, dir, "photos.zip", "notes.txt")
+	helper := []string{os.Args[0], "-test.run=TestActionHelperProcess", "--"}
+	serverConfig.Actions = []FileAction{
+		{ID: "extract", Label: "解压到这里", Extensions: []string{"zip", ".7Z"}, Command: append(helper, "{stem}", "{ext}", "{name}")},
+		{ID: "fail", Label: "失败", Command: append(helper, "fail")},
+	}
+	defer func() { serverConfig.Actions = nil }()
+
+	// 列表只包含适用的操作，不包含命令行
+	rec := serveTestRequest(apiActionsHandler, httptest.NewRequest(http.MethodGet, "/api/actions?path="+url.QueryEscape(paths[1]), nil))
+	if body := rec.Body.String(); strings.Contains(body, "extract") || !strings.Contains(body, `"fail"`) || strings.Contains(body, "command") {
+		t.Errorf("actions for notes.txt = %s", body)
+	}
+
+	run := func(id, path string) (int, ActionResult) {
+		body, _ := json.Marshal(map[string]string{"id": id, "path": path})
+		rec := serveTestRequest(apiActionsHandler, httptest.NewRequest(http.MethodPost, "/api/actions", strings.NewReader(string(body))))
+		var result ActionResult
+		if rec.Code == http.StatusOK {
+			decodeTestJSON(t, rec, &result)
+		}
+		return rec.Code, result
+	}
+
+	code, result := run("extract", paths[0])
+	if code != http.StatusOK || !result.Success || result.ExitCode != 0 || !strings.HasPrefix(result.Output, "photos|.zip|photos.zip "+dir) {
+		t.Errorf("extract: status %d, result %+v", code, result)
+	}
+	if code, result = run("fail", paths[1]); code != http.StatusOK || result.Success || result.ExitCode != 2 || !strings.Contains(result.Output, "failed") {
+		t.Errorf("fail: status %d, result %+v", code, result)
+	}
+	if code, _ = run("extract", paths[1]); code != http.StatusBadRequest {
+		t.Errorf("extract on .txt: status = %d, want 400", code)
+	}
+	if code, _ = run("unknown", paths[0]); code != http.StatusNotFound {
+		t.Errorf("unknown action: status = %d, want 404", code)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 管理操作的访问限制
+//
+// 修改文件、清除缓存、运行维护任务等管理操作默认只接受本机的请求，局域网中的其他设备只能搜索和浏览。
+// 在listeners中把某个监听地址设置为管理端口（"admin": true，一般为127.0.0.1上的另一个端口）后，
+// 管理操作只能通过管理端口访问，其他端口即使来自本机也拒绝。确实需要从局域网管理时设置remoteAdmin。
+// 经过反向代理（带X-Forwarded-For或Forwarded请求头）的请求按局域网请求处理。
+// 管理操作的Host请求头必须是本机地址、localhost或监听地址，防止DNS重绑定：其他网站把自己的域名
+// 解析到127.0.0.1后，浏览器把对该域名的请求当作同源发送（能读到ew_csrf Cookie），来源IP也是本机。
+
+// 管理操作：路径和方法，方法为空表示所有方法
+var adminRoutes = []struct {
+	path    string
+	methods []string
+}{
+	{"/api/batch", []string{http.MethodPost, http.MethodDelete}},
+	{"/api/undo", []string{http.MethodPost}},
+	{"/api/schedule", []string{http.MethodPost}},
+	{"/api/shares", []string{http.MethodPost, http.MethodDelete}},
+	{"/api/cache-clear", []string{http.MethodPost}},
+	{"/api/cache/purge", []string{http.MethodPost}},
+	{"/api/everything/rescan", []string{http.MethodPost}},
+	{"/api/redetect", []string{http.MethodPost}},
+	{"/api/notifications/test", []string{http.MethodPost}},
+	{"/api/monitors", []string{http.MethodPost, http.MethodPut, http.MethodDelete}}, // 监控的通知会通过主机的邮件账号和网络发出
+	{"/api/monitors/check", []string{http.MethodPost}},
+	{"/api/capture", []string{http.MethodPost}},
+	{"/api/clipboard", nil},
+	{"/api/actions", []string{http.MethodPost}},
+	{"/api/scripts/reload", []string{http.MethodPost}},
+	{"/api/exclusions", []string{http.MethodPut, http.MethodPatch}},
+}
+
+type listenerKey struct{}
+
+func isAdminRoute(r *http.Request) bool {
+	for _, route := range adminRoutes {
+		if r.URL.Path != route.path {
+			continue
+		}
+		if len(route.methods) == 0 {
+			return true
+		}
+		for _, method := range route.methods {
+			if r.Method == method {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 记录请求来自哪个监听地址
+func withListener(status listenStatus, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), listenerKey{}, status)))
+	})
+}
+
+// 管理端口，没有配置时返回空
+func adminListener() (listenStatus, bool) {
+	for _, status := range serverListenStatus() {
+		if status.Admin {
+			return status, true
+		}
+	}
+	return listenStatus{}, false
+}
+
+// 请求是否直接来自本机
+func isLocalRequest(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Host请求头是否为本机地址、localhost或监听地址（监听所有地址时包括各网卡的地址）
+func isAdminHost(r *http.Request) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, status := range serverListenStatus() {
+		local, lan := listenURLs(status, getLocalIPs(), getLocalIPv6s())
+		for _, address := range append(local, lan...) {
+			if u, err := url.Parse(address); err == nil && net.ParseIP(u.Hostname()).Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 请求能否执行管理操作，不能时返回原因
+func adminDenied(r *http.Request) string {
+	if !isAdminHost(r) {
+		return "管理操作的Host请求头必须是本机地址或监听地址: " + r.Host
+	}
+	if admin, ok := adminListener(); ok {
+		status, _ := r.Context().Value(listenerKey{}).(listenStatus)
+		if !status.Admin {
+			return "管理操作只能通过管理端口访问: " + admin.Scheme + "://" + admin.Addr
+		}
+	}
+	if !serverConfig.RemoteAdmin && !isLocalRequest(r) {
+		return "管理操作只能在本机访问（在data\\config.json中设置remoteAdmin允许局域网访问）"
+	}
+	return ""
+}
+
+func canAdmin(r *http.Request) bool {
+	return adminDenied(r) == ""
+}
+
+// 拒绝不允许的管理操作
+func withAdminGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAdminRoute(r) {
+			if reason := adminDenied(r); reason != "" {
+				log.Printf("拒绝管理操作 %s %s: %s，来源IP: %s", r.Method, r.URL.Path, reason, r.RemoteAddr)
+				http.Error(w, reason, http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 设置了remoteAdmin且管理端口不在本机地址上时提醒
+func warnAdminListener(status listenStatus) {
+	host, _, _ := net.SplitHostPort(status.Addr)
+	if ip := net.ParseIP(host); serverConfig.RemoteAdmin && (ip == nil || !ip.IsLoopback()) {
+		log.Printf("警告: 管理端口%s监听在%s，局域网中的设备可以修改和删除文件；建议改为127.0.0.1", status.Name, status.Addr)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 视频片段转动图
+//
+// GET /api/animation?path=&start=&end=&format=gif|webp&width=&fps=
+// 时间范围的格式与 /api/clip 相同。为控制文件大小，时长、宽度和帧率都有上限。
+
+const (
+	maxAnimationSeconds = 15.0
+	defaultAnimWidth    = 480
+	maxAnimWidth        = 640
+	defaultAnimFPS      = 12
+	maxAnimFPS          = 20
+)
+
+// 读取整数参数，未指定时使用默认值，超出范围时限制在[1, max]
+func boundedIntParam(r *http.Request, name string, def, max int) int {
+	v, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || v <= 0 {
+		return def
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// 动图生成API处理器
+func apiAnimationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ffmpegAvailable.Load() {
+		http.Error(w, "ffmpeg不可用，无法生成动图", http.StatusServiceUnavailable)
+		return
+	}
+
+	filePath, start, end, ok := parseVideoRange(w, r)
+	if !ok {
+		return
+	}
+	if end-start > maxAnimationSeconds {
+		http.Error(w, fmt.Sprintf("动图时长不能超过%.0f秒", maxAnimationSeconds), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "gif"
+	}
+	width := boundedIntParam(r, "width", defaultAnimWidth, maxAnimWidth)
+	fps := boundedIntParam(r, "fps", defaultAnimFPS, maxAnimFPS)
+
+	// 不放大比目标小的视频，高度按比例取偶数
+	scale := fmt.Sprintf("fps=%d,scale='min(%d,iw)':-2:flags=lanczos", fps, width)
+	args := []string{"-ss", strconv.FormatFloat(start, 'f', 3, 64), "-t", strconv.FormatFloat(end-start, 'f', 3, 64),
+		"-i", filePath, "-an"}
+
+	var contentType string
+	switch format {
+	case "gif":
+		// 先为这段视频生成调色板，颜色比默认的通用调色板准确得多
+		args = append(args, "-filter_complex", scale+",split[a][b];[a]palettegen=stats_mode=diff[p];[b][p]paletteuse=dither=bayer:bayer_scale=5", "-loop", "0")
+		contentType = "image/gif"
+	case "webp":
+		args = append(args, "-vf", scale, "-c:v", "libwebp", "-lossless", "0", "-q:v", "70", "-loop", "0")
+		contentType = "image/webp"
+	default:
+		http.Error(w, "不支持的动图格式: "+format, http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("生成动图: %s，%.1fs - %.1fs，格式: %s，%dpx，%dfps，来源IP: %s", filePath, start, end, format, width, fps, r.RemoteAddr)
+	begin := time.Now()
+
+	output, err := runFFmpegJob(r.Context(), args, "."+format)
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("生成动图失败: %s, 错误: %v", filePath, err)
+			http.Error(w, "生成动图失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("动图生成完成: %s，用时%v", filePath, time.Since(begin).Round(time.Millisecond))
+	serveJobOutput(w, r, output, clipFileName(filePath, start, end, "."+format), contentType)
+}
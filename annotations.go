@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 文件夹说明（显示在浏览结果上方）
+type FolderAnnotation struct {
+	Note        string `json:"note,omitempty"` // 通过 /api/annotations 设置的服务器端说明
+	NoteUpdated string `json:"noteUpdated,omitempty"`
+	Readme      string `json:"readme,omitempty"` // 文件夹内README的内容
+	ReadmeName  string `json:"readmeName,omitempty"`
+}
+
+// 服务器端保存的文件夹说明
+type StoredAnnotation struct {
+	Path    string `json:"path"`
+	Text    string `json:"text"`
+	Updated string `json:"updated"`
+}
+
+const (
+	annotationsFileName = "annotations.json"
+	maxReadmeSize       = 64 * 1024 // README超过64KB时截断
+)
+
+// 按优先级查找的README文件名（不区分大小写）
+var readmeNames = []string{"readme.md", "readme.txt", "readme", "说明.txt"}
+
+var (
+	annotations       = make(map[string]*StoredAnnotation) // 小写路径 → 说明
+	annotationsMutex  sync.RWMutex
+	annotationsLoaded sync.Once
+)
+
+func annotationKey(folderPath string) string {
+	return strings.ToLower(filepath.Clean(folderPath))
+}
+
+func ensureAnnotationsLoaded() {
+	annotationsLoaded.Do(func() {
+		annotationsMutex.Lock()
+		defer annotationsMutex.Unlock()
+		if err := loadJSONFile(annotationsFileName, &annotations); err != nil {
+			log.Printf("加载文件夹说明失败: %v", err)
+		}
+		if annotations == nil {
+			annotations = make(map[string]*StoredAnnotation)
+		}
+	})
+}
+
+// 读取文件夹说明，names为文件夹内的文件名，用于查找README和descript.ion
+// 返回的descriptions是descript.ion中的文件描述（小写文件名 → 描述）
+func loadFolderAnnotation(folderPath string, names []string) (*FolderAnnotation, map[string]string) {
+	ensureAnnotationsLoaded()
+
+	annotation := &FolderAnnotation{}
+
+	annotationsMutex.RLock()
+	if stored, ok := annotations[annotationKey(folderPath)]; ok {
+		annotation.Note = stored.Text
+		annotation.NoteUpdated = stored.Updated
+	}
+	annotationsMutex.RUnlock()
+
+	lowerNames := make(map[string]string, len(names))
+	for _, name := range names {
+		lowerNames[strings.ToLower(name)] = name
+	}
+
+	for _, readme := range readmeNames {
+		name, ok := lowerNames[readme]
+		if !ok {
+			continue
+		}
+		data, err := readFilePath(filepath.Join(folderPath, name))
+		if err != nil {
+			log.Printf("读取README失败: %s, 错误: %v", name, err)
+			continue
+		}
+		if len(data) > maxReadmeSize {
+			data = data[:maxReadmeSize]
+		}
+		annotation.Readme = detectAndConvertEncoding(data)
+		annotation.ReadmeName = name
+		break
+	}
+
+	var descriptions map[string]string
+	if name, ok := lowerNames["descript.ion"]; ok {
+		if data, err := readFilePath(filepath.Join(folderPath, name)); err == nil {
+			descriptions = parseDescriptIon(detectAndConvertEncoding(data))
+		}
+	}
+
+	if annotation.Note == "" && annotation.Readme == "" {
+		return nil, descriptions
+	}
+	return annotation, descriptions
+}
+
+// 解析descript.ion文件，每行格式为：文件名 描述，含空格的文件名用引号括起
+func parseDescriptIon(content string) map[string]string {
+	descriptions := make(map[string]string)
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		var name, desc string
+		if strings.HasPrefix(line, "\"") {
+			end := strings.Index(line[1:], "\"")
+			if end < 0 {
+				continue
+			}
+			name = line[1 : end+1]
+			desc = line[end+2:]
+		} else {
+			parts := strings.SplitN(line, " ", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			name, desc = parts[0], parts[1]
+		}
+
+		// Total Commander等工具会在描述末尾附加 \x04 开头的扩展数据
+		if idx := strings.IndexByte(desc, 0x04); idx >= 0 {
+			desc = desc[:idx]
+		}
+		desc = strings.ReplaceAll(strings.TrimSpace(desc), `\n`, "\n")
+
+		if name != "" && desc != "" {
+			descriptions[strings.ToLower(name)] = desc
+		}
+	}
+
+	return descriptions
+}
+
+// 文件夹说明API处理器
+// GET ?path= 获取说明；PUT/POST {"path","text"} 设置说明；DELETE ?path= 删除说明
+func apiAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	ensureAnnotationsLoaded()
+
+	switch r.Method {
+	case http.MethodGet:
+		folderPath := resolveClientPath(r.URL.Query().Get("path"))
+
+		annotationsMutex.RLock()
+		defer annotationsMutex.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if folderPath == "" {
+			list := []*StoredAnnotation{}
+			for _, stored := range annotations {
+				list = append(list, stored)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"annotations": list,
+				"count":       len(list),
+			})
+			return
+		}
+
+		stored, ok := annotations[annotationKey(folderPath)]
+		if !ok {
+			http.Error(w, "该文件夹没有说明", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(stored)
+
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Path string `json:"path"`
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+			http.Error(w, "请求格式错误，需要path参数", http.StatusBadRequest)
+			return
+		}
+		folderPath := resolveClientPath(req.Path)
+
+		stored := &StoredAnnotation{
+			Path:    clientPath(folderPath),
+			Text:    req.Text,
+			Updated: time.Now().Format("2006-01-02 15:04:05"),
+		}
+
+		annotationsMutex.Lock()
+		if strings.TrimSpace(req.Text) == "" {
+			delete(annotations, annotationKey(folderPath))
+		} else {
+			annotations[annotationKey(folderPath)] = stored
+		}
+		err := saveJSONFile(annotationsFileName, annotations)
+		annotationsMutex.Unlock()
+
+		if err != nil {
+			log.Printf("保存文件夹说明失败: %v", err)
+			http.Error(w, "保存说明失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("设置文件夹说明: %s, 来源IP: %s", folderPath, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(stored)
+
+	case http.MethodDelete:
+		folderPath := resolveClientPath(r.URL.Query().Get("path"))
+		if folderPath == "" {
+			http.Error(w, "路径参数不能为空", http.StatusBadRequest)
+			return
+		}
+
+		annotationsMutex.Lock()
+		_, existed := annotations[annotationKey(folderPath)]
+		delete(annotations, annotationKey(folderPath))
+		err := saveJSONFile(annotationsFileName, annotations)
+		annotationsMutex.Unlock()
+
+		if err != nil {
+			http.Error(w, "保存说明失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("删除文件夹说明: %s, 来源IP: %s", folderPath, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": existed,
+			"path":    clientPath(folderPath),
+		})
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"time"
+)
+
+// Authenticode数字签名
+//
+// PE文件的签名在证书表（安全目录）中，MSI的签名在\x05DigitalSignature流中，都是PKCS#7 SignedData。
+// 这里检查签名者对签名属性的签名和签名中记录的文件摘要（MSI不检查文件摘要），
+// 证书链是否可信由系统判断（Windows上调用WinVerifyTrust，其他系统不检查）。
+// 没有内嵌签名、只通过系统编录签名的文件（例如大部分系统自带的dll）显示为未签名。
+
+const (
+	signatureUnsigned  = "unsigned"
+	signatureSigned    = "signed"    // 签名和文件内容一致，没有检查证书链
+	signatureTrusted   = "trusted"   // 系统信任签名的证书
+	signatureUntrusted = "untrusted" // 签名有效但系统不信任，例如自签名证书
+	signatureInvalid   = "invalid"   // 签名损坏或文件被修改
+)
+
+const maxSignatureSize = 1 << 20
+
+type ExecutableSigner struct {
+	Status          string    `json:"status"`
+	Detail          string    `json:"detail,omitempty"`
+	Signer          string    `json:"signer,omitempty"`
+	Issuer          string    `json:"issuer,omitempty"`
+	NotBefore       time.Time `json:"notBefore,omitzero"`
+	NotAfter        time.Time `json:"notAfter,omitzero"`
+	DigestAlgorithm string    `json:"digestAlgorithm,omitempty"`
+	DigestChecked   bool      `json:"digestChecked"` // 是否校验了文件内容的摘要
+}
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+)
+
+var signatureHashes = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+	name string
+}{
+	{asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, crypto.SHA1, "SHA-1"},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, crypto.SHA256, "SHA-256"},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}, crypto.SHA384, "SHA-384"},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}, crypto.SHA512, "SHA-512"},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 5}, crypto.MD5, "MD5"},
+}
+
+func signatureHash(oid asn1.ObjectIdentifier) (crypto.Hash, string) {
+	for _, h := range signatureHashes {
+		if h.oid.Equal(oid) {
+			return h.hash, h.name
+		}
+	}
+	return 0, oid.String()
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7SignerInfo struct {
+	Version         int
+	IssuerAndSerial struct {
+		Issuer asn1.RawValue
+		Serial *big.Int
+	}
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthAttributes            asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthAttributes          asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// SpcIndirectDataContent：签名对象的类型和文件内容的摘要
+type spcIndirectData struct {
+	Data          asn1.RawValue
+	MessageDigest struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Digest    []byte
+	}
+}
+
+// 解析后的签名，digest是签名中记录的文件摘要
+type authenticode struct {
+	hash   crypto.Hash
+	digest []byte
+}
+
+// 解析PKCS#7签名并验证签名者，结果写入sig，返回签名中记录的文件摘要
+func parseAuthenticode(der []byte, sig *ExecutableSigner) (*authenticode, error) {
+	var ci pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil || !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("无法解析签名")
+	}
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil || len(sd.SignerInfos) != 1 {
+		return nil, errors.New("无法解析签名")
+	}
+	var content asn1.RawValue
+	var indirect spcIndirectData
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return nil, errors.New("无法解析签名内容")
+	}
+	if _, err := asn1.Unmarshal(content.FullBytes, &indirect); err != nil {
+		return nil, errors.New("无法解析签名内容")
+	}
+	result := &authenticode{digest: indirect.MessageDigest.Digest}
+	result.hash, sig.DigestAlgorithm = signatureHash(indirect.MessageDigest.Algorithm.Algorithm)
+	if !result.hash.Available() {
+		return nil, errors.New("不支持的摘要算法" + sig.DigestAlgorithm)
+	}
+
+	si := sd.SignerInfos[0]
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, errors.New("无法解析签名证书")
+	}
+	var signer *x509.Certificate
+	for _, c := range certs {
+		if c.SerialNumber.Cmp(si.IssuerAndSerial.Serial) == 0 && bytes.Equal(c.RawIssuer, si.IssuerAndSerial.Issuer.FullBytes) {
+			signer = c
+			break
+		}
+	}
+	if signer == nil {
+		return nil, errors.New("签名中没有签名者的证书")
+	}
+	sig.Signer = signer.Subject.CommonName
+	if sig.Signer == "" {
+		sig.Signer = signer.Subject.String()
+	}
+	sig.Issuer = signer.Issuer.CommonName
+	sig.NotBefore, sig.NotAfter = signer.NotBefore, signer.NotAfter
+
+	// 签名者签署的是签名属性（标签改为SET），其中的messageDigest是SpcIndirectDataContent内容的摘要
+	if len(si.AuthAttributes.FullBytes) == 0 {
+		return nil, errors.New("签名缺少签名属性")
+	}
+	signed := bytes.Clone(si.AuthAttributes.FullBytes)
+	signed[0] = 0x31
+	var attrs []pkcs7Attribute
+	if _, err := asn1.UnmarshalWithParams(signed, &attrs, "set"); err != nil {
+		return nil, errors.New("无法解析签名属性")
+	}
+	attrHash, _ := signatureHash(si.DigestAlgorithm.Algorithm)
+	if !attrHash.Available() {
+		return nil, errors.New("不支持的摘要算法")
+	}
+	var messageDigest []byte
+	for _, a := range attrs {
+		if a.Type.Equal(oidMessageDigest) {
+			asn1.Unmarshal(a.Values.Bytes, &messageDigest)
+		}
+	}
+	h := attrHash.New()
+	h.Write(content.Bytes)
+	if !bytes.Equal(h.Sum(nil), messageDigest) {
+		return nil, errors.New("签名内容与签名属性不符")
+	}
+	if err := signer.CheckSignature(signerAlgorithm(signer, attrHash), signed, si.EncryptedDigest); err != nil {
+		return nil, errors.New("签名者的签名无效")
+	}
+	return result, nil
+}
+
+func signerAlgorithm(cert *x509.Certificate, h crypto.Hash) x509.SignatureAlgorithm {
+	rsa := map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA1: x509.SHA1WithRSA, crypto.SHA256: x509.SHA256WithRSA, crypto.SHA384: x509.SHA384WithRSA, crypto.SHA512: x509.SHA512WithRSA, crypto.MD5: x509.MD5WithRSA}
+	ecdsa := map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA1: x509.ECDSAWithSHA1, crypto.SHA256: x509.ECDSAWithSHA256, crypto.SHA384: x509.ECDSAWithSHA384, crypto.SHA512: x509.ECDSAWithSHA512}
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		return rsa[h]
+	case x509.ECDSA:
+		return ecdsa[h]
+	}
+	return x509.UnknownSignatureAlgorithm
+}
+
+// 读取PE文件的证书表并校验文件摘要
+func peSignature(ctx context.Context, file *os.File, size int64, f *pe.File, dirs []pe.DataDirectory) ExecutableSigner {
+	sig := ExecutableSigner{Status: signatureUnsigned}
+	if len(dirs) <= pe.IMAGE_DIRECTORY_ENTRY_SECURITY || dirs[pe.IMAGE_DIRECTORY_ENTRY_SECURITY].Size == 0 {
+		return sig
+	}
+	sig.Status = signatureInvalid
+	// 安全目录的地址是文件偏移，不是相对虚拟地址
+	certOff := int64(dirs[pe.IMAGE_DIRECTORY_ENTRY_SECURITY].VirtualAddress)
+	certSize := int64(dirs[pe.IMAGE_DIRECTORY_ENTRY_SECURITY].Size)
+	if certSize < 8 || certSize > maxSignatureSize || certOff+certSize > size {
+		sig.Detail = "证书表超出文件范围"
+		return sig
+	}
+	table := make([]byte, certSize)
+	if _, err := file.ReadAt(table, certOff); err != nil {
+		sig.Detail = err.Error()
+		return sig
+	}
+	// WIN_CERTIFICATE：dwLength、wRevision、wCertificateType（2为PKCS#7）
+	length := int64(binary.LittleEndian.Uint32(table))
+	if length < 8 || length > certSize || binary.LittleEndian.Uint16(table[6:]) != 2 {
+		sig.Detail = "不支持的证书类型"
+		return sig
+	}
+	auth, err := parseAuthenticode(table[8:length], &sig)
+	if err != nil {
+		sig.Detail = err.Error()
+		return sig
+	}
+
+	// 摘要覆盖整个文件，但不包括校验和字段、安全目录项和证书表本身
+	var peOff [4]byte
+	if _, err := file.ReadAt(peOff[:], 0x3C); err != nil {
+		sig.Detail = err.Error()
+		return sig
+	}
+	optional := int64(binary.LittleEndian.Uint32(peOff[:])) + 24
+	dirsOff := optional + 96
+	if _, ok := f.OptionalHeader.(*pe.OptionalHeader64); ok {
+		dirsOff = optional + 112
+	}
+	securityEntry := dirsOff + pe.IMAGE_DIRECTORY_ENTRY_SECURITY*8
+	h := auth.hash.New()
+	ranges := [][2]int64{{0, optional + 64}, {optional + 68, securityEntry}, {securityEntry + 8, certOff}, {certOff + certSize, size}}
+	for _, r := range ranges {
+		if r[1] <= r[0] {
+			continue
+		}
+		if _, err := io.Copy(h, &contextReader{ctx, io.NewSectionReader(file, r[0], r[1]-r[0])}); err != nil {
+			sig.Detail = err.Error()
+			return sig
+		}
+	}
+	sig.DigestChecked = true
+	if !bytes.Equal(h.Sum(nil), auth.digest) {
+		sig.Detail = "文件内容与签名不符，文件可能被修改过"
+		return sig
+	}
+	sig.Status, sig.Detail = signatureSigned, ""
+	return sig
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package main
+
+// 其他系统没有Windows的证书库，只检查签名本身，不判断证书链是否可信
+func verifySignatureTrust(path string, sig *ExecutableSigner) {}
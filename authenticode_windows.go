@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	wintrust           = syscall.NewLazyDLL("wintrust.dll")
+	procWinVerifyTrust = wintrust.NewProc("WinVerifyTrust")
+)
+
+// WINTRUST_ACTION_GENERIC_VERIFY_V2 {00AAC56B-CD44-11D0-8CC2-00C04FC295EE}
+var actionGenericVerifyV2 = syscall.GUID{Data1: 0x00AAC56B, Data2: 0xCD44, Data3: 0x11D0, Data4: [8]byte{0x8C, 0xC2, 0x00, 0xC0, 0x4F, 0xC2, 0x95, 0xEE}}
+
+type wintrustFileInfo struct {
+	cbStruct       uint32
+	pcwszFilePath  *uint16
+	hFile          uintptr
+	pgKnownSubject *syscall.GUID
+}
+
+type wintrustData struct {
+	cbStruct            uint32
+	pPolicyCallbackData uintptr
+	pSIPClientData      uintptr
+	dwUIChoice          uint32
+	fdwRevocationChecks uint32
+	dwUnionChoice       uint32
+	pFile               *wintrustFileInfo
+	dwStateAction       uint32
+	hWVTStateData       uintptr
+	pwszURLReference    *uint16
+	dwProvFlags         uint32
+	dwUIContext         uint32
+	pSignatureSettings  uintptr
+}
+
+const (
+	wtdUINone                = 2
+	wtdChoiceFile            = 1
+	wtdStateActionVerify     = 1
+	wtdStateActionClose      = 2
+	wtdCacheOnlyURLRetrieval = 0x1000 // 不联网检查吊销
+	trustENoSignature        = 0x800B0100
+	trustEBadDigest          = 0x80096010
+	trustENoSignerCert       = 0x80096002
+	trustECertSignature      = 0x80096004
+	certEExpired             = 0x800B0101
+	certEUntrustedRoot       = 0x800B0109
+	certEChaining            = 0x800B010A
+	certERevoked             = 0x800B010C
+	certEUntrustedTestRoot   = 0x800B010D
+	certEWrongUsage          = 0x800B0110
+	trustEExplicitDistrust   = 0x800B0111
+)
+
+var trustErrors = map[uint32]string{
+	certEExpired:           "证书已过期",
+	certEUntrustedRoot:     "根证书不受信任",
+	certERevoked:           "证书已被吊销",
+	trustEExplicitDistrust: "证书被明确标记为不信任",
+	certEChaining:          "无法构建证书链",
+	certEWrongUsage:        "证书不能用于代码签名",
+	certEUntrustedTestRoot: "测试根证书不受信任",
+	trustENoSignerCert:     "签名者证书无效",
+	trustECertSignature:    "证书的签名无效",
+}
+
+// 调用WinVerifyTrust检查证书链，不显示界面，也不联网检查吊销
+func verifySignatureTrust(path string, sig *ExecutableSigner) {
+	pathPtr, err := syscall.UTF16PtrFromString(extendedPath(path))
+	if err != nil {
+		return
+	}
+	file := wintrustFileInfo{pcwszFilePath: pathPtr}
+	file.cbStruct = uint32(unsafe.Sizeof(file))
+	data := wintrustData{
+		dwUIChoice:    wtdUINone,
+		dwUnionChoice: wtdChoiceFile,
+		pFile:         &file,
+		dwStateAction: wtdStateActionVerify,
+		dwProvFlags:   wtdCacheOnlyURLRetrieval,
+	}
+	data.cbStruct = uint32(unsafe.Sizeof(data))
+	invalidHandle := ^uintptr(0)
+	ret, _, _ := procWinVerifyTrust.Call(invalidHandle, uintptr(unsafe.Pointer(&actionGenericVerifyV2)), uintptr(unsafe.Pointer(&data)))
+	data.dwStateAction = wtdStateActionClose
+	procWinVerifyTrust.Call(invalidHandle, uintptr(unsafe.Pointer(&actionGenericVerifyV2)), uintptr(unsafe.Pointer(&data)))
+
+	switch code := uint32(ret); code {
+	case 0:
+		sig.Status = signatureTrusted
+	case trustEBadDigest:
+		sig.Status, sig.Detail = signatureInvalid, "文件内容与签名不符，文件可能被修改过"
+	case trustENoSignature:
+		// 系统不支持的签名格式，保留自己的检查结果
+	default:
+		sig.Status = signatureUntrusted
+		if sig.Detail = trustErrors[code]; sig.Detail == "" {
+			sig.Detail = fmt.Sprintf("WinVerifyTrust错误0x%08X", code)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "context"
+
+// 搜索后端
+//
+// 所有Everything查询（搜索、模糊和拼音搜索的候选结果、快照、监视器等）都通过searchBackend执行。
+// Windows上默认使用本机或远程的Everything；其他系统上没有Everything，使用远程Everything或
+// 系统的文件索引工具（macOS的mdfind，Linux的plocate/locate或fd，见backend_other.go）。
+// 测试中替换为返回固定结果的后端，不需要安装Everything。
+
+type SearchBackend interface {
+	// 执行Everything查询，返回匹配的完整路径
+	Search(ctx context.Context, query string) ([]string, error)
+}
+
+// 使用Everything的搜索后端：远程Everything、Everything 1.5实例、SDK，最后回退到es.exe
+type everythingBackend struct{}
+
+var searchBackend SearchBackend = defaultSearchBackend()
@@ -0,0 +1,172 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// 其他系统的搜索后端
+//
+// 没有Everything时，用系统的文件索引工具取得候选路径，再按Everything的规则过滤：
+// 每个关键词都要出现在文件名中（含 / 的关键词匹配完整路径），支持 * 和 ? 通配符，
+// 以 ! 开头的关键词排除匹配的结果。ext:、size: 等Everything搜索函数不支持，会被忽略。
+// 配置了远程Everything时仍然使用远程Everything。
+
+// 依次尝试的索引工具，fd没有索引，从根目录遍历，速度最慢
+var localSearchTools = map[string][]string{
+	"darwin": {"mdfind", "fd"},
+	"linux":  {"plocate", "locate", "fd", "fdfind"},
+}
+
+func defaultSearchBackend() SearchBackend {
+	return localBackend{}
+}
+
+type localBackend struct{}
+
+var (
+	localSearchTool     string
+	localSearchToolOnce sync.Once
+)
+
+// 查找可用的索引工具，没有时返回空字符串
+func findLocalSearchTool() string {
+	localSearchToolOnce.Do(func() {
+		tools, ok := localSearchTools[runtime.GOOS]
+		if !ok {
+			tools = []string{"locate", "fd"}
+		}
+		for _, tool := range tools {
+			if path, err := exec.LookPath(tool); err == nil {
+				localSearchTool = path
+				log.Printf("使用 %s 搜索文件", path)
+				return
+			}
+		}
+		log.Printf("未找到文件索引工具（%s），搜索不可用", strings.Join(tools, "、"))
+	})
+	return localSearchTool
+}
+
+// 查询中的关键词（小写），搜索函数被忽略
+func localQueryTerms(query string) (include, exclude []string) {
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		negate := strings.HasPrefix(field, "!")
+		field = strings.Trim(strings.TrimPrefix(field, "!"), `"`)
+		if field == "" || strings.Contains(field, ":") {
+			continue
+		}
+		if negate {
+			exclude = append(exclude, field)
+		} else {
+			include = append(include, field)
+		}
+	}
+	return include, exclude
+}
+
+// 关键词是否匹配路径：含 / 的匹配完整路径，否则匹配文件名
+func localTermMatches(term, path string) bool {
+	target := strings.ToLower(path)
+	if !strings.Contains(term, "/") {
+		target = filepath.Base(target)
+	}
+	if strings.ContainsAny(term, "*?") {
+		matched, _ := filepath.Match(term, target)
+		return matched
+	}
+	return strings.Contains(target, term)
+}
+
+func localPathMatches(include, exclude []string, path string) bool {
+	for _, term := range include {
+		if !localTermMatches(term, path) {
+			return false
+		}
+	}
+	for _, term := range exclude {
+		if localTermMatches(term, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// 索引工具的命令行，工具只负责取得候选路径
+func localSearchCommand(ctx context.Context, tool string, include []string) *exec.Cmd {
+	switch name := filepath.Base(tool); name {
+	case "mdfind":
+		conditions := make([]string, len(include))
+		for i, term := range include {
+			pattern := term
+			if !strings.ContainsAny(term, "*?") {
+				pattern = "*" + term + "*"
+			}
+			conditions[i] = fmt.Sprintf(`kMDItemFSName == "%s"c`, strings.ReplaceAll(pattern, `"`, `\"`))
+		}
+		return exec.CommandContext(ctx, tool, strings.Join(conditions, " && "))
+	case "fd", "fdfind":
+		// fd只接受一个模式，其余关键词由过滤处理
+		args := []string{"--hidden", "--no-ignore", "--absolute-path", "--ignore-case"}
+		if strings.ContainsAny(include[0], "*?") {
+			args = append(args, "--glob")
+		} else {
+			args = append(args, "--fixed-strings")
+		}
+		return exec.CommandContext(ctx, tool, append(args, include[0], "/")...)
+	default:
+		// locate的多个模式默认任意一个匹配即可，-A要求全部匹配
+		args := []string{"-i"}
+		if name == "locate" {
+			args = append(args, "-A")
+		}
+		return exec.CommandContext(ctx, tool, append(args, include...)...)
+	}
+}
+
+func (localBackend) Search(ctx context.Context, query string) ([]string, error) {
+	if remoteEverythingEnabled() {
+		return searchWithRemoteEverything(ctx, query)
+	}
+
+	tool := findLocalSearchTool()
+	if tool == "" {
+		return nil, errors.New("未找到文件索引工具，请安装plocate/locate或fd，或配置远程Everything")
+	}
+	include, exclude := localQueryTerms(query)
+	if len(include) == 0 {
+		return nil, errors.New("查询中没有可搜索的关键词")
+	}
+
+	log.Printf("使用 %s 搜索: %s", filepath.Base(tool), query)
+	output, err := localSearchCommand(ctx, tool, include).Output()
+	if err != nil {
+		// locate没有结果时退出码为1
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 || len(output) > 0 {
+			return nil, fmt.Errorf("执行%s失败: %v", filepath.Base(tool), err)
+		}
+	}
+
+	var paths []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if path := scanner.Text(); path != "" && localPathMatches(include, exclude, path) {
+			paths = append(paths, path)
+		}
+	}
+	log.Printf("%s返回%d个有效路径", filepath.Base(tool), len(paths))
+	return applyQueryOptions(paths, queryOptionsFrom(ctx)), nil
+}
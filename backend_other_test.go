@@ -0,0 +1,28 @@
+//go:build !windows
+
+package main
+
+import "testing"
+
+func TestLocalPathMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		path  string
+		want  bool
+	}{
+		{"report", "/home/user/Report 2024.pdf", true},
+		{"report pdf", "/home/user/report.pdf", true},
+		{"report", "/home/report/notes.txt", false}, // 只匹配文件名
+		{"report/", "/home/report/notes.txt", true}, // 含 / 时匹配完整路径
+		{"*.mp4", "/media/movie.MP4", true},
+		{"*.mp4", "/media/movie.mkv", false},
+		{"movie !*.mkv", "/media/movie.mkv", false},
+		{"movie ext:mkv", "/media/movie.mp4", true}, // 忽略搜索函数
+	}
+	for _, tt := range tests {
+		include, exclude := localQueryTerms(tt.query)
+		if got := localPathMatches(include, exclude, tt.path); got != tt.want {
+			t.Errorf("query %q, path %q: got %v, want %v", tt.query, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package main
+
+func defaultSearchBackend() SearchBackend {
+	return everythingBackend{}
+}
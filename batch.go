@@ -0,0 +1,667 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 批量操作类型
+const (
+	BatchOpDelete    = "delete"
+	BatchOpMove      = "move"
+	BatchOpCopy      = "copy"
+	BatchOpZip       = "zip"
+	BatchOpTranscode = "transcode"
+	BatchOpVerify    = "verify" // 按校验文件校验，不修改文件，见checksumfile.go
+)
+
+// 批量任务状态
+const (
+	BatchStatusQueued    = "queued"
+	BatchStatusRunning   = "running"
+	BatchStatusCompleted = "completed"
+	BatchStatusCancelled = "cancelled"
+)
+
+const (
+	maxBatchJobs      = 100           // 最多保留的任务数
+	batchJobRetention = 1 * time.Hour // 已完成任务的保留时间
+	batchPollInterval = 500 * time.Millisecond
+)
+
+// 单个批量操作：对一组路径执行同一种操作
+type BatchOperation struct {
+	Op    string   `json:"op"`
+	Paths []string `json:"paths"`
+	Dest  string   `json:"dest,omitempty"` // move/copy的目标文件夹，zip的目标文件或文件夹，transcode的输出文件夹（可选）
+
+	checks []checksumCheck // verify操作展开后的校验项
+}
+
+// 单个条目的执行结果
+type BatchItem struct {
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	Status string `json:"status"` // pending, done, failed, skipped
+	Error  string `json:"error,omitempty"`
+	Output string `json:"output,omitempty"` // 生成的文件路径
+	UndoID string `json:"undoId,omitempty"` // 删除操作的撤销ID，见 /api/undo
+
+	Checksum *ChecksumResult `json:"checksum,omitempty"` // verify操作的校验结果
+}
+
+// 校验文件中记录的校验和与实际计算的结果
+type ChecksumResult struct {
+	Algorithm string `json:"algorithm,omitempty"`
+	Expected  string `json:"expected,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+	Match     bool   `json:"match"`
+}
+
+// 批量任务
+type BatchJob struct {
+	ID       string      `json:"id"`
+	Status   string      `json:"status"`
+	Total    int         `json:"total"`
+	Done     int         `json:"done"`
+	Failed   int         `json:"failed"`
+	Items    []BatchItem `json:"items"`
+	Created  string      `json:"created"`
+	Finished string      `json:"finished,omitempty"`
+
+	operations []BatchOperation
+	ctx        context.Context
+	cancel     context.CancelFunc
+	finishedAt time.Time
+}
+
+var (
+	batchJobs      = make(map[string]*BatchJob)
+	batchJobsMutex sync.RWMutex
+	batchQueue     = make(chan *BatchJob, maxBatchJobs)
+	batchWorker    sync.Once
+)
+
+// 启动后台工作协程，任务按提交顺序逐个执行
+func ensureBatchWorker() {
+	batchWorker.Do(func() {
+		go func() {
+			for job := range batchQueue {
+				runBatchJob(job)
+			}
+		}()
+	})
+}
+
+// 创建批量任务并加入队列
+func submitBatchJob(operations []BatchOperation) (*BatchJob, error) {
+	job := &BatchJob{
+		ID:         newRandomID(8),
+		Status:     BatchStatusQueued,
+		Created:    time.Now().Format("2006-01-02 15:04:05"),
+		operations: operations,
+	}
+	job.ctx, job.cancel = context.WithCancel(context.Background())
+
+	for i, op := range operations {
+		if op.Op == "queue-transcode" {
+			op.Op = BatchOpTranscode
+			operations[i].Op = BatchOpTranscode
+		}
+		switch op.Op {
+		case BatchOpDelete, BatchOpTranscode:
+		case BatchOpVerify:
+			if remoteEverythingEnabled() {
+				return nil, errRemoteFile
+			}
+		case BatchOpMove, BatchOpCopy, BatchOpZip:
+			if op.Dest == "" {
+				return nil, fmt.Errorf("%s操作需要dest参数", op.Op)
+			}
+		default:
+			return nil, fmt.Errorf("不支持的操作: %s", op.Op)
+		}
+		if op.Op == BatchOpTranscode && !ffmpegAvailable.Load() {
+			return nil, fmt.Errorf("ffmpeg不可用，无法转码")
+		}
+		if op.Op == BatchOpVerify {
+			operations[i].checks = expandChecksumChecks(op.Paths)
+			for _, check := range operations[i].checks {
+				job.Items = append(job.Items, BatchItem{Op: op.Op, Path: clientPath(check.path), Status: "pending",
+					Checksum: &ChecksumResult{Algorithm: check.algorithm, Expected: check.expected}})
+			}
+			continue
+		}
+		for _, path := range op.Paths {
+			job.Items = append(job.Items, BatchItem{Op: op.Op, Path: path, Status: "pending"})
+		}
+	}
+	job.Total = len(job.Items)
+	if job.Total == 0 {
+		return nil, fmt.Errorf("没有要处理的路径")
+	}
+
+	batchJobsMutex.Lock()
+	pruneBatchJobs()
+	if len(batchJobs) >= maxBatchJobs {
+		batchJobsMutex.Unlock()
+		return nil, fmt.Errorf("任务过多，请稍后再试")
+	}
+	batchJobs[job.ID] = job
+	batchJobsMutex.Unlock()
+
+	ensureBatchWorker()
+	batchQueue <- job
+	return job, nil
+}
+
+// 删除超过保留时间的已完成任务（调用方需持有写锁）
+func pruneBatchJobs() {
+	for id, job := range batchJobs {
+		if !job.finishedAt.IsZero() && time.Since(job.finishedAt) > batchJobRetention {
+			delete(batchJobs, id)
+		}
+	}
+}
+
+// 获取任务状态的副本
+func getBatchJob(id string) (BatchJob, bool) {
+	batchJobsMutex.RLock()
+	defer batchJobsMutex.RUnlock()
+
+	job, ok := batchJobs[id]
+	if !ok {
+		return BatchJob{}, false
+	}
+	snapshot := *job
+	snapshot.Items = append([]BatchItem(nil), job.Items...)
+	return snapshot, true
+}
+
+// 更新条目结果
+func setBatchItem(job *BatchJob, index int, output, undoID string, err error) {
+	batchJobsMutex.Lock()
+	defer batchJobsMutex.Unlock()
+
+	item := &job.Items[index]
+	if err != nil {
+		item.Status = "failed"
+		item.Error = err.Error()
+		job.Failed++
+	} else {
+		item.Status = "done"
+		item.Output = output
+		item.UndoID = undoID
+	}
+	job.Done++
+}
+
+// 更新verify条目的实际校验和（替换而不修改原结果，getBatchJob的副本共享该指针）
+func setBatchChecksum(job *BatchJob, index int, actual string) {
+	batchJobsMutex.Lock()
+	defer batchJobsMutex.Unlock()
+
+	item := &job.Items[index]
+	result := *item.Checksum
+	result.Actual = actual
+	result.Match = actual != "" && actual == result.Expected
+	item.Checksum = &result
+}
+
+func setBatchStatus(job *BatchJob, status string) {
+	batchJobsMutex.Lock()
+	defer batchJobsMutex.Unlock()
+
+	job.Status = status
+	if status == BatchStatusCompleted || status == BatchStatusCancelled {
+		job.finishedAt = time.Now()
+		job.Finished = job.finishedAt.Format("2006-01-02 15:04:05")
+	}
+}
+
+// 执行批量任务
+func runBatchJob(job *BatchJob) {
+	if job.ctx.Err() != nil {
+		setBatchStatus(job, BatchStatusCancelled)
+		return
+	}
+
+	setBatchStatus(job, BatchStatusRunning)
+	log.Printf("开始批量任务: %s, 共%d项", job.ID, job.Total)
+
+	index := 0
+	for _, op := range job.operations {
+		dest := resolveClientPath(op.Dest)
+
+		var zipWriter *zip.Writer
+		var zipFile *os.File
+		if op.Op == BatchOpZip && job.ctx.Err() == nil {
+			var err error
+			zipFile, err = createZipTarget(dest, job.ID)
+			if err != nil {
+				// 无法创建压缩文件时，该操作的所有条目都失败
+				for range op.Paths {
+					setBatchItem(job, index, "", "", err)
+					index++
+				}
+				continue
+			}
+			zipWriter = zip.NewWriter(zipFile)
+		}
+
+		if op.Op == BatchOpVerify {
+			for _, check := range op.checks {
+				if job.ctx.Err() != nil {
+					break
+				}
+				err := check.err
+				if err == nil {
+					var actual string
+					actual, err = verifyChecksum(job.ctx, check)
+					setBatchChecksum(job, index, actual)
+				}
+				if err != nil {
+					log.Printf("批量任务%s: 校验失败: %s, 错误: %v", job.ID, check.path, err)
+				}
+				setBatchItem(job, index, "", "", err)
+				index++
+			}
+			if job.ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		for _, clientSrc := range op.Paths {
+			if job.ctx.Err() != nil {
+				break
+			}
+
+			src := resolveClientPath(clientSrc)
+			var output, undoID string
+			var err error
+			if isRootPath(src) && (op.Op == BatchOpDelete || op.Op == BatchOpMove) {
+				setBatchItem(job, index, "", "", fmt.Errorf("不能删除或移动根目录: %s", src))
+				index++
+				continue
+			}
+			switch op.Op {
+			case BatchOpDelete:
+				var entry *TrashEntry
+				if entry, err = safeDelete(job.ctx, src); err == nil {
+					undoID = entry.ID
+				}
+			case BatchOpMove:
+				output, err = movePath(job.ctx, src, dest)
+			case BatchOpCopy:
+				output = filepath.Join(dest, filepath.Base(src))
+				err = copyPath(job.ctx, src, output)
+			case BatchOpZip:
+				output = zipFile.Name()
+				err = addToZip(job.ctx, zipWriter, src)
+			case BatchOpTranscode:
+				output, err = transcodeToFile(job.ctx, src, dest)
+			}
+
+			if err != nil {
+				log.Printf("批量任务%s: %s失败: %s, 错误: %v", job.ID, op.Op, src, err)
+			}
+			setBatchItem(job, index, clientPath(output), undoID, err)
+			index++
+		}
+
+		if zipWriter != nil {
+			if err := zipWriter.Close(); err != nil {
+				log.Printf("批量任务%s: 写入压缩文件失败: %v", job.ID, err)
+			}
+			zipFile.Close()
+		}
+		if job.ctx.Err() != nil {
+			break
+		}
+	}
+
+	if job.ctx.Err() != nil {
+		batchJobsMutex.Lock()
+		for i := range job.Items {
+			if job.Items[i].Status == "pending" {
+				job.Items[i].Status = "skipped"
+			}
+		}
+		batchJobsMutex.Unlock()
+		setBatchStatus(job, BatchStatusCancelled)
+		log.Printf("批量任务已取消: %s", job.ID)
+		return
+	}
+
+	setBatchStatus(job, BatchStatusCompleted)
+	snapshot, _ := getBatchJob(job.ID)
+	log.Printf("批量任务完成: %s, 成功%d项, 失败%d项", job.ID, snapshot.Done-snapshot.Failed, snapshot.Failed)
+}
+
+// 判断是否为盘符或共享的根目录
+func isRootPath(path string) bool {
+	clean := filepath.Clean(path)
+	return clean == "" || filepath.Dir(clean) == clean
+}
+
+// 移动文件或文件夹到目标文件夹，跨卷时先复制再删除
+func movePath(ctx context.Context, src, destDir string) (string, error) {
+	target := filepath.Join(destDir, filepath.Base(src))
+	if _, err := lstatPath(target); err == nil {
+		return "", fmt.Errorf("目标已存在: %s", target)
+	}
+
+	if err := os.Rename(extendedPath(src), extendedPath(target)); err == nil {
+		return target, nil
+	}
+
+	if err := copyPath(ctx, src, target); err != nil {
+		return "", err
+	}
+	return target, os.RemoveAll(extendedPath(src))
+}
+
+// 复制文件或文件夹，目标已存在时失败
+func copyPath(ctx context.Context, src, dest string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	info, err := lstatPath(src)
+	if err != nil {
+		return err
+	}
+	if _, err := lstatPath(dest); err == nil {
+		return fmt.Errorf("目标已存在: %s", dest)
+	}
+
+	if !info.IsDir() {
+		return copyFileContent(ctx, src, dest, info.Mode())
+	}
+
+	if err := os.MkdirAll(extendedPath(dest), 0755); err != nil {
+		return err
+	}
+	entries, err := readDirPath(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := copyPath(ctx, filepath.Join(src, entry.Name()), filepath.Join(dest, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFileContent(ctx context.Context, src, dest string, mode os.FileMode) error {
+	in, err := openPath(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(extendedPath(dest), os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, &contextReader{ctx: ctx, r: in})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(extendedPath(dest))
+	}
+	return err
+}
+
+// 在每次读取前检查是否已取消，使大文件复制可以中途停止
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// 创建压缩文件，dest为已存在的文件夹时在其中生成 batch-任务ID.zip
+func createZipTarget(dest, jobID string) (*os.File, error) {
+	if info, err := statPath(dest); err == nil && info.IsDir() {
+		dest = filepath.Join(dest, "batch-"+jobID+".zip")
+	} else if !strings.EqualFold(filepath.Ext(dest), ".zip") {
+		dest += ".zip"
+	}
+	file, err := os.OpenFile(extendedPath(dest), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// 将文件或文件夹加入压缩包，文件夹以其名称作为压缩包内的顶层目录
+func addToZip(ctx context.Context, zw *zip.Writer, src string) error {
+	base := filepath.Dir(src)
+	return filepath.Walk(extendedPath(src), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rel, err := filepath.Rel(extendedPath(base), path)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+			_, err = zw.CreateHeader(header)
+			return err
+		}
+		header.Method = zip.Deflate
+
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(writer, &contextReader{ctx: ctx, r: file})
+		return err
+	})
+}
+
+// 转码为MP4文件，输出到destDir（为空时输出到源文件所在文件夹）
+func transcodeToFile(ctx context.Context, src, destDir string) (string, error) {
+	if destDir == "" {
+		destDir = filepath.Dir(src)
+	}
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	output := filepath.Join(destDir, name+".mp4")
+	if strings.EqualFold(output, src) {
+		output = filepath.Join(destDir, name+"-transcoded.mp4")
+	}
+	if _, err := statPath(output); err == nil {
+		return "", fmt.Errorf("目标已存在: %s", output)
+	}
+
+	_, profile, err := getTranscodeProfile("")
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{"-i", src}, profile.args()...)
+	args = append(args, "-movflags", "+faststart", "-n", output)
+	cmd := exec.CommandContext(ctx, ffmpegBinary(), args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(output)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		err = fmt.Errorf("ffmpeg转码失败: %v, %s", err, strings.TrimSpace(lines[len(lines)-1]))
+		recordFFmpegFailure(err)
+		return "", err
+	}
+	return output, nil
+}
+
+// 批量操作API处理器
+// GET 列出任务，GET ?id= 查询任务进度；POST {"operations":[...]} 提交任务；DELETE ?id= 取消任务
+func apiBatchHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		if id := r.URL.Query().Get("id"); id != "" {
+			job, ok := getBatchJob(id)
+			if !ok {
+				http.Error(w, "任务不存在", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(job)
+			return
+		}
+
+		batchJobsMutex.RLock()
+		list := []BatchJob{}
+		for _, job := range batchJobs {
+			summary := *job
+			summary.Items = nil
+			list = append(list, summary)
+		}
+		batchJobsMutex.RUnlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Created > list[j].Created })
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jobs":  list,
+			"count": len(list),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Operations []BatchOperation `json:"operations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// 只读的verify操作不需要启用文件操作
+		for _, op := range req.Operations {
+			if op.Op != BatchOpVerify && !serverConfig.EnableFileOperations {
+				http.Error(w, "文件操作未启用，请在data\\config.json中设置enableFileOperations", http.StatusForbidden)
+				return
+			}
+		}
+
+		job, err := submitBatchJob(req.Operations)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("提交批量任务: %s, %d个操作, 共%d项, 来源IP: %s", job.ID, len(req.Operations), job.Total, r.RemoteAddr)
+
+		snapshot, _ := getBatchJob(job.ID)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(snapshot)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		batchJobsMutex.RLock()
+		job, ok := batchJobs[id]
+		batchJobsMutex.RUnlock()
+		if !ok {
+			http.Error(w, "任务不存在", http.StatusNotFound)
+			return
+		}
+
+		job.cancel()
+		log.Printf("取消批量任务: %s, 来源IP: %s", id, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"id":      id,
+		})
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 以Server-Sent Events推送任务进度，任务结束后关闭连接
+func apiBatchEventsHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if _, ok := getBatchJob(id); !ok {
+		http.Error(w, "任务不存在", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(batchPollInterval)
+	defer ticker.Stop()
+
+	lastDone, lastStatus := -1, ""
+	for {
+		job, ok := getBatchJob(id)
+		if !ok {
+			return
+		}
+
+		if job.Done != lastDone || job.Status != lastStatus {
+			lastDone, lastStatus = job.Done, job.Status
+			data, _ := json.Marshal(job)
+			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+
+		if job.Status == BatchStatusCompleted || job.Status == BatchStatusCancelled {
+			fmt.Fprintf(w, "event: end\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 生成模拟的搜索结果路径
+func fakePaths(n int) []string {
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = fmt.Sprintf(`D:\Photos\%04d\%02d\IMG_%06d.jpg`, 2000+i%25, i%12+1, i)
+	}
+	return paths
+}
+
+func BenchmarkNewPathList(b *testing.B) {
+	paths := fakePaths(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newPathList(paths)
+	}
+}
+
+func BenchmarkPathListSlice(b *testing.B) {
+	list := newPathList(fakePaths(1000000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := (i * MaxPageSize) % (list.Len() - MaxPageSize)
+		list.Slice(start, start+MaxPageSize)
+	}
+}
+
+func BenchmarkPageBounds(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		pageBounds(1000000, i%5000+1, DefaultPageSize)
+	}
+}
+
+func BenchmarkSortSearchResults(b *testing.B) {
+	base := make([]SearchResult, 5000)
+	for i := range base {
+		base[i] = SearchResult{
+			Name:     fmt.Sprintf("file%05d.txt", (i*7919)%5000),
+			Size:     int64((i * 104729) % 100000),
+			Modified: time.Unix(int64(i*3600), 0).Format("2006-01-02 15:04:05"),
+			IsDir:    i%10 == 0,
+		}
+	}
+	results := make([]SearchResult, len(base))
+
+	for _, sortBy := range []string{"name", "size", "date", "type"} {
+		b.Run(sortBy, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				copy(results, base)
+				sortSearchResults(results, sortBy, "asc")
+			}
+		})
+	}
+}
+
+func BenchmarkSplitTagQuery(b *testing.B) {
+	query := "ext:mp4 size:>1gb tag:watch-later tag:work 电影"
+	for i := 0; i < b.N; i++ {
+		splitTagQuery(query)
+	}
+}
+
+// 缓存命中时的搜索路径：查找缓存、分页并获取当前页的文件信息
+// （模拟路径不存在，stat很快失败，主要衡量缓存和分页的开销）
+func BenchmarkSearchCacheHit(b *testing.B) {
+	const query = "benchmark-cache-hit"
+	cacheMutex.Lock()
+	searchCache[query] = &SearchCache{Paths: newPathList(fakePaths(1000000)), Timestamp: time.Now()}
+	cacheMutex.Unlock()
+	defer func() {
+		cacheMutex.Lock()
+		delete(searchCache, query)
+		cacheMutex.Unlock()
+	}()
+
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, fromCache, err := searchFilesWithCache(ctx, query, i%1000+1, DefaultPageSize); err != nil || !fromCache {
+			b.Fatalf("fromCache=%t, err=%v", fromCache, err)
+		}
+	}
+}
+
+func BenchmarkPercentile(b *testing.B) {
+	latencies := make([]time.Duration, 10000)
+	for i := range latencies {
+		latencies[i] = time.Duration(i) * time.Microsecond
+	}
+	for i := 0; i < b.N; i++ {
+		percentile(latencies, 99)
+	}
+}
+
+func TestReadLoadTestQueries(t *testing.T) {
+	queries, err := readLoadTestQueries("", []string{"*.mp4", "ext:jpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(queries, "|") != "*.mp4|ext:jpg" {
+		t.Errorf("queries = %q", queries)
+	}
+}
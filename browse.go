@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 文件夹浏览的分页、排序和过滤参数
+type BrowseParams struct {
+	Page       int
+	PageSize   int
+	SortBy     string // name, size, date, type, rating, natural
+	Order      string // asc, desc
+	Filter     string // 名称包含的子串（不区分大小写）
+	Type       string // folder, video, image, file
+	ShowHidden bool   // 是否显示隐藏文件和系统文件
+}
+
+// 根据文件名判断结果类型
+func getResultType(name string, isDir bool) string {
+	if isDir {
+		return "folder"
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm":
+		return "video"
+	case ".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp", ".avif":
+		return "image"
+	default:
+		return "file"
+	}
+}
+
+// 解析分页参数，规则与搜索API一致
+func parsePageParams(r *http.Request) (int, int) {
+	page := 1
+	pageSize := DefaultPageSize
+
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+
+	if ps, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && ps > 0 && ps <= MaxPageSize {
+		pageSize = ps
+	}
+
+	return page, pageSize
+}
+
+// 解析浏览参数
+func parseBrowseParams(r *http.Request) BrowseParams {
+	params := BrowseParams{
+		SortBy: "name",
+		Order:  "asc",
+		Filter: strings.ToLower(strings.TrimSpace(r.URL.Query().Get("filter"))),
+		Type:   r.URL.Query().Get("type"),
+	}
+	params.Page, params.PageSize = parsePageParams(r)
+
+	switch sortBy := r.URL.Query().Get("sort"); sortBy {
+	case "name", "size", "date", "type", "rating", "natural":
+		params.SortBy = sortBy
+	}
+
+	if r.URL.Query().Get("order") == "desc" {
+		params.Order = "desc"
+	}
+
+	params.ShowHidden, _ = strconv.ParseBool(r.URL.Query().Get("showHidden"))
+
+	return params
+}
+
+// 判断结果是否满足过滤条件
+func matchBrowseFilter(result SearchResult, params BrowseParams) bool {
+	if params.Type != "" && result.Type != params.Type {
+		return false
+	}
+	if params.Filter != "" && !strings.Contains(strings.ToLower(result.Name), params.Filter) {
+		return false
+	}
+	return true
+}
+
+// 排序结果：文件夹始终在前，再按指定字段排序
+func sortSearchResults(results []SearchResult, sortBy, order string) {
+	less := func(a, b SearchResult) bool {
+		switch sortBy {
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case "date":
+			// Modified为"2006-01-02 15:04:05"格式，可直接按字符串比较
+			if a.Modified != b.Modified {
+				return a.Modified < b.Modified
+			}
+		case "type":
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+			extA := strings.ToLower(filepath.Ext(a.Name))
+			extB := strings.ToLower(filepath.Ext(b.Name))
+			if extA != extB {
+				return extA < extB
+			}
+		case "rating":
+			if a.Rating != b.Rating {
+				return a.Rating < b.Rating
+			}
+			if a.RatingCount != b.RatingCount {
+				return a.RatingCount < b.RatingCount
+			}
+		case "natural":
+			return naturalLess(a.Name, b.Name)
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if order == "desc" {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
+
+// 解析搜索排序参数，支持natural和relevance
+func parseSearchSort(r *http.Request) string {
+	switch sortBy := r.URL.Query().Get("sort"); sortBy {
+	case "natural", "relevance":
+		return sortBy
+	}
+	return ""
+}
+
+// 计算分页范围，返回切片的起止下标
+func pageBounds(totalCount, page, pageSize int) (int, int) {
+	start := (page - 1) * pageSize
+	if start > totalCount {
+		start = totalCount
+	}
+	end := start + pageSize
+	if end > totalCount {
+		end = totalCount
+	}
+	return start, end
+}
+
+// 将文件属性标志写入结果
+func applyFileAttributes(result *SearchResult, attrs uint32) {
+	result.Hidden = attrs&fileAttributeHidden != 0
+	result.System = attrs&fileAttributeSystem != 0
+	result.ReadOnly = attrs&fileAttributeReadOnly != 0
+}
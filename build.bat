@@ -8,7 +8,7 @@ echo - AVI/MKV
 echo - ־¼: ϸ״̬
 echo.
 
-go build -o everything-web-server-final.exe main.go
+go build -o everything-web-server-final.exe .
 if %errorlevel% equ 0 (
     echo ? ɹ!  everything-web-server-final.exe
     echo.
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 缓存目录管理
+//
+// 服务器生成、可以随时重新生成的文件都放在数据目录下的cache文件夹中，按种类分子目录：
+//   thumbnails  缩略图
+//   images      缩放和格式转换后的图片
+//   storyboard  进度条预览图
+//   icons       文件图标（Windows）
+//   jobs        截取片段、动图等任务的临时输出，发送后即删除
+// 实时转码和打包下载直接输出给客户端，不占用缓存目录。
+//
+// 每个种类有单独的容量上限（cacheQuotas，MB），整个缓存目录另有总上限（cacheQuotaMB）。
+// 缓存文件被使用时更新修改时间，超过上限时从最久未使用的文件开始删除，直到降到上限的90%。
+// 启动时清理上次运行遗留的文件：未完成的临时文件、任务输出、不成对的预览图和不属于任何种类的文件。
+//
+//   GET  /api/cache                              各种类的文件数、占用和上限
+//   POST /api/cache/purge {"category":"images"}  清空一个种类，category为空时清空所有种类
+
+const jobOutputMaxAge = time.Hour // 运行中清空jobs时只删除超过此时间的文件，避免删除正在发送的输出
+
+type cacheCategory struct {
+	Name         string
+	Description  string
+	DefaultQuota int  // 默认容量上限（MB），0表示只受总上限限制
+	Temporary    bool // 任务的临时输出，不按容量删除，启动时清空
+}
+
+var cacheCategories = []cacheCategory{
+	{"thumbnails", "缩略图", 256, false},
+	{"images", "缩放和转换的图片", 512, false},
+	{"storyboard", "进度条预览图", 256, false},
+	{"icons", "文件图标", 64, false},
+	{"jobs", "截取片段、动图等任务的临时输出", 0, true},
+}
+
+type cacheFile struct {
+	path string
+	size int64
+	mod  time.Time
+}
+
+func findCacheCategory(name string) (cacheCategory, bool) {
+	for _, c := range cacheCategories {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return cacheCategory{}, false
+}
+
+// 种类的容量上限（字节），0表示不限制；配置中的cacheQuotas优先
+func categoryQuota(c cacheCategory) int64 {
+	quota := c.DefaultQuota
+	if configured := serverConfig.CacheQuotas[c.Name]; configured > 0 {
+		quota = configured
+	}
+	if c.Temporary {
+		quota = 0
+	}
+	return int64(quota) << 20
+}
+
+// 生成中的临时文件：ensureCachedFile的 xxx.tmp.jpg 和预览图的 xxx.jpg.tmp.jpg
+func isCacheTempFile(name string) bool {
+	return strings.Contains(name, ".tmp.") || strings.HasSuffix(name, ".tmp")
+}
+
+// 列出目录中的缓存文件（不含生成中的临时文件），目录不存在时返回空
+func scanCacheFiles(ctx context.Context, dir string) ([]cacheFile, int64, error) {
+	var files []cacheFile
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || isCacheTempFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, cacheFile{path, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	return files, total, err
+}
+
+// 从最久未使用的文件开始删除，直到总大小不超过target，返回剩余的文件、总大小和删除的数量
+func evictCacheFiles(files []cacheFile, total, target int64) ([]cacheFile, int64, int) {
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+	removed := 0
+	for removed < len(files) && total > target {
+		f := files[removed]
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除缓存文件失败: %s, 错误: %v", f.path, err)
+			break
+		}
+		total -= f.size
+		removed++
+	}
+	return files[removed:], total, removed
+}
+
+// 按各种类的上限和总上限删除最久未使用的文件
+func enforceCacheQuotas(ctx context.Context, root string) error {
+	var all []cacheFile
+	var total int64
+	for _, c := range cacheCategories {
+		files, size, err := scanCacheFiles(ctx, filepath.Join(root, c.Name))
+		if err != nil {
+			return err
+		}
+		if quota := categoryQuota(c); quota > 0 && size > quota {
+			var removed int
+			files, size, removed = evictCacheFiles(files, size, quota*9/10)
+			log.Printf("%s缓存超过上限%s，删除了%d个文件，当前占用%s", c.Description, formatSize(quota), removed, formatSize(size))
+		}
+		if !c.Temporary {
+			all = append(all, files...)
+		}
+		total += size
+	}
+
+	quota := cacheQuota()
+	if total <= quota {
+		log.Printf("缓存目录占用%s，未超过上限%s", formatSize(total), formatSize(quota))
+		return nil
+	}
+	_, total, removed := evictCacheFiles(all, total, quota*9/10)
+	log.Printf("缓存目录超过上限%s，删除了%d个文件，当前占用%s", formatSize(quota), removed, formatSize(total))
+	return ctx.Err()
+}
+
+// 清空一个种类的缓存文件，返回删除的数量和释放的空间。
+// 生成中的临时文件不删除；任务输出只删除超过jobOutputMaxAge的
+func purgeCacheCategory(ctx context.Context, root string, c cacheCategory) (int, int64, error) {
+	files, _, err := scanCacheFiles(ctx, filepath.Join(root, c.Name))
+	if err != nil {
+		return 0, 0, err
+	}
+	removed, freed := 0, int64(0)
+	for _, f := range files {
+		if c.Temporary && time.Since(f.mod) < jobOutputMaxAge {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			log.Printf("删除缓存文件失败: %s, 错误: %v", f.path, err)
+			continue
+		}
+		removed++
+		freed += f.size
+	}
+	return removed, freed, nil
+}
+
+// 启动时清理上次运行遗留的文件，返回删除的数量和释放的空间
+func reconcileCacheDir(root string) (int, int64) {
+	removed, freed := 0, int64(0)
+	remove := func(path string, size int64) {
+		if err := os.RemoveAll(path); err != nil {
+			log.Printf("删除缓存文件失败: %s, 错误: %v", path, err)
+			return
+		}
+		removed++
+		freed += size
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return 0, 0
+	}
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		c, ok := findCacheCategory(entry.Name())
+		if !ok || !entry.IsDir() {
+			// 旧版本留下的或不属于任何种类的文件
+			_, size, _ := scanCacheFiles(context.Background(), path)
+			remove(path, size)
+			continue
+		}
+
+		filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if c.Temporary || isCacheTempFile(d.Name()) {
+				remove(file, info.Size())
+				return nil
+			}
+			// 预览图的雪碧图和WebVTT成对存在，缺少一个时另一个也不能使用
+			if c.Name == "storyboard" {
+				ext := filepath.Ext(file)
+				pair := map[string]string{".jpg": ".vtt", ".vtt": ".jpg"}[ext]
+				if pair != "" {
+					if _, err := os.Stat(strings.TrimSuffix(file, ext) + pair); os.IsNotExist(err) {
+						remove(file, info.Size())
+					}
+				}
+			}
+			return nil
+		})
+	}
+	return removed, freed
+}
+
+// 启动时清理缓存目录
+func reconcileCacheDirs() {
+	removed, freed := reconcileCacheDir(filepath.Join(getDataDir(), cacheDirName))
+	if removed > 0 {
+		log.Printf("清理了缓存目录中上次运行遗留的%d个文件，释放%s", removed, formatSize(freed))
+	}
+}
+
+// 缓存目录API处理器，GET 返回各种类的文件数、占用和上限
+func apiCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	root := filepath.Join(getDataDir(), cacheDirName)
+	categories := []map[string]interface{}{}
+	var total int64
+	for _, c := range cacheCategories {
+		files, size, err := scanCacheFiles(r.Context(), filepath.Join(root, c.Name))
+		if err != nil {
+			if r.Context().Err() == nil {
+				http.Error(w, "读取缓存目录失败: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		category := map[string]interface{}{
+			"name":        c.Name,
+			"description": c.Description,
+			"files":       len(files),
+			"size":        size,
+			"quota":       categoryQuota(c),
+		}
+		if len(files) > 0 {
+			oldest, newest := files[0].mod, files[0].mod
+			for _, f := range files[1:] {
+				if f.mod.Before(oldest) {
+					oldest = f.mod
+				}
+				if f.mod.After(newest) {
+					newest = f.mod
+				}
+			}
+			category["oldest"] = oldest.Format("2006-01-02 15:04:05")
+			category["newest"] = newest.Format("2006-01-02 15:04:05")
+		}
+		categories = append(categories, category)
+		total += size
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"root":       root,
+		"categories": categories,
+		"size":       total,
+		"quota":      cacheQuota(),
+	})
+}
+
+// 清空缓存API处理器，POST {"category":"images"}，category为空时清空所有种类
+func apiCachePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Category string `json:"category"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	categories := cacheCategories
+	if req.Category != "" {
+		c, ok := findCacheCategory(req.Category)
+		if !ok {
+			http.Error(w, "缓存种类不存在: "+req.Category, http.StatusBadRequest)
+			return
+		}
+		categories = []cacheCategory{c}
+	}
+
+	root := filepath.Join(getDataDir(), cacheDirName)
+	removed, freed := 0, int64(0)
+	for _, c := range categories {
+		n, size, err := purgeCacheCategory(r.Context(), root, c)
+		removed += n
+		freed += size
+		if err != nil {
+			log.Printf("清空%s缓存失败: %v", c.Description, err)
+			http.Error(w, "清空缓存失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	name := req.Category
+	if name == "" {
+		name = "全部"
+	}
+	log.Printf("清空缓存: %s，删除了%d个文件，释放%s，来源IP: %s", name, removed, formatSize(freed), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("删除了%d个文件，释放%s", removed, formatSize(freed)),
+		"removed": removed,
+		"freed":   freed,
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCacheFile(t *testing.T, path string, size int, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mod := time.Now().Add(-age)
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func cacheFileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestEnforceCacheQuotas(t *testing.T) {
+	root := t.TempDir()
+	serverConfig.CacheQuotas = map[string]int{"thumbnails": 1}
+	defer func() { serverConfig.CacheQuotas = nil }()
+
+	// 5个300KB的缩略图超过1MB上限，降到90%以内只能保留最近使用的3个
+	var thumbs []string
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(root, "thumbnails", string(rune('a'+i))+".jpg")
+		writeCacheFile(t, path, 300<<10, time.Duration(5-i)*time.Hour)
+		thumbs = append(thumbs, path)
+	}
+	image := filepath.Join(root, "images", "old.jpg")
+	writeCacheFile(t, image, 300<<10, 10*time.Hour)
+	tmp := filepath.Join(root, "thumbnails", "x.tmp.jpg")
+	writeCacheFile(t, tmp, 300<<10, 10*time.Hour)
+
+	if err := enforceCacheQuotas(context.Background(), root); err != nil {
+		t.Fatal(err)
+	}
+	for i, path := range thumbs {
+		if want := i >= 2; cacheFileExists(path) != want {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(path), !want, want)
+		}
+	}
+	if !cacheFileExists(image) {
+		t.Error("images category is under its quota and should be untouched")
+	}
+	if !cacheFileExists(tmp) {
+		t.Error("in-progress temp file should not be evicted")
+	}
+}
+
+func TestReconcileCacheDir(t *testing.T) {
+	root := t.TempDir()
+	keep := []string{
+		filepath.Join(root, "thumbnails", "a.jpg"),
+		filepath.Join(root, "storyboard", "b.jpg"),
+		filepath.Join(root, "storyboard", "b.vtt"),
+	}
+	drop := []string{
+		filepath.Join(root, "thumbnails", "a.tmp.jpg"),
+		filepath.Join(root, "storyboard", "c.jpg"), // 缺少WebVTT
+		filepath.Join(root, "storyboard", "d.vtt"), // 缺少雪碧图
+		filepath.Join(root, "jobs", "clip.mp4"),
+		filepath.Join(root, "obsolete", "x.bin"),
+	}
+	for _, path := range append(keep, drop...) {
+		writeCacheFile(t, path, 10, 0)
+	}
+
+	removed, _ := reconcileCacheDir(root)
+	if removed != len(drop) {
+		t.Errorf("removed = %d, want %d", removed, len(drop))
+	}
+	for _, path := range keep {
+		if !cacheFileExists(path) {
+			t.Errorf("%s should be kept", path)
+		}
+	}
+	for _, path := range drop {
+		if cacheFileExists(path) {
+			t.Errorf("%s should be removed", path)
+		}
+	}
+}
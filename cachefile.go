@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓存目录中由源文件生成的文件（预览图、缩放后的图片等）
+//
+// 缓存键由源文件路径、大小、修改时间和生成参数决定，源文件变化后自动使用新文件，
+// 旧文件由purge-caches定时任务按容量清理。缓存文件被使用时更新修改时间，清理时先删除最久未使用的。同一个缓存文件同时只生成一次，
+// 所有种类共享同时生成的数量上限。
+
+const (
+	maxCacheGenJobs    = 2
+	cacheGenTimeout    = 10 * time.Minute // 单个文件的生成时间上限
+	cacheTouchInterval = time.Hour        // 更新使用时间的最小间隔，避免每次命中都写磁盘
+)
+
+var (
+	cacheGenJobs  = make(map[string]chan struct{}) // 目标文件 → 生成完成时关闭
+	cacheGenMutex sync.Mutex
+	cacheGenSlots = make(chan struct{}, maxCacheGenJobs)
+)
+
+// 缓存键：同一文件内容和参数不变时生成结果相同
+func fileCacheKey(path string, info os.FileInfo, variant string) string {
+	sum := sha1.Sum([]byte(strings.ToLower(path) + fileETag(info) + variant))
+	return hex.EncodeToString(sum[:])
+}
+
+// 确保缓存文件target存在，不存在时调用generate生成
+// generate写入传入的临时文件（扩展名与target相同），成功后重命名为target。
+// 生成不随请求取消，避免客户端离开后白白浪费已完成的部分；等待中的请求取消时直接返回。
+func ensureCachedFile(ctx context.Context, target string, generate func(ctx context.Context, tmp string) error) error {
+	for {
+		if info, err := os.Stat(target); err == nil {
+			touchCacheFile(target, info)
+			return nil
+		}
+
+		cacheGenMutex.Lock()
+		done, running := cacheGenJobs[target]
+		if !running {
+			done = make(chan struct{})
+			cacheGenJobs[target] = done
+		}
+		cacheGenMutex.Unlock()
+
+		if running {
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		defer func() {
+			cacheGenMutex.Lock()
+			delete(cacheGenJobs, target)
+			cacheGenMutex.Unlock()
+			close(done)
+		}()
+		cacheGenSlots <- struct{}{}
+		defer func() { <-cacheGenSlots }()
+
+		genCtx, cancel := context.WithTimeout(context.Background(), cacheGenTimeout)
+		defer cancel()
+
+		ext := filepath.Ext(target)
+		tmp := strings.TrimSuffix(target, ext) + ".tmp" + ext
+		if err := generate(genCtx, tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, target); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	}
+}
+
+// 缓存命中时更新修改时间，容量清理按修改时间删除最久未使用的文件
+func touchCacheFile(path string, info os.FileInfo) {
+	if now := time.Now(); now.Sub(info.ModTime()) > cacheTouchInterval {
+		os.Chtimes(path, now, now)
+	}
+}
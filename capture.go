@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 屏幕截图和录屏
+//
+//   GET  /api/capture                                  是否启用、保存的文件夹和最近的截图
+//   POST /api/capture {"type": "video", "seconds": 10}  截图（默认）或录屏，返回保存的文件路径
+// 用ffmpeg抓取本机屏幕（Windows为gdigrab，Linux为x11grab，macOS为avfoundation），
+// 保存到配置的captureDir中；该文件夹在Everything索引中时，新文件随即可以搜索到。
+// 默认关闭，设置captureDir后启用，提交属于管理操作。同时只能进行一个截图或录屏。
+
+const (
+	defaultCaptureSeconds = 10
+	maxCaptureSeconds     = 300
+	captureFramerate      = 15
+)
+
+var (
+	captureMutex   sync.Mutex
+	errCaptureBusy = errors.New("正在进行另一个截图或录屏，请稍后再试")
+)
+
+type CaptureResult struct {
+	Path    string  `json:"path"`
+	Name    string  `json:"name"`
+	URL     string  `json:"url"`
+	Type    string  `json:"type"` // screenshot或video
+	Size    int64   `json:"size"`
+	Seconds int     `json:"seconds,omitempty"`
+	Elapsed float64 `json:"elapsed"` // 秒
+}
+
+// 截图和录屏保存的文件夹，相对路径位于数据目录下；未配置时返回空
+func captureDir() string {
+	dir := serverConfig.CaptureDir
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(getDataDir(), dir)
+}
+
+// 抓取屏幕的ffmpeg输入参数
+func captureInputArgs() []string {
+	rate := fmt.Sprint(captureFramerate)
+	switch runtime.GOOS {
+	case "windows":
+		return []string{"-f", "gdigrab", "-framerate", rate, "-i", "desktop"}
+	case "darwin":
+		return []string{"-f", "avfoundation", "-framerate", rate, "-capture_cursor", "1", "-i", "1:none"}
+	default:
+		display := os.Getenv("DISPLAY")
+		if display == "" {
+			display = ":0"
+		}
+		return []string{"-f", "x11grab", "-framerate", rate, "-i", display}
+	}
+}
+
+// 截图或录屏的完整ffmpeg参数（不含输出文件）和扩展名
+func captureArgs(kind string, seconds int) ([]string, string) {
+	args := append([]string{"-hide_banner", "-loglevel", "error"}, captureInputArgs()...)
+	if kind == "video" {
+		return append(args, "-t", fmt.Sprint(seconds), "-c:v", "libx264", "-preset", "ultrafast",
+			"-pix_fmt", "yuv420p", "-movflags", "+faststart"), ".mp4"
+	}
+	return append(args, "-frames:v", "1"), ".png"
+}
+
+// 截图或录屏，保存到captureDir中
+func runCapture(kind string, seconds int) (*CaptureResult, error) {
+	if !captureMutex.TryLock() {
+		return nil, errCaptureBusy
+	}
+	defer captureMutex.Unlock()
+
+	dir := captureDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	args, ext := captureArgs(kind, seconds)
+	prefix := "截图"
+	if kind == "video" {
+		prefix = "录屏"
+	}
+	name := prefix + "_" + time.Now().Format("20060102_150405")
+	output := filepath.Join(dir, name+ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(output); os.IsNotExist(err) {
+			break
+		}
+		output = filepath.Join(dir, fmt.Sprintf("%s_%d%s", name, i, ext))
+	}
+
+	// 录屏不随请求取消，客户端断开后仍然完成并保存
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(seconds)*time.Second+30*time.Second)
+	defer cancel()
+	start := time.Now()
+	out, err := exec.CommandContext(ctx, ffmpegBinary(), append(args, "-y", output)...).CombinedOutput()
+	if err != nil {
+		os.Remove(output)
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		return nil, fmt.Errorf("ffmpeg抓取屏幕失败: %v, %s", err, strings.TrimSpace(lines[len(lines)-1]))
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CaptureResult{
+		Path:    clientPath(output),
+		Name:    filepath.Base(output),
+		URL:     "/file/" + url.PathEscape(clientPath(output)),
+		Type:    kind,
+		Size:    info.Size(),
+		Elapsed: time.Since(start).Seconds(),
+	}
+	if kind == "video" {
+		result.Seconds = seconds
+	}
+	return result, nil
+}
+
+// 文件夹中最近的截图和录屏，新的在前
+func recentCaptures(dir string, limit int) []string {
+	recent := []string{}
+	if dir == "" {
+		return recent
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return recent
+	}
+	type capture struct {
+		path    string
+		modTime time.Time
+	}
+	var files []capture
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			files = append(files, capture{filepath.Join(dir, entry.Name()), info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for i := 0; i < len(files) && i < limit; i++ {
+		recent = append(recent, clientPath(files[i].path))
+	}
+	return recent
+}
+
+// 截图和录屏API处理器
+func apiCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		dir := captureDir()
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled":    dir != "",
+			"dir":        clientPath(dir),
+			"maxSeconds": maxCaptureSeconds,
+			"recent":     recentCaptures(dir, 20),
+		})
+
+	case http.MethodPost:
+		if captureDir() == "" {
+			http.Error(w, "截图和录屏未启用，请在data\\config.json中设置captureDir", http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			Type    string `json:"type"`
+			Seconds int    `json:"seconds"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		switch req.Type {
+		case "", "screenshot":
+			req.Type, req.Seconds = "screenshot", 0
+		case "video":
+			if req.Seconds == 0 {
+				req.Seconds = defaultCaptureSeconds
+			}
+			if req.Seconds < 1 || req.Seconds > maxCaptureSeconds {
+				http.Error(w, fmt.Sprintf("seconds应为1-%d之间的整数", maxCaptureSeconds), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "type应为screenshot或video", http.StatusBadRequest)
+			return
+		}
+
+		result, err := runCapture(req.Type, req.Seconds)
+		// 录屏最长maxCaptureSeconds，超过了请求开始时设置的写超时
+		restartWriteDeadline(w, apiWriteTimeout)
+		if err != nil {
+			log.Printf("屏幕抓取失败: %v, 来源IP: %s", err, r.RemoteAddr)
+			status := http.StatusInternalServerError
+			if err == errCaptureBusy {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		log.Printf("屏幕抓取: %s, 大小%d, 来源IP: %s", result.Path, result.Size, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCaptureArgs(t *testing.T) {
+	args, ext := captureArgs("screenshot", 0)
+	if ext != ".png" || !strings.Contains(strings.Join(args, " "), "-frames:v 1") {
+		t.Errorf("screenshot args = %v, %s", args, ext)
+	}
+	args, ext = captureArgs("video", 20)
+	if ext != ".mp4" || !strings.Contains(strings.Join(args, " "), "-t 20") {
+		t.Errorf("video args = %v, %s", args, ext)
+	}
+}
+
+func TestCaptureHandler(t *testing.T) {
+	post := func(body string) *httptest.ResponseRecorder {
+		return serveTestRequest(apiCaptureHandler, httptest.NewRequest(http.MethodPost, "/api/capture", strings.NewReader(body)))
+	}
+
+	// 默认关闭
+	if rec := post(""); rec.Code != http.StatusForbidden {
+		t.Errorf("disabled: status = %d, want 403", rec.Code)
+	}
+
+	dir := t.TempDir()
+	serverConfig.CaptureDir = dir
+	serverConfig.FFmpegPath = filepath.Join(dir, "no-such-ffmpeg")
+	defer func() { serverConfig.CaptureDir, serverConfig.FFmpegPath = "", "" }()
+
+	for _, body := range []string{`{"type":"audio"}`, `{"type":"video","seconds":-1}`, `{"type":"video","seconds":3600}`} {
+		if rec := post(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+
+	// ffmpeg无法运行时不留下文件
+	if rec := post(`{"type":"screenshot"}`); rec.Code != http.StatusInternalServerError {
+		t.Errorf("missing ffmpeg: status = %d, want 500", rec.Code)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("capture dir should be empty, got %d entries", len(entries))
+	}
+
+	var status struct {
+		Enabled bool     `json:"enabled"`
+		Recent  []string `json:"recent"`
+	}
+	decodeTestJSON(t, serveTestRequest(apiCaptureHandler, httptest.NewRequest(http.MethodGet, "/api/capture", nil)), &status)
+	if !status.Enabled || len(status.Recent) != 0 {
+		t.Errorf("status = %+v", status)
+	}
+}
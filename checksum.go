@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 文件校验和
+//
+// 下载时可以要求服务器附带整个文件的校验和，供下载工具在续传完成后校验：
+//   /file/路径?checksum=sha256（或sha1、sha512、md5、crc32），或请求头 Want-Digest: sha-256（RFC 3230）
+// 响应带 X-Checksum: sha256=十六进制 和 Digest: SHA-256=Base64（crc32没有对应的Digest算法名，只带X-Checksum）。
+// 校验和按路径、大小和修改时间缓存在 data\checksums.json；不超过checksumSyncMaxMB（默认1024MB）的文件
+// 在请求时计算，更大的文件在后台计算，计算完成前响应带 X-Checksum-Status: pending。
+
+const (
+	checksumsFileName        = "checksums.json"
+	defaultChecksumSyncMaxMB = 1024
+	checksumBufferSize       = 1 << 20
+)
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"sha512": sha512.New,
+	"md5":    md5.New,
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+}
+
+// Digest头中的算法名（RFC 3230）
+var digestAlgorithmNames = map[string]string{
+	"sha256": "SHA-256",
+	"sha1":   "SHA",
+	"sha512": "SHA-512",
+	"md5":    "MD5",
+}
+
+// 缓存的校验和，文件大小或修改时间变化后失效
+type checksumEntry struct {
+	Size     int64             `json:"size"`
+	Modified int64             `json:"modified"` // Unix秒
+	Sums     map[string]string `json:"sums"`     // 算法 → 十六进制
+}
+
+var (
+	checksums        map[string]checksumEntry // 小写路径 → 校验和
+	checksumsMutex   sync.Mutex
+	checksumsLoaded  sync.Once
+	checksumInFlight = make(map[string]chan struct{}) // 正在计算的 小写路径|算法
+	checksumQueued   = make(map[string]bool)          // 等待后台计算的 小写路径|算法
+	checksumSlot     = make(chan struct{}, 1)         // 后台计算同时只计算一个文件
+)
+
+func loadChecksums() {
+	checksumsLoaded.Do(func() {
+		checksums = make(map[string]checksumEntry)
+		if err := loadJSONFile(checksumsFileName, &checksums); err != nil {
+			log.Printf("读取校验和缓存失败: %v", err)
+		}
+	})
+}
+
+func checksumSyncLimit() int64 {
+	mb := serverConfig.ChecksumSyncMaxMB
+	if mb <= 0 {
+		mb = defaultChecksumSyncMaxMB
+	}
+	return int64(mb) << 20
+}
+
+// 已缓存的校验和
+func cachedChecksum(path string, info os.FileInfo, algo string) (string, bool) {
+	loadChecksums()
+	checksumsMutex.Lock()
+	defer checksumsMutex.Unlock()
+	entry, ok := checksums[strings.ToLower(path)]
+	if !ok || entry.Size != info.Size() || entry.Modified != info.ModTime().Unix() {
+		return "", false
+	}
+	sum, ok := entry.Sums[algo]
+	return sum, ok
+}
+
+func storeChecksum(path string, info os.FileInfo, algo, sum string) {
+	loadChecksums()
+	checksumsMutex.Lock()
+	defer checksumsMutex.Unlock()
+	key := strings.ToLower(path)
+	entry := checksums[key]
+	if entry.Size != info.Size() || entry.Modified != info.ModTime().Unix() || entry.Sums == nil {
+		entry = checksumEntry{Size: info.Size(), Modified: info.ModTime().Unix(), Sums: make(map[string]string)}
+	}
+	entry.Sums[algo] = sum
+	checksums[key] = entry
+	if err := saveJSONFile(checksumsFileName, checksums); err != nil {
+		log.Printf("保存校验和缓存失败: %v", err)
+	}
+}
+
+// 计算文件的校验和，优先使用缓存；同一文件同时只计算一次，其他请求等待结果
+func fileChecksum(ctx context.Context, path string, info os.FileInfo, algo string) (string, error) {
+	newHash, ok := checksumAlgorithms[algo]
+	if !ok {
+		return "", os.ErrInvalid
+	}
+	for {
+		if sum, ok := cachedChecksum(path, info, algo); ok {
+			return sum, nil
+		}
+
+		key := strings.ToLower(path) + "|" + algo
+		checksumsMutex.Lock()
+		done, running := checksumInFlight[key]
+		if !running {
+			done = make(chan struct{})
+			checksumInFlight[key] = done
+		}
+		checksumsMutex.Unlock()
+
+		if running {
+			select {
+			case <-done:
+				continue // 另一个请求已完成（或失败），重新检查缓存
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		sum, err := computeChecksum(ctx, path, newHash())
+		checksumsMutex.Lock()
+		delete(checksumInFlight, key)
+		checksumsMutex.Unlock()
+		close(done)
+		if err != nil {
+			return "", err
+		}
+		storeChecksum(path, info, algo, sum)
+		return sum, nil
+	}
+}
+
+func computeChecksum(ctx context.Context, path string, h hash.Hash) (string, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buf := make([]byte, checksumBufferSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := file.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 在后台计算校验和，已在计算中时不重复
+func queueChecksum(path string, info os.FileInfo, algo string) {
+	key := strings.ToLower(path) + "|" + algo
+	checksumsMutex.Lock()
+	_, running := checksumInFlight[key]
+	skip := running || checksumQueued[key]
+	if !skip {
+		checksumQueued[key] = true
+	}
+	checksumsMutex.Unlock()
+	if skip {
+		return
+	}
+	go func() {
+		checksumSlot <- struct{}{}
+		defer func() { <-checksumSlot }()
+		defer func() {
+			checksumsMutex.Lock()
+			delete(checksumQueued, key)
+			checksumsMutex.Unlock()
+		}()
+		if _, err := fileChecksum(context.Background(), path, info, algo); err != nil {
+			log.Printf("计算校验和失败: %s, 错误: %v", path, err)
+		} else {
+			log.Printf("后台计算校验和完成: %s (%s)", path, algo)
+		}
+	}()
+}
+
+// 请求要求的校验和算法：checksum参数，或Want-Digest头中第一个支持的算法，没有时返回空
+func requestedChecksum(r *http.Request) string {
+	if algo := strings.ToLower(r.URL.Query().Get("checksum")); algo != "" {
+		algo = strings.ReplaceAll(algo, "-", "")
+		if _, ok := checksumAlgorithms[algo]; ok {
+			return algo
+		}
+		return ""
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(r.Header.Get("Want-Digest"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		for algo, digestName := range digestAlgorithmNames {
+			if strings.EqualFold(name, digestName) && q > bestQ {
+				best, bestQ = algo, q
+			}
+		}
+	}
+	return best
+}
+
+// 按请求设置校验和响应头，大文件未缓存时在后台计算
+func setChecksumHeaders(w http.ResponseWriter, r *http.Request, path string, info os.FileInfo) {
+	algo := requestedChecksum(r)
+	if algo == "" || remoteEverythingEnabled() {
+		return
+	}
+
+	sum, ok := cachedChecksum(path, info, algo)
+	if !ok && info.Size() > checksumSyncLimit() {
+		queueChecksum(path, info, algo)
+		w.Header().Set("X-Checksum-Status", "pending")
+		return
+	}
+	if !ok {
+		var err error
+		if sum, err = fileChecksum(r.Context(), path, info, algo); err != nil {
+			log.Printf("计算校验和失败: %s, 错误: %v", path, err)
+			w.Header().Set("X-Checksum-Status", "error")
+			return
+		}
+	}
+
+	w.Header().Set("X-Checksum", algo+"="+sum)
+	raw, err := hex.DecodeString(sum)
+	if name, ok := digestAlgorithmNames[algo]; ok && err == nil {
+		w.Header().Set("Digest", name+"="+base64.StdEncoding.EncodeToString(raw))
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 校验文件（.sha256、.md5、.sfv、SHA256SUMS等）
+//
+// 文件旁边有记录了它的校验文件时，搜索和浏览结果带 checksumFile，页面显示“校验”按钮。
+// 校验通过批量任务（/api/batch 的verify操作）在服务器上重新计算校验和并与校验文件比较，
+// 计算结果同时写入下载用的校验和缓存（见checksum.go）。支持的格式：
+//   GNU：  十六进制  文件名   或   十六进制 *文件名
+//   BSD：  SHA256 (文件名) = 十六进制
+//   SFV：  文件名 CRC32（;开头为注释）
+//   只有一个校验和：校验的是去掉校验文件扩展名后的同名文件，例如 a.iso.sha256 对应 a.iso
+
+const (
+	maxChecksumFileSize  = 1 << 20
+	maxChecksumDirCached = 1000
+)
+
+// 校验文件扩展名对应的算法
+var checksumFileExtensions = map[string]string{
+	".sha256":    "sha256",
+	".sha256sum": "sha256",
+	".sha1":      "sha1",
+	".sha1sum":   "sha1",
+	".sha512":    "sha512",
+	".sha512sum": "sha512",
+	".md5":       "md5",
+	".md5sum":    "md5",
+	".sfv":       "crc32",
+}
+
+// 按文件名识别的校验文件（可以带.txt扩展名），算法为空时按校验和长度判断
+var checksumFileNames = map[string]string{
+	"sha256sums": "sha256",
+	"sha1sums":   "sha1",
+	"sha512sums": "sha512",
+	"md5sums":    "md5",
+	"checksums":  "",
+}
+
+// 十六进制长度对应的算法
+var checksumHexLengths = map[int]string{32: "md5", 40: "sha1", 64: "sha256", 128: "sha512"}
+
+// BSD格式中的算法名
+var bsdChecksumNames = map[string]string{"SHA256": "sha256", "SHA1": "sha1", "SHA512": "sha512", "MD5": "md5"}
+
+var (
+	bsdChecksumLine = regexp.MustCompile(`^([A-Za-z0-9-]+) ?\((.+)\) ?= ?([0-9A-Fa-f]+)$`)
+	hexChecksum     = regexp.MustCompile(`^[0-9A-Fa-f]+$`)
+)
+
+// 校验文件中的一条记录，Name是相对于校验文件所在文件夹的路径
+type checksumLine struct {
+	Name      string
+	Algorithm string
+	Expected  string
+}
+
+// 判断文件名是否为校验文件，返回其算法（为空时按每行的长度判断）
+func checksumFileAlgorithm(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	if algo, ok := checksumFileNames[strings.TrimSuffix(lower, ".txt")]; ok {
+		return algo, true
+	}
+	algo, ok := checksumFileExtensions[filepath.Ext(lower)]
+	return algo, ok
+}
+
+// 解析校验文件内容，无法识别的行忽略
+func parseChecksumFile(name, text string) []checksumLine {
+	algo, _ := checksumFileAlgorithm(name)
+	var lines []checksumLine
+	var single []string
+	for _, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		single = append(single, line)
+
+		if algo == "crc32" {
+			// SFV：文件名和CRC32之间用空格分隔，文件名本身可以包含空格
+			i := strings.LastIndexAny(line, " \t")
+			sum := line[i+1:]
+			if i > 0 && len(sum) == 8 && hexChecksum.MatchString(sum) {
+				lines = append(lines, checksumLine{Name: strings.TrimSpace(line[:i]), Algorithm: algo, Expected: strings.ToLower(sum)})
+			}
+			continue
+		}
+
+		if m := bsdChecksumLine.FindStringSubmatch(line); m != nil {
+			lineAlgo := bsdChecksumNames[strings.ToUpper(strings.ReplaceAll(m[1], "-", ""))]
+			if lineAlgo != "" && (algo == "" || algo == lineAlgo) && len(m[3]) == checksumHexLength(lineAlgo) {
+				lines = append(lines, checksumLine{Name: m[2], Algorithm: lineAlgo, Expected: strings.ToLower(m[3])})
+			}
+			continue
+		}
+
+		// GNU：开头的\表示文件名中的\和换行被转义
+		escaped := strings.HasPrefix(line, `\`)
+		line = strings.TrimPrefix(line, `\`)
+		sum, rest, ok := strings.Cut(line, " ")
+		if !ok || !hexChecksum.MatchString(sum) {
+			continue
+		}
+		lineAlgo := checksumHexLengths[len(sum)]
+		if lineAlgo == "" || (algo != "" && algo != lineAlgo) {
+			continue
+		}
+		// 名称前的空格或*表示文本或二进制模式，计算方式相同
+		rest = strings.TrimPrefix(rest, " ")
+		rest = strings.TrimPrefix(rest, "*")
+		if escaped {
+			rest = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(rest)
+		}
+		if rest != "" {
+			lines = append(lines, checksumLine{Name: rest, Algorithm: lineAlgo, Expected: strings.ToLower(sum)})
+		}
+	}
+
+	// 只有一个校验和时，对应去掉校验文件扩展名后的同名文件
+	if len(lines) == 0 && len(single) == 1 {
+		sum := strings.Fields(single[0])[0]
+		lineAlgo := algo
+		if lineAlgo == "" {
+			lineAlgo = checksumHexLengths[len(sum)]
+		}
+		target := strings.TrimSuffix(name, filepath.Ext(name))
+		if lineAlgo != "" && len(sum) == checksumHexLength(lineAlgo) && hexChecksum.MatchString(sum) && target != "" && filepath.Ext(name) != "" {
+			lines = append(lines, checksumLine{Name: target, Algorithm: lineAlgo, Expected: strings.ToLower(sum)})
+		}
+	}
+	return lines
+}
+
+func checksumHexLength(algo string) int {
+	if algo == "crc32" {
+		return 8
+	}
+	for n, a := range checksumHexLengths {
+		if a == algo {
+			return n
+		}
+	}
+	return 0
+}
+
+// 解析过的校验文件，按大小和修改时间判断是否需要重新读取
+type parsedChecksumFile struct {
+	size     int64
+	modified time.Time
+	lines    []checksumLine
+}
+
+// 文件夹中的校验文件名，按文件夹修改时间判断是否需要重新读取
+type checksumDirEntry struct {
+	modified time.Time
+	names    []string
+}
+
+var (
+	checksumFileCache = make(map[string]parsedChecksumFile) // 小写路径 → 解析结果
+	checksumDirCache  = make(map[string]checksumDirEntry)   // 小写文件夹路径 → 校验文件名
+	checksumFileMutex sync.Mutex
+)
+
+// 读取并解析校验文件
+func loadChecksumFile(path string) ([]checksumLine, error) {
+	info, err := statPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxChecksumFileSize {
+		return nil, errors.New("校验文件过大")
+	}
+	key := strings.ToLower(path)
+	checksumFileMutex.Lock()
+	cached, ok := checksumFileCache[key]
+	checksumFileMutex.Unlock()
+	if ok && cached.size == info.Size() && cached.modified.Equal(info.ModTime()) {
+		return cached.lines, nil
+	}
+
+	data, err := readFilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := parseChecksumFile(filepath.Base(path), decodeTextFile(data))
+	checksumFileMutex.Lock()
+	if len(checksumFileCache) >= maxChecksumDirCached {
+		clear(checksumFileCache)
+	}
+	checksumFileCache[key] = parsedChecksumFile{size: info.Size(), modified: info.ModTime(), lines: lines}
+	checksumFileMutex.Unlock()
+	return lines, nil
+}
+
+// 文件夹中的校验文件名，供搜索结果使用（浏览时已读取了文件夹，直接传入文件名）
+func checksumFilesIn(dir string) []string {
+	info, err := statPath(dir)
+	if err != nil {
+		return nil
+	}
+	key := strings.ToLower(dir)
+	checksumFileMutex.Lock()
+	cached, ok := checksumDirCache[key]
+	checksumFileMutex.Unlock()
+	if ok && cached.modified.Equal(info.ModTime()) {
+		return cached.names
+	}
+
+	entries, err := readDirPath(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if _, ok := checksumFileAlgorithm(entry.Name()); ok && !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	checksumFileMutex.Lock()
+	if len(checksumDirCache) >= maxChecksumDirCached {
+		clear(checksumDirCache)
+	}
+	checksumDirCache[key] = checksumDirEntry{modified: info.ModTime(), names: names}
+	checksumFileMutex.Unlock()
+	return names
+}
+
+// 同一文件夹中的文件对应的校验记录
+type siblingChecksum struct {
+	file string // 校验文件名
+	line checksumLine
+}
+
+// 读取names中的校验文件，返回 小写文件名 → 校验记录，只包含同一文件夹中的文件；
+// 多个校验文件记录了同一个文件时使用第一个
+func siblingChecksums(dir string, names []string) map[string]siblingChecksum {
+	var result map[string]siblingChecksum
+	for _, name := range names {
+		if _, ok := checksumFileAlgorithm(name); !ok {
+			continue
+		}
+		lines, err := loadChecksumFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			target := filepath.Clean(filepath.FromSlash(line.Name))
+			if filepath.Base(target) != target {
+				continue
+			}
+			if result == nil {
+				result = make(map[string]siblingChecksum)
+			}
+			key := strings.ToLower(target)
+			if _, ok := result[key]; !ok {
+				result[key] = siblingChecksum{file: name, line: line}
+			}
+		}
+	}
+	return result
+}
+
+// 为搜索结果标记对应的校验文件，超出时间预算时不再读取其余文件夹
+func applyChecksumFiles(ctx context.Context, results []SearchResult) {
+	if remoteEverythingEnabled() {
+		return
+	}
+	dirs := make(map[string][]int)
+	var order []string
+	for i, result := range results {
+		if result.IsDir || result.Partial {
+			continue
+		}
+		dir := filepath.Dir(resolveClientPath(result.Path))
+		if _, ok := dirs[dir]; !ok {
+			order = append(order, dir)
+		}
+		dirs[dir] = append(dirs[dir], i)
+	}
+	for _, dir := range order {
+		if ctx.Err() != nil {
+			return
+		}
+		names := checksumFilesIn(dir)
+		if len(names) == 0 {
+			continue
+		}
+		sums := siblingChecksums(dir, names)
+		for _, i := range dirs[dir] {
+			if sum, ok := sums[strings.ToLower(results[i].Name)]; ok {
+				results[i].ChecksumFile = clientPath(filepath.Join(dir, sum.file))
+			}
+		}
+	}
+}
+
+// 批量校验的一项：文件路径和校验文件中记录的校验和，err不为空时该项直接失败
+type checksumCheck struct {
+	path      string
+	algorithm string
+	expected  string
+	err       error
+}
+
+// 展开verify操作的路径：校验文件展开为其中的每一项，其他文件在同一文件夹中查找记录了它的校验文件
+func expandChecksumChecks(paths []string) []checksumCheck {
+	var checks []checksumCheck
+	for _, clientSrc := range paths {
+		src := resolveClientPath(clientSrc)
+		dir := filepath.Dir(src)
+		if _, ok := checksumFileAlgorithm(filepath.Base(src)); ok {
+			lines, err := loadChecksumFile(src)
+			if os.IsNotExist(err) {
+				err = errors.New("文件不存在")
+			} else if err == nil && len(lines) == 0 {
+				err = errors.New("校验文件中没有可识别的校验和")
+			}
+			if err != nil {
+				checks = append(checks, checksumCheck{path: src, err: err})
+				continue
+			}
+			for _, line := range lines {
+				checks = append(checks, checksumCheck{
+					path:      filepath.Join(dir, filepath.FromSlash(line.Name)),
+					algorithm: line.Algorithm,
+					expected:  line.Expected,
+				})
+			}
+			continue
+		}
+
+		sum, ok := siblingChecksums(dir, checksumFilesIn(dir))[strings.ToLower(filepath.Base(src))]
+		if !ok {
+			checks = append(checks, checksumCheck{path: src, err: errors.New("没有找到记录该文件的校验文件")})
+			continue
+		}
+		checks = append(checks, checksumCheck{path: src, algorithm: sum.line.Algorithm, expected: sum.line.Expected})
+	}
+	return checks
+}
+
+// 重新计算文件的校验和（不使用缓存）并与期望值比较，返回实际的校验和
+func verifyChecksum(ctx context.Context, check checksumCheck) (string, error) {
+	info, err := statPath(check.path)
+	if os.IsNotExist(err) {
+		return "", errors.New("文件不存在")
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", errors.New("不是文件")
+	}
+	newHash, ok := checksumAlgorithms[check.algorithm]
+	if !ok {
+		return "", errors.New("不支持的校验算法: " + check.algorithm)
+	}
+	sum, err := computeChecksum(ctx, check.path, newHash())
+	if err != nil {
+		return "", err
+	}
+	storeChecksum(check.path, info, check.algorithm, sum)
+	if sum != check.expected {
+		return sum, errors.New("校验和不符")
+	}
+	return sum, nil
+}
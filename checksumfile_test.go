@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试文件内容 "0123456789" 的校验和
+const (
+	testSHA256 = "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882"
+	testSHA1   = "87acec17cd9dcd20a716cc2cf67417b71c8a7016"
+	testMD5    = "781e5e245d69b566979b86e28d23f2c7"
+	testCRC32  = "a684c7c6"
+)
+
+func TestParseChecksumFile(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []checksumLine
+	}{
+		{"SHA256SUMS", testSHA256 + "  a.iso\r\n" + strings.ToUpper(testSHA256) + " *sub/b c.bin\n\n# 注释\n", []checksumLine{
+			{"a.iso", "sha256", testSHA256},
+			{"sub/b c.bin", "sha256", testSHA256},
+		}},
+		{"checksums.txt", testMD5 + "  a\n" + testSHA1 + "  b\nSHA256 (c.iso) = " + testSHA256 + "\n", []checksumLine{
+			{"a", "md5", testMD5},
+			{"b", "sha1", testSHA1},
+			{"c.iso", "sha256", testSHA256},
+		}},
+		// 扩展名指定了算法时忽略长度不符的行
+		{"files.md5", testSHA256 + "  a\n" + testMD5 + "  b\n", []checksumLine{{"b", "md5", testMD5}}},
+		{"a.iso.sha256", testSHA256 + "\n", []checksumLine{{"a.iso", "sha256", testSHA256}}},
+		{"a.iso.sha256", testSHA256 + " *a.iso\n", []checksumLine{{"a.iso", "sha256", testSHA256}}},
+		{"album.sfv", "; 由某工具生成\n01 track one.flac " + strings.ToUpper(testCRC32) + "\n02.flac\tzzzzzzzz\n", []checksumLine{
+			{"01 track one.flac", "crc32", testCRC32},
+		}},
+		{"MD5SUMS", `\` + testMD5 + `  a\\b` + "\n", []checksumLine{{`a\b`, "md5", testMD5}}},
+		{"SHA256SUMS", "not a checksum\n", nil},
+	}
+	for _, tt := range tests {
+		if got := parseChecksumFile(tt.name, tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseChecksumFile(%s, %q) = %+v, want %+v", tt.name, tt.text, got, tt.want)
+		}
+	}
+
+	for name, want := range map[string]bool{"SHA256SUMS.txt": true, "x.sha1sum": true, "a.SFV": true, "checksums.json": false, "md5.txt": false} {
+		if _, ok := checksumFileAlgorithm(name); ok != want {
+			t.Errorf("checksumFileAlgorithm(%s) = %v, want %v", name, ok, want)
+		}
+	}
+}
+
+// 提交批量任务并等待完成
+func runTestBatch(t *testing.T, body string) (*httptest.ResponseRecorder, BatchJob) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(body))
+	rec := serveTestRequest(apiBatchHandler, req)
+	if rec.Code != http.StatusAccepted {
+		return rec, BatchJob{}
+	}
+	var job BatchJob
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ = getBatchJob(job.ID); job.Status == BatchStatusCompleted {
+			return rec, job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("batch job %s did not finish", job.ID)
+	return rec, job
+}
+
+func TestChecksumVerifyJob(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir, "a.bin", "b.bin", "d.bin", "e.bin")
+	os.WriteFile(filepath.Join(dir, "b.bin"), []byte("changed"), 0644)
+	sums := testSHA256 + "  a.bin\n" + testSHA256 + "  b.bin\n" + testSHA256 + "  c.bin\n"
+	os.WriteFile(filepath.Join(dir, "SHA256SUMS"), []byte(sums), 0644)
+	os.WriteFile(filepath.Join(dir, "d.bin.md5"), []byte(testMD5+"\n"), 0644)
+
+	// verify不需要启用文件操作，其他操作仍然需要
+	if serverConfig.EnableFileOperations {
+		t.Fatal("file operations unexpectedly enabled")
+	}
+	if rec, _ := runTestBatch(t, `{"operations":[{"op":"delete","paths":["x"]},{"op":"verify","paths":["x"]}]}`); rec.Code != http.StatusForbidden {
+		t.Errorf("delete with file operations disabled: status = %d", rec.Code)
+	}
+
+	body := strings.NewReplacer(`\`, `\\`).Replace(`{"operations":[{"op":"verify","paths":["` +
+		filepath.Join(dir, "SHA256SUMS") + `","` + filepath.Join(dir, "d.bin") + `","` + filepath.Join(dir, "e.bin") + `"]}]}`)
+	rec, job := runTestBatch(t, body)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if job.Total != 5 || job.Done != 5 || job.Failed != 3 {
+		t.Fatalf("total=%d done=%d failed=%d, items: %+v", job.Total, job.Done, job.Failed, job.Items)
+	}
+
+	want := []struct {
+		name, status, err string
+		match             bool
+	}{
+		{"a.bin", "done", "", true},
+		{"b.bin", "failed", "校验和不符", false},
+		{"c.bin", "failed", "文件不存在", false},
+		{"d.bin", "done", "", true},
+		{"e.bin", "failed", "没有找到记录该文件的校验文件", false},
+	}
+	for i, w := range want {
+		item := job.Items[i]
+		if item.Op != BatchOpVerify || filepath.Base(item.Path) != w.name || item.Status != w.status || item.Error != w.err {
+			t.Errorf("item %d = %+v, want %s %s %q", i, item, w.name, w.status, w.err)
+			continue
+		}
+		if w.name == "e.bin" {
+			continue
+		}
+		if item.Checksum == nil || item.Checksum.Match != w.match {
+			t.Errorf("item %s checksum = %+v, want match %v", w.name, item.Checksum, w.match)
+		}
+	}
+	if c := job.Items[1].Checksum; c.Expected != testSHA256 || c.Actual == "" || c.Actual == testSHA256 {
+		t.Errorf("mismatch result = %+v", c)
+	}
+	if c := job.Items[3].Checksum; c.Algorithm != "md5" || c.Actual != testMD5 {
+		t.Errorf("bare checksum result = %+v", c)
+	}
+
+	// 校验结果写入下载用的校验和缓存
+	info, _ := os.Stat(filepath.Join(dir, "a.bin"))
+	if sum, ok := cachedChecksum(filepath.Join(dir, "a.bin"), info, "sha256"); !ok || sum != testSHA256 {
+		t.Errorf("cached checksum = %q, %v", sum, ok)
+	}
+}
+
+func TestChecksumFileAnnotation(t *testing.T) {
+	dir := t.TempDir()
+	paths := createTestFiles(t, dir, "a.iso", "b.iso", "c.iso", filepath.Join("sub", "d.iso"))
+	os.WriteFile(filepath.Join(dir, "a.iso.sha256"), []byte(testSHA256+"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "album.sfv"), []byte("b.iso "+testCRC32+"\nsub/d.iso "+testCRC32+"\n"), 0644)
+
+	want := map[string]string{"a.iso": "a.iso.sha256", "b.iso": "album.sfv", "c.iso": "", "d.iso": "", "sub": ""}
+	check := func(mode string, results []SearchResult) {
+		for _, r := range results {
+			w, ok := want[r.Name]
+			if !ok {
+				continue
+			}
+			if got := filepath.Base(r.ChecksumFile); (w == "" && r.ChecksumFile != "") || (w != "" && got != w) {
+				t.Errorf("%s: %s checksumFile = %q, want %q", mode, r.Name, r.ChecksumFile, w)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/browse?path="+url.QueryEscape(dir), nil)
+	var browse BrowseResponse
+	decodeTestJSON(t, serveTestRequest(apiBrowseHandler, req), &browse)
+	check("browse", browse.Results)
+
+	useFakeBackend(t, paths)
+	req = httptest.NewRequest(http.MethodGet, "/api/search?q=.iso", nil)
+	var search SearchResponse
+	decodeTestJSON(t, serveTestRequest(apiSearchHandler, req), &search)
+	if len(search.Results) != 4 {
+		t.Fatalf("search returned %d results", len(search.Results))
+	}
+	check("search", search.Results)
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 存储清理建议
+//
+// GET /api/cleanup-suggestions?path=文件夹路径&minSizeMB=500&olderThanDays=365
+// 基于统计/磁盘占用使用的Everything元数据，找出：长期未修改的大文件、空文件夹、
+// 重复文件（大小相同且首尾内容的哈希相同）、安装包残留和临时文件。
+// 删除通过 /api/batch 的delete操作执行，需要在配置中启用文件操作，删除后可以撤销。
+// GET /cleanup?path= 为清理建议页面。
+
+const (
+	cleanupDefaultMinSizeMB  = 500
+	cleanupDefaultOlderDays  = 365
+	cleanupMaxItems          = 200     // 每类最多列出的条目
+	cleanupMinDuplicateSize  = 1 << 20 // 小于1MB的文件不检查重复
+	cleanupMaxHashedFiles    = 2000    // 最多计算哈希的文件数
+	cleanupHashChunk         = 64 << 10
+	cleanupMaxDuplicateGroup = 100 // 最多列出的重复文件组
+)
+
+// 残留文件的名称模式（小写）及说明
+var leftoverPatterns = []struct{ Pattern, Reason string }{
+	{"*.tmp", "临时文件"},
+	{"*.temp", "临时文件"},
+	{"~$*", "Office临时文件"},
+	{"*.crdownload", "未完成的Chrome下载"},
+	{"*.part", "未完成的下载"},
+	{"*.partial", "未完成的下载"},
+	{"*.!ut", "未完成的uTorrent下载"},
+	{"*.bak", "备份文件"},
+	{"*.old", "旧版本文件"},
+	{"*.dmp", "崩溃转储"},
+	{"thumbs.db", "缩略图缓存"},
+	{"desktop.ini.bak", "备份文件"},
+	{"*setup*.exe", "安装程序"},
+	{"*install*.exe", "安装程序"},
+	{"*.msi", "安装包"},
+}
+
+type CleanupItem struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Modified string `json:"modified,omitempty"`
+	IsDir    bool   `json:"isDir,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Group    int    `json:"group,omitempty"` // 重复文件的组号（从1开始）
+	Keep     bool   `json:"keep,omitempty"`  // 重复文件组中建议保留的一份
+}
+
+type CleanupCategory struct {
+	ID        string        `json:"id"` // large-old, empty-folders, duplicates, leftovers
+	Title     string        `json:"title"`
+	Count     int           `json:"count"`
+	TotalSize int64         `json:"totalSize"` // 删除建议的条目后可释放的空间
+	Items     []CleanupItem `json:"items"`
+}
+
+type CleanupResponse struct {
+	Path       string            `json:"path"`
+	Categories []CleanupCategory `json:"categories"`
+	CanDelete  bool              `json:"canDelete"` // 是否已启用文件操作
+	ElapsedMs  int64             `json:"elapsedMs"`
+}
+
+func newCleanupItem(e everythingEntry, reason string) CleanupItem {
+	item := CleanupItem{Path: clientPath(e.Path), Size: e.Size, IsDir: e.IsDir, Reason: reason}
+	if !e.Modified.IsZero() {
+		item.Modified = e.Modified.Format("2006-01-02 15:04:05")
+	}
+	return item
+}
+
+// 按大小排序并截断，统计可释放的空间
+func finishCategory(c *CleanupCategory) {
+	sort.SliceStable(c.Items, func(i, j int) bool { return c.Items[i].Size > c.Items[j].Size })
+	c.Count = len(c.Items)
+	for _, item := range c.Items {
+		if !item.Keep {
+			c.TotalSize += item.Size
+		}
+	}
+	if len(c.Items) > cleanupMaxItems {
+		c.Items = c.Items[:cleanupMaxItems]
+	}
+}
+
+// 长期未修改的大文件
+func findLargeOldFiles(entries []everythingEntry, minSize int64, olderThan time.Time) CleanupCategory {
+	c := CleanupCategory{ID: "large-old", Title: "长期未修改的大文件", Items: []CleanupItem{}}
+	for _, e := range entries {
+		if !e.IsDir && e.Size >= minSize && !e.Modified.IsZero() && e.Modified.Before(olderThan) {
+			c.Items = append(c.Items, newCleanupItem(e, fmt.Sprintf("%d天未修改", int(time.Since(e.Modified).Hours()/24))))
+		}
+	}
+	finishCategory(&c)
+	return c
+}
+
+// 空文件夹：没有任何条目以它为上级
+func findEmptyFolders(entries []everythingEntry) CleanupCategory {
+	c := CleanupCategory{ID: "empty-folders", Title: "空文件夹", Items: []CleanupItem{}}
+	parents := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		parents[strings.ToLower(filepath.Dir(e.Path))] = true
+	}
+	for _, e := range entries {
+		if e.IsDir && !parents[strings.ToLower(e.Path)] {
+			c.Items = append(c.Items, newCleanupItem(e, "空文件夹"))
+		}
+	}
+	finishCategory(&c)
+	return c
+}
+
+// 安装包残留和临时文件
+func findLeftovers(entries []everythingEntry) CleanupCategory {
+	c := CleanupCategory{ID: "leftovers", Title: "安装包残留和临时文件", Items: []CleanupItem{}}
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		name := strings.ToLower(filepath.Base(e.Path))
+		for _, p := range leftoverPatterns {
+			if ok, _ := filepath.Match(p.Pattern, name); ok {
+				c.Items = append(c.Items, newCleanupItem(e, p.Reason))
+				break
+			}
+		}
+	}
+	finishCategory(&c)
+	return c
+}
+
+// 文件首尾各64KB和大小的哈希，用于快速判断内容是否相同
+func quickFileHash(path string, size int64) (string, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha1.New()
+	fmt.Fprintf(h, "%d:", size)
+	if _, err := io.CopyN(h, file, cleanupHashChunk); err != nil && err != io.EOF {
+		return "", err
+	}
+	if size > 2*cleanupHashChunk {
+		if _, err := file.Seek(-cleanupHashChunk, io.SeekEnd); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(h, file); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 重复文件：先按大小分组，再对大小相同的文件计算首尾哈希
+func findDuplicates(ctx context.Context, entries []everythingEntry) CleanupCategory {
+	c := CleanupCategory{ID: "duplicates", Title: "重复文件", Items: []CleanupItem{}}
+
+	bySize := make(map[int64][]everythingEntry)
+	for _, e := range entries {
+		if !e.IsDir && e.Size >= cleanupMinDuplicateSize {
+			bySize[e.Size] = append(bySize[e.Size], e)
+		}
+	}
+	var sizes []int64
+	for size, files := range bySize {
+		if len(files) > 1 {
+			sizes = append(sizes, size)
+		}
+	}
+	// 先检查大文件，哈希数量达到上限时优先找出浪费空间最多的重复
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	hashed := 0
+	var groups [][]everythingEntry
+	for _, size := range sizes {
+		if ctx.Err() != nil || hashed >= cleanupMaxHashedFiles || len(groups) >= cleanupMaxDuplicateGroup {
+			break
+		}
+		byHash := make(map[string][]everythingEntry)
+		var order []string
+		for _, e := range bySize[size] {
+			sum, err := quickFileHash(e.Path, size)
+			hashed++
+			if err != nil {
+				continue
+			}
+			if _, ok := byHash[sum]; !ok {
+				order = append(order, sum)
+			}
+			byHash[sum] = append(byHash[sum], e)
+		}
+		for _, sum := range order {
+			if len(byHash[sum]) > 1 {
+				groups = append(groups, byHash[sum])
+			}
+		}
+	}
+
+	for i, group := range groups {
+		// 保留最早的一份
+		sort.SliceStable(group, func(a, b int) bool { return group[a].Modified.Before(group[b].Modified) })
+		for j, e := range group {
+			item := newCleanupItem(e, fmt.Sprintf("与其他%d个文件内容相同", len(group)-1))
+			item.Group = i + 1
+			item.Keep = j == 0
+			c.Items = append(c.Items, item)
+		}
+	}
+	// 按组排列，不按大小重新排序
+	c.Count = len(c.Items)
+	for _, item := range c.Items {
+		if !item.Keep {
+			c.TotalSize += item.Size
+		}
+	}
+	if hashed >= cleanupMaxHashedFiles {
+		log.Printf("重复文件检查达到上限，已计算%d个文件的哈希", hashed)
+	}
+	return c
+}
+
+// 清理建议API处理器
+func apiCleanupSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	folder := resolveClientPath(r.URL.Query().Get("path"))
+	if folder == "" {
+		http.Error(w, "缺少path参数", http.StatusBadRequest)
+		return
+	}
+	if info, err := statPath(folder); err != nil || !info.IsDir() {
+		if err != nil && !os.IsNotExist(err) {
+			http.Error(w, "访问文件夹失败: "+err.Error(), http.StatusInternalServerError)
+		} else {
+			http.Error(w, "文件夹不存在", http.StatusNotFound)
+		}
+		return
+	}
+
+	minSizeMB, olderDays := cleanupDefaultMinSizeMB, cleanupDefaultOlderDays
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"minSizeMB", &minSizeMB}, {"olderThanDays", &olderDays}} {
+		if s := r.URL.Query().Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				http.Error(w, p.name+"参数应为非负整数", http.StatusBadRequest)
+				return
+			}
+			*p.dst = n
+		}
+	}
+
+	start := time.Now()
+	entries, err := collectEntries(r.Context(), folderQuery(folder))
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消清理建议: %s", folder)
+		return
+	}
+	if err != nil {
+		log.Printf("生成清理建议失败: %s, 错误: %v", folder, err)
+		http.Error(w, "生成清理建议失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := &CleanupResponse{
+		Path: clientPath(folder),
+		Categories: []CleanupCategory{
+			findLargeOldFiles(entries, int64(minSizeMB)<<20, time.Now().AddDate(0, 0, -olderDays)),
+			findDuplicates(r.Context(), entries),
+			findLeftovers(entries),
+			findEmptyFolders(entries),
+		},
+		CanDelete: serverConfig.EnableFileOperations && canAdmin(r),
+		ElapsedMs: time.Since(start).Milliseconds(),
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+
+	var total int64
+	for _, c := range resp.Categories {
+		total += c.TotalSize
+	}
+	log.Printf("清理建议: %s，%d个条目，可释放约%s，用时%dms，来源IP: %s", folder, len(entries), formatSize(total), resp.ElapsedMs, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 清理建议页面
+func cleanupPageHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
+	folderJSON, _ := json.Marshal(r.URL.Query().Get("path"))
+
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    ` + csrfFetchScript(nonce) + `
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>清理建议 - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .container { max-width: 1000px; margin: 0 auto; }
+        .category { background: white; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); padding: 15px 20px; margin-bottom: 15px; }
+        .category h3 { margin: 0 0 8px; }
+        .meta { color: #888; font-size: 13px; }
+        .item { display: flex; gap: 8px; align-items: center; padding: 4px 0; border-bottom: 1px solid #f0f0f0; font-size: 13px; word-break: break-all; }
+        .item .size { width: 80px; flex-shrink: 0; text-align: right; color: #555; }
+        .item .path { flex: 1; }
+        .item.keep { color: #2e7d32; }
+        .group-sep { border-top: 2px solid #ddd; }
+        a { color: #4CAF50; }
+        button { padding: 6px 14px; background: #f44336; color: white; border: none; border-radius: 4px; cursor: pointer; }
+        button.small { padding: 2px 8px; font-size: 12px; }
+        .notice { padding: 10px; background: #fff3e0; border-radius: 4px; margin-bottom: 15px; font-size: 13px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h2>🧹 清理建议</h2>
+        <p><a href="/">← 返回首页</a> <span class="meta" id="info">分析中...</span></p>
+        <div id="content"></div>
+    </div>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({
+            deleteCategory: el => deleteCategory(Number(el.dataset.category)),
+            deleteItem: el => deletePaths([data.categories[el.dataset.category].items[el.dataset.item].path]),
+        });
+
+        const folder = ` + string(folderJSON) + `;
+        let data = null;
+
+        function formatSize(bytes) {
+            const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+            let i = 0;
+            while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
+            return bytes.toFixed(i ? 1 : 0) + ' ' + units[i];
+        }
+
+        function escapeHtml(s) {
+            return String(s).replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
+        }
+
+        async function load() {
+            try {
+                const response = await fetch('/api/cleanup-suggestions?path=' + encodeURIComponent(folder));
+                if (!response.ok) throw new Error(await response.text());
+                data = await response.json();
+                render();
+            } catch (error) {
+                document.getElementById('info').textContent = '分析失败: ' + error.message;
+            }
+        }
+
+        function render() {
+            document.getElementById('info').textContent = data.path + '（用时' + data.elapsedMs + 'ms）';
+            let html = data.canDelete ? '' : '<div class="notice">文件操作未启用，只能查看建议。在data\\config.json中设置enableFileOperations后可以直接删除（删除后可撤销）。</div>';
+            data.categories.forEach((c, ci) => {
+                html += '<div class="category"><h3>' + c.title + '</h3>';
+                html += '<div class="meta">' + c.count + ' 项' + (c.totalSize ? '，可释放 ' + formatSize(c.totalSize) : '') + (c.count > c.items.length ? '，仅列出前 ' + c.items.length + ' 项' : '') + '</div>';
+                if (data.canDelete && c.items.some(it => !it.keep)) {
+                    html += '<p><button data-click="deleteCategory" data-category="' + ci + '">删除勾选的条目</button></p>';
+                }
+                let lastGroup = 0;
+                c.items.forEach((it, i) => {
+                    const sep = it.group && it.group !== lastGroup && lastGroup ? ' group-sep' : '';
+                    lastGroup = it.group;
+                    html += '<div class="item' + (it.keep ? ' keep' : '') + sep + '">';
+                    if (data.canDelete) html += '<input type="checkbox" data-cat="' + ci + '" data-idx="' + i + '"' + (it.keep ? '' : ' checked') + '>';
+                    html += '<span class="size">' + (it.isDir ? '' : formatSize(it.size)) + '</span>';
+                    html += '<span class="path">' + escapeHtml(it.path) + ' <span class="meta">' + escapeHtml(it.reason || '') + (it.keep ? '（建议保留）' : '') + (it.modified ? ' • ' + it.modified : '') + '</span></span>';
+                    if (data.canDelete) html += '<button class="small" data-click="deleteItem" data-category="' + ci + '" data-item="' + i + '">删除</button>';
+                    html += '</div>';
+                });
+                html += '</div>';
+            });
+            document.getElementById('content').innerHTML = html;
+        }
+
+        function deleteCategory(ci) {
+            const paths = Array.from(document.querySelectorAll('input[data-cat="' + ci + '"]:checked'))
+                .map(box => data.categories[ci].items[parseInt(box.dataset.idx, 10)].path);
+            if (paths.length) deletePaths(paths);
+        }
+
+        // 通过批量操作删除，删除后可以在撤销期限内恢复
+        async function deletePaths(paths) {
+            if (!confirm('确定删除 ' + paths.length + ' 项？\n' + paths.slice(0, 10).join('\n') + (paths.length > 10 ? '\n...' : ''))) return;
+            const response = await fetch('/api/batch', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ operations: [{ op: 'delete', paths: paths }] })
+            });
+            if (!response.ok) {
+                alert('删除失败: ' + await response.text());
+                return;
+            }
+            const job = await response.json();
+            document.getElementById('info').textContent = '已提交删除任务 ' + job.id + '，共 ' + job.total + ' 项，完成后重新分析...';
+            const events = new EventSource('/api/batch/events?id=' + job.id);
+            events.addEventListener('end', () => { events.close(); load(); });
+            events.onerror = () => { events.close(); load(); };
+        }
+
+        load();
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
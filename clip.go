@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 视频片段截取
+//
+// GET /api/clip?path=&start=&end=&format=
+// start/end为秒数或 [hh:]mm:ss[.ms]；format为mp4、mkv、webm，默认与源文件相同。
+// 能直接复制音视频流时不重新编码（速度快，但起止位置对齐到关键帧），
+// 复制失败（编码与目标格式不兼容）或目标为webm时重新编码。
+
+// 片段的输出格式：扩展名 → Content-Type
+var clipFormats = map[string]string{
+	"mp4":  "video/mp4",
+	"mkv":  "video/x-matroska",
+	"webm": "video/webm",
+	"mov":  "video/quicktime",
+	"avi":  "video/x-msvideo",
+	"flv":  "video/x-flv",
+	"wmv":  "video/x-ms-wmv",
+}
+
+// 解析时间参数：秒数或 [hh:]mm:ss[.ms]
+func parseTimestamp(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("时间不能为空")
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("无效的时间: %s", s)
+	}
+	total := 0.0
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("无效的时间: %s", s)
+		}
+		total = total*60 + v
+	}
+	return total, nil
+}
+
+// 解析请求中的视频路径和时间范围，出错时已写入响应
+func parseVideoRange(w http.ResponseWriter, r *http.Request) (string, float64, float64, bool) {
+	query := r.URL.Query()
+
+	if query.Get("path") == "" {
+		http.Error(w, "缺少path参数", http.StatusBadRequest)
+		return "", 0, 0, false
+	}
+	filePath := resolveClientPath(query.Get("path"))
+	if _, err := statPath(filePath); err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "访问文件失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return "", 0, 0, false
+	}
+	if !isVideoFile(strings.ToLower(filepath.Ext(filePath))) {
+		http.Error(w, "不是视频文件", http.StatusBadRequest)
+		return "", 0, 0, false
+	}
+
+	start, err := parseTimestamp(query.Get("start"))
+	if err != nil {
+		http.Error(w, "start参数错误: "+err.Error(), http.StatusBadRequest)
+		return "", 0, 0, false
+	}
+	end, err := parseTimestamp(query.Get("end"))
+	if err != nil {
+		http.Error(w, "end参数错误: "+err.Error(), http.StatusBadRequest)
+		return "", 0, 0, false
+	}
+	if end <= start {
+		http.Error(w, "结束时间必须大于开始时间", http.StatusBadRequest)
+		return "", 0, 0, false
+	}
+	return filePath, start, end, true
+}
+
+// 片段文件名，如 video_00-01-30_00-02-00.mp4
+func clipFileName(src string, start, end float64, ext string) string {
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	stamp := func(t float64) string {
+		return strings.ReplaceAll(strings.SplitN(vttTimestamp(t), ".", 2)[0], ":", "-")
+	}
+	return name + "_" + stamp(start) + "_" + stamp(end) + ext
+}
+
+// 发送任务生成的临时文件作为下载，发送后删除。
+// 生成用时和下载用时都可能超过写超时，发送前取消请求开始时设置的写超时
+func serveJobOutput(w http.ResponseWriter, r *http.Request, output, downloadName, contentType string) {
+	defer os.Remove(output)
+	restartWriteDeadline(w, 0)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", downloadName))
+	w.Header().Set("Cache-Control", "no-store")
+	serveFileContent(w, r, output)
+}
+
+// 片段截取API处理器
+func apiClipHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ffmpegAvailable.Load() {
+		http.Error(w, "ffmpeg不可用，无法截取片段", http.StatusServiceUnavailable)
+		return
+	}
+
+	filePath, start, end, ok := parseVideoRange(w, r)
+	if !ok {
+		return
+	}
+
+	srcFormat := strings.TrimPrefix(strings.ToLower(filepath.Ext(filePath)), ".")
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = srcFormat
+	}
+	contentType, ok := clipFormats[format]
+	if !ok {
+		http.Error(w, "不支持的输出格式: "+format, http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("截取片段: %s，%.1fs - %.1fs，格式: %s，来源IP: %s", filePath, start, end, format, r.RemoteAddr)
+	begin := time.Now()
+
+	// -ss放在-i之前按关键帧快速定位
+	input := []string{"-ss", strconv.FormatFloat(start, 'f', 3, 64), "-i", filePath,
+		"-t", strconv.FormatFloat(end-start, 'f', 3, 64)}
+	ext := "." + format
+
+	var output string
+	var err error
+	copied := format != "webm"
+	if copied {
+		args := append(append([]string{}, input...), "-map", "0:v?", "-map", "0:a?", "-c", "copy", "-avoid_negative_ts", "make_zero")
+		if format == "mp4" || format == "mov" {
+			args = append(args, "-movflags", "+faststart")
+		}
+		output, err = runFFmpegJob(r.Context(), args, ext)
+		if err != nil && r.Context().Err() == nil {
+			log.Printf("直接复制失败，改为重新编码: %v", err)
+			copied = false
+		}
+	}
+	if !copied {
+		var encode []string
+		if format == "webm" {
+			encode = []string{"-c:v", "libvpx-vp9", "-crf", "32", "-b:v", "0", "-deadline", "realtime", "-cpu-used", "8", "-c:a", "libopus"}
+		} else {
+			_, profile, perr := getTranscodeProfile("")
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusInternalServerError)
+				return
+			}
+			encode = profile.args()
+		}
+		args := append(append([]string{}, input...), encode...)
+		if format == "mp4" || format == "mov" {
+			args = append(args, "-movflags", "+faststart")
+		}
+		output, err = runFFmpegJob(r.Context(), args, ext)
+	}
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("截取片段失败: %s, 错误: %v", filePath, err)
+			http.Error(w, "截取片段失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	mode := "直接复制"
+	if !copied {
+		mode = "重新编码"
+	}
+	log.Printf("片段截取完成: %s，%s，用时%v", filePath, mode, time.Since(begin).Round(time.Millisecond))
+	serveJobOutput(w, r, output, clipFileName(filePath, start, end, ext), contentType)
+}
+
+// 播放页中的片段截取控件：用当前播放位置设置起止时间后下载
+func clipControls(filePath, nonce string) string {
+	return `<div class="clip-controls" style="margin-top: 10px; padding: 10px; background: rgba(255,255,255,0.1); border-radius: 8px; display: flex; gap: 8px; align-items: center; flex-wrap: wrap; font-size: 13px;">
+            <span>✂️ 截取片段</span>
+            <button class="btn btn-secondary" data-click="setClipPoint" data-target="clipStart">设为起点</button>
+            <input id="clipStart" value="0:00" size="8" style="padding: 4px;">
+            <span>—</span>
+            <input id="clipEnd" value="0:30" size="8" style="padding: 4px;">
+            <button class="btn btn-secondary" data-click="setClipPoint" data-target="clipEnd">设为终点</button>
+            <select id="clipFormat" style="padding: 4px;">
+                <option value="">原格式</option>
+                <option value="mp4">MP4</option>
+                <option value="mkv">MKV</option>
+                <option value="webm">WebM</option>
+            </select>
+            <button class="btn btn-primary" data-click="downloadClip">下载片段</button>
+            <button class="btn btn-secondary" data-click="downloadAnimation" data-format="gif" title="最长15秒">生成GIF</button>
+            <button class="btn btn-secondary" data-click="downloadAnimation" data-format="webp" title="最长15秒">生成WebP</button>
+        </div>
+        <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({
+            setClipPoint: el => setClipPoint(el.dataset.target),
+            downloadClip: () => downloadClip(),
+            downloadAnimation: el => downloadAnimation(el.dataset.format),
+        });
+
+        function setClipPoint(id) {
+            const t = document.querySelector('.video-container video').currentTime;
+            const m = Math.floor(t / 60), s = (t % 60).toFixed(1);
+            document.getElementById(id).value = m + ':' + (s < 10 ? '0' : '') + s;
+        }
+
+        function clipQuery() {
+            return 'path=` + url.QueryEscape(clientPath(filePath)) + `' +
+                '&start=' + encodeURIComponent(document.getElementById('clipStart').value) +
+                '&end=' + encodeURIComponent(document.getElementById('clipEnd').value);
+        }
+
+        function downloadClip() {
+            const format = document.getElementById('clipFormat').value;
+            window.location.href = '/api/clip?' + clipQuery() + (format ? '&format=' + format : '');
+        }
+
+        function downloadAnimation(format) {
+            window.location.href = '/api/animation?' + clipQuery() + '&format=' + format;
+        }
+        </script>`
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// 主机剪贴板文本交换
+//
+//   GET  /api/clipboard                 读取主机剪贴板中的文本
+//   POST /api/clipboard {"text": "..."}  把文本写入主机剪贴板
+// 例如在手机上把网址推送到电脑的剪贴板，或者取回电脑上复制的文本。
+// Windows直接调用剪贴板API（见clipboard_windows.go），其他系统使用pbcopy、wl-copy、xclip等工具。
+// 剪贴板可能含有密码等敏感内容，读写都属于管理操作，日志中只记录长度。
+
+const maxClipboardBytes = 1 << 20
+
+var errClipboardTooLarge = fmt.Errorf("文本超过%d字节", maxClipboardBytes)
+
+// 剪贴板API处理器
+func apiClipboardHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		text, err := readClipboard()
+		if err != nil {
+			log.Printf("读取剪贴板失败: %v, 来源IP: %s", err, r.RemoteAddr)
+			http.Error(w, "读取剪贴板失败: "+err.Error(), clipboardErrorStatus(err))
+			return
+		}
+		truncated := len(text) > maxClipboardBytes
+		if truncated {
+			text = strings.ToValidUTF8(text[:maxClipboardBytes], "")
+		}
+
+		log.Printf("读取剪贴板: %d字节, 来源IP: %s", len(text), r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"text":      text,
+			"length":    utf8.RuneCountInString(text),
+			"truncated": truncated,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Text *string `json:"text"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxClipboardBytes*2)).Decode(&req); err != nil || req.Text == nil {
+			http.Error(w, "请求格式错误，需要text参数", http.StatusBadRequest)
+			return
+		}
+		if len(*req.Text) > maxClipboardBytes {
+			http.Error(w, errClipboardTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		// 剪贴板文本以NUL结尾，其中的NUL之后的内容会丢失
+		text := strings.ReplaceAll(*req.Text, "\x00", "")
+
+		if err := writeClipboard(text); err != nil {
+			log.Printf("写入剪贴板失败: %v, 来源IP: %s", err, r.RemoteAddr)
+			http.Error(w, "写入剪贴板失败: "+err.Error(), clipboardErrorStatus(err))
+			return
+		}
+
+		log.Printf("写入剪贴板: %d字节, 来源IP: %s", len(text), r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"length":  utf8.RuneCountInString(text),
+		})
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+func clipboardErrorStatus(err error) int {
+	if errors.Is(err, errClipboardUnsupported) {
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
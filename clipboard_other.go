@@ -0,0 +1,59 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// macOS使用pbpaste/pbcopy，其他系统按顺序查找Wayland的wl-paste/wl-copy、xclip和xsel
+
+var errClipboardUnsupported = errors.New("没有找到剪贴板工具，请安装wl-clipboard、xclip或xsel")
+
+// 读取和写入剪贴板的命令
+func clipboardCommands() (read, write []string, err error) {
+	candidates := [][2][]string{
+		{{"wl-paste", "--no-newline"}, {"wl-copy"}},
+		{{"xclip", "-selection", "clipboard", "-o"}, {"xclip", "-selection", "clipboard", "-i"}},
+		{{"xsel", "--clipboard", "--output"}, {"xsel", "--clipboard", "--input"}},
+	}
+	if runtime.GOOS == "darwin" {
+		candidates = [][2][]string{{{"pbpaste"}, {"pbcopy"}}}
+	} else if os.Getenv("WAYLAND_DISPLAY") == "" {
+		candidates = candidates[1:]
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0][0]); err == nil {
+			return c[0], c[1], nil
+		}
+	}
+	return nil, nil, errClipboardUnsupported
+}
+
+func readClipboard() (string, error) {
+	read, _, err := clipboardCommands()
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command(read[0], read[1:]...).Output()
+	if err != nil {
+		// 剪贴板为空时wl-paste和xclip返回非0
+		return "", nil
+	}
+	return string(out), nil
+}
+
+func writeClipboard(text string) error {
+	_, write, err := clipboardCommands()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(write[0], write[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	// xclip和xsel在后台继续持有剪贴板，不能等待它们的输出管道关闭
+	return cmd.Run()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClipboardHandlerValidation(t *testing.T) {
+	tests := []struct {
+		method, body string
+		want         int
+	}{
+		{http.MethodPost, `{}`, http.StatusBadRequest},
+		{http.MethodPost, `not json`, http.StatusBadRequest},
+		{http.MethodPost, `{"text":"` + strings.Repeat("a", maxClipboardBytes+1) + `"}`, http.StatusRequestEntityTooLarge},
+		{http.MethodDelete, ``, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := serveTestRequest(apiClipboardHandler, httptest.NewRequest(tt.method, "/api/clipboard", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %.20q: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+}
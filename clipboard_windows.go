@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// user32在icon_windows.go中声明，kernel32在codepage_windows.go中声明
+var (
+	procOpenClipboard              = user32.NewProc("OpenClipboard")
+	procCloseClipboard             = user32.NewProc("CloseClipboard")
+	procEmptyClipboard             = user32.NewProc("EmptyClipboard")
+	procGetClipboardData           = user32.NewProc("GetClipboardData")
+	procSetClipboardData           = user32.NewProc("SetClipboardData")
+	procIsClipboardFormatAvailable = user32.NewProc("IsClipboardFormatAvailable")
+	procGlobalAlloc                = kernel32.NewProc("GlobalAlloc")
+	procGlobalFree                 = kernel32.NewProc("GlobalFree")
+	procGlobalLock                 = kernel32.NewProc("GlobalLock")
+	procGlobalUnlock               = kernel32.NewProc("GlobalUnlock")
+	procGlobalSize                 = kernel32.NewProc("GlobalSize")
+	procRtlMoveMemory              = kernel32.NewProc("RtlMoveMemory")
+)
+
+const (
+	cfUnicodeText = 13
+	gmemMoveable  = 0x0002
+)
+
+var errClipboardUnsupported = errors.New("剪贴板不可用")
+
+// 打开剪贴板，其他程序占用时稍等重试；剪贴板属于调用线程，调用方需要锁定线程
+func openClipboard() error {
+	var err error
+	for i := 0; i < 10; i++ {
+		var ret uintptr
+		if ret, _, err = procOpenClipboard.Call(0); ret != 0 {
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("OpenClipboard失败（剪贴板被其他程序占用，或服务器作为服务运行时没有桌面会话）: %v", err)
+}
+
+// 读取剪贴板中的Unicode文本，没有文本时返回空字符串
+func readClipboard() (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := openClipboard(); err != nil {
+		return "", err
+	}
+	defer procCloseClipboard.Call()
+
+	if ret, _, _ := procIsClipboardFormatAvailable.Call(cfUnicodeText); ret == 0 {
+		return "", nil
+	}
+	handle, _, err := procGetClipboardData.Call(cfUnicodeText)
+	if handle == 0 {
+		return "", fmt.Errorf("GetClipboardData失败: %v", err)
+	}
+	data, _, err := procGlobalLock.Call(handle)
+	if data == 0 {
+		return "", fmt.Errorf("GlobalLock失败: %v", err)
+	}
+	defer procGlobalUnlock.Call(handle)
+
+	size, _, _ := procGlobalSize.Call(handle)
+	if size < 2 {
+		return "", nil
+	}
+	buf := make([]uint16, size/2)
+	procRtlMoveMemory.Call(uintptr(unsafe.Pointer(&buf[0])), data, size/2*2)
+	return syscall.UTF16ToString(buf), nil
+}
+
+// 把文本写入剪贴板，替换原有内容
+func writeClipboard(text string) error {
+	utf16, err := syscall.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+	size := uintptr(len(utf16) * 2)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := openClipboard(); err != nil {
+		return err
+	}
+	defer procCloseClipboard.Call()
+
+	handle, _, err := procGlobalAlloc.Call(gmemMoveable, size)
+	if handle == 0 {
+		return fmt.Errorf("GlobalAlloc失败: %v", err)
+	}
+	data, _, err := procGlobalLock.Call(handle)
+	if data == 0 {
+		procGlobalFree.Call(handle)
+		return fmt.Errorf("GlobalLock失败: %v", err)
+	}
+	procRtlMoveMemory.Call(data, uintptr(unsafe.Pointer(&utf16[0])), size)
+	procGlobalUnlock.Call(handle)
+
+	procEmptyClipboard.Call()
+	// 成功后内存归系统所有，失败时需要自己释放
+	if ret, _, err := procSetClipboardData.Call(cfUnicodeText, handle); ret == 0 {
+		procGlobalFree.Call(handle)
+		return fmt.Errorf("SetClipboardData失败: %v", err)
+	}
+	return nil
+}
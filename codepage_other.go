@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import "golang.org/x/text/encoding/simplifiedchinese"
+
+// 其他系统的文件名都是UTF-8，快捷方式中的ANSI字符串按原样解码
+func decodeANSI(b []byte) string {
+	return string(b)
+}
+
+// 字符的GBK编码（双字节），不是双字节字符时返回0。没有系统代码页，使用x/text的GBK编码表
+func gbkCode(r rune) uint16 {
+	b, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(string(r)))
+	if err != nil || len(b) != 2 {
+		return 0
+	}
+	return uint16(b[0])<<8 | uint16(b[1])
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	kernel32                = syscall.NewLazyDLL("kernel32.dll")
+	procMultiByteToWideChar = kernel32.NewProc("MultiByteToWideChar")
+	procWideCharToMultiByte = kernel32.NewProc("WideCharToMultiByte")
+)
+
+// 按系统代码页（中文系统为GBK）解码
+func decodeANSI(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	n, _, _ := procMultiByteToWideChar.Call(0, 0, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), 0, 0)
+	if n == 0 {
+		return string(b)
+	}
+	buf := make([]uint16, n)
+	procMultiByteToWideChar.Call(0, 0, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), uintptr(unsafe.Pointer(&buf[0])), n)
+	return string(utf16.Decode(buf))
+}
+
+// 字符的GBK编码（双字节），不是双字节字符时返回0
+func gbkCode(r rune) uint16 {
+	units := utf16.Encode([]rune{r})
+	var buf [4]byte
+	var usedDefault int32
+	n, _, _ := procWideCharToMultiByte.Call(936, 0,
+		uintptr(unsafe.Pointer(&units[0])), uintptr(len(units)),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0,
+		uintptr(unsafe.Pointer(&usedDefault)))
+	if n != 2 || usedDefault != 0 {
+		return 0
+	}
+	return uint16(buf[0])<<8 | uint16(buf[1])
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 响应压缩中间件
+//
+// 根据Accept-Encoding对JSON、HTML等文本响应使用gzip或deflate压缩。
+// 媒体流、文件下载等路径不压缩，以免破坏Range请求和Content-Length。
+// brotli需要引入外部依赖，暂不支持。
+
+const minCompressSize = 1024 // 已知长度小于此值的响应不压缩
+
+// 不压缩的路径前缀
+var noCompressPrefixes = []string{
+	"/file/",
+	"/stream/",
+	"/transcode/",
+	"/thumbnail/",
+	"/download/",
+	"/api/batch/events",
+}
+
+// 可压缩的Content-Type前缀
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// 选择压缩方式，优先gzip
+func negotiateEncoding(acceptEncoding string) string {
+	gzipOK, deflateOK := false, false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		// q=0 表示不接受
+		rejected := false
+		for _, param := range fields[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+					rejected = true
+				}
+			}
+		}
+		if rejected {
+			continue
+		}
+		switch name {
+		case "gzip", "*":
+			gzipOK = true
+		case "deflate":
+			deflateOK = true
+		}
+	}
+	if gzipOK {
+		return "gzip"
+	}
+	if deflateOK {
+		return "deflate"
+	}
+	return ""
+}
+
+// 包装处理器，按需压缩响应
+func withCompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range noCompressPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// 在写入响应头时根据Content-Type决定是否压缩
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	writer      io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	if cw.shouldCompress(status) {
+		h := cw.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		// 压缩后内容不同，强校验ETag改为弱校验
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		if cw.encoding == "gzip" {
+			cw.writer = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.writer, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressResponseWriter) shouldCompress(status int) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if length, err := strconv.Atoi(h.Get("Content-Length")); err == nil && length < minCompressSize {
+		return false
+	}
+
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *compressResponseWriter) Flush() {
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// 供http.ResponseController访问底层连接（设置超时等）
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressResponseWriter) Close() error {
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+	return nil
+}
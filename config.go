@@ -0,0 +1,112 @@
+package main
+
+import "log"
+
+// 服务器配置，保存在数据目录的config.json中，文件不存在时使用默认值
+type ServerConfig struct {
+	// 监听端口，默认8080；命令行参数 -port 优先
+	Port int `json:"port"`
+	// 端口被占用时直接退出，不尝试后面的端口（命令行参数 -strict-port）
+	StrictPort bool `json:"strictPort"`
+	// 监听地址列表，如局域网和本机分别使用不同端口；为空时在所有地址上监听port
+	Listeners []ListenerConfig `json:"listeners"`
+	// 允许局域网中的设备执行管理操作（修改文件、清除缓存等），默认只允许本机
+	RemoteAdmin bool `json:"remoteAdmin"`
+	// 扩展名对应的MIME类型，如 {".heic": "image/heic"}，优先于内置类型和按内容识别
+	MimeTypes map[string]string `json:"mimeTypes"`
+	// 不发送Content-Security-Policy
+	DisableCSP bool `json:"disableCSP"`
+	// 浏览文件夹时不在后台预生成图片和视频的缩略图
+	DisableThumbnailPregen bool `json:"disableThumbnailPregen"`
+	// 允许删除、移动、复制等修改文件的操作，默认关闭
+	EnableFileOperations bool `json:"enableFileOperations"`
+	// 删除后可以撤销的时间（分钟），默认10分钟
+	UndoWindowMinutes int `json:"undoWindowMinutes"`
+	// 撤销期限过后移入回收站，而不是永久删除
+	UseRecycleBin bool `json:"useRecycleBin"`
+	// HTTPS证书和私钥文件，都设置时启用TLS（浏览器通过TLS使用HTTP/2）
+	TLSCertFile string `json:"tlsCertFile"`
+	TLSKeyFile  string `json:"tlsKeyFile"`
+	// 无法使用零拷贝（HTTPS、HTTP/2）时的复制缓冲区大小（KB），默认256KB
+	CopyBufferKB int `json:"copyBufferKB"`
+	// 定时维护任务，与默认计划按任务名合并；cron为空或"off"时停用该任务
+	Schedule []ScheduleEntry `json:"schedule"`
+	// 缓存目录（data\cache）的容量上限（MB），默认1024MB
+	CacheQuotaMB int `json:"cacheQuotaMB"`
+	// 各种类缓存的容量上限（MB），如 {"thumbnails": 512}，未设置的使用默认值
+	CacheQuotas map[string]int `json:"cacheQuotas"`
+	// 时间线默认包含的文件夹，请求中没有path参数时使用
+	TimelineFolders []string `json:"timelineFolders"`
+	// 地图页面使用的Leaflet（leaflet.js和leaflet.css所在的地址），默认unpkg.com
+	LeafletURL string `json:"leafletURL"`
+	// 地图瓦片地址，如 https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png（默认）
+	MapTileURL string `json:"mapTileURL"`
+	// 3D模型页面使用的three.js（build和examples/jsm所在的地址），默认unpkg.com
+	ThreeJSURL string `json:"threeJSURL"`
+	// 智能筛选，与内置筛选按id合并（同id覆盖，"hidden": true 隐藏）
+	SmartFilters []SmartFilter `json:"smartFilters"`
+	// 下载时要求校验和（checksum参数或Want-Digest头）时，不超过此大小（MB）的文件在请求时计算，默认1024MB
+	ChecksumSyncMaxMB int `json:"checksumSyncMaxMB"`
+	// 首页仪表板的分区，为空时使用内置分区（最近的下载、最近修改的文档、本周最大的文件）
+	Dashboard []DashboardSection `json:"dashboard"`
+	// 仪表板分区默认查找的文件夹（多个之间为“或”），为空时查找整个索引
+	DashboardRoots []string `json:"dashboardRoots"`
+	// 屏幕截图和录屏保存的文件夹（相对路径位于数据目录下），设置后启用 /api/capture，默认关闭
+	CaptureDir string `json:"captureDir"`
+	// 其他主机（名称、地址和用于网络唤醒的MAC地址），见 /api/peers 和 /api/wol
+	Peers []PeerConfig `json:"peers"`
+	// 外部命令预览提供者（扩展名、命令和输出类型），见 /preview/
+	PreviewCommands []PreviewCommand `json:"previewCommands"`
+	// 按文件类型在主机上执行的外部命令（名称、命令模板和适用的扩展名），见 /api/actions
+	Actions []FileAction `json:"actions"`
+	// 定时刷新的搜索，在空闲时段预先执行，白天使用时直接命中缓存
+	WarmQueries []string `json:"warmQueries"`
+	// 日志文件，设置后日志同时写入该文件（相对路径位于数据目录下），由定时任务轮转
+	LogFile string `json:"logFile"`
+	// 保留的历史日志文件数，默认7个
+	LogKeep int `json:"logKeep"`
+	// ffmpeg和ffprobe可执行文件，为空时从PATH查找
+	FFmpegPath  string `json:"ffmpegPath"`
+	FFprobePath string `json:"ffprobePath"`
+	// 自定义转码配置（按名称），与内置的default、1080p、720p、480p同名时覆盖
+	TranscodeProfiles map[string]TranscodeProfile `json:"transcodeProfiles"`
+	// 未指定profile时使用的转码配置，默认"default"
+	DefaultProfile string `json:"defaultProfile"`
+	// Everything实例名（如"1.5a"），为空时连接默认实例
+	EverythingInstance string `json:"everythingInstance"`
+	// 便携版Everything所在文件夹，优先从这里加载DLL、es.exe和Everything.exe
+	EverythingDir string `json:"everythingDir"`
+	// 远程Everything HTTP服务器，设置url后搜索和文件访问都通过该服务器
+	RemoteEverything RemoteEverythingConfig `json:"remoteEverything"`
+	// 发送邮件通知的SMTP服务器
+	SMTP SMTPConfig `json:"smtp"`
+	// 服务器告警（ffmpeg连续失败等）的通知渠道，为空时配置了SMTP则发邮件
+	AlertChannels []NotifyChannel `json:"alertChannels"`
+	// ntfy和Gotify推送
+	Ntfy   NtfyConfig   `json:"ntfy"`
+	Gotify GotifyConfig `json:"gotify"`
+}
+
+// 定时任务配置，如 {"task":"purge-caches","cron":"30 3 * * *"}
+type ScheduleEntry struct {
+	Task string `json:"task"`
+	Cron string `json:"cron"`
+}
+
+const configFileName = "config.json"
+
+var serverConfig = ServerConfig{}
+
+// 加载服务器配置
+func loadServerConfig() {
+	if err := loadJSONFile(configFileName, &serverConfig); err != nil {
+		log.Printf("加载配置失败，使用默认配置: %v", err)
+	}
+	log.Printf("文件操作: %t", serverConfig.EnableFileOperations)
+	if serverConfig.RemoteEverything.URL != "" {
+		log.Printf("使用远程Everything: %s", serverConfig.RemoteEverything.URL)
+	}
+	if serverConfig.EverythingInstance != "" {
+		log.Printf("Everything实例: %s", serverConfig.EverythingInstance)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+)
+
+// CSRF防护
+//
+// 采用双重提交Cookie：服务器在ew_csrf Cookie（SameSite=Strict，页面脚本可以读取）中下发随机令牌，
+// 页面的fetch包装（csrfFetchScript）在POST、PUT、PATCH、DELETE请求的X-CSRF-Token请求头中带上同一个值。
+// 其他网站的页面读不到本站的Cookie，无法伪造请求头，因此不能借用户的浏览器删除文件。
+// 浏览器发出的请求总是带有Origin或Sec-Fetch-Site请求头；两者都没有的请求（curl、脚本等）不是CSRF，不检查令牌。
+
+const (
+	csrfCookieName = "ew_csrf"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+// 页面脚本：同源的修改请求自动带上CSRF令牌，放在<head>中，先于页面的其他脚本执行
+func csrfFetchScript(nonce string) string {
+	return `<script nonce="` + nonce + `">
+    (function() {
+        const originalFetch = window.fetch;
+        window.fetch = function(input, init) {
+            init = init || {};
+            const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+            const target = new URL(input instanceof Request ? input.url : input, location.href);
+            const match = document.cookie.match(/(?:^|;\s*)ew_csrf=([^;]+)/);
+            if (match && target.origin === location.origin && !['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+                const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+                headers.set('X-CSRF-Token', match[1]);
+                init = Object.assign({}, init, { headers: headers });
+            }
+            return originalFetch.call(this, input, init);
+        };
+    })();
+    </script>`
+}
+
+// 当前请求的CSRF令牌，没有时生成新的并写入Cookie
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && len(cookie.Value) == 32 {
+		return cookie.Value
+	}
+
+	token := newRandomID(16)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   sessionCookieMaxAge,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// 修改数据的请求方法
+func isStateChangingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// 请求是否由浏览器发出
+func isBrowserRequest(r *http.Request) bool {
+	return r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Site") != ""
+}
+
+// 检查修改请求的CSRF令牌，并为没有令牌的浏览器下发令牌
+func withCSRFProtection(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := csrfToken(w, r)
+		if isStateChangingMethod(r.Method) && isBrowserRequest(r) {
+			header := r.Header.Get(csrfHeaderName)
+			if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+				log.Printf("拒绝CSRF令牌无效的请求 %s %s，Origin: %s，来源IP: %s", r.Method, r.URL.Path, r.Header.Get("Origin"), r.RemoteAddr)
+				http.Error(w, "CSRF令牌无效，请刷新页面后重试", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
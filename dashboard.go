@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 首页仪表板
+//
+// GET /api/dashboard 同时执行几个小查询（最近的下载、最近修改的文档、本周最大的文件），
+// 一次返回所有分区的前几条结果，首页在没有搜索时显示这些分区，而不是空白的搜索框。
+// 分区可以在 data\config.json 的dashboard中配置（为空时使用内置分区），每个分区可以：
+//   query        Everything查询
+//   filter       或者使用一个智能筛选（见smartfilters.go），params覆盖筛选的参数
+//   roots        只在这些文件夹中查找，多个文件夹之间为“或”，为空时使用dashboardRoots
+//   sort/order   date（默认）、size、name，asc或desc（默认）
+//   limit        条数，默认10条
+// 各分区并行查询，单个分区失败或超时只在该分区中显示错误，不影响其他分区。
+
+const (
+	dashboardDefaultLimit = 10
+	dashboardMaxLimit     = 100
+	dashboardMaxSections  = 12
+	dashboardQueryTimeout = 15 * time.Second
+)
+
+type DashboardSection struct {
+	ID     string         `json:"id"`
+	Title  string         `json:"title"`
+	Icon   string         `json:"icon,omitempty"`
+	Query  string         `json:"query,omitempty"`
+	Filter string         `json:"filter,omitempty"` // 智能筛选id，设置时忽略query
+	Params map[string]int `json:"params,omitempty"` // 覆盖智能筛选的参数
+	Roots  []string       `json:"roots,omitempty"`
+	Sort   string         `json:"sort,omitempty"`
+	Order  string         `json:"order,omitempty"`
+	Limit  int            `json:"limit,omitempty"`
+}
+
+var defaultDashboardSections = []DashboardSection{
+	{ID: "downloads", Title: "最近的下载", Icon: "⬇️", Query: `file: \Downloads\ dm:last7days`, Sort: "date"},
+	{ID: "documents", Title: "最近修改的文档", Icon: "📄", Filter: "documents", Params: map[string]int{"days": 7}, Sort: "date"},
+	{ID: "largest", Title: "本周最大的文件", Icon: "📦", Query: `file: dm:thisweek`, Sort: "size"},
+}
+
+type DashboardItem struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Modified string `json:"modified,omitempty"`
+	IsDir    bool   `json:"isDir"`
+	Type     string `json:"type"`
+}
+
+type DashboardResult struct {
+	ID        string          `json:"id"`
+	Title     string          `json:"title"`
+	Icon      string          `json:"icon,omitempty"`
+	Query     string          `json:"query"`
+	Items     []DashboardItem `json:"items"`
+	Total     int             `json:"total"`
+	Error     string          `json:"error,omitempty"`
+	ElapsedMs int64           `json:"elapsedMs"`
+}
+
+type DashboardResponse struct {
+	Sections  []DashboardResult `json:"sections"`
+	ElapsedMs int64             `json:"elapsedMs"`
+}
+
+// 配置的分区，没有配置时使用内置分区
+func dashboardSections() []DashboardSection {
+	sections := serverConfig.Dashboard
+	if len(sections) == 0 {
+		sections = defaultDashboardSections
+	}
+	if len(sections) > dashboardMaxSections {
+		sections = sections[:dashboardMaxSections]
+	}
+	return sections
+}
+
+// 分区对应的Everything查询：智能筛选或query，再限定到roots中的任一文件夹
+func dashboardQuery(s DashboardSection) (string, error) {
+	query := s.Query
+	if s.Filter != "" {
+		found := false
+		for _, f := range smartFilters() {
+			if f.ID == s.Filter {
+				query = expandSmartFilter(f, s.Params)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("智能筛选不存在: %s", s.Filter)
+		}
+	}
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("分区没有设置查询")
+	}
+
+	roots := s.Roots
+	if len(roots) == 0 {
+		roots = serverConfig.DashboardRoots
+	}
+	if len(roots) == 0 {
+		return query, nil
+	}
+	terms := make([]string, len(roots))
+	for i, root := range roots {
+		terms[i] = folderQuery(resolveClientPath(root))
+	}
+	return "<" + strings.Join(terms, "|") + "> " + query, nil
+}
+
+// 按分区的排序取前limit条
+func sortDashboardEntries(entries []everythingEntry, s DashboardSection) {
+	desc := s.Order != "asc"
+	less := func(a, b everythingEntry) bool {
+		switch s.Sort {
+		case "size":
+			return a.Size < b.Size
+		case "name":
+			return strings.ToLower(filepath.Base(a.Path)) < strings.ToLower(filepath.Base(b.Path))
+		default:
+			return a.Modified.Before(b.Modified)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if desc {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+// 执行一个分区的查询
+func runDashboardSection(ctx context.Context, s DashboardSection) DashboardResult {
+	start := time.Now()
+	result := DashboardResult{ID: s.ID, Title: s.Title, Icon: s.Icon, Items: []DashboardItem{}}
+	defer func() { result.ElapsedMs = time.Since(start).Milliseconds() }()
+
+	query, err := dashboardQuery(s)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Query = query
+
+	ctx, cancel := context.WithTimeout(ctx, dashboardQueryTimeout)
+	defer cancel()
+	entries, err := collectEntries(ctx, query)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if !isExcludedPath(e.Path) {
+			kept = append(kept, e)
+		}
+	}
+	entries = kept
+	result.Total = len(entries)
+
+	limit := s.Limit
+	if limit <= 0 {
+		limit = dashboardDefaultLimit
+	}
+	limit = min(limit, dashboardMaxLimit, len(entries))
+	sortDashboardEntries(entries, s)
+
+	for _, e := range entries[:limit] {
+		item := DashboardItem{
+			Name:  filepath.Base(e.Path),
+			Path:  clientPath(e.Path),
+			Size:  e.Size,
+			IsDir: e.IsDir,
+			Type:  getResultType(e.Path, e.IsDir),
+		}
+		if !e.Modified.IsZero() {
+			item.Modified = e.Modified.Format("2006-01-02 15:04:05")
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result
+}
+
+// 仪表板API处理器
+func apiDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+	sections := dashboardSections()
+	resp := DashboardResponse{Sections: make([]DashboardResult, len(sections))}
+
+	var wg sync.WaitGroup
+	for i, s := range sections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp.Sections[i] = runDashboardSection(r.Context(), s)
+		}()
+	}
+	wg.Wait()
+
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消仪表板查询")
+		return
+	}
+	resp.ElapsedMs = time.Since(start).Milliseconds()
+	log.Printf("仪表板: %d个分区，用时%dms，来源IP: %s", len(sections), resp.ElapsedMs, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardQuery(t *testing.T) {
+	root := t.TempDir()
+	serverConfig.DashboardRoots = []string{root}
+	defer func() { serverConfig.DashboardRoots = nil }()
+
+	query, err := dashboardQuery(DashboardSection{Filter: "documents", Params: map[string]int{"days": 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(query, "<"+folderQuery(root)+"> ") || !strings.HasSuffix(query, "dm:last7days") {
+		t.Errorf("documents section query = %q", query)
+	}
+
+	// 分区自己的roots优先于dashboardRoots，多个文件夹之间为“或”
+	other := t.TempDir()
+	query, _ = dashboardQuery(DashboardSection{Query: "ext:pdf", Roots: []string{root, other}})
+	if want := "<" + folderQuery(root) + "|" + folderQuery(other) + "> ext:pdf"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+
+	for _, s := range []DashboardSection{{Filter: "missing"}, {Query: "  "}} {
+		if _, err := dashboardQuery(s); err == nil {
+			t.Errorf("dashboardQuery(%+v): expected error", s)
+		}
+	}
+}
+
+func TestSortDashboardEntries(t *testing.T) {
+	now := time.Now()
+	entries := func() []everythingEntry {
+		return []everythingEntry{
+			{Path: filepath.Join("d", "b.txt"), Size: 30, Modified: now.Add(-2 * time.Hour)},
+			{Path: filepath.Join("d", "A.txt"), Size: 10, Modified: now},
+			{Path: filepath.Join("d", "c.txt"), Size: 20, Modified: now.Add(-time.Hour)},
+		}
+	}
+	names := func(list []everythingEntry) string {
+		var s []string
+		for _, e := range list {
+			s = append(s, filepath.Base(e.Path))
+		}
+		return strings.Join(s, ",")
+	}
+
+	tests := []struct {
+		section DashboardSection
+		want    string
+	}{
+		{DashboardSection{}, "A.txt,c.txt,b.txt"},
+		{DashboardSection{Sort: "size"}, "b.txt,c.txt,A.txt"},
+		{DashboardSection{Sort: "name", Order: "asc"}, "A.txt,b.txt,c.txt"},
+	}
+	for _, tt := range tests {
+		list := entries()
+		sortDashboardEntries(list, tt.section)
+		if got := names(list); got != tt.want {
+			t.Errorf("sort %q %q = %s, want %s", tt.section.Sort, tt.section.Order, got, tt.want)
+		}
+	}
+}
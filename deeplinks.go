@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"path/filepath"
+)
+
+// 可收藏、可分享的搜索和浏览地址
+//
+// /search?q=关键词&page=2 和 /browse?path=D:\Videos 直接返回首页，并把服务器端
+// 已获取的结果嵌入页面，页面加载后无需再请求API即可显示；在页面中搜索和浏览时，
+// 地址栏会通过pushState同步更新为这两种地址。
+
+// 嵌入页面的初始数据
+type PageState struct {
+	Mode   string          `json:"mode"` // search, browse
+	Query  string          `json:"query,omitempty"`
+	Path   string          `json:"path,omitempty"`
+	Page   int             `json:"page"`
+	Search *SearchResponse `json:"search,omitempty"`
+	Browse *BrowseResponse `json:"browse,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// 搜索结果页面 /search?q=...&page=...&pageSize=...&sort=...
+func searchPageHandler(w http.ResponseWriter, r *http.Request, query string) {
+	page, pageSize := parsePageParams(r)
+
+	log.Printf("搜索页面请求: query=%s, page=%d, pageSize=%d, IP=%s", query, page, pageSize, r.RemoteAddr)
+
+	state := &PageState{Mode: "search", Query: query, Page: page}
+	response, err := buildSearchResponse(withQueryOptions(r.Context(), parseEverythingQueryOptions(r)), query, parseSearchSort(r), parseGroupBy(r), page, pageSize)
+	if r.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Printf("搜索失败: %v", err)
+		state.Error = "搜索失败: " + err.Error()
+	} else {
+		state.Search = response
+	}
+
+	writeIndexPage(w, r, query+" - Everything Web Server", state)
+}
+
+// 文件夹浏览页面 /browse?path=...&page=...（排序等参数与浏览API相同）
+func browsePageHandler(w http.ResponseWriter, r *http.Request) {
+	folderPath := resolveClientPath(r.URL.Query().Get("path"))
+	if folderPath == "" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	log.Printf("浏览页面请求: path=%s, query=%s, IP=%s", folderPath, r.URL.RawQuery, r.RemoteAddr)
+
+	params := parseBrowseParams(r)
+	state := &PageState{Mode: "browse", Path: clientPath(folderPath), Page: params.Page}
+	response, status, err := buildBrowseResponse(r.Context(), folderPath, params)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		state.Error = err.Error()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+	} else {
+		state.Browse = response
+		recordBrowseVisit(w, r, folderPath)
+	}
+
+	title := filepath.Base(folderPath)
+	if title == "." || title == `\` {
+		title = folderPath
+	}
+	writeIndexPage(w, r, title+" - Everything Web Server", state)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 运行时检测外部工具
+//
+// ffmpeg和Everything可能在本服务之后才启动或安装。除了启动时检测，定时任务
+// recheck-tools会定期重新检测，也可以通过 POST /api/redetect 立即检测；
+// 播放器根据ffmpegAvailable决定是否转码，检测结果变化后立即生效。
+
+type FFmpegStatus struct {
+	Available bool   `json:"available"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type EverythingStatus struct {
+	SDKLoaded  bool   `json:"sdkLoaded"`         // Everything64.dll已加载
+	DLLPath    string `json:"dllPath,omitempty"` // 加载的DLL
+	Running    bool   `json:"running"`           // Everything进程正在运行（SDK能通过IPC获取版本）
+	Version    string `json:"version,omitempty"`
+	ESExe      bool   `json:"esExe"`              // 回退用的es.exe存在
+	Properties bool   `json:"properties"`         // Everything 1.5可提供尺寸、时长等属性
+	Instance   string `json:"instance,omitempty"` // 配置的实例名
+	Remote     string `json:"remote,omitempty"`   // 远程Everything HTTP服务器
+	Error      string `json:"error,omitempty"`
+}
+
+type ToolStatus struct {
+	FFmpeg     FFmpegStatus     `json:"ffmpeg"`
+	Everything EverythingStatus `json:"everything"`
+	CheckedAt  string           `json:"checkedAt"`
+}
+
+var (
+	toolStatus    ToolStatus
+	toolMutex     sync.Mutex // 同一时间只进行一次检测
+	toolsDetected atomic.Bool
+	serverStarted = time.Now()
+)
+
+// 检测ffmpeg和Everything，返回最新状态
+func detectTools() ToolStatus {
+	toolMutex.Lock()
+	defer toolMutex.Unlock()
+
+	var status ToolStatus
+
+	version, err := checkFFmpegAvailability()
+	status.FFmpeg = FFmpegStatus{Available: err == nil, Version: version}
+	if err != nil {
+		status.FFmpeg.Error = err.Error()
+	}
+
+	status.Everything = detectEverything()
+	status.CheckedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	previous := toolStatus
+	toolStatus = status
+	if toolsDetected.Swap(true) && previous.Everything.Running != status.Everything.Running {
+		if status.Everything.Running {
+			log.Printf("Everything已运行: %s", status.Everything.Version)
+		} else {
+			log.Printf("Everything已停止运行，搜索将回退到es.exe")
+		}
+	}
+	return status
+}
+
+func detectEverything() EverythingStatus {
+	var status EverythingStatus
+
+	status.ESExe = esExePath() != ""
+
+	// 远程Everything能返回结果即视为运行中（查询不存在的文件名，只检查连接）
+	if status.Remote = serverConfig.RemoteEverything.URL; status.Remote != "" {
+		if _, err := fetchRemotePage(context.Background(), `wfn:"|"`, 0); err != nil {
+			status.Error = err.Error()
+			return status
+		}
+		status.Running = true
+		return status
+	}
+
+	// 命名实例只能通过Everything3 SDK连接
+	if status.Instance = everythingInstanceName(); status.Instance != "" {
+		if status.Version = everything3Version(); status.Version == "" {
+			status.Error = "无法连接Everything实例: " + status.Instance
+			return status
+		}
+		status.Running = true
+		status.Properties = true
+		return status
+	}
+
+	if err := initEverythingSDK(); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.SDKLoaded = true
+	status.DLLPath = everythingDLLPath
+
+	// Everything未运行时SDK无法通过IPC获取版本
+	version, err := everythingClient.Version()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Running = true
+	status.Version = version.String()
+	status.Properties = everything3Available()
+	return status
+}
+
+// 最近一次检测的结果
+func getToolStatus() ToolStatus {
+	toolMutex.Lock()
+	defer toolMutex.Unlock()
+	return toolStatus
+}
+
+// 服务器状态API处理器
+func apiStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cacheMutex.RLock()
+	cacheCount := len(searchCache)
+	cacheMutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tools":          getToolStatus(),
+		"started":        serverStarted.Format("2006-01-02 15:04:05"),
+		"uptime":         time.Since(serverStarted).Round(time.Second).String(),
+		"cacheCount":     cacheCount,
+		"fileOperations": serverConfig.EnableFileOperations && canAdmin(r),
+		"admin":          canAdmin(r),
+		"requests":       requestTimingStatus(),
+		"panics":         panicStatus(),
+		"listen":         serverListenStatus(),
+		"thumbnails":     thumbnailPregenStatus(),
+	})
+}
+
+// 重新检测API处理器，POST 立即检测并返回结果
+func apiRedetectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	log.Printf("重新检测ffmpeg和Everything，来源IP: %s", r.RemoteAddr)
+	status := detectTools()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,984 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// 光盘映像
+//
+// 不挂载就能浏览ISO/IMG光盘映像中的文件并单独下载其中一个，支持ISO9660（Rock Ridge和Joliet扩展）和UDF
+// （Windows安装盘等超过4GB的文件只在UDF中）：
+//
+//   GET /api/disc?path=映像&dir=/sources     列出文件夹内容
+//   GET /disc/映像?file=/sources/boot.wim    下载映像中的文件，支持Range
+//
+// 同时包含两种文件系统时使用UDF。只读取需要的扇区，不会把映像读入内存。
+
+const (
+	discSectorSize = 2048
+	maxDiscDirSize = 64 << 20 // 文件夹内容的最大大小，防止损坏的映像造成大量内存分配
+	maxDiscADs     = 1 << 16  // 一个文件的最多分配描述符
+)
+
+var (
+	errNotDiscImage = errors.New("不是ISO9660或UDF光盘映像")
+	errDiscCorrupt  = errors.New("光盘映像已损坏")
+	errDiscNotFound = errors.New("映像中没有这个文件")
+)
+
+// 文件内容在映像中的一段，zero表示未记录的区域（读取为0），data为内嵌在文件项中的内容
+type discExtent struct {
+	offset int64
+	length int64
+	zero   bool
+	data   []byte
+}
+
+type discEntry struct {
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	extents []discExtent
+}
+
+type discFS interface {
+	root() (*discEntry, error)
+	readDir(dir *discEntry) ([]*discEntry, error)
+}
+
+type discImage struct {
+	r      io.ReaderAt
+	size   int64
+	Format string // UDF、Rock Ridge、Joliet或ISO9660
+	Label  string
+	fs     discFS
+}
+
+func readDiscAt(r io.ReaderAt, size, offset int64, n int) ([]byte, error) {
+	if offset < 0 || n < 0 || offset+int64(n) > size {
+		return nil, errDiscCorrupt
+	}
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func openDiscImage(r io.ReaderAt, size int64) (*discImage, error) {
+	img := &discImage{r: r, size: size}
+	// 卷识别序列中有NSR02/NSR03时是UDF
+	for sector := int64(16); sector < 64; sector++ {
+		buf, err := readDiscAt(r, size, sector*discSectorSize, 7)
+		if err != nil {
+			break
+		}
+		id := string(buf[1:6])
+		if id == "NSR02" || id == "NSR03" {
+			if u, err := openUDF(r, size); err == nil {
+				img.Format, img.Label, img.fs = "UDF", u.label, u
+				return img, nil
+			}
+			break
+		}
+		if id != "CD001" && id != "BEA01" && id != "TEA01" && id != "BOOT2" && id != "CDW02" {
+			break
+		}
+	}
+	iso, err := openISO9660(r, size)
+	if err != nil {
+		return nil, err
+	}
+	img.Format, img.Label, img.fs = "ISO9660", iso.label, iso
+	switch {
+	case iso.rockRidge:
+		img.Format = "Rock Ridge"
+	case iso.joliet:
+		img.Format = "Joliet"
+	}
+	return img, nil
+}
+
+// 按/分隔的路径查找文件或文件夹，空路径或/为根目录
+func (img *discImage) lookup(p string) (*discEntry, error) {
+	entry, err := img.fs.root()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		if !entry.Dir {
+			return nil, errDiscNotFound
+		}
+		children, err := img.fs.readDir(entry)
+		if err != nil {
+			return nil, err
+		}
+		var found *discEntry
+		for _, child := range children {
+			if child.Name == name {
+				found = child
+				break
+			}
+			// ISO9660的文件名不区分大小写
+			if found == nil && strings.EqualFold(child.Name, name) {
+				found = child
+			}
+		}
+		if found == nil {
+			return nil, errDiscNotFound
+		}
+		entry = found
+	}
+	return entry, nil
+}
+
+// 按分段读取映像中的文件，实现io.ReadSeeker供http.ServeContent使用
+type discFileReader struct {
+	r       io.ReaderAt
+	extents []discExtent
+	size    int64
+	pos     int64
+}
+
+func (img *discImage) open(e *discEntry) *discFileReader {
+	return &discFileReader{r: img.r, extents: e.extents, size: e.Size}
+}
+
+func (f *discFileReader) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > f.size-f.pos {
+		p = p[:f.size-f.pos]
+	}
+	start := int64(0)
+	for _, ext := range f.extents {
+		if f.pos >= start+ext.length {
+			start += ext.length
+			continue
+		}
+		within := f.pos - start
+		n := int(min(int64(len(p)), ext.length-within))
+		var err error
+		switch {
+		case ext.data != nil:
+			copy(p[:n], ext.data[within:])
+		case ext.zero:
+			clear(p[:n])
+		default:
+			n, err = f.r.ReadAt(p[:n], ext.offset+within)
+			if err == io.EOF && n > 0 {
+				err = nil
+			}
+		}
+		f.pos += int64(n)
+		return n, err
+	}
+	// 分段比文件短时剩余部分为0
+	n := int(min(int64(len(p)), f.size-f.pos))
+	clear(p[:n])
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *discFileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("无效的位置")
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+// ---------- ISO9660 ----------
+
+type iso9660FS struct {
+	r         io.ReaderAt
+	size      int64
+	joliet    bool
+	rockRidge bool
+	label     string
+	rootRec   []byte
+}
+
+func openISO9660(r io.ReaderAt, size int64) (*iso9660FS, error) {
+	var primary, joliet []byte
+	for sector := int64(16); sector < 64; sector++ {
+		buf, err := readDiscAt(r, size, sector*discSectorSize, discSectorSize)
+		if err != nil || string(buf[1:6]) != "CD001" {
+			break
+		}
+		switch buf[0] {
+		case 1:
+			primary = buf
+		case 2:
+			// Joliet的转义序列%/@、%/C、%/E对应UCS-2的三个级别
+			if esc := string(buf[88:91]); esc == "%/@" || esc == "%/C" || esc == "%/E" {
+				joliet = buf
+			}
+		}
+		if buf[0] == 255 {
+			break
+		}
+	}
+	fs := &iso9660FS{r: r, size: size}
+	switch {
+	case primary != nil && fs.hasRockRidge(primary[156:190]):
+		// Rock Ridge保留原始的大小写和长文件名，优先于Joliet
+		fs.rockRidge, fs.rootRec = true, primary[156:190]
+		fs.label = strings.TrimSpace(string(primary[40:72]))
+	case joliet != nil:
+		fs.joliet, fs.rootRec = true, joliet[156:190]
+		fs.label = strings.TrimSpace(decodeUCS2(joliet[40:72]))
+	case primary != nil:
+		fs.rootRec = primary[156:190]
+		fs.label = strings.TrimSpace(string(primary[40:72]))
+	default:
+		return nil, errNotDiscImage
+	}
+	return fs, nil
+}
+
+// 根目录的第一项（.）的系统使用区以SUSP的SP项开头时有Rock Ridge
+func (fs *iso9660FS) hasRockRidge(rootRec []byte) bool {
+	buf, err := readDiscAt(fs.r, fs.size, int64(binary.LittleEndian.Uint32(rootRec[2:]))*discSectorSize, 256)
+	if err != nil || buf[0] < 34 || int(buf[0]) > len(buf) {
+		return false
+	}
+	su := systemUseArea(buf[:buf[0]])
+	return len(su) >= 7 && su[0] == 'S' && su[1] == 'P' && su[4] == 0xBE && su[5] == 0xEF
+}
+
+// 目录记录中文件名之后的系统使用区（文件名长度为偶数时有一个填充字节）
+func systemUseArea(rec []byte) []byte {
+	start := 33 + int(rec[32])
+	if rec[32]%2 == 0 {
+		start++
+	}
+	if start >= len(rec) {
+		return nil
+	}
+	return rec[start:]
+}
+
+// Rock Ridge的NM项中的原始文件名，可以分成多个NM项
+func rockRidgeName(rec []byte) (string, bool) {
+	su := systemUseArea(rec)
+	var name []byte
+	found := false
+	for len(su) >= 4 {
+		n := int(su[2])
+		if n < 4 || n > len(su) {
+			break
+		}
+		if su[0] == 'N' && su[1] == 'M' && n >= 5 && su[4]&6 == 0 {
+			name = append(name, su[5:n]...)
+			found = true
+		}
+		su = su[n:]
+	}
+	return string(name), found
+}
+
+func decodeUCS2(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, binary.BigEndian.Uint16(b[i:]))
+	}
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
+
+func (fs *iso9660FS) record(rec []byte) *discEntry {
+	e := &discEntry{Dir: rec[25]&2 != 0}
+	e.Size = int64(binary.LittleEndian.Uint32(rec[10:]))
+	e.extents = []discExtent{{offset: int64(binary.LittleEndian.Uint32(rec[2:])) * discSectorSize, length: e.Size}}
+	if rec[18] != 0 {
+		tz := time.FixedZone("", int(int8(rec[24]))*15*60)
+		e.ModTime = time.Date(1900+int(rec[18]), time.Month(rec[19]), int(rec[20]), int(rec[21]), int(rec[22]), int(rec[23]), 0, tz)
+	}
+	name := rec[33 : 33+int(rec[32])]
+	if fs.rockRidge {
+		if original, ok := rockRidgeName(rec); ok {
+			e.Name = original
+			return e
+		}
+	}
+	if fs.joliet {
+		e.Name = decodeUCS2(name)
+	} else {
+		e.Name = string(name)
+	}
+	// 去掉版本号;1和没有扩展名时的结尾.
+	if i := strings.LastIndexByte(e.Name, ';'); i > 0 {
+		e.Name = e.Name[:i]
+	}
+	if !e.Dir {
+		e.Name = strings.TrimSuffix(e.Name, ".")
+	}
+	return e
+}
+
+func (fs *iso9660FS) root() (*discEntry, error) {
+	e := fs.record(fs.rootRec)
+	e.Name = ""
+	return e, nil
+}
+
+func (fs *iso9660FS) readDir(dir *discEntry) ([]*discEntry, error) {
+	if len(dir.extents) == 0 || dir.Size > maxDiscDirSize {
+		return nil, errDiscCorrupt
+	}
+	data, err := readDiscAt(fs.r, fs.size, dir.extents[0].offset, int(dir.Size))
+	if err != nil {
+		return nil, err
+	}
+	var entries []*discEntry
+	var multi *discEntry // 多段文件（超过4GB）的前面几段
+	for pos := 0; pos < len(data); {
+		n := int(data[pos])
+		if n == 0 {
+			// 记录不跨扇区，剩余部分为0时跳到下一个扇区
+			pos = (pos/discSectorSize + 1) * discSectorSize
+			continue
+		}
+		if n < 34 || pos+n > len(data) || 33+int(data[pos+32]) > n {
+			return nil, errDiscCorrupt
+		}
+		rec := data[pos : pos+n]
+		pos += n
+		if rec[32] == 1 && (rec[33] == 0 || rec[33] == 1) {
+			continue // .和..
+		}
+		e := fs.record(rec)
+		if multi != nil && multi.Name == e.Name {
+			multi.extents = append(multi.extents, e.extents...)
+			multi.Size += e.Size
+			e = multi
+		} else {
+			entries = append(entries, e)
+		}
+		multi = nil
+		if rec[25]&0x80 != 0 {
+			multi = e
+		}
+	}
+	return entries, nil
+}
+
+// ---------- UDF ----------
+
+type udfFS struct {
+	r         io.ReaderAt
+	size      int64
+	blockSize int64
+	label     string
+	// 每个分区引用号对应的逻辑块到映像偏移的映射
+	partitions []func(block uint32) (int64, error)
+	rootICB    []byte
+}
+
+// 检查描述符标签，返回标签标识
+func udfTag(buf []byte) (uint16, bool) {
+	if len(buf) < 16 {
+		return 0, false
+	}
+	var sum byte
+	for i := 0; i < 16; i++ {
+		if i != 4 {
+			sum += buf[i]
+		}
+	}
+	return binary.LittleEndian.Uint16(buf), sum == buf[4]
+}
+
+// OSTA CS0字符串：第一个字节为8时每个字符一字节，16时为UTF-16BE
+func udfString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case 8:
+		runes := make([]rune, len(b)-1)
+		for i, c := range b[1:] {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	case 16:
+		return decodeUCS2(b[1:])
+	}
+	return ""
+}
+
+// 固定长度的dstring，最后一个字节是实际长度
+func udfDString(b []byte) string {
+	n := int(b[len(b)-1])
+	if n == 0 || n >= len(b) {
+		return ""
+	}
+	return udfString(b[:n])
+}
+
+func udfTimestamp(b []byte) time.Time {
+	year := int(int16(binary.LittleEndian.Uint16(b[2:])))
+	if year == 0 {
+		return time.Time{}
+	}
+	loc := time.UTC
+	typeTZ := binary.LittleEndian.Uint16(b)
+	if typeTZ>>12 == 1 {
+		// 低12位是有符号的分钟数，-2047表示未指定
+		offset := int(typeTZ&0x0FFF) << 20 >> 20
+		if offset != -2047 {
+			loc = time.FixedZone("", offset*60)
+		}
+	}
+	return time.Date(year, time.Month(b[4]), int(b[5]), int(b[6]), int(b[7]), int(b[8]), int(b[9])*10_000_000, loc)
+}
+
+func openUDF(r io.ReaderAt, size int64) (*udfFS, error) {
+	u := &udfFS{r: r, size: size}
+	anchor, err := readDiscAt(r, size, 256*discSectorSize, discSectorSize)
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := udfTag(anchor); !ok || id != 2 {
+		return nil, errNotDiscImage
+	}
+	vdsLength := int64(binary.LittleEndian.Uint32(anchor[16:]))
+	vdsStart := int64(binary.LittleEndian.Uint32(anchor[20:]))
+
+	partitionStart := map[uint16]int64{}
+	var lvd []byte
+	for i := int64(0); i < vdsLength/discSectorSize && i < 256; i++ {
+		buf, err := readDiscAt(r, size, (vdsStart+i)*discSectorSize, discSectorSize)
+		if err != nil {
+			return nil, err
+		}
+		id, ok := udfTag(buf)
+		if !ok || id == 8 {
+			break
+		}
+		switch id {
+		case 5: // 分区描述符
+			partitionStart[binary.LittleEndian.Uint16(buf[22:])] = int64(binary.LittleEndian.Uint32(buf[188:]))
+		case 6: // 逻辑卷描述符
+			lvd = buf
+		}
+	}
+	if lvd == nil || len(partitionStart) == 0 {
+		return nil, errNotDiscImage
+	}
+	u.blockSize = int64(binary.LittleEndian.Uint32(lvd[212:]))
+	if u.blockSize != discSectorSize {
+		return nil, fmt.Errorf("%w: 不支持%d字节的逻辑块", errNotDiscImage, u.blockSize)
+	}
+	u.label = udfDString(lvd[84:212])
+
+	// 分区映射：类型1直接对应物理分区，类型2中只支持元数据分区（UDF 2.50）
+	maps := lvd[440:]
+	count := int(binary.LittleEndian.Uint32(lvd[268:]))
+	var metadata [][2]int // 元数据分区的引用号和元数据文件位置
+	for i, pos := 0, 0; i < count; i++ {
+		if pos+2 > len(maps) || int(maps[pos+1]) < 2 || pos+int(maps[pos+1]) > len(maps) {
+			return nil, errDiscCorrupt
+		}
+		m := maps[pos : pos+int(maps[pos+1])]
+		pos += len(m)
+		switch {
+		case m[0] == 1 && len(m) >= 6:
+			start, ok := partitionStart[binary.LittleEndian.Uint16(m[4:])]
+			if !ok {
+				return nil, errDiscCorrupt
+			}
+			u.partitions = append(u.partitions, u.physical(start))
+		case m[0] == 2 && len(m) >= 44 && strings.HasPrefix(string(m[5:36]), "*UDF Metadata Partition"):
+			start, ok := partitionStart[binary.LittleEndian.Uint16(m[38:])]
+			if !ok {
+				return nil, errDiscCorrupt
+			}
+			u.partitions = append(u.partitions, u.physical(start))
+			metadata = append(metadata, [2]int{i, int(binary.LittleEndian.Uint32(m[40:]))})
+		case m[0] == 2 && len(m) >= 40 && strings.HasPrefix(string(m[5:36]), "*UDF Sparable Partition"):
+			// 可擦写光盘的备用扇区表只在出现坏扇区时使用，映像文件中按普通分区读取
+			start, ok := partitionStart[binary.LittleEndian.Uint16(m[38:])]
+			if !ok {
+				return nil, errDiscCorrupt
+			}
+			u.partitions = append(u.partitions, u.physical(start))
+		default:
+			return nil, fmt.Errorf("%w: 不支持的分区类型", errNotDiscImage)
+		}
+	}
+	// 元数据分区中的块位于元数据文件中，元数据文件本身在对应的物理分区
+	for _, md := range metadata {
+		ref, location := md[0], md[1]
+		fe, err := u.readBlock(uint16(ref), uint32(location))
+		if err != nil {
+			return nil, err
+		}
+		file, err := u.fileEntry(fe, uint16(ref))
+		if err != nil {
+			return nil, err
+		}
+		extents := file.extents
+		u.partitions[ref] = func(block uint32) (int64, error) {
+			offset := int64(block) * u.blockSize
+			for _, ext := range extents {
+				if offset >= ext.length {
+					offset -= ext.length
+					continue
+				}
+				if ext.data != nil || ext.zero {
+					break
+				}
+				return ext.offset + offset, nil
+			}
+			return 0, errDiscCorrupt
+		}
+	}
+
+	// 文件集描述符中是根目录的ICB
+	fsdLocation := lvd[248:264]
+	fsd, err := u.readBlock(binary.LittleEndian.Uint16(fsdLocation[8:]), binary.LittleEndian.Uint32(fsdLocation[4:]))
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := udfTag(fsd); !ok || id != 256 {
+		return nil, errDiscCorrupt
+	}
+	u.rootICB = fsd[400:416]
+	return u, nil
+}
+
+func (u *udfFS) physical(start int64) func(uint32) (int64, error) {
+	return func(block uint32) (int64, error) {
+		return (start + int64(block)) * discSectorSize, nil
+	}
+}
+
+func (u *udfFS) offset(partition uint16, block uint32) (int64, error) {
+	if int(partition) >= len(u.partitions) {
+		return 0, errDiscCorrupt
+	}
+	return u.partitions[partition](block)
+}
+
+func (u *udfFS) readBlock(partition uint16, block uint32) ([]byte, error) {
+	offset, err := u.offset(partition, block)
+	if err != nil {
+		return nil, err
+	}
+	return readDiscAt(u.r, u.size, offset, int(u.blockSize))
+}
+
+// 读取long_ad指向的文件项
+func (u *udfFS) entryAt(longAD []byte) (*discEntry, error) {
+	partition := binary.LittleEndian.Uint16(longAD[8:])
+	fe, err := u.readBlock(partition, binary.LittleEndian.Uint32(longAD[4:]))
+	if err != nil {
+		return nil, err
+	}
+	return u.fileEntry(fe, partition)
+}
+
+// 解析文件项（FE）或扩展文件项（EFE）：大小、修改时间和内容的分配描述符
+func (u *udfFS) fileEntry(fe []byte, partition uint16) (*discEntry, error) {
+	id, ok := udfTag(fe)
+	if !ok || (id != 261 && id != 266) {
+		return nil, errDiscCorrupt
+	}
+	e := &discEntry{Dir: fe[27] == 4, Size: int64(binary.LittleEndian.Uint64(fe[56:]))}
+	var lenEA, lenAD, adStart int
+	if id == 261 {
+		e.ModTime = udfTimestamp(fe[84:])
+		lenEA, lenAD, adStart = int(binary.LittleEndian.Uint32(fe[168:])), int(binary.LittleEndian.Uint32(fe[172:])), 176
+	} else {
+		e.ModTime = udfTimestamp(fe[92:])
+		lenEA, lenAD, adStart = int(binary.LittleEndian.Uint32(fe[208:])), int(binary.LittleEndian.Uint32(fe[212:])), 216
+	}
+	adStart += lenEA
+	if lenEA < 0 || lenAD < 0 || adStart+lenAD > len(fe) || e.Size < 0 {
+		return nil, errDiscCorrupt
+	}
+	ads := fe[adStart : adStart+lenAD]
+
+	adType := binary.LittleEndian.Uint16(fe[34:]) & 7
+	if adType == 3 {
+		// 内容直接存放在文件项中
+		if int64(len(ads)) < e.Size {
+			return nil, errDiscCorrupt
+		}
+		e.extents = []discExtent{{length: e.Size, data: ads[:e.Size]}}
+		return e, nil
+	}
+	adSize := map[uint16]int{0: 8, 1: 16, 2: 20}[adType]
+	if adSize == 0 {
+		return nil, errDiscCorrupt
+	}
+	for len(ads) >= adSize && len(e.extents) < maxDiscADs {
+		ad := ads[:adSize]
+		ads = ads[adSize:]
+		raw := binary.LittleEndian.Uint32(ad)
+		length, kind := int64(raw&0x3FFFFFFF), raw>>30
+		if length == 0 {
+			break
+		}
+		var block uint32
+		p := partition
+		switch adType {
+		case 0:
+			block = binary.LittleEndian.Uint32(ad[4:])
+		case 1:
+			block, p = binary.LittleEndian.Uint32(ad[4:]), binary.LittleEndian.Uint16(ad[8:])
+		case 2:
+			block, p = binary.LittleEndian.Uint32(ad[12:]), binary.LittleEndian.Uint16(ad[16:])
+		}
+		if kind == 3 {
+			// 分配描述符的下一段，在分配扩展描述符（AED）中
+			aed, err := u.readBlock(p, block)
+			if err != nil {
+				return nil, err
+			}
+			if id, ok := udfTag(aed); !ok || id != 258 {
+				return nil, errDiscCorrupt
+			}
+			n := int(binary.LittleEndian.Uint32(aed[20:]))
+			if 24+n > len(aed) {
+				return nil, errDiscCorrupt
+			}
+			ads = aed[24 : 24+n]
+			continue
+		}
+		if kind != 0 {
+			e.extents = append(e.extents, discExtent{length: length, zero: true})
+			continue
+		}
+		offset, err := u.offset(p, block)
+		if err != nil {
+			return nil, err
+		}
+		e.extents = append(e.extents, discExtent{offset: offset, length: length})
+	}
+	return e, nil
+}
+
+func (u *udfFS) root() (*discEntry, error) {
+	return u.entryAt(u.rootICB)
+}
+
+// 文件夹内容是一组文件标识描述符（FID）
+func (u *udfFS) readDir(dir *discEntry) ([]*discEntry, error) {
+	if dir.Size > maxDiscDirSize {
+		return nil, errDiscCorrupt
+	}
+	data := make([]byte, dir.Size)
+	if _, err := io.ReadFull(&discFileReader{r: u.r, extents: dir.extents, size: dir.Size}, data); err != nil {
+		return nil, err
+	}
+	var entries []*discEntry
+	for pos := 0; pos+38 <= len(data); {
+		fid := data[pos:]
+		if id, ok := udfTag(fid); !ok || id != 257 {
+			return nil, errDiscCorrupt
+		}
+		characteristics := fid[18]
+		nameLen, iuLen := int(fid[19]), int(binary.LittleEndian.Uint16(fid[36:]))
+		n := (38 + iuLen + nameLen + 3) &^ 3
+		if 38+iuLen+nameLen > len(fid) {
+			return nil, errDiscCorrupt
+		}
+		icb := fid[20:36]
+		name := udfString(fid[38+iuLen : 38+iuLen+nameLen])
+		pos += n
+		// 跳过上级目录和已删除的项
+		if characteristics&(4|8) != 0 {
+			continue
+		}
+		e, err := u.entryAt(icb)
+		if err != nil {
+			return nil, err
+		}
+		e.Name = name
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// ---------- HTTP ----------
+
+var discImageExtensions = []string{".iso", ".img", ".udf"}
+
+// 打开请求中的映像文件，出错时写入响应并返回nil
+func openRequestDisc(w http.ResponseWriter, r *http.Request, path string) (*discImage, *os.File) {
+	if remoteEverythingEnabled() {
+		http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+		return nil, nil
+	}
+	file, err := openPath(path)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if os.IsNotExist(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, "打开映像失败: "+err.Error(), status)
+		return nil, nil
+	}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		http.Error(w, "不能打开文件夹", http.StatusBadRequest)
+		return nil, nil
+	}
+	img, err := openDiscImage(file, info.Size())
+	if err != nil {
+		file.Close()
+		discError(w, path, err)
+		return nil, nil
+	}
+	return img, file
+}
+
+func discError(w http.ResponseWriter, path string, err error) {
+	switch {
+	case errors.Is(err, errDiscNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errNotDiscImage), errors.Is(err, errDiscCorrupt), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		http.Error(w, errNotDiscImage.Error()+": "+err.Error(), http.StatusUnprocessableEntity)
+	default:
+		log.Printf("读取光盘映像失败: %s, 错误: %v", path, err)
+		http.Error(w, "读取映像失败: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func apiDiscHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	clientSrc := r.URL.Query().Get("path")
+	if clientSrc == "" {
+		http.Error(w, "path参数不能为空", http.StatusBadRequest)
+		return
+	}
+	path := resolveClientPath(clientSrc)
+	img, file := openRequestDisc(w, r, path)
+	if img == nil {
+		return
+	}
+	defer file.Close()
+
+	dirPath := "/" + strings.Trim(r.URL.Query().Get("dir"), "/")
+	dir, err := img.lookup(dirPath)
+	if err == nil && !dir.Dir {
+		err = errDiscNotFound
+	}
+	var entries []*discEntry
+	if err == nil {
+		entries, err = img.fs.readDir(dir)
+	}
+	if err != nil {
+		discError(w, path, err)
+		return
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Dir != entries[j].Dir {
+			return entries[i].Dir
+		}
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+	if entries == nil {
+		entries = []*discEntry{}
+	}
+
+	log.Printf("浏览光盘映像: %s, 文件夹: %s, %d项, 来源IP: %s", path, dirPath, len(entries), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":    clientPath(path),
+		"format":  img.Format,
+		"label":   img.Label,
+		"dir":     dirPath,
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// 下载映像中的一个文件
+func discFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	path := decodeRequestPath(strings.TrimPrefix(r.URL.Path, "/disc/"))
+	inner := r.URL.Query().Get("file")
+	if strings.Trim(inner, "/") == "" {
+		http.Error(w, "file参数不能为空", http.StatusBadRequest)
+		return
+	}
+	img, file := openRequestDisc(w, r, path)
+	if img == nil {
+		return
+	}
+	defer file.Close()
+
+	entry, err := img.lookup(inner)
+	if err == nil && entry.Dir {
+		err = errDiscNotFound
+	}
+	if err != nil {
+		discError(w, path, err)
+		return
+	}
+
+	contentType := getContentType(strings.ToLower(filepath.Ext(entry.Name)))
+	disposition, servedType := fileDisposition(r, contentType)
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, entry.Name))
+	w.Header().Set("Content-Type", servedType)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	log.Printf("提供光盘映像中的文件: %s, 文件: %s (大小: %d 字节，Range: %s), 来源IP: %s", path, inner, entry.Size, r.Header.Get("Range"), r.RemoteAddr)
+	http.ServeContent(w, r, entry.Name, entry.ModTime, img.open(entry))
+}
+
+type discPreviewProvider struct{ previewExtensions }
+
+func init() {
+	registerPreviewProvider(discPreviewProvider{previewExtensions(discImageExtensions)})
+}
+
+func (discPreviewProvider) Name() string { return "disc" }
+
+// 映像浏览页面，文件夹内容由页面通过/api/disc读取
+func (discPreviewProvider) Render(ctx context.Context, path string) (*Preview, error) {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	fileName := filepath.Base(path)
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + html.EscapeString(fileName) + ` - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 12px; font-size: 14px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; text-decoration: none; }
+        #crumbs a { margin: 0 2px; }
+        .panel { background: white; border-radius: 8px; padding: 8px 0; }
+        table { border-collapse: collapse; width: 100%; font-size: 14px; }
+        th, td { padding: 6px 16px; text-align: left; border-bottom: 1px solid #f0f0f0; }
+        th { color: #888; font-weight: normal; font-size: 13px; }
+        td.size, td.time { white-space: nowrap; color: #666; font-variant-numeric: tabular-nums; }
+        td.size { text-align: right; }
+        td.name { word-break: break-all; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>💿 ` + html.EscapeString(fileName) + `</strong>
+        <span class="meta" id="info">加载中...</span>
+    </div>
+    <div class="toolbar" id="crumbs"></div>
+    <div class="panel">
+        <table><thead><tr><th>名称</th><th>大小</th><th>修改时间</th><th></th></tr></thead><tbody id="entries"></tbody></table>
+    </div>
+    <script nonce="` + nonce + `">
+        const imagePath = ` + jsString(clientPath(path)) + `;
+        const info = document.getElementById('info');
+        const body = document.getElementById('entries');
+
+        function formatSize(bytes) {
+            const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+            let i = 0;
+            while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
+            return (i === 0 ? bytes : bytes.toFixed(2)) + ' ' + units[i];
+        }
+
+        function fileURL(file, download) {
+            return '/disc/' + encodeURIComponent(imagePath) + '?' + new URLSearchParams(download ? { file: file, download: '1' } : { file: file });
+        }
+
+        function renderCrumbs(dir) {
+            const crumbs = document.getElementById('crumbs');
+            crumbs.textContent = '';
+            const parts = dir.split('/').filter(Boolean);
+            const root = document.createElement('a');
+            root.href = '#/';
+            root.textContent = '根目录';
+            crumbs.appendChild(root);
+            parts.forEach((part, i) => {
+                crumbs.appendChild(document.createTextNode('/'));
+                const a = document.createElement('a');
+                a.href = '#/' + parts.slice(0, i + 1).map(encodeURIComponent).join('/');
+                a.textContent = part;
+                crumbs.appendChild(a);
+            });
+        }
+
+        async function load() {
+            const dir = decodeURIComponent(location.hash.slice(1)) || '/';
+            body.textContent = '';
+            renderCrumbs(dir);
+            try {
+                const response = await fetch('/api/disc?' + new URLSearchParams({ path: imagePath, dir: dir }));
+                if (!response.ok) throw new Error(await response.text());
+                const data = await response.json();
+                info.textContent = data.format + (data.label ? '，卷标 ' + data.label : '') + '，' + data.count + '项';
+                data.entries.forEach(e => {
+                    const full = (data.dir === '/' ? '' : data.dir) + '/' + e.name;
+                    const tr = body.insertRow();
+                    const name = tr.insertCell();
+                    name.className = 'name';
+                    const a = document.createElement('a');
+                    a.textContent = (e.dir ? '📁 ' : '📄 ') + e.name;
+                    a.href = e.dir ? '#' + full.split('/').map(encodeURIComponent).join('/') : fileURL(full, false);
+                    if (!e.dir) a.target = '_blank';
+                    name.appendChild(a);
+                    const size = tr.insertCell();
+                    size.className = 'size';
+                    size.textContent = e.dir ? '' : formatSize(e.size);
+                    const time = tr.insertCell();
+                    time.className = 'time';
+                    time.textContent = e.modTime && !e.modTime.startsWith('0001') ? new Date(e.modTime).toLocaleString() : '';
+                    const action = tr.insertCell();
+                    if (!e.dir) {
+                        const download = document.createElement('a');
+                        download.href = fileURL(full, true);
+                        download.textContent = '下载';
+                        action.appendChild(download);
+                    }
+                });
+            } catch (error) {
+                info.textContent = '加载失败: ' + error.message;
+            }
+        }
+
+        window.addEventListener('hashchange', load);
+        load();
+    </script>
+</body>
+</html>`
+	return &Preview{ContentType: "text/html; charset=utf-8", Body: io.NopCloser(strings.NewReader(page))}, nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// 按扇区写入的测试映像
+type testDisc []byte
+
+func (d *testDisc) sector(n int) []byte {
+	if need := (n + 1) * discSectorSize; len(*d) < need {
+		*d = append(*d, make([]byte, need-len(*d))...)
+	}
+	return (*d)[n*discSectorSize : (n+1)*discSectorSize]
+}
+
+// UDF描述符标签，校验和不包括第4个字节
+func udfTestTag(buf []byte, id uint16) {
+	binary.LittleEndian.PutUint16(buf, id)
+	buf[4] = 0
+	var sum byte
+	for i := 0; i < 16; i++ {
+		sum += buf[i]
+	}
+	buf[4] = sum
+}
+
+func udfTestName(name string) []byte {
+	for _, r := range name {
+		if r > 0xFF {
+			b := []byte{16}
+			for _, u := range utf16.Encode([]rune(name)) {
+				b = binary.BigEndian.AppendUint16(b, u)
+			}
+			return b
+		}
+	}
+	return append([]byte{8}, name...)
+}
+
+func udfTestFIDs(entries map[string][2]int) []byte {
+	data := make([]byte, 40) // 上级目录
+	data[18] = 8 | 2
+	udfTestTag(data, 257)
+	for name, e := range entries {
+		id := udfTestName(name)
+		fid := make([]byte, (38+len(id)+3)&^3)
+		fid[18], fid[19] = byte(e[1]), byte(len(id))
+		binary.LittleEndian.PutUint32(fid[20:], discSectorSize)
+		binary.LittleEndian.PutUint32(fid[24:], uint32(e[0]))
+		copy(fid[38:], id)
+		udfTestTag(fid, 257)
+		data = append(data, fid...)
+	}
+	return data
+}
+
+// UDF文件项，adType为0（short_ad）或3（内嵌），ads为short_ad或内嵌的内容
+func udfTestFE(buf []byte, fileType byte, size int, adType uint16, ads []byte) {
+	buf[27] = fileType
+	binary.LittleEndian.PutUint16(buf[34:], adType)
+	binary.LittleEndian.PutUint64(buf[56:], uint64(size))
+	binary.LittleEndian.PutUint16(buf[84:], 1<<12|480) // UTC+8
+	binary.LittleEndian.PutUint16(buf[86:], 2024)
+	buf[88], buf[89] = 5, 20
+	binary.LittleEndian.PutUint32(buf[172:], uint32(len(ads)))
+	copy(buf[176:], ads)
+	udfTestTag(buf, 261)
+}
+
+func shortAD(length, block int, kind uint32) []byte {
+	ad := binary.LittleEndian.AppendUint32(nil, uint32(length)|kind<<30)
+	return binary.LittleEndian.AppendUint32(ad, uint32(block))
+}
+
+// UDF映像：分区从第64扇区开始，big.bin由两段内容和中间未记录的一段组成
+func buildTestUDF() (image []byte, big []byte) {
+	d := testDisc{}
+	for i, id := range []string{"BEA01", "NSR02", "TEA01"} {
+		copy(d.sector(16 + i)[1:], id)
+	}
+	avdp := d.sector(256)
+	binary.LittleEndian.PutUint32(avdp[16:], 3*discSectorSize)
+	binary.LittleEndian.PutUint32(avdp[20:], 32)
+	udfTestTag(avdp, 2)
+	pd := d.sector(32)
+	binary.LittleEndian.PutUint32(pd[188:], 64)
+	udfTestTag(pd, 5)
+	lvd := d.sector(33)
+	label := udfTestName("测试光盘")
+	copy(lvd[84:], label)
+	lvd[211] = byte(len(label))
+	binary.LittleEndian.PutUint32(lvd[212:], discSectorSize)
+	binary.LittleEndian.PutUint32(lvd[248:], discSectorSize) // 文件集描述符在分区的第0块
+	binary.LittleEndian.PutUint32(lvd[264:], 6)
+	binary.LittleEndian.PutUint32(lvd[268:], 1)
+	copy(lvd[440:], []byte{1, 6, 1, 0, 0, 0})
+	udfTestTag(lvd, 6)
+	udfTestTag(d.sector(34), 8)
+
+	block := func(n int) []byte { return d.sector(64 + n) }
+	fsd := block(0)
+	binary.LittleEndian.PutUint32(fsd[400:], discSectorSize)
+	binary.LittleEndian.PutUint32(fsd[404:], 1)
+	udfTestTag(fsd, 256)
+
+	root := udfTestFIDs(map[string][2]int{"docs": {3, 2}, "big.bin": {5, 0}, "tiny.txt": {6, 0}})
+	copy(block(2), root)
+	udfTestFE(block(1), 4, len(root), 0, shortAD(len(root), 2, 0))
+	docs := udfTestFIDs(map[string][2]int{"说明.txt": {7, 0}})
+	copy(block(4), docs)
+	udfTestFE(block(3), 4, len(docs), 0, shortAD(len(docs), 4, 0))
+
+	big = bytes.Repeat([]byte("0123456789abcdef"), 3*discSectorSize/16)
+	clear(big[discSectorSize : 2*discSectorSize])
+	copy(block(20), big[:discSectorSize])
+	copy(block(10), big[2*discSectorSize:])
+	ads := append(append(shortAD(discSectorSize, 20, 0), shortAD(discSectorSize, 0, 1)...), shortAD(discSectorSize, 10, 0)...)
+	udfTestFE(block(5), 5, len(big), 0, ads)
+	udfTestFE(block(6), 5, 5, 3, []byte("tiny!"))
+	copy(block(8), "你好")
+	udfTestFE(block(7), 5, len("你好"), 0, shortAD(len("你好"), 8, 0))
+	return d, big
+}
+
+// 只有主卷描述符的ISO9660映像，LARGE.BIN由两个目录记录（多段文件）组成
+func buildTestISO() []byte {
+	d := testDisc{}
+	record := func(name string, sector, size int, flags byte) []byte {
+		n := 33 + len(name)
+		n += n % 2
+		rec := make([]byte, n)
+		rec[0] = byte(n)
+		binary.LittleEndian.PutUint32(rec[2:], uint32(sector))
+		binary.LittleEndian.PutUint32(rec[10:], uint32(size))
+		rec[18], rec[19], rec[20] = 124, 1, 2
+		rec[25], rec[32] = flags, byte(len(name))
+		copy(rec[33:], name)
+		return rec
+	}
+	pvd := d.sector(16)
+	pvd[0] = 1
+	copy(pvd[1:], "CD001")
+	copy(pvd[40:], "ISO_TEST                        ")
+	copy(pvd[156:], record("\x00", 20, discSectorSize, 2))
+	term := d.sector(17)
+	term[0] = 255
+	copy(term[1:], "CD001")
+
+	var dir []byte
+	dir = append(dir, record("\x00", 20, discSectorSize, 2)...)
+	dir = append(dir, record("\x01", 20, discSectorSize, 2)...)
+	dir = append(dir, record("LARGE.BIN;1", 30, 3, 0x80)...)
+	dir = append(dir, record("LARGE.BIN;1", 31, 4, 0)...)
+	dir = append(dir, record("README.;1", 32, 6, 0)...)
+	copy(d.sector(20), dir)
+	copy(d.sector(30), "abc")
+	copy(d.sector(31), "defg")
+	copy(d.sector(32), "readme")
+	return d
+}
+
+func TestDiscImage(t *testing.T) {
+	udf, big := buildTestUDF()
+	img, err := openDiscImage(bytes.NewReader(udf), int64(len(udf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != "UDF" || img.Label != "测试光盘" {
+		t.Errorf("format = %s, label = %s", img.Format, img.Label)
+	}
+	e, err := img.lookup("/docs/说明.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ModTime.Year() != 2024 || e.ModTime.Format("-0700") != "+0800" {
+		t.Errorf("modTime = %v", e.ModTime)
+	}
+	for path, want := range map[string][]byte{"/docs/说明.txt": []byte("你好"), "tiny.txt": []byte("tiny!"), "/big.bin": big} {
+		e, err := img.lookup(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		got, _ := io.ReadAll(img.open(e))
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: content differs (%d bytes, want %d)", path, len(got), len(want))
+		}
+	}
+	if _, err := img.lookup("/docs/missing"); err != errDiscNotFound {
+		t.Errorf("missing file: err = %v", err)
+	}
+
+	iso := buildTestISO()
+	img, err = openDiscImage(bytes.NewReader(iso), int64(len(iso)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := img.fs.root()
+	entries, err := img.fs.readDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != "ISO9660" || img.Label != "ISO_TEST" || len(entries) != 2 || entries[0].Name != "LARGE.BIN" || entries[0].Size != 7 || entries[1].Name != "README" {
+		t.Fatalf("iso: format %s, label %q, entries %+v", img.Format, img.Label, entries)
+	}
+	// 按名称查找不区分大小写
+	e, err = img.lookup("large.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(img.open(e)); string(got) != "abcdefg" {
+		t.Errorf("multi-extent content = %q", got)
+	}
+
+	if _, err := openDiscImage(strings.NewReader("not an image"), 12); err == nil {
+		t.Error("expected error for non-image")
+	}
+}
+
+func TestDiscHandlers(t *testing.T) {
+	dir := t.TempDir()
+	udf, big := buildTestUDF()
+	iso := filepath.Join(dir, "install.iso")
+	os.WriteFile(iso, udf, 0644)
+	notImage := createTestFiles(t, dir, "disk.img")[0]
+
+	rec := serveTestRequest(apiDiscHandler, httptest.NewRequest(http.MethodGet, "/api/disc?path="+url.QueryEscape(iso), nil))
+	var list struct {
+		Format  string      `json:"format"`
+		Entries []discEntry `json:"entries"`
+	}
+	decodeTestJSON(t, rec, &list)
+	// 文件夹排在前面
+	if list.Format != "UDF" || len(list.Entries) != 3 || list.Entries[0].Name != "docs" || !list.Entries[0].Dir || list.Entries[1].Name != "big.bin" || list.Entries[1].Size != int64(len(big)) {
+		t.Fatalf("list = %+v", list)
+	}
+
+	// 从映像中按Range读取一个文件
+	req := httptest.NewRequest(http.MethodGet, "/disc/"+url.PathEscape(iso)+"?file=/big.bin&download=1", nil)
+	req.Header.Set("Range", "bytes=2000-2100")
+	rec = serveTestRequest(discFileHandler, req)
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), big[2000:2101]) {
+		t.Errorf("range: status %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+	}
+
+	tests := []struct {
+		handler http.HandlerFunc
+		target  string
+		want    int
+	}{
+		{discFileHandler, "/disc/" + url.PathEscape(iso) + "?file=/docs", http.StatusNotFound},
+		{discFileHandler, "/disc/" + url.PathEscape(iso) + "?file=/nope.txt", http.StatusNotFound},
+		{apiDiscHandler, "/api/disc?dir=/big.bin&path=" + url.QueryEscape(iso), http.StatusNotFound},
+		{apiDiscHandler, "/api/disc?path=" + url.QueryEscape(notImage), http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		if rec := serveTestRequest(tt.handler, httptest.NewRequest(http.MethodGet, tt.target, nil)); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Content-Disposition响应头
+//
+// 文件名可能含有引号、反斜杠、换行或中文，直接放进 filename="..." 会破坏响应头。
+// 按RFC 6266同时给出两种写法：filename为ASCII回退（其他字符替换为_），
+// filename*为RFC 5987编码的UTF-8原名，现代浏览器优先使用filename*。
+//
+// /file/ 只在请求带有 download=1 时作为附件下载，其他情况都内联返回，由下面的表决定Content-Type：
+// 浏览器能直接显示的类型按原类型返回；网页、SVG、脚本等可能在本站执行脚本的类型和表中没有的文本类型
+// （text/x-python等，浏览器会下载而不是显示）作为纯文本返回；其他类型（压缩包、程序等）按原类型返回，
+// 浏览器无法显示时自行下载，文件名由filename给出。
+
+// 按原类型内联预览的类型，以/结尾的表示整个大类
+var previewableContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"application/pdf",
+	"text/plain",
+	"text/css",
+	"text/csv",
+	"text/markdown",
+	"application/json",
+}
+
+// 可能执行脚本的类型，作为纯文本预览
+var scriptableContentTypes = []string{
+	"text/html",
+	"application/xhtml+xml",
+	"image/svg+xml",
+	"text/xml",
+	"application/xml",
+	"text/javascript",
+	"application/javascript",
+}
+
+func matchContentType(contentType string, table []string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, entry := range table {
+		if mediaType == entry || strings.HasSuffix(entry, "/") && strings.HasPrefix(mediaType, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// /file/ 的返回方式：attachment或inline，以及实际使用的Content-Type
+func fileDisposition(r *http.Request, contentType string) (disposition, servedType string) {
+	switch {
+	case r.URL.Query().Get("download") == "1":
+		return "attachment", "application/octet-stream"
+	case isPreviewableContentType(contentType):
+		return "inline", contentType
+	case matchContentType(contentType, scriptableContentTypes), strings.HasPrefix(contentType, "text/"):
+		return "inline", "text/plain; charset=utf-8"
+	default:
+		return "inline", contentType
+	}
+}
+
+// 能否在浏览器中按原类型预览
+func isPreviewableContentType(contentType string) bool {
+	return matchContentType(contentType, previewableContentTypes) && !matchContentType(contentType, scriptableContentTypes)
+}
+
+// 生成Content-Disposition，disposition为attachment或inline
+func contentDisposition(disposition, name string) string {
+	return disposition + `; filename="` + asciiFilename(name) + `"; filename*=UTF-8''` + encodeRFC5987(name)
+}
+
+// 只保留可打印ASCII字符，引号、反斜杠和其他字符替换为_
+func asciiFilename(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			b.WriteByte('_')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "download"
+	}
+	return b.String()
+}
+
+// RFC 5987的ext-value编码：attr-char原样保留，其他字节编码为%XX
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isRFC5987AttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+func isRFC5987AttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 多文件打包下载
+//
+// 选中的文件按大小拆分为若干个ZIP分卷，每个分卷在下载时即时生成。
+// 条目使用存储（不压缩）方式，ZIP的长度只取决于文件名和大小，
+// 因此可以预先算出Content-Length，并通过重新生成、跳过已下载部分来支持断点续传。
+
+const (
+	defaultDownloadPartMB = 2048           // 默认分卷大小（MB）
+	maxDownloadFiles      = 10000          // 单次打包的最大文件数
+	downloadBundleExpiry  = 24 * time.Hour // 打包任务保留时间
+)
+
+// 打包中的单个条目
+type downloadEntry struct {
+	path    string // 文件系统路径
+	name    string // ZIP内的路径
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// ZIP分卷
+type DownloadPart struct {
+	Index     int    `json:"index"`
+	URL       string `json:"url"`
+	FileName  string `json:"fileName"`
+	Size      int64  `json:"size"`
+	FileCount int    `json:"fileCount"`
+	Downloads int    `json:"downloads"` // 从头开始下载的次数
+
+	entries []downloadEntry
+	etag    string
+}
+
+// 打包下载任务
+type DownloadBundle struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Paths     []string        `json:"paths"`
+	Parts     []*DownloadPart `json:"parts"`
+	TotalSize int64           `json:"totalSize"`
+	FileCount int             `json:"fileCount"`
+	Created   string          `json:"created"`
+	Errors    []string        `json:"errors,omitempty"` // 无法访问的路径
+
+	session   string
+	createdAt time.Time
+}
+
+var (
+	downloadBundles      = make(map[string]*DownloadBundle)
+	downloadBundlesMutex sync.RWMutex
+)
+
+// 收集要打包的条目，文件夹递归展开
+func collectDownloadEntries(paths []string) ([]downloadEntry, []string) {
+	var entries []downloadEntry
+	var errs []string
+	usedNames := make(map[string]bool)
+
+	for _, clientSrc := range paths {
+		src := resolveClientPath(clientSrc)
+		info, err := statPath(src)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", clientSrc, err))
+			continue
+		}
+
+		// 不同文件夹中的同名条目加序号区分
+		rootName := filepath.Base(src)
+		for i := 2; usedNames[strings.ToLower(rootName)]; i++ {
+			ext := filepath.Ext(filepath.Base(src))
+			rootName = strings.TrimSuffix(filepath.Base(src), ext) + " (" + strconv.Itoa(i) + ")" + ext
+		}
+		usedNames[strings.ToLower(rootName)] = true
+
+		if !info.IsDir() {
+			entries = append(entries, downloadEntry{path: src, name: rootName, size: info.Size(), modTime: info.ModTime()})
+			continue
+		}
+
+		err = filepath.Walk(extendedPath(src), func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", displayPath(path), err))
+				return nil
+			}
+			if len(entries) >= maxDownloadFiles {
+				return errors.New("文件数超过上限")
+			}
+			rel, err := filepath.Rel(extendedPath(src), path)
+			if err != nil {
+				return nil
+			}
+			name := filepath.ToSlash(filepath.Join(rootName, rel))
+			if fi.IsDir() {
+				entries = append(entries, downloadEntry{path: displayPath(path), name: name + "/", modTime: fi.ModTime(), isDir: true})
+			} else if fi.Mode().IsRegular() {
+				entries = append(entries, downloadEntry{path: displayPath(path), name: name, size: fi.Size(), modTime: fi.ModTime()})
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", clientSrc, err))
+		}
+		if len(entries) >= maxDownloadFiles {
+			break
+		}
+	}
+
+	return entries, errs
+}
+
+// 按分卷大小拆分条目，单个文件超过分卷大小时单独成卷
+func splitDownloadEntries(entries []downloadEntry, partSize int64) [][]downloadEntry {
+	var parts [][]downloadEntry
+	var current []downloadEntry
+	var currentSize int64
+
+	for _, entry := range entries {
+		if len(current) > 0 && currentSize+entry.size > partSize {
+			parts = append(parts, current)
+			current, currentSize = nil, 0
+		}
+		current = append(current, entry)
+		currentSize += entry.size
+	}
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// 写出ZIP分卷。open为nil时写入全零数据，用于计算长度
+func writeZipPart(w io.Writer, entries []downloadEntry, open func(string) (io.ReadCloser, error)) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		header := &zip.FileHeader{
+			Name:     entry.name,
+			Method:   zip.Store,
+			Modified: entry.modTime,
+		}
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if entry.isDir {
+			continue
+		}
+
+		var src io.Reader = zeroReader{}
+		var file io.ReadCloser
+		if open != nil {
+			if file, err = open(entry.path); err == nil {
+				// 文件变短时用零补齐，保证长度与预先计算的一致
+				src = io.MultiReader(file, zeroReader{})
+			} else {
+				log.Printf("打包下载: 无法读取文件 %s, 错误: %v", entry.path, err)
+				file = nil
+			}
+		}
+		_, err = io.CopyN(writer, src, entry.size)
+		if file != nil {
+			file.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+type countingWriter struct{ n int64 }
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
+
+// 跳过前skip个字节后写入
+type skipWriter struct {
+	w    io.Writer
+	skip int64
+}
+
+func (sw *skipWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if sw.skip >= int64(n) {
+		sw.skip -= int64(n)
+		return n, nil
+	}
+	p = p[sw.skip:]
+	sw.skip = 0
+	if _, err := sw.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// 可定位的ZIP分卷读取器，供http.ServeContent使用。
+// 定位后重新生成ZIP并跳过offset之前的内容
+type zipPartReader struct {
+	ctx    context.Context // 请求的上下文，客户端断开后停止读取文件
+	part   *DownloadPart
+	offset int64
+	pr     *io.PipeReader
+}
+
+func (zr *zipPartReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += zr.offset
+	case io.SeekEnd:
+		offset += zr.part.Size
+	}
+	if offset < 0 {
+		return 0, errors.New("无效的偏移量")
+	}
+	if offset != zr.offset {
+		zr.Close()
+		zr.offset = offset
+	}
+	return offset, nil
+}
+
+func (zr *zipPartReader) Read(p []byte) (int, error) {
+	if zr.pr == nil {
+		pr, pw := io.Pipe()
+		zr.pr = pr
+		skip := zr.offset
+		go func() {
+			err := writeZipPart(&skipWriter{w: pw, skip: skip}, zr.part.entries, func(path string) (io.ReadCloser, error) {
+				file, err := openPath(path)
+				if err != nil {
+					return nil, err
+				}
+				return struct {
+					io.Reader
+					io.Closer
+				}{&contextReader{ctx: zr.ctx, r: file}, file}, nil
+			})
+			pw.CloseWithError(err)
+		}()
+	}
+	n, err := zr.pr.Read(p)
+	zr.offset += int64(n)
+	return n, err
+}
+
+func (zr *zipPartReader) Close() error {
+	if zr.pr != nil {
+		zr.pr.Close()
+		zr.pr = nil
+	}
+	return nil
+}
+
+// 创建打包下载任务
+func createDownloadBundle(session, name string, paths []string, partSize int64) (*DownloadBundle, error) {
+	entries, errs := collectDownloadEntries(paths)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("没有可下载的文件: %s", strings.Join(errs, "; "))
+	}
+
+	if name == "" {
+		if len(paths) == 1 {
+			name = filepath.Base(resolveClientPath(paths[0]))
+		} else {
+			name = "download-" + time.Now().Format("20060102-150405")
+		}
+	}
+
+	bundle := &DownloadBundle{
+		ID:        newRandomID(8),
+		Name:      name,
+		Paths:     paths,
+		Created:   time.Now().Format("2006-01-02 15:04:05"),
+		Errors:    errs,
+		session:   session,
+		createdAt: time.Now(),
+	}
+
+	chunks := splitDownloadEntries(entries, partSize)
+	for i, chunk := range chunks {
+		counter := &countingWriter{}
+		if err := writeZipPart(counter, chunk, nil); err != nil {
+			return nil, err
+		}
+
+		fileName := name + ".zip"
+		if len(chunks) > 1 {
+			fileName = fmt.Sprintf("%s.part%d.zip", name, i+1)
+		}
+
+		// ETag由条目的路径、大小和修改时间决定，文件变化后断点续传会重新开始
+		var sig strings.Builder
+		fileCount := 0
+		for _, entry := range chunk {
+			fmt.Fprintf(&sig, "%s|%d|%d\n", entry.path, entry.size, entry.modTime.UnixNano())
+			if !entry.isDir {
+				fileCount++
+			}
+		}
+
+		bundle.Parts = append(bundle.Parts, &DownloadPart{
+			Index:     i + 1,
+			URL:       fmt.Sprintf("/download/%s/%d", bundle.ID, i+1),
+			FileName:  fileName,
+			Size:      counter.n,
+			FileCount: fileCount,
+			entries:   chunk,
+			etag:      fmt.Sprintf(`"%s-%d-%x"`, bundle.ID, i+1, hashString(sig.String())),
+		})
+		bundle.TotalSize += counter.n
+		bundle.FileCount += fileCount
+	}
+
+	downloadBundlesMutex.Lock()
+	for id, b := range downloadBundles {
+		if time.Since(b.createdAt) > downloadBundleExpiry {
+			delete(downloadBundles, id)
+		}
+	}
+	downloadBundles[bundle.ID] = bundle
+	downloadBundlesMutex.Unlock()
+
+	return bundle, nil
+}
+
+// FNV-1a哈希
+func hashString(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// 打包下载API处理器
+// POST {"paths":[...],"name":"可选名称","partSizeMB":2048} 创建任务；GET 列出当前会话的任务
+func apiDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	session := getSessionID(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"bundles": sessionDownloadBundles(session),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Paths      []string `json:"paths"`
+			Name       string   `json:"name"`
+			PartSizeMB int64    `json:"partSizeMB"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Paths) == 0 {
+			http.Error(w, "请求格式错误，需要paths参数", http.StatusBadRequest)
+			return
+		}
+		if req.PartSizeMB <= 0 {
+			req.PartSizeMB = defaultDownloadPartMB
+		}
+
+		bundle, err := createDownloadBundle(session, req.Name, req.Paths, req.PartSizeMB*1024*1024)
+		if err != nil {
+			log.Printf("创建打包下载失败: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("创建打包下载: %s, %d个文件, %d个分卷, 总大小%d, 来源IP: %s", bundle.ID, bundle.FileCount, len(bundle.Parts), bundle.TotalSize, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(bundle)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+func sessionDownloadBundles(session string) []*DownloadBundle {
+	downloadBundlesMutex.RLock()
+	defer downloadBundlesMutex.RUnlock()
+
+	list := []*DownloadBundle{}
+	for _, bundle := range downloadBundles {
+		if bundle.session == session {
+			list = append(list, bundle)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].createdAt.After(list[j].createdAt) })
+	return list
+}
+
+// 分卷下载处理器 /download/任务ID/分卷序号，支持Range断点续传
+func downloadPartHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	index, err := strconv.Atoi(parts[1])
+
+	downloadBundlesMutex.RLock()
+	bundle, ok := downloadBundles[parts[0]]
+	downloadBundlesMutex.RUnlock()
+	if !ok || err != nil || index < 1 || index > len(bundle.Parts) {
+		http.Error(w, "下载任务不存在或已过期", http.StatusNotFound)
+		return
+	}
+	part := bundle.Parts[index-1]
+
+	log.Printf("分卷下载: %s 第%d卷, Range: %s, 来源IP: %s", bundle.ID, index, r.Header.Get("Range"), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", part.FileName))
+	w.Header().Set("ETag", part.etag)
+
+	reader := &zipPartReader{ctx: r.Context(), part: part}
+	defer reader.Close()
+	http.ServeContent(newTransferWriter(w, r), r, part.FileName, time.Time{}, reader)
+
+	if r.Header.Get("Range") == "" && r.Method == http.MethodGet {
+		downloadBundlesMutex.Lock()
+		part.Downloads++
+		downloadBundlesMutex.Unlock()
+	}
+}
+
+// 下载队列页面：列出当前会话的打包任务，可逐个或依次下载所有分卷
+func downloadsPageHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
+	bundles := sessionDownloadBundles(getSessionID(w, r))
+
+	var body strings.Builder
+	if len(bundles) == 0 {
+		body.WriteString(`<div class="empty">暂无打包下载任务。在搜索或浏览结果中勾选文件后点击“打包下载”。</div>`)
+	}
+	for _, bundle := range bundles {
+		fmt.Fprintf(&body, `<div class="bundle"><h3>%s</h3><div class="meta">%d个文件 • %s • 创建于 %s</div>`,
+			html.EscapeString(bundle.Name), bundle.FileCount, formatSize(bundle.TotalSize), bundle.Created)
+		if len(bundle.Errors) > 0 {
+			fmt.Fprintf(&body, `<div class="errors">%d个路径无法访问：%s</div>`, len(bundle.Errors), html.EscapeString(strings.Join(bundle.Errors, "; ")))
+		}
+		body.WriteString(`<ul>`)
+		for _, part := range bundle.Parts {
+			fmt.Fprintf(&body, `<li><a href="%s" download>%s</a> <span class="meta">%d个文件 • %s%s</span></li>`,
+				part.URL, html.EscapeString(part.FileName), part.FileCount, formatSize(part.Size),
+				map[bool]string{true: " • ✅ 已下载", false: ""}[part.Downloads > 0])
+		}
+		body.WriteString(`</ul>`)
+		if len(bundle.Parts) > 1 {
+			fmt.Fprintf(&body, `<button data-click="downloadAll" data-id="%s">依次下载全部分卷</button>`, html.EscapeString(bundle.ID))
+		}
+		body.WriteString(`</div>`)
+	}
+
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>下载队列 - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .container { max-width: 900px; margin: 0 auto; }
+        .bundle { background: white; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); padding: 15px 20px; margin-bottom: 15px; }
+        .bundle h3 { margin: 0 0 5px; }
+        .meta { color: #888; font-size: 13px; }
+        .errors { color: #f44336; font-size: 13px; margin-top: 5px; }
+        li { margin: 6px 0; }
+        a { color: #4CAF50; }
+        button { padding: 8px 16px; background: #4CAF50; color: white; border: none; border-radius: 4px; cursor: pointer; }
+        .empty { text-align: center; color: #888; padding: 40px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h2>📦 下载队列</h2>
+        <p><a href="/">← 返回首页</a></p>
+        ` + body.String() + `
+    </div>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({ downloadAll: el => downloadAll(el.dataset.id) });
+
+        // 逐个触发分卷下载，间隔几秒避免浏览器拦截多个下载
+        async function downloadAll(id) {
+            const response = await fetch('/api/download');
+            const data = await response.json();
+            const bundle = data.bundles.find(b => b.id === id);
+            if (!bundle) return;
+            for (const part of bundle.parts) {
+                const a = document.createElement('a');
+                a.href = part.url;
+                a.download = part.fileName;
+                document.body.appendChild(a);
+                a.click();
+                a.remove();
+                await new Promise(resolve => setTimeout(resolve, 3000));
+            }
+        }
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
+
+// 格式化文件大小
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// 其他系统只有一个根目录
+func getDriveRoots() []string {
+	return []string{"/"}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "syscall"
+
+var getLogicalDrivesProc = syscall.NewLazyDLL("kernel32.dll").NewProc("GetLogicalDrives")
+
+// 获取所有盘符根目录，如 C:\ D:\
+func getDriveRoots() []string {
+	var roots []string
+
+	mask, _, _ := getLogicalDrivesProc.Call()
+	for i := 0; i < 26; i++ {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		roots = append(roots, string(rune('A'+i))+":\\")
+	}
+
+	return roots
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 磁盘占用（树状图数据）
+//
+// GET /api/du?path=文件夹路径&depth=2&refresh=1
+// 用Everything索引中的文件大小汇总出各级文件夹的大小，返回嵌套结构，
+// 每层按大小从大到小排列，超过duMaxChildren的部分合并为一项。
+// 结果缓存5分钟（refresh=1时重新计算），客户端断开后停止计算。
+// GET /du?path= 为树状图页面。
+
+const (
+	duDefaultDepth = 2
+	duMaxDepth     = 5
+	duMaxChildren  = 30
+	duCacheExpiry  = 5 * time.Minute
+)
+
+type DuNode struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path,omitempty"` // 合并项没有路径
+	Size     int64     `json:"size"`
+	Files    int       `json:"files"` // 包含的文件数（含子文件夹）
+	IsDir    bool      `json:"isDir"`
+	Other    bool      `json:"other,omitempty"` // 合并的其余项
+	Children []*DuNode `json:"children,omitempty"`
+
+	childIndex map[string]*DuNode // 按小写名称查找子项，仅构建时使用
+}
+
+type DuResponse struct {
+	Root       *DuNode `json:"root"`
+	Depth      int     `json:"depth"`
+	Computed   string  `json:"computed"`
+	FromCache  bool    `json:"fromCache"`
+	ElapsedMs  int64   `json:"elapsedMs"`
+	EntryCount int     `json:"entryCount"` // 参与统计的条目数
+}
+
+type duCacheEntry struct {
+	resp    DuResponse
+	created time.Time
+}
+
+var (
+	duCache      = make(map[string]*duCacheEntry)
+	duCacheMutex sync.Mutex
+)
+
+func (n *DuNode) child(name, path string, isDir bool) *DuNode {
+	key := strings.ToLower(name)
+	if c, ok := n.childIndex[key]; ok {
+		return c
+	}
+	if n.childIndex == nil {
+		n.childIndex = make(map[string]*DuNode)
+	}
+	c := &DuNode{Name: name, Path: path, IsDir: isDir}
+	n.childIndex[key] = c
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// 按大小排序子项，超出数量的合并，并清除构建用的索引
+func (n *DuNode) finish() {
+	n.childIndex = nil
+	if len(n.Children) == 0 {
+		return
+	}
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Size > n.Children[j].Size })
+	if len(n.Children) > duMaxChildren {
+		rest := n.Children[duMaxChildren-1:]
+		other := &DuNode{Other: true}
+		for _, c := range rest {
+			other.Size += c.Size
+			other.Files += c.Files
+		}
+		other.Name = fmt.Sprintf("其余%d项", len(rest))
+		n.Children = append(n.Children[:duMaxChildren-1:duMaxChildren-1], other)
+	}
+	for _, c := range n.Children {
+		c.finish()
+	}
+}
+
+// 汇总文件夹大小，depth层以下的内容计入第depth层的文件夹
+func buildDuTree(root string, entries []everythingEntry, depth int) *DuNode {
+	root = strings.TrimRight(root, `\`)
+	tree := &DuNode{Name: displayPath(root), Path: clientPath(root), IsDir: true}
+
+	for _, e := range entries {
+		if len(e.Path) <= len(root)+1 || !strings.EqualFold(e.Path[:len(root)], root) || e.Path[len(root)] != '\\' {
+			continue
+		}
+		parts := strings.Split(e.Path[len(root)+1:], `\`)
+		if !e.IsDir {
+			tree.Size += e.Size
+			tree.Files++
+		}
+
+		node := tree
+		for i, part := range parts {
+			if i >= depth {
+				break
+			}
+			isDir := e.IsDir || i < len(parts)-1
+			node = node.child(part, clientPath(root+`\`+strings.Join(parts[:i+1], `\`)), isDir)
+			if !e.IsDir {
+				node.Size += e.Size
+				node.Files++
+			}
+		}
+	}
+	tree.finish()
+	return tree
+}
+
+// 计算（或从缓存获取）文件夹的占用树
+func computeDu(ctx context.Context, folder string, depth int, refresh bool) (*DuResponse, error) {
+	key := strings.ToLower(folder) + "|" + strconv.Itoa(depth)
+	duCacheMutex.Lock()
+	cached, ok := duCache[key]
+	duCacheMutex.Unlock()
+	if ok && !refresh && time.Since(cached.created) < duCacheExpiry {
+		resp := cached.resp
+		resp.FromCache = true
+		return &resp, nil
+	}
+
+	start := time.Now()
+	entries, err := collectEntries(ctx, folderQuery(folder))
+	if err != nil {
+		return nil, err
+	}
+	resp := DuResponse{
+		Root:       buildDuTree(folder, entries, depth),
+		Depth:      depth,
+		Computed:   time.Now().Format("2006-01-02 15:04:05"),
+		ElapsedMs:  time.Since(start).Milliseconds(),
+		EntryCount: len(entries),
+	}
+
+	duCacheMutex.Lock()
+	duCache[key] = &duCacheEntry{resp: resp, created: time.Now()}
+	for k, c := range duCache {
+		if time.Since(c.created) > duCacheExpiry {
+			delete(duCache, k)
+		}
+	}
+	duCacheMutex.Unlock()
+	return &resp, nil
+}
+
+// 磁盘占用API处理器
+func apiDuHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	folder := resolveClientPath(r.URL.Query().Get("path"))
+	if folder == "" {
+		http.Error(w, "缺少path参数", http.StatusBadRequest)
+		return
+	}
+	if info, err := statPath(folder); err != nil || !info.IsDir() {
+		if err != nil && !os.IsNotExist(err) {
+			http.Error(w, "访问文件夹失败: "+err.Error(), http.StatusInternalServerError)
+		} else {
+			http.Error(w, "文件夹不存在", http.StatusNotFound)
+		}
+		return
+	}
+
+	depth := duDefaultDepth
+	if s := r.URL.Query().Get("depth"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > duMaxDepth {
+			http.Error(w, fmt.Sprintf("depth参数应为1-%d之间的整数", duMaxDepth), http.StatusBadRequest)
+			return
+		}
+		depth = n
+	}
+
+	resp, err := computeDu(r.Context(), folder, depth, r.URL.Query().Get("refresh") == "1")
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消磁盘占用计算: %s", folder)
+		return
+	}
+	if err != nil {
+		log.Printf("计算磁盘占用失败: %s, 错误: %v", folder, err)
+		http.Error(w, "计算磁盘占用失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("磁盘占用: %s，深度%d，共%s，缓存: %v，用时%dms，来源IP: %s",
+		folder, depth, formatSize(resp.Root.Size), resp.FromCache, resp.ElapsedMs, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 磁盘占用树状图页面
+func duPageHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
+	folder := r.URL.Query().Get("path")
+	folderJSON, _ := json.Marshal(folder)
+
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>磁盘占用 - ` + html.EscapeString(folder) + ` - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 10px; font-size: 14px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; }
+        #treemap { position: relative; width: 100%; height: calc(100vh - 120px); background: white; border-radius: 8px; overflow: hidden; }
+        .tile { position: absolute; box-sizing: border-box; border: 1px solid white; overflow: hidden; font-size: 12px; padding: 2px 4px; color: white; cursor: pointer; text-shadow: 0 1px 2px rgba(0,0,0,0.5); }
+        .tile.other { cursor: default; background: #bbb !important; }
+        .tile:hover { filter: brightness(1.1); }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>🗂 磁盘占用</strong>
+        <span id="crumb"></span>
+        <label>层数 <select id="depth"><option>1</option><option selected>2</option><option>3</option><option>4</option><option>5</option></select></label>
+        <button data-click="reload">重新计算</button>
+        <span class="meta" id="info">计算中...</span>
+    </div>
+    <div id="treemap"></div>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({ reload: () => load(true) });
+
+        const folder = ` + string(folderJSON) + `;
+        const colors = ['#4CAF50', '#2196F3', '#FF9800', '#9C27B0', '#009688', '#E91E63', '#3F51B5', '#795548'];
+        let controller = null;
+        let data = null;
+
+        function formatSize(bytes) {
+            const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+            let i = 0;
+            while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
+            return bytes.toFixed(i ? 1 : 0) + ' ' + units[i];
+        }
+
+        function escapeHtml(s) {
+            return String(s).replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
+        }
+
+        async function load(refresh) {
+            if (controller) controller.abort(); // 取消上一次尚未完成的计算
+            controller = new AbortController();
+            document.getElementById('info').textContent = '计算中...';
+            const depth = document.getElementById('depth').value;
+            try {
+                const response = await fetch('/api/du?path=' + encodeURIComponent(folder) + '&depth=' + depth + (refresh ? '&refresh=1' : ''), { signal: controller.signal });
+                if (!response.ok) throw new Error(await response.text());
+                data = await response.json();
+                document.getElementById('crumb').textContent = data.root.name + '（' + formatSize(data.root.size) + '，' + data.root.files + '个文件）';
+                document.getElementById('info').textContent = (data.fromCache ? '缓存于 ' : '计算于 ') + data.computed + '，用时' + data.elapsedMs + 'ms';
+                render();
+            } catch (error) {
+                if (error.name !== 'AbortError') document.getElementById('info').textContent = '计算失败: ' + error.message;
+            }
+        }
+
+        // 方形化树状图布局：按行排列，使每个矩形尽量接近正方形
+        function squarify(items, x, y, w, h, out) {
+            const total = items.reduce((s, it) => s + it.size, 0);
+            if (!items.length || total <= 0 || w <= 0 || h <= 0) return;
+            const scale = w * h / total;
+            let row = [], rest = items.slice();
+            const worst = (row, side) => {
+                const sum = row.reduce((s, it) => s + it.size * scale, 0);
+                const max = Math.max(...row.map(it => it.size * scale)), min = Math.min(...row.map(it => it.size * scale));
+                return Math.max(side * side * max / (sum * sum), sum * sum / (side * side * min));
+            };
+            while (rest.length) {
+                const side = Math.min(w, h);
+                const next = row.concat([rest[0]]);
+                if (row.length && worst(next, side) > worst(row, side)) break;
+                row = next;
+                rest.shift();
+            }
+            const rowArea = row.reduce((s, it) => s + it.size * scale, 0);
+            if (w >= h) {
+                const rw = rowArea / h;
+                let cy = y;
+                row.forEach(it => { const ih = it.size * scale / rw; out.push({ item: it, x: x, y: cy, w: rw, h: ih }); cy += ih; });
+                squarify(rest, x + rw, y, w - rw, h, out);
+            } else {
+                const rh = rowArea / w;
+                let cx = x;
+                row.forEach(it => { const iw = it.size * scale / rh; out.push({ item: it, x: cx, y: y, w: iw, h: rh }); cx += iw; });
+                squarify(rest, x, y + rh, w, h - rh, out);
+            }
+        }
+
+        function render() {
+            const container = document.getElementById('treemap');
+            container.innerHTML = '';
+            if (!data) return;
+            const draw = (node, x, y, w, h, level, color) => {
+                const rects = [];
+                squarify((node.children || []).filter(c => c.size > 0), x, y, w, h, rects);
+                rects.forEach((r, i) => {
+                    const c = color || colors[i % colors.length];
+                    const tile = document.createElement('div');
+                    tile.className = 'tile' + (r.item.other ? ' other' : '');
+                    tile.style.cssText = 'left:' + r.x + 'px;top:' + r.y + 'px;width:' + r.w + 'px;height:' + r.h + 'px;background:' + c + ';opacity:' + (1 - level * 0.12);
+                    tile.title = r.item.name + '\n' + formatSize(r.item.size) + '，' + r.item.files + '个文件';
+                    if (r.w > 50 && r.h > 16) tile.innerHTML = escapeHtml(r.item.name) + ' <small>' + formatSize(r.item.size) + '</small>';
+                    tile.onclick = e => {
+                        e.stopPropagation();
+                        if (r.item.other) return;
+                        if (r.item.isDir) window.location.href = '/du?path=' + encodeURIComponent(r.item.path);
+                        else window.open('/file/' + encodeURIComponent(r.item.path), '_blank');
+                    };
+                    container.appendChild(tile);
+                    // 文件夹内部留出标题行后绘制下一层
+                    if (r.item.children && r.w > 40 && r.h > 40) draw(r.item, r.x + 2, r.y + 16, r.w - 4, r.h - 18, level + 1, c);
+                });
+            };
+            draw(data.root, 0, 0, container.clientWidth, container.clientHeight, 0, null);
+        }
+
+        document.getElementById('depth').addEventListener('change', () => load(false));
+        window.addEventListener('resize', render);
+        load(false);
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
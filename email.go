@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 邮件通知
+//
+// 在config.json的smtp中配置发信服务器后，通知渠道可以使用 {"type":"email"}（可用to指定收件人，
+// 默认使用smtp.to）。端口465使用SSL直接连接，其他端口在服务器支持时使用STARTTLS。
+
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"` // 默认587
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"` // 默认与username相同
+	To       []string `json:"to"`   // 默认收件人
+}
+
+func smtpConfigured() bool {
+	return serverConfig.SMTP.Host != ""
+}
+
+func notifyEmail(ctx context.Context, ch NotifyChannel, n Notification) error {
+	to := ch.To
+	if len(to) == 0 {
+		to = serverConfig.SMTP.To
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("没有收件人")
+	}
+
+	body := n.Message
+	if body == "" {
+		body = n.Title
+	}
+	body += "\n\n" + n.Time + " · Everything Web"
+	return sendEmail(ctx, to, n.Title, body)
+}
+
+// 发送纯文本邮件，标题和正文使用UTF-8
+func sendEmail(ctx context.Context, to []string, subject, body string) error {
+	cfg := serverConfig.SMTP
+	if cfg.Host == "" {
+		return fmt.Errorf("未配置SMTP服务器")
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: notifyTimeout}
+	var conn net.Conn
+	var err error
+	if port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: cfg.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != 465 {
+		if err := client.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return fmt.Errorf("STARTTLS失败: %v", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP登录失败: %v", err)
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return fmt.Errorf("收件人%s被拒绝: %v", addr, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"everything-web-server/pkg/everything"
+)
+
+// es.exe回退搜索
+//
+// Everything SDK不可用时通过es.exe（Everything命令行工具）搜索。es.exe以CSV输出完整路径、
+// 大小（字节）、修改时间（FILETIME）和属性，解析为与SDK相同的everything.Result。
+// 搜索API的排序和结果数上限转换为 -sort 和 -n，配置的实例转换为 -instance。
+// es.exe自己解析命令行，查询按原样追加在选项之后（见esexe_windows.go），
+// 引号、空格和 | 等与在Everything搜索框中输入的效果相同。输出使用UTF-8代码页，避免中文乱码。
+
+// 依次查找es.exe的位置
+func esExeCandidates() []string {
+	var candidates []string
+	if path := everythingDirFile("es.exe"); path != "" {
+		candidates = append(candidates, path)
+	}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), "es.exe"))
+	}
+	if wd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(wd, "es.exe"))
+	}
+	if path, err := exec.LookPath("es.exe"); err == nil {
+		candidates = append(candidates, path)
+	}
+	return append(candidates,
+		`C:\Program Files\Everything\es.exe`,
+		`C:\Program Files (x86)\Everything\es.exe`,
+	)
+}
+
+// es.exe的完整路径，找不到时返回空字符串
+func esExePath() string {
+	for _, path := range esExeCandidates() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			if abs, err := filepath.Abs(path); err == nil {
+				return abs
+			}
+			return path
+		}
+	}
+	return ""
+}
+
+// es.exe的选项，查询追加在最后
+func esExeArgs(opts everythingQueryOptions) []string {
+	args := []string{
+		"-csv",
+		"-cp", "65001", // UTF-8输出
+		"-size", "-size-format", "1", // 字节
+		"-date-modified", "-date-format", "2", // FILETIME
+		"-attributes",
+	}
+	args = append(args, opts.esExeArgs()...)
+	return append(args, everythingInstanceArgs()...)
+}
+
+// 回退方案：使用es.exe搜索文件（Everything SDK不可用时）
+func searchWithESExe(ctx context.Context, query string) ([]everything.Result, error) {
+	log.Printf("使用es.exe回退搜索: %s", query)
+
+	exe := esExePath()
+	if exe == "" {
+		return nil, errors.New("找不到es.exe，请将es.exe放在程序所在文件夹或配置everythingDir")
+	}
+	cmd := exec.CommandContext(ctx, exe, esExeArgs(queryOptionsFrom(ctx))...)
+	setESExeQuery(cmd, query)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("执行es.exe失败: %v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("执行es.exe失败: %v", err)
+	}
+
+	results, err := parseESExeCSV(output)
+	if err != nil {
+		return nil, fmt.Errorf("解析es.exe输出失败: %v", err)
+	}
+	log.Printf("es.exe返回%d个有效路径", len(results))
+	return results, nil
+}
+
+// 解析es.exe的CSV输出，按表头确定列的位置
+func parseESExeCSV(output []byte) ([]everything.Result, error) {
+	output = bytes.TrimPrefix(output, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	pathColumn, ok := columns["filename"]
+	if !ok {
+		return nil, fmt.Errorf("缺少Filename列: %v", header)
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var results []everything.Result
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pathColumn >= len(record) || record[pathColumn] == "" {
+			continue
+		}
+
+		result := everything.Result{
+			Path:  record[pathColumn],
+			IsDir: strings.Contains(field(record, "attributes"), "D"),
+			Size:  -1,
+		}
+		if size, err := strconv.ParseInt(field(record, "size"), 10, 64); err == nil {
+			result.Size = size
+		}
+		if ft, err := strconv.ParseInt(field(record, "date modified"), 10, 64); err == nil && ft > 0 {
+			result.Modified = filetimeToTime(ft)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// 结果的完整路径
+func resultPaths(results []everything.Result) []string {
+	paths := make([]string, len(results))
+	for i, result := range results {
+		paths[i] = result.Path
+	}
+	return paths
+}
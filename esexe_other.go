@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+import "os/exec"
+
+func setESExeQuery(cmd *exec.Cmd, query string) {
+	cmd.Args = append(cmd.Args, query)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"everything-web-server/pkg/everything"
+)
+
+func TestParseESExeCSV(t *testing.T) {
+	output := "\xef\xbb\xbf" + `Size,Date Modified,Attributes,Filename
+1024,133500000000000000,A,"C:\Videos\a, b.mp4"
+,133500000000000000,D,C:\Videos\中文 文件夹
+2048,,A,"C:\Videos\say ""hi"".txt"
+`
+	results, err := parseESExeCSV([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("%d results, want 3", len(results))
+	}
+
+	if r := results[0]; r.Path != `C:\Videos\a, b.mp4` || r.Size != 1024 || r.IsDir {
+		t.Errorf("results[0] = %+v", r)
+	}
+	want := time.Date(2024, 1, 17, 21, 20, 0, 0, time.UTC)
+	if got := results[0].Modified.UTC(); !got.Equal(want) {
+		t.Errorf("modified = %v, want %v", got, want)
+	}
+	if r := results[1]; r.Path != `C:\Videos\中文 文件夹` || !r.IsDir || r.Size != -1 {
+		t.Errorf("results[1] = %+v", r)
+	}
+	if r := results[2]; r.Path != `C:\Videos\say "hi".txt` || !r.Modified.IsZero() {
+		t.Errorf("results[2] = %+v", r)
+	}
+}
+
+func TestParseESExeCSVEmpty(t *testing.T) {
+	results, err := parseESExeCSV(nil)
+	if err != nil || len(results) != 0 {
+		t.Errorf("got %v, %v", results, err)
+	}
+}
+
+func TestESExeQueryOptionArgs(t *testing.T) {
+	tests := []struct {
+		opts everythingQueryOptions
+		want string
+	}{
+		{everythingQueryOptions{}, ""},
+		{everythingQueryOptions{Sort: "size", Descending: true}, "-sort size-descending"},
+		{everythingQueryOptions{Sort: "date", Max: 50}, "-sort date-modified-ascending -n 50"},
+		{everythingQueryOptions{Sort: "ext"}, "-sort extension-ascending"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.opts.esExeArgs(), " "); got != tt.want {
+			t.Errorf("%+v: args = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+
+	if got := (everythingQueryOptions{Sort: "date", Descending: true}).sdkSort(); got != everything.SortDateModifiedDescending {
+		t.Errorf("sdkSort = %d, want %d", got, everything.SortDateModifiedDescending)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// 查询按原样追加到命令行末尾，不经过Go的参数转义，es.exe按Everything的规则解析引号
+func setESExeQuery(cmd *exec.Cmd, query string) {
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		args[i] = syscall.EscapeArg(arg)
+	}
+	cmd.Args = append(cmd.Args, query)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: strings.Join(args, " ") + " " + query}
+}
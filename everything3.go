@@ -0,0 +1,52 @@
+package main
+
+import "strings"
+
+// Everything 1.5 扩展属性
+//
+// Everything 1.5可以在索引中保存图片和视频的尺寸、时长、码率等属性。检测到1.5（Everything3 SDK
+// 能连接）时，每页搜索和浏览结果通过一次查询取回这些属性，写入SearchResult，不需要访问文件本身。
+// 1.4或未安装Everything3_x64.dll时跳过，结果中没有这些字段。
+
+const everything3MaxPathsPerQuery = 200 // 每次查询的路径数，避免搜索字符串过长
+
+type everything3Properties struct {
+	Width, Height int
+	Duration      float64 // 秒
+	Bitrate       int     // kbps
+	Attributes    uint32
+	HasAttributes bool
+}
+
+// 从Everything 1.5索引中取出结果的尺寸、时长、码率和属性，1.5不可用时不做任何事
+func applyEverythingProperties(results []SearchResult) {
+	if len(results) == 0 || remoteEverythingEnabled() {
+		return
+	}
+
+	for start := 0; start < len(results); start += everything3MaxPathsPerQuery {
+		end := min(start+everything3MaxPathsPerQuery, len(results))
+		paths := make([]string, 0, end-start)
+		for _, result := range results[start:end] {
+			paths = append(paths, resolveClientPath(result.Path))
+		}
+
+		props, ok := queryEverything3Properties(paths)
+		if !ok {
+			return
+		}
+		for i := start; i < end; i++ {
+			p, found := props[strings.ToLower(paths[i-start])]
+			if !found {
+				continue
+			}
+			results[i].Width = p.Width
+			results[i].Height = p.Height
+			results[i].Duration = p.Duration
+			results[i].Bitrate = p.Bitrate
+			if p.HasAttributes {
+				applyFileAttributes(&results[i], p.Attributes)
+			}
+		}
+	}
+}
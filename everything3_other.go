@@ -0,0 +1,26 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// Everything 1.5只能在Windows上连接，其他系统上扩展属性和命名实例不可用
+
+func everything3Available() bool {
+	return false
+}
+
+func queryEverything3Properties(paths []string) (map[string]everything3Properties, bool) {
+	return nil, false
+}
+
+func searchWithEverything3(ctx context.Context, query string) ([]string, error) {
+	return nil, errors.New("Everything3 SDK只支持Windows")
+}
+
+func everything3Version() string {
+	return ""
+}
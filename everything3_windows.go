@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	everything3InvalidPropertyID = 0xFFFFFFFF
+	everything3InvalidDWORD      = 0xFFFFFFFF
+	everything3InvalidUINT64     = ^uintptr(0)
+)
+
+// 属性及其可能的名称（不同版本的名称不同，依次尝试）
+var everything3PropertyNames = map[string][]string{
+	"width":      {"width"},
+	"height":     {"height"},
+	"length":     {"length", "duration"},
+	"bitrate":    {"total-bitrate", "total bitrate", "bitrate", "bit-rate"},
+	"attributes": {"attributes"},
+	"name":       {"name"},
+	"path":       {"path"},
+}
+
+var (
+	everything3DLL     *syscall.LazyDLL
+	everything3Client  uintptr
+	everything3PropIDs map[string]uintptr // 属性名 → 属性ID
+	everything3Mutex   sync.Mutex
+)
+
+// 查找Everything3 SDK的DLL
+func everything3DLLPath() string {
+	for _, path := range []string{
+		everythingDirFile("Everything3_x64.dll"),
+		"Everything3_x64.dll",
+		`C:\Program Files\Everything 1.5a\Everything3_x64.dll`,
+		`C:\Program Files\Everything\Everything3_x64.dll`,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func everything3Call(name string, args ...uintptr) uintptr {
+	ret, _, _ := everything3DLL.NewProc(name).Call(args...)
+	return ret
+}
+
+// 连接Everything 1.5，已连接时直接返回；调用方需持有everything3Mutex
+func connectEverything3() bool {
+	if everything3Client != 0 {
+		return true
+	}
+	if everything3DLL == nil {
+		path := everything3DLLPath()
+		if path == "" {
+			return false
+		}
+		dll := syscall.NewLazyDLL(path)
+		if err := dll.Load(); err != nil {
+			log.Printf("无法加载 %s: %v", path, err)
+			return false
+		}
+		everything3DLL = dll
+	}
+
+	for _, instance := range everything3InstanceNames() {
+		var namePtr *uint16
+		if instance != "" {
+			namePtr, _ = syscall.UTF16PtrFromString(instance)
+		}
+		client := everything3Call("Everything3_ConnectW", uintptr(unsafe.Pointer(namePtr)))
+		if client == 0 {
+			continue
+		}
+		everything3Client = client
+		everything3PropIDs = make(map[string]uintptr)
+		for key, names := range everything3PropertyNames {
+			for _, name := range names {
+				namePtr, _ := syscall.UTF16PtrFromString(name)
+				id := everything3Call("Everything3_FindPropertyW", client, uintptr(unsafe.Pointer(namePtr)))
+				if id != everything3InvalidPropertyID {
+					everything3PropIDs[key] = id
+					break
+				}
+			}
+		}
+		log.Printf("已连接Everything 1.5（实例: %q），可用属性: %d个", instance, len(everything3PropIDs))
+		return true
+	}
+	return false
+}
+
+// 断开连接，下次使用时重新连接（Everything重启后旧连接失效）
+func disconnectEverything3() {
+	if everything3Client != 0 {
+		everything3Call("Everything3_DestroyClient", everything3Client)
+		everything3Client = 0
+	}
+}
+
+// Everything 1.5是否可用
+func everything3Available() bool {
+	everything3Mutex.Lock()
+	defer everything3Mutex.Unlock()
+	return connectEverything3()
+}
+
+// 按完整路径查询一组文件的扩展属性
+func queryEverything3Properties(paths []string) (map[string]everything3Properties, bool) {
+	everything3Mutex.Lock()
+	defer everything3Mutex.Unlock()
+	if !connectEverything3() {
+		return nil, false
+	}
+
+	terms := make([]string, len(paths))
+	for i, path := range paths {
+		terms[i] = `wfn:"` + path + `"`
+	}
+	text, err := syscall.UTF16PtrFromString(strings.Join(terms, " | "))
+	if err != nil {
+		return nil, true
+	}
+
+	state := everything3Call("Everything3_CreateSearchState")
+	if state == 0 {
+		return nil, true
+	}
+	defer everything3Call("Everything3_DestroySearchState", state)
+	everything3Call("Everything3_SetSearchTextW", state, uintptr(unsafe.Pointer(text)))
+	for _, id := range everything3PropIDs {
+		everything3Call("Everything3_AddSearchPropertyRequest", state, id)
+	}
+
+	list := everything3Call("Everything3_Search", everything3Client, state)
+	if list == 0 {
+		// 连接可能已失效，下次重新连接
+		disconnectEverything3()
+		return nil, true
+	}
+	defer everything3Call("Everything3_DestroyResultList", list)
+
+	props := make(map[string]everything3Properties)
+	count := everything3Call("Everything3_GetResultListCount", list)
+	buffer := make([]uint16, 4096)
+	for i := uintptr(0); i < count; i++ {
+		everything3Call("Everything3_GetResultFullPathNameW", list, i, uintptr(unsafe.Pointer(&buffer[0])), uintptr(len(buffer)))
+		var p everything3Properties
+		dword := func(key string) int {
+			id, ok := everything3PropIDs[key]
+			if !ok {
+				return 0
+			}
+			v := everything3Call("Everything3_GetResultPropertyDWORD", list, i, id)
+			if v == everything3InvalidDWORD {
+				return 0
+			}
+			return int(v)
+		}
+		p.Width = dword("width")
+		p.Height = dword("height")
+		p.Bitrate = dword("bitrate") / 1000
+		if id, ok := everything3PropIDs["length"]; ok {
+			// 时长以100纳秒为单位
+			if v := everything3Call("Everything3_GetResultPropertyUINT64", list, i, id); v != everything3InvalidUINT64 {
+				p.Duration = float64(v) / 1e7
+			}
+		}
+		if _, ok := everything3PropIDs["attributes"]; ok {
+			p.Attributes = uint32(dword("attributes"))
+			p.HasAttributes = true
+		}
+		props[strings.ToLower(syscall.UTF16ToString(buffer))] = p
+	}
+	return props, true
+}
+
+// 通过Everything3 SDK在配置的实例中搜索
+func searchWithEverything3(ctx context.Context, query string) ([]string, error) {
+	log.Printf("使用Everything3 SDK搜索（实例: %s）: %s", everythingInstanceName(), query)
+
+	everything3Mutex.Lock()
+	defer everything3Mutex.Unlock()
+	if !connectEverything3() {
+		return nil, fmt.Errorf("无法连接Everything实例: %s", everythingInstanceName())
+	}
+
+	text, err := syscall.UTF16PtrFromString(query)
+	if err != nil {
+		return nil, err
+	}
+	state := everything3Call("Everything3_CreateSearchState")
+	if state == 0 {
+		return nil, fmt.Errorf("Everything3_CreateSearchState失败")
+	}
+	defer everything3Call("Everything3_DestroySearchState", state)
+	everything3Call("Everything3_SetSearchTextW", state, uintptr(unsafe.Pointer(text)))
+
+	list := everything3Call("Everything3_Search", everything3Client, state)
+	if list == 0 {
+		disconnectEverything3()
+		return nil, fmt.Errorf("Everything实例 %s 查询失败", everythingInstanceName())
+	}
+	defer everything3Call("Everything3_DestroyResultList", list)
+
+	count := everything3Call("Everything3_GetResultListCount", list)
+	log.Printf("Everything找到%d个结果", count)
+	paths := make([]string, 0, count)
+	buffer := make([]uint16, 4096)
+	for i := uintptr(0); i < count; i++ {
+		if i%1000 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		everything3Call("Everything3_GetResultFullPathNameW", list, i, uintptr(unsafe.Pointer(&buffer[0])), uintptr(len(buffer)))
+		if path := syscall.UTF16ToString(buffer); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return applyQueryOptions(paths, queryOptionsFrom(ctx)), nil
+}
+
+// 配置的实例的版本，无法连接时返回空字符串
+func everything3Version() string {
+	everything3Mutex.Lock()
+	defer everything3Mutex.Unlock()
+	if !connectEverything3() {
+		return ""
+	}
+	major := everything3Call("Everything3_GetMajorVersion", everything3Client)
+	if major == 0 {
+		// Everything已退出，旧连接失效
+		disconnectEverything3()
+		return ""
+	}
+	minor := everything3Call("Everything3_GetMinorVersion", everything3Client)
+	revision := everything3Call("Everything3_GetRevision", everything3Client)
+	build := everything3Call("Everything3_GetBuildNumber", everything3Client)
+	return fmt.Sprintf("%d.%d.%d.%d", major, minor, revision, build)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Everything索引状态和重新扫描
+//
+// GET  /api/everything/status            索引是否已加载、索引中的文件和文件夹数、最近一次重新扫描
+// POST /api/everything/rescan?mode=update|rebuild
+// update重新扫描文件夹索引（非NTFS卷、网络路径等不能实时更新的位置），rebuild重建整个数据库。
+// 优先通过SDK执行，SDK不可用时调用 Everything.exe -reindex（仅rebuild）。
+// 重新扫描后清除搜索缓存；两次重新扫描至少间隔1分钟。
+
+const rescanMinInterval = time.Minute
+
+type RescanRecord struct {
+	Mode   string `json:"mode"`
+	Method string `json:"method"` // sdk或command
+	Time   string `json:"time"`
+	Source string `json:"source"` // 来源IP
+
+	at time.Time
+}
+
+type EverythingIndexStatus struct {
+	Running      bool          `json:"running"`
+	DBLoaded     bool          `json:"dbLoaded"`
+	TotalFiles   int           `json:"totalFiles"`
+	TotalFolders int           `json:"totalFolders"`
+	Version      string        `json:"version,omitempty"`
+	LastRescan   *RescanRecord `json:"lastRescan,omitempty"`
+	CheckedAt    string        `json:"checkedAt"`
+	Error        string        `json:"error,omitempty"`
+	RescanMethod string        `json:"rescanMethod,omitempty"` // sdk或command，为空时无法重新扫描
+}
+
+var (
+	lastRescan  *RescanRecord
+	rescanMutex sync.Mutex
+)
+
+// 查询索引状态：是否加载完成，以及索引中的文件和文件夹总数
+func getEverythingIndexStatus() EverythingIndexStatus {
+	status := EverythingIndexStatus{CheckedAt: time.Now().Format("2006-01-02 15:04:05")}
+	tools := getToolStatus()
+	status.Running = tools.Everything.Running
+	status.Version = tools.Everything.Version
+
+	rescanMutex.Lock()
+	if lastRescan != nil {
+		record := *lastRescan
+		status.LastRescan = &record
+	}
+	rescanMutex.Unlock()
+
+	if everythingExePath() != "" {
+		status.RescanMethod = "command"
+	}
+	if err := initEverythingSDK(); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	if status.Running {
+		status.RescanMethod = "sdk"
+	}
+
+	status.DBLoaded = everythingClient.IsDBLoaded()
+	if !status.DBLoaded {
+		return status
+	}
+
+	// 空查询匹配所有条目，只需要总数，不取回结果
+	files, dirs, err := everythingClient.Totals(context.Background(), "")
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.TotalFiles = files
+	status.TotalFolders = dirs
+	return status
+}
+
+// Everything.exe的位置，找不到时返回空字符串
+func everythingExePath() string {
+	var candidates []string
+	if path := everythingDirFile("Everything.exe"); path != "" {
+		candidates = append(candidates, path)
+	}
+	if everythingDLLPath != "" {
+		if abs, err := filepath.Abs(everythingDLLPath); err == nil {
+			candidates = append(candidates, filepath.Join(filepath.Dir(abs), "Everything.exe"))
+		}
+	}
+	candidates = append(candidates,
+		"Everything.exe",
+		`C:\Program Files\Everything\Everything.exe`,
+		`C:\Program Files (x86)\Everything\Everything.exe`)
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// 触发重新扫描，返回使用的方式
+func triggerRescan(mode string) (string, error) {
+	if err := initEverythingSDK(); err == nil && getToolStatus().Everything.Running {
+		rescan := everythingClient.UpdateAllFolderIndexes
+		if mode == "rebuild" {
+			rescan = everythingClient.RebuildDB
+		}
+		err := rescan()
+		if err == nil {
+			return "sdk", nil
+		}
+		log.Printf("通过SDK重新扫描失败(%s): %v", mode, err)
+	}
+
+	if mode != "rebuild" {
+		return "", fmt.Errorf("Everything SDK不可用，无法重新扫描文件夹索引")
+	}
+	exe := everythingExePath()
+	if exe == "" {
+		return "", fmt.Errorf("Everything SDK不可用，也找不到Everything.exe")
+	}
+	if err := exec.Command(exe, append(everythingInstanceArgs(), "-reindex")...).Start(); err != nil {
+		return "", fmt.Errorf("执行Everything.exe失败: %v", err)
+	}
+	return "command", nil
+}
+
+// 索引状态API处理器
+func apiEverythingStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(getEverythingIndexStatus())
+}
+
+// 重新扫描API处理器
+func apiEverythingRescanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "update"
+	}
+	if mode != "update" && mode != "rebuild" {
+		http.Error(w, "mode参数应为update或rebuild", http.StatusBadRequest)
+		return
+	}
+
+	rescanMutex.Lock()
+	defer rescanMutex.Unlock()
+	if lastRescan != nil && time.Since(lastRescan.at) < rescanMinInterval {
+		w.Header().Set("Retry-After", fmt.Sprint(int((rescanMinInterval-time.Since(lastRescan.at)).Seconds())+1))
+		http.Error(w, "重新扫描过于频繁，请稍后再试", http.StatusTooManyRequests)
+		return
+	}
+
+	method, err := triggerRescan(mode)
+	if err != nil {
+		log.Printf("重新扫描失败: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	now := time.Now()
+	lastRescan = &RescanRecord{Mode: mode, Method: method, Time: now.Format("2006-01-02 15:04:05"), Source: r.RemoteAddr, at: now}
+
+	// 索引变化后缓存的结果可能已过时
+	cacheMutex.Lock()
+	cleared := len(searchCache)
+	searchCache = make(map[string]*SearchCache)
+	cacheMutex.Unlock()
+
+	log.Printf("已触发Everything重新扫描: %s（%s），清除%d个搜索缓存，来源IP: %s", mode, method, cleared, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"rescan":  lastRescan,
+		"cleared": cleared,
+	})
+}
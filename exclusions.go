@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 排除规则
+//
+// 匹配排除规则的文件和文件夹不出现在搜索和浏览结果中，规则保存在exclusions.json，可通过 /api/exclusions 修改：
+//   builtIn      排除回收站（$RECYCLE.BIN）和System Volume Information，默认开启
+//   nodeModules  排除node_modules文件夹中的内容，默认关闭
+//   patterns     自定义规则，"regex:"开头的按正则表达式匹配完整路径；其他按通配符匹配，
+//                包含\时匹配完整路径，否则匹配路径中的任一级名称（如*.tmp、.git）
+// 所有规则不区分大小写。
+
+const exclusionsFileName = "exclusions.json"
+
+var builtInExclusions = []string{"$recycle.bin", "system volume information"}
+
+type ExclusionSettings struct {
+	BuiltIn     bool     `json:"builtIn"`
+	NodeModules bool     `json:"nodeModules"`
+	Patterns    []string `json:"patterns"`
+}
+
+// 编译后的排除规则
+type exclusionMatcher struct {
+	names []string         // 匹配任一级名称的通配符（小写）
+	paths []string         // 匹配完整路径的通配符（小写）
+	regex []*regexp.Regexp // 匹配完整路径的正则表达式
+}
+
+var (
+	exclusions       = ExclusionSettings{BuiltIn: true, Patterns: []string{}}
+	exclusionRules   *exclusionMatcher
+	exclusionsMutex  sync.RWMutex
+	exclusionsLoaded sync.Once
+)
+
+// 首次使用时从数据目录加载排除规则
+func ensureExclusionsLoaded() {
+	exclusionsLoaded.Do(func() {
+		exclusionsMutex.Lock()
+		defer exclusionsMutex.Unlock()
+		if err := loadJSONFile(exclusionsFileName, &exclusions); err != nil {
+			log.Printf("加载排除规则失败: %v", err)
+		}
+		rules, err := compileExclusions(exclusions)
+		if err != nil {
+			log.Printf("排除规则无效，只使用内置规则: %v", err)
+			rules, _ = compileExclusions(ExclusionSettings{BuiltIn: exclusions.BuiltIn, NodeModules: exclusions.NodeModules})
+		}
+		exclusionRules = rules
+	})
+}
+
+// 检查并编译排除规则
+func compileExclusions(settings ExclusionSettings) (*exclusionMatcher, error) {
+	m := &exclusionMatcher{}
+	if settings.BuiltIn {
+		m.names = append(m.names, builtInExclusions...)
+	}
+	if settings.NodeModules {
+		m.names = append(m.names, "node_modules")
+	}
+	for _, pattern := range settings.Patterns {
+		pattern = strings.TrimSpace(pattern)
+		switch {
+		case pattern == "":
+			return nil, fmt.Errorf("排除规则不能为空")
+		case strings.HasPrefix(strings.ToLower(pattern), "regex:"):
+			re, err := regexp.Compile("(?i)" + pattern[6:])
+			if err != nil {
+				return nil, fmt.Errorf("正则表达式无效: %s: %v", pattern, err)
+			}
+			m.regex = append(m.regex, re)
+		default:
+			lower := strings.ToLower(strings.ReplaceAll(pattern, "/", "\\"))
+			if _, err := filepath.Match(lower, ""); err != nil {
+				return nil, fmt.Errorf("通配符无效: %s", pattern)
+			}
+			if strings.Contains(lower, "\\") {
+				m.paths = append(m.paths, lower)
+			} else {
+				m.names = append(m.names, lower)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *exclusionMatcher) empty() bool {
+	return len(m.names) == 0 && len(m.paths) == 0 && len(m.regex) == 0
+}
+
+// 路径是否被排除
+func (m *exclusionMatcher) match(path string) bool {
+	lower := strings.ToLower(path)
+	// 完整路径的规则也匹配上级文件夹，D:\Backup\*排除D:\Backup中的所有内容
+	for _, pattern := range m.paths {
+		for p := lower; ; p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				return true
+			}
+			if filepath.Dir(p) == p {
+				break
+			}
+		}
+	}
+	if len(m.names) > 0 {
+		for _, part := range strings.Split(strings.TrimRight(lower, "\\"), "\\") {
+			if part == "" {
+				continue
+			}
+			for _, pattern := range m.names {
+				if ok, _ := filepath.Match(pattern, part); ok {
+					return true
+				}
+			}
+		}
+	}
+	for _, re := range m.regex {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func currentExclusionRules() *exclusionMatcher {
+	ensureExclusionsLoaded()
+	exclusionsMutex.RLock()
+	defer exclusionsMutex.RUnlock()
+	return exclusionRules
+}
+
+// 路径是否被排除规则排除
+func isExcludedPath(path string) bool {
+	return currentExclusionRules().match(path)
+}
+
+// 去掉被排除的路径，没有排除规则时原样返回
+func filterExcludedPaths(paths []string) []string {
+	rules := currentExclusionRules()
+	if rules.empty() {
+		return paths
+	}
+	kept := paths[:0:0]
+	for _, path := range paths {
+		if !rules.match(path) {
+			kept = append(kept, path)
+		}
+	}
+	if excluded := len(paths) - len(kept); excluded > 0 {
+		log.Printf("排除规则过滤了%d个路径", excluded)
+	}
+	return kept
+}
+
+func getExclusions() ExclusionSettings {
+	ensureExclusionsLoaded()
+	exclusionsMutex.RLock()
+	defer exclusionsMutex.RUnlock()
+	settings := exclusions
+	settings.Patterns = append([]string{}, exclusions.Patterns...)
+	return settings
+}
+
+// 保存排除规则，规则变化后缓存的搜索结果不再可用
+func saveExclusions(settings ExclusionSettings) error {
+	ensureExclusionsLoaded()
+	if settings.Patterns == nil {
+		settings.Patterns = []string{}
+	}
+	rules, err := compileExclusions(settings)
+	if err != nil {
+		return err
+	}
+
+	exclusionsMutex.Lock()
+	exclusions = settings
+	exclusionRules = rules
+	err = saveJSONFile(exclusionsFileName, exclusions)
+	exclusionsMutex.Unlock()
+
+	cacheMutex.Lock()
+	searchCache = make(map[string]*SearchCache)
+	cacheMutex.Unlock()
+	return err
+}
+
+// 排除规则API处理器
+// GET 获取；PUT 整体替换；PATCH 只修改提交的字段
+func apiExclusionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"exclusions":      getExclusions(),
+			"builtInPatterns": builtInExclusions,
+		})
+
+	case http.MethodPut, http.MethodPatch:
+		settings := ExclusionSettings{BuiltIn: true}
+		if r.Method == http.MethodPatch {
+			settings = getExclusions()
+		}
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := compileExclusions(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := saveExclusions(settings); err != nil {
+			log.Printf("保存排除规则失败: %v", err)
+			http.Error(w, "保存排除规则失败", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("排除规则已更新: 内置=%v, node_modules=%v, 自定义%d条，来源IP: %s",
+			settings.BuiltIn, settings.NodeModules, len(settings.Patterns), r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{"exclusions": getExclusions()})
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
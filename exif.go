@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 照片的拍摄时间和GPS坐标（EXIF）
+//
+// 从JPEG的APP1段或TIFF文件头中读取DateTimeOriginal，没有时依次使用DateTimeDigitized和DateTime；
+// GPS IFD中有经纬度时一并读取。只读取文件开头的一段，不依赖ffmpeg或其他工具。读取结果按路径、大小和
+// 修改时间缓存在 data\exifdates.json，文件变化后重新读取；没有EXIF的文件也记录下来，不再重复读取。
+// 时间线、地图等功能只使用已缓存的结果，未缓存的文件放入后台队列逐个读取，下次请求时生效。
+
+const (
+	exifFileName     = "exifdates.json"
+	exifReadLimit    = 256 << 10 // 最多读取的字节数，EXIF通常在前64KB内
+	exifQueueSize    = 4096
+	exifSaveInterval = 500 // 每读取多少个文件保存一次缓存
+	exifCacheVersion = 2   // 读取的内容增加时递增，旧版本的缓存条目重新读取
+)
+
+var errNoEXIF = errors.New("没有EXIF拍摄时间")
+
+// 缓存的EXIF信息，Taken为空表示文件没有EXIF时间
+type exifDateEntry struct {
+	Version  int     `json:"v,omitempty"`
+	Size     int64   `json:"size"`
+	Modified int64   `json:"modified"` // Unix秒
+	Taken    string  `json:"taken,omitempty"`
+	GPS      bool    `json:"gps,omitempty"`
+	Lat      float64 `json:"lat,omitempty"`
+	Lon      float64 `json:"lon,omitempty"`
+}
+
+// 从文件读取的EXIF信息
+type exifInfo struct {
+	Taken    time.Time // 没有拍摄时间时为零值
+	HasGPS   bool
+	Lat, Lon float64 // 十进制度数，南纬和西经为负
+}
+
+var (
+	exifDates     map[string]exifDateEntry // 小写路径 → 拍摄时间
+	exifMutex     sync.Mutex
+	exifLoadOnce  sync.Once
+	exifQueue     = make(chan everythingEntry, exifQueueSize)
+	exifPending   = make(map[string]bool)
+	exifWorkerRun sync.Once
+)
+
+func loadEXIFDates() {
+	exifLoadOnce.Do(func() {
+		exifDates = make(map[string]exifDateEntry)
+		if err := loadJSONFile(exifFileName, &exifDates); err != nil {
+			log.Printf("读取EXIF缓存失败: %v", err)
+		}
+	})
+}
+
+// 已缓存的EXIF信息。cached表示文件已读取过（可能没有EXIF）
+func cachedEXIF(e everythingEntry) (info exifInfo, cached bool) {
+	loadEXIFDates()
+	exifMutex.Lock()
+	entry, ok := exifDates[strings.ToLower(e.Path)]
+	exifMutex.Unlock()
+	if !ok || entry.Version != exifCacheVersion || entry.Size != e.Size || entry.Modified != e.Modified.Unix() {
+		return exifInfo{}, false
+	}
+	info = exifInfo{HasGPS: entry.GPS, Lat: entry.Lat, Lon: entry.Lon}
+	if entry.Taken != "" {
+		info.Taken, _ = time.ParseInLocation("2006-01-02 15:04:05", entry.Taken, time.Local)
+	}
+	return info, true
+}
+
+// 已缓存的拍摄时间。cached表示文件已读取过（可能没有EXIF时间）
+func cachedCaptureDate(e everythingEntry) (taken time.Time, cached bool) {
+	info, cached := cachedEXIF(e)
+	return info.Taken, cached
+}
+
+// 把未缓存的文件加入后台读取队列，队列已满时返回false
+func queueEXIFScan(e everythingEntry) bool {
+	exifWorkerRun.Do(func() { go exifWorker() })
+
+	key := strings.ToLower(e.Path)
+	exifMutex.Lock()
+	defer exifMutex.Unlock()
+	if exifPending[key] {
+		return true
+	}
+	select {
+	case exifQueue <- e:
+		exifPending[key] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// 待读取的文件数
+func pendingEXIFScans() int {
+	return len(exifQueue)
+}
+
+// 后台逐个读取队列中文件的拍摄时间，队列空闲或每读取一批后保存缓存
+func exifWorker() {
+	loadEXIFDates()
+	unsaved := 0
+	for {
+		var e everythingEntry
+		select {
+		case e = <-exifQueue:
+		default:
+			if unsaved > 0 {
+				saveEXIFDates()
+				unsaved = 0
+			}
+			e = <-exifQueue
+		}
+
+		entry := exifDateEntry{Version: exifCacheVersion, Size: e.Size, Modified: e.Modified.Unix()}
+		if info, err := readEXIF(e.Path); err == nil {
+			if !info.Taken.IsZero() {
+				entry.Taken = info.Taken.Format("2006-01-02 15:04:05")
+			}
+			entry.GPS, entry.Lat, entry.Lon = info.HasGPS, info.Lat, info.Lon
+		}
+
+		key := strings.ToLower(e.Path)
+		exifMutex.Lock()
+		exifDates[key] = entry
+		delete(exifPending, key)
+		exifMutex.Unlock()
+
+		if unsaved++; unsaved >= exifSaveInterval {
+			saveEXIFDates()
+			unsaved = 0
+		}
+	}
+}
+
+func saveEXIFDates() {
+	exifMutex.Lock()
+	defer exifMutex.Unlock()
+	if err := saveJSONFile(exifFileName, exifDates); err != nil {
+		log.Printf("保存EXIF缓存失败: %v", err)
+	}
+}
+
+// 读取文件的EXIF拍摄时间
+func readCaptureDate(path string) (time.Time, error) {
+	info, err := readEXIF(path)
+	if err == nil && info.Taken.IsZero() {
+		err = errNoEXIF
+	}
+	return info.Taken, err
+}
+
+// 读取文件的EXIF信息
+func readEXIF(path string) (exifInfo, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return exifInfo{}, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, exifReadLimit))
+	if err != nil {
+		return exifInfo{}, err
+	}
+	tiff := findEXIFBlock(data)
+	if tiff == nil {
+		return exifInfo{}, errNoEXIF
+	}
+	return parseEXIF(tiff)
+}
+
+// 找到TIFF结构的EXIF数据：JPEG中在APP1段的"Exif\0\0"之后，TIFF文件本身就是
+func findEXIFBlock(data []byte) []byte {
+	if len(data) >= 4 && (string(data[:4]) == "II*\x00" || string(data[:4]) == "MM\x00*") {
+		return data
+	}
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+		if marker == 0xD8 || marker >= 0xD0 && marker <= 0xD7 || marker == 0x01 {
+			i += 2
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return nil // 图像数据开始，后面不会再有EXIF
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		segment := data[i+4 : end]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return segment[6:]
+		}
+		i = end
+	}
+	return nil
+}
+
+// 各数据类型每个值的字节数：BYTE、ASCII、SHORT、LONG、RATIONAL、SBYTE、UNDEFINED、SSHORT、SLONG、SRATIONAL
+var exifTypeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8}
+
+// 从TIFF结构中读取拍摄时间和GPS坐标
+func parseEXIF(tiff []byte) (exifInfo, error) {
+	var info exifInfo
+	if len(tiff) < 8 {
+		return info, errNoEXIF
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return info, errNoEXIF
+	}
+
+	// IFD中的条目：标签 → 值的原始字节，超过4字节的值存放在偏移处
+	readIFD := func(offset uint32) map[uint16][]byte {
+		tags := make(map[uint16][]byte)
+		if uint64(offset)+2 > uint64(len(tiff)) {
+			return tags
+		}
+		count := int(order.Uint16(tiff[offset:]))
+		for i := 0; i < count; i++ {
+			p := int(offset) + 2 + i*12
+			if p+12 > len(tiff) {
+				break
+			}
+			tag := order.Uint16(tiff[p:])
+			size := uint64(exifTypeSizes[order.Uint16(tiff[p+2:])]) * uint64(order.Uint32(tiff[p+4:]))
+			value := tiff[p+8 : p+12]
+			if size > 4 {
+				start := uint64(order.Uint32(value))
+				if start+size > uint64(len(tiff)) {
+					continue
+				}
+				value = tiff[start : start+size]
+			}
+			tags[tag] = value
+		}
+		return tags
+	}
+	pointer := func(tags map[uint16][]byte, tag uint16) (map[uint16][]byte, bool) {
+		value, ok := tags[tag]
+		if !ok || len(value) < 4 {
+			return nil, false
+		}
+		return readIFD(order.Uint32(value)), true
+	}
+
+	ifd0 := readIFD(order.Uint32(tiff[4:]))
+	exif, _ := pointer(ifd0, 0x8769)
+	for _, candidate := range [][]byte{exif[0x9003], exif[0x9004], ifd0[0x0132]} {
+		s := strings.TrimRight(string(candidate), "\x00 ")
+		if taken, err := time.ParseInLocation("2006:01:02 15:04:05", s, time.Local); err == nil && taken.Year() > 1900 {
+			info.Taken = taken
+			break
+		}
+	}
+
+	// GPSLatitude/GPSLongitude为度、分、秒三个RATIONAL，Ref为N/S、E/W
+	if gps, ok := pointer(ifd0, 0x8825); ok {
+		degrees := func(value []byte, ref []byte, negative byte) (float64, bool) {
+			if len(value) < 24 {
+				return 0, false
+			}
+			var parts [3]float64
+			for i := range parts {
+				num, den := order.Uint32(value[i*8:]), order.Uint32(value[i*8+4:])
+				if den == 0 {
+					return 0, false
+				}
+				parts[i] = float64(num) / float64(den)
+			}
+			d := parts[0] + parts[1]/60 + parts[2]/3600
+			if len(ref) > 0 && ref[0] == negative {
+				d = -d
+			}
+			return d, true
+		}
+		lat, latOK := degrees(gps[2], gps[1], 'S')
+		lon, lonOK := degrees(gps[4], gps[3], 'W')
+		// 部分相机没有定位时写入0,0
+		if latOK && lonOK && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 && (lat != 0 || lon != 0) {
+			info.HasGPS, info.Lat, info.Lon = true, lat, lon
+		}
+	}
+
+	if info.Taken.IsZero() && !info.HasGPS {
+		return info, errNoEXIF
+	}
+	return info, nil
+}
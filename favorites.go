@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 收藏的文件或文件夹
+type Favorite struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	IsDir bool   `json:"isDir"`
+	Type  string `json:"type"`
+	Added string `json:"added"`
+}
+
+const favoritesFileName = "favorites.json"
+
+var (
+	favorites       = make(map[string][]Favorite) // 会话ID → 收藏列表
+	favoritesMutex  sync.RWMutex
+	favoritesLoaded sync.Once
+)
+
+// 首次使用时从数据目录加载收藏
+func ensureFavoritesLoaded() {
+	favoritesLoaded.Do(func() {
+		favoritesMutex.Lock()
+		defer favoritesMutex.Unlock()
+		if err := loadJSONFile(favoritesFileName, &favorites); err != nil {
+			log.Printf("加载收藏失败: %v", err)
+		}
+		if favorites == nil {
+			favorites = make(map[string][]Favorite)
+		}
+	})
+}
+
+// 获取会话的收藏列表副本
+func getFavorites(sessionID string) []Favorite {
+	ensureFavoritesLoaded()
+
+	favoritesMutex.RLock()
+	defer favoritesMutex.RUnlock()
+
+	list := make([]Favorite, len(favorites[sessionID]))
+	copy(list, favorites[sessionID])
+	return list
+}
+
+// 修改会话的收藏列表并保存
+func updateFavorites(sessionID string, update func([]Favorite) []Favorite) ([]Favorite, error) {
+	ensureFavoritesLoaded()
+
+	favoritesMutex.Lock()
+	defer favoritesMutex.Unlock()
+
+	list := update(favorites[sessionID])
+	if len(list) == 0 {
+		delete(favorites, sessionID)
+	} else {
+		favorites[sessionID] = list
+	}
+
+	result := make([]Favorite, len(list))
+	copy(result, list)
+	return result, saveJSONFile(favoritesFileName, favorites)
+}
+
+// 根据路径创建收藏条目
+func newFavorite(path, name string) (Favorite, error) {
+	path = resolveClientPath(path)
+	info, err := statPath(path)
+	if err != nil {
+		return Favorite{}, err
+	}
+
+	if name == "" {
+		name = filepath.Base(path)
+	}
+
+	return Favorite{
+		ID:    newRandomID(8),
+		Name:  name,
+		Path:  clientPath(path),
+		IsDir: info.IsDir(),
+		Type:  getResultType(path, info.IsDir()),
+		Added: time.Now().Format("2006-01-02 15:04:05"),
+	}, nil
+}
+
+func writeFavoritesResponse(w http.ResponseWriter, list []Favorite) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"favorites": list,
+		"count":     len(list),
+	})
+}
+
+// 收藏API处理器
+// GET 列出收藏；POST 添加；PUT 重命名；DELETE 删除
+func apiFavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := getSessionID(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeFavoritesResponse(w, getFavorites(sessionID))
+
+	case http.MethodPost:
+		var req struct {
+			Path string `json:"path"`
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+			http.Error(w, "请求格式错误，需要path参数", http.StatusBadRequest)
+			return
+		}
+
+		fav, err := newFavorite(req.Path, req.Name)
+		if err != nil {
+			log.Printf("添加收藏失败: %s, 错误: %v", req.Path, err)
+			http.Error(w, "无法访问路径: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		list, err := updateFavorites(sessionID, func(list []Favorite) []Favorite {
+			for _, existing := range list {
+				if existing.Path == fav.Path {
+					return list // 已收藏
+				}
+			}
+			return append(list, fav)
+		})
+		if err != nil {
+			log.Printf("保存收藏失败: %v", err)
+			http.Error(w, "保存收藏失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("添加收藏: %s, 来源IP: %s", fav.Path, r.RemoteAddr)
+		writeFavoritesResponse(w, list)
+
+	case http.MethodPut:
+		var req struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" || req.Name == "" {
+			http.Error(w, "请求格式错误，需要id和name参数", http.StatusBadRequest)
+			return
+		}
+
+		found := false
+		list, err := updateFavorites(sessionID, func(list []Favorite) []Favorite {
+			for i := range list {
+				if list[i].ID == req.ID {
+					list[i].Name = req.Name
+					found = true
+				}
+			}
+			return list
+		})
+		if err != nil {
+			http.Error(w, "保存收藏失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "收藏不存在", http.StatusNotFound)
+			return
+		}
+
+		writeFavoritesResponse(w, list)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id参数不能为空", http.StatusBadRequest)
+			return
+		}
+
+		list, err := updateFavorites(sessionID, func(list []Favorite) []Favorite {
+			kept := list[:0]
+			for _, fav := range list {
+				if fav.ID != id {
+					kept = append(kept, fav)
+				}
+			}
+			return kept
+		})
+		if err != nil {
+			http.Error(w, "保存收藏失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("删除收藏: %s, 来源IP: %s", id, r.RemoteAddr)
+		writeFavoritesResponse(w, list)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 导出收藏列表为JSON文件
+func apiFavoritesExportHandler(w http.ResponseWriter, r *http.Request) {
+	list := getFavorites(getSessionID(w, r))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", "favorites.json"))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"favorites": list,
+		"exported":  time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// 导入收藏列表，mode=replace时替换现有收藏，默认合并
+func apiFavoritesImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Favorites []Favorite `json:"favorites"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "导入文件格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	replace := r.URL.Query().Get("mode") == "replace"
+	imported := 0
+
+	list, err := updateFavorites(getSessionID(w, r), func(list []Favorite) []Favorite {
+		if replace {
+			list = nil
+		}
+
+		existing := make(map[string]bool)
+		for _, fav := range list {
+			existing[fav.Path] = true
+		}
+
+		for _, fav := range req.Favorites {
+			if fav.Path == "" || existing[fav.Path] {
+				continue
+			}
+			if fav.ID == "" {
+				fav.ID = newRandomID(8)
+			}
+			if fav.Name == "" {
+				fav.Name = filepath.Base(resolveClientPath(fav.Path))
+			}
+			existing[fav.Path] = true
+			list = append(list, fav)
+			imported++
+		}
+		return list
+	})
+	if err != nil {
+		http.Error(w, "保存收藏失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("导入收藏: %d条, 替换模式: %t, 来源IP: %s", imported, replace, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"favorites": list,
+		"count":     len(list),
+		"imported":  imported,
+	})
+}
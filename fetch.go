@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 分段下载子命令：everything-web-server.exe fetch -url http://服务器:8080 -c 8 -o 输出文件 "D:\大文件.iso"
+//
+// 向运行中的实例获取下载计划（/api/download-plan），用多个连接并行下载各段并写入输出文件的对应位置，
+// 每段中断后从已下载的位置续传，重试次数用尽才失败。-checksum sha256 时下载完成后与服务器的校验和比较。
+
+// 按分段写入输出文件的对应位置，并累计总进度
+type segmentWriter struct {
+	w        io.Writer
+	progress *int64
+}
+
+func (sw segmentWriter) Write(p []byte) (int, error) {
+	n, err := sw.w.Write(p)
+	atomic.AddInt64(sw.progress, int64(n))
+	return n, err
+}
+
+var errFetchFileChanged = errors.New("服务器上的文件已变化，请重新下载")
+
+func runFetch(args []string) int {
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	baseURL := fs.String("url", "http://127.0.0.1:8080", "服务器地址")
+	segments := fs.Int("c", defaultDownloadSegments, "并行连接数（分段数）")
+	output := fs.String("o", "", "输出文件，默认为原文件名")
+	checksum := fs.String("checksum", "", "下载完成后校验：sha256、sha1或md5")
+	retries := fs.Int("retries", 3, "每段失败后的重试次数")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "用法: fetch [-url 服务器地址] [-c 分段数] [-o 输出文件] [-checksum sha256] 文件路径")
+		return 2
+	}
+	if *checksum != "" && checksumAlgorithms[*checksum] == nil {
+		fmt.Fprintf(os.Stderr, "不支持的校验算法: %s\n", *checksum)
+		return 2
+	}
+
+	base := strings.TrimRight(*baseURL, "/")
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: *segments}}
+
+	plan, err := fetchDownloadPlan(client, base, fs.Arg(0), *segments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "获取下载计划失败: %v\n", err)
+		return 1
+	}
+	if *output == "" {
+		*output = plan.Name
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建输出文件失败: %v\n", err)
+		return 1
+	}
+	if err := file.Truncate(plan.Size); err != nil {
+		file.Close()
+		fmt.Fprintf(os.Stderr, "分配输出文件失败: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("下载: %s (%s), %d段 → %s\n", plan.Path, formatSize(plan.Size), len(plan.Segments), *output)
+
+	var progress int64
+	start := time.Now()
+	stopProgress := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				done := atomic.LoadInt64(&progress)
+				fmt.Printf("  %s / %s (%.1f%%), %s/s\n", formatSize(done), formatSize(plan.Size),
+					float64(done)*100/float64(max(plan.Size, 1)), formatSize(int64(float64(done)/time.Since(start).Seconds())))
+			case <-stopProgress:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(plan.Segments))
+	for i, seg := range plan.Segments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fetchSegment(client, base+seg.URL, file, seg, *retries, &progress)
+		}()
+	}
+	wg.Wait()
+	close(stopProgress)
+
+	closeErr := file.Close()
+	if err := errors.Join(append(errs, closeErr)...); err != nil {
+		fmt.Fprintf(os.Stderr, "下载失败:\n%v\n", err)
+		return 1
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("完成: %s, 用时%v, 平均%s/s\n", formatSize(plan.Size), elapsed.Round(time.Millisecond),
+		formatSize(int64(float64(plan.Size)/max(elapsed.Seconds(), 0.001))))
+
+	if *checksum != "" {
+		return verifyFetchedFile(client, base+plan.FileURL, *output, *checksum)
+	}
+	return 0
+}
+
+func fetchDownloadPlan(client *http.Client, base, path string, segments int) (*DownloadPlan, error) {
+	resp, err := client.Get(fmt.Sprintf("%s/api/download-plan?path=%s&segments=%d", base, url.QueryEscape(path), segments))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("状态%d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var plan DownloadPlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// 下载一段，中断后用Range从已下载的位置继续
+func fetchSegment(client *http.Client, target string, file *os.File, seg DownloadSegment, retries int, progress *int64) error {
+	var done int64
+	for attempt := 0; ; attempt++ {
+		err := func() error {
+			req, err := http.NewRequest(http.MethodGet, target, nil)
+			if err != nil {
+				return err
+			}
+			if done > 0 {
+				req.Header.Set("Range", fmt.Sprintf("bytes=%d-", done))
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				atomic.AddInt64(progress, -done) // 服务器从头发送，重新计算
+				done = 0
+			case http.StatusPartialContent:
+			case http.StatusConflict:
+				return errFetchFileChanged
+			default:
+				return fmt.Errorf("状态%d", resp.StatusCode)
+			}
+
+			w := segmentWriter{w: io.NewOffsetWriter(file, seg.Start+done), progress: progress}
+			n, err := io.Copy(w, io.LimitReader(resp.Body, seg.Length-done))
+			done += n
+			return err
+		}()
+
+		if done == seg.Length {
+			return nil
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		if errors.Is(err, errFetchFileChanged) || attempt >= retries {
+			return fmt.Errorf("第%d段（%s）: %w", seg.Index, seg.Range, err)
+		}
+		fmt.Printf("  第%d段中断（%v），%d秒后从%s处续传\n", seg.Index, err, attempt+1, formatSize(done))
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+}
+
+// 与服务器计算的校验和比较
+func verifyFetchedFile(client *http.Client, fileURL, output, algo string) int {
+	resp, err := client.Head(fileURL + "&checksum=" + algo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "获取校验和失败: %v\n", err)
+		return 1
+	}
+	resp.Body.Close()
+	remote, ok := strings.CutPrefix(resp.Header.Get("X-Checksum"), algo+"=")
+	if !ok {
+		fmt.Printf("服务器尚未计算出校验和（%s），跳过校验\n", resp.Header.Get("X-Checksum-Status"))
+		return 0
+	}
+
+	local, err := computeChecksum(context.Background(), output, checksumAlgorithms[algo]())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "计算校验和失败: %v\n", err)
+		return 1
+	}
+	if local != remote {
+		fmt.Fprintf(os.Stderr, "校验失败: 本地%s=%s，服务器%s\n", algo, local, remote)
+		return 1
+	}
+	fmt.Printf("校验通过: %s=%s\n", algo, local)
+	return 0
+}
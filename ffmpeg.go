@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ffmpeg可执行文件和转码参数配置
+//
+// ffmpegPath/ffprobePath为空时从PATH查找。转码参数按名称组织为profile，
+// /transcode/ 通过 ?profile= 选择，未指定时使用defaultProfile（默认"default"）。
+// config.json中的同名profile覆盖内置profile。
+
+type TranscodeProfile struct {
+	VideoCodec   string   `json:"videoCodec"`             // 默认libx264
+	AudioCodec   string   `json:"audioCodec"`             // 默认aac
+	Preset       string   `json:"preset,omitempty"`       // 编码速度预设，如fast、veryfast
+	CRF          int      `json:"crf,omitempty"`          // 视频质量（越小质量越好），0表示不设置
+	MaxRate      string   `json:"maxRate,omitempty"`      // 最大码率，如2M
+	BufSize      string   `json:"bufSize,omitempty"`      // 码率控制缓冲区，如4M
+	Height       int      `json:"height,omitempty"`       // 缩放到指定高度（宽度按比例），0表示不缩放
+	AudioBitrate string   `json:"audioBitrate,omitempty"` // 音频码率，如128k
+	ExtraArgs    []string `json:"extraArgs,omitempty"`    // 追加在编码参数之后的其他参数
+}
+
+const defaultProfileName = "default"
+
+// 内置profile，default与原来固定的转码参数一致
+var builtinProfiles = map[string]TranscodeProfile{
+	"default": {VideoCodec: "libx264", AudioCodec: "aac", Preset: "fast", CRF: 23, MaxRate: "2M", BufSize: "4M"},
+	"1080p":   {VideoCodec: "libx264", AudioCodec: "aac", Preset: "fast", CRF: 23, MaxRate: "5M", BufSize: "10M", Height: 1080, AudioBitrate: "192k"},
+	"720p":    {VideoCodec: "libx264", AudioCodec: "aac", Preset: "fast", CRF: 23, MaxRate: "2500k", BufSize: "5M", Height: 720, AudioBitrate: "128k"},
+	"480p":    {VideoCodec: "libx264", AudioCodec: "aac", Preset: "veryfast", CRF: 26, MaxRate: "1M", BufSize: "2M", Height: 480, AudioBitrate: "96k"},
+}
+
+// ffmpeg可执行文件
+func ffmpegBinary() string {
+	if serverConfig.FFmpegPath != "" {
+		return serverConfig.FFmpegPath
+	}
+	return "ffmpeg"
+}
+
+// ffprobe可执行文件
+func ffprobeBinary() string {
+	if serverConfig.FFprobePath != "" {
+		return serverConfig.FFprobePath
+	}
+	return "ffprobe"
+}
+
+// 用ffprobe获取视频时长（秒）
+func probeDuration(ctx context.Context, path string) (float64, error) {
+	cmd := exec.CommandContext(ctx, ffprobeBinary(), "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe获取时长失败: %v", err)
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("无法获取视频时长: %s", strings.TrimSpace(string(out)))
+	}
+	return duration, nil
+}
+
+// 所有可用的profile，配置中的同名profile覆盖内置的
+func transcodeProfiles() map[string]TranscodeProfile {
+	profiles := make(map[string]TranscodeProfile, len(builtinProfiles)+len(serverConfig.TranscodeProfiles))
+	for name, p := range builtinProfiles {
+		profiles[name] = p
+	}
+	for name, p := range serverConfig.TranscodeProfiles {
+		profiles[name] = p
+	}
+	return profiles
+}
+
+// 按名称获取profile，名称为空时使用默认profile
+func getTranscodeProfile(name string) (string, TranscodeProfile, error) {
+	if name == "" {
+		name = serverConfig.DefaultProfile
+	}
+	if name == "" {
+		name = defaultProfileName
+	}
+	p, ok := transcodeProfiles()[name]
+	if !ok {
+		return name, TranscodeProfile{}, fmt.Errorf("转码配置不存在: %s", name)
+	}
+	return name, p, nil
+}
+
+// 生成编码参数（不含输入和输出）
+func (p TranscodeProfile) args() []string {
+	videoCodec, audioCodec := p.VideoCodec, p.AudioCodec
+	if videoCodec == "" {
+		videoCodec = "libx264"
+	}
+	if audioCodec == "" {
+		audioCodec = "aac"
+	}
+
+	args := []string{"-c:v", videoCodec, "-c:a", audioCodec}
+	if p.Preset != "" {
+		args = append(args, "-preset", p.Preset)
+	}
+	if p.CRF > 0 {
+		args = append(args, "-crf", strconv.Itoa(p.CRF))
+	}
+	if p.MaxRate != "" {
+		args = append(args, "-maxrate", p.MaxRate)
+	}
+	if p.BufSize != "" {
+		args = append(args, "-bufsize", p.BufSize)
+	}
+	if p.Height > 0 {
+		// 宽度取偶数，libx264要求宽高为偶数；不放大比目标小的视频
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", p.Height))
+	}
+	if p.AudioBitrate != "" {
+		args = append(args, "-b:a", p.AudioBitrate)
+	}
+	return append(args, p.ExtraArgs...)
+}
+
+// 转码配置API处理器，GET 列出可用的profile
+func apiTranscodeProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profiles := transcodeProfiles()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	defaultName, _, _ := getTranscodeProfile("")
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"profiles": profiles,
+		"names":    names,
+		"default":  defaultName,
+		"ffmpeg":   ffmpegBinary(),
+		"ffprobe":  ffprobeBinary(),
+	})
+}
+
+// 截取片段、生成动图等输出到临时文件的ffmpeg任务，同时执行的数量有限
+const maxFFmpegJobs = 2
+
+var ffmpegJobSlots = make(chan struct{}, maxFFmpegJobs)
+
+// 执行ffmpeg，输出到缓存目录中的临时文件，返回文件路径（调用方负责删除）
+// args中不含输出文件；等待空闲名额时请求取消则直接返回
+func runFFmpegJob(ctx context.Context, args []string, ext string) (string, error) {
+	select {
+	case ffmpegJobSlots <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-ffmpegJobSlots }()
+
+	output := filepath.Join(getCacheDir("jobs"), newRandomID(8)+ext)
+	cmd := exec.CommandContext(ctx, ffmpegBinary(), append(args, "-y", output)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(output)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", ffmpegError(err, out)
+	}
+	return output, nil
+}
+
+// ffmpeg失败时取输出的最后一行作为错误信息
+func ffmpegError(err error, out []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	err = fmt.Errorf("ffmpeg执行失败: %v, %s", err, strings.TrimSpace(lines[len(lines)-1]))
+	recordFFmpegFailure(err)
+	return err
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// Windows文件属性标志，其他系统上由文件信息推算（见fileattr_other.go）
+const (
+	fileAttributeReadOnly     = 0x00000001
+	fileAttributeHidden       = 0x00000002
+	fileAttributeSystem       = 0x00000004
+	fileAttributeDirectory    = 0x00000010
+	fileAttributeArchive      = 0x00000020
+	fileAttributeReparsePoint = 0x00000400
+)
+
+// 自带文件属性的FileInfo（如远程Everything的结果），不读取本机同名路径的属性
+type attributedFileInfo interface {
+	os.FileInfo
+	FileAttributes() uint32
+}
+
+// FILETIME（1601年起的100纳秒数）转换为时间
+func filetimeToTime(ft int64) time.Time {
+	const epochDiff = 116444736000000000 // 1601-01-01到1970-01-01的100纳秒数
+	return time.Unix(0, (ft-epochDiff)*100)
+}
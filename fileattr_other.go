@@ -0,0 +1,47 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 由文件信息推算Windows文件属性：以.开头的为隐藏文件，没有写权限的为只读，符号链接为重解析点
+func getFileAttributes(path string, info os.FileInfo) uint32 {
+	if fi, ok := info.(attributedFileInfo); ok {
+		return fi.FileAttributes()
+	}
+	if info == nil || info.Mode()&os.ModeSymlink == 0 {
+		if linkInfo, err := os.Lstat(path); err == nil {
+			info = linkInfo
+		} else if info == nil {
+			return 0
+		}
+	}
+
+	var attrs uint32
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		attrs |= fileAttributeHidden
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		attrs |= fileAttributeReadOnly
+	}
+	if info.IsDir() {
+		attrs |= fileAttributeDirectory
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		attrs |= fileAttributeReparsePoint
+	}
+	return attrs
+}
+
+// 符号链接视为Windows的符号链接重解析点，其他系统没有目录联接
+func getReparseTag(path string) uint32 {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return 0
+	}
+	return ioReparseTagSymlink
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// 获取Windows文件属性，优先使用FileInfo中已有的数据，否则调用GetFileAttributes
+func getFileAttributes(path string, info os.FileInfo) uint32 {
+	if fi, ok := info.(attributedFileInfo); ok {
+		return fi.FileAttributes()
+	}
+	if info != nil {
+		if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+			return data.FileAttributes
+		}
+	}
+
+	pathPtr, err := syscall.UTF16PtrFromString(extendedPath(path))
+	if err != nil {
+		return 0
+	}
+	attrs, err := syscall.GetFileAttributes(pathPtr)
+	if err != nil {
+		return 0
+	}
+	return attrs
+}
+
+// 获取重解析点标签，非重解析点返回0
+func getReparseTag(path string) uint32 {
+	pathPtr, err := syscall.UTF16PtrFromString(extendedPath(path))
+	if err != nil {
+		return 0
+	}
+
+	var data syscall.Win32finddata
+	handle, err := syscall.FindFirstFile(pathPtr, &data)
+	if err != nil {
+		return 0
+	}
+	syscall.FindClose(handle)
+
+	if data.FileAttributes&syscall.FILE_ATTRIBUTE_REPARSE_POINT == 0 {
+		return 0
+	}
+	return data.Reserved0
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 用户自定义筛选
+//
+// 与收藏一样按会话保存在 data\filters.json，字段与智能筛选相同（查询模板、参数、排序、视图）：
+//   GET    /api/filters                 列出
+//   POST   /api/filters                 新建，id由服务器生成
+//   PUT    /api/filters                 按id修改
+//   DELETE /api/filters?id=xxx          删除
+//   GET    /api/filters/export          导出为JSON文件
+//   POST   /api/filters/import          导入，mode=replace时替换现有筛选，默认按名称合并
+// 首页的筛选按钮中显示在内置筛选之后，id以"user-"开头，不会与内置和配置的筛选冲突。
+
+const (
+	filtersFileName    = "filters.json"
+	maxUserFilters     = 100
+	maxFilterNameLen   = 100
+	maxFilterQueryLen  = 2000
+	userFilterIDPrefix = "user-"
+)
+
+var errTooManyFilters = fmt.Errorf("自定义筛选最多%d个", maxUserFilters)
+
+var (
+	userFilters       = make(map[string][]SmartFilter) // 会话ID → 自定义筛选
+	userFiltersMutex  sync.RWMutex
+	userFiltersLoaded sync.Once
+)
+
+// 首次使用时从数据目录加载自定义筛选
+func ensureUserFiltersLoaded() {
+	userFiltersLoaded.Do(func() {
+		userFiltersMutex.Lock()
+		defer userFiltersMutex.Unlock()
+		if err := loadJSONFile(filtersFileName, &userFilters); err != nil {
+			log.Printf("加载自定义筛选失败: %v", err)
+		}
+		if userFilters == nil {
+			userFilters = make(map[string][]SmartFilter)
+		}
+	})
+}
+
+// 获取会话的自定义筛选副本
+func getUserFilters(sessionID string) []SmartFilter {
+	ensureUserFiltersLoaded()
+
+	userFiltersMutex.RLock()
+	defer userFiltersMutex.RUnlock()
+
+	list := make([]SmartFilter, len(userFilters[sessionID]))
+	copy(list, userFilters[sessionID])
+	for i := range list {
+		list[i].User = true
+	}
+	return list
+}
+
+// 修改会话的自定义筛选并保存
+func updateUserFilters(sessionID string, update func([]SmartFilter) ([]SmartFilter, error)) ([]SmartFilter, error) {
+	ensureUserFiltersLoaded()
+
+	userFiltersMutex.Lock()
+	defer userFiltersMutex.Unlock()
+
+	// 在副本上修改，超出数量限制时不影响已保存的筛选
+	list, err := update(append([]SmartFilter(nil), userFilters[sessionID]...))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) > maxUserFilters {
+		return nil, errTooManyFilters
+	}
+	if len(list) == 0 {
+		delete(userFilters, sessionID)
+	} else {
+		userFilters[sessionID] = list
+	}
+
+	result := make([]SmartFilter, len(list))
+	copy(result, list)
+	for i := range result {
+		result[i].User = true
+	}
+	return result, saveJSONFile(filtersFileName, userFilters)
+}
+
+// 检查并规范化用户提交的筛选
+func normalizeUserFilter(f SmartFilter) (SmartFilter, error) {
+	f.Name = strings.TrimSpace(f.Name)
+	f.Template = strings.TrimSpace(f.Template)
+	if f.Name == "" || f.Template == "" {
+		return f, fmt.Errorf("name和template不能为空")
+	}
+	if len(f.Name) > maxFilterNameLen || len(f.Template) > maxFilterQueryLen {
+		return f, fmt.Errorf("名称或查询过长")
+	}
+	for name, v := range f.Params {
+		if v <= 0 || v > maxSmartFilterParam {
+			return f, fmt.Errorf("%s参数应为1-%d之间的整数", name, maxSmartFilterParam)
+		}
+	}
+	switch f.Sort {
+	case "", "name", "size", "date", "type", "rating", "natural":
+	default:
+		return f, fmt.Errorf("不支持的排序: %s", f.Sort)
+	}
+	switch f.Order {
+	case "", "asc", "desc":
+	default:
+		return f, fmt.Errorf("不支持的排序方向: %s", f.Order)
+	}
+	switch f.ViewMode {
+	case "", "list", "compact":
+	default:
+		return f, fmt.Errorf("不支持的视图: %s", f.ViewMode)
+	}
+	f.Hidden = false
+	f.User = false // 只在返回时填写，不保存
+	f.Query = ""
+	return f, nil
+}
+
+func filtersErrorStatus(err error) int {
+	if errors.Is(err, errTooManyFilters) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeUserFiltersResponse(w http.ResponseWriter, list []SmartFilter) {
+	for i := range list {
+		list[i].Query = expandSmartFilter(list[i], nil)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"filters": list,
+		"count":   len(list),
+	})
+}
+
+// 自定义筛选API处理器
+// GET 列出；POST 新建；PUT 修改；DELETE 删除
+func apiFiltersHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := getSessionID(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeUserFiltersResponse(w, getUserFilters(sessionID))
+
+	case http.MethodPost, http.MethodPut:
+		var req SmartFilter
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter, err := normalizeUserFilter(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		found := false
+		if r.Method == http.MethodPost {
+			filter.ID = userFilterIDPrefix + newRandomID(8)
+		} else if filter.ID == "" {
+			http.Error(w, "id参数不能为空", http.StatusBadRequest)
+			return
+		}
+
+		list, err := updateUserFilters(sessionID, func(list []SmartFilter) ([]SmartFilter, error) {
+			if r.Method == http.MethodPost {
+				found = true
+				return append(list, filter), nil
+			}
+			for i := range list {
+				if list[i].ID == filter.ID {
+					list[i] = filter
+					found = true
+				}
+			}
+			return list, nil
+		})
+		if err != nil {
+			log.Printf("保存自定义筛选失败: %v", err)
+			http.Error(w, "保存自定义筛选失败: "+err.Error(), filtersErrorStatus(err))
+			return
+		}
+		if !found {
+			http.Error(w, "筛选不存在", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("保存自定义筛选: %s (%s), 来源IP: %s", filter.Name, filter.Template, r.RemoteAddr)
+		writeUserFiltersResponse(w, list)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id参数不能为空", http.StatusBadRequest)
+			return
+		}
+
+		list, err := updateUserFilters(sessionID, func(list []SmartFilter) ([]SmartFilter, error) {
+			kept := list[:0]
+			for _, f := range list {
+				if f.ID != id {
+					kept = append(kept, f)
+				}
+			}
+			return kept, nil
+		})
+		if err != nil {
+			http.Error(w, "保存自定义筛选失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("删除自定义筛选: %s, 来源IP: %s", id, r.RemoteAddr)
+		writeUserFiltersResponse(w, list)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 导出自定义筛选为JSON文件，可以导入到其他会话或其他服务器
+func apiFiltersExportHandler(w http.ResponseWriter, r *http.Request) {
+	list := getUserFilters(getSessionID(w, r))
+	for i := range list {
+		list[i].User = false
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", "filters.json"))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"filters":  list,
+		"exported": time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// 导入自定义筛选，mode=replace时替换现有筛选，默认合并（同名的覆盖）
+func apiFiltersImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Filters []SmartFilter `json:"filters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "导入文件格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imported := make([]SmartFilter, 0, len(req.Filters))
+	for _, f := range req.Filters {
+		filter, err := normalizeUserFilter(f)
+		if err != nil {
+			http.Error(w, "导入的筛选无效: "+f.Name+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		imported = append(imported, filter)
+	}
+
+	replace := r.URL.Query().Get("mode") == "replace"
+
+	list, err := updateUserFilters(getSessionID(w, r), func(list []SmartFilter) ([]SmartFilter, error) {
+		if replace {
+			list = nil
+		}
+		byName := make(map[string]int)
+		for i, f := range list {
+			byName[f.Name] = i
+		}
+		for _, f := range imported {
+			if i, ok := byName[f.Name]; ok {
+				f.ID = list[i].ID
+				list[i] = f
+				continue
+			}
+			f.ID = userFilterIDPrefix + newRandomID(8)
+			byName[f.Name] = len(list)
+			list = append(list, f)
+		}
+		return list, nil
+	})
+	if err != nil {
+		http.Error(w, "导入自定义筛选失败: "+err.Error(), filtersErrorStatus(err))
+		return
+	}
+
+	log.Printf("导入自定义筛选: %d个, 替换模式: %t, 来源IP: %s", len(imported), replace, r.RemoteAddr)
+
+	for i := range list {
+		list[i].Query = expandSmartFilter(list[i], nil)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"filters":  list,
+		"count":    len(list),
+		"imported": len(imported),
+	})
+}
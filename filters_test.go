@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserFilters(t *testing.T) {
+	cookie := &http.Cookie{Name: sessionCookieName, Value: strings.Repeat("f", 32)}
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		switch {
+		case strings.HasPrefix(target, "/api/filters/export"):
+			apiFiltersExportHandler(rec, req)
+		case strings.HasPrefix(target, "/api/filters/import"):
+			apiFiltersImportHandler(rec, req)
+		case strings.HasPrefix(target, "/api/smart-filters"):
+			apiSmartFiltersHandler(rec, req)
+		default:
+			apiFiltersHandler(rec, req)
+		}
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) []SmartFilter {
+		t.Helper()
+		var resp struct{ Filters []SmartFilter }
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v (status %d)", err, rec.Code)
+		}
+		return resp.Filters
+	}
+	defer do(http.MethodPost, "/api/filters/import?mode=replace", `{"filters": []}`)
+
+	rec := do(http.MethodPost, "/api/filters", `{"name": "大照片", "template": "ext:jpg size:>{mb}mb", "params": {"mb": 5}, "sort": "size", "order": "desc", "viewMode": "compact"}`)
+	list := decode(rec)
+	if len(list) != 1 || !strings.HasPrefix(list[0].ID, userFilterIDPrefix) || list[0].Query != "ext:jpg size:>5mb" || !list[0].User {
+		t.Fatalf("after POST: %+v", list)
+	}
+	id := list[0].ID
+
+	for _, body := range []string{`{"name": "x"}`, `{"name": "x", "template": "a", "viewMode": "grid"}`, `{"name": "x", "template": "a", "params": {"n": 0}}`} {
+		if rec := do(http.MethodPost, "/api/filters", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: status = %d, want 400", body, rec.Code)
+		}
+	}
+
+	// 修改后出现在智能筛选列表末尾，按id可以单独展开
+	do(http.MethodPut, "/api/filters", `{"id": "`+id+`", "name": "大照片", "template": "ext:jpg size:>{mb}mb", "params": {"mb": 8}}`)
+	list = decode(do(http.MethodGet, "/api/smart-filters", ""))
+	if last := list[len(list)-1]; last.ID != id || !last.User || last.Query != "ext:jpg size:>8mb" || last.ViewMode != "" {
+		t.Errorf("last smart filter = %+v", last)
+	}
+	var one SmartFilter
+	json.NewDecoder(do(http.MethodGet, "/api/smart-filters?id="+id+"&mb=20", "").Body).Decode(&one)
+	if one.Query != "ext:jpg size:>20mb" {
+		t.Errorf("expanded user filter = %q", one.Query)
+	}
+	if rec := do(http.MethodPut, "/api/filters", `{"id": "user-missing", "name": "a", "template": "b"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("PUT unknown id: status = %d, want 404", rec.Code)
+	}
+
+	// 导出后再导入：同名的覆盖，新名称追加
+	exported := do(http.MethodGet, "/api/filters/export", "").Body.String()
+	if strings.Contains(exported, `"user"`) {
+		t.Errorf("export contains user flag: %s", exported)
+	}
+	list = decode(do(http.MethodPost, "/api/filters/import", strings.Replace(exported, `]`, `, {"name": "视频", "template": "ext:mp4"}]`, 1)))
+	if len(list) != 2 || list[0].ID != id || list[1].Name != "视频" {
+		t.Errorf("after import: %+v", list)
+	}
+
+	list = decode(do(http.MethodDelete, "/api/filters?id="+id, ""))
+	if len(list) != 1 || list[0].Name != "视频" {
+		t.Errorf("after DELETE: %+v", list)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 模糊搜索（容忍拼写错误）
+//
+// 以"fuzzy:"开头的查询由服务器处理：每个词拆成若干三字母片段（中文等为两字片段），
+// 用"<vac|act|cti|tio|ion>"这样的或条件交给Everything取回较宽的候选结果，
+// 再按与各个词的相似度（允许增删改和相邻字母对调）过滤并从高到低排序。
+// 这样"fuzzy:vaction photos"也能找到"vacation photos"。
+
+const (
+	fuzzyMinSimilarity = 0.7    // 每个词的最低相似度
+	fuzzyMaxCandidates = 100000 // 参与评分的候选结果上限
+)
+
+// 分离查询前的fuzzy:，返回剩余的查询和是否为模糊搜索
+func splitFuzzyQuery(query string) (string, bool) {
+	trimmed := strings.TrimSpace(query)
+	if len(trimmed) >= 6 && strings.EqualFold(trimmed[:6], "fuzzy:") {
+		return strings.TrimSpace(trimmed[6:]), true
+	}
+	return query, false
+}
+
+// 把查询拆成由字母和数字组成的小写词
+func fuzzyWords(text string) [][]rune {
+	var words [][]rune
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words = append(words, []rune(field))
+	}
+	return words
+}
+
+// 片段长度：ASCII词用三个字母，中文等每个字信息量大，用两个字
+func fuzzyGramSize(word []rune) int {
+	for _, r := range word {
+		if r > unicode.MaxASCII {
+			return 2
+		}
+	}
+	return 3
+}
+
+// 生成取回候选结果的Everything查询：各词之间为与，每个词的片段之间为或
+func fuzzyCandidateQuery(words [][]rune) string {
+	parts := make([]string, 0, len(words))
+	for _, word := range words {
+		n := fuzzyGramSize(word)
+		if len(word) <= n {
+			parts = append(parts, string(word))
+			continue
+		}
+		seen := make(map[string]bool)
+		var grams []string
+		for i := 0; i+n <= len(word); i++ {
+			if gram := string(word[i : i+n]); !seen[gram] {
+				seen[gram] = true
+				grams = append(grams, gram)
+			}
+		}
+		parts = append(parts, "<"+strings.Join(grams, "|")+">")
+	}
+	return "nopath: " + strings.Join(parts, " ")
+}
+
+// 编辑距离（相邻字符对调算一次编辑）
+func fuzzyDistance(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// 词与文件名中最相近的一段的相似度（0-1）
+func fuzzyWordSimilarity(word, name []rune) float64 {
+	if strings.Contains(string(name), string(word)) {
+		return 1
+	}
+	best := 0.0
+	for length := len(word) - 1; length <= len(word)+1; length++ {
+		if length <= 0 || length > len(name) {
+			continue
+		}
+		for start := 0; start+length <= len(name); start++ {
+			d := fuzzyDistance(word, name[start:start+length])
+			if s := 1 - float64(d)/float64(max(len(word), length)); s > best {
+				best = s
+				if best == 1 {
+					return 1
+				}
+			}
+		}
+	}
+	return best
+}
+
+// 文件名与所有词的相似度，任一词低于下限时返回0
+func fuzzyScore(words [][]rune, name string) float64 {
+	lower := []rune(strings.ToLower(name))
+	total := 0.0
+	for _, word := range words {
+		s := fuzzyWordSimilarity(word, lower)
+		if s < fuzzyMinSimilarity {
+			return 0
+		}
+		total += s
+	}
+	return total / float64(len(words))
+}
+
+// 执行模糊搜索，结果按相似度从高到低排序
+func searchFuzzy(ctx context.Context, query string) ([]string, error) {
+	words := fuzzyWords(query)
+	if len(words) == 0 {
+		return []string{}, nil
+	}
+
+	candidateQuery := fuzzyCandidateQuery(words)
+	log.Printf("模糊搜索: %s，候选查询: %s", query, candidateQuery)
+	candidates, err := runEverythingQuery(ctx, candidateQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > fuzzyMaxCandidates {
+		log.Printf("模糊搜索候选结果过多(%d)，只评分前%d个", len(candidates), fuzzyMaxCandidates)
+		candidates = candidates[:fuzzyMaxCandidates]
+	}
+
+	type scored struct {
+		path  string
+		score float64
+	}
+	var matches []scored
+	for i, path := range candidates {
+		if i%1000 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if s := fuzzyScore(words, filepath.Base(path)); s > 0 {
+			matches = append(matches, scored{path, s})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	paths := make([]string, len(matches))
+	for i, m := range matches {
+		paths[i] = m.path
+	}
+	log.Printf("模糊搜索: %d个候选结果中%d个匹配", len(candidates), len(paths))
+	return paths, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 带GPS坐标的照片（地图）
+//
+// GET /api/geophotos?path=文件夹  或  GET /api/geophotos?q=搜索关键词
+// 返回其中有GPS坐标的图片，坐标来自EXIF缓存（见exif.go），未缓存的图片在后台读取，下次请求时出现。
+// GET /map?path=文件夹 用Leaflet在地图上显示这些照片，点击标记打开图片查看器。
+// Leaflet和地图瓦片从外部加载（默认unpkg.com和OpenStreetMap），地址可以在配置中修改为自建的服务，
+// 地图页面的Content-Security-Policy只额外允许这两个来源。
+
+const (
+	geoPhotoLimit     = 20000 // 最多返回的照片数
+	defaultLeafletURL = "https://unpkg.com/leaflet@1.9.4/dist/"
+	defaultMapTileURL = "https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png"
+)
+
+type GeoPhoto struct {
+	Path  string  `json:"path"`
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Taken string  `json:"taken,omitempty"`
+}
+
+type GeoPhotosResponse struct {
+	Query       string     `json:"query"`
+	Photos      []GeoPhoto `json:"photos"`
+	Images      int        `json:"images"`      // 查询到的图片数
+	Located     int        `json:"located"`     // 有GPS坐标的图片数
+	PendingEXIF int        `json:"pendingExif"` // 后台尚未读取EXIF的图片数
+	Truncated   bool       `json:"truncated"`   // 超过geoPhotoLimit，只返回了一部分
+	ElapsedMs   int64      `json:"elapsedMs"`
+}
+
+// 请求对应的图片查询：q为搜索关键词，否则为path指定的文件夹（与时间线相同）
+func geoPhotosQuery(r *http.Request) (string, int, error) {
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		return "<" + q + "> file: ext:" + strings.Join(timelineExtensions, ";"), http.StatusOK, nil
+	}
+	folders, status, err := timelineFolders(r)
+	if err != nil {
+		return "", status, err
+	}
+	return timelineQuery(folders), http.StatusOK, nil
+}
+
+// 地图照片API处理器
+func apiGeoPhotosHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, status, err := geoPhotosQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	start := time.Now()
+	entries, err := collectEntries(r.Context(), query)
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消地图照片查询: %s", query)
+		return
+	}
+	if err != nil {
+		log.Printf("地图照片查询失败: %s, 错误: %v", query, err)
+		http.Error(w, "地图照片查询失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := GeoPhotosResponse{Query: query, Photos: []GeoPhoto{}}
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		resp.Images++
+		info, cached := cachedEXIF(e)
+		if !cached {
+			if !remoteEverythingEnabled() {
+				queueEXIFScan(e)
+			}
+			continue
+		}
+		if !info.HasGPS {
+			continue
+		}
+		resp.Located++
+		if len(resp.Photos) >= geoPhotoLimit {
+			resp.Truncated = true
+			continue
+		}
+		photo := GeoPhoto{Path: clientPath(e.Path), Lat: info.Lat, Lon: info.Lon}
+		if !info.Taken.IsZero() {
+			photo.Taken = info.Taken.Format("2006-01-02 15:04:05")
+		}
+		resp.Photos = append(resp.Photos, photo)
+	}
+	resp.PendingEXIF = pendingEXIFScans()
+	resp.ElapsedMs = time.Since(start).Milliseconds()
+	log.Printf("地图照片: %s，%d张图片，%d张有坐标，用时%dms，来源IP: %s", query, resp.Images, resp.Located, resp.ElapsedMs, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func leafletURL() string {
+	if serverConfig.LeafletURL != "" {
+		return strings.TrimSuffix(serverConfig.LeafletURL, "/") + "/"
+	}
+	return defaultLeafletURL
+}
+
+func mapTileURL() string {
+	if serverConfig.MapTileURL != "" {
+		return serverConfig.MapTileURL
+	}
+	return defaultMapTileURL
+}
+
+// 地址的scheme://host部分，用于CSP；瓦片地址中的{s}子域名替换为通配符
+func urlOrigin(raw string) string {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return "'self'" // 相对地址
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return scheme + "://" + strings.ReplaceAll(host, "{s}", "*")
+}
+
+// 地图页面
+func mapPageHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
+	if !serverConfig.DisableCSP {
+		leaflet, tiles := urlOrigin(leafletURL()), urlOrigin(mapTileURL())
+		policy := contentSecurityPolicy(nonce)
+		policy = addCSPSources(policy, "script-src", leaflet)
+		policy = addCSPSources(policy, "style-src", leaflet)
+		policy = addCSPSources(policy, "img-src", leaflet, tiles)
+		w.Header().Set("Content-Security-Policy", policy)
+	}
+
+	params := r.URL.Query()
+	title := params.Get("q")
+	if title == "" {
+		title = strings.Join(params["path"], "; ")
+	}
+	apiQuery := params.Encode()
+
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>地图 - ` + html.EscapeString(title) + ` - Everything Web Server</title>
+    <link rel="stylesheet" href="` + html.EscapeString(leafletURL()) + `leaflet.css">
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 10px; font-size: 14px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; }
+        #map { width: 100%; height: calc(100vh - 90px); border-radius: 8px; }
+        .photo-tip img { display: block; max-width: 160px; max-height: 160px; margin-bottom: 4px; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>🗺 地图</strong>
+        <span>` + html.EscapeString(title) + `</span>
+        <span class="meta" id="info">加载中...</span>
+    </div>
+    <div id="map"></div>
+    <script nonce="` + nonce + `" src="` + html.EscapeString(leafletURL()) + `leaflet.js"></script>
+    <script nonce="` + nonce + `">
+        const apiQuery = ` + jsString(apiQuery) + `;
+        const info = document.getElementById('info');
+
+        function escapeHtml(s) {
+            return String(s).replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
+        }
+
+        async function load() {
+            if (typeof L === 'undefined') {
+                info.textContent = 'Leaflet加载失败，请检查网络或配置中的leafletURL';
+                return;
+            }
+            const map = L.map('map').setView([20, 0], 2);
+            L.tileLayer(` + jsString(mapTileURL()) + `, { maxZoom: 19, attribution: '&copy; OpenStreetMap contributors' }).addTo(map);
+
+            try {
+                const response = await fetch('/api/geophotos?' + apiQuery);
+                if (!response.ok) throw new Error(await response.text());
+                const data = await response.json();
+                const points = [];
+                data.photos.forEach(photo => {
+                    const path = encodeURIComponent(photo.path);
+                    const name = photo.path.split(/[\\/]/).pop();
+                    L.circleMarker([photo.lat, photo.lon], { radius: 6, color: '#4CAF50', fillOpacity: 0.7 })
+                        .bindTooltip('<div class="photo-tip"><img src="/thumbnail/' + path + '">' + escapeHtml(name) + (photo.taken ? '<br>' + photo.taken : '') + '</div>')
+                        .on('click', () => window.open('/imageview/' + path, '_blank'))
+                        .addTo(map);
+                    points.push([photo.lat, photo.lon]);
+                });
+                if (points.length) map.fitBounds(points, { padding: [30, 30], maxZoom: 15 });
+                info.textContent = data.images + '张图片，' + data.located + '张有坐标' +
+                    (data.truncated ? '，只显示前' + data.photos.length + '张' : '') +
+                    (data.pendingExif ? '，后台正在读取' + data.pendingExif + '张图片的EXIF，稍后刷新' : '') + '，用时' + data.elapsedMs + 'ms';
+            } catch (error) {
+                info.textContent = '加载失败: ' + error.message;
+            }
+        }
+
+        load();
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
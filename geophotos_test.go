@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestParseEXIFGPS(t *testing.T) {
+	be := binary.BigEndian
+	tiff := []byte("MM\x00*")
+	tiff = be.AppendUint32(tiff, 8)
+	// IFD0：GPS IFD指针
+	tiff = be.AppendUint16(tiff, 1)
+	tiff = be.AppendUint16(tiff, 0x8825)
+	tiff = be.AppendUint16(tiff, 4)
+	tiff = be.AppendUint32(tiff, 1)
+	tiff = be.AppendUint32(tiff, 26)
+	tiff = be.AppendUint32(tiff, 0)
+	// GPS IFD：4个条目，度分秒存放在偏移80和104处
+	entry := func(tag, typ uint16, count uint32, value []byte) {
+		tiff = be.AppendUint16(tiff, tag)
+		tiff = be.AppendUint16(tiff, typ)
+		tiff = be.AppendUint32(tiff, count)
+		tiff = append(tiff, value...)
+	}
+	tiff = be.AppendUint16(tiff, 4)
+	entry(1, 2, 2, []byte("N\x00\x00\x00"))
+	entry(2, 5, 3, be.AppendUint32(nil, 80))
+	entry(3, 2, 2, []byte("W\x00\x00\x00"))
+	entry(4, 5, 3, be.AppendUint32(nil, 104))
+	tiff = be.AppendUint32(tiff, 0)
+	for _, r := range [][2]uint32{{40, 1}, {26, 1}, {4610, 100}, {79, 1}, {58, 1}, {5600, 100}} {
+		tiff = be.AppendUint32(tiff, r[0])
+		tiff = be.AppendUint32(tiff, r[1])
+	}
+
+	info, err := parseEXIF(tiff)
+	if err != nil {
+		t.Fatalf("parseEXIF: %v", err)
+	}
+	if !info.HasGPS {
+		t.Fatal("expected GPS coordinates")
+	}
+	if math.Abs(info.Lat-40.446139) > 1e-5 || math.Abs(info.Lon+79.982222) > 1e-5 {
+		t.Errorf("lat, lon = %f, %f, want 40.446139, -79.982222", info.Lat, info.Lon)
+	}
+	if !info.Taken.IsZero() {
+		t.Errorf("taken = %v, want zero", info.Taken)
+	}
+}
+
+func TestMapPageCSPOrigins(t *testing.T) {
+	tests := []struct{ url, want string }{
+		{defaultLeafletURL, "https://unpkg.com"},
+		{defaultMapTileURL, "https://*.tile.openstreetmap.org"},
+		{"/static/leaflet/", "'self'"},
+	}
+	for _, tt := range tests {
+		if got := urlOrigin(tt.url); got != tt.want {
+			t.Errorf("urlOrigin(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	policy := addCSPSources("script-src 'self'; img-src 'self' data:", "img-src", "https://a.example")
+	if want := "script-src 'self'; img-src 'self' data: https://a.example"; policy != want {
+		t.Errorf("addCSPSources = %q, want %q", policy, want)
+	}
+}
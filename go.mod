@@ -2,4 +2,13 @@ module everything-web-server
 
 go 1.24.4
 
-require golang.org/x/text v0.29.0 // indirect
+require (
+	github.com/dop251/goja v0.0.0-20260106131823-651366fbe6e3
+	golang.org/x/text v0.29.0
+)
+
+require (
+	github.com/dlclark/regexp2 v1.11.4 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
+)
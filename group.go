@@ -0,0 +1,94 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 搜索结果按文件夹分组
+//
+// /api/search?groupBy=folder 时把同一文件夹中的结果排在一起（文件夹按第一次出现的顺序，
+// 文件夹内保持原有顺序），响应中的groups列出当前页涉及的文件夹及其在全部结果中的数量。
+// 分组后的顺序保存在搜索缓存中，翻页时不重复计算。
+
+type FolderGroup struct {
+	Folder string `json:"folder"`
+	Count  int    `json:"count"` // 该文件夹在全部结果中的数量
+	Start  int    `json:"start"` // 第一个结果在全部结果中的位置
+}
+
+func parseGroupBy(r *http.Request) string {
+	if r.URL.Query().Get("groupBy") == "folder" {
+		return "folder"
+	}
+	return ""
+}
+
+// 把同一文件夹中的路径排在一起
+func groupPathsByFolder(paths *pathList) *pathList {
+	var order []string
+	byFolder := make(map[string][]string)
+	for i := 0; i < paths.Len(); i++ {
+		path := paths.At(i)
+		folder := strings.ToLower(filepath.Dir(path))
+		if _, ok := byFolder[folder]; !ok {
+			order = append(order, folder)
+		}
+		byFolder[folder] = append(byFolder[folder], path)
+	}
+
+	grouped := make([]string, 0, paths.Len())
+	for _, folder := range order {
+		grouped = append(grouped, byFolder[folder]...)
+	}
+	return newPathList(grouped)
+}
+
+// 分组后的搜索结果，按排序方式缓存在搜索缓存中
+func groupedSearchPaths(query, sortBy string, paths *pathList) *pathList {
+	cacheMutex.RLock()
+	cache, ok := searchCache[query]
+	if ok && cache.Grouped[sortBy] != nil {
+		grouped := cache.Grouped[sortBy]
+		cacheMutex.RUnlock()
+		return grouped
+	}
+	cacheMutex.RUnlock()
+
+	start := time.Now()
+	grouped := groupPathsByFolder(paths)
+	log.Printf("搜索结果按文件夹分组: query=%s, %d个路径, 用时%v", query, grouped.Len(), time.Since(start).Round(time.Millisecond))
+
+	cacheMutex.Lock()
+	if cache, ok := searchCache[query]; ok {
+		if cache.Grouped == nil {
+			cache.Grouped = make(map[string]*pathList)
+		}
+		cache.Grouped[sortBy] = grouped
+	}
+	cacheMutex.Unlock()
+	return grouped
+}
+
+// 当前页涉及的文件夹，数量按全部结果计算（同一文件夹的结果是连续的）
+func pageFolderGroups(paths *pathList, page, pageSize int) []FolderGroup {
+	start, end := pageBounds(paths.Len(), page, pageSize)
+	var groups []FolderGroup
+	for i := start; i < end; {
+		folder := filepath.Dir(paths.At(i))
+		first := i
+		for first > 0 && strings.EqualFold(filepath.Dir(paths.At(first-1)), folder) {
+			first--
+		}
+		last := i + 1
+		for last < paths.Len() && strings.EqualFold(filepath.Dir(paths.At(last)), folder) {
+			last++
+		}
+		groups = append(groups, FolderGroup{Folder: clientPath(folder), Count: last - first, Start: first})
+		i = last
+	}
+	return groups
+}
@@ -0,0 +1,728 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	stdhtml "html"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// 返回固定结果的搜索后端，记录查询次数
+type fakeBackend struct {
+	mu      sync.Mutex
+	paths   []string
+	queries []string
+}
+
+// 返回文件名包含查询（不区分大小写）的路径
+func (f *fakeBackend) Search(ctx context.Context, query string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, query)
+
+	var matched []string
+	for _, path := range f.paths {
+		if strings.Contains(strings.ToLower(filepath.Base(path)), strings.ToLower(query)) {
+			matched = append(matched, path)
+		}
+	}
+	return matched, nil
+}
+
+func (f *fakeBackend) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queries)
+}
+
+// 使用假后端并清空搜索缓存，测试结束后恢复
+func useFakeBackend(t *testing.T, paths []string) *fakeBackend {
+	t.Helper()
+	fake := &fakeBackend{paths: paths}
+	previous := searchBackend
+	searchBackend = fake
+	clearSearchCacheForTest()
+	t.Cleanup(func() {
+		searchBackend = previous
+		clearSearchCacheForTest()
+	})
+	return fake
+}
+
+func clearSearchCacheForTest() {
+	cacheMutex.Lock()
+	searchCache = make(map[string]*SearchCache)
+	cacheMutex.Unlock()
+}
+
+// 在临时目录中创建文件，返回完整路径
+func createTestFiles(t *testing.T, dir string, names ...string) []string {
+	t.Helper()
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(paths[i]), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(paths[i], []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func serveTestRequest(handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func decodeTestJSON(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	dir := t.TempDir()
+	names := make([]string, 25)
+	for i := range names {
+		names[i] = fmt.Sprintf("report_%02d.txt", i)
+	}
+	useFakeBackend(t, createTestFiles(t, dir, names...))
+
+	tests := []struct {
+		page, pageSize int
+		wantCount      int
+		wantFirst      string
+		wantTotalPages int
+		wantFirstIndex int
+	}{
+		{1, 10, 10, "report_00.txt", 3, 0},
+		{2, 10, 10, "report_10.txt", 3, 10},
+		{3, 10, 5, "report_20.txt", 3, 20},
+		{4, 10, 0, "", 3, 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/search?q=report&page=%d&pageSize=%d", tt.page, tt.pageSize), nil)
+		var resp SearchResponse
+		decodeTestJSON(t, serveTestRequest(apiSearchHandler, req), &resp)
+
+		if resp.TotalCount != 25 || resp.TotalPages != tt.wantTotalPages {
+			t.Errorf("page %d: totalCount=%d totalPages=%d", tt.page, resp.TotalCount, resp.TotalPages)
+		}
+		if len(resp.Results) != tt.wantCount {
+			t.Fatalf("page %d: %d results, want %d", tt.page, len(resp.Results), tt.wantCount)
+		}
+		if tt.wantCount > 0 {
+			if resp.Results[0].Name != tt.wantFirst || resp.Results[0].Index != tt.wantFirstIndex {
+				t.Errorf("page %d: first = %s (index %d), want %s (index %d)",
+					tt.page, resp.Results[0].Name, resp.Results[0].Index, tt.wantFirst, tt.wantFirstIndex)
+			}
+		}
+	}
+}
+
+func TestSearchUsesCache(t *testing.T) {
+	dir := t.TempDir()
+	fake := useFakeBackend(t, createTestFiles(t, dir, "a.txt", "b.txt"))
+
+	for page := 1; page <= 3; page++ {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/search?q=.txt&page=%d&pageSize=1", page), nil)
+		var resp SearchResponse
+		decodeTestJSON(t, serveTestRequest(apiSearchHandler, req), &resp)
+	}
+	if n := fake.calls(); n != 1 {
+		t.Errorf("backend queried %d times while paging, want 1", n)
+	}
+
+	clearSearchCacheForTest()
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=.txt", nil)
+	serveTestRequest(apiSearchHandler, req)
+	if n := fake.calls(); n != 2 {
+		t.Errorf("backend queried %d times after clearing cache, want 2", n)
+	}
+}
+
+// Everything排序和结果数上限不同的查询分别缓存
+func TestSearchCacheSeparatesQueryOptions(t *testing.T) {
+	dir := t.TempDir()
+	fake := useFakeBackend(t, createTestFiles(t, dir, "a.txt", "b.txt"))
+
+	for _, params := range []string{"", "&sort=size&order=desc", "&sort=size&order=desc&page=2&pageSize=1", "&max=1"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/search?q=.txt"+params, nil)
+		var resp SearchResponse
+		decodeTestJSON(t, serveTestRequest(apiSearchHandler, req), &resp)
+	}
+	if n := fake.calls(); n != 3 {
+		t.Errorf("backend queried %d times, want 3", n)
+	}
+}
+
+func TestSearchRequiresQuery(t *testing.T) {
+	useFakeBackend(t, nil)
+	rec := serveTestRequest(apiSearchHandler, httptest.NewRequest(http.MethodGet, "/api/search", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestFileRangeRequest(t *testing.T) {
+	dir := t.TempDir()
+	path := createTestFiles(t, dir, "中文 名称#1.txt")[0]
+
+	req := httptest.NewRequest(http.MethodGet, "/file/"+url.PathEscape(filepath.ToSlash(path)), nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := serveTestRequest(fileHandler, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206, body: %s", rec.Code, rec.Body.String())
+	}
+	if body, _ := io.ReadAll(rec.Body); string(body) != "2345" {
+		t.Errorf("body = %q, want %q", body, "2345")
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Errorf("Content-Range = %q", got)
+	}
+}
+
+func TestBrowseEdgeCases(t *testing.T) {
+	dir := t.TempDir()
+	file := createTestFiles(t, dir, "file.txt")[0]
+	empty := filepath.Join(dir, "empty")
+	if err := os.Mkdir(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"missing parameter", "", http.StatusBadRequest},
+		{"not found", filepath.Join(dir, "missing"), http.StatusNotFound},
+		{"not a folder", file, http.StatusBadRequest},
+		{"empty folder", empty, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/browse?path="+url.QueryEscape(tt.path), nil)
+		rec := serveTestRequest(apiBrowseHandler, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/browse?path="+url.QueryEscape(empty), nil)
+	var resp BrowseResponse
+	decodeTestJSON(t, serveTestRequest(apiBrowseHandler, req), &resp)
+	if resp.TotalCount != 0 || resp.Results == nil {
+		t.Errorf("empty folder: totalCount=%d, results=%v", resp.TotalCount, resp.Results)
+	}
+}
+
+func TestBrowsePagination(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir, "c.txt", "a.txt", "b.txt", filepath.Join("sub", "d.txt"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/browse?path="+url.QueryEscape(dir)+"&page=1&pageSize=2", nil)
+	var resp BrowseResponse
+	decodeTestJSON(t, serveTestRequest(apiBrowseHandler, req), &resp)
+
+	if resp.TotalCount != 4 {
+		t.Errorf("totalCount = %d, want 4", resp.TotalCount)
+	}
+	// 文件夹排在文件前面
+	if len(resp.Results) != 2 || resp.Results[0].Name != "sub" || resp.Results[1].Name != "a.txt" {
+		t.Errorf("first page = %+v", resp.Results)
+	}
+}
+
+func TestListenWithFallback(t *testing.T) {
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	if _, err := listenWithFallback("tcp", "", port, true); err == nil {
+		t.Error("strict mode should fail when the port is in use")
+	}
+
+	listener, err := listenWithFallback("tcp", "", port, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if got := listener.Addr().(*net.TCPAddr).Port; got <= port || got >= port+portFallbackAttempts {
+		t.Errorf("fallback port = %d, want one of the next %d ports after %d", got, portFallbackAttempts-1, port)
+	}
+}
+
+func TestListenURLs(t *testing.T) {
+	ipv4 := []string{"192.168.1.10"}
+	ipv6 := []string{"2001:db8::10"}
+	tests := []struct {
+		status listenStatus
+		local  []string
+		lan    []string
+	}{
+		{
+			listenStatus{Network: "tcp", Addr: "[::]:8080", Scheme: "http"},
+			[]string{"http://127.0.0.1:8080", "http://localhost:8080", "http://[::1]:8080"},
+			[]string{"http://192.168.1.10:8080", "http://[2001:db8::10]:8080"},
+		},
+		{
+			listenStatus{Network: "tcp4", Addr: "0.0.0.0:8080", Scheme: "http"},
+			[]string{"http://127.0.0.1:8080", "http://localhost:8080"},
+			[]string{"http://192.168.1.10:8080"},
+		},
+		{
+			listenStatus{Network: "tcp", Addr: "127.0.0.1:9090", Scheme: "https"},
+			[]string{"https://127.0.0.1:9090"},
+			nil,
+		},
+		{
+			listenStatus{Network: "tcp", Addr: "192.168.1.10:8080", Scheme: "http"},
+			nil,
+			[]string{"http://192.168.1.10:8080"},
+		},
+	}
+	for _, tt := range tests {
+		local, lan := listenURLs(tt.status, ipv4, ipv6)
+		if fmt.Sprint(local) != fmt.Sprint(tt.local) || fmt.Sprint(lan) != fmt.Sprint(tt.lan) {
+			t.Errorf("listenURLs(%s %s) = %v, %v; want %v, %v", tt.status.Network, tt.status.Addr, local, lan, tt.local, tt.lan)
+		}
+	}
+}
+
+func TestAdminGuard(t *testing.T) {
+	handler := withAdminGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	host := "127.0.0.1:8080"
+	serve := func(method, target, remoteAddr string, header http.Header) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = remoteAddr
+		req.Host = host
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		method, target, remoteAddr string
+		header                     http.Header
+		want                       int
+	}{
+		{http.MethodGet, "/api/batch", "192.168.1.20:50000", nil, http.StatusOK},
+		{http.MethodPost, "/api/batch", "192.168.1.20:50000", nil, http.StatusForbidden},
+		{http.MethodPost, "/api/batch", "127.0.0.1:50000", nil, http.StatusOK},
+		{http.MethodPost, "/api/batch", "[::1]:50000", nil, http.StatusOK},
+		{http.MethodPost, "/api/batch", "127.0.0.1:50000", http.Header{"X-Forwarded-For": {"192.168.1.20"}}, http.StatusForbidden},
+		{http.MethodPost, "/api/cache-clear", "192.168.1.20:50000", nil, http.StatusForbidden},
+		{http.MethodPost, "/api/cache-clear", "127.0.0.1:50000", nil, http.StatusOK},
+		{http.MethodGet, "/api/clipboard", "192.168.1.20:50000", nil, http.StatusForbidden},
+		{http.MethodPost, "/api/search", "192.168.1.20:50000", nil, http.StatusOK},
+		{http.MethodGet, "/api/exclusions", "192.168.1.20:50000", nil, http.StatusOK},
+		{http.MethodPut, "/api/exclusions", "192.168.1.20:50000", nil, http.StatusForbidden},
+		{http.MethodPatch, "/api/exclusions", "192.168.1.20:50000", nil, http.StatusForbidden},
+		{http.MethodPatch, "/api/exclusions", "127.0.0.1:50000", nil, http.StatusOK},
+		{http.MethodGet, "/api/monitors", "192.168.1.20:50000", nil, http.StatusOK},
+		{http.MethodPost, "/api/monitors", "192.168.1.20:50000", nil, http.StatusForbidden},
+		{http.MethodPut, "/api/monitors", "192.168.1.20:50000", nil, http.StatusForbidden},
+		{http.MethodPost, "/api/monitors/check", "192.168.1.20:50000", nil, http.StatusForbidden},
+		{http.MethodPost, "/api/monitors", "127.0.0.1:50000", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := serve(tt.method, tt.target, tt.remoteAddr, tt.header); got != tt.want {
+			t.Errorf("%s %s from %s = %d, want %d", tt.method, tt.target, tt.remoteAddr, got, tt.want)
+		}
+	}
+
+	// DNS重绑定：其他网站的域名解析到127.0.0.1，请求来自本机但Host是该域名
+	for h, want := range map[string]int{
+		"attacker.example:8080": http.StatusForbidden,
+		"attacker.example":      http.StatusForbidden,
+		"127.0.0.2":             http.StatusOK,
+		"[::1]:8080":            http.StatusOK,
+		"LocalHost.:8080":       http.StatusOK,
+		"192.168.1.10:8080":     http.StatusForbidden, // 不是监听地址
+	} {
+		host = h
+		if got := serve(http.MethodPost, "/api/batch", "127.0.0.1:50000", nil); got != want {
+			t.Errorf("POST /api/batch with Host %s = %d, want %d", h, got, want)
+		}
+	}
+
+	setListen := func(statuses ...listenStatus) {
+		serverListenMutex.Lock()
+		serverListen = statuses
+		serverListenMutex.Unlock()
+	}
+	defer setListen()
+
+	serverConfig.RemoteAdmin = true
+	defer func() { serverConfig.RemoteAdmin = false }()
+	setListen(listenStatus{Name: "lan", Network: "tcp", Addr: "192.168.1.10:8080", Scheme: "http"})
+	host = "192.168.1.10:8080"
+	if got := serve(http.MethodPost, "/api/batch", "192.168.1.20:50000", nil); got != http.StatusOK {
+		t.Errorf("remoteAdmin: POST /api/batch from LAN = %d, want 200", got)
+	}
+	host = "attacker.example:8080"
+	if got := serve(http.MethodPost, "/api/batch", "192.168.1.20:50000", nil); got != http.StatusForbidden {
+		t.Errorf("remoteAdmin: POST /api/batch with Host %s = %d, want 403", host, got)
+	}
+	serverConfig.RemoteAdmin = false
+
+	// 配置了管理端口时，其他端口即使来自本机也拒绝
+	lan := listenStatus{Name: "lan", Addr: "[::]:8080", Scheme: "http"}
+	admin := listenStatus{Name: "admin", Addr: "127.0.0.1:9090", Admin: true, Scheme: "http"}
+	setListen(lan, admin)
+	for _, tt := range []struct {
+		status listenStatus
+		want   int
+	}{{lan, http.StatusForbidden}, {admin, http.StatusOK}} {
+		req := httptest.NewRequest(http.MethodPost, "/api/undo", nil)
+		req.RemoteAddr = "127.0.0.1:50000"
+		req.Host = "127.0.0.1:9090"
+		rec := httptest.NewRecorder()
+		withListener(tt.status, handler).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("POST /api/undo on %s listener = %d, want %d", tt.status.Name, rec.Code, tt.want)
+		}
+	}
+}
+
+// 清除缓存只接受POST，GET请求不经过CSRF检查
+func TestCacheClearRequiresPost(t *testing.T) {
+	if rec := serveTestRequest(cacheClearHandler, httptest.NewRequest(http.MethodGet, "/api/cache-clear", nil)); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /api/cache-clear = %d, want 405", rec.Code)
+	}
+	if rec := serveTestRequest(cacheClearHandler, httptest.NewRequest(http.MethodPost, "/api/cache-clear", nil)); rec.Code != http.StatusOK {
+		t.Errorf("POST /api/cache-clear = %d, want 200", rec.Code)
+	}
+}
+
+func TestCSRFProtection(t *testing.T) {
+	handler := withCSRFProtection(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	const token = "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name   string
+		method string
+		origin string
+		cookie string
+		header string
+		want   int
+	}{
+		{"browser GET", http.MethodGet, "http://evil.example", "", "", http.StatusOK},
+		{"non-browser POST", http.MethodPost, "", "", "", http.StatusOK},
+		{"cross-site POST without token", http.MethodPost, "http://evil.example", token, "", http.StatusForbidden},
+		{"POST with wrong token", http.MethodDelete, "http://127.0.0.1:8080", token, "fedcba9876543210fedcba9876543210", http.StatusForbidden},
+		{"POST with token", http.MethodPost, "http://127.0.0.1:8080", token, token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/batch", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: tt.cookie})
+		}
+		if tt.header != "" {
+			req.Header.Set(csrfHeaderName, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	// 没有令牌的浏览器得到新令牌
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != csrfCookieName || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Errorf("expected a SameSite=Strict %s cookie, got %v", csrfCookieName, cookies)
+	}
+}
+
+func TestPageScriptsCarryCSPNonce(t *testing.T) {
+	handler := withSecurityHeaders(http.HandlerFunc(indexHandler))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	csp := rec.Header().Get("Content-Security-Policy")
+	_, rest, ok := strings.Cut(csp, "'nonce-")
+	nonce, _, _ := strings.Cut(rest, "'")
+	if !ok || len(nonce) != 32 {
+		t.Fatalf("Content-Security-Policy has no nonce: %q", csp)
+	}
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q", got)
+	}
+
+	// 不允许内联事件属性
+	if strings.Contains(csp, "script-src-attr") || strings.Contains(csp, "'unsafe-inline'; style-src") {
+		t.Errorf("Content-Security-Policy allows inline scripts: %q", csp)
+	}
+
+	body := rec.Body.String()
+	scripts := strings.Count(body, "<script")
+	if scripts == 0 || strings.Count(body, `<script nonce="`+nonce+`">`) != scripts {
+		t.Errorf("%d script tags, but not all of them carry the nonce %s", scripts, nonce)
+	}
+}
+
+// CSP不允许onclick等内联事件属性，页面中的按钮都要通过data-click等属性绑定
+func TestPagesHaveNoInlineEventHandlers(t *testing.T) {
+	dir := t.TempDir()
+	paths := createTestFiles(t, dir, "a.mp4", "b.mov", "c.avi", "d.jpg", "e.txt")
+	fileURL := func(prefix, path string) string { return prefix + url.PathEscape(filepath.ToSlash(path)) }
+	previous := ffmpegAvailable.Load()
+	t.Cleanup(func() { ffmpegAvailable.Store(previous) })
+
+	tests := []struct {
+		handler http.HandlerFunc
+		target  string
+		ffmpeg  bool
+	}{
+		{indexHandler, "/", false},
+		{videoPlayerHandler, fileURL("/video/", paths[0]), false},
+		{videoPlayerHandler, fileURL("/video/", paths[1]), false},
+		{videoPlayerHandler, fileURL("/video/", paths[2]), false},
+		{videoPlayerHandler, fileURL("/video/", paths[2]), true},
+		{imageViewerHandler, fileURL("/imageview/", paths[3]), false},
+		{textViewerHandler, fileURL("/textview/", paths[4]), false},
+		{duPageHandler, "/du?path=" + url.QueryEscape(dir), false},
+		{cleanupPageHandler, "/cleanup?path=" + url.QueryEscape(dir), false},
+		{selfTestPageHandler, "/selftest", false},
+		{downloadsPageHandler, "/downloads", false},
+	}
+	scriptsAndStyles := regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>`)
+	attrValues := regexp.MustCompile(`"[^"]*"`)
+	inlineHandler := regexp.MustCompile(`<[^>]*\son[a-z]+=`)
+	for _, tt := range tests {
+		ffmpegAvailable.Store(tt.ffmpeg)
+		rec := serveTestRequest(tt.handler, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d", tt.target, rec.Code)
+			continue
+		}
+		markup := attrValues.ReplaceAllString(scriptsAndStyles.ReplaceAllString(rec.Body.String(), ""), `""`)
+		if attr := inlineHandler.FindString(markup); attr != "" {
+			t.Errorf("%s (ffmpeg %v): inline event handler %q", tt.target, tt.ffmpeg, attr)
+		}
+	}
+}
+
+func TestJSStringEscapesScriptEnd(t *testing.T) {
+	got := jsString(`a'b"</script><script>alert(1)</script>`)
+	if strings.Contains(got, "</script>") || strings.Contains(got, "'b\"") {
+		t.Errorf("jsString did not escape: %s", got)
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`},
+		{"中文 名称.txt", `attachment; filename="__ __.txt"; filename*=UTF-8''%E4%B8%AD%E6%96%87%20%E5%90%8D%E7%A7%B0.txt`},
+		{"a\"b\\c\r\nd;e,f'g%.txt", `attachment; filename="a_b_c__d;e,f'g_.txt"; filename*=UTF-8''a%22b%5Cc%0D%0Ad%3Be%2Cf%27g%25.txt`},
+		{"", `attachment; filename="download"; filename*=UTF-8''`},
+	}
+	for _, tt := range tests {
+		if got := contentDisposition("attachment", tt.name); got != tt.want {
+			t.Errorf("contentDisposition(%q)\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 文件名中的引号、换行和HTML标签不能破坏响应头或页面
+func TestHostileFilenames(t *testing.T) {
+	const hostile = "x\"'<img src=x onerror=alert(1)>\r\n<script>名"
+	dir := t.TempDir()
+
+	tests := []struct {
+		handler http.HandlerFunc
+		prefix  string
+		ext     string
+		query   string
+	}{
+		{fileHandler, "/file/", ".txt", "?download=1"},
+		{videoPlayerHandler, "/video/", ".mp4", ""},
+		{imageViewerHandler, "/imageview/", ".jpg", ""},
+		{textViewerHandler, "/textview/", ".txt", ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, hostile+strings.TrimPrefix(tt.prefix, "/")[:1]+tt.ext)
+		if err := os.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+			t.Skipf("file system does not allow the test file name: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, tt.prefix+url.PathEscape(filepath.ToSlash(path))+tt.query, nil)
+		rec := serveTestRequest(tt.handler, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, body: %s", tt.prefix, rec.Code, rec.Body.String())
+			continue
+		}
+
+		for key, values := range rec.Header() {
+			for _, v := range values {
+				if strings.ContainsAny(v, "\r\n") {
+					t.Errorf("%s: header %s contains CR/LF: %q", tt.prefix, key, v)
+				}
+			}
+		}
+		if disposition := rec.Header().Get("Content-Disposition"); disposition != "" && !strings.Contains(disposition, "filename*=UTF-8''") {
+			t.Errorf("%s: Content-Disposition = %q", tt.prefix, disposition)
+		}
+		if strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+			body := rec.Body.String()
+			if strings.Contains(body, "<img src=x") || strings.Contains(body, "'<img") {
+				t.Errorf("%s: file name is not escaped in the page", tt.prefix)
+			}
+			if !strings.Contains(body, "&lt;img src=x") {
+				t.Errorf("%s: escaped file name is missing from the page", tt.prefix)
+			}
+		}
+	}
+
+	// 搜索和浏览结果列表由页面脚本拼接HTML，在goja中运行页面脚本显示服务器端嵌入的结果，检查生成的HTML
+	sub := filepath.Join(dir, hostile+"d")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	paths := createTestFiles(t, sub, hostile+".txt", hostile+".jpg", hostile+".sha256")
+	useFakeBackend(t, paths)
+
+	for _, target := range []string{"/search?q=" + url.QueryEscape("x"), "/browse?path=" + url.QueryEscape(sub)} {
+		handler := searchHandler
+		if strings.HasPrefix(target, "/browse") {
+			handler = browsePageHandler
+		}
+		rec := serveTestRequest(handler, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", target, rec.Code)
+		}
+		html := renderInitialResults(t, rec.Body.String())
+		if !strings.Contains(html, "&lt;img src=x") {
+			t.Fatalf("%s: results do not contain the escaped file name:\n%s", target, html)
+		}
+		if strings.Contains(html, "<img src=x") || strings.Contains(html, "<script>") {
+			t.Errorf("%s: file name is not escaped in the results:\n%s", target, html)
+		}
+		// 属性值中的引号都已转义，去掉属性值后检查标签中的on*属性
+		tags := regexp.MustCompile(`"[^"]*"`).ReplaceAllString(html, `""`)
+		if attr := regexp.MustCompile(`<[^>]*\son[a-z]+=`).FindString(tags); attr != "" {
+			t.Errorf("%s: results contain inline event handler %q", target, attr)
+		}
+		// data-path中的路径经过转义后原样保留，点击时得到正确的路径
+		found := false
+		for _, m := range regexp.MustCompile(`data-path="([^"]*)"`).FindAllStringSubmatch(html, -1) {
+			if stdhtml.UnescapeString(m[1]) == paths[0] {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: no data-path attribute with the file path", target)
+		}
+	}
+}
+
+// 在goja中运行首页脚本并调用applyInitialState，返回写入各元素innerHTML的内容。
+// document等浏览器对象用Proxy代替：读取任意属性和调用都返回新的代理，写入innerHTML时记录下来
+func renderInitialResults(t *testing.T, page string) string {
+	t.Helper()
+	start := strings.Index(page, "const initialState")
+	end := strings.LastIndex(page, "</script>")
+	if start < 0 || end < start {
+		t.Fatal("page script not found")
+	}
+	const browserStub = `
+		const rendered = [];
+		const stubs = {};
+		function stub(name) {
+			if (stubs[name]) return stubs[name];
+			const values = {};
+			return stubs[name] = new Proxy(function() {}, {
+				get(target, key) {
+					if (key === Symbol.toPrimitive) return () => '';
+					if (typeof key !== 'string') return undefined;
+					return key in values ? values[key] : stub(name + '.' + key);
+				},
+				set(target, key, value) {
+					values[key] = value;
+					if (key === 'innerHTML') rendered.push(value);
+					return true;
+				},
+				apply(target, self, args) { return stub(name + '(' + args.map(String).join(',') + ')'); },
+				construct() { return stub('new ' + name); },
+			});
+		}
+		const document = stub('document'), window = stub('window'), localStorage = stub('localStorage'),
+			history = stub('history'), location = stub('location'), navigator = stub('navigator'),
+			fetch = stub('fetch'), EventSource = stub('EventSource'), setTimeout = stub('setTimeout'),
+			setInterval = stub('setInterval'), Element = function() {};
+	`
+	vm := goja.New()
+	vm.Set("console", map[string]interface{}{"log": func(...interface{}) {}, "error": func(...interface{}) {}})
+	result, err := vm.RunString(browserStub + page[start:end] + "\napplyInitialState();\nrendered.join('\\n');")
+	if err != nil {
+		t.Fatalf("run page script: %v", err)
+	}
+	return result.String()
+}
+
+// 只有download=1时作为附件下载，其他查询参数和Accept请求头不影响
+func TestFileDisposition(t *testing.T) {
+	dir := t.TempDir()
+	paths := createTestFiles(t, dir, "doc.pdf", "page.html", "script.py", "archive.zip")
+	fileURL := func(path string) string { return "/file/" + url.PathEscape(filepath.ToSlash(path)) }
+
+	tests := []struct {
+		target      string
+		accept      string
+		disposition string
+		contentType string
+	}{
+		{fileURL(paths[0]), "*/*", "inline", "application/pdf"},
+		{fileURL(paths[0]) + "?t=123", "", "inline", "application/pdf"},
+		{fileURL(paths[0]) + "?download=1", "", "attachment", "application/octet-stream"},
+		{fileURL(paths[1]), "text/html", "inline", "text/plain; charset=utf-8"},
+		{fileURL(paths[2]), "", "inline", "text/plain; charset=utf-8"},
+		{fileURL(paths[3]), "", "inline", "application/zip"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := serveTestRequest(fileHandler, req)
+		disposition, _, _ := strings.Cut(rec.Header().Get("Content-Disposition"), ";")
+		if disposition != tt.disposition || rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: Content-Disposition %q, Content-Type %q; want %s, %s", tt.target, disposition, rec.Header().Get("Content-Type"), tt.disposition, tt.contentType)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 按会话记录的文件夹浏览历史和搜索历史
+//
+// entries是后退/前进栈，index指向当前位置；recent是去重后的最近访问文件夹，
+// 供“最近访问”列表使用；searches是去重后的最近搜索，供搜索框自动补全使用。浏览器自身的历史由页面通过pushState维护，
+// 服务器端的栈用于刷新页面、换设备或不方便使用浏览器后退键的场景（如电视遥控器）。
+
+type HistoryEntry struct {
+	Path    string `json:"path"`
+	Visited string `json:"visited"`
+}
+
+type SearchHistoryEntry struct {
+	Query    string `json:"query"`
+	Searched string `json:"searched"`
+}
+
+type BrowseHistory struct {
+	Entries  []HistoryEntry       `json:"entries"`
+	Index    int                  `json:"index"`
+	Recent   []HistoryEntry       `json:"recent"`
+	Searches []SearchHistoryEntry `json:"searches,omitempty"`
+}
+
+const (
+	historyFileName   = "history.json"
+	maxHistoryEntries = 100 // 后退栈最大长度
+	maxRecentFolders  = 20  // 最近访问列表最大长度
+	maxSearchHistory  = 50  // 搜索历史最大长度
+)
+
+var (
+	browseHistories = make(map[string]*BrowseHistory) // 会话ID → 浏览历史
+	historyMutex    sync.Mutex
+	historyLoaded   sync.Once
+)
+
+func ensureHistoryLoaded() {
+	historyLoaded.Do(func() {
+		historyMutex.Lock()
+		defer historyMutex.Unlock()
+		if err := loadJSONFile(historyFileName, &browseHistories); err != nil {
+			log.Printf("加载浏览历史失败: %v", err)
+		}
+		if browseHistories == nil {
+			browseHistories = make(map[string]*BrowseHistory)
+		}
+	})
+}
+
+// 修改会话的浏览历史并保存，返回修改后的副本
+func updateHistory(sessionID string, update func(h *BrowseHistory)) BrowseHistory {
+	ensureHistoryLoaded()
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	h, ok := browseHistories[sessionID]
+	if !ok {
+		h = &BrowseHistory{Index: -1}
+		browseHistories[sessionID] = h
+	}
+	update(h)
+
+	if len(h.Entries) == 0 && len(h.Recent) == 0 && len(h.Searches) == 0 {
+		delete(browseHistories, sessionID)
+	}
+	if err := saveJSONFile(historyFileName, browseHistories); err != nil {
+		log.Printf("保存浏览历史失败: %v", err)
+	}
+	return copyHistory(h)
+}
+
+func getHistory(sessionID string) BrowseHistory {
+	ensureHistoryLoaded()
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	if h, ok := browseHistories[sessionID]; ok {
+		return copyHistory(h)
+	}
+	return BrowseHistory{Entries: []HistoryEntry{}, Index: -1, Recent: []HistoryEntry{}}
+}
+
+func copyHistory(h *BrowseHistory) BrowseHistory {
+	result := BrowseHistory{
+		Entries: append([]HistoryEntry{}, h.Entries...),
+		Index:   h.Index,
+		Recent:  append([]HistoryEntry{}, h.Recent...),
+	}
+	result.Searches = append([]SearchHistoryEntry{}, h.Searches...)
+	return result
+}
+
+// 记录一次导航：丢弃当前位置之后的前进记录，追加新条目并更新最近访问
+func (h *BrowseHistory) visit(path string) {
+	entry := HistoryEntry{Path: path, Visited: time.Now().Format("2006-01-02 15:04:05")}
+
+	// 刷新同一文件夹不产生新的历史条目
+	if h.Index >= 0 && h.Index < len(h.Entries) && strings.EqualFold(h.Entries[h.Index].Path, path) {
+		h.Entries[h.Index] = entry
+	} else {
+		h.Entries = append(h.Entries[:h.Index+1], entry)
+		if len(h.Entries) > maxHistoryEntries {
+			h.Entries = h.Entries[len(h.Entries)-maxHistoryEntries:]
+		}
+		h.Index = len(h.Entries) - 1
+	}
+
+	recent := []HistoryEntry{entry}
+	for _, e := range h.Recent {
+		if !strings.EqualFold(e.Path, path) && len(recent) < maxRecentFolders {
+			recent = append(recent, e)
+		}
+	}
+	h.Recent = recent
+}
+
+// 记录一次搜索，相同的查询（不区分大小写）移到最前
+func (h *BrowseHistory) search(query string) {
+	searches := []SearchHistoryEntry{{Query: query, Searched: time.Now().Format("2006-01-02 15:04:05")}}
+	for _, e := range h.Searches {
+		if !strings.EqualFold(e.Query, query) && len(searches) < maxSearchHistory {
+			searches = append(searches, e)
+		}
+	}
+	h.Searches = searches
+}
+
+// 在历史中移动，返回是否移动成功
+func (h *BrowseHistory) move(delta int) bool {
+	target := h.Index + delta
+	if target < 0 || target >= len(h.Entries) {
+		return false
+	}
+	h.Index = target
+	return true
+}
+
+// 在浏览API中记录访问（只记录第一页，翻页不算新的导航）
+func recordBrowseVisit(w http.ResponseWriter, r *http.Request, folderPath string) {
+	q := r.URL.Query()
+	if q.Get("history") == "0" || (q.Get("page") != "" && q.Get("page") != "1") {
+		return
+	}
+	sessionID := getSessionID(w, r)
+	updateHistory(sessionID, func(h *BrowseHistory) {
+		h.visit(clientPath(folderPath))
+	})
+}
+
+// 在搜索API中记录搜索（只记录第一页）
+func recordSearch(w http.ResponseWriter, r *http.Request, query string) {
+	if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+		return
+	}
+	sessionID := getSessionID(w, r)
+	updateHistory(sessionID, func(h *BrowseHistory) {
+		h.search(query)
+	})
+}
+
+func writeHistoryResponse(w http.ResponseWriter, h BrowseHistory) {
+	current := ""
+	if h.Index >= 0 && h.Index < len(h.Entries) {
+		current = h.Entries[h.Index].Path
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries":    h.Entries,
+		"index":      h.Index,
+		"current":    current,
+		"canBack":    h.Index > 0,
+		"canForward": h.Index >= 0 && h.Index < len(h.Entries)-1,
+		"recent":     h.Recent,
+	})
+}
+
+// 浏览历史API处理器
+// GET 获取历史和最近访问；POST {"path"} 记录访问，{"action":"back"|"forward"} 后退或前进；
+// DELETE 清空历史
+func apiHistoryBrowseHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := getSessionID(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeHistoryResponse(w, getHistory(sessionID))
+
+	case http.MethodPost:
+		var req struct {
+			Path   string `json:"path"`
+			Action string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var h BrowseHistory
+		switch req.Action {
+		case "", "visit":
+			if req.Path == "" {
+				http.Error(w, "需要path参数", http.StatusBadRequest)
+				return
+			}
+			path := clientPath(resolveClientPath(req.Path))
+			h = updateHistory(sessionID, func(bh *BrowseHistory) { bh.visit(path) })
+		case "back", "forward":
+			delta := -1
+			if req.Action == "forward" {
+				delta = 1
+			}
+			moved := false
+			h = updateHistory(sessionID, func(bh *BrowseHistory) { moved = bh.move(delta) })
+			if !moved {
+				http.Error(w, "没有可以后退或前进的记录", http.StatusConflict)
+				return
+			}
+		default:
+			http.Error(w, "未知的操作: "+req.Action, http.StatusBadRequest)
+			return
+		}
+		writeHistoryResponse(w, h)
+
+	case http.MethodDelete:
+		h := updateHistory(sessionID, func(bh *BrowseHistory) {
+			*bh = BrowseHistory{Index: -1}
+		})
+		log.Printf("清空浏览历史，来源IP: %s", r.RemoteAddr)
+		writeHistoryResponse(w, h)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
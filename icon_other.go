@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "net/http"
+
+// 其他系统上没有Shell图标，前端加载失败时显示默认图标
+func iconHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "当前系统不支持获取文件图标", http.StatusNotFound)
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// 文件图标
+//
+// GET /icon?path=文件路径&size=16|32|48|256
+// 通过Shell（SHGetFileInfo和系统图像列表）获取资源管理器中显示的图标，转换为PNG。
+// exe、lnk、ico等每个文件图标不同的类型按文件缓存，其他类型按扩展名缓存（不访问文件本身），
+// 缓存在 data\cache\icons。Shell调用需要COM，全部在一个初始化了COM的专用线程中执行。
+
+// shell32在trash_windows.go中声明
+var (
+	user32 = syscall.NewLazyDLL("user32.dll")
+	gdi32  = syscall.NewLazyDLL("gdi32.dll")
+	ole32  = syscall.NewLazyDLL("ole32.dll")
+
+	procSHGetFileInfo      = shell32.NewProc("SHGetFileInfoW")
+	procSHGetImageList     = shell32.NewProc("SHGetImageList")
+	procDestroyIcon        = user32.NewProc("DestroyIcon")
+	procGetIconInfo        = user32.NewProc("GetIconInfo")
+	procGetObject          = gdi32.NewProc("GetObjectW")
+	procGetDIBits          = gdi32.NewProc("GetDIBits")
+	procCreateCompatibleDC = gdi32.NewProc("CreateCompatibleDC")
+	procDeleteDC           = gdi32.NewProc("DeleteDC")
+	procDeleteObject       = gdi32.NewProc("DeleteObject")
+	procCoInitializeEx     = ole32.NewProc("CoInitializeEx")
+)
+
+const (
+	shgfiSysIconIndex     = 0x4000
+	shgfiUseFileAttribute = 0x10
+	fileAttributeNormal   = 0x80
+	fileAttributeDir      = 0x10
+	shilExtraLarge        = 2 // 48×48
+	shilJumbo             = 4 // 256×256
+	ildTransparent        = 1
+	coinitApartmentThread = 2
+)
+
+// IID_IImageList {46EB5926-582E-4017-9FDF-E8998DAA0950}
+var iidImageList = syscall.GUID{Data1: 0x46EB5926, Data2: 0x582E, Data3: 0x4017, Data4: [8]byte{0x9F, 0xDF, 0xE8, 0x99, 0x8D, 0xAA, 0x09, 0x50}}
+
+// 每个文件图标不同的扩展名，其他扩展名同类型共用一个图标
+var perFileIconExts = map[string]bool{
+	".exe": true, ".lnk": true, ".ico": true, ".url": true, ".cpl": true, ".scr": true, ".msc": true,
+}
+
+type shFileInfo struct {
+	hIcon         uintptr
+	iIcon         int32
+	dwAttributes  uint32
+	szDisplayName [260]uint16
+	szTypeName    [80]uint16
+}
+
+type iconInfo struct {
+	fIcon    int32
+	xHotspot uint32
+	yHotspot uint32
+	hbmMask  uintptr
+	hbmColor uintptr
+}
+
+type bitmap struct {
+	bmType       int32
+	bmWidth      int32
+	bmHeight     int32
+	bmWidthBytes int32
+	bmPlanes     uint16
+	bmBitsPixel  uint16
+	bmBits       uintptr
+}
+
+type bitmapInfoHeader struct {
+	biSize          uint32
+	biWidth         int32
+	biHeight        int32
+	biPlanes        uint16
+	biBitCount      uint16
+	biCompression   uint32
+	biSizeImage     uint32
+	biXPelsPerMeter int32
+	biYPelsPerMeter int32
+	biClrUsed       uint32
+	biClrImportant  uint32
+}
+
+type iconRequest struct {
+	path   string
+	isDir  bool
+	byType bool // 只按扩展名获取，不访问文件
+	size   int
+	result chan iconResult
+}
+
+type iconResult struct {
+	png []byte
+	err error
+}
+
+var (
+	iconRequests   = make(chan iconRequest)
+	iconWorkerOnce sync.Once
+)
+
+// 启动图标线程：锁定系统线程并初始化COM，依次处理请求
+func startIconWorker() {
+	go func() {
+		runtime.LockOSThread()
+		procCoInitializeEx.Call(0, coinitApartmentThread)
+		for req := range iconRequests {
+			data, err := extractIcon(req.path, req.isDir, req.byType, req.size)
+			req.result <- iconResult{data, err}
+		}
+	}()
+}
+
+// 规范为系统图像列表支持的尺寸
+func normalizeIconSize(size int) int {
+	switch {
+	case size <= 16:
+		return 16
+	case size <= 32:
+		return 32
+	case size <= 48:
+		return 48
+	default:
+		return 256
+	}
+}
+
+// 获取图标并编码为PNG（在图标线程中调用）
+func extractIcon(path string, isDir, byType bool, size int) ([]byte, error) {
+	if err := shell32.Load(); err != nil {
+		return nil, err
+	}
+
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	flags := uintptr(shgfiSysIconIndex)
+	attrs := uintptr(fileAttributeNormal)
+	if isDir {
+		attrs = fileAttributeDir
+	}
+	if byType {
+		flags |= shgfiUseFileAttribute
+	}
+
+	var info shFileInfo
+	ret, _, _ := procSHGetFileInfo.Call(uintptr(unsafe.Pointer(pathPtr)), attrs,
+		uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info), flags)
+	if ret == 0 {
+		return nil, errors.New("SHGetFileInfo失败")
+	}
+
+	// 16和32对应SHIL_SMALL(1)和SHIL_LARGE(0)
+	list := map[int]uintptr{16: 1, 32: 0, 48: shilExtraLarge, 256: shilJumbo}[size]
+	var imageList unsafe.Pointer
+	if hr, _, _ := procSHGetImageList.Call(list, uintptr(unsafe.Pointer(&iidImageList)), uintptr(unsafe.Pointer(&imageList))); hr != 0 || imageList == nil {
+		return nil, fmt.Errorf("SHGetImageList失败: 0x%x", hr)
+	}
+	defer comRelease(imageList)
+
+	// IImageList::GetIcon是虚函数表中的第11项
+	var hIcon uintptr
+	if hr := comCall(imageList, 10, uintptr(info.iIcon), ildTransparent, uintptr(unsafe.Pointer(&hIcon))); hr != 0 || hIcon == 0 {
+		return nil, fmt.Errorf("获取图标失败: 0x%x", hr)
+	}
+	defer procDestroyIcon.Call(hIcon)
+
+	img, err := iconToImage(hIcon)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 调用COM对象虚函数表中的第index个方法
+func comCall(obj unsafe.Pointer, index int, args ...uintptr) uintptr {
+	vtbl := *(**[32]uintptr)(obj)
+	ret, _, _ := syscall.SyscallN(vtbl[index], append([]uintptr{uintptr(obj)}, args...)...)
+	return ret
+}
+
+func comRelease(obj unsafe.Pointer) {
+	comCall(obj, 2)
+}
+
+// 把HICON转换为图片，旧式图标没有alpha通道时使用掩码
+func iconToImage(hIcon uintptr) (*image.NRGBA, error) {
+	var ii iconInfo
+	if ret, _, _ := procGetIconInfo.Call(hIcon, uintptr(unsafe.Pointer(&ii))); ret == 0 {
+		return nil, errors.New("GetIconInfo失败")
+	}
+	defer procDeleteObject.Call(ii.hbmMask)
+	if ii.hbmColor == 0 {
+		return nil, errors.New("不支持单色图标")
+	}
+	defer procDeleteObject.Call(ii.hbmColor)
+
+	var bm bitmap
+	if ret, _, _ := procGetObject.Call(ii.hbmColor, unsafe.Sizeof(bm), uintptr(unsafe.Pointer(&bm))); ret == 0 {
+		return nil, errors.New("GetObject失败")
+	}
+	width, height := int(bm.bmWidth), int(bm.bmHeight)
+
+	dc, _, _ := procCreateCompatibleDC.Call(0)
+	if dc == 0 {
+		return nil, errors.New("CreateCompatibleDC失败")
+	}
+	defer procDeleteDC.Call(dc)
+
+	color, err := readBitmapBits(dc, ii.hbmColor, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	hasAlpha := false
+	for i := 3; i < len(color); i += 4 {
+		if color[i] != 0 {
+			hasAlpha = true
+			break
+		}
+	}
+	var mask []byte
+	if !hasAlpha && ii.hbmMask != 0 {
+		if mask, err = readBitmapBits(dc, ii.hbmMask, width, height); err != nil {
+			return nil, err
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		b, g, r, a := color[i*4], color[i*4+1], color[i*4+2], color[i*4+3]
+		if !hasAlpha {
+			a = 255
+			if mask != nil && mask[i*4] != 0 { // 掩码为白色表示透明
+				a = 0
+			}
+		}
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = r, g, b, a
+	}
+	return img, nil
+}
+
+// 以32位自上而下的格式读取位图像素（BGRA）
+func readBitmapBits(dc, hbm uintptr, width, height int) ([]byte, error) {
+	header := bitmapInfoHeader{
+		biWidth:    int32(width),
+		biHeight:   -int32(height),
+		biPlanes:   1,
+		biBitCount: 32,
+	}
+	header.biSize = uint32(unsafe.Sizeof(header))
+	// BITMAPINFO在头后面还有颜色表，32位时不使用，预留空间即可
+	buf := make([]byte, unsafe.Sizeof(header)+1024)
+	*(*bitmapInfoHeader)(unsafe.Pointer(&buf[0])) = header
+
+	pixels := make([]byte, width*height*4)
+	if ret, _, _ := procGetDIBits.Call(dc, hbm, 0, uintptr(height), uintptr(unsafe.Pointer(&pixels[0])), uintptr(unsafe.Pointer(&buf[0])), 0); ret == 0 {
+		return nil, errors.New("GetDIBits失败")
+	}
+	return pixels, nil
+}
+
+// 文件图标处理器
+func iconHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("path") == "" {
+		http.Error(w, "缺少path参数", http.StatusBadRequest)
+		return
+	}
+	filePath := resolveClientPath(r.URL.Query().Get("path"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	size = normalizeIconSize(size)
+
+	info, err := statPath(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "访问文件失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 文件夹和普通类型按类型获取，用"文件.扩展名"代替实际路径，同类型共用缓存
+	ext := strings.ToLower(filepath.Ext(filePath))
+	byType := info.IsDir() || !perFileIconExts[ext]
+	var key, shellPath string
+	switch {
+	case info.IsDir():
+		key = fmt.Sprintf("folder.%d", size)
+		shellPath = "folder"
+	case byType:
+		key = fmt.Sprintf("type%s.%d", ext, size)
+		shellPath = "file" + ext
+	default:
+		key = fileCacheKey(filePath, info, "icon"+strconv.Itoa(size))
+		shellPath = displayPath(filePath)
+	}
+	target := filepath.Join(getCacheDir("icons"), key+".png")
+
+	iconWorkerOnce.Do(startIconWorker)
+	err = ensureCachedFile(r.Context(), target, func(ctx context.Context, tmp string) error {
+		req := iconRequest{path: shellPath, isDir: info.IsDir(), byType: byType, size: size, result: make(chan iconResult, 1)}
+		select {
+		case iconRequests <- req:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		res := <-req.result
+		if res.err != nil {
+			return res.err
+		}
+		return os.WriteFile(tmp, res.png, 0644)
+	})
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("获取图标失败: %s, 错误: %v", filePath, err)
+			http.Error(w, "获取图标失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", thumbnailCacheControl)
+	serveFileContent(w, r, target)
+}
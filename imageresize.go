@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 图片缩放和格式转换
+//
+// GET /resize/图片路径?w=&h=&format=jpeg|webp|png&quality=
+// 按比例缩放到不超过w×h（不放大），用ffmpeg处理，结果缓存在 data\cache\images。
+// 手机浏览大尺寸照片时只需下载屏幕大小的版本。
+//
+// WebP、AVIF等较新的格式，客户端的Accept请求头中没有对应类型时（旧电视、电子书阅读器等），
+// /file/、/thumbnail/ 自动转换为JPEG发送，同样使用上面的缓存。
+
+const (
+	maxResizeDimension = 8192
+	defaultQuality     = 85
+)
+
+// 图片输出格式：格式名 → 扩展名和Content-Type
+var imageFormats = map[string]struct{ Ext, ContentType string }{
+	"jpeg": {".jpg", "image/jpeg"},
+	"webp": {".webp", "image/webp"},
+	"png":  {".png", "image/png"},
+}
+
+type ImageVariant struct {
+	Width   int    // 最大宽度，0表示不限制
+	Height  int    // 最大高度，0表示不限制
+	Format  string // jpeg, webp, png
+	Quality int    // 1-100，png忽略
+}
+
+// 从请求参数解析缩放参数
+func parseImageVariant(r *http.Request) (ImageVariant, error) {
+	query := r.URL.Query()
+	v := ImageVariant{Format: strings.ToLower(query.Get("format")), Quality: defaultQuality}
+
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"w", &v.Width}, {"h", &v.Height}} {
+		if s := query.Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 || n > maxResizeDimension {
+				return v, fmt.Errorf("%s参数应为1-%d之间的整数", p.name, maxResizeDimension)
+			}
+			*p.dst = n
+		}
+	}
+	if s := query.Get("quality"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			return v, fmt.Errorf("quality参数应为1-100之间的整数")
+		}
+		v.Quality = n
+	}
+
+	if v.Format == "" || v.Format == "jpg" {
+		v.Format = "jpeg"
+	}
+	if _, ok := imageFormats[v.Format]; !ok {
+		return v, fmt.Errorf("不支持的图片格式: %s", v.Format)
+	}
+	return v, nil
+}
+
+// ffmpeg参数（不含输入输出）
+func (v ImageVariant) args() []string {
+	var args []string
+	if v.Width > 0 || v.Height > 0 {
+		w, h := "iw", "ih"
+		if v.Width > 0 {
+			w = fmt.Sprintf("min(%d,iw)", v.Width)
+		}
+		if v.Height > 0 {
+			h = fmt.Sprintf("min(%d,ih)", v.Height)
+		}
+		args = append(args, "-vf", fmt.Sprintf("scale='%s':'%s':force_original_aspect_ratio=decrease", w, h))
+	}
+	args = append(args, "-frames:v", "1")
+
+	switch v.Format {
+	case "jpeg":
+		// 质量1-100对应mjpeg的qscale 31-2
+		args = append(args, "-q:v", strconv.Itoa(31-(v.Quality-1)*29/99))
+	case "webp":
+		args = append(args, "-c:v", "libwebp", "-quality", strconv.Itoa(v.Quality))
+	}
+	return args
+}
+
+// 生成（或从缓存获取）图片的变体，返回缓存文件路径
+func convertImage(ctx context.Context, src string, info os.FileInfo, v ImageVariant) (string, error) {
+	variant := fmt.Sprintf("%dx%d.%s.q%d", v.Width, v.Height, v.Format, v.Quality)
+	target := filepath.Join(getCacheDir("images"), fileCacheKey(src, info, variant)+imageFormats[v.Format].Ext)
+
+	err := ensureCachedFile(ctx, target, func(ctx context.Context, tmp string) error {
+		args := append([]string{"-i", src}, v.args()...)
+		cmd := exec.CommandContext(ctx, ffmpegBinary(), append(args, "-y", tmp)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return ffmpegError(err, out)
+		}
+		log.Printf("生成图片: %s → %s", src, variant)
+		return nil
+	})
+	return target, err
+}
+
+// 发送转换后的图片，生成失败时已写入错误响应
+func serveImageVariant(w http.ResponseWriter, r *http.Request, src string, info os.FileInfo, v ImageVariant) {
+	target, err := convertImage(r.Context(), src, info, v)
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("转换图片失败: %s, 错误: %v", src, err)
+			http.Error(w, "转换图片失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", imageFormats[v.Format].ContentType)
+	w.Header().Set("Cache-Control", thumbnailCacheControl)
+	serveFileContent(w, r, target)
+}
+
+// 图片缩放处理器
+func resizeHandler(w http.ResponseWriter, r *http.Request) {
+	filePath := decodeRequestPath(r.URL.Path[len("/resize/"):])
+
+	info, err := statPath(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "访问文件失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !isImageFile(strings.ToLower(filepath.Ext(filePath))) {
+		http.Error(w, "不是图片文件", http.StatusBadRequest)
+		return
+	}
+
+	v, err := parseImageVariant(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ffmpegAvailable.Load() {
+		http.Error(w, "ffmpeg不可用，无法缩放图片", http.StatusServiceUnavailable)
+		return
+	}
+
+	log.Printf("缩放图片: %s，%dx%d，格式: %s，质量: %d，来源IP: %s", filePath, v.Width, v.Height, v.Format, v.Quality, r.RemoteAddr)
+	serveImageVariant(w, r, filePath, info, v)
+}
+
+// 需要按Accept协商的图片格式：扩展名 → MIME类型
+var negotiatedImageTypes = map[string]string{
+	".webp": "image/webp",
+	".avif": "image/avif",
+}
+
+// Accept请求头中是否明确列出了该类型（*/*和image/*不算，旧设备也会发送）
+func acceptsImageType(r *http.Request, mimeType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		fields := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), mimeType) {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if q := strings.TrimSpace(param); q == "q=0" || q == "q=0.0" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// 客户端不支持原图格式时转换为JPEG发送，返回false表示不需要转换（由调用方发送原文件）
+func serveCompatibleImage(w http.ResponseWriter, r *http.Request, path string, info os.FileInfo) bool {
+	mimeType, ok := negotiatedImageTypes[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return false
+	}
+	w.Header().Add("Vary", "Accept")
+	if acceptsImageType(r, mimeType) || !ffmpegAvailable.Load() {
+		return false
+	}
+
+	log.Printf("客户端不支持%s，转换为JPEG: %s，来源IP: %s", mimeType, path, r.RemoteAddr)
+	serveImageVariant(w, r, path, info, ImageVariant{Format: "jpeg", Quality: 90})
+	return true
+}
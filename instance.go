@@ -0,0 +1,41 @@
+package main
+
+import "path/filepath"
+
+// Everything命名实例和便携版
+//
+// Everything可以用 -instance 名称 运行多个实例（如1.5 alpha默认的"1.5a"）。配置everythingInstance后：
+//   - 搜索通过Everything3 SDK连接该实例，失败时回退到 es.exe -instance 名称
+//   - Everything 1.4 SDK（Everything64.dll）只能连接默认实例，不再使用
+//   - 重建数据库时执行 Everything.exe -instance 名称 -reindex
+//
+// 配置everythingDir（便携版所在文件夹）后，优先从该文件夹加载DLL、es.exe和Everything.exe。
+
+// 配置的实例名，为空时使用默认实例
+func everythingInstanceName() string {
+	return serverConfig.EverythingInstance
+}
+
+// es.exe和Everything.exe的实例参数
+func everythingInstanceArgs() []string {
+	if name := everythingInstanceName(); name != "" {
+		return []string{"-instance", name}
+	}
+	return nil
+}
+
+// Everything3 SDK依次尝试连接的实例名，空字符串为默认实例
+func everything3InstanceNames() []string {
+	if name := everythingInstanceName(); name != "" {
+		return []string{name}
+	}
+	return []string{"1.5a", ""}
+}
+
+// 便携版文件夹中的文件，未配置everythingDir时返回空字符串
+func everythingDirFile(name string) string {
+	if serverConfig.EverythingDir == "" {
+		return ""
+	}
+	return filepath.Join(serverConfig.EverythingDir, name)
+}
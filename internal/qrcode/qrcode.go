@@ -0,0 +1,496 @@
+// Package qrcode 生成QR码（字节模式、纠错等级M、版本1～10），用于在控制台显示访问地址。
+//
+// 版本10的字节模式最多容纳213字节，足够放下局域网访问地址；
+// 更长的内容返回ErrTooLong。
+package qrcode
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrTooLong = errors.New("内容太长，无法生成QR码")
+
+// 纠错等级M的格式信息位
+const eclM = 0
+
+// 版本1～10在纠错等级M下的分块：每块纠错码字数，两组块的块数和每块数据码字数
+var blockTable = [11]struct {
+	ec             int
+	blocks1, data1 int
+	blocks2, data2 int
+}{
+	1:  {10, 1, 16, 0, 0},
+	2:  {16, 1, 28, 0, 0},
+	3:  {26, 1, 44, 0, 0},
+	4:  {18, 2, 32, 0, 0},
+	5:  {24, 2, 43, 0, 0},
+	6:  {16, 4, 27, 0, 0},
+	7:  {18, 4, 31, 0, 0},
+	8:  {22, 2, 38, 2, 39},
+	9:  {22, 3, 36, 2, 37},
+	10: {26, 4, 43, 1, 44},
+}
+
+// 校正图形的中心坐标
+var alignmentTable = [11][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+// QR码的模块矩阵
+type Code struct {
+	version    int
+	size       int
+	modules    [][]bool // [y][x]，true为深色
+	isFunction [][]bool // 定位、校正、时序图形和格式信息，不放数据也不加掩码
+}
+
+// 边长（模块数）
+func (c *Code) Size() int {
+	return c.size
+}
+
+// 版本（1～10）
+func (c *Code) Version() int {
+	return c.version
+}
+
+// 坐标处是否为深色模块，超出范围时为浅色
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.size && y < c.size && c.modules[y][x]
+}
+
+// 以字节模式编码文本，选择能容纳的最小版本
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v <= 10; v++ {
+		// 模式指示符4位 + 字符计数8位（版本10为16位）
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= dataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(addErrorCorrection(version, encodeData(version, data)))
+
+	// 选择惩罚分最低的掩码
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask) // 再次异或即撤销
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c, nil
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+	c := &Code{version: version, size: size}
+	c.modules = make([][]bool, size)
+	c.isFunction = make([][]bool, size)
+	for y := range c.modules {
+		c.modules[y] = make([]bool, size)
+		c.isFunction[y] = make([]bool, size)
+	}
+	return c
+}
+
+// 数据码字总数
+func dataCodewords(version int) int {
+	b := blockTable[version]
+	return b.blocks1*b.data1 + b.blocks2*b.data2
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.isFunction[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	// 时序图形
+	for i := 0; i < c.size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// 三个角的定位图形（含分隔符）
+	for _, center := range [][2]int{{3, 3}, {c.size - 4, 3}, {3, c.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x < 0 || y < 0 || x >= c.size || y >= c.size {
+					continue
+				}
+				dist := max(abs(dx), abs(dy))
+				c.setFunction(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	// 校正图形，跳过与定位图形重叠的三个角
+	positions := alignmentTable[c.version]
+	last := len(positions) - 1
+	for i, cy := range positions {
+		for j, cx := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// 先占住格式信息的位置，选择掩码后再写入
+	c.drawFormatBits(0)
+	c.drawVersionBits()
+}
+
+// 格式信息：纠错等级和掩码，BCH(15,5)编码
+func formatBits(ecl, mask int) int {
+	data := ecl<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(eclM, mask)
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	// 左上角定位图形旁
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	// 右上角和左下角的副本
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.size-15+i, bit(i))
+	}
+	c.setFunction(8, c.size-8, true) // 固定的深色模块
+}
+
+// 版本信息（版本7及以上），BCH(18,6)编码
+func (c *Code) drawVersionBits() {
+	if c.version < 7 {
+		return
+	}
+	rem := c.version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := c.version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 != 0
+		a, b := c.size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// 字节模式的数据码字：模式、长度、数据、终止符和填充
+func encodeData(version int, data []byte) []byte {
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, value>>i&1 != 0)
+		}
+	}
+	appendBits(0b0100, 4)
+	if version >= 10 {
+		appendBits(len(data), 16)
+	} else {
+		appendBits(len(data), 8)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	capacity := dataCodewords(version) * 8
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+
+	codewords := make([]byte, 0, capacity/8)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << (7 - j)
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xEC); len(codewords) < capacity/8; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// 分块计算纠错码字，按规范交错排列
+func addErrorCorrection(version int, data []byte) []byte {
+	b := blockTable[version]
+	divisor := reedSolomonDivisor(b.ec)
+
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < b.blocks1+b.blocks2; i++ {
+		n := b.data1
+		if i >= b.blocks1 {
+			n = b.data2
+		}
+		block := data[:n]
+		data = data[n:]
+		dataBlocks = append(dataBlocks, block)
+		ecBlocks = append(ecBlocks, reedSolomonRemainder(block, divisor))
+	}
+
+	var result []byte
+	for i := 0; i < max(b.data1, b.data2); i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < b.ec; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// GF(256)乘法，本原多项式0x11D
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// 纠错码生成多项式（不含最高次项的系数）
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// 按之字形从右下角开始放置数据位，跳过功能图形
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // 跳过竖直的时序图形
+		}
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.size - 1 - vert // 向上
+				}
+				if !c.isFunction[y][x] && i < len(data)*8 {
+					c.modules[y][x] = data[i>>3]>>(7-i&7)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.isFunction[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// 掩码惩罚分：连续同色、2×2同色块、类似定位图形的序列、深浅比例
+func (c *Code) penalty() int {
+	penalty := 0
+	line := make([]bool, c.size)
+	for _, vertical := range []bool{false, true} {
+		for a := 0; a < c.size; a++ {
+			for b := 0; b < c.size; b++ {
+				if vertical {
+					line[b] = c.modules[b][a]
+				} else {
+					line[b] = c.modules[a][b]
+				}
+			}
+			penalty += linePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.size && y+1 < c.size {
+				v := c.modules[y][x]
+				if c.modules[y][x+1] == v && c.modules[y+1][x] == v && c.modules[y+1][x+1] == v {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := c.size * c.size
+	percent := dark * 100 / total
+	penalty += abs(percent-50) / 5 * 10
+	return penalty
+}
+
+var finderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+func linePenalty(line []bool) int {
+	penalty := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += run - 2
+		}
+		run = 1
+	}
+
+	for i := 0; i+11 <= len(line); i++ {
+		for _, pattern := range finderLike {
+			match := true
+			for j, v := range pattern {
+				if line[i+j] != v {
+					match = false
+					break
+				}
+			}
+			if match {
+				penalty += 40
+			}
+		}
+	}
+	return penalty
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// 用半高方块字符输出，每行字符表示两行模块，四周留2个模块的空白
+//
+// 按控制台为深色背景、浅色文字绘制：浅色模块用方块填充，深色模块留空。
+func (c *Code) Terminal() string {
+	const quiet = 2
+	var b strings.Builder
+	for y := -quiet; y < c.size+quiet; y += 2 {
+		for x := -quiet; x < c.size+quiet; x++ {
+			top, bottom := !c.Dark(x, y), !c.Dark(x, y+1)
+			if y+1 >= c.size+quiet {
+				bottom = false
+			}
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// "HELLO WORLD" 版本1-M的数据码字和纠错码字
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	if got, want := formatBits(eclM, 0), 0b101010000010010; got != want {
+		t.Errorf("got %015b, want %015b", got, want)
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		length  int
+		version int
+	}{
+		{1, 1},
+		{14, 1},
+		{15, 2},
+		{106, 6},
+		{107, 7},
+		{213, 10},
+	}
+	for _, tt := range tests {
+		code, err := Encode(strings.Repeat("a", tt.length))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", tt.length, err)
+		}
+		if code.Version() != tt.version || code.Size() != tt.version*4+17 {
+			t.Errorf("Encode(%d bytes): version %d size %d, want version %d", tt.length, code.Version(), code.Size(), tt.version)
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("a", 214)); err != ErrTooLong {
+		t.Errorf("Encode(214 bytes) error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestEncodeFunctionPatterns(t *testing.T) {
+	code, err := Encode("http://192.168.1.10:8080/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 三个定位图形的中心和外框都是深色，分隔符是浅色
+	n := code.Size()
+	for _, corner := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		x, y := corner[0], corner[1]
+		if !code.Dark(x, y) || !code.Dark(x+3, y+3) || code.Dark(x+1, y+1) {
+			t.Errorf("finder pattern at (%d,%d) is wrong", x, y)
+		}
+	}
+	if !code.Dark(8, n-8) {
+		t.Error("dark module is missing")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(code.Terminal(), "\n"), "\n")
+	if want := (n + 5) / 2; len(lines) != want {
+		t.Errorf("terminal output has %d lines, want %d", len(lines), want)
+	}
+}
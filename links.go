@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 重解析点标签
+const (
+	ioReparseTagMountPoint = 0xA0000003
+	ioReparseTagSymlink    = 0xA000000C
+)
+
+// 链接类型
+const (
+	LinkTypeSymlink    = "symlink"
+	LinkTypeJunction   = "junction"
+	LinkTypeMountPoint = "mountpoint"
+)
+
+// 判断链接类型并解析目标，不是链接时返回空字符串
+func resolveLink(path string) (string, string) {
+	var linkType string
+	switch getReparseTag(path) {
+	case ioReparseTagSymlink:
+		linkType = LinkTypeSymlink
+	case ioReparseTagMountPoint:
+		linkType = LinkTypeJunction
+	default:
+		return "", ""
+	}
+
+	target, err := os.Readlink(extendedPath(path))
+	if err != nil {
+		return linkType, ""
+	}
+
+	// 卷挂载点的目标是卷GUID路径，例如 \\?\Volume{...}\
+	if linkType == LinkTypeJunction && strings.Contains(target, "Volume{") {
+		return LinkTypeMountPoint, target
+	}
+
+	target = displayPath(target)
+
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return linkType, filepath.Clean(target)
+}
+
+// 将链接信息写入结果（仅处理带重解析属性的条目）
+func applyLinkInfo(result *SearchResult, path string, attrs uint32) {
+	if attrs&fileAttributeReparsePoint == 0 {
+		return
+	}
+	linkType, target := resolveLink(path)
+	result.LinkType = linkType
+	if target != "" {
+		result.LinkTarget = clientPath(target)
+	}
+}
+
+// 获取文件夹的真实路径，用于检测链接循环
+func realFolderPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return strings.ToLower(filepath.Clean(path))
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"everything-web-server/internal/qrcode"
+)
+
+// 监听地址和端口回退
+//
+// 默认在所有地址（IPv4和IPv6双栈）上监听配置文件的port或命令行参数 -port 指定的端口（默认8080）。
+// 配置listeners时按列表分别监听，如局域网地址一个端口、127.0.0.1另一个端口，每个监听地址可以
+// 单独指定只用IPv4（tcp4）或IPv6（tcp6）。端口被占用或无权使用时，依次尝试后面的端口
+// （最多portFallbackAttempts个），并在控制台醒目地提示实际端口；strictPort或监听地址的strict
+// 为true时不回退，直接退出。实际监听的地址见 /api/status 的listen。
+
+const (
+	defaultServerPort    = 8080
+	portFallbackAttempts = 20
+)
+
+// 监听地址配置，如 {"name":"lan","addr":"192.168.1.10:8080"}
+type ListenerConfig struct {
+	Name    string `json:"name"`    // 显示在控制台和 /api/status 中
+	Addr    string `json:"addr"`    // 主机为空时监听所有地址，如 ":8080"、"127.0.0.1:9090"、"[::1]:9090"
+	Network string `json:"network"` // tcp（默认，双栈）、tcp4、tcp6
+	Strict  bool   `json:"strict"`  // 端口被占用时直接退出
+	Admin   bool   `json:"admin"`   // 管理端口，设置后管理操作只能通过该端口访问（见admin.go）
+}
+
+type listenStatus struct {
+	Name          string `json:"name"`
+	Network       string `json:"network"`
+	Addr          string `json:"addr"`
+	Port          int    `json:"port"`
+	RequestedPort int    `json:"requestedPort"`
+	Fallback      bool   `json:"fallback"` // 请求的端口不可用，使用了后面的端口
+	Strict        bool   `json:"strict"`
+	Admin         bool   `json:"admin"`
+	Scheme        string `json:"scheme"`
+}
+
+var (
+	serverListen      []listenStatus
+	serverListenMutex sync.RWMutex
+
+	flagPort int // 命令行参数 -port，覆盖第一个监听地址的端口
+)
+
+// 解析命令行参数，覆盖配置文件中的端口设置
+func parseServerFlags(args []string) {
+	fs := flag.NewFlagSet("everything-web-server", flag.ExitOnError)
+	port := fs.Int("port", 0, "监听端口，默认8080（或配置文件中的port）；配置了listeners时覆盖第一个监听地址的端口")
+	strict := fs.Bool("strict-port", false, "端口被占用时直接退出，不尝试后面的端口")
+	fs.Parse(args)
+
+	if *port > 0 {
+		flagPort = *port
+		serverConfig.Port = *port
+	}
+	if *strict {
+		serverConfig.StrictPort = true
+	}
+}
+
+// 要监听的地址：配置的listeners，未配置时为所有地址上的port
+func configuredListeners() []ListenerConfig {
+	if len(serverConfig.Listeners) == 0 {
+		port := serverConfig.Port
+		if port <= 0 || port > 65535 {
+			port = defaultServerPort
+		}
+		return []ListenerConfig{{Name: "main", Addr: ":" + strconv.Itoa(port)}}
+	}
+
+	listeners := make([]ListenerConfig, len(serverConfig.Listeners))
+	copy(listeners, serverConfig.Listeners)
+	for i := range listeners {
+		if listeners[i].Name == "" {
+			listeners[i].Name = "listener" + strconv.Itoa(i+1)
+		}
+	}
+	if flagPort > 0 {
+		host, _, _ := net.SplitHostPort(listeners[0].Addr)
+		listeners[0].Addr = net.JoinHostPort(host, strconv.Itoa(flagPort))
+	}
+	return listeners
+}
+
+// 监听地址，被占用时依次尝试后面的端口
+func listenWithFallback(network, host string, port int, strict bool) (net.Listener, error) {
+	attempts := portFallbackAttempts
+	if strict {
+		attempts = 1
+	}
+
+	var firstErr error
+	for i := 0; i < attempts && port+i <= 65535; i++ {
+		listener, err := net.Listen(network, net.JoinHostPort(host, strconv.Itoa(port+i)))
+		if err == nil {
+			return listener, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		log.Printf("无法监听端口%d: %v", port+i, err)
+	}
+	if strict {
+		return nil, fmt.Errorf("端口%d不可用（已启用strictPort）: %v", port, firstErr)
+	}
+	return nil, fmt.Errorf("端口%d～%d都不可用: %v", port, port+attempts-1, firstErr)
+}
+
+// 按配置监听全部地址，记录实际地址，任何一个地址无法监听时退出
+func listenServerPorts(scheme string) []net.Listener {
+	var listeners []net.Listener
+	var statuses []listenStatus
+	for _, cfg := range configuredListeners() {
+		network := cfg.Network
+		if network == "" {
+			network = "tcp"
+		}
+		host, portText, err := net.SplitHostPort(cfg.Addr)
+		port, _ := strconv.Atoi(portText)
+		if err != nil || port <= 0 || port > 65535 {
+			log.Fatalf("监听地址%s无效: %s，格式为\"主机:端口\"，如\":8080\"或\"127.0.0.1:9090\"", cfg.Name, cfg.Addr)
+		}
+		strict := cfg.Strict || serverConfig.StrictPort
+
+		listener, err := listenWithFallback(network, host, port, strict)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", cfg.Name, err)
+			fmt.Printf("💡 运行 stop.bat 停止已运行的服务器，或使用 -port 指定其他端口\n")
+			log.Fatal(err)
+		}
+
+		actual := listener.Addr().(*net.TCPAddr).Port
+		if actual != port {
+			log.Printf("%s: 端口%d已被占用，改为监听端口%d", cfg.Name, port, actual)
+		}
+		listeners = append(listeners, listener)
+		statuses = append(statuses, listenStatus{
+			Name:          cfg.Name,
+			Network:       network,
+			Addr:          listener.Addr().String(),
+			Port:          actual,
+			RequestedPort: port,
+			Fallback:      actual != port,
+			Strict:        strict,
+			Admin:         cfg.Admin,
+			Scheme:        scheme,
+		})
+	}
+
+	serverListenMutex.Lock()
+	serverListen = statuses
+	serverListenMutex.Unlock()
+	serverPort = strconv.Itoa(statuses[0].Port)
+	return listeners
+}
+
+func serverListenStatus() []listenStatus {
+	serverListenMutex.RLock()
+	defer serverListenMutex.RUnlock()
+	return append([]listenStatus(nil), serverListen...)
+}
+
+// 监听地址对应的访问地址。监听所有地址时为本机地址和各网卡的IPv4、IPv6地址
+func listenURLs(status listenStatus, ipv4, ipv6 []string) (local, lan []string) {
+	host, port, err := net.SplitHostPort(status.Addr)
+	if err != nil {
+		return nil, nil
+	}
+	url := func(host string) string {
+		return status.Scheme + "://" + net.JoinHostPort(host, port)
+	}
+
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil || ip.IsUnspecified():
+		// tcp时Go在[::]上同时接受IPv4和IPv6连接
+		v4 := status.Network != "tcp6"
+		v6 := status.Network != "tcp4" && (ip == nil || ip.To4() == nil)
+		if v4 {
+			local = append(local, url("127.0.0.1"), url("localhost"))
+			for _, addr := range ipv4 {
+				lan = append(lan, url(addr))
+			}
+		}
+		if v6 {
+			local = append(local, url("::1"))
+			for _, addr := range ipv6 {
+				lan = append(lan, url(addr))
+			}
+		}
+	case ip.IsLoopback():
+		local = append(local, url(host))
+	default:
+		lan = append(lan, url(host))
+	}
+	return local, lan
+}
+
+// 在控制台输出访问地址的QR码，手机扫码即可打开
+func printAddressQRCode(address string) {
+	code, err := qrcode.Encode(address)
+	if err != nil {
+		log.Printf("生成QR码失败: %v", err)
+		return
+	}
+	fmt.Printf("📱 手机扫码访问 %s\n", address)
+	fmt.Fprint(os.Stdout, code.Terminal())
+}
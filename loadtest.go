@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 压力测试子命令：everything-web-server.exe loadtest -url http://127.0.0.1:8080 -queries queries.txt
+//
+// 从查询文件（每行一个查询，#开头为注释）读取查询，以指定并发数循环请求运行中实例的 /api/search，
+// 结束后输出吞吐量和延迟百分位，用于比较SDK封装和缓存的性能变化。
+
+type loadTestResult struct {
+	latency time.Duration
+	status  int
+	err     error
+}
+
+func runLoadTest(args []string) int {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	baseURL := fs.String("url", "http://127.0.0.1:8080", "服务器地址")
+	queryFile := fs.String("queries", "", "查询文件，每行一个查询")
+	concurrency := fs.Int("c", 8, "并发数")
+	total := fs.Int("n", 200, "请求总数（设置 -d 时忽略）")
+	duration := fs.Duration("d", 0, "持续时间，如 30s")
+	pageSize := fs.Int("pageSize", DefaultPageSize, "每页条数")
+	pages := fs.Int("pages", 1, "每个查询依次请求的页数，用于测试缓存翻页")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	queries, err := readLoadTestQueries(*queryFile, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取查询失败: %v\n", err)
+		return 1
+	}
+	if len(queries) == 0 {
+		fmt.Fprintln(os.Stderr, "没有查询，请使用 -queries 指定查询文件或在参数中直接给出查询")
+		return 2
+	}
+
+	// 每个请求对应一个(查询, 页码)组合，按顺序循环使用
+	type request struct {
+		query string
+		page  int
+	}
+	var requests []request
+	for _, q := range queries {
+		for p := 1; p <= *pages; p++ {
+			requests = append(requests, request{q, p})
+		}
+	}
+
+	client := &http.Client{
+		Timeout: 2 * time.Minute,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: *concurrency,
+		},
+	}
+
+	fmt.Printf("压力测试: %s, %d个查询, 并发%d\n", *baseURL, len(queries), *concurrency)
+
+	var next int64 = -1
+	var deadline time.Time
+	if *duration > 0 {
+		deadline = time.Now().Add(*duration)
+	}
+
+	results := make(chan loadTestResult, *concurrency*2)
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := atomic.AddInt64(&next, 1)
+				if deadline.IsZero() && i >= int64(*total) {
+					return
+				}
+				if !deadline.IsZero() && time.Now().After(deadline) {
+					return
+				}
+
+				req := requests[i%int64(len(requests))]
+				target := fmt.Sprintf("%s/api/search?q=%s&page=%d&pageSize=%d",
+					strings.TrimRight(*baseURL, "/"), url.QueryEscape(req.query), req.page, *pageSize)
+
+				begin := time.Now()
+				resp, err := client.Get(target)
+				result := loadTestResult{err: err}
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					result.status = resp.StatusCode
+				}
+				result.latency = time.Since(begin)
+				results <- result
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var latencies []time.Duration
+	failures := 0
+	for result := range results {
+		if result.err != nil || result.status != http.StatusOK {
+			failures++
+			continue
+		}
+		latencies = append(latencies, result.latency)
+	}
+	elapsed := time.Since(start)
+
+	printLoadTestReport(latencies, failures, elapsed)
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// 读取查询文件，args中的查询追加在后面
+func readLoadTestQueries(path string, args []string) ([]string, error) {
+	var queries []string
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				queries = append(queries, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return append(queries, args...), nil
+}
+
+// 计算百分位（latencies需已排序）
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	idx := int(float64(len(latencies)-1) * p / 100)
+	return latencies[idx]
+}
+
+func printLoadTestReport(latencies []time.Duration, failures int, elapsed time.Duration) {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	total := len(latencies) + failures
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("请求总数: %d, 成功: %d, 失败: %d\n", total, len(latencies), failures)
+	fmt.Printf("耗时: %v, 吞吐量: %.1f 请求/秒\n", elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+	if len(latencies) == 0 {
+		return
+	}
+
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	fmt.Printf("延迟: 平均 %v, 最小 %v, 最大 %v\n",
+		(sum / time.Duration(len(latencies))).Round(time.Microsecond),
+		latencies[0].Round(time.Microsecond),
+		latencies[len(latencies)-1].Round(time.Microsecond))
+	for _, p := range []float64{50, 90, 95, 99} {
+		fmt.Printf("  p%-3.0f %v\n", p, percentile(latencies, p).Round(time.Microsecond))
+	}
+}
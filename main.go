@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"html"
 	"log"
 	"net"
 	"net/http"
@@ -14,9 +15,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
-	"unsafe"
+
+	"everything-web-server/pkg/everything"
 )
 
 type SearchResult struct {
@@ -26,6 +28,32 @@ type SearchResult struct {
 	Modified string `json:"modified"`
 	Type     string `json:"type"`
 	IsDir    bool   `json:"isDir"`
+	Hidden   bool   `json:"hidden,omitempty"`
+	System   bool   `json:"system,omitempty"`
+	ReadOnly bool   `json:"readOnly,omitempty"`
+	// 符号链接、目录联接、挂载点或快捷方式的类型及其指向的目标
+	LinkType      string `json:"linkType,omitempty"`
+	LinkTarget    string `json:"linkTarget,omitempty"`
+	LinkTargetDir bool   `json:"linkTargetDir,omitempty"` // 快捷方式的目标是文件夹
+	LinkBroken    bool   `json:"linkBroken,omitempty"`    // 快捷方式的目标不存在
+	// 来自descript.ion的文件描述
+	Description string `json:"description,omitempty"`
+	// 同一文件夹中记录了该文件校验和的校验文件（.sha256、.md5、.sfv等）
+	ChecksumFile string `json:"checksumFile,omitempty"`
+	// 用户设置的标签
+	Tags []string `json:"tags,omitempty"`
+	// 平均评分(1-5)和评分人数
+	Rating      float64 `json:"rating,omitempty"`
+	RatingCount int     `json:"ratingCount,omitempty"`
+	// 来自Everything 1.5索引的图片/视频尺寸、时长（秒）和码率（kbps）
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	Bitrate  int     `json:"bitrate,omitempty"`
+	// 在时间限制内未能获取文件信息，只有名称和路径
+	Partial bool `json:"partial,omitempty"`
+	// 在整个结果列表中的位置（从0开始），翻页和跳过无法访问的文件后保持不变，供键盘导航使用
+	Index int `json:"index"`
 }
 
 type SearchResponse struct {
@@ -36,25 +64,44 @@ type SearchResponse struct {
 	Page       int            `json:"page"`
 	PageSize   int            `json:"pageSize"`
 	TotalPages int            `json:"totalPages"`
+	Sort       string         `json:"sort,omitempty"` // natural或relevance，为空时保持Everything返回的顺序
+	GroupBy    string         `json:"groupBy,omitempty"`
+	Groups     []FolderGroup  `json:"groups,omitempty"` // 按文件夹分组时当前页涉及的文件夹
 }
 
 type BrowseResponse struct {
-	Results     []SearchResult `json:"results"`
-	Count       int            `json:"count"`
-	CurrentPath string         `json:"currentPath"`
-	ParentPath  string         `json:"parentPath"`
-	PathParts   []PathPart     `json:"pathParts"`
-	CanGoUp     bool           `json:"canGoUp"`
+	Results       []SearchResult `json:"results"`
+	Count         int            `json:"count"`
+	TotalCount    int            `json:"totalCount"`
+	Page          int            `json:"page"`
+	PageSize      int            `json:"pageSize"`
+	TotalPages    int            `json:"totalPages"`
+	Sort          string         `json:"sort"`
+	Order         string         `json:"order"`
+	ShowHidden    bool           `json:"showHidden"`
+	HiddenCount   int            `json:"hiddenCount"`             // 被隐藏的条目数
+	ExcludedCount int            `json:"excludedCount,omitempty"` // 被排除规则去掉的条目数
+	CurrentPath   string         `json:"currentPath"`
+	ParentPath    string         `json:"parentPath"`
+	PathParts     []PathPart     `json:"pathParts"`
+	CanGoUp       bool           `json:"canGoUp"`
+	// 文件夹说明（README或服务器端说明），没有时为空
+	Annotation *FolderAnnotation `json:"annotation,omitempty"`
 }
 
 type PathPart struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
+	// 同级文件夹通过 /api/siblings?path= 按需获取
+	SiblingsURL string `json:"siblingsUrl"`
 }
 
 // 搜索缓存结构
 type SearchCache struct {
-	Paths     []string
+	Paths     *pathList
+	Natural   *pathList            // 按文件名自然排序的Paths，第一次需要时生成
+	Relevance *pathList            // 按相关度排序的Paths，第一次需要时生成
+	Grouped   map[string]*pathList // 按文件夹分组后的结果，键为排序方式
 	Timestamp time.Time
 }
 
@@ -63,99 +110,57 @@ var (
 	searchCache     = make(map[string]*SearchCache)
 	cacheMutex      sync.RWMutex
 	cacheExpiry     = 10 * time.Minute // 缓存10分钟过期
-	ffmpegAvailable = false            // ffmpeg是否可用
+	ffmpegAvailable atomic.Bool        // ffmpeg是否可用，运行中会重新检测
 )
 
+const thumbnailCacheControl = "private, max-age=3600"
+
+const maxLoggedPaths = 20 // 每次搜索最多逐条记录的路径数
+
 const (
 	DefaultPageSize = 50  // 默认每页显示50条结果
 	MaxPageSize     = 200 // 最大每页显示200条结果
 )
 
-// Everything SDK Windows API 定义
+// Everything SDK，第一次使用时加载
 var (
-	everythingDLL                   *syscall.LazyDLL
-	everythingSetSearch             *syscall.LazyProc
-	everythingQuery                 *syscall.LazyProc
-	everythingGetNumResults         *syscall.LazyProc
-	everythingGetResultFullPath     *syscall.LazyProc
-	everythingGetResultSize         *syscall.LazyProc
-	everythingGetResultDateModified *syscall.LazyProc
-	everythingIsFolder              *syscall.LazyProc
-	everythingReset                 *syscall.LazyProc
-	everythingSetMax                *syscall.LazyProc
-	everythingSetOffset             *syscall.LazyProc
-	everythingGetLastError          *syscall.LazyProc
-	everythingInitialized           = false
+	everythingClient      *everything.Client
+	everythingInitialized = false
+	everythingInitMutex   sync.Mutex
+	everythingDLLPath     string // 已加载的DLL路径
 )
 
 // 初始化Everything SDK
 func initEverythingSDK() error {
+	everythingInitMutex.Lock()
+	defer everythingInitMutex.Unlock()
+
 	if everythingInitialized {
 		return nil
 	}
-
-	// 尝试不同的DLL位置
-	dllPaths := []string{
-		"Everything64.dll", // 当前目录
-		"C:\\Program Files\\Everything\\Everything64.dll",       // 标准安装位置
-		"C:\\Program Files (x86)\\Everything\\Everything64.dll", // x86安装位置
-		"Everything.exe", // 如果有Everything.exe，尝试同目录的DLL
+	if name := everythingInstanceName(); name != "" {
+		return fmt.Errorf("已配置Everything实例 %s，Everything64.dll只能连接默认实例", name)
 	}
 
-	var lastErr error
-	for _, path := range dllPaths {
-		if path == "Everything.exe" {
-			// 检查Everything进程是否在运行，获取其路径
-			continue // 暂时跳过进程检测
-		}
-
-		if _, err := os.Stat(path); err == nil {
-			log.Printf("找到Everything DLL: %s", path)
-			everythingDLL = syscall.NewLazyDLL(path)
-
-			// 测试加载
-			if err := everythingDLL.Load(); err != nil {
-				lastErr = err
-				log.Printf("无法加载 %s: %v", path, err)
-				continue
-			}
-
-			// 初始化所有函数指针
-			everythingSetSearch = everythingDLL.NewProc("Everything_SetSearchW")
-			everythingQuery = everythingDLL.NewProc("Everything_QueryW")
-			everythingGetNumResults = everythingDLL.NewProc("Everything_GetNumResults")
-			everythingGetResultFullPath = everythingDLL.NewProc("Everything_GetResultFullPathNameW")
-			everythingGetResultSize = everythingDLL.NewProc("Everything_GetResultSize")
-			everythingGetResultDateModified = everythingDLL.NewProc("Everything_GetResultDateModified")
-			everythingIsFolder = everythingDLL.NewProc("Everything_IsFolderResult")
-			everythingReset = everythingDLL.NewProc("Everything_Reset")
-			everythingSetMax = everythingDLL.NewProc("Everything_SetMax")
-			everythingSetOffset = everythingDLL.NewProc("Everything_SetOffset")
-			everythingGetLastError = everythingDLL.NewProc("Everything_GetLastError")
-
-			everythingInitialized = true
-			log.Printf("Everything SDK初始化成功，使用: %s", path)
-			return nil
-		}
+	// 尝试不同的DLL位置，便携版优先
+	dllPaths := everything.DefaultDLLPaths
+	if path := everythingDirFile("Everything64.dll"); path != "" {
+		dllPaths = append([]string{path}, dllPaths...)
 	}
 
-	return fmt.Errorf("无法找到Everything64.dll，请确保Everything已安装。最后错误: %v", lastErr)
+	client, err := everything.Find(dllPaths...)
+	if err != nil {
+		return fmt.Errorf("无法加载Everything64.dll，请确保Everything已安装: %v", err)
+	}
+	everythingClient = client
+	everythingInitialized = true
+	everythingDLLPath = client.Path()
+	log.Printf("Everything SDK初始化成功，使用: %s", everythingDLLPath)
+	return nil
 }
 
-// Everything SDK 错误码
-const (
-	EVERYTHING_OK                    = 0
-	EVERYTHING_ERROR_MEMORY          = 1
-	EVERYTHING_ERROR_IPC             = 2
-	EVERYTHING_ERROR_REGISTERCLASSEX = 3
-	EVERYTHING_ERROR_CREATEWINDOW    = 4
-	EVERYTHING_ERROR_CREATETHREAD    = 5
-	EVERYTHING_ERROR_INVALIDINDEX    = 6
-	EVERYTHING_ERROR_INVALIDCALL     = 7
-)
-
 // 使用Everything SDK搜索文件
-func searchWithEverythingSDK(query string) ([]string, error) {
+func searchWithEverythingSDK(ctx context.Context, query string) ([]string, error) {
 	log.Printf("使用Everything SDK搜索: %s", query)
 
 	// 初始化Everything SDK
@@ -163,74 +168,33 @@ func searchWithEverythingSDK(query string) ([]string, error) {
 		return nil, err
 	}
 
-	// 重置搜索
-	everythingReset.Call()
-
-	// 设置搜索字符串（UTF-16）
-	searchPtr, _ := syscall.UTF16PtrFromString(query)
-	everythingSetSearch.Call(uintptr(unsafe.Pointer(searchPtr)))
-
-	// 执行查询
-	ret, _, _ := everythingQuery.Call(1) // TRUE for wait
-	if ret == 0 {
-		// 获取错误码
-		errorCode, _, _ := everythingGetLastError.Call()
-		return nil, fmt.Errorf("Everything查询失败，错误码: %d", errorCode)
-	}
-
-	// 获取结果数量
-	numResults, _, _ := everythingGetNumResults.Call()
-	log.Printf("Everything找到%d个结果", numResults)
-
-	if numResults == 0 {
-		return []string{}, nil
+	opts := queryOptionsFrom(ctx)
+	results, err := everythingClient.Search(ctx, everything.Query{Search: query, Sort: opts.sdkSort(), Max: opts.Max})
+	if err != nil {
+		return nil, err
 	}
+	log.Printf("Everything找到%d个结果", results.Len())
 
-	// 获取所有结果
-	var paths []string
-	for i := uintptr(0); i < numResults; i++ {
-		// 获取文件路径
-		pathBuffer := make([]uint16, 4096)
-		everythingGetResultFullPath.Call(
-			i,
-			uintptr(unsafe.Pointer(&pathBuffer[0])),
-			uintptr(len(pathBuffer)),
-		)
-		path := syscall.UTF16ToString(pathBuffer)
-		if path != "" {
-			paths = append(paths, path)
-		}
+	paths, err := results.Paths()
+	if err != nil {
+		return nil, err
 	}
-
 	log.Printf("Everything SDK返回%d个有效路径", len(paths))
 	return paths, nil
 }
 
-// 回退方案：使用es.exe搜索文件（保留用于Everything SDK不可用时）
-func searchWithESExe(query string) ([]string, error) {
-	log.Printf("使用es.exe回退搜索: %s", query)
-
-	cmd := exec.Command("./es.exe", query)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("执行es.exe失败: %v", err)
-	}
-
-	lines := strings.Split(string(output), "\n")
-	var paths []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			paths = append(paths, line)
-		}
-	}
+// 获取本机所有IPv4地址
+func getLocalIPs() []string {
+	return localInterfaceIPs(func(ip net.IP) bool { return ip.To4() != nil })
+}
 
-	log.Printf("es.exe返回%d个有效路径", len(paths))
-	return paths, nil
+// 获取本机的IPv6地址（全局地址和ULA），链路本地地址需要区域ID，浏览器无法直接访问，不包括在内
+func getLocalIPv6s() []string {
+	return localInterfaceIPs(func(ip net.IP) bool { return ip.To4() == nil && ip.IsGlobalUnicast() })
 }
 
-// 获取本机所有IP地址
-func getLocalIPs() []string {
+// 已激活的非环回网卡上符合条件的地址
+func localInterfaceIPs(match func(net.IP) bool) []string {
 	var ips []string
 
 	interfaces, err := net.Interfaces()
@@ -259,12 +223,12 @@ func getLocalIPs() []string {
 				ip = v.IP
 			}
 
-			// 只获取IPv4地址，排除环回地址
+			// 排除环回地址
 			if ip == nil || ip.IsLoopback() {
 				continue
 			}
 
-			if ip.To4() != nil {
+			if match(ip) {
 				ips = append(ips, ip.String())
 			}
 		}
@@ -273,25 +237,47 @@ func getLocalIPs() []string {
 	return ips
 }
 
+// 监听端口
+var serverPort = "8080"
+
 func main() {
+	// 子命令
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		os.Exit(runLoadTest(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fetch" {
+		os.Exit(runFetch(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "mirror" {
+		os.Exit(runMirror(os.Args[2:]))
+	}
+
 	// 设置日志格式
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("正在启动Everything Web Server...")
 
-	// 检测ffmpeg是否可用
-	checkFFmpegAvailability()
+	// 加载配置，命令行参数覆盖配置文件
+	loadServerConfig()
+	parseServerFlags(os.Args[1:])
+	setupLogFile()
 
-	// 启动缓存清理协程
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute) // 每5分钟清理一次
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				cleanExpiredCache()
-			}
-		}
-	}()
+	// 检测ffmpeg和Everything是否可用，运行中由定时任务重新检测
+	detectTools()
+
+	// 清理超过撤销期限的已删除文件
+	startTrashPurger()
+
+	// 重新连接已保存凭据的网络共享
+	go restoreShareConnections()
+
+	// 清理缓存目录中上次运行遗留的文件
+	reconcileCacheDirs()
+
+	// 启动定时维护任务（搜索缓存清理、缓存目录容量控制、日志轮转等）
+	startScheduler()
+
+	// 加载数据目录scripts文件夹中的脚本钩子
+	loadScripts()
 
 	// 设置静态文件服务
 	http.HandleFunc("/", indexHandler)
@@ -300,42 +286,183 @@ func main() {
 	http.HandleFunc("/stream/", streamHandler)
 	http.HandleFunc("/transcode/", transcodeHandler)
 	http.HandleFunc("/thumbnail/", thumbnailHandler)
+	http.HandleFunc("/resize/", resizeHandler)
+	http.HandleFunc("/icon", iconHandler)
+	http.HandleFunc("/api/shortcut", apiShortcutHandler)
+	http.HandleFunc("/storyboard/", storyboardHandler)
+	http.HandleFunc("/api/clip", apiClipHandler)
+	http.HandleFunc("/api/animation", apiAnimationHandler)
 	http.HandleFunc("/api/search", apiSearchHandler)
+	http.HandleFunc("/api/refine", apiRefineHandler)
+	http.HandleFunc("/api/exclusions", apiExclusionsHandler)
+	http.HandleFunc("/api/syntax", apiSyntaxHandler)
+	http.HandleFunc("/api/autocomplete", apiAutocompleteHandler)
+	http.HandleFunc("/api/snapshots", apiSnapshotsHandler)
+	http.HandleFunc("/api/snapshots/diff", apiSnapshotDiffHandler)
+	http.HandleFunc("/api/monitors", apiMonitorsHandler)
+	http.HandleFunc("/api/monitors/check", apiMonitorCheckHandler)
+	http.HandleFunc("/api/notifications", apiNotificationsHandler)
+	http.HandleFunc("/api/notifications/events", apiNotificationEventsHandler)
+	http.HandleFunc("/api/notifications/test", apiNotificationTestHandler)
+	http.HandleFunc("/api/stats", apiStatsHandler)
+	http.HandleFunc("/api/du", apiDuHandler)
+	http.HandleFunc("/du", duPageHandler)
+	http.HandleFunc("/api/timeline", apiTimelineHandler)
+	http.HandleFunc("/timeline", timelinePageHandler)
+	http.HandleFunc("/api/geophotos", apiGeoPhotosHandler)
+	http.HandleFunc("/map", mapPageHandler)
+	http.HandleFunc("/api/cleanup-suggestions", apiCleanupSuggestionsHandler)
+	http.HandleFunc("/cleanup", cleanupPageHandler)
 	http.HandleFunc("/api/browse", apiBrowseHandler)
+	http.HandleFunc("/api/tree", apiTreeHandler)
+	http.HandleFunc("/api/siblings", apiSiblingsHandler)
+	http.HandleFunc("/api/neighbors", apiNeighborsHandler)
+	http.HandleFunc("/api/shares", apiSharesHandler)
+	http.HandleFunc("/api/bootstrap", apiBootstrapHandler)
+	http.HandleFunc("/api/prefs", apiPrefsHandler)
+	http.HandleFunc("/api/history/browse", apiHistoryBrowseHandler)
+	http.HandleFunc("/b/", indexHandler)
+	http.HandleFunc("/browse", browsePageHandler)
+	http.HandleFunc("/api/shortcuts", apiShortcutsHandler)
+	http.HandleFunc("/api/smart-filters", apiSmartFiltersHandler)
+	http.HandleFunc("/api/filters", apiFiltersHandler)
+	http.HandleFunc("/api/filters/export", apiFiltersExportHandler)
+	http.HandleFunc("/api/filters/import", apiFiltersImportHandler)
+	http.HandleFunc("/api/dashboard", apiDashboardHandler)
+	http.HandleFunc("/api/transfers", apiTransfersHandler)
+	http.HandleFunc("/api/favorites", apiFavoritesHandler)
+	http.HandleFunc("/api/favorites/export", apiFavoritesExportHandler)
+	http.HandleFunc("/api/favorites/import", apiFavoritesImportHandler)
+	http.HandleFunc("/api/annotations", apiAnnotationsHandler)
+	http.HandleFunc("/api/tags", apiTagsHandler)
+	http.HandleFunc("/api/ratings", apiRatingsHandler)
+	http.HandleFunc("/api/comments", apiCommentsHandler)
+	http.HandleFunc("/api/batch", apiBatchHandler)
+	http.HandleFunc("/api/batch/events", apiBatchEventsHandler)
+	http.HandleFunc("/api/undo", apiUndoHandler)
+	http.HandleFunc("/api/download", apiDownloadHandler)
+	http.HandleFunc("/download/", downloadPartHandler)
+	http.HandleFunc("/api/download-plan", apiDownloadPlanHandler)
+	http.HandleFunc("/segment/", segmentHandler)
+	http.HandleFunc("/api/sync/manifest", apiSyncManifestHandler)
+	http.HandleFunc("/api/sync/pull", apiSyncPullHandler)
+	http.HandleFunc("/api/capture", apiCaptureHandler)
+	http.HandleFunc("/api/clipboard", apiClipboardHandler)
+	http.HandleFunc("/api/peers", apiPeersHandler)
+	http.HandleFunc("/api/wol", apiWOLHandler)
+	http.HandleFunc("/api/actions", apiActionsHandler)
+	http.HandleFunc("/api/scripts", apiScriptsHandler)
+	http.HandleFunc("/api/scripts/reload", apiScriptsReloadHandler)
+	http.HandleFunc("/api/sqlite", apiSQLiteHandler)
+	http.HandleFunc("/api/torrent", apiTorrentHandler)
+	http.HandleFunc("/api/torrent/check", apiTorrentCheckHandler)
+	http.HandleFunc("/api/subtitle/video", apiSubtitleVideoHandler)
+	http.HandleFunc("/api/disc", apiDiscHandler)
+	http.HandleFunc("/api/properties", apiPropertiesHandler)
+	http.HandleFunc("/downloads", downloadsPageHandler)
 	http.HandleFunc("/api/text", textPreviewHandler)
 	http.HandleFunc("/api/cache-status", cacheStatusHandler)
+	http.HandleFunc("/api/schedule", apiScheduleHandler)
+	http.HandleFunc("/api/status", apiStatusHandler)
+	http.HandleFunc("/api/transcode-profiles", apiTranscodeProfilesHandler)
+	http.HandleFunc("/api/abr", apiABRHandler)
+	http.HandleFunc("/api/redetect", apiRedetectHandler)
+	http.HandleFunc("/api/selftest", apiSelfTestHandler)
+	http.HandleFunc("/selftest", selfTestPageHandler)
+	http.HandleFunc("/api/everything/status", apiEverythingStatusHandler)
+	http.HandleFunc("/api/everything/rescan", apiEverythingRescanHandler)
 	http.HandleFunc("/api/cache-clear", cacheClearHandler)
+	http.HandleFunc("/api/cache", apiCacheHandler)
+	http.HandleFunc("/api/cache/purge", apiCachePurgeHandler)
 	http.HandleFunc("/video/", videoPlayerHandler)
 	http.HandleFunc("/imageview/", imageViewerHandler)
+	http.HandleFunc("/svg/", svgHandler)
+	http.HandleFunc("/subtitle/", subtitleHandler)
+	http.HandleFunc("/disc/", discFileHandler)
 	http.HandleFunc("/textview/", textViewerHandler)
+	http.HandleFunc("/preview/", previewHandler)
+	http.HandleFunc("/modelview/", modelViewerHandler)
+	http.HandleFunc("/model/", modelHandler)
+	http.HandleFunc("/api/preview/providers", apiPreviewProvidersHandler)
 
 	// 启动服务器
-	port := "8080"
+	scheme := "http"
+	if serverConfig.TLSCertFile != "" && serverConfig.TLSKeyFile != "" {
+		scheme = "https"
+	}
+	listeners := listenServerPorts(scheme)
+	statuses := serverListenStatus()
+
+	// 检查运行环境，问题和修复方法写入日志，也可以在 /selftest 页面查看（防火墙检查使用实际端口）
+	go runStartupSelfTest()
 
 	// 获取本机IP地址
 	localIPs := getLocalIPs()
+	localIPv6s := getLocalIPv6s()
 
-	log.Printf("服务器启动在端口: %s", port)
+	var ports []string
+	for _, status := range statuses {
+		ports = append(ports, strconv.Itoa(status.Port))
+	}
+	log.Printf("服务器启动在端口: %s (%s)", strings.Join(ports, ", "), scheme)
 	fmt.Printf("🚀 Everything Web Server 已启动！\n")
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	for _, status := range statuses {
+		if status.Fallback {
+			fmt.Printf("⚠️  %s: 端口%d已被占用，改用端口%d\n", status.Name, status.RequestedPort, status.Port)
+		}
+	}
 	fmt.Printf("📍 访问地址：\n")
-	fmt.Printf("   本地访问: http://127.0.0.1:%s\n", port)
-	fmt.Printf("   本地访问: http://localhost:%s\n", port)
 
-	for _, ip := range localIPs {
-		fmt.Printf("   局域网访问: http://%s:%s\n", ip, port)
+	var qrAddress string
+	for _, status := range statuses {
+		if status.Admin {
+			fmt.Printf("   [%s] 监听 %s（管理端口）\n", status.Name, status.Addr)
+			warnAdminListener(status)
+		} else if len(statuses) > 1 {
+			fmt.Printf("   [%s] 监听 %s\n", status.Name, status.Addr)
+		}
+		local, lan := listenURLs(status, localIPs, localIPv6s)
+		for _, address := range local {
+			fmt.Printf("   本地访问: %s\n", address)
+		}
+		for _, address := range lan {
+			fmt.Printf("   局域网访问: %s\n", address)
+		}
+		if qrAddress == "" && len(lan) > 0 {
+			qrAddress = lan[0]
+		}
 	}
 
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 	fmt.Printf("💡 如果局域网无法访问，请检查Windows防火墙设置\n")
-	fmt.Printf("🔧 运行 'netsh advfirewall firewall add rule name=\"Everything Web Server\" dir=in action=allow protocol=TCP localport=%s' 添加防火墙规则\n", port)
-	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
-
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	fmt.Printf("🔧 运行 'netsh advfirewall firewall add rule name=\"Everything Web Server\" dir=in action=allow protocol=TCP localport=%s' 添加防火墙规则\n", strings.Join(ports, ","))
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if qrAddress != "" {
+		printAddressQRCode(qrAddress)
+	}
+	fmt.Println()
+
+	// 每个监听地址一个HTTP服务器，任何一个停止时退出
+	errs := make(chan error, len(listeners))
+	for i, listener := range listeners {
+		handler := withListener(statuses[i], withAdminGuard(withCSRFProtection(withSecurityHeaders(withDownloadVeto(withCompression(http.DefaultServeMux))))))
+		server := newHTTPServer(listener.Addr().String(), handler)
+		go func() {
+			errs <- startHTTPServer(server, listener)
+		}()
+	}
+	log.Fatal(<-errs)
 }
 
 // 首页处理器
 func indexHandler(w http.ResponseWriter, r *http.Request) {
+	// /b/<编码后的路径> 是较早的文件夹书签地址，转到 /browse?path=
+	if strings.HasPrefix(r.URL.Path, "/b/") {
+		folderPath := decodeRequestPath(r.URL.Path[3:])
+		http.Redirect(w, r, "/browse?path="+url.QueryEscape(clientPath(folderPath)), http.StatusMovedPermanently)
+		return
+	}
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
@@ -343,12 +470,19 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("访问首页，来源IP: %s", r.RemoteAddr)
 
+	writeIndexPage(w, r, "Everything Web Server", nil)
+}
+
+// 输出首页。initial不为nil时嵌入服务器端已获取的搜索或浏览结果，页面加载后直接显示
+func writeIndexPage(w http.ResponseWriter, r *http.Request, title string, initial *PageState) {
+	nonce := cspNonce(r)
 	tmpl := `<!DOCTYPE html>
 <html lang="zh-CN">
 <head>
+    ` + csrfFetchScript(nonce) + `
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Everything Web Server</title>
+    <title>{{TITLE}}</title>
     <style>
         * { box-sizing: border-box; margin: 0; padding: 0; }
         body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; }
@@ -382,6 +516,12 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
         .search-box { display: flex; gap: 10px; margin-bottom: 20px; }
         .search-input { flex: 1; padding: 12px; border: 2px solid #ddd; border-radius: 6px; font-size: 16px; }
         .search-input:focus { outline: none; border-color: #4CAF50; }
+        .search-box { position: relative; }
+        .autocomplete-list { position: absolute; top: 100%; left: 0; right: 90px; z-index: 20; background: white; border: 1px solid #ddd; border-radius: 0 0 6px 6px; box-shadow: 0 4px 8px rgba(0,0,0,0.1); max-height: 360px; overflow-y: auto; }
+        .autocomplete-item { padding: 8px 12px; cursor: pointer; display: flex; justify-content: space-between; gap: 10px; }
+        .autocomplete-item.active, .autocomplete-item:hover { background: #e8f5e9; }
+        .autocomplete-detail { color: #999; font-size: 12px; white-space: nowrap; }
+        .syntax-table td { padding: 2px 10px 2px 0; vertical-align: top; }
         .search-btn { padding: 12px 24px; background: #4CAF50; color: white; border: none; border-radius: 6px; cursor: pointer; font-size: 16px; }
         .search-btn:hover { background: #45a049; }
         .path-bar { margin-top: 15px; }
@@ -399,10 +539,32 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
         .breadcrumb { margin-bottom: 20px; padding: 10px; background: white; border-radius: 6px; }
         .breadcrumb a { color: #4CAF50; text-decoration: none; margin-right: 5px; }
         .breadcrumb a:hover { text-decoration: underline; }
+        .favorites { margin-bottom: 20px; padding: 10px; background: white; border-radius: 6px; display: flex; flex-wrap: wrap; gap: 8px; align-items: center; }
+        .selection-bar { margin-bottom: 20px; padding: 10px; background: #e8f5e9; border-radius: 6px; display: flex; gap: 10px; align-items: center; position: sticky; top: 0; z-index: 400; }
+        .select-item { width: 18px; height: 18px; margin-right: 5px; cursor: pointer; }
+        .favorite-item { padding: 4px 10px; background: #fff8e1; border: 1px solid #ffe082; border-radius: 14px; font-size: 13px; cursor: pointer; }
+        .favorite-item:hover { background: #ffecb3; }
+        .smart-filter { background: #e8f5e9; border-color: #a5d6a7; }
+        .smart-filter:hover { background: #c8e6c9; }
+        .favorite-item .remove { color: #999; margin-left: 6px; }
+        .favorite-item .remove:hover { color: #f44336; }
+        .sibling-toggle { color: #999; cursor: pointer; padding: 0 4px; margin-right: 5px; }
+        .sibling-toggle:hover { color: #4CAF50; }
+        .siblings-menu { position: absolute; background: white; border: 1px solid #ddd; border-radius: 4px; box-shadow: 0 2px 10px rgba(0,0,0,0.15); max-height: 300px; overflow-y: auto; z-index: 500; min-width: 180px; }
+        .sibling-item { padding: 6px 12px; cursor: pointer; font-size: 14px; white-space: nowrap; }
+        .sibling-item:hover { background: #f5f5f5; }
+        .sibling-item.current { font-weight: bold; color: #4CAF50; }
         .results { background: white; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
         .result-item { display: flex; align-items: center; padding: 15px; border-bottom: 1px solid #eee; transition: background 0.2s; }
         .result-item:hover { background: #f9f9f9; }
         .result-item:last-child { border-bottom: none; }
+        .result-item.hidden-file { opacity: 0.5; }
+        .result-item.active { background: #e8f5e9; box-shadow: inset 3px 0 0 #4CAF50; }
+        .results.compact .result-item { padding: 6px 15px; }
+        .results.compact .file-icon { width: 24px; height: 24px; font-size: 12px; }
+        .results.compact .shell-icon { width: 24px; height: 24px; padding: 0; }
+        .results.compact .file-name { margin-bottom: 0; }
+        .results.compact .file-meta, .results.compact .thumbnail { display: none; }
         .file-icon { width: 40px; height: 40px; margin-right: 15px; background: #4CAF50; border-radius: 4px; display: flex; align-items: center; justify-content: center; color: white; font-weight: bold; }
         .file-icon.video { background: #FF5722; }
         .file-icon.image { background: #2196F3; }
@@ -419,15 +581,36 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
         .btn:hover { opacity: 0.8; }
         .loading { text-align: center; padding: 40px; color: #666; }
         .no-results { text-align: center; padding: 40px; color: #666; }
+        .dashboard { display: grid; grid-template-columns: repeat(auto-fill, minmax(320px, 1fr)); gap: 16px; padding: 16px; }
+        .dashboard-section h3 { margin: 0 0 8px; font-size: 15px; color: #333; }
+        .dashboard-section h3 a { font-size: 12px; font-weight: normal; margin-left: 8px; }
+        .dashboard-item { display: flex; justify-content: space-between; gap: 8px; padding: 4px 0; border-bottom: 1px solid #f0f0f0; font-size: 13px; cursor: pointer; }
+        .dashboard-item:hover { color: #4CAF50; }
+        .dashboard-item .name { overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+        .dashboard-item .meta { color: #999; white-space: nowrap; }
+        .dashboard-empty { color: #999; font-size: 13px; }
         .thumbnail { width: 60px; height: 60px; object-fit: cover; border-radius: 4px; margin-right: 15px; }
+        .shell-icon { width: 40px; height: 40px; padding: 4px; object-fit: contain; margin-right: 15px; }
         .pagination { text-align: center; padding: 20px; }
         .pagination button { margin: 0 5px; padding: 8px 12px; border: 1px solid #ddd; background: white; cursor: pointer; border-radius: 4px; }
         .pagination button.active { background: #4CAF50; color: white; border-color: #4CAF50; }
         .pagination button:hover:not(.active) { background: #f5f5f5; }
         .pagination button:disabled { opacity: 0.5; cursor: not-allowed; }
+        .folder-group-header { padding: 8px 12px; background: #f0f4f8; border-radius: 4px; margin: 8px 0 4px; font-size: 14px; color: #333; cursor: pointer; user-select: none; }
+        .folder-group-header .group-count { color: #888; }
+        .folder-group-header a { margin-left: 10px; font-size: 12px; }
+        .folder-group { padding-left: 12px; }
+        .stats-panel { padding: 12px 15px; background: #f9f9f9; border-radius: 4px; margin-bottom: 10px; font-size: 13px; color: #555; }
+        .stats-panel .stats-columns { display: flex; gap: 30px; flex-wrap: wrap; margin-top: 8px; }
+        .stats-panel .stats-columns > div { flex: 1; min-width: 250px; word-break: break-all; }
+        .refine-bar { display: flex; gap: 10px; align-items: center; margin-bottom: 10px; font-size: 13px; color: #666; }
+        .refine-bar input[type="text"] { flex: 1; padding: 6px 10px; border: 1px solid #ddd; border-radius: 4px; }
         .search-stats { text-align: center; padding: 10px; color: #666; background: #f9f9f9; margin-bottom: 10px; }
         .cache-info { text-align: center; padding: 8px; background: #e3f2fd; color: #1976d2; font-size: 12px; margin-bottom: 10px; border-radius: 4px; }
         .cache-info.cached { background: #e8f5e8; color: #2e7d32; }
+        .folder-note { padding: 12px 15px; background: #fffde7; border-left: 4px solid #fbc02d; border-radius: 4px; margin-bottom: 10px; font-size: 14px; color: #555; }
+        .folder-note .note-text { white-space: pre-wrap; margin-bottom: 6px; }
+        .folder-note .readme { white-space: pre-wrap; font-family: monospace; font-size: 13px; max-height: 200px; overflow: auto; background: #fafafa; padding: 8px; border-radius: 4px; }
         .image-overlay { position: fixed; top: 0; left: 0; width: 100%; height: 100%; background: rgba(0,0,0,0.9); z-index: 1000; display: none; justify-content: center; align-items: center; cursor: pointer; }
         .image-preview { max-width: 90%; max-height: 90%; border-radius: 8px; box-shadow: 0 4px 20px rgba(0,0,0,0.5); }
         .image-overlay .close-btn { position: absolute; top: 20px; right: 20px; color: white; font-size: 30px; cursor: pointer; background: rgba(0,0,0,0.5); width: 40px; height: 40px; border-radius: 50%; display: flex; align-items: center; justify-content: center; }
@@ -437,7 +620,7 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 <body>
     <div class="container">
         <div class="header">
-            <div class="logo-container" onclick="resetSearch()">
+            <div class="logo-container" data-click="resetSearch">
                 <h1 class="logo">Everything Web Server</h1>
                 <div class="mode-indicator" id="modeIndicator">🔍 搜索模式</div>
             </div>
@@ -450,10 +633,46 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
                         <option value="200">200条</option>
                     </select>
                 </label>
+                <label>浏览排序：
+                    <select id="sortBy">
+                        <option value="name" selected>名称</option>
+                        <option value="size">大小</option>
+                        <option value="date">修改日期</option>
+                        <option value="type">类型</option>
+                        <option value="rating">评分</option>
+                        <option value="natural">自然顺序</option>
+                        <option value="relevance" title="只用于搜索结果，浏览文件夹时按名称">相关度</option>
+                    </select>
+                    <select id="sortOrder">
+                        <option value="asc" selected>升序</option>
+                        <option value="desc">降序</option>
+                    </select>
+                </label>
+                <label>视图：
+                    <select id="viewMode">
+                        <option value="list" selected>列表</option>
+                        <option value="compact">紧凑</option>
+                    </select>
+                </label>
+                <label>视频静音：
+                    <select id="muteAutoplay">
+                        <option value="auto" selected>按来源</option>
+                        <option value="always">总是</option>
+                        <option value="never">从不</option>
+                    </select>
+                </label>
+                <label><input type="checkbox" id="showHidden"> 显示隐藏文件</label>
+                <label><input type="checkbox" id="groupByFolder"> 搜索结果按文件夹分组</label>
+                <label title="容忍拼写错误，结果按相似度排序"><input type="checkbox" id="fuzzySearch"> 模糊搜索</label>
+                <label title="输入拼音首字母匹配中文文件名，如bjld"><input type="checkbox" id="pinyinSearch"> 拼音首字母</label>
+                <a href="#" data-click="showIndexStatus">🗃 索引状态</a>
+                <a href="#" data-click="showSyntaxHelp">❓ 搜索语法</a>
+                <a href="/selftest" target="_blank">🩺 诊断</a>
             </div>
             <div class="search-box">
                 <input type="text" class="search-input" id="searchInput" placeholder="搜索文件和文件夹..." autocomplete="off">
-                <button class="search-btn" onclick="performSearch()">搜索</button>
+                <div class="autocomplete-list" id="autocompleteList" style="display: none;"></div>
+                <button class="search-btn" data-click="performSearch">搜索</button>
             </div>
             
             <!-- 路径栏 -->
@@ -461,18 +680,46 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
                 <div class="path-input-container">
                     <span class="path-label">📂 路径:</span>
                     <input type="text" class="path-input" id="pathInput" placeholder="输入文件夹路径，如: C:\Users" autocomplete="off">
-                    <button class="path-btn" onclick="navigateToPath()">进入</button>
-                    <button class="path-btn-secondary" onclick="togglePathBar()">取消</button>
+                    <button class="path-btn" data-click="navigateToPath">进入</button>
+                    <button class="path-btn-secondary" data-click="togglePathBar">取消</button>
                 </div>
             </div>
         </div>
         
+        <!-- 选中条目 -->
+        <div class="selection-bar" id="selectionBar" style="display: none;">
+            <span id="selectionCount"></span>
+            <button class="btn btn-primary" data-click="downloadSelected">打包下载</button>
+            <button class="btn btn-secondary" data-click="clearSelection">清除选择</button>
+            <a href="/downloads" target="_blank">下载队列</a>
+        </div>
+        
+        <!-- 智能筛选 -->
+        <div class="favorites" id="smartFilters" style="display: none;"></div>
+        
+        <!-- 收藏栏 -->
+        <div class="favorites" id="favorites" style="display: none;"></div>
+        
+        <!-- 最近访问的文件夹 -->
+        <div class="favorites" id="recentFolders" style="display: none;"></div>
+        
         <div class="breadcrumb" id="breadcrumb" style="display: none;"></div>
         
         <div class="cache-info" id="cacheInfo" style="display: none;"></div>
         
+        <div class="folder-note" id="folderNote" style="display: none;"></div>
+        
+        <!-- 在搜索结果中筛选 -->
+        <div class="refine-bar" id="refineBar" style="display: none;">
+            <input type="text" id="refineInput" placeholder="在结果中筛选..." autocomplete="off">
+            <label><input type="checkbox" id="refineRegex"> 正则</label>
+            <label><input type="checkbox" id="refinePath"> 匹配完整路径</label>
+        </div>
+        
         <div class="search-stats" id="searchStats" style="display: none;"></div>
         
+        <div class="stats-panel" id="statsPanel" style="display: none;"></div>
+        
         <div class="results" id="results">
             <div class="no-results">输入关键词开始搜索</div>
         </div>
@@ -481,18 +728,107 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
     </div>
     
     <!-- 图片预览覆盖层 -->
-    <div class="image-overlay" id="imageOverlay" onclick="closeImagePreview()">
-        <div class="close-btn" onclick="closeImagePreview()">×</div>
-        <img class="image-preview" id="imagePreview" onclick="event.stopPropagation()">
+    <div class="image-overlay" id="imageOverlay" data-click="closeImagePreview">
+        <div class="close-btn" data-click="closeImagePreview">×</div>
+        <img class="image-preview" id="imagePreview" data-click="">
     </div>
 
-    <script>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        // 页面元素的data-click等属性对应的处理函数，参数从data-path等属性读取
+        bindPageActions({
+            resetSearch: () => resetSearch(),
+            showIndexStatus: () => { showIndexStatus(); return false; },
+            showSyntaxHelp: () => { showSyntaxHelp(); return false; },
+            showStats: () => { showStats(); return false; },
+            performSearch: () => performSearch(),
+            navigateToPath: () => navigateToPath(),
+            togglePathBar: () => togglePathBar(),
+            resetToSearch: () => resetToSearch(),
+            downloadSelected: () => downloadSelected(),
+            clearSelection: () => clearSelection(),
+            closeImagePreview: () => closeImagePreview(),
+            closeTextPreview: () => closeTextPreview(),
+            applyAutocomplete: (el, e) => applyAutocomplete(Number(el.dataset.item), e),
+            toggleFolderGroup: el => toggleFolderGroup(el),
+            browseFolder: el => { browseFolder(el.dataset.path); return false; },
+            openFile: el => handleFileClick(el.dataset.path, el.dataset.type, el.dataset.name),
+            rescanIndex: el => rescanIndex(el.dataset.mode),
+            goToPage: el => goToPage(Number(el.dataset.page)),
+            iconFallback: el => { el.style.display = 'none'; el.nextElementSibling.style.display = 'flex'; },
+            addFavorite: el => addFavorite(el.dataset.path),
+            rateFile: el => rateFile(el.dataset.path),
+            showImagePreview: el => showImagePreview(el.dataset.path),
+            showTextPreview: el => showTextPreview(el.dataset.path),
+            openTextInNewWindow: el => openTextInNewWindow(el.dataset.path),
+            runFileAction: el => runFileAction(el.dataset.id, el.dataset.path),
+            verifyChecksums: el => verifyChecksums(el.dataset.path, el),
+            toggleSelection: el => toggleSelection(el),
+            toggleSiblings: (el, e) => toggleSiblings(e, el.dataset.url),
+        });
+
+        const initialState = {{INITIAL_STATE}}; // 服务器端渲染的 /search 或 /browse 页面数据
         let currentPage = 1;
         let currentQuery = '';
         let totalPages = 1;
         let currentMode = 'search'; // 'search' 或 'browse'
         let currentPath = '';
         let browseHistory = []; // 浏览历史
+        let historyIndex = 0; // 当前页面在浏览器历史中的位置，用于判断popstate的方向
+        let prefs = { pageSize: 50, sortBy: 'name', sortOrder: 'asc', viewMode: 'list', muteAutoplay: 'auto', showHidden: false }; // 服务器端保存的偏好设置
+        let features = { ffmpeg: false, fileOperations: false }; // 服务器功能开关
+        let previewExtensions = new Set(); // 有预览提供者的扩展名，见 /preview/
+        let fileActionList = []; // 配置的外部命令操作，只有管理员可见
+        
+        // 加载初始化数据，把偏好设置应用到页面控件
+        async function loadBootstrap() {
+            try {
+                const response = await fetch('/api/bootstrap');
+                if (!response.ok) return;
+                const data = await response.json();
+                if (data.prefs) prefs = data.prefs;
+                if (data.features) features = data.features;
+                if (data.previewExtensions) previewExtensions = new Set(data.previewExtensions);
+                if (data.actions) fileActionList = data.actions;
+            } catch (error) {
+                console.error('加载偏好设置失败:', error);
+            }
+            applyPrefs();
+        }
+        
+        function applyPrefs() {
+            document.getElementById('pageSize').value = String(prefs.pageSize);
+            document.getElementById('sortBy').value = prefs.sortBy;
+            document.getElementById('sortOrder').value = prefs.sortOrder;
+            document.getElementById('viewMode').value = prefs.viewMode;
+            document.getElementById('muteAutoplay').value = prefs.muteAutoplay;
+            document.getElementById('showHidden').checked = !!prefs.showHidden;
+            document.getElementById('groupByFolder').checked = prefs.groupBy === 'folder';
+            document.getElementById('results').classList.toggle('compact', prefs.viewMode === 'compact');
+        }
+        
+        // 控件修改后保存到服务器
+        async function savePrefs() {
+            prefs = {
+                ...prefs, // 保留页面上没有控件的设置，如播放页的自动播放下一个
+                pageSize: parseInt(document.getElementById('pageSize').value, 10),
+                sortBy: document.getElementById('sortBy').value,
+                sortOrder: document.getElementById('sortOrder').value,
+                viewMode: document.getElementById('viewMode').value,
+                muteAutoplay: document.getElementById('muteAutoplay').value,
+                showHidden: document.getElementById('showHidden').checked,
+                groupBy: document.getElementById('groupByFolder').checked ? 'folder' : ''
+            };
+            document.getElementById('results').classList.toggle('compact', prefs.viewMode === 'compact');
+            try {
+                await fetch('/api/prefs', {
+                    method: 'PUT',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(prefs)
+                });
+            } catch (error) {
+                console.error('保存偏好设置失败:', error);
+            }
+        }
         
         document.getElementById('searchInput').addEventListener('keypress', function(e) {
             if (e.key === 'Enter') {
@@ -500,6 +836,91 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             }
         });
         
+        // 搜索框自动补全：搜索历史、语法关键字、扩展名和文件夹名
+        let autocompleteItems = [], autocompleteIndex = -1, autocompleteTimer = null;
+        const autocompleteIcons = { history: '🕘', syntax: '⌨️', extension: '📄', folder: '📁' };
+        
+        function hideAutocomplete() {
+            clearTimeout(autocompleteTimer);
+            autocompleteItems = [];
+            autocompleteIndex = -1;
+            document.getElementById('autocompleteList').style.display = 'none';
+        }
+        
+        function renderAutocomplete() {
+            const list = document.getElementById('autocompleteList');
+            if (autocompleteItems.length === 0) {
+                list.style.display = 'none';
+                return;
+            }
+            list.innerHTML = autocompleteItems.map((s, i) =>
+                '<div class="autocomplete-item' + (i === autocompleteIndex ? ' active' : '') + '" data-mousedown="applyAutocomplete" data-item="' + i + '">' +
+                '<span>' + (autocompleteIcons[s.kind] || '') + ' ' + escapeHtml(s.label) + '</span>' +
+                (s.detail ? '<span class="autocomplete-detail">' + escapeHtml(s.detail) + '</span>' : '') + '</div>').join('');
+            list.style.display = 'block';
+        }
+        
+        async function updateAutocomplete() {
+            const input = document.getElementById('searchInput');
+            const q = input.value;
+            try {
+                const response = await fetch('/api/autocomplete?q=' + encodeURIComponent(q));
+                const data = await response.json();
+                if (input.value !== q || document.activeElement !== input) return;
+                autocompleteItems = data.suggestions;
+                autocompleteIndex = -1;
+                renderAutocomplete();
+            } catch (error) {
+                console.log('自动补全失败:', error);
+            }
+        }
+        
+        // 历史记录和关键字补全后直接搜索；路径和未完成的函数继续输入
+        function applyAutocomplete(i, e) {
+            if (e) e.preventDefault();
+            const s = autocompleteItems[i];
+            const input = document.getElementById('searchInput');
+            input.value = s.text;
+            hideAutocomplete();
+            if (s.kind === 'history' || s.kind === 'extension') {
+                performSearch();
+            } else {
+                input.focus();
+                updateAutocomplete();
+            }
+        }
+        
+        document.getElementById('searchInput').addEventListener('input', function() {
+            clearTimeout(autocompleteTimer);
+            autocompleteTimer = setTimeout(updateAutocomplete, 150);
+        });
+        document.getElementById('searchInput').addEventListener('blur', hideAutocomplete);
+        document.getElementById('searchInput').addEventListener('keydown', function(e) {
+            if (autocompleteItems.length === 0) return;
+            if (e.key === 'ArrowDown' || e.key === 'ArrowUp') {
+                e.preventDefault();
+                const n = autocompleteItems.length;
+                if (e.key === 'ArrowDown') autocompleteIndex = (autocompleteIndex + 1) % n;
+                else autocompleteIndex = autocompleteIndex <= 0 ? n - 1 : autocompleteIndex - 1;
+                renderAutocomplete();
+            } else if (e.key === 'Enter' && autocompleteIndex >= 0) {
+                e.preventDefault();
+                applyAutocomplete(autocompleteIndex);
+            } else if (e.key === 'Enter' || e.key === 'Escape') {
+                hideAutocomplete();
+            }
+        });
+        
+        // 结果内筛选：输入停顿后重新请求第一页
+        let refineTimer = null;
+        document.getElementById('refineInput').addEventListener('input', function() {
+            clearTimeout(refineTimer);
+            refineTimer = setTimeout(() => performSearch(1, 'replace'), 300);
+        });
+        ['refineRegex', 'refinePath'].forEach(id => document.getElementById(id).addEventListener('change', () => {
+            if (document.getElementById('refineInput').value.trim()) performSearch(1, 'replace');
+        }));
+        
         // 为搜索框添加点击时的智能行为
         document.getElementById('searchInput').addEventListener('focus', function() {
             if (currentMode === 'browse') {
@@ -524,7 +945,9 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             }
         });
         
-        async function performSearch(page = 1) {
+        // nav为'history'时由浏览器后退/前进触发，不再写入浏览器历史
+        async function performSearch(page = 1, nav = 'push') {
+            hideAutocomplete();
             const searchInput = document.getElementById('searchInput');
             const pageSizeSelect = document.getElementById('pageSize');
             const resultsContainer = document.getElementById('results');
@@ -538,11 +961,39 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
                 return;
             }
             
-            const query = searchInput.value;
+            let query = searchInput.value;
+            if (document.getElementById('fuzzySearch').checked && query.trim() && !/^\s*fuzzy:/i.test(query)) {
+                query = 'fuzzy:' + query.trim();
+                searchInput.value = query;
+            }
+            if (document.getElementById('pinyinSearch').checked && query.trim() && !/^\s*(fuzzy|py):/i.test(query)) {
+                query = 'py:' + query.trim();
+                searchInput.value = query;
+            }
             const pageSize = pageSizeSelect.value;
+            // 自然顺序和相关度由服务器排序，大小、修改日期和名称降序由Everything排序，类型和评分只用于浏览
+            const searchSort = document.getElementById('sortBy').value;
+            const searchOrder = document.getElementById('sortOrder').value;
+            let sortParam = '';
+            if (searchSort === 'natural' || searchSort === 'relevance') {
+                sortParam = '&sort=' + searchSort;
+            } else if (searchSort === 'size' || searchSort === 'date' || (searchSort === 'name' && searchOrder === 'desc')) {
+                sortParam = '&sort=' + searchSort + '&order=' + searchOrder;
+            }
+            if (document.getElementById('groupByFolder').checked) sortParam += '&groupBy=folder';
             
             if (!query.trim()) return;
             
+            // 换了新的查询时清除结果内筛选
+            const refineInput = document.getElementById('refineInput');
+            if (query !== currentQuery) refineInput.value = '';
+            const refine = refineInput.value.trim();
+            
+            // 更新地址栏，搜索结果可以收藏或在新标签页打开
+            if (nav === 'push' && (currentMode !== 'search' || currentQuery !== query || currentPage !== page)) {
+                history.pushState({ query: query, page: page, idx: ++historyIndex }, '', '/search?q=' + encodeURIComponent(query) + (page > 1 ? '&page=' + page : '') + sortParam);
+            }
+            
             // 切换到搜索模式
             currentMode = 'search';
             currentQuery = query;
@@ -555,6 +1006,8 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             // 隐藏面包屑导航
             const breadcrumbContainer = document.getElementById('breadcrumb');
             if (breadcrumbContainer) breadcrumbContainer.style.display = 'none';
+            const folderNote = document.getElementById('folderNote');
+            if (folderNote) folderNote.style.display = 'none';
             
             resultsContainer.innerHTML = '<div class="loading">搜索中...</div>';
             if (searchStats) searchStats.style.display = 'none';
@@ -564,7 +1017,13 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             const startTime = Date.now();
             
             try {
-                const response = await fetch('/api/search?q=' + encodeURIComponent(query) + '&page=' + page + '&pageSize=' + pageSize);
+                let url = '/api/search?q=' + encodeURIComponent(query) + '&page=' + page + '&pageSize=' + pageSize + sortParam;
+                if (refine) {
+                    url = '/api/refine?q=' + encodeURIComponent(query) + '&filter=' + encodeURIComponent(refine) + '&page=' + page + '&pageSize=' + pageSize + sortParam +
+                        (document.getElementById('refineRegex').checked ? '&regex=1' : '') +
+                        (document.getElementById('refinePath').checked ? '&in=path' : '');
+                }
+                const response = await fetch(url);
                 
                 if (!response.ok) {
                     throw new Error('搜索请求失败: ' + response.status);
@@ -583,7 +1042,7 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
                 displayResults(data, responseTime);
             } catch (error) {
                 console.error('搜索错误:', error);
-                resultsContainer.innerHTML = '<div class="no-results">搜索出错: ' + error.message + '</div>';
+                resultsContainer.innerHTML = '<div class="no-results">搜索出错: ' + escapeHtml(error.message) + '</div>';
                 if (searchStats) searchStats.style.display = 'none';
                 if (cacheInfo) cacheInfo.style.display = 'none';
                 if (pagination) pagination.style.display = 'none';
@@ -626,41 +1085,154 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             const currentPage = data.page || 1;
             const totalPages = data.totalPages || 1;
             
-            statsContainer.innerHTML = '找到 <strong>' + totalCount + '</strong> 个结果，当前显示第 <strong>' + currentPage + '</strong> 页，共 <strong>' + totalPages + '</strong> 页';
+            statsContainer.innerHTML = (data.filter !== undefined ? '在 ' + data.baseCount + ' 个结果中筛选出 ' : '找到 ') + '<strong>' + totalCount + '</strong> 个结果，当前显示第 <strong>' + currentPage + '</strong> 页，共 <strong>' + totalPages + '</strong> 页' + statsLink;
+            document.getElementById('statsPanel').style.display = 'none';
             statsContainer.style.display = 'block';
             
             // 显示结果
             let html = '';
+            let currentGroup = null;
             data.results.forEach(file => {
                 // 检查file对象是否完整
                 if (!file || !file.path) {
                     return; // 跳过无效的file对象
                 }
                 
+                // 按文件夹分组时，在每个文件夹的第一个结果前插入可折叠的标题
+                const group = (data.groups || []).find(g => file.index >= g.start && file.index < g.start + g.count);
+                if (group && group !== currentGroup) {
+                    if (currentGroup) html += '</div>';
+                    currentGroup = group;
+                    html += '<div class="folder-group-header" data-click="toggleFolderGroup">';
+                    html += '<span class="group-arrow">▼</span> 📁 ' + escapeHtml(group.folder) + ' <span class="group-count">(' + group.count + ')</span>';
+                    html += ' <a href="' + escapeHtml(browseUrl(group.folder, 1)) + '" data-click="browseFolder" data-path="' + escapeHtml(group.folder) + '">打开文件夹</a>';
+                    html += '</div><div class="folder-group">';
+                }
+                
                 const icon = getFileIcon(file);
                 const size = formatFileSize(file.size || 0);
-                const actions = getFileActions(file);
+                const actions = getSelectBox(file) + getFileActions(file);
                 const fileName = file.name || '未知文件';
                 const fileType = file.type || 'file';
                 
-                html += '<div class="result-item">';
+                html += '<div class="result-item" data-index="' + file.index + '">';
                 html += icon;
                 html += '<div class="file-info">';
-                html += '<div class="file-name" onclick="handleFileClick(\'' + file.path.replace(/'/g, "\\'").replace(/\\/g, "\\\\") + '\', \'' + fileType + '\', \'' + fileName.replace(/'/g, "\\'") + '\')">' + fileName + '</div>';
-                html += '<div class="file-meta">' + file.path + ' • ' + size + ' • ' + (file.modified || '') + '</div>';
+                html += '<div class="file-name" data-click="openFile" data-path="' + escapeHtml(file.path) + '" data-type="' + escapeHtml(fileType) + '" data-name="' + escapeHtml(fileName) + '">' + escapeHtml(fileName) + '</div>';
+                html += '<div class="file-meta">' + escapeHtml(file.path) + (file.partial ? ' • ⏳ 文件信息获取超时' : ' • ' + size + ' • ' + escapeHtml(file.modified || '')) + formatMediaProps(file) + '</div>';
                 html += '</div>';
                 html += '<div class="file-actions">';
                 html += actions;
                 html += '</div>';
                 html += '</div>';
             });
+            if (currentGroup) html += '</div>';
             
             container.innerHTML = html;
+            activeIndex = -1;
             
             // 显示分页
             displayPagination(data);
         }
         
+        // 当前搜索结果或文件夹的统计（总大小、按类型、最大的文件）
+        const statsLink = ' <a href="#" data-click="showStats">📊 统计</a>';
+        
+        async function showStats() {
+            const panel = document.getElementById('statsPanel');
+            if (panel.style.display !== 'none') {
+                panel.style.display = 'none';
+                return;
+            }
+            panel.innerHTML = '统计中...';
+            panel.style.display = 'block';
+            const url = '/api/stats?' + (currentMode === 'browse' ? 'path=' + encodeURIComponent(currentPath) : 'q=' + encodeURIComponent(currentQuery));
+            try {
+                const response = await fetch(url);
+                if (!response.ok) throw new Error(await response.text());
+                const s = await response.json();
+                let html = '<strong>' + s.fileCount + '</strong> 个文件，<strong>' + s.folderCount + '</strong> 个文件夹，共 <strong>' + formatFileSize(s.totalSize) + '</strong>（用时' + s.elapsedMs + 'ms）';
+                if (s.oldest) {
+                    html += '<br>最旧: ' + escapeHtml(s.oldest.path) + '（' + s.oldest.modified + '）';
+                    html += '<br>最新: ' + escapeHtml(s.newest.path) + '（' + s.newest.modified + '）';
+                }
+                html += '<div class="stats-columns"><div><b>按类型</b>' + s.extensions.slice(0, 10).map(e =>
+                    '<div>' + escapeHtml(e.ext || '(无扩展名)') + ': ' + e.count + ' 个，' + formatFileSize(e.size) + '</div>').join('') + '</div>';
+                html += '<div><b>最大的文件</b>' + s.largest.slice(0, 10).map(f =>
+                    '<div>' + formatFileSize(f.size) + ' ' + escapeHtml(f.path) + '</div>').join('') + '</div></div>';
+                panel.innerHTML = html;
+            } catch (error) {
+                panel.innerHTML = '统计失败: ' + escapeHtml(error.message);
+            }
+        }
+        
+        // Everything索引状态，结果不全或过时时可以重新扫描
+        // 监视器等发出的通知，用浏览器通知显示（未授权时在标题栏提示）
+        function listenNotifications() {
+            if (!window.EventSource) return;
+            const source = new EventSource('/api/notifications/events');
+            source.addEventListener('notification', function(e) {
+                const n = JSON.parse(e.data);
+                if (window.Notification && Notification.permission === 'granted') {
+                    new Notification(n.title, { body: n.message });
+                } else {
+                    document.title = '🔔 ' + n.title;
+                    if (window.Notification && Notification.permission === 'default') Notification.requestPermission();
+                }
+            });
+        }
+        
+        async function showIndexStatus() {
+            const panel = document.getElementById('statsPanel');
+            panel.style.display = 'block';
+            panel.innerHTML = '查询索引状态...';
+            try {
+                const response = await fetch('/api/everything/status');
+                const s = await response.json();
+                let html = 'Everything ' + (s.running ? escapeHtml(s.version) + ' 正在运行' : '未运行') +
+                    '，索引' + (s.dbLoaded ? '已加载：' + s.totalFiles + ' 个文件，' + s.totalFolders + ' 个文件夹' : '未加载');
+                if (s.error) html += '<br>⚠️ ' + escapeHtml(s.error);
+                if (s.lastRescan) html += '<br>上次重新扫描: ' + s.lastRescan.time + '（' + (s.lastRescan.mode === 'rebuild' ? '重建' : '更新') + '）';
+                if (s.rescanMethod) {
+                    html += '<br><button class="btn btn-secondary" data-click="rescanIndex" data-mode="update">更新文件夹索引</button> ' +
+                        '<button class="btn btn-secondary" data-click="rescanIndex" data-mode="rebuild">重建索引</button>';
+                }
+                panel.innerHTML = html;
+            } catch (error) {
+                panel.innerHTML = '查询索引状态失败: ' + escapeHtml(error.message);
+            }
+        }
+        
+        async function showSyntaxHelp() {
+            const panel = document.getElementById('statsPanel');
+            panel.style.display = 'block';
+            try {
+                const response = await fetch('/api/syntax');
+                const data = await response.json();
+                const kinds = { operator: '运算符', modifier: '修饰符', function: '函数', macro: '宏', constant: '常量' };
+                panel.innerHTML = '<b>Everything搜索语法</b>' + (data.version ? '（' + escapeHtml(data.version) + '）' : '') +
+                    '<table class="syntax-table">' + data.items.map(item =>
+                        '<tr><td><code>' + escapeHtml(item.syntax) + '</code></td><td>' + (kinds[item.kind] || '') + '</td><td>' +
+                        escapeHtml(item.description) + '</td><td>' + (item.example ? '<code>' + escapeHtml(item.example) + '</code>' : '') + '</td></tr>').join('') +
+                    '</table>';
+            } catch (error) {
+                panel.innerHTML = '获取搜索语法失败: ' + escapeHtml(error.message);
+            }
+        }
+        
+        async function rescanIndex(mode) {
+            if (mode === 'rebuild' && !confirm('重建索引需要几分钟，期间搜索结果可能不完整。确定重建？')) return;
+            const response = await fetch('/api/everything/rescan?mode=' + mode, { method: 'POST' });
+            alert(response.ok ? '已开始重新扫描，搜索缓存已清除' : '重新扫描失败: ' + await response.text());
+        }
+        
+        function toggleFolderGroup(header) {
+            const body = header.nextElementSibling;
+            const collapsed = body.style.display !== 'none';
+            body.style.display = collapsed ? 'none' : '';
+            header.querySelector('.group-arrow').textContent = collapsed ? '▶' : '▼';
+        }
+        
         function displayPagination(data) {
             const container = document.getElementById('pagination');
             
@@ -686,37 +1258,46 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             let html = '';
             
             // 上一页按钮
-            html += '<button onclick="performSearch(' + (currentPage - 1) + ')" ' + (currentPage <= 1 ? 'disabled' : '') + '>上一页</button>';
+            html += '<button data-click="goToPage" data-page="' + (currentPage - 1) + '" ' + (currentPage <= 1 ? 'disabled' : '') + '>上一页</button>';
             
             // 页码按钮
             const startPage = Math.max(1, currentPage - 2);
             const endPage = Math.min(totalPages, currentPage + 2);
             
             if (startPage > 1) {
-                html += '<button onclick="performSearch(1)">1</button>';
+                html += '<button data-click="goToPage" data-page="1">1</button>';
                 if (startPage > 2) {
                     html += '<span>...</span>';
                 }
             }
             
             for (let i = startPage; i <= endPage; i++) {
-                html += '<button onclick="performSearch(' + i + ')" ' + (i === currentPage ? 'class="active"' : '') + '>' + i + '</button>';
+                html += '<button data-click="goToPage" data-page="' + i + '" ' + (i === currentPage ? 'class="active"' : '') + '>' + i + '</button>';
             }
             
             if (endPage < totalPages) {
                 if (endPage < totalPages - 1) {
                     html += '<span>...</span>';
                 }
-                html += '<button onclick="performSearch(' + totalPages + ')">' + totalPages + '</button>';
+                html += '<button data-click="goToPage" data-page="' + totalPages + '">' + totalPages + '</button>';
             }
             
             // 下一页按钮
-            html += '<button onclick="performSearch(' + (currentPage + 1) + ')" ' + (currentPage >= totalPages ? 'disabled' : '') + '>下一页</button>';
+            html += '<button data-click="goToPage" data-page="' + (currentPage + 1) + '" ' + (currentPage >= totalPages ? 'disabled' : '') + '>下一页</button>';
             
             container.innerHTML = html;
             container.style.display = 'block';
         }
         
+        // 根据当前模式翻页
+        function goToPage(page) {
+            if (currentMode === 'browse') {
+                browseFolder(currentPath, page);
+            } else {
+                performSearch(page);
+            }
+        }
+        
         function getFileIcon(file) {
             if (file.isDir) {
                 return '<div class="file-icon folder">📁</div>';
@@ -729,17 +1310,29 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             
             const ext = file.name.toLowerCase().split('.').pop();
             if (['mp4', 'mkv', 'avi', 'mov', 'wmv', 'flv', 'webm'].includes(ext)) {
-                return '<div class="file-icon video">🎬</div>';
+                return '<img src="/thumbnail/' + encodeURIComponent(file.path) + '" class="thumbnail" loading="lazy" data-error="iconFallback"><div class="file-icon video" style="display:none">🎬</div>';
+            }
+            if (ext === 'svg') {
+                return '<img src="/svg/' + encodeURIComponent(file.path) + '" class="thumbnail" loading="lazy" data-error="iconFallback"><div class="file-icon image" style="display:none">🖼️</div>';
             }
-            if (['jpg', 'jpeg', 'png', 'gif', 'bmp', 'webp'].includes(ext)) {
-                return '<img src="/thumbnail/' + encodeURIComponent(file.path) + '" class="thumbnail" onerror="this.style.display=\'none\'; this.nextElementSibling.style.display=\'flex\'"><div class="file-icon image" style="display:none">🖼️</div>';
+            if (['jpg', 'jpeg', 'png', 'gif', 'bmp', 'webp', 'avif'].includes(ext)) {
+                return '<img src="/thumbnail/' + encodeURIComponent(file.path) + '" class="thumbnail" loading="lazy" data-error="iconFallback"><div class="file-icon image" style="display:none">🖼️</div>';
             }
-            return '<div class="file-icon">📄</div>';
+            // 其他文件使用系统图标，获取失败时显示默认图标
+            return '<img src="/icon?size=32&path=' + encodeURIComponent(file.path) + '" class="shell-icon" loading="lazy" data-error="iconFallback"><div class="file-icon" style="display:none">📄</div>';
         }
         
         function getFileActions(file) {
+            // 快捷方式：打开、播放等操作作用于目标
+            if (file.linkType === 'shortcut' && file.linkTarget && !file.linkBroken) {
+                return getFileActions({ name: file.linkTarget.split(/[\\/]/).pop(), path: file.linkTarget, isDir: file.linkTargetDir });
+            }
+
+            const favoriteBtn = ' <button class="btn btn-secondary" title="收藏" data-click="addFavorite" data-path="' + escapeHtml(file.path) + '">☆</button>' +
+                ' <button class="btn btn-secondary" title="评分" data-click="rateFile" data-path="' + escapeHtml(file.path) + '">⭐</button>';
+            
             if (file.isDir) {
-                return '<a href="#" class="btn btn-primary" onclick="browseFolder(\'' + file.path.replace(/'/g, "\\'").replace(/\\/g, "\\\\") + '\')">打开</a>';
+                return '<a href="' + escapeHtml(browseUrl(file.path, 1)) + '" class="btn btn-primary" data-click="browseFolder" data-path="' + escapeHtml(file.path) + '">打开</a>' + favoriteBtn;
             }
             
             // 检查file.name是否存在
@@ -750,26 +1343,419 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             const ext = file.name.toLowerCase().split('.').pop();
             let actions = '<a href="/file/' + encodeURIComponent(file.path) + '?download=1" class="btn btn-secondary" download>下载</a>';
             
+            // 由预览提供者处理的文件
+            if (previewExtensions.has(ext)) {
+                actions = '<a href="/preview/' + encodeURIComponent(file.path) + '" class="btn btn-primary" target="_blank">预览</a> ' + actions;
+            }
+            // 3D模型
+            else if (['stl', 'obj', 'gltf', 'glb'].includes(ext)) {
+                actions = '<a href="/modelview/' + encodeURIComponent(file.path) + '" class="btn btn-primary" target="_blank">3D预览</a> ' + actions;
+            }
             // 视频文件
-            if (['mp4', 'mkv', 'avi', 'mov', 'wmv', 'flv', 'webm'].includes(ext)) {
+            else if (['mp4', 'mkv', 'avi', 'mov', 'wmv', 'flv', 'webm'].includes(ext)) {
                 actions = '<a href="/video/' + encodeURIComponent(file.path) + '" class="btn btn-primary" target="_blank">播放</a> ' + actions;
             }
             // 图片文件
-            else if (['jpg', 'jpeg', 'png', 'gif', 'bmp', 'webp'].includes(ext)) {
+            else if (['jpg', 'jpeg', 'png', 'gif', 'bmp', 'webp', 'avif', 'svg'].includes(ext)) {
                 let encodedPath = encodeURIComponent(file.path)
                     .replace(/'/g, '%27').replace(/\(/g, '%28').replace(/\)/g, '%29')
                     .replace(/%5C/g, '%5C'); // 确保反斜杠被编码
-                actions = '<button class="btn btn-primary" onclick="showImagePreview(\'' + file.path.replace(/'/g, "\\'").replace(/\\/g, "\\\\") + '\')">预览</button> <a href="/imageview/' + encodedPath + '" class="btn btn-info" target="_blank">新窗口</a> ' + actions;
+                actions = '<button class="btn btn-primary" data-click="showImagePreview" data-path="' + escapeHtml(file.path) + '">预览</button> <a href="/imageview/' + encodedPath + '" class="btn btn-info" target="_blank">新窗口</a> ' + actions;
             }
             // 文本文件
             else if (isTextFile(ext)) {
                 let encodedPath = encodeURIComponent(file.path)
                     .replace(/'/g, '%27').replace(/\(/g, '%28').replace(/\)/g, '%29')
                     .replace(/%5C/g, '%5C'); // 确保反斜杠被编码
-                actions = '<button class="btn btn-primary" onclick="showTextPreview(\'' + file.path.replace(/'/g, "\\'").replace(/\\/g, "\\\\") + '\')">预览</button> <a href="/textview/' + encodedPath + '" class="btn btn-info" target="_blank">新窗口</a> ' + actions;
+                actions = '<button class="btn btn-primary" data-click="showTextPreview" data-path="' + escapeHtml(file.path) + '">预览</button> <a href="/textview/' + encodedPath + '" class="btn btn-info" target="_blank">新窗口</a> ' + actions;
+            }
+            
+            // 配置的外部命令操作
+            fileActionList.filter(a => !a.extensions || a.extensions.some(e => e.toLowerCase().replace(/^\./, '') === ext)).forEach(a => {
+                actions += ' <button class="btn btn-secondary" data-click="runFileAction" data-id="' + escapeHtml(a.id) + '" data-path="' + escapeHtml(file.path) + '">' + escapeHtml(a.label) + '</button>';
+            });
+            // 有记录了该文件的校验文件，或本身是校验文件
+            if (file.checksumFile || isChecksumFile(file.name)) {
+                actions += ' <button class="btn btn-secondary" title="' + escapeHtml(file.checksumFile ? '按 ' + file.checksumFile.split(/[\\/]/).pop() + ' 校验' : '校验其中记录的文件') + '" data-click="verifyChecksums" data-path="' + escapeHtml(file.path) + '">校验</button>';
+            }
+            return actions + favoriteBtn;
+        }
+        
+        // 加载收藏列表
+        async function loadFavorites() {
+            try {
+                const response = await fetch('/api/favorites');
+                if (!response.ok) {
+                    throw new Error('获取收藏失败: ' + response.status);
+                }
+                displayFavorites(await response.json());
+            } catch (error) {
+                console.error('收藏加载错误:', error);
+            }
+        }
+        
+        function displayFavorites(data) {
+            const container = document.getElementById('favorites');
+            if (!container) return;
+            
+            if (!data || !data.favorites || data.favorites.length === 0) {
+                container.style.display = 'none';
+                return;
             }
             
-            return actions;
+            container.innerHTML = '<span style="color: #666;">⭐ 收藏:</span>';
+            data.favorites.forEach(fav => {
+                const item = document.createElement('span');
+                item.className = 'favorite-item';
+                item.title = fav.path;
+                item.textContent = (fav.isDir ? '📁 ' : '📄 ') + fav.name;
+                item.onclick = function() {
+                    if (fav.isDir) {
+                        browseFolder(fav.path);
+                    } else {
+                        handleFileClick(fav.path, fav.type, fav.name);
+                    }
+                };
+                
+                const remove = document.createElement('span');
+                remove.className = 'remove';
+                remove.textContent = '×';
+                remove.title = '取消收藏';
+                remove.onclick = function(e) {
+                    e.stopPropagation();
+                    removeFavorite(fav.id);
+                };
+                item.appendChild(remove);
+                container.appendChild(item);
+            });
+            container.style.display = 'flex';
+        }
+        
+        // 智能筛选：点击后按预设的查询、排序和视图搜索，自定义筛选可以删除
+        async function loadSmartFilters() {
+            try {
+                const response = await fetch('/api/smart-filters');
+                if (!response.ok) return;
+                const data = await response.json();
+                const container = document.getElementById('smartFilters');
+                container.innerHTML = '<span style="color: #666;">🔎 筛选:</span>';
+                (data.filters || []).forEach(filter => {
+                    const item = document.createElement('span');
+                    item.className = 'favorite-item smart-filter';
+                    item.title = (filter.description ? filter.description + '\n' : '') + filter.query;
+                    item.textContent = (filter.icon ? filter.icon + ' ' : '') + filter.name;
+                    item.onclick = function() {
+                        document.getElementById('searchInput').value = filter.query;
+                        if (filter.sort) document.getElementById('sortBy').value = filter.sort;
+                        if (filter.order) document.getElementById('sortOrder').value = filter.order;
+                        if (filter.viewMode && filter.viewMode !== prefs.viewMode) {
+                            document.getElementById('viewMode').value = filter.viewMode;
+                            savePrefs();
+                        }
+                        performSearch(1);
+                    };
+                    if (filter.user) {
+                        const remove = document.createElement('span');
+                        remove.className = 'remove';
+                        remove.textContent = '×';
+                        remove.title = '删除筛选';
+                        remove.onclick = function(e) {
+                            e.stopPropagation();
+                            removeUserFilter(filter.id, filter.name);
+                        };
+                        item.appendChild(remove);
+                    }
+                    container.appendChild(item);
+                });
+                
+                const save = document.createElement('span');
+                save.className = 'favorite-item smart-filter';
+                save.title = '把当前的搜索、排序和视图保存为筛选';
+                save.textContent = '＋ 保存当前搜索';
+                save.onclick = saveUserFilter;
+                container.appendChild(save);
+                container.style.display = 'flex';
+            } catch (error) {
+                console.error('智能筛选加载错误:', error);
+            }
+        }
+        
+        async function saveUserFilter() {
+            const query = document.getElementById('searchInput').value.trim();
+            if (!query) {
+                alert('请先输入搜索内容');
+                return;
+            }
+            const name = prompt('筛选名称：', query.length > 20 ? query.substring(0, 20) + '…' : query);
+            if (!name) return;
+            try {
+                const response = await fetch('/api/filters', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({
+                        name: name,
+                        template: query,
+                        sort: document.getElementById('sortBy').value,
+                        order: document.getElementById('sortOrder').value,
+                        viewMode: document.getElementById('viewMode').value
+                    })
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                loadSmartFilters();
+            } catch (error) {
+                alert('保存筛选失败: ' + error.message);
+            }
+        }
+        
+        async function removeUserFilter(id, name) {
+            if (!confirm('删除筛选“' + name + '”？')) return;
+            try {
+                const response = await fetch('/api/filters?id=' + encodeURIComponent(id), { method: 'DELETE' });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                loadSmartFilters();
+            } catch (error) {
+                alert('删除筛选失败: ' + error.message);
+            }
+        }
+        
+        // 仪表板：没有搜索时在结果区域显示最近的下载、文档等分区
+        async function loadDashboard() {
+            try {
+                const response = await fetch('/api/dashboard');
+                if (!response.ok) return;
+                const data = await response.json();
+                // 加载期间已经开始搜索或浏览时不覆盖结果
+                if (currentMode !== 'search' || currentQuery || !data.sections || data.sections.length === 0) return;
+                
+                const container = document.createElement('div');
+                container.className = 'dashboard';
+                data.sections.forEach(section => {
+                    const box = document.createElement('div');
+                    box.className = 'dashboard-section';
+                    const title = document.createElement('h3');
+                    title.textContent = (section.icon ? section.icon + ' ' : '') + section.title;
+                    if (section.query) {
+                        const more = document.createElement('a');
+                        more.href = '#';
+                        more.textContent = '全部' + (section.total ? ' (' + section.total + ')' : '');
+                        more.onclick = function(e) {
+                            e.preventDefault();
+                            document.getElementById('searchInput').value = section.query;
+                            performSearch(1);
+                        };
+                        title.appendChild(more);
+                    }
+                    box.appendChild(title);
+                    
+                    if (section.error || section.items.length === 0) {
+                        const empty = document.createElement('div');
+                        empty.className = 'dashboard-empty';
+                        empty.textContent = section.error ? '查询失败: ' + section.error : '没有文件';
+                        box.appendChild(empty);
+                    }
+                    section.items.forEach(file => {
+                        const item = document.createElement('div');
+                        item.className = 'dashboard-item';
+                        item.title = file.path;
+                        const name = document.createElement('span');
+                        name.className = 'name';
+                        name.textContent = (file.isDir ? '📁 ' : '') + file.name;
+                        const meta = document.createElement('span');
+                        meta.className = 'meta';
+                        meta.textContent = formatFileSize(file.size) + ' • ' + (file.modified || '').substring(0, 16);
+                        item.appendChild(name);
+                        item.appendChild(meta);
+                        item.onclick = function() {
+                            if (file.isDir) {
+                                browseFolder(file.path);
+                            } else {
+                                handleFileClick(file.path, file.type, file.name);
+                            }
+                        };
+                        box.appendChild(item);
+                    });
+                    container.appendChild(box);
+                });
+                
+                const results = document.getElementById('results');
+                results.innerHTML = '';
+                results.appendChild(container);
+            } catch (error) {
+                console.error('仪表板加载错误:', error);
+            }
+        }
+        
+        // 跨页保留的选中路径
+        const selectedPaths = new Set();
+        
+        function getSelectBox(file) {
+            return '<input type="checkbox" class="select-item" title="选择" data-path="' + escapeHtml(file.path) + '"' + (selectedPaths.has(file.path) ? ' checked' : '') + ' data-change="toggleSelection">';
+        }
+        
+        function toggleSelection(checkbox) {
+            if (checkbox.checked) {
+                selectedPaths.add(checkbox.dataset.path);
+            } else {
+                selectedPaths.delete(checkbox.dataset.path);
+            }
+            updateSelectionBar();
+        }
+        
+        function updateSelectionBar() {
+            const bar = document.getElementById('selectionBar');
+            document.getElementById('selectionCount').textContent = '已选择 ' + selectedPaths.size + ' 项';
+            bar.style.display = selectedPaths.size > 0 ? 'flex' : 'none';
+        }
+        
+        function clearSelection() {
+            selectedPaths.clear();
+            document.querySelectorAll('.select-item').forEach(cb => cb.checked = false);
+            updateSelectionBar();
+        }
+        
+        async function downloadSelected() {
+            if (selectedPaths.size === 0) return;
+            try {
+                const response = await fetch('/api/download', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ paths: Array.from(selectedPaths) })
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                const bundle = await response.json();
+                if (bundle.parts.length === 1) {
+                    window.location.href = bundle.parts[0].url;
+                } else {
+                    window.open('/downloads', '_blank');
+                }
+                clearSelection();
+            } catch (error) {
+                alert('打包下载失败: ' + error.message);
+            }
+        }
+        
+        async function rateFile(path) {
+            const input = prompt('请输入评分(1-5)，输入0取消评分：');
+            if (input === null) return;
+            const rating = parseInt(input, 10);
+            if (isNaN(rating) || rating < 0 || rating > 5) {
+                alert('评分必须是0-5之间的数字');
+                return;
+            }
+            try {
+                const response = await fetch('/api/ratings', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ path: path, rating: rating })
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                const data = await response.json();
+                alert('已评分，平均 ' + data.rating + ' 分（' + data.ratingCount + '人）');
+            } catch (error) {
+                alert('评分失败: ' + error.message);
+            }
+        }
+
+        // 在主机上执行配置的外部命令操作，完成后显示输出
+        async function runFileAction(id, path) {
+            const action = fileActionList.find(a => a.id === id);
+            if (!action || !confirm('在服务器上执行“' + action.label + '”？\n' + path)) return;
+            try {
+                const response = await fetch('/api/actions', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ id: id, path: path })
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                const result = await response.json();
+                const status = result.error ? '失败: ' + result.error : (result.success ? '完成' : '退出码 ' + result.exitCode);
+                const output = result.output.length > 2000 ? '…' + result.output.slice(-2000) : result.output;
+                alert(action.label + ' ' + status + '（' + result.elapsed.toFixed(1) + '秒）' + (output ? '\n\n' + output : ''));
+                if (currentMode === 'browse') browseFolder(currentPath, currentPage);
+            } catch (error) {
+                alert('执行失败: ' + error.message);
+            }
+        }
+
+        function isChecksumFile(name) {
+            const lower = name.toLowerCase();
+            return /\.(sha256|sha1|sha512|md5)(sum)?$|\.sfv$/.test(lower) || /^(sha256sums|sha1sums|sha512sums|md5sums|checksums)(\.txt)?$/.test(lower);
+        }
+
+        // 在服务器上按校验文件重新计算校验和，按钮上显示进度，结束后列出不符的文件
+        async function verifyChecksums(path, btn) {
+            const label = btn.textContent;
+            btn.disabled = true;
+            try {
+                const response = await fetch('/api/batch', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ operations: [{ op: 'verify', paths: [path] }] })
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                let job = await response.json();
+                await new Promise(resolve => {
+                    const events = new EventSource('/api/batch/events?id=' + encodeURIComponent(job.id));
+                    events.addEventListener('progress', e => {
+                        job = JSON.parse(e.data);
+                        btn.textContent = job.done + '/' + job.total;
+                    });
+                    events.addEventListener('end', () => { events.close(); resolve(); });
+                    events.onerror = () => { events.close(); resolve(); };
+                });
+                const failed = (job.items || []).filter(item => item.status === 'failed');
+                if (failed.length === 0) {
+                    alert('校验通过，共' + job.total + '个文件');
+                } else {
+                    alert('校验完成，' + failed.length + '/' + job.total + '个文件未通过：\n\n' +
+                        failed.slice(0, 50).map(item => item.path + '\n  ' + item.error).join('\n') + (failed.length > 50 ? '\n…' : ''));
+                }
+            } catch (error) {
+                alert('校验失败: ' + error.message);
+            } finally {
+                btn.textContent = label;
+                btn.disabled = false;
+            }
+        }
+
+        async function addFavorite(path) {
+            try {
+                const response = await fetch('/api/favorites', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ path: path })
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                displayFavorites(await response.json());
+            } catch (error) {
+                alert('收藏失败: ' + error.message);
+            }
+        }
+        
+        async function removeFavorite(id) {
+            try {
+                const response = await fetch('/api/favorites?id=' + encodeURIComponent(id), { method: 'DELETE' });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                displayFavorites(await response.json());
+            } catch (error) {
+                alert('取消收藏失败: ' + error.message);
+            }
         }
         
         // 检查是否为文本文件
@@ -800,6 +1786,19 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             return textExts.includes(ext);
         }
         
+        // Everything 1.5索引中的尺寸、时长和码率
+        function formatMediaProps(file) {
+            const parts = [];
+            if (file.width && file.height) parts.push(file.width + '×' + file.height);
+            if (file.duration) {
+                const t = Math.round(file.duration);
+                const h = Math.floor(t / 3600), m = Math.floor(t % 3600 / 60), sec = t % 60;
+                parts.push((h ? h + ':' + String(m).padStart(2, '0') : m) + ':' + String(sec).padStart(2, '0'));
+            }
+            if (file.bitrate) parts.push(file.bitrate + ' kbps');
+            return parts.length ? ' • 🎞 ' + parts.join(' ') : '';
+        }
+        
         function formatFileSize(bytes) {
             if (bytes === 0) return '0 B';
             const k = 1024;
@@ -813,9 +1812,13 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             
             if (type === 'folder') {
                 browseFolder(path);
+            } else if (previewExtensions.has(name.toLowerCase().split('.').pop())) {
+                window.open('/preview/' + encodeURIComponent(path), '_blank');
+            } else if (['stl', 'obj', 'gltf', 'glb'].includes(name.toLowerCase().split('.').pop())) {
+                window.open('/modelview/' + encodeURIComponent(path), '_blank');
             } else if (type === 'video') {
                 window.open('/video/' + encodeURIComponent(path), '_blank');
-            } else if (type === 'image') {
+            } else if (type === 'image' || name.toLowerCase().endsWith('.svg')) {
                 showImagePreview(path);
             } else {
                 // 检查是否为文本文件
@@ -833,7 +1836,15 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             const overlay = document.getElementById('imageOverlay');
             const preview = document.getElementById('imagePreview');
             
-            preview.src = '/file/' + encodeURIComponent(path);
+            // SVG在服务器上净化后显示；有ffmpeg时按屏幕大小请求缩放后的图片，GIF保留动画
+            if (/\.svg$/i.test(path)) {
+                preview.src = '/svg/' + encodeURIComponent(path);
+            } else if (features.ffmpeg && !/\.gif$/i.test(path)) {
+                const ratio = window.devicePixelRatio || 1;
+                preview.src = '/resize/' + encodeURIComponent(path) + '?w=' + Math.round(window.innerWidth * ratio) + '&h=' + Math.round(window.innerHeight * ratio);
+            } else {
+                preview.src = '/file/' + encodeURIComponent(path);
+            }
             overlay.style.display = 'flex';
             
             // 添加ESC键关闭功能
@@ -887,20 +1898,20 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             previewContainer.innerHTML = '<div style="padding: 20px; border-bottom: 1px solid #333; color: white;">' +
                 '<div style="display: flex; justify-content: space-between; align-items: center;">' +
                     '<div>' +
-                        '<h3 style="color: #4FC3F7; margin: 0 0 5px 0;">' + data.name + '</h3>' +
+                        '<h3 style="color: #4FC3F7; margin: 0 0 5px 0;">' + escapeHtml(data.name) + '</h3>' +
                         '<div style="font-size: 12px; color: #888;">' +
                             '大小: ' + formatFileSize(data.size) + ' • ' +
                             '行数: ' + data.lines + ' • ' +
-                            '编码: ' + data.encoding +
+                            '编码: ' + escapeHtml(data.encoding) +
                             (isLongFile ? ' • 预览前500行' : '') +
                         '</div>' +
                     '</div>' +
                     '<div>' +
-                        '<button onclick="openTextInNewWindow(\'' + data.path.replace(/\\/g, '\\\\').replace(/'/g, "\\'") + '\')" ' +
+                        '<button data-click="openTextInNewWindow" data-path="' + escapeHtml(data.path) + '" ' +
                                 'style="padding: 8px 16px; background: #2196F3; color: white; border: none; border-radius: 4px; cursor: pointer; margin-right: 10px;">' +
                             '新窗口' +
                         '</button>' +
-                        '<button onclick="closeTextPreview()" ' +
+                        '<button data-click="closeTextPreview" ' +
                                 'style="padding: 8px 16px; background: #666; color: white; border: none; border-radius: 4px; cursor: pointer;">' +
                             '关闭' +
                         '</button>' +
@@ -959,10 +1970,9 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
         }
         
         // HTML转义函数
+        // 转义HTML，结果也可以放在属性值中（引号同样转义）
         function escapeHtml(text) {
-            const div = document.createElement('div');
-            div.textContent = text;
-            return div.innerHTML;
+            return String(text).replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
         }
         
         function resetSearch() {
@@ -976,10 +1986,11 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             
             // 重置搜索输入框
             if (searchInput) searchInput.value = '';
-            if (pageSize) pageSize.value = '50';
+            if (pageSize) pageSize.value = String(prefs.pageSize);
             
             // 清空结果显示
             if (results) results.innerHTML = '<div class="no-results">输入关键词开始搜索</div>';
+            loadDashboard();
             if (searchStats) searchStats.style.display = 'none';
             if (cacheInfo) cacheInfo.style.display = 'none';
             if (pagination) pagination.style.display = 'none';
@@ -995,8 +2006,14 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             console.log('搜索已重置');
         }
         
-        async function browseFolder(path) {
-            console.log('浏览文件夹:', path);
+        // nav为'history'时由浏览器后退/前进触发，不再写入浏览器历史，也不记录到服务器端的浏览历史
+        async function browseFolder(path, page = 1, nav = 'push') {
+            console.log('浏览文件夹:', path, '页码:', page);
+            
+            // 更新地址栏，文件夹可以收藏或在新标签页打开
+            if (nav === 'push' && (currentMode !== 'browse' || currentPath !== path || currentPage !== page)) {
+                history.pushState({ path: path, page: page, idx: ++historyIndex }, '', browseUrl(path, page));
+            }
             
             // 清空搜索框并切换到浏览模式
             const searchInput = document.getElementById('searchInput');
@@ -1007,6 +2024,7 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             currentMode = 'browse';
             currentPath = path;
             currentQuery = '';
+            currentPage = page;
             
             // 更新模式指示器
             updateModeIndicator();
@@ -1031,7 +2049,11 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             const startTime = Date.now();
             
             try {
-                const response = await fetch('/api/browse?path=' + encodeURIComponent(path));
+                const pageSize = document.getElementById('pageSize').value;
+                const sortBy = document.getElementById('sortBy').value;
+                const sortOrder = document.getElementById('sortOrder').value;
+                const showHidden = document.getElementById('showHidden').checked;
+                const response = await fetch('/api/browse?path=' + encodeURIComponent(path) + '&page=' + page + '&pageSize=' + pageSize + '&sort=' + sortBy + '&order=' + sortOrder + '&showHidden=' + showHidden + (nav === 'history' ? '&history=0' : ''));
                 
                 if (!response.ok) {
                     throw new Error('浏览请求失败: ' + response.status);
@@ -1045,7 +2067,7 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             } catch (error) {
                 console.error('浏览错误:', error);
                 if (resultsContainer) {
-                    resultsContainer.innerHTML = '<div class="no-results">浏览失败: ' + error.message + '</div>';
+                    resultsContainer.innerHTML = '<div class="no-results">浏览失败: ' + escapeHtml(error.message) + '</div>';
                 }
                 if (searchStats) searchStats.style.display = 'none';
                 if (cacheInfo) cacheInfo.style.display = 'none';
@@ -1069,17 +2091,25 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             // 显示面包屑导航
             displayBreadcrumb(data);
             
+            // 显示文件夹说明
+            displayFolderNote(data.annotation);
+            
             // 显示文件夹信息
-            cacheContainer.innerHTML = '📁 文件夹浏览 (' + responseTime + 'ms) - 当前位置: ' + data.currentPath;
+            cacheContainer.innerHTML = '📁 文件夹浏览 (' + responseTime + 'ms) - 当前位置: ' + escapeHtml(data.currentPath);
             cacheContainer.className = 'cache-info';
             cacheContainer.style.display = 'block';
             
             // 显示文件夹统计
-            statsContainer.innerHTML = '找到 <strong>' + data.count + '</strong> 个项目';
+            statsContainer.innerHTML = '找到 <strong>' + (data.totalCount || 0) + '</strong> 个项目，当前显示第 <strong>' + (data.page || 1) + '</strong> 页，共 <strong>' + (data.totalPages || 1) + '</strong> 页' + statsLink +
+                ' <a href="/du?path=' + encodeURIComponent(currentPath) + '" target="_blank">🗂 磁盘占用</a>' +
+                ' <a href="/cleanup?path=' + encodeURIComponent(currentPath) + '" target="_blank">🧹 清理建议</a>' +
+                ' <a href="/timeline?path=' + encodeURIComponent(currentPath) + '" target="_blank">📅 时间线</a>' +
+                ' <a href="/map?path=' + encodeURIComponent(currentPath) + '" target="_blank">🗺 地图</a>';
+            document.getElementById('statsPanel').style.display = 'none';
             statsContainer.style.display = 'block';
             
-            // 隐藏分页（文件夹浏览不需要分页）
-            if (paginationContainer) paginationContainer.style.display = 'none';
+            // 显示分页
+            displayPagination(data);
             
             // 检查data和data.results是否存在
             if (!data || !data.results || data.results.length === 0) {
@@ -1092,25 +2122,18 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             
             // 如果可以返回上级，添加返回上级按钮
             if (data.canGoUp && data.parentPath) {
-                html += '<div class="result-item">';
+                html += '<div class="result-item parent-item" data-index="-1">';
                 html += '<div class="file-icon folder">↩️</div>';
                 html += '<div class="file-info">';
-                html += '<div class="file-name" onclick="browseFolder(\'' + data.parentPath.replace(/'/g, "\\'").replace(/\\/g, "\\\\") + '\')">..</div>';
+                html += '<div class="file-name" data-click="browseFolder" data-path="' + escapeHtml(data.parentPath) + '">..</div>';
                 html += '<div class="file-meta">返回上级目录</div>';
                 html += '</div>';
                 html += '<div class="file-actions">';
-                html += '<button class="btn btn-primary" onclick="browseFolder(\'' + data.parentPath.replace(/'/g, "\\'").replace(/\\/g, "\\\\") + '\')">进入</button>';
+                html += '<button class="btn btn-primary" data-click="browseFolder" data-path="' + escapeHtml(data.parentPath) + '">进入</button>';
                 html += '</div>';
                 html += '</div>';
             }
             
-            // 先显示文件夹，再显示文件
-            data.results.sort((a, b) => {
-                if (a.isDir && !b.isDir) return -1;
-                if (!a.isDir && b.isDir) return 1;
-                return a.name.localeCompare(b.name, 'zh-CN');
-            });
-            
             data.results.forEach(file => {
                 if (!file || !file.path) {
                     return;
@@ -1118,15 +2141,15 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
                 
                 const icon = getFileIcon(file);
                 const size = formatFileSize(file.size || 0);
-                const actions = getFileActions(file);
+                const actions = getSelectBox(file) + getFileActions(file);
                 const fileName = file.name || '未知文件';
                 const fileType = file.type || 'file';
                 
-                html += '<div class="result-item">';
+                html += '<div class="result-item' + (file.hidden || file.system ? ' hidden-file' : '') + '" data-index="' + file.index + '">';
                 html += icon;
                 html += '<div class="file-info">';
-                html += '<div class="file-name" onclick="handleFileClick(\'' + file.path.replace(/'/g, "\\'").replace(/\\/g, "\\\\") + '\', \'' + fileType + '\', \'' + fileName.replace(/'/g, "\\'") + '\')">' + fileName + '</div>';
-                html += '<div class="file-meta">' + file.path + ' • ' + size + ' • ' + (file.modified || '') + '</div>';
+                html += '<div class="file-name" data-click="openFile" data-path="' + escapeHtml(file.path) + '" data-type="' + escapeHtml(fileType) + '" data-name="' + escapeHtml(fileName) + '">' + escapeHtml(fileName) + '</div>';
+                html += '<div class="file-meta">' + escapeHtml(file.path) + ' • ' + size + ' • ' + escapeHtml(file.modified || '') + (file.linkType ? ' • 🔗 ' + escapeHtml(file.linkType) + (file.linkTarget ? ' → ' + escapeHtml(file.linkTarget) : '') + (file.linkBroken ? '（目标不存在）' : '') : '') + formatMediaProps(file) + (file.description ? ' • 💬 ' + escapeHtml(file.description) : '') + (file.tags ? ' • 🏷️ ' + file.tags.map(escapeHtml).join(', ') : '') + (file.ratingCount ? ' • ⭐ ' + file.rating + ' (' + file.ratingCount + ')' : '') + '</div>';
                 html += '</div>';
                 html += '<div class="file-actions">';
                 html += actions;
@@ -1135,6 +2158,28 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             });
             
             container.innerHTML = html;
+            activeIndex = -1;
+        }
+        
+        function displayFolderNote(annotation) {
+            const container = document.getElementById('folderNote');
+            if (!container) return;
+            
+            if (!annotation) {
+                container.style.display = 'none';
+                return;
+            }
+            
+            let html = '';
+            if (annotation.note) {
+                html += '<div class="note-text">📝 ' + escapeHtml(annotation.note) + '</div>';
+            }
+            if (annotation.readme) {
+                html += '<div style="color: #999; font-size: 12px; margin-bottom: 4px;">📖 ' + escapeHtml(annotation.readmeName) + '</div>';
+                html += '<div class="readme">' + escapeHtml(annotation.readme) + '</div>';
+            }
+            container.innerHTML = html;
+            container.style.display = 'block';
         }
         
         function displayBreadcrumb(data) {
@@ -1152,21 +2197,74 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
                 
                 // 如果是当前路径，不加链接
                 if (part.path === data.currentPath) {
-                    html += '<strong>' + part.name + '</strong>';
+                    html += '<strong>' + escapeHtml(part.name) + '</strong>';
                 } else {
-                    html += '<a href="#" onclick="browseFolder(\'' + part.path.replace(/'/g, "\\'").replace(/\\/g, "\\\\") + '\')">' + part.name + '</a>';
+                    html += '<a href="' + escapeHtml(browseUrl(part.path, 1)) + '" data-click="browseFolder" data-path="' + escapeHtml(part.path) + '">' + escapeHtml(part.name) + '</a>';
                 }
+                
+                // 同级文件夹下拉按钮
+                html += '<span class="sibling-toggle" data-click="toggleSiblings" data-url="' + escapeHtml(part.siblingsUrl) + '">▾</span>';
             });
             
             // 添加回到搜索和输入路径的按钮
-            html += ' <button style="margin-left: 15px; padding: 4px 8px; background: #2196F3; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 12px;" onclick="togglePathBar()">输入路径</button>';
-            html += ' <button style="margin-left: 5px; padding: 4px 8px; background: #4CAF50; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 12px;" onclick="resetToSearch()">回到搜索</button>';
+            html += ' <button style="margin-left: 15px; padding: 4px 8px; background: #2196F3; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 12px;" data-click="togglePathBar">输入路径</button>';
+            html += ' <button style="margin-left: 5px; padding: 4px 8px; background: #4CAF50; color: white; border: none; border-radius: 3px; cursor: pointer; font-size: 12px;" data-click="resetToSearch">回到搜索</button>';
             
             breadcrumbContainer.innerHTML = html;
             breadcrumbContainer.style.display = 'block';
         }
         
+        // 显示同级文件夹下拉菜单
+        async function toggleSiblings(event, siblingsUrl) {
+            event.stopPropagation();
+            closeSiblingsMenu();
+            
+            try {
+                const showHidden = document.getElementById('showHidden').checked;
+                const response = await fetch(siblingsUrl + '&showHidden=' + showHidden);
+                if (!response.ok) {
+                    throw new Error('获取同级文件夹失败: ' + response.status);
+                }
+                const data = await response.json();
+                
+                const menu = document.createElement('div');
+                menu.id = 'siblingsMenu';
+                menu.className = 'siblings-menu';
+                menu.style.left = event.pageX + 'px';
+                menu.style.top = (event.pageY + 10) + 'px';
+                
+                if (!data.siblings || data.siblings.length === 0) {
+                    menu.innerHTML = '<div class="sibling-item">没有同级文件夹</div>';
+                }
+                (data.siblings || []).forEach(sibling => {
+                    const item = document.createElement('div');
+                    item.className = 'sibling-item' + (sibling.current ? ' current' : '');
+                    item.textContent = '📁 ' + sibling.name;
+                    item.onclick = function() {
+                        closeSiblingsMenu();
+                        browseFolder(sibling.path);
+                    };
+                    menu.appendChild(item);
+                });
+                
+                document.body.appendChild(menu);
+                document.addEventListener('click', closeSiblingsMenu, { once: true });
+            } catch (error) {
+                console.error('同级文件夹错误:', error);
+            }
+        }
+        
+        function closeSiblingsMenu() {
+            const menu = document.getElementById('siblingsMenu');
+            if (menu) {
+                menu.remove();
+            }
+        }
+        
         function resetToSearch() {
+            if (location.pathname !== '/') {
+                history.pushState({ idx: ++historyIndex }, '', '/');
+            }
             currentMode = 'search';
             currentPath = '';
             currentQuery = '';
@@ -1188,6 +2286,9 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             const indicator = document.getElementById('modeIndicator');
             if (!indicator) return;
             
+            const refineBar = document.getElementById('refineBar');
+            if (refineBar) refineBar.style.display = currentMode === 'search' ? 'flex' : 'none';
+            
             if (currentMode === 'browse') {
                 indicator.textContent = '📁 浏览模式 - ' + (currentPath.length > 50 ? '...' + currentPath.slice(-50) : currentPath);
                 indicator.className = 'mode-indicator browse-mode';
@@ -1231,8 +2332,208 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
             browseFolder(path);
         }
         
+        // 加载最近访问的文件夹
+        async function loadRecentFolders() {
+            const container = document.getElementById('recentFolders');
+            if (!container) return;
+            try {
+                const response = await fetch('/api/history/browse');
+                if (!response.ok) return;
+                const data = await response.json();
+                if (!data.recent || data.recent.length === 0) {
+                    container.style.display = 'none';
+                    return;
+                }
+                container.innerHTML = '<span style="color: #666;">🕘 最近访问:</span>';
+                data.recent.slice(0, 10).forEach(entry => {
+                    const item = document.createElement('span');
+                    item.className = 'favorite-item';
+                    item.title = entry.path + ' (' + entry.visited + ')';
+                    item.textContent = '📁 ' + (entry.path.replace(/[\\/]+$/, '').split(/[\\/]/).pop() || entry.path);
+                    item.onclick = function() { browseFolder(entry.path); };
+                    container.appendChild(item);
+                });
+                container.style.display = 'flex';
+            } catch (error) {
+                console.error('加载最近访问失败:', error);
+            }
+        }
+        
+        function browseUrl(path, page) {
+            return '/browse?path=' + encodeURIComponent(path) + (page > 1 ? '&page=' + page : '');
+        }
+        
+        // 显示服务器端嵌入页面的搜索或浏览结果
+        function applyInitialState() {
+            if (!initialState) return;
+            const resultsContainer = document.getElementById('results');
+            currentPage = initialState.page || 1;
+            
+            if (initialState.mode === 'search') {
+                currentMode = 'search';
+                currentQuery = initialState.query;
+                document.getElementById('searchInput').value = initialState.query;
+                history.replaceState({ query: currentQuery, page: currentPage, idx: historyIndex }, '', location.href);
+                updateModeIndicator();
+                if (initialState.search) {
+                    displayResults(initialState.search, 0);
+                } else {
+                    resultsContainer.innerHTML = '<div class="no-results">' + escapeHtml(initialState.error || '搜索出错') + '</div>';
+                }
+            } else if (initialState.mode === 'browse') {
+                currentMode = 'browse';
+                currentPath = initialState.path;
+                history.replaceState({ path: currentPath, page: currentPage, idx: historyIndex }, '', location.href);
+                updateModeIndicator();
+                if (initialState.browse) {
+                    displayBrowseResults(initialState.browse, 0);
+                } else {
+                    resultsContainer.innerHTML = '<div class="no-results">浏览失败: ' + escapeHtml(initialState.error || '') + '</div>';
+                }
+            }
+        }
+        
+        // 浏览器后退/前进：恢复对应的文件夹、搜索结果或首页，并同步服务器端的浏览历史
+        window.addEventListener('popstate', function(e) {
+            const state = e.state || {};
+            const idx = typeof state.idx === 'number' ? state.idx : 0;
+            const action = idx < historyIndex ? 'back' : 'forward';
+            historyIndex = idx;
+            
+            if (state.path) {
+                // 同一文件夹内翻页不属于服务器端的浏览历史
+                if (state.path !== currentPath) {
+                    fetch('/api/history/browse', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ action: action })
+                    }).catch(() => {});
+                }
+                browseFolder(state.path, state.page || 1, 'history');
+            } else if (state.query) {
+                document.getElementById('searchInput').value = state.query;
+                performSearch(state.page || 1, 'history');
+            } else {
+                resetToSearch();
+                loadRecentFolders();
+            }
+        });
+        
+        // 键盘导航：按键与操作的对应关系来自 /api/shortcuts
+        let shortcutMap = {};
+        let typeAheadResetMs = 1000;
+        let typeAheadBuffer = '';
+        let typeAheadTime = 0;
+        let activeIndex = -1; // 当前选中项在结果列表中的位置（DOM顺序）
+        
+        async function loadShortcuts() {
+            try {
+                const response = await fetch('/api/shortcuts');
+                if (!response.ok) return;
+                const data = await response.json();
+                shortcutMap = {};
+                (data.shortcuts || []).forEach(s => s.keys.forEach(key => { shortcutMap[key] = s; }));
+                typeAheadResetMs = data.typeAheadResetMs || typeAheadResetMs;
+            } catch (error) {
+                console.error('加载快捷键失败:', error);
+            }
+        }
+        
+        function getResultItems() {
+            return Array.from(document.querySelectorAll('#results .result-item[data-index]'));
+        }
+        
+        function setActiveItem(index) {
+            const items = getResultItems();
+            if (items.length === 0) return;
+            activeIndex = Math.max(0, Math.min(index, items.length - 1));
+            items.forEach((item, i) => item.classList.toggle('active', i === activeIndex));
+            items[activeIndex].scrollIntoView({ block: 'nearest' });
+        }
+        
+        function runShortcut(action) {
+            const items = getResultItems();
+            const active = items[activeIndex];
+            switch (action) {
+                case 'next': setActiveItem(activeIndex + 1); break;
+                case 'prev': setActiveItem(activeIndex - 1); break;
+                case 'first': setActiveItem(0); break;
+                case 'last': setActiveItem(items.length - 1); break;
+                case 'open':
+                    if (active) active.querySelector('.file-name').click();
+                    break;
+                case 'toggleSelect': {
+                    const box = active && active.querySelector('.select-item');
+                    if (box) {
+                        box.checked = !box.checked;
+                        toggleSelection(box);
+                    }
+                    break;
+                }
+                case 'parent': {
+                    const parent = document.querySelector('#results .parent-item .file-name');
+                    if (currentMode === 'browse' && parent) parent.click();
+                    break;
+                }
+                case 'nextPage':
+                    if (currentPage < totalPages) goToPage(currentPage + 1);
+                    break;
+                case 'prevPage':
+                    if (currentPage > 1) goToPage(currentPage - 1);
+                    break;
+                case 'focusSearch': document.getElementById('searchInput').focus(); break;
+                case 'blur':
+                    if (document.activeElement) document.activeElement.blur();
+                    break;
+            }
+        }
+        
+        // 按名称前缀跳转到匹配的条目
+        function typeAhead(ch) {
+            const now = Date.now();
+            typeAheadBuffer = (now - typeAheadTime > typeAheadResetMs ? '' : typeAheadBuffer) + ch.toLowerCase();
+            typeAheadTime = now;
+            const items = getResultItems();
+            const index = items.findIndex(item => item.querySelector('.file-name').textContent.toLowerCase().startsWith(typeAheadBuffer));
+            if (index >= 0) setActiveItem(index);
+        }
+        
+        document.addEventListener('keydown', function(e) {
+            if (e.ctrlKey || e.metaKey || e.altKey) return;
+            const shortcut = shortcutMap[e.key];
+            const tag = e.target.tagName;
+            if (tag === 'INPUT' || tag === 'TEXTAREA' || tag === 'SELECT') {
+                // 输入框中只响应离开输入框的快捷键
+                if (shortcut && shortcut.action === 'blur') runShortcut('blur');
+                return;
+            }
+            if (shortcut) {
+                e.preventDefault();
+                runShortcut(shortcut.action);
+            } else if (e.key.length === 1) {
+                typeAhead(e.key);
+            }
+        });
+        
         // 为路径输入框添加回车键支持
         document.addEventListener('DOMContentLoaded', function() {
+            loadShortcuts();
+            loadBootstrap();
+            listenNotifications();
+            loadSmartFilters();
+            loadFavorites();
+            loadRecentFolders();
+            
+            applyInitialState();
+            if (!initialState || !initialState.mode) loadDashboard();
+            
+            ['pageSize', 'sortBy', 'sortOrder', 'viewMode', 'muteAutoplay', 'showHidden', 'groupByFolder'].forEach(function(id) {
+                document.getElementById(id).addEventListener('change', savePrefs);
+            });
+            document.getElementById('groupByFolder').addEventListener('change', function() {
+                if (currentMode === 'search') performSearch(currentPage, 'replace');
+            });
+            
             const pathInput = document.getElementById('pathInput');
             if (pathInput) {
                 pathInput.addEventListener('keypress', function(e) {
@@ -1249,43 +2550,51 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 </body>
 </html>`
 
+	state := []byte("null")
+	if initial != nil {
+		// json.Marshal会转义<、>和&，嵌入script标签中是安全的
+		if data, err := json.Marshal(initial); err == nil {
+			state = data
+		}
+	}
+	// 先替换脚本中的占位符，标题中出现占位符文本时不会被误替换
+	tmpl = strings.Replace(tmpl, "{{INITIAL_STATE}}", string(state), 1)
+	tmpl = strings.Replace(tmpl, "{{TITLE}}", html.EscapeString(title), 1)
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Write([]byte(tmpl))
 }
 
-// 视频播放器页面处理器
-func videoPlayerHandler(w http.ResponseWriter, r *http.Request) {
-	filePath := r.URL.Path[7:] // 去掉 "/video/" 前缀
-
-	// 多次URL解码以确保正确处理
-	for i := 0; i < 3; i++ {
-		if decoded, err := url.QueryUnescape(filePath); err == nil {
-			filePath = decoded
-		} else {
-			break
-		}
-	}
-
-	// 替换正斜杠为反斜杠（Windows路径）
-	filePath = strings.ReplaceAll(filePath, "/", "\\")
+// 视频播放器页面处理器
+func videoPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	filePath := r.URL.Path[7:] // 去掉 "/video/" 前缀
+
+	// URL解码并转换为Windows路径
+	filePath = decodeRequestPath(filePath)
 
 	// 检测访问来源，决定音频策略
 	referer := r.Header.Get("Referer")
-	muteByDefault := true // 默认静音
+	fromSearchPage := false
 	accessSource := "直接访问"
 
 	if referer != "" {
 		// 检查是否来自搜索页面
-		if strings.Contains(referer, r.Host) && (strings.Contains(referer, "/?") || strings.Contains(referer, "/search") || referer == "http://"+r.Host+"/" || referer == "https://"+r.Host+"/") {
-			muteByDefault = false // 从搜索页面来的，不静音
+		if strings.Contains(referer, r.Host) && (strings.Contains(referer, "/?") || strings.Contains(referer, "/search") || strings.Contains(referer, "/browse?") || referer == "http://"+r.Host+"/" || referer == "https://"+r.Host+"/") {
+			fromSearchPage = true // 从搜索页面来的，默认不静音
 			accessSource = "搜索页面"
+		} else if strings.Contains(referer, r.Host) && strings.Contains(referer, "/video/") {
+			fromSearchPage = true // 播放页中的上一个/下一个，与原页面一致不静音
+			accessSource = "播放列表"
 		}
 	}
 
+	// 偏好设置可以指定总是或从不静音，默认按来源判断
+	muteByDefault := resolveMuteAutoplay(r, fromSearchPage)
+
 	log.Printf("请求播放视频: %s，来源IP: %s，访问来源: %s，静音策略: %t", filePath, r.RemoteAddr, accessSource, muteByDefault)
 
 	// 检查文件是否存在
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := statPath(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("视频文件不存在: %s", filePath)
@@ -1299,17 +2608,7 @@ func videoPlayerHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 检查是否为视频文件并判断兼容性
 	ext := strings.ToLower(filepath.Ext(filePath))
-	videoExts := []string{".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm"}
-
-	isVideo := false
-	for _, videoExt := range videoExts {
-		if ext == videoExt {
-			isVideo = true
-			break
-		}
-	}
-
-	if !isVideo {
+	if !isVideoFile(ext) {
 		log.Printf("非视频文件: %s", filePath)
 		http.Error(w, "不是视频文件", http.StatusBadRequest)
 		return
@@ -1320,6 +2619,9 @@ func videoPlayerHandler(w http.ResponseWriter, r *http.Request) {
 	fileName := filepath.Base(filePath)
 	fileSizeMB := float64(fileInfo.Size()) / (1024 * 1024)
 
+	// 同一文件夹中的上一个/下一个视频
+	playlist := playlistControls(filePath, requestPrefs(r).AutoPlayNext, cspNonce(r))
+
 	// 根据格式和ffmpeg可用性智能选择播放方式
 	// 浏览器原生支持良好：MP4, WebM
 	// 需要转码处理：AVI, FLV, MKV, WMV (现代浏览器支持差)
@@ -1345,26 +2647,27 @@ func videoPlayerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if needTranscode {
-		if ffmpegAvailable {
+		if ffmpegAvailable.Load() {
 			log.Printf("%s格式，使用ffmpeg转码播放: %s", strings.ToUpper(ext[1:]), filePath)
-			generateTranscodeVideoPlayer(w, filePath, fileName, fileSizeMB, ext, muteByDefault, accessSource)
+			generateTranscodeVideoPlayer(w, r, filePath, fileName, fileSizeMB, ext, muteByDefault, accessSource, playlist)
 		} else {
 			log.Printf("%s格式，ffmpeg不可用，显示兼容性警告: %s", strings.ToUpper(ext[1:]), filePath)
-			generateIncompatibleVideoPlayer(w, filePath, fileName, fileSizeMB, ext, muteByDefault, accessSource)
+			generateIncompatibleVideoPlayer(w, r, filePath, fileName, fileSizeMB, ext, muteByDefault, accessSource)
 		}
 	} else if isWebCompatible {
 		log.Printf("%s格式，浏览器兼容，直接播放: %s", strings.ToUpper(ext[1:]), filePath)
-		generateCompatibleVideoPlayer(w, filePath, fileName, fileSizeMB, ext, muteByDefault, accessSource)
+		generateCompatibleVideoPlayer(w, r, filePath, fileName, fileSizeMB, ext, muteByDefault, accessSource, playlist)
 	} else {
 		// MOV等格式：先尝试播放，失败时显示警告
 		log.Printf("%s格式，尝试兼容播放: %s", strings.ToUpper(ext[1:]), filePath)
 
-		generateCompatibleVideoPlayerWithFallback(w, filePath, fileName, fileSizeMB, ext, muteByDefault, accessSource)
+		generateCompatibleVideoPlayerWithFallback(w, r, filePath, fileName, fileSizeMB, ext, muteByDefault, accessSource, playlist)
 	}
 }
 
 // 兼容格式的视频播放器
-func generateCompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName string, fileSizeMB float64, ext string, muteByDefault bool, accessSource string) {
+func generateCompatibleVideoPlayer(w http.ResponseWriter, r *http.Request, filePath, fileName string, fileSizeMB float64, ext string, muteByDefault bool, accessSource, playlist string) {
+	nonce := cspNonce(r)
 	// 根据来源设置video标签属性
 	muteAttribute := ""
 	if muteByDefault {
@@ -1379,9 +2682,10 @@ func generateCompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName str
 	tmpl := `<!DOCTYPE html>
 <html lang="zh-CN">
 <head>
+    ` + csrfFetchScript(nonce) + `
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>视频播放器 - ` + fileName + `</title>
+    <title>视频播放器 - ` + html.EscapeString(fileName) + `</title>
     <style>
         * { box-sizing: border-box; margin: 0; padding: 0; }
         body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #000; color: white; overflow-x: hidden; }
@@ -1441,12 +2745,12 @@ func generateCompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName str
     <div class="container">
         <div class="header">
             <div class="video-info">
-                <div class="video-title">` + fileName + `</div>
-                <div class="video-meta">文件大小: ` + fmt.Sprintf("%.1f MB", fileSizeMB) + ` • 路径: ` + filePath + `</div>
+                <div class="video-title">` + html.EscapeString(fileName) + `</div>
+                <div class="video-meta">文件大小: ` + fmt.Sprintf("%.1f MB", fileSizeMB) + ` • 路径: ` + html.EscapeString(filePath) + `</div>
             </div>
             <div class="controls">
                 <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>下载视频</a>
-                <button class="btn btn-secondary" onclick="window.close()">关闭窗口</button>
+                <button class="btn btn-secondary" data-click="closeWindow">关闭窗口</button>
             </div>
         </div>
         
@@ -1459,12 +2763,16 @@ func generateCompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName str
         </div>
         
         <div class="video-container">
-            <video class="video-player" controls autoplay` + muteAttribute + ` preload="metadata" onloadstart="logEvent('视频开始加载')" onloadedmetadata="logEvent('视频元数据加载完成，分辨率: ' + this.videoWidth + 'x' + this.videoHeight)" oncanplay="logEvent('视频可以播放')" onplay="logEvent('视频开始播放')" onpause="logEvent('视频暂停')" onerror="showCompatibilityWarning(this)" onstalled="logEvent('视频加载停滞')" onabort="logEvent('视频加载中止')">
+            <video class="video-player" controls autoplay` + muteAttribute + ` preload="metadata">
                 <source src="/stream/` + url.QueryEscape(filePath) + `" type="video/mp4">
+                ` + subtitleTrack(r) + `
                 <p class="error">您的浏览器不支持视频播放。</p>
             </video>
-            <button class="fullscreen-btn" onclick="toggleFullscreen()">全屏</button>
+            ` + storyboardPreview(filePath, nonce) + `
+            <button class="fullscreen-btn" data-click="toggleFullscreen">全屏</button>
         </div>
+        ` + playlist + `
+        ` + clipControls(filePath, nonce) + `
         
         <!-- 动态兼容性警告（默认隐藏） -->
         <div id="compatibilityWarning" class="warning-box" style="display: none;">
@@ -1478,7 +2786,7 @@ func generateCompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName str
                 <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>
                     📥 下载文件
                 </a>
-                <button class="btn btn-warning" onclick="retryPlay()">
+                <button class="btn btn-warning" data-click="retryPlay">
                     🔄 重新尝试
                 </button>
             </div>
@@ -1494,7 +2802,13 @@ func generateCompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName str
         </div>
     </div>
 
-    <script>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({
+            closeWindow: () => window.close(),
+            toggleFullscreen: () => toggleFullscreen(),
+            retryPlay: () => retryPlay(),
+        });
+
         function logEvent(message) {
             const logs = document.getElementById('logs');
             const time = new Date().toLocaleTimeString();
@@ -1541,6 +2855,16 @@ func generateCompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName str
         
         // 记录视频播放进度
         const video = document.querySelector('.video-player');
+        video.addEventListener('loadstart', () => logEvent('视频开始加载'));
+        video.addEventListener('loadedmetadata', () => logEvent('视频元数据加载完成，分辨率: ' + video.videoWidth + 'x' + video.videoHeight));
+        video.addEventListener('canplay', () => logEvent('视频可以播放'));
+        video.addEventListener('play', () => logEvent('视频开始播放'));
+        video.addEventListener('pause', () => logEvent('视频暂停'));
+        video.addEventListener('error', () => showCompatibilityWarning(video));
+        video.addEventListener('stalled', () => logEvent('视频加载停滞'));
+        video.addEventListener('abort', () => logEvent('视频加载中止'));
+        if (video.error) showCompatibilityWarning(video);
+        
         let lastProgress = -1;
         
         video.addEventListener('timeupdate', function() {
@@ -1637,7 +2961,8 @@ func generateCompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName str
 }
 
 // 不兼容格式的视频播放器
-func generateIncompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName string, fileSizeMB float64, ext string, muteByDefault bool, accessSource string) {
+func generateIncompatibleVideoPlayer(w http.ResponseWriter, r *http.Request, filePath, fileName string, fileSizeMB float64, ext string, muteByDefault bool, accessSource string) {
+	nonce := cspNonce(r)
 	// 根据来源设置video标签属性
 	muteAttribute := ""
 	if muteByDefault {
@@ -1652,9 +2977,10 @@ func generateIncompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName s
 	tmpl := `<!DOCTYPE html>
 <html lang="zh-CN">
 <head>
+    ` + csrfFetchScript(nonce) + `
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>视频播放器 - ` + fileName + `</title>
+    <title>视频播放器 - ` + html.EscapeString(fileName) + `</title>
     <style>
         * { box-sizing: border-box; margin: 0; padding: 0; }
         body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #000; color: white; overflow-x: hidden; }
@@ -1707,12 +3033,12 @@ func generateIncompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName s
     <div class="container">
         <div class="header">
             <div class="video-info">
-                <div class="video-title">` + fileName + `</div>
-                <div class="video-meta">文件大小: ` + fmt.Sprintf("%.1f MB", fileSizeMB) + ` • 路径: ` + filePath + `</div>
+                <div class="video-title">` + html.EscapeString(fileName) + `</div>
+                <div class="video-meta">文件大小: ` + fmt.Sprintf("%.1f MB", fileSizeMB) + ` • 路径: ` + html.EscapeString(filePath) + `</div>
             </div>
             <div class="controls">
                 <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>下载视频</a>
-                <button class="btn btn-secondary" onclick="window.close()">关闭窗口</button>
+                <button class="btn btn-secondary" data-click="closeWindow">关闭窗口</button>
             </div>
         </div>
         
@@ -1742,7 +3068,7 @@ func generateIncompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName s
                 <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>
                     📥 下载文件
                 </a>
-                <button class="btn btn-warning" onclick="tryForcePlay()">
+                <button class="btn btn-warning" data-click="tryForcePlay">
                     ⚡ 强制尝试播放
                 </button>
             </div>
@@ -1755,12 +3081,18 @@ func generateIncompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName s
             </div>
             <video id="videoElement" controls autoplay` + muteAttribute + ` preload="metadata" style="width: 100%; max-height: 60vh; border-radius: 8px;">
                 <source src="/stream/` + url.QueryEscape(filePath) + `">
+                ` + subtitleTrack(r) + `
                 <p style="color: #ff6b6b;">您的浏览器不支持此视频格式。</p>
             </video>
         </div>
     </div>
 
-    <script>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({
+            closeWindow: () => window.close(),
+            tryForcePlay: () => tryForcePlay(),
+        });
+
         function tryForcePlay() {
             const placeholder = document.querySelector('.video-player-placeholder');
             const forcePlayer = document.getElementById('forcePlayer');
@@ -1773,7 +3105,7 @@ func generateIncompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName s
                 alert('播放失败！此格式不被浏览器支持，请下载文件使用专业播放器观看。');
             });
             
-            console.log('尝试强制播放 ` + ext + ` 格式视频 (来源: ` + accessSource + `)');
+            console.log(` + jsString("尝试强制播放 "+ext+" 格式视频 (来源: "+accessSource+")") + `);
         }
     </script>
 </body>
@@ -1784,7 +3116,8 @@ func generateIncompatibleVideoPlayer(w http.ResponseWriter, filePath, fileName s
 }
 
 // 带有强化错误检测的兼容播放器（用于MOV等不确定兼容性的格式）
-func generateCompatibleVideoPlayerWithFallback(w http.ResponseWriter, filePath, fileName string, fileSizeMB float64, ext string, muteByDefault bool, accessSource string) {
+func generateCompatibleVideoPlayerWithFallback(w http.ResponseWriter, r *http.Request, filePath, fileName string, fileSizeMB float64, ext string, muteByDefault bool, accessSource, playlist string) {
+	nonce := cspNonce(r)
 	// 根据来源设置video标签属性
 	muteAttribute := ""
 	if muteByDefault {
@@ -1799,9 +3132,10 @@ func generateCompatibleVideoPlayerWithFallback(w http.ResponseWriter, filePath,
 	tmpl := `<!DOCTYPE html>
 <html lang="zh-CN">
 <head>
+    ` + csrfFetchScript(nonce) + `
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>视频播放器 - ` + fileName + `</title>
+    <title>视频播放器 - ` + html.EscapeString(fileName) + `</title>
     <style>
         * { box-sizing: border-box; margin: 0; padding: 0; }
         body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #000; color: white; overflow-x: hidden; }
@@ -1876,12 +3210,12 @@ func generateCompatibleVideoPlayerWithFallback(w http.ResponseWriter, filePath,
     <div class="container">
         <div class="header">
             <div class="video-info">
-                <div class="video-title">` + fileName + `</div>
-                <div class="video-meta">文件大小: ` + fmt.Sprintf("%.1f MB", fileSizeMB) + ` • 路径: ` + filePath + `</div>
+                <div class="video-title">` + html.EscapeString(fileName) + `</div>
+                <div class="video-meta">文件大小: ` + fmt.Sprintf("%.1f MB", fileSizeMB) + ` • 路径: ` + html.EscapeString(filePath) + `</div>
             </div>
             <div class="controls">
                 <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>下载视频</a>
-                <button class="btn btn-secondary" onclick="window.close()">关闭窗口</button>
+                <button class="btn btn-secondary" data-click="closeWindow">关闭窗口</button>
             </div>
         </div>
         
@@ -1894,12 +3228,16 @@ func generateCompatibleVideoPlayerWithFallback(w http.ResponseWriter, filePath,
         </div>
         
         <div class="video-container">
-            <video class="video-player" controls autoplay` + muteAttribute + ` preload="metadata" onloadstart="logEvent('视频开始加载')" onloadedmetadata="logEvent('视频元数据加载完成，分辨率: ' + this.videoWidth + 'x' + this.videoHeight)" oncanplay="logEvent('视频可以播放')" onplay="logEvent('视频开始播放')" onpause="logEvent('视频暂停')" onerror="showCompatibilityWarning(this)" onstalled="handleStalled(this)" onabort="handleAbort(this)" onwaiting="logEvent('视频缓冲中...')">
+            <video class="video-player" controls autoplay` + muteAttribute + ` preload="metadata">
                 <source src="/stream/` + url.QueryEscape(filePath) + `" type="video/mp4">
+                ` + subtitleTrack(r) + `
                 <p class="error">您的浏览器不支持视频播放。</p>
             </video>
-            <button class="fullscreen-btn" onclick="toggleFullscreen()">全屏</button>
+            ` + storyboardPreview(filePath, nonce) + `
+            <button class="fullscreen-btn" data-click="toggleFullscreen">全屏</button>
         </div>
+        ` + playlist + `
+        ` + clipControls(filePath, nonce) + `
         
         <!-- 动态兼容性警告（默认隐藏） -->
         <div id="compatibilityWarning" class="warning-box">
@@ -1913,7 +3251,7 @@ func generateCompatibleVideoPlayerWithFallback(w http.ResponseWriter, filePath,
                 <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>
                     📥 下载文件
                 </a>
-                <button class="btn btn-warning" onclick="retryPlay()">
+                <button class="btn btn-warning" data-click="retryPlay">
                     🔄 重新尝试
                 </button>
             </div>
@@ -1929,7 +3267,13 @@ func generateCompatibleVideoPlayerWithFallback(w http.ResponseWriter, filePath,
         </div>
     </div>
 
-    <script>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({
+            closeWindow: () => window.close(),
+            toggleFullscreen: () => toggleFullscreen(),
+            retryPlay: () => retryPlay(),
+        });
+
         let errorDetectionTimer = null;
         let playbackStarted = false;
         
@@ -2029,6 +3373,17 @@ func generateCompatibleVideoPlayerWithFallback(w http.ResponseWriter, filePath,
         
         // 记录视频播放进度
         const video = document.querySelector('.video-player');
+        video.addEventListener('loadstart', () => logEvent('视频开始加载'));
+        video.addEventListener('loadedmetadata', () => logEvent('视频元数据加载完成，分辨率: ' + video.videoWidth + 'x' + video.videoHeight));
+        video.addEventListener('canplay', () => logEvent('视频可以播放'));
+        video.addEventListener('play', () => logEvent('视频开始播放'));
+        video.addEventListener('pause', () => logEvent('视频暂停'));
+        video.addEventListener('error', () => showCompatibilityWarning(video));
+        video.addEventListener('stalled', () => handleStalled(video));
+        video.addEventListener('abort', () => handleAbort(video));
+        video.addEventListener('waiting', () => logEvent('视频缓冲中...'));
+        if (video.error) showCompatibilityWarning(video);
+        
         let lastProgress = -1;
         
         video.addEventListener('timeupdate', function() {
@@ -2108,37 +3463,44 @@ func apiSearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取分页参数
-	pageStr := r.URL.Query().Get("page")
-	pageSizeStr := r.URL.Query().Get("pageSize")
+	page, pageSize := parsePageParams(r)
+	sortBy := parseSearchSort(r)
+	groupBy := parseGroupBy(r)
 
-	page := 1
-	pageSize := DefaultPageSize
+	log.Printf("搜索请求: query=%s, page=%d, pageSize=%d, sort=%s, groupBy=%s, IP=%s", query, page, pageSize, sortBy, groupBy, r.RemoteAddr)
 
-	if pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
+	ctx := withQueryOptions(r.Context(), parseEverythingQueryOptions(r))
+	response, err := buildSearchResponse(ctx, query, sortBy, groupBy, page, pageSize)
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消搜索: %s", query)
+		return
 	}
-
-	if pageSizeStr != "" {
-		if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 && ps <= MaxPageSize {
-			pageSize = ps
-		}
+	if err != nil {
+		log.Printf("搜索失败: %v", err)
+		http.Error(w, "搜索失败: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
+	recordSearch(w, r, query)
 
-	log.Printf("搜索请求: query=%s, page=%d, pageSize=%d, IP=%s", query, page, pageSize, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(response)
+}
 
+// 执行搜索并生成一页的响应数据，供搜索API和可收藏的搜索页面共用
+func buildSearchResponse(ctx context.Context, query, sortBy, groupBy string, page, pageSize int) (*SearchResponse, error) {
 	// 使用缓存优化的搜索函数
-	results, totalCount, fromCache, err := searchFilesWithCache(query, page, pageSize)
+	allPaths, fromCache, err := getSearchPaths(ctx, query, sortBy)
 	if err != nil {
-		log.Printf("搜索失败: %v", err)
-		http.Error(w, "搜索失败: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
+	}
+	if groupBy == "folder" {
+		allPaths = groupedSearchPaths(searchCacheKey(ctx, query), sortBy, allPaths)
 	}
+	results, totalCount := pagePathResults(ctx, allPaths, page, pageSize)
 
 	totalPages := (totalCount + pageSize - 1) / pageSize
 
-	response := SearchResponse{
+	response := &SearchResponse{
 		Results:    results,
 		Count:      len(results),
 		TotalCount: totalCount,
@@ -2146,6 +3508,14 @@ func apiSearchHandler(w http.ResponseWriter, r *http.Request) {
 		Page:       page,
 		PageSize:   pageSize,
 		TotalPages: totalPages,
+		Sort:       sortBy,
+		GroupBy:    groupBy,
+	}
+	if opts := queryOptionsFrom(ctx); opts.Sort != "" {
+		response.Sort = opts.Sort
+	}
+	if groupBy == "folder" {
+		response.Groups = pageFolderGroups(allPaths, page, pageSize)
 	}
 
 	if fromCache {
@@ -2153,61 +3523,91 @@ func apiSearchHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		log.Printf("搜索完成(新查询): 总共%d条结果, 返回第%d页(%d条), 已缓存", totalCount, page, len(results))
 	}
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	json.NewEncoder(w).Encode(response)
+	return response, nil
 }
 
 // 带缓存的搜索文件函数
-func searchFilesWithCache(query string, page, pageSize int) ([]SearchResult, int, bool, error) {
-	// 检查缓存
+func searchFilesWithCache(ctx context.Context, query string, page, pageSize int) ([]SearchResult, int, bool, error) {
+	return searchFilesSorted(ctx, query, "", page, pageSize)
+}
+
+// 带缓存的搜索，sortBy为natural时按文件名自然排序，为relevance时按相关度排序，为空时保持Everything返回的顺序
+func searchFilesSorted(ctx context.Context, query, sortBy string, page, pageSize int) ([]SearchResult, int, bool, error) {
+	allPaths, fromCache, err := getSearchPaths(ctx, query, sortBy)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	results, totalCount := pagePathResults(ctx, allPaths, page, pageSize)
+	return results, totalCount, fromCache, nil
+}
+
+// 获取查询的全部路径，优先使用缓存，缓存不存在或已过期时执行搜索并缓存
+func getSearchPaths(ctx context.Context, query, sortBy string) (*pathList, bool, error) {
+	// 脚本可以改写查询（见scripts.go），缓存按改写后的查询保存
+	query = scriptRewriteQuery(query)
+
+	// 检查缓存，Everything排序和结果数上限不同时分别缓存
+	key := searchCacheKey(ctx, query)
 	cacheMutex.RLock()
-	cache, exists := searchCache[query]
+	cache, exists := searchCache[key]
 	cacheMutex.RUnlock()
 
-	var allPaths []string
+	var allPaths *pathList
 	fromCache := false
 
 	if exists && time.Since(cache.Timestamp) < cacheExpiry {
 		// 使用缓存
 		allPaths = cache.Paths
 		fromCache = true
-		log.Printf("使用缓存结果: query=%s, 缓存了%d个路径", query, len(allPaths))
-		for i, path := range allPaths {
-			log.Printf("缓存路径[%d]: %s", i+1, path)
-		}
+		log.Printf("使用缓存结果: query=%s, 缓存了%d个路径", query, allPaths.Len())
+		logPaths("缓存路径", allPaths)
 	} else {
-		// 执行新搜索 - 优先使用Everything SDK，如果失败则回退到es.exe
+		// 执行新搜索，fuzzy:查询和tag:条件由服务器处理，其余条件交给Everything
+		var paths []string
 		var err error
-		allPaths, err = searchWithEverythingSDK(query)
+		if rest, fuzzy := splitFuzzyQuery(query); fuzzy {
+			paths, err = searchFuzzy(ctx, rest)
+		} else if initials, rest, py := splitPinyinQuery(query); py {
+			paths, err = searchPinyin(ctx, initials, rest)
+		} else if rest, tags := splitTagQuery(query); len(tags) > 0 {
+			paths, err = searchWithTags(ctx, rest, tags)
+		} else {
+			paths, err = runEverythingQuery(ctx, query)
+		}
 		if err != nil {
-			log.Printf("Everything SDK搜索失败，回退到es.exe: %v", err)
-			allPaths, err = searchWithESExe(query)
-			if err != nil {
-				return nil, 0, false, fmt.Errorf("搜索失败 - SDK错误: %v, es.exe错误: %v", err, err)
-			}
+			return nil, false, err
 		}
+		allPaths = newPathList(scriptFilterPaths(filterExcludedPaths(paths)))
 
-		log.Printf("总共%d个有效路径", len(allPaths))
-		for i, path := range allPaths {
-			log.Printf("搜索路径[%d]: %s", i+1, path)
-		}
+		log.Printf("总共%d个有效路径", allPaths.Len())
+		logPaths("搜索路径", allPaths)
 
 		// 更新缓存
 		cacheMutex.Lock()
-		searchCache[query] = &SearchCache{
+		searchCache[key] = &SearchCache{
 			Paths:     allPaths,
 			Timestamp: time.Now(),
 		}
 		cacheMutex.Unlock()
 
-		log.Printf("已将搜索结果缓存: query=%s, 路径数=%d", query, len(allPaths))
+		log.Printf("已将搜索结果缓存: query=%s, 路径数=%d, 约%s", query, allPaths.Len(), formatSize(allPaths.MemorySize()))
+	}
+
+	switch sortBy {
+	case "natural":
+		allPaths = naturalSortedPaths(key, allPaths)
+	case "relevance":
+		allPaths = relevanceSortedPaths(key, query, allPaths)
 	}
+	return allPaths, fromCache, nil
+}
 
-	totalCount := len(allPaths)
+// 取出一页路径并获取文件信息，返回该页结果和总数
+func pagePathResults(ctx context.Context, allPaths *pathList, page, pageSize int) ([]SearchResult, int) {
+	totalCount := allPaths.Len()
 
 	if totalCount == 0 {
-		return []SearchResult{}, 0, fromCache, nil
+		return []SearchResult{}, 0
 	}
 
 	// 计算分页范围
@@ -2221,48 +3621,52 @@ func searchFilesWithCache(query string, page, pageSize int) ([]SearchResult, int
 	if start < totalCount {
 		log.Printf("开始处理第%d页: %d-%d", page, start+1, end)
 
-		for i := start; i < end; i++ {
-			filePath := allPaths[i]
-			log.Printf("处理文件路径[%d]: %s", i+1, filePath)
+		results = gatherPageMetadata(ctx, allPaths.Slice(start, end), start)
 
-			// 获取文件信息
-			info, err := os.Stat(filePath)
-			if err != nil {
-				log.Printf("无法访问文件[%d]: %s, 错误: %v", i+1, filePath, err)
-				continue // 跳过无法访问的文件
-			}
-			log.Printf("文件[%d]访问成功: %s", i+1, filePath)
-
-			result := SearchResult{
-				Name:     filepath.Base(filePath),
-				Path:     filePath,
-				Size:     info.Size(),
-				Modified: info.ModTime().Format("2006-01-02 15:04:05"),
-				IsDir:    info.IsDir(),
-			}
+		log.Printf("第%d页处理完成，返回%d条结果", page, len(results))
+	}
 
-			// 确定文件类型
-			if result.IsDir {
-				result.Type = "folder"
-			} else {
-				ext := strings.ToLower(filepath.Ext(filePath))
-				switch ext {
-				case ".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm":
-					result.Type = "video"
-				case ".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp":
-					result.Type = "image"
-				default:
-					result.Type = "file"
-				}
-			}
+	return results, totalCount
+}
 
-			results = append(results, result)
-		}
+// 记录前几个路径，结果很多时不逐条记录
+func logPaths(label string, paths *pathList) {
+	for i := 0; i < paths.Len() && i < maxLoggedPaths; i++ {
+		log.Printf("%s[%d]: %s", label, i+1, paths.At(i))
+	}
+	if paths.Len() > maxLoggedPaths {
+		log.Printf("%s: 其余%d个路径未记录", label, paths.Len()-maxLoggedPaths)
+	}
+}
 
-		log.Printf("第%d页处理完成，返回%d条结果", page, len(results))
+// 执行Everything搜索，由当前的搜索后端处理
+func runEverythingQuery(ctx context.Context, query string) ([]string, error) {
+	return searchBackend.Search(ctx, query)
+}
+
+// 通过Everything搜索 - 优先使用Everything SDK，如果失败则回退到es.exe
+func (everythingBackend) Search(ctx context.Context, query string) ([]string, error) {
+	if remoteEverythingEnabled() {
+		return searchWithRemoteEverything(ctx, query)
+	}
+	search := searchWithEverythingSDK
+	if everythingInstanceName() != "" {
+		search = searchWithEverything3
+	}
+	paths, sdkErr := search(ctx, query)
+	if sdkErr == nil {
+		return paths, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
 
-	return results, totalCount, fromCache, nil
+	log.Printf("Everything SDK搜索失败，回退到es.exe: %v", sdkErr)
+	results, err := searchWithESExe(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("搜索失败 - SDK错误: %v, es.exe错误: %v", sdkErr, err)
+	}
+	return resultPaths(results), nil
 }
 
 // 清理过期缓存的函数
@@ -2279,14 +3683,14 @@ func cleanExpiredCache() {
 }
 
 // 优化的搜索文件函数（保持向后兼容）
-func searchFilesOptimized(query string, page, pageSize int) ([]SearchResult, int, error) {
-	results, totalCount, _, err := searchFilesWithCache(query, page, pageSize)
+func searchFilesOptimized(ctx context.Context, query string, page, pageSize int) ([]SearchResult, int, error) {
+	results, totalCount, _, err := searchFilesWithCache(ctx, query, page, pageSize)
 	return results, totalCount, err
 }
 
 // 使用es.exe搜索文件（保持向后兼容）
-func searchFiles(query string) ([]SearchResult, error) {
-	results, _, err := searchFilesOptimized(query, 1, 999999)
+func searchFiles(ctx context.Context, query string) ([]SearchResult, error) {
+	results, _, err := searchFilesOptimized(ctx, query, 1, 999999)
 	return results, err
 }
 
@@ -2294,22 +3698,13 @@ func searchFiles(query string) ([]SearchResult, error) {
 func fileHandler(w http.ResponseWriter, r *http.Request) {
 	filePath := r.URL.Path[6:] // 去掉 "/file/" 前缀
 
-	// 多次URL解码以确保正确处理
-	for i := 0; i < 3; i++ {
-		if decoded, err := url.QueryUnescape(filePath); err == nil {
-			filePath = decoded
-		} else {
-			break
-		}
-	}
-
-	// 替换正斜杠为反斜杠（Windows路径）
-	filePath = strings.ReplaceAll(filePath, "/", "\\")
+	// URL解码并转换为Windows路径
+	filePath = decodeRequestPath(filePath)
 
 	log.Printf("文件下载请求: %s，来源IP: %s", filePath, r.RemoteAddr)
 
 	// 检查文件是否存在
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := statPath(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("文件不存在: %s", filePath)
@@ -2321,34 +3716,40 @@ func fileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 客户端不支持WebP/AVIF时转换为JPEG，明确要求下载时发送原文件
+	if r.URL.Query().Get("download") != "1" && serveCompatibleImage(w, r, filePath, fileInfo) {
+		return
+	}
+
 	// 获取文件名
 	fileName := filepath.Base(filePath)
 
-	// 检查是否为下载请求（通过URL参数或来源判断）
-	isDownload := r.URL.Query().Get("download") != "" ||
-		r.Header.Get("Accept") != "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"
-
-	// 如果是下载请求，设置下载头
-	if isDownload || r.URL.RawQuery != "" {
-		// 设置下载响应头
-		w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
-		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
-		log.Printf("强制下载文件: %s (大小: %d 字节)", fileName, fileInfo.Size())
+	// 只有download=1时作为附件下载，其他情况按类型表内联返回（见disposition.go）
+	contentType := detectContentType(filePath, strings.ToLower(filepath.Ext(filePath)))
+	disposition, servedType := fileDisposition(r, contentType)
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, fileName))
+	w.Header().Set("Content-Type", servedType)
+	if disposition == "attachment" {
+		log.Printf("下载文件: %s (大小: %d 字节，Range: %s)", fileName, fileInfo.Size(), r.Header.Get("Range"))
 	} else {
-		// 普通访问，设置适当的Content-Type
-		ext := strings.ToLower(filepath.Ext(filePath))
-		contentType := getContentType(ext)
-		w.Header().Set("Content-Type", contentType)
-		log.Printf("提供文件预览: %s (类型: %s)", fileName, contentType)
+		log.Printf("提供文件预览: %s (类型: %s，预览: %t)", fileName, servedType, isPreviewableContentType(servedType))
 	}
 
+	// 每次使用前向服务器确认，文件未变化时返回304而不重新下载
+	w.Header().Set("Cache-Control", "private, no-cache")
+	// 支持断点续传：Content-Length和206响应由http.ServeContent按Range设置（见transfers.go）
+	w.Header().Set("Accept-Ranges", "bytes")
+	setChecksumHeaders(w, r, filePath, fileInfo)
+
 	log.Printf("开始提供文件: %s", filePath)
-	http.ServeFile(w, r, filePath)
+	serveFileTransfer(w, r, filePath, fileInfo, disposition == "attachment")
 }
 
 // 获取文件的Content-Type
 func getContentType(ext string) string {
+	if configured := configuredContentType(ext); configured != "" {
+		return configured
+	}
 	switch ext {
 	case ".jpg", ".jpeg":
 		return "image/jpeg"
@@ -2360,6 +3761,8 @@ func getContentType(ext string) string {
 		return "image/bmp"
 	case ".webp":
 		return "image/webp"
+	case ".avif":
+		return "image/avif"
 	case ".mp4":
 		return "video/mp4"
 	case ".avi":
@@ -2395,6 +3798,9 @@ func getContentType(ext string) string {
 	case ".7z":
 		return "application/x-7z-compressed"
 	default:
+		if contentType := systemContentType(ext); contentType != "" {
+			return contentType
+		}
 		return "application/octet-stream"
 	}
 }
@@ -2403,22 +3809,13 @@ func getContentType(ext string) string {
 func streamHandler(w http.ResponseWriter, r *http.Request) {
 	filePath := r.URL.Path[8:] // 去掉 "/stream/" 前缀
 
-	// 多次URL解码以确保正确处理
-	for i := 0; i < 3; i++ {
-		if decoded, err := url.QueryUnescape(filePath); err == nil {
-			filePath = decoded
-		} else {
-			break
-		}
-	}
-
-	// 替换正斜杠为反斜杠（Windows路径）
-	filePath = strings.ReplaceAll(filePath, "/", "\\")
+	// URL解码并转换为Windows路径
+	filePath = decodeRequestPath(filePath)
 
 	log.Printf("视频流请求: %s，Range: %s，来源IP: %s", filePath, r.Header.Get("Range"), r.RemoteAddr)
 
 	// 检查文件是否存在
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := statPath(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("视频文件不存在: %s", filePath)
@@ -2430,7 +3827,13 @@ func streamHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, err := os.Open(filePath)
+	if remoteEverythingEnabled() {
+		w.Header().Set("Content-Type", getContentType(strings.ToLower(filepath.Ext(filePath))))
+		proxyRemoteFile(w, r, filePath)
+		return
+	}
+
+	file, err := openPath(filePath)
 	if err != nil {
 		log.Printf("无法打开视频文件: %s, 错误: %v", filePath, err)
 		http.Error(w, "无法打开文件", http.StatusInternalServerError)
@@ -2460,128 +3863,103 @@ func streamHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("视频文件信息: 大小=%d字节, 类型=%s", fileInfo.Size(), contentType)
 
-	// 支持Range请求以实现视频拖拽
+	// 由http.ServeContent处理Range（包括后缀范围和多范围）、If-Range及其他条件请求
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fileETag(fileInfo))
+	serveRange(w, r, file, fileInfo)
+}
+
+// 支持Range请求的视频流处理，记录请求的范围和实际传输情况
+func serveRange(w http.ResponseWriter, r *http.Request, file *os.File, fileInfo os.FileInfo) {
 	rangeHeader := r.Header.Get("Range")
 	if rangeHeader != "" {
 		log.Printf("处理Range请求: %s", rangeHeader)
-		serveRange(w, r, file, fileInfo.Size(), contentType)
 	} else {
 		log.Printf("提供完整视频文件")
-		w.Header().Set("Content-Type", contentType)
-		w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
-		w.Header().Set("Accept-Ranges", "bytes")
-		io.Copy(w, file)
+	}
+
+	lw := newTransferWriter(w, r)
+	http.ServeContent(lw, r, fileInfo.Name(), fileInfo.ModTime(), file)
+
+	switch lw.status {
+	case http.StatusPartialContent:
+		log.Printf("Range请求完成: %s/%d, 传输了%d字节", lw.Header().Get("Content-Range"), fileInfo.Size(), lw.written)
+	case http.StatusRequestedRangeNotSatisfiable:
+		log.Printf("无效的Range范围: %s, fileSize=%d", rangeHeader, fileInfo.Size())
+	case http.StatusNotModified:
+		log.Printf("视频文件未修改，返回304")
+	default:
+		log.Printf("视频流传输结束: 状态%d, 传输了%d字节", lw.status, lw.written)
 	}
 }
 
-// 支持Range请求的视频流处理
-func serveRange(w http.ResponseWriter, r *http.Request, file *os.File, fileSize int64, contentType string) {
-	rangeHeader := r.Header.Get("Range")
+// 缩略图处理器
+func thumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	filePath := r.URL.Path[11:] // 去掉 "/thumbnail/" 前缀
 
-	// 解析Range头
-	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		log.Printf("无效的Range头格式: %s", rangeHeader)
-		http.Error(w, "无效的Range头", http.StatusRequestedRangeNotSatisfiable)
+	// URL解码并转换为Windows路径
+	filePath = decodeRequestPath(filePath)
+
+	log.Printf("缩略图请求: %s", filePath)
+
+	// 检查文件是否存在
+	info, err := statPath(filePath)
+	if os.IsNotExist(err) {
+		log.Printf("缩略图文件不存在: %s", filePath)
+		http.Error(w, "文件不存在", http.StatusNotFound)
 		return
 	}
 
-	rangeSpec := rangeHeader[6:] // 去掉"bytes="
-	rangeParts := strings.Split(rangeSpec, "-")
-	if len(rangeParts) != 2 {
-		log.Printf("无效的Range头格式: %s", rangeHeader)
-		http.Error(w, "无效的Range头", http.StatusRequestedRangeNotSatisfiable)
+	// 检查是否为图片或视频文件
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if !isImageFile(ext) && !isVideoFile(ext) {
+		log.Printf("非图片或视频文件: %s", filePath)
+		http.Error(w, "不是图片或视频文件", http.StatusBadRequest)
 		return
 	}
 
-	var start, end int64
-	var err error
-
-	if rangeParts[0] != "" {
-		start, err = strconv.ParseInt(rangeParts[0], 10, 64)
-		if err != nil {
-			log.Printf("无法解析Range起始位置: %s", rangeParts[0])
-			http.Error(w, "无效的Range头", http.StatusRequestedRangeNotSatisfiable)
+	// 缩略图允许浏览器缓存1小时，过期后通过ETag确认
+	if err == nil && needsThumbnail(filePath, info) {
+		target, genErr := generateThumbnail(r.Context(), filePath, info)
+		if genErr == nil {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("Cache-Control", thumbnailCacheControl)
+			serveFileContent(w, r, target)
 			return
 		}
-	}
-
-	if rangeParts[1] != "" {
-		end, err = strconv.ParseInt(rangeParts[1], 10, 64)
-		if err != nil {
-			log.Printf("无法解析Range结束位置: %s", rangeParts[1])
-			http.Error(w, "无效的Range头", http.StatusRequestedRangeNotSatisfiable)
+		if r.Context().Err() != nil {
 			return
 		}
-	} else {
-		end = fileSize - 1
+		log.Printf("生成缩略图失败: %s, 错误: %v", filePath, genErr)
 	}
-
-	if start > end || start >= fileSize {
-		log.Printf("无效的Range范围: start=%d, end=%d, fileSize=%d", start, end, fileSize)
-		http.Error(w, "无效的Range头", http.StatusRequestedRangeNotSatisfiable)
+	if isVideoFile(ext) {
+		http.Error(w, "无法生成视频缩略图", http.StatusNotFound)
 		return
 	}
 
-	contentLength := end - start + 1
-
-	log.Printf("Range请求处理: %d-%d/%d (长度: %d)", start, end, fileSize, contentLength)
+	// 客户端不支持WebP/AVIF时转换为JPEG
+	if err == nil && serveCompatibleImage(w, r, filePath, info) {
+		return
+	}
 
-	// 设置响应头
-	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
-	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
-	w.Header().Set("Accept-Ranges", "bytes")
-	w.WriteHeader(http.StatusPartialContent)
+	w.Header().Set("Cache-Control", thumbnailCacheControl)
 
-	// 移动到起始位置并复制数据
-	file.Seek(start, 0)
-	copied, err := io.CopyN(w, file, contentLength)
-	if err != nil {
-		log.Printf("Range请求数据传输错误: %v, 已传输: %d字节", err, copied)
-	} else {
-		log.Printf("Range请求完成: 传输了%d字节", copied)
-	}
+	// 较小的图片或无法生成缩略图时返回原图
+	serveFileContent(w, r, filePath)
 }
 
-// 缩略图处理器
-func thumbnailHandler(w http.ResponseWriter, r *http.Request) {
-	filePath := r.URL.Path[11:] // 去掉 "/thumbnail/" 前缀
-
-	// 多次URL解码以确保正确处理
-	for i := 0; i < 3; i++ {
-		if decoded, err := url.QueryUnescape(filePath); err == nil {
-			filePath = decoded
-		} else {
-			break
+func isVideoFile(ext string) bool {
+	videoExts := []string{".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm"}
+	for _, videoExt := range videoExts {
+		if ext == videoExt {
+			return true
 		}
 	}
-
-	// 替换正斜杠为反斜杠（Windows路径）
-	filePath = strings.ReplaceAll(filePath, "/", "\\")
-
-	log.Printf("缩略图请求: %s", filePath)
-
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		log.Printf("缩略图文件不存在: %s", filePath)
-		http.Error(w, "文件不存在", http.StatusNotFound)
-		return
-	}
-
-	// 检查是否为图片文件
-	ext := strings.ToLower(filepath.Ext(filePath))
-	if !isImageFile(ext) {
-		log.Printf("非图片文件: %s", filePath)
-		http.Error(w, "不是图片文件", http.StatusBadRequest)
-		return
-	}
-
-	// 简单实现：直接返回原图片（在实际项目中可以生成缩略图）
-	http.ServeFile(w, r, filePath)
+	return false
 }
 
 func isImageFile(ext string) bool {
-	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp"}
+	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp", ".avif"}
 	for _, imgExt := range imageExts {
 		if ext == imgExt {
 			return true
@@ -2592,13 +3970,19 @@ func isImageFile(ext string) bool {
 
 // 搜索处理器（保持兼容性）
 func searchHandler(w http.ResponseWriter, r *http.Request) {
+	// /search?q= 是可收藏的搜索结果页面，/search?search= 保持原来的JSON接口
+	if q := r.URL.Query().Get("q"); q != "" {
+		searchPageHandler(w, r, q)
+		return
+	}
+
 	query := r.URL.Query().Get("search")
 	if query == "" {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	results, err := searchFiles(query)
+	results, err := searchFiles(r.Context(), query)
 	if err != nil {
 		http.Error(w, "搜索失败: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -2623,23 +4007,33 @@ func cacheStatusHandler(w http.ResponseWriter, r *http.Request) {
 	status["cache_expiry_minutes"] = int(cacheExpiry.Minutes())
 
 	var cacheInfo []map[string]interface{}
+	var totalMemory int64
 	for query, cache := range searchCache {
 		info := map[string]interface{}{
-			"query":       query,
-			"path_count":  len(cache.Paths),
-			"timestamp":   cache.Timestamp.Format("2006-01-02 15:04:05"),
-			"age_minutes": int(time.Since(cache.Timestamp).Minutes()),
+			"query":        query,
+			"path_count":   cache.Paths.Len(),
+			"memory_bytes": cache.Paths.MemorySize(),
+			"timestamp":    cache.Timestamp.Format("2006-01-02 15:04:05"),
+			"age_minutes":  int(time.Since(cache.Timestamp).Minutes()),
 		}
+		totalMemory += cache.Paths.MemorySize()
 		cacheInfo = append(cacheInfo, info)
 	}
 	status["caches"] = cacheInfo
+	status["memory_bytes"] = totalMemory
+	status["memory"] = formatSize(totalMemory)
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	json.NewEncoder(w).Encode(status)
 }
 
-// 清除缓存API
+// 清除缓存API，只接受POST（GET不经过CSRF检查，其他网站的<img>就能触发）
 func cacheClearHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
 	cacheMutex.Lock()
 	defer cacheMutex.Unlock()
 
@@ -2656,21 +4050,28 @@ func cacheClearHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// 检测ffmpeg是否可用的函数
-func checkFFmpegAvailability() {
-	cmd := exec.Command("ffmpeg", "-version")
-	err := cmd.Run()
+// 检测ffmpeg是否可用的函数，返回版本信息（ffmpeg -version的第一行）
+func checkFFmpegAvailability() (string, error) {
+	cmd := exec.Command(ffmpegBinary(), "-version")
+	output, err := cmd.Output()
 	if err != nil {
-		log.Printf("ffmpeg不可用: %v", err)
-		ffmpegAvailable = false
-	} else {
-		log.Printf("ffmpeg可用")
-		ffmpegAvailable = true
+		if ffmpegAvailable.Swap(false) || !toolsDetected.Load() {
+			log.Printf("ffmpeg不可用: %v", err)
+		}
+		return "", err
 	}
+
+	version, _, _ := strings.Cut(string(output), "\n")
+	version = strings.TrimSpace(version)
+	if !ffmpegAvailable.Swap(true) {
+		log.Printf("ffmpeg可用: %s", version)
+	}
+	return version, nil
 }
 
 // ffmpeg转码播放器页面
-func generateTranscodeVideoPlayer(w http.ResponseWriter, filePath, fileName string, fileSizeMB float64, ext string, muteByDefault bool, accessSource string) {
+func generateTranscodeVideoPlayer(w http.ResponseWriter, r *http.Request, filePath, fileName string, fileSizeMB float64, ext string, muteByDefault bool, accessSource, playlist string) {
+	nonce := cspNonce(r)
 	// 根据来源设置video标签属性
 	muteAttribute := ""
 	if muteByDefault {
@@ -2685,9 +4086,10 @@ func generateTranscodeVideoPlayer(w http.ResponseWriter, filePath, fileName stri
 	tmpl := `<!DOCTYPE html>
 <html lang="zh-CN">
 <head>
+    ` + csrfFetchScript(nonce) + `
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>视频播放器 - ` + fileName + `</title>
+    <title>视频播放器 - ` + html.EscapeString(fileName) + `</title>
     <style>
         * { box-sizing: border-box; margin: 0; padding: 0; }
         body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #000; color: white; overflow-x: hidden; }
@@ -2747,12 +4149,12 @@ func generateTranscodeVideoPlayer(w http.ResponseWriter, filePath, fileName stri
     <div class="container">
         <div class="header">
             <div class="video-info">
-                <div class="video-title">` + fileName + `</div>
-                <div class="video-meta">文件大小: ` + fmt.Sprintf("%.1f MB", fileSizeMB) + ` • 路径: ` + filePath + `</div>
+                <div class="video-title">` + html.EscapeString(fileName) + `</div>
+                <div class="video-meta">文件大小: ` + fmt.Sprintf("%.1f MB", fileSizeMB) + ` • 路径: ` + html.EscapeString(filePath) + `</div>
             </div>
             <div class="controls">
                 <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>下载视频</a>
-                <button class="btn btn-secondary" onclick="window.close()">关闭窗口</button>
+                <button class="btn btn-secondary" data-click="closeWindow">关闭窗口</button>
             </div>
         </div>
         
@@ -2765,12 +4167,14 @@ func generateTranscodeVideoPlayer(w http.ResponseWriter, filePath, fileName stri
         </div>
         
         <div class="video-container">
-            <video class="video-player" controls autoplay` + muteAttribute + ` preload="metadata" onloadstart="logEvent('开始加载转码视频')" onloadedmetadata="logEvent('转码视频元数据加载完成，分辨率: ' + this.videoWidth + 'x' + this.videoHeight)" oncanplay="logEvent('转码视频可以播放')" onplay="logEvent('转码视频开始播放')" onpause="logEvent('转码视频暂停')" onerror="logTranscodeError(this)" onwaiting="logEvent('转码缓冲中...')" onprogress="logEvent('转码视频下载进度更新')">
-                <source src="/transcode/` + url.QueryEscape(filePath) + `" type="video/mp4">
+            <video class="video-player" controls autoplay` + muteAttribute + ` preload="metadata">
+                <source src="/transcode/` + url.QueryEscape(filePath) + `?profile=auto" type="video/mp4">
+                ` + subtitleTrack(r) + `
                 <p class="error">您的浏览器不支持视频播放。</p>
             </video>
-            <button class="fullscreen-btn" onclick="toggleFullscreen()">全屏</button>
+            <button class="fullscreen-btn" data-click="toggleFullscreen">全屏</button>
         </div>
+        ` + playlist + `
         
         <div class="tips">
             💡 提示：使用ffmpeg实时转码，首次播放需要等待转码启动。转码过程中可能出现短暂缓冲。<br>
@@ -2782,7 +4186,12 @@ func generateTranscodeVideoPlayer(w http.ResponseWriter, filePath, fileName stri
         </div>
     </div>
 
-    <script>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({
+            closeWindow: () => window.close(),
+            toggleFullscreen: () => toggleFullscreen(),
+        });
+
         function logEvent(message) {
             const logs = document.getElementById('logs');
             const time = new Date().toLocaleTimeString();
@@ -2829,6 +4238,16 @@ func generateTranscodeVideoPlayer(w http.ResponseWriter, filePath, fileName stri
         
         // 记录视频播放进度
         const video = document.querySelector('.video-player');
+        video.addEventListener('loadstart', () => logEvent('开始加载转码视频'));
+        video.addEventListener('loadedmetadata', () => logEvent('转码视频元数据加载完成，分辨率: ' + video.videoWidth + 'x' + video.videoHeight));
+        video.addEventListener('canplay', () => logEvent('转码视频可以播放'));
+        video.addEventListener('play', () => logEvent('转码视频开始播放'));
+        video.addEventListener('pause', () => logEvent('转码视频暂停'));
+        video.addEventListener('error', () => logTranscodeError(video));
+        video.addEventListener('waiting', () => logEvent('转码缓冲中...'));
+        video.addEventListener('progress', () => logEvent('转码视频下载进度更新'));
+        if (video.error) logTranscodeError(video);
+        
         let lastProgress = -1;
         
         video.addEventListener('timeupdate', function() {
@@ -2849,9 +4268,61 @@ func generateTranscodeVideoPlayer(w http.ResponseWriter, filePath, fileName stri
         // 双击进入全屏
         video.addEventListener('dblclick', toggleFullscreen);
         
+        // 自适应码率：30秒内缓冲3次时，从当前位置以低一档的清晰度重新转码
+        const transcodeBase = '/transcode/` + url.QueryEscape(filePath) + `';
+        let abrOffset = 0;       // 当前转码流的起始位置（秒）
+        let abrProfile = '';     // 当前清晰度，空表示由服务器自动选择
+        let abrLadder = [];
+        let stallTimes = [];
+        
+        function bandwidthHint() {
+            const conn = navigator.connection;
+            return conn && conn.downlink ? '&bw=' + Math.round(conn.downlink * 1000) : '';
+        }
+        
+        async function stepDown() {
+            try {
+                const data = await (await fetch('/api/abr')).json();
+                abrLadder = data.ladder || [];
+                const current = abrProfile || data.lastProfile || data.profile;
+                const index = abrLadder.indexOf(current);
+                if (index < 0 || index >= abrLadder.length - 1) {
+                    logEvent('已是最低清晰度，无法继续降低');
+                    return;
+                }
+                abrProfile = abrLadder[index + 1];
+                const shift = video.currentTime;
+                abrOffset += shift;
+                // 新的转码流从0开始计时，字幕随之提前
+                Array.from(video.textTracks).forEach(track => Array.from(track.cues || []).forEach(cue => {
+                    cue.startTime -= shift;
+                    cue.endTime -= shift;
+                }));
+                stallTimes = [];
+                logEvent('缓冲频繁，切换到' + abrProfile + '，从' + abrOffset.toFixed(1) + '秒继续');
+                video.src = transcodeBase + '?profile=' + abrProfile + '&start=' + abrOffset.toFixed(3);
+                video.play();
+            } catch (error) {
+                logEvent('切换清晰度失败: ' + error.message);
+            }
+        }
+        
+        video.addEventListener('waiting', function() {
+            const now = Date.now();
+            stallTimes = stallTimes.filter(t => now - t < 30000);
+            stallTimes.push(now);
+            if (stallTimes.length >= 3) {
+                stepDown();
+            }
+        });
+        
         // 页面加载完成
         window.onload = function() {
             logEvent('页面加载完成，准备播放转码视频');
+            if (bandwidthHint()) {
+                video.src = transcodeBase + '?profile=auto' + bandwidthHint();
+                video.play().catch(() => {});
+            }
             ` + func() string {
 		if muteByDefault {
 			return `logEvent('默认静音模式：直接访问URL');`
@@ -2881,7 +4352,7 @@ func generateTranscodeVideoPlayer(w http.ResponseWriter, filePath, fileName stri
 
 // 转码处理器 - 使用ffmpeg实时转码视频
 func transcodeHandler(w http.ResponseWriter, r *http.Request) {
-	if !ffmpegAvailable {
+	if !ffmpegAvailable.Load() {
 		log.Printf("转码请求失败: ffmpeg不可用")
 		http.Error(w, "ffmpeg不可用", http.StatusServiceUnavailable)
 		return
@@ -2889,22 +4360,34 @@ func transcodeHandler(w http.ResponseWriter, r *http.Request) {
 
 	filePath := r.URL.Path[11:] // 去掉 "/transcode/" 前缀
 
-	// 多次URL解码以确保正确处理
-	for i := 0; i < 3; i++ {
-		if decoded, err := url.QueryUnescape(filePath); err == nil {
-			filePath = decoded
-		} else {
-			break
-		}
+	// URL解码并转换为Windows路径
+	filePath = decodeRequestPath(filePath)
+
+	// profile=auto 时按客户端带宽选择清晰度
+	requested := r.URL.Query().Get("profile")
+	if requested == "auto" {
+		var bps float64
+		var source string
+		requested, bps, source = selectAdaptiveProfile(r)
+		rememberProfile(clientKey(r), requested)
+		log.Printf("自适应码率: 带宽%.0fkbps(%s)，选择%s", bps/1000, source, requested)
+	}
+	profileName, profile, err := getTranscodeProfile(requested)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// 替换正斜杠为反斜杠（Windows路径）
-	filePath = strings.ReplaceAll(filePath, "/", "\\")
+	// 从指定位置（秒）开始转码，播放中切换清晰度时使用
+	start := 0.0
+	if s, err := strconv.ParseFloat(r.URL.Query().Get("start"), 64); err == nil && s > 0 {
+		start = s
+	}
 
-	log.Printf("转码请求: %s，来源IP: %s", filePath, r.RemoteAddr)
+	log.Printf("转码请求: %s，转码配置: %s，起始位置: %.1fs，来源IP: %s", filePath, profileName, start, r.RemoteAddr)
 
 	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	if _, err := statPath(filePath); os.IsNotExist(err) {
 		log.Printf("转码文件不存在: %s", filePath)
 		http.Error(w, "文件不存在", http.StatusNotFound)
 		return
@@ -2914,28 +4397,26 @@ func transcodeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "video/mp4")
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Transcode-Profile", profileName)
 
 	// ffmpeg转码命令
 	// -i: 输入文件
-	// -c:v libx264: 视频编码器H.264
-	// -c:a aac: 音频编码器AAC
+	// 编码参数来自转码配置（编码器、质量、码率、缩放等）
 	// -f mp4: 输出格式MP4
 	// -movflags frag_keyframe+empty_moov: 支持流式播放
 	// -: 输出到stdout
-	cmd := exec.Command("ffmpeg",
-		"-i", filePath,
-		"-c:v", "libx264",
-		"-c:a", "aac",
-		"-preset", "fast", // 快速编码预设
-		"-crf", "23", // 视频质量（越小质量越好）
-		"-maxrate", "2M", // 最大码率2Mbps
-		"-bufsize", "4M", // 缓冲区大小
-		"-f", "mp4",
-		"-movflags", "frag_keyframe+empty_moov",
-		"-")
-
-	// 设置命令的stdout为HTTP响应
-	cmd.Stdout = w
+	// 客户端断开时结束ffmpeg进程
+	var args []string
+	if start > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(start, 'f', 3, 64))
+	}
+	args = append(args, "-i", filePath)
+	args = append(args, profile.args()...)
+	args = append(args, "-f", "mp4", "-movflags", "frag_keyframe+empty_moov", "-")
+	cmd := exec.CommandContext(r.Context(), ffmpegBinary(), args...)
+
+	// 设置命令的stdout为HTTP响应，同时测量网络吞吐量
+	cmd.Stdout = newMeteredWriter(w, r)
 
 	// 获取stderr用于错误日志
 	stderr, err := cmd.StderrPipe()
@@ -2983,73 +4464,126 @@ func transcodeHandler(w http.ResponseWriter, r *http.Request) {
 
 // 文件夹浏览API处理器
 func apiBrowseHandler(w http.ResponseWriter, r *http.Request) {
-	folderPath := r.URL.Query().Get("path")
+	folderPath := resolveClientPath(r.URL.Query().Get("path"))
 	if folderPath == "" {
 		http.Error(w, "路径参数不能为空", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("文件夹浏览请求: path=%s, IP=%s", folderPath, r.RemoteAddr)
+	log.Printf("文件夹浏览请求: path=%s, query=%s, IP=%s", folderPath, r.URL.RawQuery, r.RemoteAddr)
+
+	response, status, err := buildBrowseResponse(r.Context(), folderPath, parseBrowseParams(r))
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	recordBrowseVisit(w, r, folderPath)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(response)
+}
 
+// 读取文件夹并生成一页的响应数据，供浏览API和可收藏的浏览页面共用。
+// 出错时返回对应的HTTP状态码
+func buildBrowseResponse(ctx context.Context, folderPath string, params BrowseParams) (*BrowseResponse, int, error) {
 	// 检查路径是否存在且为目录
-	fileInfo, err := os.Stat(folderPath)
+	fileInfo, err := statPath(folderPath)
 	if os.IsNotExist(err) {
 		log.Printf("文件夹不存在: %s", folderPath)
-		http.Error(w, "文件夹不存在", http.StatusNotFound)
-		return
+		return nil, http.StatusNotFound, fmt.Errorf("文件夹不存在")
+	}
+	if err != nil {
+		log.Printf("访问文件夹失败: %s, 错误: %v", folderPath, err)
+		return nil, http.StatusInternalServerError, fmt.Errorf("访问文件夹失败: %v", err)
 	}
 
 	if !fileInfo.IsDir() {
 		log.Printf("路径不是文件夹: %s", folderPath)
-		http.Error(w, "路径不是文件夹", http.StatusBadRequest)
-		return
+		return nil, http.StatusBadRequest, fmt.Errorf("路径不是文件夹")
 	}
 
 	// 读取文件夹内容
-	entries, err := os.ReadDir(folderPath)
+	entries, err := readDirPath(folderPath)
 	if err != nil {
 		log.Printf("读取文件夹失败: %s, 错误: %v", folderPath, err)
-		http.Error(w, "读取文件夹失败: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, fmt.Errorf("读取文件夹失败: %v", err)
 	}
 
-	var results []SearchResult
-	for _, entry := range entries {
+	// 读取文件夹说明（README、descript.ion和服务器端说明）
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	annotation, descriptions := loadFolderAnnotation(folderPath, names)
+	sums := siblingChecksums(folderPath, names)
+
+	results := []SearchResult{}
+	hiddenCount, excludedCount := 0, 0
+	for i, entry := range entries {
+		// 客户端断开后不再读取剩余条目的信息（网络路径上每条都可能较慢）
+		if i%256 == 0 && ctx.Err() != nil {
+			log.Printf("客户端已断开，取消浏览: %s", folderPath)
+			return nil, 0, ctx.Err()
+		}
+
 		entryPath := filepath.Join(folderPath, entry.Name())
+		if isExcludedPath(entryPath) {
+			excludedCount++
+			continue
+		}
+		result := SearchResult{
+			Name:  entry.Name(),
+			Path:  clientPath(entryPath),
+			IsDir: entry.IsDir(),
+			Type:  getResultType(entry.Name(), entry.IsDir()),
+		}
+
+		if !matchBrowseFilter(result, params) {
+			continue
+		}
+		result.Description = descriptions[strings.ToLower(entry.Name())]
+		if sum, ok := sums[strings.ToLower(entry.Name())]; ok && !entry.IsDir() {
+			result.ChecksumFile = clientPath(filepath.Join(folderPath, sum.file))
+		}
+		result.Tags = getTags(entryPath)
+		applyRating(&result, entryPath)
 
-		// 获取详细信息
+		// 获取详细信息（Windows下ReadDir已带回这些数据，不会额外访问磁盘）
 		info, err := entry.Info()
 		if err != nil {
 			log.Printf("获取文件信息失败: %s, 跳过", entryPath)
 			continue
 		}
-
-		result := SearchResult{
-			Name:     entry.Name(),
-			Path:     entryPath,
-			Size:     info.Size(),
-			Modified: info.ModTime().Format("2006-01-02 15:04:05"),
-			IsDir:    entry.IsDir(),
-		}
-
-		// 确定文件类型
-		if result.IsDir {
-			result.Type = "folder"
-		} else {
-			ext := strings.ToLower(filepath.Ext(entry.Name()))
-			switch ext {
-			case ".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm":
-				result.Type = "video"
-			case ".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp":
-				result.Type = "image"
-			default:
-				result.Type = "file"
-			}
+		result.Size = info.Size()
+		result.Modified = info.ModTime().Format("2006-01-02 15:04:05")
+		attrs := getFileAttributes(entryPath, info)
+		applyFileAttributes(&result, attrs)
+		applyLinkInfo(&result, entryPath, attrs)
+		applyShortcutInfo(&result, entryPath)
+
+		// 默认不显示隐藏文件和系统文件
+		if (result.Hidden || result.System) && !params.ShowHidden {
+			hiddenCount++
+			continue
 		}
 
 		results = append(results, result)
 	}
 
+	sortSearchResults(results, params.SortBy, params.Order)
+
+	totalCount := len(results)
+	start, end := pageBounds(totalCount, params.Page, params.PageSize)
+	queueFolderThumbnails(results, start)
+	results = results[start:end]
+	for i := range results {
+		results[i].Index = start + i
+	}
+	applyEverythingProperties(results)
+
 	// 生成路径部分用于面包屑导航
 	pathParts := generatePathParts(folderPath)
 
@@ -3057,19 +4591,27 @@ func apiBrowseHandler(w http.ResponseWriter, r *http.Request) {
 	parentPath := filepath.Dir(folderPath)
 	canGoUp := folderPath != filepath.VolumeName(folderPath) && parentPath != folderPath
 
-	response := BrowseResponse{
-		Results:     results,
-		Count:       len(results),
-		CurrentPath: folderPath,
-		ParentPath:  parentPath,
-		PathParts:   pathParts,
-		CanGoUp:     canGoUp,
+	response := &BrowseResponse{
+		Results:       results,
+		Count:         len(results),
+		TotalCount:    totalCount,
+		Page:          params.Page,
+		PageSize:      params.PageSize,
+		TotalPages:    (totalCount + params.PageSize - 1) / params.PageSize,
+		Sort:          params.SortBy,
+		Order:         params.Order,
+		ShowHidden:    params.ShowHidden,
+		HiddenCount:   hiddenCount,
+		ExcludedCount: excludedCount,
+		CurrentPath:   clientPath(folderPath),
+		ParentPath:    clientPath(parentPath),
+		PathParts:     pathParts,
+		CanGoUp:       canGoUp,
+		Annotation:    annotation,
 	}
 
-	log.Printf("文件夹浏览完成: %s, 返回%d个项目", folderPath, len(results))
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	json.NewEncoder(w).Encode(response)
+	log.Printf("文件夹浏览完成: %s, 共%d个项目, 返回第%d页(%d条)", folderPath, totalCount, params.Page, len(results))
+	return response, http.StatusOK, nil
 }
 
 // 生成路径部分用于面包屑导航
@@ -3079,20 +4621,22 @@ func generatePathParts(fullPath string) []PathPart {
 	// 清理路径并分割
 	cleanPath := filepath.Clean(fullPath)
 
-	// 获取盘符（Windows）
+	// 获取盘符或网络共享根路径（Windows），如 C: 或 \\server\share，其他系统为根目录 /
 	volume := filepath.VolumeName(cleanPath)
-	if volume != "" {
+	root := volume + string(os.PathSeparator)
+	if volume != "" || filepath.IsAbs(cleanPath) {
 		parts = append(parts, PathPart{
-			Name: volume + "\\",
-			Path: volume + "\\",
+			Name:        root,
+			Path:        clientPath(root),
+			SiblingsURL: "/api/siblings?path=" + url.QueryEscape(clientPath(root)),
 		})
-		cleanPath = cleanPath[len(volume)+1:] // 移除盘符部分
+		cleanPath = strings.TrimPrefix(cleanPath[len(volume):], string(os.PathSeparator)) // 移除盘符部分
 	}
 
 	// 分割剩余路径
 	if cleanPath != "" && cleanPath != "." {
 		pathElements := strings.Split(cleanPath, string(os.PathSeparator))
-		currentPath := volume + "\\"
+		currentPath := root
 
 		for _, element := range pathElements {
 			if element == "" {
@@ -3100,8 +4644,9 @@ func generatePathParts(fullPath string) []PathPart {
 			}
 			currentPath = filepath.Join(currentPath, element)
 			parts = append(parts, PathPart{
-				Name: element,
-				Path: currentPath,
+				Name:        element,
+				Path:        clientPath(currentPath),
+				SiblingsURL: "/api/siblings?path=" + url.QueryEscape(clientPath(currentPath)),
 			})
 		}
 	}
@@ -3111,7 +4656,7 @@ func generatePathParts(fullPath string) []PathPart {
 
 // 文本预览API处理器
 func textPreviewHandler(w http.ResponseWriter, r *http.Request) {
-	filePath := r.URL.Query().Get("path")
+	filePath := resolveClientPath(r.URL.Query().Get("path"))
 	if filePath == "" {
 		http.Error(w, "路径参数不能为空", http.StatusBadRequest)
 		return
@@ -3120,7 +4665,7 @@ func textPreviewHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("文本预览请求: path=%s, IP=%s", filePath, r.RemoteAddr)
 
 	// 检查文件是否存在
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := statPath(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("文本文件不存在: %s", filePath)
@@ -3145,7 +4690,7 @@ func textPreviewHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 读取文件内容
-	content, err := os.ReadFile(filePath)
+	content, err := readFilePath(filePath)
 	if err != nil {
 		log.Printf("读取文本文件失败: %s, 错误: %v", filePath, err)
 		http.Error(w, "读取文件失败: "+err.Error(), http.StatusInternalServerError)
@@ -3156,7 +4701,7 @@ func textPreviewHandler(w http.ResponseWriter, r *http.Request) {
 	contentStr := detectAndConvertEncoding(content)
 
 	response := map[string]interface{}{
-		"path":     filePath,
+		"path":     clientPath(filePath),
 		"name":     filepath.Base(filePath),
 		"size":     fileInfo.Size(),
 		"modified": fileInfo.ModTime().Format("2006-01-02 15:04:05"),
@@ -3229,24 +4774,16 @@ func tryGBKDecode(data []byte) string {
 
 // 图片查看器页面处理器
 func imageViewerHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
 	filePath := r.URL.Path[11:] // 去掉 "/imageview/" 前缀
 
-	// 多次URL解码以确保正确处理
-	for i := 0; i < 3; i++ {
-		if decoded, err := url.QueryUnescape(filePath); err == nil {
-			filePath = decoded
-		} else {
-			break
-		}
-	}
-
-	// 替换正斜杠为反斜杠（Windows路径）
-	filePath = strings.ReplaceAll(filePath, "/", "\\")
+	// URL解码并转换为Windows路径
+	filePath = decodeRequestPath(filePath)
 
 	log.Printf("图片查看器请求: %s，来源IP: %s", filePath, r.RemoteAddr)
 
 	// 检查文件是否存在
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := statPath(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("图片文件不存在: %s", filePath)
@@ -3258,9 +4795,12 @@ func imageViewerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 检查是否为图片文件
+	// 检查是否为图片文件，SVG净化后显示（见svg.go）
 	ext := strings.ToLower(filepath.Ext(filePath))
-	if !isImageFile(ext) {
+	imageSrc := "/file/" + url.QueryEscape(filePath)
+	if ext == ".svg" {
+		imageSrc = "/svg/" + url.QueryEscape(filePath)
+	} else if !isImageFile(ext) {
 		log.Printf("非图片文件: %s", filePath)
 		http.Error(w, "不是图片文件", http.StatusBadRequest)
 		return
@@ -3272,9 +4812,10 @@ func imageViewerHandler(w http.ResponseWriter, r *http.Request) {
 	tmpl := `<!DOCTYPE html>
 <html lang="zh-CN">
 <head>
+    ` + csrfFetchScript(nonce) + `
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>图片查看器 - ` + fileName + `</title>
+    <title>图片查看器 - ` + html.EscapeString(fileName) + `</title>
     <style>
         * { box-sizing: border-box; margin: 0; padding: 0; }
         body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #000; color: white; overflow: hidden; }
@@ -3340,23 +4881,23 @@ func imageViewerHandler(w http.ResponseWriter, r *http.Request) {
         <div class="header">
             <div class="header-content">
                 <div class="image-info">
-                    <div class="image-title">` + fileName + `</div>
-                    <div class="image-meta">文件大小: ` + fmt.Sprintf("%.2f MB", fileSizeMB) + ` • 路径: ` + filePath + `</div>
+                    <div class="image-title">` + html.EscapeString(fileName) + `</div>
+                    <div class="image-meta">文件大小: ` + fmt.Sprintf("%.2f MB", fileSizeMB) + ` • 路径: ` + html.EscapeString(filePath) + `</div>
                 </div>
                 <div class="controls">
                     <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>下载图片</a>
-                    <button class="btn btn-secondary" onclick="window.close()">关闭窗口</button>
+                    <button class="btn btn-secondary" data-click="closeWindow">关闭窗口</button>
                 </div>
             </div>
         </div>
         
         <div class="image-container">
             <div class="loading" id="loading">加载中...</div>
-            <img class="image-display" id="imageDisplay" src="/file/` + url.QueryEscape(filePath) + `" 
-                 alt="` + fileName + `" 
-                 onload="imageLoaded()" 
-                 onerror="imageError()"
-                 onclick="toggleZoom()"
+            <img class="image-display" id="imageDisplay" src="` + imageSrc + `" 
+                 alt="` + html.EscapeString(fileName) + `" 
+                 data-load="imageLoaded"
+                 data-error="imageError"
+                 data-click="toggleZoom"
                  style="display: none;">
         </div>
         
@@ -3365,7 +4906,20 @@ func imageViewerHandler(w http.ResponseWriter, r *http.Request) {
         </div>
     </div>
 
-    <script>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({
+            closeWindow: () => window.close(),
+            imageLoaded: () => imageLoaded(),
+            imageError: () => imageError(),
+            toggleZoom: () => toggleZoom(),
+        });
+
+        // 脚本执行前已加载完（缓存）的图片不会再触发load事件
+        const imageDisplay = document.getElementById('imageDisplay');
+        if (imageDisplay.complete) {
+            if (imageDisplay.naturalWidth) imageLoaded(); else imageError();
+        }
+
         let isZoomed = false;
         
         function imageLoaded() {
@@ -3384,13 +4938,13 @@ func imageViewerHandler(w http.ResponseWriter, r *http.Request) {
             
             statusBar.innerHTML = '原始尺寸: ' + naturalWidth + ' × ' + naturalHeight + ' • 显示尺寸: ' + displayWidth + ' × ' + displayHeight + ' • 点击放大/缩小 • ESC键关闭';
             
-            console.log('图片加载完成:', '` + filePath + `', naturalWidth + 'x' + naturalHeight);
+            console.log('图片加载完成:', ` + jsString(filePath) + `, naturalWidth + 'x' + naturalHeight);
         }
         
         function imageError() {
             const loading = document.getElementById('loading');
             loading.innerHTML = '图片加载失败';
-            console.error('图片加载失败:', '` + filePath + `');
+            console.error('图片加载失败:', ` + jsString(filePath) + `);
         }
         
         function toggleZoom() {
@@ -3420,7 +4974,7 @@ func imageViewerHandler(w http.ResponseWriter, r *http.Request) {
             e.preventDefault();
         });
         
-        console.log('图片查看器初始化完成:', '` + fileName + `');
+        console.log('图片查看器初始化完成:', ` + jsString(fileName) + `);
     </script>
 </body>
 </html>`
@@ -3431,24 +4985,16 @@ func imageViewerHandler(w http.ResponseWriter, r *http.Request) {
 
 // 文本查看器页面处理器
 func textViewerHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
 	filePath := r.URL.Path[10:] // 去掉 "/textview/" 前缀
 
-	// 多次URL解码以确保正确处理
-	for i := 0; i < 3; i++ {
-		if decoded, err := url.QueryUnescape(filePath); err == nil {
-			filePath = decoded
-		} else {
-			break
-		}
-	}
-
-	// 替换正斜杠为反斜杠（Windows路径）
-	filePath = strings.ReplaceAll(filePath, "/", "\\")
+	// URL解码并转换为Windows路径
+	filePath = decodeRequestPath(filePath)
 
 	log.Printf("文本查看器请求: %s，来源IP: %s", filePath, r.RemoteAddr)
 
 	// 检查文件是否存在
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := statPath(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("文本文件不存在: %s", filePath)
@@ -3484,7 +5030,7 @@ func textViewerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 读取文件内容
-	content, err := os.ReadFile(filePath)
+	content, err := readFilePath(filePath)
 	if err != nil {
 		log.Printf("读取文本文件失败: %s, 错误: %v", filePath, err)
 		http.Error(w, "读取文件失败: "+err.Error(), http.StatusInternalServerError)
@@ -3506,9 +5052,10 @@ func textViewerHandler(w http.ResponseWriter, r *http.Request) {
 	tmpl := `<!DOCTYPE html>
 <html lang="zh-CN">
 <head>
+    ` + csrfFetchScript(nonce) + `
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>文本查看器 - ` + fileName + `</title>
+    <title>文本查看器 - ` + html.EscapeString(fileName) + `</title>
     <style>
         * { box-sizing: border-box; margin: 0; padding: 0; }
         body { font-family: 'Consolas', 'Monaco', 'Courier New', monospace; background: #1e1e1e; color: #d4d4d4; line-height: 1.5; }
@@ -3578,7 +5125,7 @@ func textViewerHandler(w http.ResponseWriter, r *http.Request) {
         <div class="header">
             <div class="header-content">
                 <div class="file-info">
-                    <div class="file-title">` + fileName + `</div>
+                    <div class="file-title">` + html.EscapeString(fileName) + `</div>
                     <div class="file-meta">
                         <span>大小: ` + fmt.Sprintf("%.2f MB", fileSizeMB) + `</span>
                         <span>行数: ` + strconv.Itoa(lineCount) + `</span>
@@ -3587,16 +5134,16 @@ func textViewerHandler(w http.ResponseWriter, r *http.Request) {
                     </div>
                 </div>
                 <div class="controls">
-                    <button class="btn btn-info" onclick="toggleSearch()">搜索</button>
-                    <button class="btn btn-secondary" onclick="selectAll()">全选</button>
+                    <button class="btn btn-info" data-click="toggleSearch">搜索</button>
+                    <button class="btn btn-secondary" data-click="selectAll">全选</button>
                     <a href="/file/` + url.QueryEscape(filePath) + `?download=1" class="btn btn-primary" download>下载</a>
-                    <button class="btn btn-secondary" onclick="window.close()">关闭</button>
+                    <button class="btn btn-secondary" data-click="closeWindow">关闭</button>
                 </div>
             </div>
         </div>
         
         <div class="search-box" id="searchBox">
-            <input type="text" class="search-input" id="searchInput" placeholder="输入搜索内容..." onkeyup="performSearch()" oninput="performSearch()">
+            <input type="text" class="search-input" id="searchInput" placeholder="输入搜索内容..." data-keyup="performSearch" data-input="performSearch">
         </div>
         
         <div class="content-container">
@@ -3605,12 +5152,19 @@ func textViewerHandler(w http.ResponseWriter, r *http.Request) {
         
         <div class="status-bar">
             <div class="language-info">` + language + ` • ` + encoding + `</div>
-            <div>` + filePath + `</div>
+            <div>` + html.EscapeString(filePath) + `</div>
             <div>` + strconv.Itoa(lineCount) + ` 行 • ` + fmt.Sprintf("%.2f MB", fileSizeMB) + `</div>
         </div>
     </div>
 
-    <script>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({
+            closeWindow: () => window.close(),
+            toggleSearch: () => toggleSearch(),
+            selectAll: () => selectAll(),
+            performSearch: () => performSearch(),
+        });
+
         const originalContent = document.getElementById('contentArea').textContent;
         const lines = originalContent.split('\n');
         const lineCount = lines.length;
@@ -3693,7 +5247,7 @@ func textViewerHandler(w http.ResponseWriter, r *http.Request) {
         
         // 初始化
         window.onload = function() {
-            console.log('文本查看器初始化完成:', '` + fileName + `', lineCount + ' 行');
+            console.log('文本查看器初始化完成:', ` + jsString(fileName) + `, lineCount + ' 行');
         };
         
         // 滚动功能已简化
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 定时维护任务的具体实现：缓存目录容量控制、预热搜索、日志轮转和外部工具检测
+
+const (
+	cacheDirName        = "cache" // 数据目录下的缓存文件夹，缩略图、转码结果等放在其子目录中
+	defaultCacheQuotaMB = 1024
+	defaultLogKeep      = 7
+)
+
+// 获取缓存子目录，不存在时自动创建
+func getCacheDir(name string) string {
+	dir := filepath.Join(getDataDir(), cacheDirName, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("创建缓存目录失败: %s, 错误: %v", dir, err)
+	}
+	return dir
+}
+
+func cacheQuota() int64 {
+	quota := serverConfig.CacheQuotaMB
+	if quota <= 0 {
+		quota = defaultCacheQuotaMB
+	}
+	return int64(quota) << 20
+}
+
+// 缓存超过各种类的上限或总上限时，从最久未使用的文件开始删除，直到降到上限的90%
+func purgeCacheDirs(ctx context.Context) error {
+	return enforceCacheQuotas(ctx, filepath.Join(getDataDir(), cacheDirName))
+}
+
+// 重新执行配置的预热搜索，刷新搜索缓存
+func refreshWarmQueries(ctx context.Context) error {
+	var failed []string
+	for _, query := range serverConfig.WarmQueries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		cacheMutex.Lock()
+		delete(searchCache, query)
+		cacheMutex.Unlock()
+
+		if _, total, _, err := searchFilesWithCache(ctx, query, 1, 1); err != nil {
+			log.Printf("预热搜索失败: %s, 错误: %v", query, err)
+			failed = append(failed, query)
+		} else {
+			log.Printf("预热搜索完成: %s, %d条结果", query, total)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d个预热搜索失败: %v", len(failed), failed)
+	}
+	return nil
+}
+
+// 重新检测ffmpeg和Everything是否可用
+func recheckTools(ctx context.Context) error {
+	detectTools()
+	return nil
+}
+
+// 可轮转的日志文件
+type rotatingLogFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+var logFile *rotatingLogFile
+
+// 配置了日志文件时，把日志同时写入控制台和文件
+func setupLogFile() {
+	if serverConfig.LogFile == "" {
+		return
+	}
+
+	path := serverConfig.LogFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(getDataDir(), path)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("打开日志文件失败: %s, 错误: %v", path, err)
+		return
+	}
+
+	logFile = &rotatingLogFile{path: path, file: file}
+	log.SetOutput(io.MultiWriter(os.Stderr, logFile))
+	log.Printf("日志同时写入: %s", path)
+}
+
+func (l *rotatingLogFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return len(p), nil
+	}
+	return l.file.Write(p)
+}
+
+// 把当前日志改名为 .1，原有的 .1 改为 .2，依此类推，超过保留数量的删除
+func (l *rotatingLogFile) rotate(keep int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	if info, err := l.file.Stat(); err == nil && info.Size() == 0 {
+		return nil
+	}
+
+	l.file.Close()
+	os.Remove(fmt.Sprintf("%s.%d", l.path, keep))
+	for i := keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	renameErr := os.Rename(l.path, l.path+".1")
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		// 无法重新打开时停止写文件日志，控制台日志不受影响
+		l.file = nil
+		return fmt.Errorf("重新打开日志文件失败: %v", err)
+	}
+	l.file = file
+	return renameErr
+}
+
+func rotateLogs(ctx context.Context) error {
+	if logFile == nil {
+		return nil
+	}
+	keep := serverConfig.LogKeep
+	if keep <= 0 {
+		keep = defaultLogKeep
+	}
+	return logFile.rotate(keep)
+}
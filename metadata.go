@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 搜索结果页的文件信息获取
+//
+// 一页最多200个路径，逐个stat时一个无响应的网络路径就会让整个请求卡住几分钟。
+// 这里以有限的并发获取文件信息，每个文件有单独的超时，整页也有总的时间预算；
+// 超时的条目仍然返回，但只包含名称和路径，并标记partial。
+
+const (
+	metadataWorkers     = 16
+	metadataFileTimeout = 3 * time.Second
+	metadataPageBudget  = 10 * time.Second
+)
+
+type statResult struct {
+	info os.FileInfo
+	err  error
+}
+
+// 在超时内获取文件信息。超时后stat仍在后台运行，结果被丢弃
+func statWithTimeout(ctx context.Context, path string, timeout time.Duration) (os.FileInfo, bool, error) {
+	ch := make(chan statResult, 1)
+	go func() {
+		info, err := statPath(path)
+		ch <- statResult{info, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-ch:
+		return res.info, false, res.err
+	case <-timer.C:
+		return nil, true, nil
+	case <-ctx.Done():
+		return nil, true, nil
+	}
+}
+
+// 并发获取一页路径的文件信息，保持原有顺序，跳过无法访问的文件
+func gatherPageMetadata(ctx context.Context, paths []string, offset int) []SearchResult {
+	ctx, cancel := context.WithTimeout(ctx, metadataPageBudget)
+	defer cancel()
+
+	results := make([]*SearchResult, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < metadataWorkers && w < len(paths); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = buildPageResult(ctx, paths[i], offset+i+1)
+			}
+		}()
+	}
+
+	for i := range paths {
+		if ctx.Err() != nil {
+			// 超出整页时间预算，剩余条目不再访问
+			results[i] = partialResult(paths[i])
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	partial := 0
+	list := make([]SearchResult, 0, len(paths))
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		result.Index = offset + i
+		if result.Partial {
+			partial++
+		}
+		list = append(list, *result)
+	}
+	if partial > 0 {
+		log.Printf("有%d个文件在时间限制内未能获取信息，仅返回路径", partial)
+	}
+	applyEverythingProperties(list)
+	applyChecksumFiles(ctx, list)
+	return list
+}
+
+// 获取单个路径的结果，无法访问时返回nil
+func buildPageResult(ctx context.Context, filePath string, index int) *SearchResult {
+	info, timedOut, err := statWithTimeout(ctx, filePath, metadataFileTimeout)
+	if timedOut {
+		log.Printf("获取文件信息超时[%d]: %s", index, filePath)
+		return partialResult(filePath)
+	}
+	if err != nil {
+		log.Printf("无法访问文件[%d]: %s, 错误: %v", index, filePath, err)
+		return nil // 跳过无法访问的文件
+	}
+
+	result := &SearchResult{
+		Name:     filepath.Base(filePath),
+		Path:     clientPath(filePath),
+		Size:     info.Size(),
+		Modified: info.ModTime().Format("2006-01-02 15:04:05"),
+		IsDir:    info.IsDir(),
+	}
+
+	// 确定文件类型
+	result.Type = getResultType(filePath, result.IsDir)
+	result.Tags = getTags(filePath)
+	applyRating(result, filePath)
+	applyShortcutInfo(result, filePath)
+	return result
+}
+
+// 只包含路径信息的结果，类型按扩展名推断
+func partialResult(filePath string) *SearchResult {
+	result := &SearchResult{
+		Name:    filepath.Base(filePath),
+		Path:    clientPath(filePath),
+		Type:    getResultType(filePath, false),
+		Partial: true,
+	}
+	result.Tags = getTags(filePath)
+	return result
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// 按文件内容识别MIME类型
+//
+// 扩展名未知（或只能识别为application/octet-stream）时读取文件开头512字节，用http.DetectContentType
+// 和补充的文件头特征识别类型；扩展名已知但内容明显是另一种图片、音视频或PDF时（如实际为PNG的.jpg），
+// 以内容为准。按内容识别时不会得到text/html等可以执行脚本的类型，HTML文件只按扩展名识别。
+// data\config.json 中的mimeTypes（如 {".heic": "image/heic"}）优先于内置的对应关系和内容识别。
+
+const sniffLength = 512
+
+// http.DetectContentType不能识别的格式
+var magicNumbers = []struct {
+	offset int
+	magic  []byte
+	mime   string
+}{
+	{0, []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}, "application/x-7z-compressed"},
+	{0, []byte("FLV\x01"), "video/x-flv"},
+	{0, []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11}, "video/x-ms-wmv"},
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{4, []byte("ftypavif"), "image/avif"},
+	{4, []byte("ftypheic"), "image/heic"},
+	{4, []byte("ftypqt  "), "video/quicktime"},
+	{4, []byte("ftypisom"), "video/mp4"},
+}
+
+// 配置的扩展名对应的MIME类型，扩展名不区分大小写，可以省略点号
+func configuredContentType(ext string) string {
+	for key, value := range serverConfig.MimeTypes {
+		if strings.EqualFold(strings.TrimPrefix(key, "."), strings.TrimPrefix(ext, ".")) {
+			return value
+		}
+	}
+	return ""
+}
+
+// 按文件开头的内容识别MIME类型，无法识别时返回application/octet-stream
+func sniffContentType(head []byte) string {
+	for _, m := range magicNumbers {
+		if len(head) >= m.offset+len(m.magic) && bytes.Equal(head[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.mime
+		}
+	}
+	// DetectContentType把所有EBML文件识别为WebM，按DocType区分Matroska
+	if bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}) && bytes.Contains(head, []byte("matroska")) {
+		return "video/x-matroska"
+	}
+
+	detected := http.DetectContentType(head)
+	mediaType, _, _ := strings.Cut(detected, ";")
+	switch mediaType {
+	case "text/html", "text/xml", "application/xml":
+		// 不按内容把文件识别为可以执行脚本的类型
+		return "text/plain; charset=utf-8"
+	}
+	return detected
+}
+
+// 内容识别结果是否足以纠正扩展名
+func isSpecificSniffedType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") ||
+		strings.HasPrefix(contentType, "video/") ||
+		strings.HasPrefix(contentType, "audio/") ||
+		contentType == "application/pdf"
+}
+
+// 文件的Content-Type：配置、扩展名和文件内容综合判断
+func detectContentType(filePath, ext string) string {
+	if configured := configuredContentType(ext); configured != "" {
+		return configured
+	}
+	byExt := getContentType(ext)
+
+	file, err := openPath(filePath)
+	if err != nil {
+		return byExt // 远程文件等无法读取内容时只按扩展名
+	}
+	defer file.Close()
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && n == 0 {
+		return byExt
+	}
+	sniffed := sniffContentType(head[:n])
+
+	if byExt == "application/octet-stream" || contentTypeConflicts(sniffed, byExt) {
+		return sniffed
+	}
+	return byExt
+}
+
+// 内容与扩展名是否明显不符：大类不同（如.jpg实际是视频），或都是图片但格式不同。
+// 音视频容器的类型名不统一（video/avi和video/x-msvideo），同为视频或音频时以扩展名为准
+func contentTypeConflicts(sniffed, byExt string) bool {
+	if !isSpecificSniffedType(sniffed) {
+		return false
+	}
+	sniffedType, _, _ := strings.Cut(sniffed, ";")
+	extType, _, _ := strings.Cut(byExt, ";")
+	sniffedTop, _, _ := strings.Cut(sniffedType, "/")
+	extTop, _, _ := strings.Cut(extType, "/")
+	if !strings.EqualFold(sniffedTop, extTop) {
+		return true
+	}
+	return sniffedTop == "image" && !strings.EqualFold(sniffedType, extType)
+}
+
+// 系统登记的扩展名类型（Windows注册表、/etc/mime.types等）
+func systemContentType(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	return mime.TypeByExtension(ext)
+}
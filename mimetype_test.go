@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	jpegHeader = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+	mkvHeader  = []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska")
+)
+
+func TestSniffContentType(t *testing.T) {
+	tests := []struct {
+		head []byte
+		want string
+	}{
+		{pngHeader, "image/png"},
+		{jpegHeader, "image/jpeg"},
+		{mkvHeader, "video/x-matroska"},
+		{[]byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C, 0, 4}, "application/x-7z-compressed"},
+		{[]byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"), "image/avif"},
+		{[]byte("<!DOCTYPE html><script>alert(1)</script>"), "text/plain; charset=utf-8"},
+		{[]byte{0, 1, 2, 3}, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := sniffContentType(tt.head); got != tt.want {
+			t.Errorf("sniffContentType(%q) = %q, want %q", tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	serverConfig.MimeTypes = map[string]string{"RAW": "image/x-raw"}
+	defer func() { serverConfig.MimeTypes = nil }()
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"photo.jpg", jpegHeader, "image/jpeg"},
+		{"renamed.jpg", pngHeader, "image/png"},      // 扩展名错误
+		{"image.unknownext", pngHeader, "image/png"}, // 扩展名未知
+		{"movie.download", mkvHeader, "video/x-matroska"},
+		{"page.unknownext", []byte("<html><body>hi</body></html>"), "text/plain; charset=utf-8"},
+		{"notes.txt", []byte("<html>not really</html>"), "text/plain"},
+		{"camera.raw", pngHeader, "image/x-raw"}, // 配置优先
+		{"empty.unknownext", nil, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		path := write(tt.name, tt.data)
+		if got := detectContentType(path, filepath.Ext(tt.name)); got != tt.want {
+			t.Errorf("detectContentType(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 镜像子命令：everything-web-server.exe mirror -url http://服务器:8080 "D:\照片" E:\备份\照片
+//
+// 获取远程文件夹的同步清单（/api/sync/manifest），与本地文件夹比较后只拉取新增和变化的文件
+// （/api/sync/pull，按批次打包为tar流），写入后恢复修改时间。默认按大小和修改时间判断变化，
+// -hash sha256 时按校验和判断；-delete 删除本地多出的文件和文件夹；-n 只列出要做的操作。
+
+const (
+	mirrorTempSuffix = ".ewsync.tmp"
+	mirrorBatchBytes = 256 << 20 // 每批最多拉取的字节数
+)
+
+type mirrorStats struct {
+	added, updated, deleted, unchanged, failed int
+	bytes                                      int64
+}
+
+func runMirror(args []string) int {
+	fs := flag.NewFlagSet("mirror", flag.ContinueOnError)
+	baseURL := fs.String("url", "http://127.0.0.1:8080", "服务器地址")
+	hashAlgo := fs.String("hash", "", "按校验和比较：sha256、sha1或md5，默认按大小和修改时间")
+	deleteExtra := fs.Bool("delete", false, "删除本地多出的文件和文件夹")
+	batch := fs.Int("batch", 500, "每批拉取的文件数")
+	dryRun := fs.Bool("n", false, "只列出要做的操作，不修改本地文件")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "用法: mirror [-url 服务器地址] [-hash sha256] [-delete] [-n] 远程文件夹 本地文件夹")
+		return 2
+	}
+	if *hashAlgo != "" && checksumAlgorithms[*hashAlgo] == nil {
+		fmt.Fprintf(os.Stderr, "不支持的校验算法: %s\n", *hashAlgo)
+		return 2
+	}
+	*batch = max(1, min(*batch, maxSyncPullFiles))
+	remoteRoot, localRoot := fs.Arg(0), fs.Arg(1)
+	base := strings.TrimRight(*baseURL, "/")
+	client := &http.Client{}
+
+	manifest, err := fetchSyncManifest(client, base, remoteRoot, *hashAlgo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "获取同步清单失败: %v\n", err)
+		return 1
+	}
+	for _, e := range manifest.Errors {
+		fmt.Fprintf(os.Stderr, "  服务器无法读取: %s\n", e)
+	}
+	fmt.Printf("远程: %s, %d个文件, %s\n", manifest.Root, manifest.Files, formatSize(manifest.TotalSize))
+
+	if !*dryRun {
+		if err := os.MkdirAll(localRoot, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "创建本地文件夹失败: %v\n", err)
+			return 1
+		}
+	}
+	local := scanMirrorLocal(localRoot)
+
+	// 比较清单，收集需要拉取的文件
+	var stats mirrorStats
+	var pull []SyncEntry
+	remote := make(map[string]bool, len(manifest.Entries))
+	for _, e := range manifest.Entries {
+		remote[e.Path] = true
+		dest, err := syncFilePath(localRoot, e.Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  跳过: %v\n", err)
+			continue
+		}
+		if e.Dir {
+			if !*dryRun {
+				os.MkdirAll(dest, 0755)
+			}
+			continue
+		}
+		info, exists := local[e.Path]
+		switch {
+		case !exists:
+			stats.added++
+		case mirrorChanged(dest, info, e, *hashAlgo):
+			stats.updated++
+		default:
+			stats.unchanged++
+			continue
+		}
+		pull = append(pull, e)
+	}
+
+	if *dryRun {
+		for _, e := range pull {
+			fmt.Printf("  拉取: %s (%s)\n", e.Path, formatSize(e.Size))
+		}
+	}
+	for start := 0; start < len(pull) && !*dryRun; {
+		end, size := start, int64(0)
+		for end < len(pull) && end-start < *batch && (end == start || size+pull[end].Size <= mirrorBatchBytes) {
+			size += pull[end].Size
+			end++
+		}
+		n, err := pullSyncBatch(client, base, manifest.Root, localRoot, pull[start:end])
+		stats.bytes += n
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  拉取失败: %v\n", err)
+			stats.failed++
+		}
+		start = end
+		fmt.Printf("  已拉取 %d/%d 个文件, %s\n", end, len(pull), formatSize(stats.bytes))
+	}
+
+	if *deleteExtra {
+		// 深层的路径先删除，文件夹删除前已经清空
+		var extra []string
+		for rel := range local {
+			if !remote[rel] {
+				extra = append(extra, rel)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(extra)))
+		for _, rel := range extra {
+			fmt.Printf("  删除: %s\n", rel)
+			if !*dryRun {
+				if err := os.Remove(filepath.Join(localRoot, filepath.FromSlash(rel))); err != nil {
+					fmt.Fprintf(os.Stderr, "  删除失败: %v\n", err)
+					continue
+				}
+			}
+			stats.deleted++
+		}
+	}
+
+	fmt.Printf("完成: 新增%d, 更新%d, 删除%d, 未变化%d, 传输%s\n", stats.added, stats.updated, stats.deleted, stats.unchanged, formatSize(stats.bytes))
+	if stats.failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d批拉取失败，重新运行可以继续\n", stats.failed)
+		return 1
+	}
+	return 0
+}
+
+func fetchSyncManifest(client *http.Client, base, root, algo string) (*SyncManifest, error) {
+	target := base + "/api/sync/manifest?path=" + url.QueryEscape(root)
+	if algo != "" {
+		target += "&hash=" + algo
+	}
+	resp, err := client.Get(target)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("状态%d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var manifest SyncManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// 本地文件夹中的所有条目（相对路径，使用/分隔），跳过上次中断留下的临时文件
+func scanMirrorLocal(root string) map[string]os.FileInfo {
+	local := make(map[string]os.FileInfo)
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasSuffix(path, mirrorTempSuffix) {
+			os.Remove(path)
+			return nil
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != "." {
+			local[filepath.ToSlash(rel)] = info
+		}
+		return nil
+	})
+	return local
+}
+
+// 本地文件与清单中的条目是否不同
+func mirrorChanged(path string, info os.FileInfo, e SyncEntry, algo string) bool {
+	if info.IsDir() || info.Size() != e.Size {
+		return true
+	}
+	if algo == "" || e.Hash == "" {
+		return info.ModTime().Unix() != e.MTime
+	}
+	sum, err := computeChecksum(context.Background(), path, checksumAlgorithms[algo]())
+	return err != nil || sum != e.Hash
+}
+
+// 拉取一批文件并写入本地文件夹，返回写入的字节数
+func pullSyncBatch(client *http.Client, base, remoteRoot, localRoot string, entries []SyncEntry) (int64, error) {
+	wanted := make(map[string]bool, len(entries))
+	files := make([]string, len(entries))
+	for i, e := range entries {
+		files[i] = e.Path
+		wanted[e.Path] = true
+	}
+	body, _ := json.Marshal(map[string]interface{}{"path": remoteRoot, "files": files})
+	resp, err := client.Post(base+"/api/sync/pull", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("状态%d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var written int64
+	tr := tar.NewReader(resp.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		if hdr.Typeflag != tar.TypeReg || !wanted[hdr.Name] {
+			return written, fmt.Errorf("服务器返回了未请求的文件: %s", hdr.Name)
+		}
+		delete(wanted, hdr.Name)
+
+		dest, err := syncFilePath(localRoot, hdr.Name)
+		if err != nil {
+			return written, err
+		}
+		n, err := writeMirrorFile(dest, tr, hdr.ModTime)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+	}
+
+	if len(wanted) > 0 {
+		missing := make([]string, 0, len(wanted))
+		for name := range wanted {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return written, fmt.Errorf("服务器未能提供%d个文件: %s", len(missing), strings.Join(missing, ", "))
+	}
+	return written, nil
+}
+
+// 先写入临时文件，完整后替换目标文件并恢复修改时间
+func writeMirrorFile(dest string, src io.Reader, modTime time.Time) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, err
+	}
+	tmp := dest + mirrorTempSuffix
+	file, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(file, src)
+	err = errors.Join(err, file.Close())
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return n, err
+	}
+	return n, os.Chtimes(dest, modTime, modTime)
+}
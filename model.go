@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 3D模型预览
+//
+// GET /modelview/文件路径  用three.js显示.stl、.obj、.gltf、.glb模型（拖动旋转、滚轮缩放、线框）
+// GET /model/文件路径      STL和OBJ转换为glTF二进制（.glb）返回
+// glTF文件由页面直接通过/file/加载，相对路径引用的缓冲区和贴图随之解析。
+// 转换只保留几何形状（OBJ的材质和贴图坐标忽略），按面计算法线，结果不缓存。
+// three.js从外部加载（默认unpkg.com），地址可以在配置中修改，模型页面的CSP只额外允许这个来源。
+
+const (
+	defaultThreeJSURL = "https://unpkg.com/three@0.160.0/"
+	maxModelFileSize  = 256 << 20
+	maxModelTriangles = 5000000
+)
+
+var modelExtensions = []string{".stl", ".obj", ".gltf", ".glb"}
+
+func isModelFile(ext string) bool {
+	for _, e := range modelExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// 三角形网格，每9个数为一个三角形的三个顶点
+type triangleMesh struct {
+	positions []float32
+}
+
+func (m *triangleMesh) addTriangle(a, b, c [3]float32) error {
+	if len(m.positions)/9 >= maxModelTriangles {
+		return fmt.Errorf("模型超过%d个三角形", maxModelTriangles)
+	}
+	m.positions = append(m.positions, a[0], a[1], a[2], b[0], b[1], b[2], c[0], c[1], c[2])
+	return nil
+}
+
+func (m *triangleMesh) triangles() int {
+	return len(m.positions) / 9
+}
+
+// 解析STL，二进制格式按文件大小判断（有些二进制文件的头部也以solid开头）
+func parseSTL(data []byte) (*triangleMesh, error) {
+	if len(data) >= 84 {
+		n := binary.LittleEndian.Uint32(data[80:84])
+		if uint64(len(data)) == 84+50*uint64(n) {
+			return parseBinarySTL(data[84:], int(n))
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return parseASCIISTL(data)
+	}
+	return nil, fmt.Errorf("不是有效的STL文件")
+}
+
+func parseBinarySTL(data []byte, n int) (*triangleMesh, error) {
+	if n > maxModelTriangles {
+		return nil, fmt.Errorf("模型超过%d个三角形", maxModelTriangles)
+	}
+	mesh := &triangleMesh{positions: make([]float32, 0, n*9)}
+	for i := 0; i < n; i++ {
+		rec := data[i*50+12 : i*50+48] // 跳过法线，按顶点重新计算
+		var v [3][3]float32
+		for j := range 9 {
+			v[j/3][j%3] = math.Float32frombits(binary.LittleEndian.Uint32(rec[j*4:]))
+		}
+		mesh.addTriangle(v[0], v[1], v[2])
+	}
+	return mesh, nil
+}
+
+func parseASCIISTL(data []byte) (*triangleMesh, error) {
+	mesh := &triangleMesh{}
+	var facet [][3]float32
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "outer":
+			facet = facet[:0]
+		case "vertex":
+			v, err := parseVec3(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("第%d行: %v", line, err)
+			}
+			facet = append(facet, v)
+		case "endloop":
+			// 多于三个顶点的面按扇形拆分
+			for i := 2; i < len(facet); i++ {
+				if err := mesh.addTriangle(facet[0], facet[i-1], facet[i]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if mesh.triangles() == 0 {
+		return nil, fmt.Errorf("STL文件中没有三角形")
+	}
+	return mesh, nil
+}
+
+// 解析OBJ的顶点和面，面按扇形拆分为三角形，支持负数（相对）索引
+func parseOBJ(data []byte) (*triangleMesh, error) {
+	mesh := &triangleMesh{}
+	var vertices [][3]float32
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			v, err := parseVec3(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("第%d行: %v", line, err)
+			}
+			vertices = append(vertices, v)
+		case "f":
+			var face [][3]float32
+			for _, f := range fields[1:] {
+				ref, _, _ := strings.Cut(f, "/")
+				idx, err := strconv.Atoi(ref)
+				if err != nil || idx == 0 {
+					return nil, fmt.Errorf("第%d行: 无效的顶点索引 %s", line, f)
+				}
+				if idx < 0 {
+					idx += len(vertices) + 1
+				}
+				if idx < 1 || idx > len(vertices) {
+					return nil, fmt.Errorf("第%d行: 顶点索引%d超出范围", line, idx)
+				}
+				face = append(face, vertices[idx-1])
+			}
+			for i := 2; i < len(face); i++ {
+				if err := mesh.addTriangle(face[0], face[i-1], face[i]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if mesh.triangles() == 0 {
+		return nil, fmt.Errorf("OBJ文件中没有面")
+	}
+	return mesh, nil
+}
+
+func parseVec3(fields []string) ([3]float32, error) {
+	var v [3]float32
+	if len(fields) < 3 {
+		return v, fmt.Errorf("顶点坐标不足三个")
+	}
+	for i := range 3 {
+		f, err := strconv.ParseFloat(fields[i], 32)
+		if err != nil {
+			return v, fmt.Errorf("无效的坐标 %s", fields[i])
+		}
+		v[i] = float32(f)
+	}
+	return v, nil
+}
+
+// 三角形的单位法线，退化的三角形返回(0, 0, 1)
+func faceNormal(p []float32) [3]float32 {
+	ux, uy, uz := p[3]-p[0], p[4]-p[1], p[5]-p[2]
+	vx, vy, vz := p[6]-p[0], p[7]-p[1], p[8]-p[2]
+	nx, ny, nz := uy*vz-uz*vy, uz*vx-ux*vz, ux*vy-uy*vx
+	length := float32(math.Sqrt(float64(nx*nx + ny*ny + nz*nz)))
+	if length == 0 || math.IsNaN(float64(length)) || math.IsInf(float64(length), 0) {
+		return [3]float32{0, 0, 1}
+	}
+	return [3]float32{nx / length, ny / length, nz / length}
+}
+
+// 以glTF二进制格式（.glb）写出网格：一个节点、一个网格，顶点不共享，带位置和法线
+func writeGLB(w io.Writer, mesh *triangleMesh, name string) error {
+	count := len(mesh.positions) / 3
+	minPos := []float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	maxPos := []float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for i, v := range mesh.positions {
+		minPos[i%3] = min(minPos[i%3], v)
+		maxPos[i%3] = max(maxPos[i%3], v)
+	}
+	viewLength := count * 12
+	doc := map[string]interface{}{
+		"asset":  map[string]string{"version": "2.0", "generator": "Everything Web Server"},
+		"scene":  0,
+		"scenes": []interface{}{map[string]interface{}{"nodes": []int{0}}},
+		"nodes":  []interface{}{map[string]interface{}{"mesh": 0, "name": name}},
+		"meshes": []interface{}{map[string]interface{}{
+			"primitives": []interface{}{map[string]interface{}{
+				"attributes": map[string]int{"POSITION": 0, "NORMAL": 1},
+				"material":   0,
+			}},
+		}},
+		"materials": []interface{}{map[string]interface{}{
+			"pbrMetallicRoughness": map[string]interface{}{"baseColorFactor": []float64{0.8, 0.8, 0.8, 1}, "metallicFactor": 0, "roughnessFactor": 0.6},
+			"doubleSided":          true,
+		}},
+		"buffers": []interface{}{map[string]int{"byteLength": viewLength * 2}},
+		"bufferViews": []interface{}{
+			map[string]int{"buffer": 0, "byteOffset": 0, "byteLength": viewLength, "target": 34962},
+			map[string]int{"buffer": 0, "byteOffset": viewLength, "byteLength": viewLength, "target": 34962},
+		},
+		"accessors": []interface{}{
+			map[string]interface{}{"bufferView": 0, "componentType": 5126, "count": count, "type": "VEC3", "min": minPos, "max": maxPos},
+			map[string]interface{}{"bufferView": 1, "componentType": 5126, "count": count, "type": "VEC3"},
+		},
+	}
+	jsonChunk, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	// 块长度需要4字节对齐，JSON用空格补齐
+	for len(jsonChunk)%4 != 0 {
+		jsonChunk = append(jsonChunk, ' ')
+	}
+
+	bw := bufio.NewWriterSize(w, 256*1024)
+	le := binary.LittleEndian
+	header := make([]byte, 0, 20)
+	header = le.AppendUint32(header, 0x46546C67) // "glTF"
+	header = le.AppendUint32(header, 2)
+	header = le.AppendUint32(header, uint32(12+8+len(jsonChunk)+8+viewLength*2))
+	header = le.AppendUint32(header, uint32(len(jsonChunk)))
+	header = le.AppendUint32(header, 0x4E4F534A) // "JSON"
+	bw.Write(header)
+	bw.Write(jsonChunk)
+
+	chunk := le.AppendUint32(nil, uint32(viewLength*2))
+	chunk = le.AppendUint32(chunk, 0x004E4942) // "BIN"
+	bw.Write(chunk)
+	buf := make([]byte, 4)
+	for _, v := range mesh.positions {
+		le.PutUint32(buf, math.Float32bits(v))
+		bw.Write(buf)
+	}
+	for i := 0; i < len(mesh.positions); i += 9 {
+		n := faceNormal(mesh.positions[i : i+9])
+		for range 3 {
+			for _, c := range n {
+				le.PutUint32(buf, math.Float32bits(c))
+				bw.Write(buf)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// 读取STL或OBJ文件并转换为网格
+func loadModelMesh(path string) (*triangleMesh, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".obj") {
+		return parseOBJ(data)
+	}
+	return parseSTL(data)
+}
+
+// 模型转换处理器 /model/文件路径
+func modelHandler(w http.ResponseWriter, r *http.Request) {
+	filePath := decodeRequestPath(strings.TrimPrefix(r.URL.Path, "/model/"))
+	if remoteEverythingEnabled() {
+		http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, err := statPath(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "访问文件失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if info.IsDir() || (ext != ".stl" && ext != ".obj") {
+		http.Error(w, "只能转换STL和OBJ文件", http.StatusUnsupportedMediaType)
+		return
+	}
+	if info.Size() > maxModelFileSize {
+		http.Error(w, fmt.Sprintf("模型文件超过%dMB", maxModelFileSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	start := time.Now()
+	mesh, err := loadModelMesh(filePath)
+	if err != nil {
+		log.Printf("转换模型失败: %s, 错误: %v", filePath, err)
+		http.Error(w, "转换模型失败: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	log.Printf("模型转换: %s, %d个三角形, 用时%v, 来源IP: %s", filePath, mesh.triangles(), time.Since(start).Round(time.Millisecond), r.RemoteAddr)
+
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	w.Header().Set("Content-Type", "model/gltf-binary")
+	w.Header().Set("Content-Disposition", "inline; filename*=UTF-8''"+url.PathEscape(name+".glb"))
+	w.Header().Set("Cache-Control", "no-store")
+	writeGLB(w, mesh, name)
+}
+
+func threeJSURL() string {
+	if serverConfig.ThreeJSURL != "" {
+		return strings.TrimSuffix(serverConfig.ThreeJSURL, "/") + "/"
+	}
+	return defaultThreeJSURL
+}
+
+// 模型查看器页面 /modelview/文件路径
+func modelViewerHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
+	filePath := decodeRequestPath(strings.TrimPrefix(r.URL.Path, "/modelview/"))
+
+	info, err := statPath(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "访问文件失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if info.IsDir() || !isModelFile(ext) {
+		http.Error(w, "不是3D模型文件", http.StatusBadRequest)
+		return
+	}
+
+	// STL和OBJ先转换为glTF，glTF直接加载原文件
+	encoded := url.PathEscape(clientPath(filePath))
+	modelURL := "/model/" + encoded
+	if ext == ".gltf" || ext == ".glb" {
+		modelURL = "/file/" + encoded
+	}
+	// STL通常以Z轴向上（3D打印和CAD），OBJ和glTF以Y轴向上
+	zUpChecked := ""
+	if ext == ".stl" {
+		zUpChecked = " checked"
+	}
+	fileName := filepath.Base(filePath)
+	log.Printf("模型查看器请求: %s，来源IP: %s", filePath, r.RemoteAddr)
+
+	if !serverConfig.DisableCSP {
+		policy := addCSPSources(contentSecurityPolicy(nonce), "script-src", urlOrigin(threeJSURL()))
+		policy = addCSPSources(policy, "connect-src", "data: blob:") // glTF中内嵌的缓冲区和贴图
+		w.Header().Set("Content-Security-Policy", policy)
+	}
+
+	imports, _ := json.Marshal(map[string]map[string]string{"imports": {
+		"three":         threeJSURL() + "build/three.module.js",
+		"three/addons/": threeJSURL() + "examples/jsm/",
+	}})
+
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + html.EscapeString(fileName) + ` - 3D模型 - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 10px; font-size: 14px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; }
+        #viewer { width: 100%; height: calc(100vh - 90px); border-radius: 8px; background: #2b2b2b; overflow: hidden; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>🧊 ` + html.EscapeString(fileName) + `</strong>
+        <label><input type="checkbox" id="wireframe"> 线框</label>
+        <label><input type="checkbox" id="zUp"` + zUpChecked + `> Z轴向上</label>
+        <button id="resetView">重置视角</button>
+        <a href="/file/` + encoded + `?download=1">下载原文件</a>
+        <span class="meta" id="info">加载中...</span>
+    </div>
+    <div id="viewer"></div>
+    <script type="importmap" nonce="` + nonce + `">` + string(imports) + `</script>
+    <script nonce="` + nonce + `">
+        window.addEventListener('load', () => {
+            if (!window.modelViewerStarted) {
+                document.getElementById('info').textContent = 'three.js加载失败，请检查网络或配置中的threeJSURL';
+            }
+        });
+    </script>
+    <script type="module" nonce="` + nonce + `">
+        import * as THREE from 'three';
+        import { OrbitControls } from 'three/addons/controls/OrbitControls.js';
+        import { GLTFLoader } from 'three/addons/loaders/GLTFLoader.js';
+        window.modelViewerStarted = true;
+
+        const container = document.getElementById('viewer');
+        const info = document.getElementById('info');
+        const renderer = new THREE.WebGLRenderer({ antialias: true });
+        renderer.setPixelRatio(window.devicePixelRatio);
+        container.appendChild(renderer.domElement);
+
+        const scene = new THREE.Scene();
+        scene.background = new THREE.Color(0x2b2b2b);
+        scene.add(new THREE.HemisphereLight(0xffffff, 0x444444, 2));
+        const light = new THREE.DirectionalLight(0xffffff, 2);
+        light.position.set(1, 2, 3);
+        scene.add(light);
+
+        const camera = new THREE.PerspectiveCamera(45, 1, 0.01, 1000);
+        const controls = new OrbitControls(camera, renderer.domElement);
+        const pivot = new THREE.Group();
+        scene.add(pivot);
+        let model = null;
+        let grid = null;
+
+        function resize() {
+            renderer.setSize(container.clientWidth, container.clientHeight);
+            camera.aspect = container.clientWidth / container.clientHeight;
+            camera.updateProjectionMatrix();
+        }
+
+        // 模型居中放在网格上，相机按包围盒大小调整距离
+        function fitView() {
+            if (!model) return;
+            pivot.rotation.x = document.getElementById('zUp').checked ? -Math.PI / 2 : 0;
+            pivot.position.set(0, 0, 0);
+            pivot.updateMatrixWorld(true);
+            const box = new THREE.Box3().setFromObject(model);
+            const center = box.getCenter(new THREE.Vector3());
+            pivot.position.set(-center.x, -box.min.y, -center.z);
+            const size = box.getSize(new THREE.Vector3());
+            const radius = Math.max(size.x, size.y, size.z) || 1;
+
+            if (grid) scene.remove(grid);
+            grid = new THREE.GridHelper(radius * 2, 20, 0x888888, 0x555555);
+            scene.add(grid);
+
+            camera.near = radius / 1000;
+            camera.far = radius * 100;
+            camera.position.set(radius * 1.2, radius * 0.9, radius * 1.6);
+            camera.updateProjectionMatrix();
+            controls.target.set(0, size.y / 2, 0);
+            controls.update();
+        }
+
+        function setWireframe(on) {
+            model && model.traverse(obj => {
+                if (obj.isMesh) [].concat(obj.material).forEach(m => { m.wireframe = on; });
+            });
+        }
+
+        window.addEventListener('resize', resize);
+        document.getElementById('wireframe').addEventListener('change', e => setWireframe(e.target.checked));
+        document.getElementById('zUp').addEventListener('change', fitView);
+        document.getElementById('resetView').addEventListener('click', fitView);
+        resize();
+        renderer.setAnimationLoop(() => renderer.render(scene, camera));
+
+        const start = performance.now();
+        new GLTFLoader().load(` + jsString(modelURL) + `, gltf => {
+            model = gltf.scene;
+            pivot.add(model);
+            fitView();
+            let triangles = 0;
+            model.traverse(obj => {
+                if (obj.isMesh) {
+                    const g = obj.geometry;
+                    triangles += (g.index ? g.index.count : g.attributes.position.count) / 3;
+                }
+            });
+            const size = new THREE.Box3().setFromObject(model).getSize(new THREE.Vector3());
+            info.textContent = triangles.toLocaleString() + '个三角形，尺寸 ' +
+                [size.x, size.y, size.z].map(v => +v.toPrecision(4)).join(' × ') +
+                '，用时' + Math.round(performance.now() - start) + 'ms';
+        }, undefined, error => {
+            info.textContent = '加载失败: ' + (error.message || error);
+        });
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testASCIISTL = `solid cube
+  facet normal 0 0 1
+    outer loop
+      vertex 0 0 0
+      vertex 10 0 0
+      vertex 10 20 0
+    endloop
+  endfacet
+  facet normal 0 0 1
+    outer loop
+      vertex 0 0 0
+      vertex 10 20 0
+      vertex 0 20 5
+    endloop
+  endfacet
+endsolid cube
+`
+
+func binarySTL(triangles ...[9]float32) []byte {
+	buf := make([]byte, 80, 84+50*len(triangles))
+	copy(buf, "solid binary header") // 二进制文件的头部也可能以solid开头
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(triangles)))
+	for _, tri := range triangles {
+		buf = append(buf, make([]byte, 12)...) // 法线
+		for _, v := range tri {
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+		}
+		buf = append(buf, 0, 0)
+	}
+	return buf
+}
+
+func TestParseModels(t *testing.T) {
+	tests := []struct {
+		name      string
+		parse     func([]byte) (*triangleMesh, error)
+		data      string
+		triangles int
+	}{
+		{"ascii stl", parseSTL, testASCIISTL, 2},
+		{"binary stl", parseSTL, string(binarySTL([9]float32{0, 0, 0, 1, 0, 0, 0, 1, 0})), 1},
+		{"obj quad and triangle", parseOBJ, "# cube\nv 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nvn 0 0 1\nf 1//1 2//1 3//1 4//1\nf -4/1 -3/2 -1/3\n", 3},
+	}
+	for _, tt := range tests {
+		mesh, err := tt.parse([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if mesh.triangles() != tt.triangles {
+			t.Errorf("%s: %d triangles, want %d", tt.name, mesh.triangles(), tt.triangles)
+		}
+	}
+
+	for _, bad := range []string{"", "not a model", "v 0 0 0\nf 1 2 3\n"} {
+		if _, err := parseOBJ([]byte(bad)); err == nil {
+			t.Errorf("parseOBJ(%q): expected error", bad)
+		}
+	}
+	if _, err := parseSTL([]byte("hello")); err == nil {
+		t.Error("parseSTL(hello): expected error")
+	}
+}
+
+func TestWriteGLB(t *testing.T) {
+	mesh, err := parseSTL([]byte(testASCIISTL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeGLB(&buf, mesh, "cube"); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	le := binary.LittleEndian
+	if string(data[:4]) != "glTF" || le.Uint32(data[4:]) != 2 || int(le.Uint32(data[8:])) != len(data) {
+		t.Fatalf("bad GLB header: % x", data[:12])
+	}
+	jsonLen := int(le.Uint32(data[12:]))
+	if jsonLen%4 != 0 || string(data[16:20]) != "JSON" {
+		t.Fatalf("bad JSON chunk header")
+	}
+	var doc struct {
+		Accessors []struct {
+			Count int       `json:"count"`
+			Min   []float32 `json:"min"`
+			Max   []float32 `json:"max"`
+		} `json:"accessors"`
+	}
+	if err := json.Unmarshal(data[20:20+jsonLen], &doc); err != nil {
+		t.Fatalf("JSON chunk: %v", err)
+	}
+	if len(doc.Accessors) != 2 || doc.Accessors[0].Count != 6 {
+		t.Fatalf("accessors = %+v", doc.Accessors)
+	}
+	if got := doc.Accessors[0].Max; len(got) != 3 || got[0] != 10 || got[1] != 20 || got[2] != 5 {
+		t.Errorf("max = %v, want [10 20 5]", got)
+	}
+
+	bin := data[20+jsonLen:]
+	if binLen := int(le.Uint32(bin)); binLen != 6*12*2 || string(bin[4:7]) != "BIN" || len(bin) != 8+binLen {
+		t.Fatalf("bad BIN chunk: length %d", binLen)
+	}
+	// 第一个三角形位于z=0平面，法线为(0, 0, 1)
+	normal := bin[8+6*12:]
+	if nz := math.Float32frombits(le.Uint32(normal[8:])); nz != 1 {
+		t.Errorf("first normal z = %v, want 1", nz)
+	}
+}
+
+func TestModelHandlers(t *testing.T) {
+	dir := t.TempDir()
+	stl := filepath.Join(dir, "part.stl")
+	os.WriteFile(stl, []byte(testASCIISTL), 0644)
+	paths := createTestFiles(t, dir, "scene.gltf", "broken.obj")
+
+	rec := serveTestRequest(modelHandler, httptest.NewRequest(http.MethodGet, "/model/"+url.PathEscape(stl), nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "model/gltf-binary" || !bytes.HasPrefix(rec.Body.Bytes(), []byte("glTF")) {
+		t.Errorf("stl: status %d, type %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec = serveTestRequest(modelHandler, httptest.NewRequest(http.MethodGet, "/model/"+url.PathEscape(paths[0]), nil)); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("gltf: status = %d, want 415", rec.Code)
+	}
+	if rec = serveTestRequest(modelHandler, httptest.NewRequest(http.MethodGet, "/model/"+url.PathEscape(paths[1]), nil)); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("broken obj: status = %d, want 422", rec.Code)
+	}
+
+	// 查看器页面：STL加载转换结果，glTF直接加载原文件
+	tests := []struct{ path, want string }{
+		{stl, `"/model/` + url.PathEscape(stl) + `"`},
+		{paths[0], `"/file/` + url.PathEscape(paths[0]) + `"`},
+	}
+	for _, tt := range tests {
+		rec := serveTestRequest(modelViewerHandler, httptest.NewRequest(http.MethodGet, "/modelview/"+url.PathEscape(tt.path), nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s: status %d, page does not load %s", filepath.Base(tt.path), rec.Code, tt.want)
+		}
+	}
+	if rec = serveTestRequest(modelViewerHandler, httptest.NewRequest(http.MethodGet, "/modelview/"+url.PathEscape(dir), nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("folder: status = %d, want 400", rec.Code)
+	}
+}
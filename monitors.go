@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 查询监视器
+//
+// 监视器定时重新执行一个Everything查询，出现新的匹配结果时通过配置的渠道发出通知，
+// 例如"D:\incoming\ *.iso"出现新的ISO文件时通知。第一次执行只记录当前结果，不发通知。
+//
+// GET    /api/monitors                列出监视器
+// POST   /api/monitors                {"name","query","intervalMinutes","channels":[{"type":"webhook","url":"..."}]}
+// PUT    /api/monitors                {"id",...} 修改
+// DELETE /api/monitors?id=...
+// POST   /api/monitors/check {"id"}   立即检查一次
+//
+// 由定时任务check-monitors每分钟检查到期的监视器。
+
+const (
+	monitorsFileName       = "monitors.json"
+	monitorsDirName        = "monitors" // 每个监视器上次的结果
+	defaultMonitorInterval = 10         // 分钟
+	maxNotifyPaths         = 50         // 通知中列出的新文件数
+)
+
+type Monitor struct {
+	ID              string          `json:"id"`
+	Name            string          `json:"name"`
+	Query           string          `json:"query"`
+	IntervalMinutes int             `json:"intervalMinutes"`
+	Channels        []NotifyChannel `json:"channels"`
+	Enabled         bool            `json:"enabled"`
+	Created         string          `json:"created"`
+	LastCheck       string          `json:"lastCheck,omitempty"`
+	LastCount       int             `json:"lastCount"`
+	LastNew         int             `json:"lastNew"`
+	LastError       string          `json:"lastError,omitempty"`
+
+	lastRun time.Time
+}
+
+var (
+	monitors       []*Monitor
+	monitorsMutex  sync.Mutex
+	monitorsLoaded sync.Once
+	monitorRunning = make(map[string]bool) // 正在检查的监视器，避免同一监视器同时检查
+)
+
+func ensureMonitorsLoaded() {
+	monitorsLoaded.Do(func() {
+		monitorsMutex.Lock()
+		defer monitorsMutex.Unlock()
+		if err := loadJSONFile(monitorsFileName, &monitors); err != nil {
+			log.Printf("加载监视器失败: %v", err)
+		}
+	})
+}
+
+// 保存监视器列表；调用方需持有monitorsMutex
+func saveMonitors() error {
+	return saveJSONFile(monitorsFileName, monitors)
+}
+
+func findMonitor(id string) *Monitor {
+	for _, m := range monitors {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// 检查并补全监视器的设置
+func normalizeMonitor(m *Monitor) error {
+	if m.Query == "" {
+		return fmt.Errorf("需要query参数")
+	}
+	if m.Name == "" {
+		m.Name = m.Query
+	}
+	if m.IntervalMinutes <= 0 {
+		m.IntervalMinutes = defaultMonitorInterval
+	}
+	if len(m.Channels) == 0 {
+		m.Channels = []NotifyChannel{{Type: "sse"}}
+	}
+	for _, ch := range m.Channels {
+		if err := validateNotifyChannel(ch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func monitorBaselinePath(id string) string {
+	return filepath.Join(monitorsDirName, id+".json")
+}
+
+// 执行一次监视器的查询，返回新出现的文件
+func checkMonitor(ctx context.Context, id string) ([]string, error) {
+	monitorsMutex.Lock()
+	m := findMonitor(id)
+	if m == nil || monitorRunning[id] {
+		monitorsMutex.Unlock()
+		return nil, fmt.Errorf("监视器不存在或正在检查")
+	}
+	monitorRunning[id] = true
+	name, query, channels := m.Name, m.Query, m.Channels
+	monitorsMutex.Unlock()
+	defer func() {
+		monitorsMutex.Lock()
+		delete(monitorRunning, id)
+		monitorsMutex.Unlock()
+	}()
+
+	paths, err := runEverythingQuery(ctx, query)
+	var added []string
+	if err == nil {
+		added, err = updateMonitorBaseline(id, paths)
+	}
+
+	monitorsMutex.Lock()
+	if m = findMonitor(id); m != nil {
+		m.lastRun = time.Now()
+		m.LastCheck = m.lastRun.Format("2006-01-02 15:04:05")
+		m.LastError = ""
+		if err != nil {
+			m.LastError = err.Error()
+		} else {
+			m.LastCount = len(paths)
+			m.LastNew = len(added)
+		}
+		if saveErr := saveMonitors(); saveErr != nil {
+			log.Printf("保存监视器失败: %v", saveErr)
+		}
+	}
+	monitorsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(added) > 0 {
+		log.Printf("监视器%s发现%d个新文件", name, len(added))
+		list := added
+		if len(list) > maxNotifyPaths {
+			list = list[:maxNotifyPaths]
+		}
+		clientPaths := make([]string, len(list))
+		for i, path := range list {
+			clientPaths[i] = clientPath(path)
+		}
+		message := strings.Join(clientPaths[:min(len(clientPaths), 10)], "\n")
+		if len(added) > 10 {
+			message += fmt.Sprintf("\n……等%d个文件", len(added))
+		}
+		sendNotification(channels, Notification{
+			Title:   fmt.Sprintf("监视器「%s」发现%d个新文件", name, len(added)),
+			Message: message,
+			Source:  "monitor",
+			Paths:   clientPaths,
+		})
+	}
+	return added, nil
+}
+
+// 用本次结果替换上次的结果，返回新增的路径；第一次执行时没有上次的结果，不算新增
+func updateMonitorBaseline(id string, paths []string) ([]string, error) {
+	var baseline []string
+	if err := loadJSONFile(monitorBaselinePath(id), &baseline); err != nil {
+		return nil, err
+	}
+	var added []string
+	if baseline != nil {
+		added, _ = diffPaths(baseline, paths)
+	}
+
+	if paths == nil {
+		paths = []string{} // 保存为[]而不是null，下次能区分"没有结果"和"第一次执行"
+	}
+	if err := os.MkdirAll(filepath.Join(getDataDir(), monitorsDirName), 0755); err != nil {
+		return nil, err
+	}
+	return added, saveJSONFile(monitorBaselinePath(id), paths)
+}
+
+// 定时任务：检查所有到期的监视器
+func checkDueMonitors(ctx context.Context) error {
+	ensureMonitorsLoaded()
+
+	monitorsMutex.Lock()
+	var due []string
+	for _, m := range monitors {
+		if m.Enabled && time.Since(m.lastRun) >= time.Duration(m.IntervalMinutes)*time.Minute {
+			due = append(due, m.ID)
+		}
+	}
+	monitorsMutex.Unlock()
+
+	var failed int
+	for _, id := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := checkMonitor(ctx, id); err != nil {
+			log.Printf("监视器检查失败: %s, 错误: %v", id, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d个监视器检查失败", failed)
+	}
+	return nil
+}
+
+func writeMonitorsResponse(w http.ResponseWriter) {
+	monitorsMutex.Lock()
+	list := make([]Monitor, len(monitors))
+	for i, m := range monitors {
+		list[i] = *m
+	}
+	monitorsMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"monitors": list,
+		"count":    len(list),
+	})
+}
+
+// 监视器API处理器
+func apiMonitorsHandler(w http.ResponseWriter, r *http.Request) {
+	ensureMonitorsLoaded()
+
+	switch r.Method {
+	case http.MethodGet:
+		writeMonitorsResponse(w)
+
+	case http.MethodPost:
+		m := &Monitor{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(m); err != nil {
+			http.Error(w, "请求格式错误", http.StatusBadRequest)
+			return
+		}
+		if err := normalizeMonitor(m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.ID = newRandomID(8)
+		m.Created = time.Now().Format("2006-01-02 15:04:05")
+		m.LastCheck, m.LastCount, m.LastNew, m.LastError = "", 0, 0, ""
+
+		monitorsMutex.Lock()
+		monitors = append(monitors, m)
+		err := saveMonitors()
+		monitorsMutex.Unlock()
+		if err != nil {
+			log.Printf("保存监视器失败: %v", err)
+			http.Error(w, "保存监视器失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("添加监视器: %s（%s，每%d分钟），来源IP: %s", m.Name, m.Query, m.IntervalMinutes, r.RemoteAddr)
+		// 立即执行一次，记录当前结果作为基准
+		go checkMonitor(context.Background(), m.ID)
+		writeMonitorsResponse(w)
+
+	case http.MethodPut:
+		var req Monitor
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+			http.Error(w, "请求格式错误，需要id参数", http.StatusBadRequest)
+			return
+		}
+		if err := normalizeMonitor(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		monitorsMutex.Lock()
+		m := findMonitor(req.ID)
+		if m != nil {
+			if m.Query != req.Query {
+				// 查询变化后上次的结果不再可比
+				os.Remove(filepath.Join(getDataDir(), monitorBaselinePath(m.ID)))
+				m.lastRun = time.Time{}
+			}
+			m.Name, m.Query, m.IntervalMinutes, m.Channels, m.Enabled = req.Name, req.Query, req.IntervalMinutes, req.Channels, req.Enabled
+		}
+		err := saveMonitors()
+		monitorsMutex.Unlock()
+		if m == nil {
+			http.Error(w, "监视器不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("保存监视器失败: %v", err)
+			http.Error(w, "保存监视器失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("修改监视器: %s，来源IP: %s", req.Name, r.RemoteAddr)
+		writeMonitorsResponse(w)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		monitorsMutex.Lock()
+		found := false
+		for i, m := range monitors {
+			if m.ID == id {
+				monitors = append(monitors[:i], monitors[i+1:]...)
+				found = true
+				break
+			}
+		}
+		var err error
+		if found {
+			err = saveMonitors()
+			os.Remove(filepath.Join(getDataDir(), monitorBaselinePath(id)))
+		}
+		monitorsMutex.Unlock()
+		if !found {
+			http.Error(w, "监视器不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("保存监视器失败: %v", err)
+		}
+
+		log.Printf("删除监视器: %s，来源IP: %s", id, r.RemoteAddr)
+		writeMonitorsResponse(w)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 立即检查API处理器
+func apiMonitorCheckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	ensureMonitorsLoaded()
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "请求格式错误，需要id参数", http.StatusBadRequest)
+		return
+	}
+
+	added, err := checkMonitor(r.Context(), req.ID)
+	if err != nil {
+		http.Error(w, "检查失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	list := make([]string, len(added))
+	for i, path := range added {
+		list[i] = clientPath(path)
+	}
+
+	log.Printf("手动检查监视器: %s，新文件%d个，来源IP: %s", req.ID, len(list), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"added": list,
+		"count": len(list),
+	})
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Windows Installer安装包（.msi、.msp、.msm）
+//
+// MSI是复合文档（OLE Compound File），产品信息在两个地方：
+//   \x05SummaryInformation流：标题、作者、平台和语言（Template）、包代码（Revision Number）
+//   Property表：ProductName、ProductVersion、Manufacturer、ProductCode、UpgradeCode
+// 表和字符串池的流名经过压缩编码，字符串池的格式参考Wine的实现。
+
+const (
+	cfbSignature     = "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"
+	cfbMaxRegSect    = 0xFFFFFFFA
+	maxCFBStreamSize = 64 << 20
+)
+
+var errCFBCorrupt = errors.New("复合文档已损坏")
+
+type cfbEntry struct {
+	name               string
+	typ                byte // 1 存储，2 流，5 根
+	left, right, child uint32
+	start              uint32
+	size               uint64
+}
+
+type cfbFile struct {
+	r          io.ReaderAt
+	sectorSize int
+	cutoff     uint64
+	fat        []uint32
+	miniFat    []uint32
+	entries    []cfbEntry
+	miniStream []byte
+}
+
+func openCFB(r io.ReaderAt, size int64) (*cfbFile, error) {
+	header := make([]byte, 512)
+	if _, err := r.ReadAt(header, 0); err != nil || string(header[:8]) != cfbSignature {
+		return nil, errNotExecutable
+	}
+	le := binary.LittleEndian
+	shift, miniShift := le.Uint16(header[0x1E:]), le.Uint16(header[0x20:])
+	if shift != 9 && shift != 12 || miniShift != 6 {
+		return nil, errCFBCorrupt
+	}
+	c := &cfbFile{r: r, sectorSize: 1 << shift, cutoff: uint64(le.Uint32(header[0x38:]))}
+	maxSectors := int(size/int64(c.sectorSize)) + 1
+
+	// FAT扇区：前109个在文件头中，其余在DIFAT扇区链中
+	numFAT := int(le.Uint32(header[0x2C:]))
+	if numFAT > maxSectors {
+		return nil, errCFBCorrupt
+	}
+	var fatSectors []uint32
+	for i := 0; i < 109 && len(fatSectors) < numFAT; i++ {
+		fatSectors = append(fatSectors, le.Uint32(header[0x4C+i*4:]))
+	}
+	difat := le.Uint32(header[0x44:])
+	for n := 0; len(fatSectors) < numFAT && difat < cfbMaxRegSect; n++ {
+		sec, err := c.readSector(difat)
+		if err != nil || n > maxSectors {
+			return nil, errCFBCorrupt
+		}
+		per := c.sectorSize/4 - 1
+		for i := 0; i < per && len(fatSectors) < numFAT; i++ {
+			fatSectors = append(fatSectors, le.Uint32(sec[i*4:]))
+		}
+		difat = le.Uint32(sec[per*4:])
+	}
+	if len(fatSectors) < numFAT {
+		return nil, errCFBCorrupt
+	}
+	for _, s := range fatSectors {
+		sec, err := c.readSector(s)
+		if err != nil {
+			return nil, err
+		}
+		c.fat = appendUint32s(c.fat, sec)
+	}
+
+	dir, err := c.readChain(le.Uint32(header[0x30:]), -1)
+	if err != nil {
+		return nil, err
+	}
+	for off := 0; off+128 <= len(dir); off += 128 {
+		e := dir[off : off+128]
+		nameLen := min(int(le.Uint16(e[0x40:])), 64)
+		entry := cfbEntry{
+			name:  decodeUTF16Z(e[:nameLen]),
+			typ:   e[0x42],
+			left:  le.Uint32(e[0x44:]),
+			right: le.Uint32(e[0x48:]),
+			child: le.Uint32(e[0x4C:]),
+			start: le.Uint32(e[0x74:]),
+			size:  le.Uint64(e[0x78:]),
+		}
+		// 版本3的大小只有低32位有效
+		if c.sectorSize == 512 {
+			entry.size &= 0xFFFFFFFF
+		}
+		c.entries = append(c.entries, entry)
+	}
+	if len(c.entries) == 0 || c.entries[0].typ != 5 || c.entries[0].size > maxCFBStreamSize {
+		return nil, errCFBCorrupt
+	}
+
+	// 小于cutoff的流保存在迷你流中，迷你流本身是根目录项的数据
+	miniFat, err := c.readChain(le.Uint32(header[0x3C:]), -1)
+	if err != nil {
+		return nil, err
+	}
+	c.miniFat = appendUint32s(nil, miniFat)
+	if c.miniStream, err = c.readChain(c.entries[0].start, int64(c.entries[0].size)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func appendUint32s(dst []uint32, b []byte) []uint32 {
+	for i := 0; i+4 <= len(b); i += 4 {
+		dst = append(dst, binary.LittleEndian.Uint32(b[i:]))
+	}
+	return dst
+}
+
+func (c *cfbFile) readSector(n uint32) ([]byte, error) {
+	if n >= cfbMaxRegSect {
+		return nil, errCFBCorrupt
+	}
+	buf := make([]byte, c.sectorSize)
+	// 最后一个扇区可能没有补齐
+	if read, err := c.r.ReadAt(buf, int64(n+1)*int64(c.sectorSize)); err != nil && (err != io.EOF || read == 0) {
+		return nil, errCFBCorrupt
+	}
+	return buf, nil
+}
+
+// 按FAT读取扇区链，size为-1时读到链结束
+func (c *cfbFile) readChain(start uint32, size int64) ([]byte, error) {
+	var data []byte
+	for s, n := start, 0; s < cfbMaxRegSect && (size < 0 || int64(len(data)) < size); n++ {
+		if n > len(c.fat) || len(data) > maxCFBStreamSize || int(s) >= len(c.fat) {
+			return nil, errCFBCorrupt
+		}
+		sec, err := c.readSector(s)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, sec...)
+		s = c.fat[s]
+	}
+	if size >= 0 {
+		if int64(len(data)) < size {
+			return nil, errCFBCorrupt
+		}
+		data = data[:size]
+	}
+	return data, nil
+}
+
+func (c *cfbFile) readStream(e cfbEntry) ([]byte, error) {
+	if e.size > maxCFBStreamSize {
+		return nil, fmt.Errorf("流%s超过%dMB", e.name, maxCFBStreamSize>>20)
+	}
+	if e.size >= c.cutoff {
+		return c.readChain(e.start, int64(e.size))
+	}
+	var data []byte
+	for s, n := e.start, 0; s < cfbMaxRegSect && uint64(len(data)) < e.size; n++ {
+		off := int(s) * 64
+		if n > len(c.miniFat) || int(s) >= len(c.miniFat) || off+64 > len(c.miniStream) {
+			return nil, errCFBCorrupt
+		}
+		data = append(data, c.miniStream[off:off+64]...)
+		s = c.miniFat[s]
+	}
+	if uint64(len(data)) < e.size {
+		return nil, errCFBCorrupt
+	}
+	return data[:e.size], nil
+}
+
+// 根存储下的流（目录项组成红黑树，按左右子树遍历）
+func (c *cfbFile) rootStreams() map[string]cfbEntry {
+	streams := make(map[string]cfbEntry)
+	visited := make(map[uint32]bool)
+	stack := []uint32{c.entries[0].child}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if int(id) >= len(c.entries) || visited[id] {
+			continue
+		}
+		visited[id] = true
+		e := c.entries[id]
+		if e.typ == 2 {
+			streams[e.name] = e
+		}
+		stack = append(stack, e.left, e.right)
+	}
+	return streams
+}
+
+// MSI流名的解码：每个0x3800~0x47FF的字符编码两个字符，0x4800~0x483F编码一个，0x4840是表名前缀
+func msiDecodeName(name string) string {
+	const charset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz._"
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 0x3800 && r < 0x4800:
+			r -= 0x3800
+			b.WriteByte(charset[r&0x3F])
+			b.WriteByte(charset[r>>6&0x3F])
+		case r >= 0x4800 && r < 0x4840:
+			b.WriteByte(charset[r-0x4800])
+		case r == 0x4840:
+			b.WriteByte('!')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// 字符串按代码页解码，UTF-8安装包和纯ASCII直接使用
+func decodeMSIString(b []byte, codepage uint32) string {
+	if codepage == 65001 || utf8.Valid(b) {
+		return string(b)
+	}
+	return decodeANSI(b)
+}
+
+func readMSIInfo(r io.ReaderAt, size int64) (*ExecutableInfo, error) {
+	c, err := openCFB(r, size)
+	if err != nil {
+		return nil, err
+	}
+	streams := make(map[string]cfbEntry)
+	for name, e := range c.rootStreams() {
+		streams[msiDecodeName(name)] = e
+	}
+	info := &ExecutableInfo{Type: "MSI", Architecture: "x86"}
+
+	if e, ok := streams["\x05SummaryInformation"]; ok {
+		data, err := c.readStream(e)
+		if err != nil {
+			return nil, err
+		}
+		summary := parseSummaryInformation(data)
+		// Template为“平台;语言”，例如 x64;1033,2052，平台为空表示x86
+		platform, languages, _ := strings.Cut(summary[7], ";")
+		switch strings.ToLower(strings.TrimSpace(platform)) {
+		case "x64", "amd64":
+			info.Architecture = "x64"
+		case "arm64":
+			info.Architecture = "ARM64"
+		case "arm":
+			info.Architecture = "ARM"
+		case "intel64":
+			info.Architecture = "IA64"
+		}
+		info.Languages = languages
+		info.ProductName = summary[3]
+		info.CompanyName = summary[4]
+		info.FileDescription = summary[6]
+		info.PackageCode = summary[9]
+	}
+
+	props, err := msiProperties(c, streams)
+	if err != nil {
+		return nil, err
+	}
+	for key, field := range map[string]*string{
+		"ProductName":    &info.ProductName,
+		"Manufacturer":   &info.CompanyName,
+		"ProductVersion": &info.ProductVersion,
+		"ProductCode":    &info.ProductCode,
+		"UpgradeCode":    &info.UpgradeCode,
+	} {
+		if v := props[key]; v != "" {
+			*field = v
+		}
+	}
+
+	// MSI的文件摘要覆盖所有流的内容，这里只验证签名者，由系统校验内容
+	info.Signature.Status = signatureUnsigned
+	if e, ok := streams["\x05DigitalSignature"]; ok {
+		info.Signature.Status = signatureInvalid
+		data, err := c.readStream(e)
+		if err == nil {
+			_, err = parseAuthenticode(data, &info.Signature)
+		}
+		if err != nil {
+			info.Signature.Detail = err.Error()
+		} else {
+			info.Signature.Status = signatureSigned
+		}
+	}
+	return info, nil
+}
+
+// 摘要信息属性集中的字符串属性，按属性ID返回
+func parseSummaryInformation(data []byte) map[uint32]string {
+	le := binary.LittleEndian
+	result := make(map[uint32]string)
+	if len(data) < 48 {
+		return result
+	}
+	section := int(le.Uint32(data[44:]))
+	if section+8 > len(data) {
+		return result
+	}
+	count := int(le.Uint32(data[section+4:]))
+	type property struct{ id, offset uint32 }
+	var props []property
+	var codepage uint32
+	for i := 0; i < count && section+8+i*8+8 <= len(data); i++ {
+		p := property{le.Uint32(data[section+8+i*8:]), le.Uint32(data[section+12+i*8:])}
+		off := section + int(p.offset)
+		if off+8 > len(data) {
+			continue
+		}
+		// 代码页（ID 1，VT_I2）
+		if p.id == 1 && le.Uint32(data[off:])&0xFFFF == 2 {
+			codepage = uint32(le.Uint16(data[off+4:]))
+		}
+		props = append(props, p)
+	}
+	for _, p := range props {
+		off := section + int(p.offset)
+		if off+8 > len(data) || le.Uint32(data[off:])&0xFFFF != 30 { // VT_LPSTR
+			continue
+		}
+		n := int(le.Uint32(data[off+4:]))
+		if n > len(data)-off-8 {
+			continue
+		}
+		value := data[off+8 : off+8+n]
+		if i := strings.IndexByte(string(value), 0); i >= 0 {
+			value = value[:i]
+		}
+		result[p.id] = strings.TrimSpace(decodeMSIString(value, codepage))
+	}
+	return result
+}
+
+// 读取字符串池：_StringPool中每项是长度和引用计数，字符串依次保存在_StringData中
+func msiStringPool(pool, data []byte) (strs []string, longRefs bool) {
+	if len(pool) < 4 {
+		return nil, false
+	}
+	le := binary.LittleEndian
+	codepage := le.Uint32(pool)
+	longRefs = codepage&0x80000000 != 0
+	codepage &^= 0x80000000
+	strs = []string{""} // 字符串ID从1开始
+	offset := 0
+	for i := 4; i+4 <= len(pool); {
+		length, refs := int(le.Uint16(pool[i:])), le.Uint16(pool[i+2:])
+		switch {
+		case length == 0 && refs == 0:
+			strs = append(strs, "")
+			i += 4
+			continue
+		case length == 0:
+			// 超过64KB的字符串占两项，长度在第二项中
+			if i+8 > len(pool) {
+				return strs, longRefs
+			}
+			length = int(le.Uint16(pool[i+6:]))<<16 | int(le.Uint16(pool[i+4:]))
+			i += 8
+		default:
+			i += 4
+		}
+		if offset+length > len(data) {
+			break
+		}
+		strs = append(strs, decodeMSIString(data[offset:offset+length], codepage))
+		offset += length
+	}
+	return strs, longRefs
+}
+
+// Property表：两列字符串（Property、Value），按列存储
+func msiProperties(c *cfbFile, streams map[string]cfbEntry) (map[string]string, error) {
+	props := make(map[string]string)
+	var raw [3][]byte
+	for i, name := range []string{"!_StringPool", "!_StringData", "!Property"} {
+		e, ok := streams[name]
+		if !ok {
+			return props, nil
+		}
+		data, err := c.readStream(e)
+		if err != nil {
+			return nil, err
+		}
+		raw[i] = data
+	}
+	strs, longRefs := msiStringPool(raw[0], raw[1])
+	table := raw[2]
+	refSize := 2
+	if longRefs {
+		refSize = 3
+	}
+	rows := len(table) / (2 * refSize)
+	str := func(i int) string {
+		off := i * refSize
+		id := int(table[off]) | int(table[off+1])<<8
+		if refSize == 3 {
+			id |= int(table[off+2]) << 16
+		}
+		if id < len(strs) {
+			return strs[id]
+		}
+		return ""
+	}
+	for i := 0; i < rows; i++ {
+		props[str(i)] = str(rows + i)
+	}
+	return props, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 自然排序：名称中的数字按数值比较，"第2集"排在"第10集"之前
+// 非数字部分不区分大小写；数值相同时前导零少的在前（"1" < "01"），最后按原字符串比较保证结果稳定
+func naturalLess(a, b string) bool {
+	if c := naturalCompare(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+func naturalCompare(a, b string) int {
+	zerosA, zerosB := 0, 0 // 第一处前导零个数不同的数字段
+	for a != "" && b != "" {
+		ra, _ := utf8.DecodeRuneInString(a)
+		rb, _ := utf8.DecodeRuneInString(b)
+
+		if isDigit(ra) && isDigit(rb) {
+			numA, restA := splitDigits(a)
+			numB, restB := splitDigits(b)
+			trimmedA := strings.TrimLeft(numA, "0")
+			trimmedB := strings.TrimLeft(numB, "0")
+			// 去掉前导零后位数多的数值大，位数相同时逐位比较
+			if len(trimmedA) != len(trimmedB) {
+				return compareInt(len(trimmedA), len(trimmedB))
+			}
+			if trimmedA != trimmedB {
+				return strings.Compare(trimmedA, trimmedB)
+			}
+			if zerosA == zerosB {
+				zerosA, zerosB = len(numA)-len(trimmedA), len(numB)-len(trimmedB)
+			}
+			a, b = restA, restB
+			continue
+		}
+
+		la, lb := unicode.ToLower(ra), unicode.ToLower(rb)
+		if la != lb {
+			return compareInt(int(la), int(lb))
+		}
+		a, b = a[utf8.RuneLen(ra):], b[utf8.RuneLen(rb):]
+	}
+
+	if a != b { // 一个是另一个的前缀
+		return compareInt(len(a), len(b))
+	}
+	return compareInt(zerosA, zerosB)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// 拆分开头的连续数字
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// 搜索结果按文件名自然排序，文件名相同时按完整路径
+// 排序结果保存在搜索缓存中，翻页时不重复排序
+func naturalSortedPaths(query string, paths *pathList) *pathList {
+	cacheMutex.RLock()
+	cache, ok := searchCache[query]
+	if ok && cache.Paths == paths && cache.Natural != nil {
+		sorted := cache.Natural
+		cacheMutex.RUnlock()
+		return sorted
+	}
+	cacheMutex.RUnlock()
+
+	start := time.Now()
+	list := paths.Slice(0, paths.Len())
+	sort.SliceStable(list, func(i, j int) bool {
+		nameA, nameB := filepath.Base(list[i]), filepath.Base(list[j])
+		if c := naturalCompare(nameA, nameB); c != 0 {
+			return c < 0
+		}
+		return naturalLess(list[i], list[j])
+	})
+	sorted := newPathList(list)
+	log.Printf("搜索结果自然排序: query=%s, %d个路径, 用时%v", query, sorted.Len(), time.Since(start).Round(time.Millisecond))
+
+	cacheMutex.Lock()
+	if cache, ok := searchCache[query]; ok && cache.Paths == paths {
+		cache.Natural = sorted
+	}
+	cacheMutex.Unlock()
+	return sorted
+}
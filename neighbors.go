@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 同一文件夹中的视频列表，用于播放页的上一集/下一集和连续播放
+//
+// GET /api/neighbors?path=视频路径
+// 按文件名自然排序（"第2集"在"第10集"之前），返回当前视频的位置和前后视频。
+
+type NeighborVideo struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type NeighborsResponse struct {
+	Folder   string          `json:"folder"`
+	Index    int             `json:"index"` // 当前视频在列表中的位置，不在列表中时为-1
+	Total    int             `json:"total"`
+	Previous *NeighborVideo  `json:"previous,omitempty"`
+	Next     *NeighborVideo  `json:"next,omitempty"`
+	Playlist []NeighborVideo `json:"playlist"`
+}
+
+// 列出视频所在文件夹中的所有视频（不含隐藏文件），按名称自然排序
+func getNeighbors(videoPath string) (*NeighborsResponse, error) {
+	folder := filepath.Dir(videoPath)
+	entries, err := readDirPath(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isVideoFile(strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			var result SearchResult
+			applyFileAttributes(&result, getFileAttributes(filepath.Join(folder, entry.Name()), info))
+			if result.Hidden || result.System {
+				continue
+			}
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Slice(names, func(i, j int) bool { return naturalLess(names[i], names[j]) })
+
+	resp := &NeighborsResponse{
+		Folder:   clientPath(folder),
+		Index:    -1,
+		Total:    len(names),
+		Playlist: make([]NeighborVideo, len(names)),
+	}
+	current := filepath.Base(videoPath)
+	for i, name := range names {
+		resp.Playlist[i] = NeighborVideo{Name: name, Path: clientPath(filepath.Join(folder, name))}
+		if strings.EqualFold(name, current) {
+			resp.Index = i
+		}
+	}
+	if resp.Index > 0 {
+		resp.Previous = &resp.Playlist[resp.Index-1]
+	}
+	if resp.Index >= 0 && resp.Index < len(names)-1 {
+		resp.Next = &resp.Playlist[resp.Index+1]
+	}
+	return resp, nil
+}
+
+// 同级视频API处理器
+func apiNeighborsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	videoPath := resolveClientPath(r.URL.Query().Get("path"))
+	if videoPath == "" {
+		http.Error(w, "缺少path参数", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := getNeighbors(videoPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件夹不存在", http.StatusNotFound)
+		} else {
+			log.Printf("获取同级视频失败: %s, 错误: %v", videoPath, err)
+			http.Error(w, "获取同级视频失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 播放页中的上一集/下一集和连续播放控件，没有其他视频时返回空字符串
+func playlistControls(filePath string, autoPlayNext bool, nonce string) string {
+	neighbors, err := getNeighbors(filePath)
+	if err != nil || neighbors.Index < 0 || neighbors.Total < 2 {
+		return ""
+	}
+
+	link := func(v *NeighborVideo, label string) string {
+		if v == nil {
+			return `<span class="btn btn-secondary" style="opacity: 0.4;">` + label + `</span>`
+		}
+		return `<a class="btn btn-secondary" href="/video/` + url.QueryEscape(v.Path) + `" title="` + html.EscapeString(v.Name) + `">` + label + `</a>`
+	}
+	nextURL := ""
+	nextName := ""
+	if neighbors.Next != nil {
+		nextURL = "/video/" + url.QueryEscape(neighbors.Next.Path)
+		nextName = neighbors.Next.Name
+	}
+	checked := ""
+	if autoPlayNext {
+		checked = " checked"
+	}
+	nextJSON, _ := json.Marshal(map[string]string{"url": nextURL, "name": nextName})
+
+	return `<div class="playlist-controls" style="margin-top: 10px; padding: 10px; background: rgba(255,255,255,0.1); border-radius: 8px; display: flex; gap: 8px; align-items: center; flex-wrap: wrap; font-size: 13px;">
+            ` + link(neighbors.Previous, "⏮ 上一个") + `
+            <span>` + html.EscapeString(neighbors.Folder) + ` · 第 ` + strconv.Itoa(neighbors.Index+1) + ` / ` + strconv.Itoa(neighbors.Total) + ` 个</span>
+            ` + link(neighbors.Next, "下一个 ⏭") + `
+            <label style="margin-left: auto;"><input type="checkbox" id="autoPlayNext"` + checked + `> 自动播放下一个</label>
+        </div>
+        <script nonce="` + nonce + `">
+        (function() {
+            const next = ` + string(nextJSON) + `;
+            const checkbox = document.getElementById('autoPlayNext');
+            checkbox.addEventListener('change', function() {
+                fetch('/api/prefs', { method: 'PATCH', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ autoPlayNext: checkbox.checked }) });
+            });
+            document.querySelector('.video-container video').addEventListener('ended', function() {
+                if (checkbox.checked && next.url) {
+                    logEvent('自动播放下一个: ' + next.name);
+                    window.location.href = next.url;
+                }
+            });
+        })();
+        </script>`
+}
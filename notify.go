@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 通知
+//
+// 监视器等功能通过sendNotification发出通知，每个通知渠道按type交给notifiers中对应的函数发送：
+//   webhook  POST JSON到url
+//   sse      推送给打开 /api/notifications/events 的页面（主页面收到后弹出浏览器通知）
+//   email    通过config.json中smtp配置的服务器发送邮件
+//   ntfy     发布到ntfy主题（config.json中的ntfy）
+//   gotify   发送到Gotify服务器（config.json中的gotify）
+// 最近的通知保存在内存中，可通过 GET /api/notifications 查看；POST /api/notifications/test 发送测试通知。
+//
+// 服务器告警（ffmpeg连续失败、添加网络共享凭据、处理请求时panic）发送到config.json的alertChannels，
+// 未配置时配置了SMTP则发邮件，否则只推送给页面。
+
+const (
+	maxRecentNotifications = 100
+	notifyTimeout          = 15 * time.Second
+
+	ffmpegAlertThreshold = 5                // 时间窗口内ffmpeg失败这么多次时告警
+	ffmpegAlertWindow    = 10 * time.Minute // 统计失败次数的时间窗口
+	ffmpegAlertInterval  = time.Hour        // 两次告警的最短间隔
+)
+
+type Notification struct {
+	Title   string   `json:"title"`
+	Message string   `json:"message"`
+	Source  string   `json:"source"`          // 发出通知的功能，如monitor
+	Paths   []string `json:"paths,omitempty"` // 相关的文件
+	Time    string   `json:"time"`
+}
+
+// 通知渠道，如 {"type":"webhook","url":"http://..."}
+type NotifyChannel struct {
+	Type  string   `json:"type"`
+	URL   string   `json:"url,omitempty"`
+	To    []string `json:"to,omitempty"`    // email的收件人，为空时使用smtp.to
+	Topic string   `json:"topic,omitempty"` // ntfy的主题，为空时使用ntfy.topic
+}
+
+var notifiers = map[string]func(ctx context.Context, ch NotifyChannel, n Notification) error{
+	"webhook": notifyWebhook,
+	"sse":     notifySSE,
+	"email":   notifyEmail,
+	"ntfy":    notifyNtfy,
+	"gotify":  notifyGotify,
+}
+
+var (
+	recentNotifications []Notification
+	notifySubscribers   = make(map[chan Notification]bool)
+	notifyMutex         sync.Mutex
+
+	ffmpegFailures    []time.Time
+	lastFFmpegAlert   time.Time
+	ffmpegAlertsMutex sync.Mutex
+)
+
+// 检查通知渠道的配置
+func validateNotifyChannel(ch NotifyChannel) error {
+	if _, ok := notifiers[ch.Type]; !ok {
+		return fmt.Errorf("未知的通知渠道: %s", ch.Type)
+	}
+	if ch.Type == "webhook" && ch.URL == "" {
+		return fmt.Errorf("webhook需要url")
+	}
+	if ch.Type == "email" && !smtpConfigured() {
+		return fmt.Errorf("使用email需要先在config.json中配置smtp")
+	}
+	if ch.Type == "ntfy" && ch.Topic == "" && serverConfig.Ntfy.Topic == "" {
+		return fmt.Errorf("使用ntfy需要指定topic或在config.json中配置ntfy")
+	}
+	if ch.Type == "gotify" && (serverConfig.Gotify.URL == "" || serverConfig.Gotify.Token == "") {
+		return fmt.Errorf("使用gotify需要先在config.json中配置gotify")
+	}
+	return nil
+}
+
+// 服务器告警使用的通知渠道
+func alertChannels() []NotifyChannel {
+	if len(serverConfig.AlertChannels) > 0 {
+		return serverConfig.AlertChannels
+	}
+	if smtpConfigured() {
+		return []NotifyChannel{{Type: "email"}, {Type: "sse"}}
+	}
+	return []NotifyChannel{{Type: "sse"}}
+}
+
+// 发送服务器告警，不阻塞调用方
+func sendAlert(title, message string) {
+	log.Printf("告警: %s", title)
+	go sendNotification(alertChannels(), Notification{Title: title, Message: message, Source: "alert"})
+}
+
+// 记录一次ffmpeg失败，短时间内连续失败时告警（通常是ffmpeg不可用或磁盘已满）
+func recordFFmpegFailure(err error) {
+	ffmpegAlertsMutex.Lock()
+	defer ffmpegAlertsMutex.Unlock()
+
+	now := time.Now()
+	recent := ffmpegFailures[:0]
+	for _, t := range ffmpegFailures {
+		if now.Sub(t) < ffmpegAlertWindow {
+			recent = append(recent, t)
+		}
+	}
+	ffmpegFailures = append(recent, now)
+
+	if len(ffmpegFailures) >= ffmpegAlertThreshold && now.Sub(lastFFmpegAlert) >= ffmpegAlertInterval {
+		lastFFmpegAlert = now
+		sendAlert(fmt.Sprintf("ffmpeg在%d分钟内失败%d次", int(ffmpegAlertWindow.Minutes()), len(ffmpegFailures)),
+			"最近一次错误: "+err.Error())
+	}
+}
+
+// 通过各渠道发送通知，单个渠道失败不影响其他渠道
+func sendNotification(channels []NotifyChannel, n Notification) {
+	if n.Time == "" {
+		n.Time = time.Now().Format("2006-01-02 15:04:05")
+	}
+
+	notifyMutex.Lock()
+	recentNotifications = append(recentNotifications, n)
+	if len(recentNotifications) > maxRecentNotifications {
+		recentNotifications = recentNotifications[len(recentNotifications)-maxRecentNotifications:]
+	}
+	notifyMutex.Unlock()
+
+	for _, ch := range channels {
+		send, ok := notifiers[ch.Type]
+		if !ok {
+			log.Printf("未知的通知渠道: %s", ch.Type)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		if err := send(ctx, ch, n); err != nil {
+			log.Printf("发送通知失败(%s): %s, 错误: %v", ch.Type, n.Title, err)
+		}
+		cancel()
+	}
+}
+
+func notifyWebhook(ctx context.Context, ch NotifyChannel, n Notification) error {
+	return postNotificationJSON(ctx, ch.URL, n, nil)
+}
+
+// 发送JSON请求，状态码不是2xx时返回错误
+func postNotificationJSON(ctx context.Context, url string, payload interface{}, header http.Header) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("返回状态%d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 推送给已连接的页面，没有页面连接时只保留在最近通知中
+func notifySSE(ctx context.Context, ch NotifyChannel, n Notification) error {
+	notifyMutex.Lock()
+	defer notifyMutex.Unlock()
+	for sub := range notifySubscribers {
+		select {
+		case sub <- n:
+		default: // 页面处理不过来时丢弃，不阻塞发送方
+		}
+	}
+	return nil
+}
+
+// 最近通知API处理器
+func apiNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	notifyMutex.Lock()
+	list := make([]Notification, len(recentNotifications))
+	copy(list, recentNotifications)
+	notifyMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": list,
+		"count":         len(list),
+	})
+}
+
+// 测试通知API处理器，POST {"channels":[...]}，不指定时使用告警渠道
+func apiNotificationTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Channels []NotifyChannel `json:"channels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+	if len(req.Channels) == 0 {
+		req.Channels = alertChannels()
+	}
+
+	n := Notification{
+		Title:   "测试通知",
+		Message: "来自Everything Web服务器的测试通知",
+		Source:  "test",
+		Time:    time.Now().Format("2006-01-02 15:04:05"),
+	}
+	results := make(map[string]string)
+	for _, ch := range req.Channels {
+		if err := validateNotifyChannel(ch); err != nil {
+			results[ch.Type] = err.Error()
+			continue
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), notifyTimeout)
+		if err := notifiers[ch.Type](ctx, ch, n); err != nil {
+			results[ch.Type] = err.Error()
+		} else {
+			results[ch.Type] = "ok"
+		}
+		cancel()
+	}
+
+	log.Printf("发送测试通知: %v，来源IP: %s", results, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
+
+// 通知事件流处理器
+func apiNotificationEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	sub := make(chan Notification, 16)
+	notifyMutex.Lock()
+	notifySubscribers[sub] = true
+	notifyMutex.Unlock()
+	defer func() {
+		notifyMutex.Lock()
+		delete(notifySubscribers, sub)
+		notifyMutex.Unlock()
+	}()
+
+	// 定期发送注释行，避免代理关闭空闲连接
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case n := <-sub:
+			data, _ := json.Marshal(n)
+			fmt.Fprintf(w, "event: notification\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "strings"
+
+// 紧凑的路径列表
+//
+// "*.jpg" 之类的宽泛查询可能返回上千万个路径。用[]string保存时每个路径
+// 除了内容还要额外16字节的字符串头和一次独立的内存分配；这里把所有路径
+// 拼接到一个字符串中，只保存每个路径的结束位置，处理器只取出需要的那一页。
+type pathList struct {
+	data string  // 所有路径依次拼接
+	ends []int64 // 第i个路径在data中的结束位置
+}
+
+// 从路径切片创建紧凑列表
+func newPathList(paths []string) *pathList {
+	total := 0
+	for _, path := range paths {
+		total += len(path)
+	}
+
+	var builder strings.Builder
+	builder.Grow(total)
+	ends := make([]int64, len(paths))
+	for i, path := range paths {
+		builder.WriteString(path)
+		ends[i] = int64(builder.Len())
+	}
+
+	return &pathList{data: builder.String(), ends: ends}
+}
+
+// 路径数量
+func (pl *pathList) Len() int {
+	return len(pl.ends)
+}
+
+// 第i个路径
+func (pl *pathList) At(i int) string {
+	var start int64
+	if i > 0 {
+		start = pl.ends[i-1]
+	}
+	return pl.data[start:pl.ends[i]]
+}
+
+// 取出[start, end)范围的路径
+func (pl *pathList) Slice(start, end int) []string {
+	paths := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		paths = append(paths, pl.At(i))
+	}
+	return paths
+}
+
+// 估算占用的内存（字节）
+func (pl *pathList) MemorySize() int64 {
+	return int64(len(pl.data)) + int64(len(pl.ends))*8
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 统一的路径处理层
+//
+// Windows的Win32路径规范化会截断末尾的空格和点、把CON/NUL等保留名映射为设备，
+// 并限制路径长度为260个字符。所有访问文件系统的地方都通过这里的函数，
+// 使用 \\?\ 扩展路径绕过这些限制。返回给客户端的路径仍然是普通形式。
+// 其他系统没有这些限制，extendedPath和displayPath原样返回路径（见pathutil_other.go）。
+
+// 含有无法用UTF-8表示的字符（如孤立的UTF-16代理项）的路径，
+// 以此前缀加base64编码后返回给客户端，避免JSON序列化时被替换为U+FFFD
+const rawPathPrefix = "wtf8:"
+
+// 转换为返回给客户端的路径
+func clientPath(path string) string {
+	path = displayPath(path)
+	if utf8.ValidString(path) {
+		return path
+	}
+	return rawPathPrefix + base64.RawURLEncoding.EncodeToString([]byte(path))
+}
+
+// 解析客户端传回的路径（clientPath的逆操作），并统一使用系统的路径分隔符
+func resolveClientPath(path string) string {
+	if strings.HasPrefix(path, rawPathPrefix) {
+		if raw, err := base64.RawURLEncoding.DecodeString(path[len(rawPathPrefix):]); err == nil {
+			return string(raw)
+		}
+	}
+	return filepath.FromSlash(displayPath(path))
+}
+
+// 解析URL路径中的文件路径（去掉前缀后的部分）
+func decodeRequestPath(encoded string) string {
+	// 多次URL解码以确保正确处理
+	filePath := encoded
+	for i := 0; i < 3; i++ {
+		if decoded, err := url.QueryUnescape(filePath); err == nil {
+			filePath = decoded
+		} else {
+			break
+		}
+	}
+
+	// Windows上替换正斜杠为反斜杠
+	return resolveClientPath(filePath)
+}
+
+// 以下函数是os包对应函数的扩展路径版本
+
+// 使用远程Everything时，文件信息和文件夹内容来自远程服务器，不能直接打开文件
+var errRemoteFile = errors.New("远程Everything上的文件不能直接读取")
+
+func statPath(path string) (os.FileInfo, error) {
+	if remoteEverythingEnabled() {
+		return statRemote(path)
+	}
+	return os.Stat(extendedPath(path))
+}
+
+func lstatPath(path string) (os.FileInfo, error) {
+	if remoteEverythingEnabled() {
+		return statRemote(path)
+	}
+	return os.Lstat(extendedPath(path))
+}
+
+func openPath(path string) (*os.File, error) {
+	if remoteEverythingEnabled() {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: errRemoteFile}
+	}
+	return os.Open(extendedPath(path))
+}
+
+func readDirPath(path string) ([]os.DirEntry, error) {
+	if remoteEverythingEnabled() {
+		return readDirRemote(path)
+	}
+	return os.ReadDir(extendedPath(path))
+}
+
+func readFilePath(path string) ([]byte, error) {
+	if remoteEverythingEnabled() {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: errRemoteFile}
+	}
+	return os.ReadFile(extendedPath(path))
+}
+
+// 根据文件大小和修改时间生成强校验ETag
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// 通过路径层打开并提供文件内容（替代http.ServeFile，支持Range和条件请求）
+// 自动设置ETag和Last-Modified，If-None-Match/If-Modified-Since命中时返回304
+func serveFileContent(w http.ResponseWriter, r *http.Request, path string) {
+	if remoteEverythingEnabled() {
+		proxyRemoteFile(w, r, path)
+		return
+	}
+	file, err := openPath(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "无法打开文件: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer file.Close()
+
+	modTime := time.Time{}
+	if info, err := file.Stat(); err == nil {
+		if info.IsDir() {
+			http.Error(w, "不能提供文件夹", http.StatusBadRequest)
+			return
+		}
+		modTime = info.ModTime()
+		if w.Header().Get("ETag") == "" {
+			w.Header().Set("ETag", fileETag(info))
+		}
+	}
+
+	http.ServeContent(newTransferWriter(w, r), r, filepath.Base(path), modTime, file)
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+// 其他系统没有Win32路径规范化和长度限制，直接使用原路径
+func extendedPath(path string) string {
+	return path
+}
+
+func displayPath(path string) string {
+	return path
+}
@@ -0,0 +1,173 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtendedPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`C:\Users\test`, `\\?\C:\Users\test`},
+		{`C:/Users/./test/`, `\\?\C:\Users\test`},
+		{`C:\`, `\\?\C:\`},
+		{`C:\dir\trailing dot.`, `\\?\C:\dir\trailing dot.`},
+		{`C:\dir\trailing space `, `\\?\C:\dir\trailing space `},
+		{`C:\dir\CON`, `\\?\C:\dir\CON`},
+		{`\\server\share\dir`, `\\?\UNC\server\share\dir`},
+		{`\\?\C:\already`, `\\?\C:\already`},
+		{`relative\path`, `relative\path`},
+	}
+
+	for _, tt := range tests {
+		if got := extendedPath(tt.in); got != tt.want {
+			t.Errorf("extendedPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`\\?\C:\Users\test`, `C:\Users\test`},
+		{`\\?\UNC\server\share\dir`, `\\server\share\dir`},
+		{`\\?\Volume{01234567-89ab-cdef-0123-456789abcdef}\`, `\\?\Volume{01234567-89ab-cdef-0123-456789abcdef}\`},
+		{`C:\plain`, `C:\plain`},
+	}
+
+	for _, tt := range tests {
+		if got := displayPath(tt.in); got != tt.want {
+			t.Errorf("displayPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestClientPathRoundTrip(t *testing.T) {
+	paths := []string{
+		`C:\普通\文件.txt`,
+		`C:\dir\emoji 😀.jpg`,
+		// 孤立的高位代理项U+D800在Go中以WTF-8形式表示
+		"C:\\dir\\bad\xed\xa0\x80name.txt",
+		"C:\\dir\\low\xed\xbf\xbfsurrogate",
+	}
+
+	for _, path := range paths {
+		encoded := clientPath(path)
+		if got := resolveClientPath(encoded); got != path {
+			t.Errorf("resolveClientPath(clientPath(%q)) = %q", path, got)
+		}
+	}
+
+	if got := clientPath(`C:\valid`); got != `C:\valid` {
+		t.Errorf("valid UTF-8 path should not be encoded, got %q", got)
+	}
+}
+
+func TestDecodeRequestPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`C%3A%5CUsers%5Ctest.mp4`, `C:\Users\test.mp4`},
+		{`C:/Users/test.mp4`, `C:\Users\test.mp4`},
+		{`%5C%5Cnas%5Cmedia%5Cmovie.mkv`, `\\nas\media\movie.mkv`},
+	}
+
+	for _, tt := range tests {
+		if got := decodeRequestPath(tt.in); got != tt.want {
+			t.Errorf("decodeRequestPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 保留名、末尾带点或空格的文件名只有通过扩展路径才能创建和访问
+func TestExoticFileNames(t *testing.T) {
+	dir := t.TempDir()
+
+	names := []string{
+		"CON",
+		"NUL.txt",
+		"trailing dot.",
+		"trailing space ",
+		"surrogate\xed\xa0\x80.txt",
+	}
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+
+		f, err := os.Create(extendedPath(path))
+		if err != nil {
+			t.Fatalf("create %q: %v", name, err)
+		}
+		f.WriteString("test")
+		f.Close()
+
+		info, err := statPath(path)
+		if err != nil {
+			t.Errorf("statPath(%q): %v", name, err)
+			continue
+		}
+		if info.Size() != 4 {
+			t.Errorf("statPath(%q) size = %d, want 4", name, info.Size())
+		}
+
+		data, err := readFilePath(resolveClientPath(clientPath(path)))
+		if err != nil || string(data) != "test" {
+			t.Errorf("readFilePath(%q) = %q, %v", name, data, err)
+		}
+	}
+
+	entries, err := readDirPath(dir)
+	if err != nil {
+		t.Fatalf("readDirPath: %v", err)
+	}
+	if len(entries) != len(names) {
+		t.Errorf("readDirPath returned %d entries, want %d", len(entries), len(names))
+	}
+}
+
+func TestLongPath(t *testing.T) {
+	dir := t.TempDir()
+
+	path := dir
+	for len(path) < 300 {
+		path = filepath.Join(path, "very_long_directory_name_segment")
+	}
+
+	if err := os.MkdirAll(extendedPath(path), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	info, err := statPath(path)
+	if err != nil {
+		t.Fatalf("statPath on %d-char path: %v", len(path), err)
+	}
+	if !info.IsDir() {
+		t.Errorf("statPath(%q) is not a directory", path)
+	}
+}
+
+// 多次编码、中文和扩展路径前缀，基本情况见TestDecodeRequestPath
+func TestDecodeRequestPathVariants(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"C%3A%2FUsers%2Ftest%2Fa%20b.txt", `C:\Users\test\a b.txt`},
+		{"C%253A%252Fdouble.txt", `C:\double.txt`},
+		{"D:/%E4%B8%AD%E6%96%87.txt", `D:\中文.txt`},
+		{`\\?\C:\long`, `C:\long`},
+	}
+	for _, tt := range tests {
+		if got := decodeRequestPath(tt.in); got != tt.want {
+			t.Errorf("decodeRequestPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
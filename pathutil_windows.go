@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	extendedPrefix    = `\\?\`
+	extendedUNCPrefix = `\\?\UNC\`
+)
+
+// 转换为 \\?\ 扩展长度路径，相对路径保持不变
+func extendedPath(path string) string {
+	if strings.HasPrefix(path, extendedPrefix) {
+		return path
+	}
+
+	path = strings.ReplaceAll(path, "/", "\\")
+
+	if isUNCPath(path) {
+		// \\server\share\dir → \\?\UNC\server\share\dir
+		return extendedUNCPrefix + filepath.Clean(path)[2:]
+	}
+
+	// 只处理带盘符的绝对路径，如 C:\dir
+	if len(path) >= 3 && path[1] == ':' && path[2] == '\\' {
+		return extendedPrefix + filepath.Clean(path)
+	}
+
+	return path
+}
+
+// 去掉 \\?\ 前缀，得到用于显示的普通路径
+func displayPath(path string) string {
+	if strings.HasPrefix(path, extendedUNCPrefix) {
+		return `\\` + path[len(extendedUNCPrefix):]
+	}
+	// 只去掉盘符路径的前缀，卷GUID路径（\\?\Volume{...}）保持不变
+	if strings.HasPrefix(path, extendedPrefix) && len(path) >= 6 && path[5] == ':' {
+		return path[len(extendedPrefix):]
+	}
+	return path
+}
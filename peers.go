@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 其他主机和网络唤醒（Wake-on-LAN）
+//
+//   GET  /api/peers                  配置的其他主机及是否在线（并发请求各主机的地址，任何HTTP响应都算在线）
+//   POST /api/wol {"peer": "名称"}   向主机的MAC地址发送魔术包，唤醒保存文件的电脑后再搜索或浏览
+// 只能唤醒config.json中配置了mac的主机，不接受任意MAC地址。
+
+const (
+	peerCheckTimeout = 3 * time.Second
+	defaultWOLAddr   = "255.255.255.255:9"
+)
+
+type PeerConfig struct {
+	Name string `json:"name"`
+	// 对方的Everything Web服务器或Everything HTTP服务器地址，用于检测是否在线
+	URL string `json:"url"`
+	// 网卡MAC地址，如 "00:11:22:33:44:55"，设置后可以网络唤醒
+	MAC string `json:"mac,omitempty"`
+	// 魔术包发送到的广播地址，默认255.255.255.255:9；跨网段时使用子网的定向广播地址，如192.168.1.255:9
+	Broadcast string `json:"broadcast,omitempty"`
+}
+
+type PeerStatus struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	Online  bool   `json:"online"`
+	Latency int64  `json:"latencyMs,omitempty"`
+	Error   string `json:"error,omitempty"`
+	CanWake bool   `json:"canWake"`
+}
+
+var peerClient = &http.Client{
+	Timeout: peerCheckTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// 按名称查找配置的主机（不区分大小写）
+func findPeer(name string) (PeerConfig, bool) {
+	for _, peer := range serverConfig.Peers {
+		if strings.EqualFold(peer.Name, name) {
+			return peer, true
+		}
+	}
+	return PeerConfig{}, false
+}
+
+// 检测主机是否在线
+func checkPeer(ctx context.Context, peer PeerConfig) PeerStatus {
+	status := PeerStatus{Name: peer.Name, URL: peer.URL, CanWake: peer.MAC != ""}
+	if peer.URL == "" {
+		status.Error = "未配置地址"
+		return status
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, peer.URL, nil)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	start := time.Now()
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	resp.Body.Close()
+	status.Online = true
+	status.Latency = time.Since(start).Milliseconds()
+	return status
+}
+
+// 魔术包：6个0xFF后接16遍MAC地址
+func magicPacket(mac string) ([]byte, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return nil, fmt.Errorf("无效的MAC地址: %s", mac)
+	}
+	packet := bytes.Repeat([]byte{0xFF}, 6)
+	for i := 0; i < 16; i++ {
+		packet = append(packet, hw...)
+	}
+	return packet, nil
+}
+
+// 向主机发送魔术包
+func wakePeer(peer PeerConfig) error {
+	if peer.MAC == "" {
+		return fmt.Errorf("主机%s没有配置mac", peer.Name)
+	}
+	packet, err := magicPacket(peer.MAC)
+	if err != nil {
+		return err
+	}
+	addr := peer.Broadcast
+	if addr == "" {
+		addr = defaultWOLAddr
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(packet)
+	return err
+}
+
+// 主机列表API处理器
+func apiPeersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	peers := serverConfig.Peers
+	statuses := make([]PeerStatus, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = checkPeer(r.Context(), peer)
+		}()
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"peers": statuses,
+		"count": len(statuses),
+	})
+}
+
+// 网络唤醒API处理器
+func apiWOLHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Peer string `json:"peer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Peer == "" {
+		http.Error(w, "请求格式错误，需要peer参数", http.StatusBadRequest)
+		return
+	}
+	peer, ok := findPeer(req.Peer)
+	if !ok {
+		http.Error(w, "没有配置该主机: "+req.Peer, http.StatusNotFound)
+		return
+	}
+	if err := wakePeer(peer); err != nil {
+		log.Printf("网络唤醒失败: %s, 错误: %v, 来源IP: %s", peer.Name, err, r.RemoteAddr)
+		http.Error(w, "网络唤醒失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("网络唤醒: %s (%s), 来源IP: %s", peer.Name, peer.MAC, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"peer":    peer.Name,
+		"message": "已发送唤醒数据包，主机启动后在主机列表中显示为在线",
+	})
+}
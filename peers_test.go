@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMagicPacket(t *testing.T) {
+	packet, err := magicPacket("00-11-22-AA-bb-cc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := []byte{0x00, 0x11, 0x22, 0xAA, 0xBB, 0xCC}
+	if len(packet) != 102 || !bytes.Equal(packet[:6], bytes.Repeat([]byte{0xFF}, 6)) || !bytes.Equal(packet[96:], mac) {
+		t.Errorf("magic packet = %x", packet)
+	}
+	for _, bad := range []string{"", "00:11:22", "00:11:22:33:44:55:66:77"} {
+		if _, err := magicPacket(bad); err == nil {
+			t.Errorf("magicPacket(%q) accepted, want error", bad)
+		}
+	}
+}
+
+func TestPeersAndWOL(t *testing.T) {
+	online := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "需要登录", http.StatusUnauthorized) // 任何HTTP响应都算在线
+	}))
+	defer online.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	// 用本机UDP端口代替广播地址接收魔术包
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	serverConfig.Peers = []PeerConfig{
+		{Name: "NAS", URL: online.URL, MAC: "00:11:22:33:44:55", Broadcast: conn.LocalAddr().String()},
+		{Name: "desktop", URL: closed.URL},
+	}
+	defer func() { serverConfig.Peers = nil }()
+
+	var list struct {
+		Peers []PeerStatus `json:"peers"`
+	}
+	decodeTestJSON(t, serveTestRequest(apiPeersHandler, httptest.NewRequest(http.MethodGet, "/api/peers", nil)), &list)
+	if len(list.Peers) != 2 || !list.Peers[0].Online || !list.Peers[0].CanWake || list.Peers[1].Online || list.Peers[1].CanWake {
+		t.Errorf("peers = %+v", list.Peers)
+	}
+
+	wol := func(body string) int {
+		return serveTestRequest(apiWOLHandler, httptest.NewRequest(http.MethodPost, "/api/wol", strings.NewReader(body))).Code
+	}
+	if code := wol(`{"peer":"unknown"}`); code != http.StatusNotFound {
+		t.Errorf("unknown peer: status = %d, want 404", code)
+	}
+	if code := wol(`{"peer":"desktop"}`); code != http.StatusInternalServerError {
+		t.Errorf("peer without mac: status = %d, want 500", code)
+	}
+	if code := wol(`{"peer":"nas"}`); code != http.StatusOK {
+		t.Fatalf("wake: status = %d, want 200", code)
+	}
+	buf := make([]byte, 200)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil || n != 102 {
+		t.Errorf("received %d bytes, err = %v", n, err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 拼音首字母搜索
+//
+// 以"py:"开头的查询由服务器处理：第一个词是拼音首字母（如bjld），其余部分作为普通的Everything查询
+// 取回候选结果，再把候选文件名中的汉字转换成拼音首字母后匹配，所以"py:bjld ext:mp3"可以找到"北京落地.mp3"。
+// 汉字的首字母通过GBK编码在GB2312一级汉字（按拼音排序）中的位置得到，不需要额外的拼音字库；
+// 二级汉字（按部首排序）和生僻字无法转换，匹配时忽略。多音字按GB2312中的读音处理。
+
+const pinyinMaxCandidates = 500000 // 参与匹配的候选结果上限
+
+// GB2312一级汉字中每个声母的第一个字的编码，汉字编码落在两个边界之间时取前一个字母
+var pinyinBoundaries = []struct {
+	code    uint16
+	initial byte
+}{
+	{0xB0A1, 'a'}, {0xB0C5, 'b'}, {0xB2C1, 'c'}, {0xB4EE, 'd'}, {0xB6EA, 'e'},
+	{0xB7A2, 'f'}, {0xB8C1, 'g'}, {0xB9FE, 'h'}, {0xBBF7, 'j'}, {0xBFA6, 'k'},
+	{0xC0AC, 'l'}, {0xC2E8, 'm'}, {0xC4C3, 'n'}, {0xC5B6, 'o'}, {0xC5BE, 'p'},
+	{0xC6DA, 'q'}, {0xC8BB, 'r'}, {0xC8F6, 's'}, {0xCBFA, 't'}, {0xCDDA, 'w'},
+	{0xCEF4, 'x'}, {0xD1B9, 'y'}, {0xD4D1, 'z'},
+}
+
+const pinyinLastLevel1 = 0xD7F9 // GB2312一级汉字的最后一个字（座）
+
+var (
+	pinyinCache      = make(map[rune]byte) // 汉字到首字母的缓存，0表示无法转换
+	pinyinCacheMutex sync.RWMutex
+)
+
+// 分离查询前的py:，返回拼音首字母、其余的查询和是否为拼音搜索
+func splitPinyinQuery(query string) (string, string, bool) {
+	trimmed := strings.TrimSpace(query)
+	if len(trimmed) < 3 || !strings.EqualFold(trimmed[:3], "py:") {
+		return "", query, false
+	}
+	fields := strings.Fields(trimmed[3:])
+	if len(fields) == 0 {
+		return "", "", true
+	}
+	return strings.ToLower(fields[0]), strings.Join(fields[1:], " "), true
+}
+
+// 单个汉字的拼音首字母，无法转换时返回0
+func pinyinInitial(r rune) byte {
+	pinyinCacheMutex.RLock()
+	initial, ok := pinyinCache[r]
+	pinyinCacheMutex.RUnlock()
+	if ok {
+		return initial
+	}
+
+	initial = 0
+	if code := gbkCode(r); code >= pinyinBoundaries[0].code && code <= pinyinLastLevel1 {
+		i := sort.Search(len(pinyinBoundaries), func(i int) bool { return pinyinBoundaries[i].code > code })
+		initial = pinyinBoundaries[i-1].initial
+	}
+
+	pinyinCacheMutex.Lock()
+	pinyinCache[r] = initial
+	pinyinCacheMutex.Unlock()
+	return initial
+}
+
+// 文件名的首字母串：汉字取拼音首字母，字母和数字保持原样（小写），其他字符忽略
+func pinyinInitials(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= 0x4E00 && r <= 0x9FFF:
+			if initial := pinyinInitial(r); initial != 0 {
+				b.WriteByte(initial)
+			}
+		}
+	}
+	return b.String()
+}
+
+// 执行拼音首字母搜索，首字母出现在文件名开头的结果排在前面
+func searchPinyin(ctx context.Context, initials, query string) ([]string, error) {
+	if initials == "" {
+		return []string{}, nil
+	}
+
+	log.Printf("拼音搜索: %s，候选查询: %s", initials, query)
+	candidates, err := runEverythingQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > pinyinMaxCandidates {
+		log.Printf("拼音搜索候选结果过多(%d)，只匹配前%d个", len(candidates), pinyinMaxCandidates)
+		candidates = candidates[:pinyinMaxCandidates]
+	}
+
+	var prefixed, others []string
+	for i, path := range candidates {
+		if i%1000 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		name := filepath.Base(path)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		s := pinyinInitials(name)
+		if strings.HasPrefix(s, initials) {
+			prefixed = append(prefixed, path)
+		} else if strings.Contains(s, initials) {
+			others = append(others, path)
+		}
+	}
+
+	paths := append(prefixed, others...)
+	if paths == nil {
+		paths = []string{}
+	}
+	log.Printf("拼音搜索: %d个候选结果中%d个匹配", len(candidates), len(paths))
+	return paths, nil
+}
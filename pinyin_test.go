@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestPinyinInitials(t *testing.T) {
+	tests := map[string]string{
+		"北京欢迎你":      "bjhyn",
+		"第2集 长江.mp4": "d2jcjmp4",
+		"Hello世界":    "hellosj",
+		"龘":          "", // 二级汉字和生僻字被忽略
+	}
+	for name, want := range tests {
+		if got := pinyinInitials(name); got != want {
+			t.Errorf("pinyinInitials(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
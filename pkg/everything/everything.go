@@ -0,0 +1,133 @@
+// Package everything 封装Everything SDK（Everything64.dll），可以在其他Go程序中直接使用。
+//
+// 基本用法：
+//
+//	client, err := everything.Find()
+//	if err != nil {
+//		return err
+//	}
+//	results, err := client.Search(ctx, everything.Query{Search: "*.mp4", Max: 100})
+//	if err != nil {
+//		return err
+//	}
+//	defer results.Close()
+//	for results.Next() {
+//		fmt.Println(results.Result().Path)
+//	}
+//	return results.Err()
+//
+// SDK的查询状态在进程内是全局的，同一个Client的查询依次执行：Search返回后直到Results.Close，
+// 其他查询会等待。SDK只在Windows上可用，其他系统上Load和Find返回ErrUnsupported。
+package everything
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 查找DLL的默认位置，依次尝试
+var DefaultDLLPaths = []string{
+	"Everything64.dll", // 当前目录
+	`C:\Program Files\Everything\Everything64.dll`,
+	`C:\Program Files (x86)\Everything\Everything64.dll`,
+}
+
+var (
+	// 在所有位置都找不到DLL
+	ErrNotFound = errors.New("无法找到Everything64.dll，请确保Everything已安装")
+	// Everything没有运行，无法通过IPC查询
+	ErrNotRunning = errors.New("Everything未运行")
+	// 当前系统不支持Everything SDK
+	ErrUnsupported = errors.New("Everything SDK只支持Windows")
+)
+
+// Everything SDK的错误码（Everything_GetLastError），可用errors.Is判断
+type Error int
+
+const (
+	ErrorMemory          Error = 1 // 内存不足
+	ErrorIPC             Error = 2 // 无法连接Everything（未运行）
+	ErrorRegisterClassEx Error = 3
+	ErrorCreateWindow    Error = 4
+	ErrorCreateThread    Error = 5
+	ErrorInvalidIndex    Error = 6 // 结果序号无效
+	ErrorInvalidCall     Error = 7 // 调用顺序错误
+)
+
+func (e Error) Error() string {
+	switch e {
+	case ErrorMemory:
+		return "Everything查询失败: 内存不足"
+	case ErrorIPC:
+		return "Everything查询失败: 无法连接Everything，请确认Everything正在运行"
+	case ErrorInvalidIndex:
+		return "Everything查询失败: 结果序号无效"
+	case ErrorInvalidCall:
+		return "Everything查询失败: 无效的调用"
+	}
+	return fmt.Sprintf("Everything查询失败，错误码: %d", int(e))
+}
+
+// 需要Everything返回的结果字段（Everything_SetRequestFlags），组合使用
+type RequestFlags uint32
+
+const (
+	RequestFileName            RequestFlags = 0x00000001
+	RequestPath                RequestFlags = 0x00000002
+	RequestFullPathAndFileName RequestFlags = 0x00000004
+	RequestExtension           RequestFlags = 0x00000008
+	RequestSize                RequestFlags = 0x00000010
+	RequestDateCreated         RequestFlags = 0x00000020
+	RequestDateModified        RequestFlags = 0x00000040
+)
+
+// 结果排序方式（Everything_SetSort）
+type Sort uint32
+
+const (
+	SortNameAscending          Sort = 1
+	SortNameDescending         Sort = 2
+	SortPathAscending          Sort = 3
+	SortPathDescending         Sort = 4
+	SortSizeAscending          Sort = 5
+	SortSizeDescending         Sort = 6
+	SortExtensionAscending     Sort = 7
+	SortExtensionDescending    Sort = 8
+	SortDateModifiedAscending  Sort = 13
+	SortDateModifiedDescending Sort = 14
+)
+
+// 查询参数
+type Query struct {
+	Search    string       // Everything搜索语法
+	Offset    int          // 跳过的结果数
+	Max       int          // 最多返回的结果数，0表示不限制
+	Sort      Sort         // 为0时使用Everything当前的排序（默认按名称升序）
+	Request   RequestFlags // 为0时只取完整路径
+	MatchCase bool
+	MatchPath bool // 匹配完整路径而不只是文件名
+	Regex     bool // Search为正则表达式
+}
+
+// 单个结果，Request中没有请求的字段为零值
+type Result struct {
+	Path     string
+	IsDir    bool
+	Size     int64 // 索引中没有大小时为-1
+	Modified time.Time
+}
+
+// Everything版本
+type Version struct {
+	Major, Minor, Revision, Build int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Revision, v.Build)
+}
+
+// 版本是否不低于major.minor
+func (v Version) AtLeast(major, minor int) bool {
+	return v.Major > major || v.Major == major && v.Minor >= minor
+}
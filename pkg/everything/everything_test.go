@@ -0,0 +1,27 @@
+package everything
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	v := Version{1, 4, 1, 1026}
+	if got := v.String(); got != "1.4.1.1026" {
+		t.Errorf("String() = %q", got)
+	}
+	if !v.AtLeast(1, 4) || !v.AtLeast(1, 3) || v.AtLeast(1, 5) || v.AtLeast(2, 0) {
+		t.Errorf("AtLeast wrong for %v", v)
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	err := fmt.Errorf("search: %w", Error(2))
+	if !errors.Is(err, ErrorIPC) {
+		t.Errorf("errors.Is(%v, ErrorIPC) = false", err)
+	}
+	if errors.Is(err, ErrorMemory) {
+		t.Errorf("errors.Is(%v, ErrorMemory) = true", err)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package everything
+
+import "context"
+
+// Everything SDK只能在Windows上加载，其他系统上保留相同的API以便编译
+type Client struct{}
+
+func Load(path string) (*Client, error) {
+	return nil, ErrUnsupported
+}
+
+func Find(paths ...string) (*Client, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *Client) Path() string                  { return "" }
+func (c *Client) Version() (Version, error)     { return Version{}, ErrUnsupported }
+func (c *Client) IsDBLoaded() bool              { return false }
+func (c *Client) UpdateAllFolderIndexes() error { return ErrUnsupported }
+func (c *Client) RebuildDB() error              { return ErrUnsupported }
+
+func (c *Client) Search(ctx context.Context, q Query) (*Results, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *Client) Totals(ctx context.Context, search string) (files, folders int, err error) {
+	return 0, 0, ErrUnsupported
+}
+
+type Results struct{}
+
+func (r *Results) Len() int                 { return 0 }
+func (r *Results) Next() bool               { return false }
+func (r *Results) Result() Result           { return Result{} }
+func (r *Results) Err() error               { return nil }
+func (r *Results) Close() error             { return nil }
+func (r *Results) Paths() ([]string, error) { return nil, nil }
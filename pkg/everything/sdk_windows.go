@@ -0,0 +1,297 @@
+//go:build windows
+
+package everything
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	maxPathLength = 4096
+	unlimited     = 0xFFFFFFFF // Everything_SetMax的默认值，不限制结果数
+)
+
+// 已加载的Everything SDK
+type Client struct {
+	path string
+	dll  *syscall.LazyDLL
+	mu   sync.Mutex // SDK的查询状态是全局的，同一时间只能执行一个查询
+
+	setSearch, setMax, setOffset, setRequestFlags    *syscall.LazyProc
+	setSort                                          *syscall.LazyProc
+	setMatchCase, setMatchPath, setRegex, query      *syscall.LazyProc
+	reset, getLastError, getNumResults               *syscall.LazyProc
+	getResultFullPath, getResultSize, getResultDate  *syscall.LazyProc
+	isFolder, getTotFileResults, getTotFolderResults *syscall.LazyProc
+}
+
+// 加载指定位置的Everything64.dll
+func Load(path string) (*Client, error) {
+	dll := syscall.NewLazyDLL(path)
+	if err := dll.Load(); err != nil {
+		return nil, err
+	}
+	return &Client{
+		path:                path,
+		dll:                 dll,
+		setSearch:           dll.NewProc("Everything_SetSearchW"),
+		setMax:              dll.NewProc("Everything_SetMax"),
+		setOffset:           dll.NewProc("Everything_SetOffset"),
+		setRequestFlags:     dll.NewProc("Everything_SetRequestFlags"),
+		setSort:             dll.NewProc("Everything_SetSort"),
+		setMatchCase:        dll.NewProc("Everything_SetMatchCase"),
+		setMatchPath:        dll.NewProc("Everything_SetMatchPath"),
+		setRegex:            dll.NewProc("Everything_SetRegex"),
+		query:               dll.NewProc("Everything_QueryW"),
+		reset:               dll.NewProc("Everything_Reset"),
+		getLastError:        dll.NewProc("Everything_GetLastError"),
+		getNumResults:       dll.NewProc("Everything_GetNumResults"),
+		getResultFullPath:   dll.NewProc("Everything_GetResultFullPathNameW"),
+		getResultSize:       dll.NewProc("Everything_GetResultSize"),
+		getResultDate:       dll.NewProc("Everything_GetResultDateModified"),
+		isFolder:            dll.NewProc("Everything_IsFolderResult"),
+		getTotFileResults:   dll.NewProc("Everything_GetTotFileResults"),
+		getTotFolderResults: dll.NewProc("Everything_GetTotFolderResults"),
+	}, nil
+}
+
+// 依次尝试paths中的位置（为空时使用DefaultDLLPaths），返回第一个能加载的DLL
+func Find(paths ...string) (*Client, error) {
+	if len(paths) == 0 {
+		paths = DefaultDLLPaths
+	}
+	lastErr := ErrNotFound
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		client, err := Load(path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return client, nil
+	}
+	return nil, lastErr
+}
+
+// 已加载的DLL路径
+func (c *Client) Path() string {
+	return c.path
+}
+
+// 正在运行的Everything的版本，未运行时返回ErrNotRunning
+func (c *Client) Version() (Version, error) {
+	major, _, _ := c.dll.NewProc("Everything_GetMajorVersion").Call()
+	if major == 0 {
+		return Version{}, ErrNotRunning
+	}
+	minor, _, _ := c.dll.NewProc("Everything_GetMinorVersion").Call()
+	revision, _, _ := c.dll.NewProc("Everything_GetRevision").Call()
+	build, _, _ := c.dll.NewProc("Everything_GetBuildNumber").Call()
+	return Version{int(major), int(minor), int(revision), int(build)}, nil
+}
+
+// 索引数据库是否已加载完成
+func (c *Client) IsDBLoaded() bool {
+	loaded, _, _ := c.dll.NewProc("Everything_IsDBLoaded").Call()
+	return loaded != 0
+}
+
+func (c *Client) lastError() error {
+	code, _, _ := c.getLastError.Call()
+	return Error(code)
+}
+
+// 执行查询（调用方需持有c.mu）
+func (c *Client) run(q Query, limit uintptr) error {
+	c.reset.Call()
+	searchPtr, err := syscall.UTF16PtrFromString(q.Search)
+	if err != nil {
+		return err
+	}
+	c.setSearch.Call(uintptr(unsafe.Pointer(searchPtr)))
+	c.setMax.Call(limit)
+	if q.Offset > 0 {
+		c.setOffset.Call(uintptr(q.Offset))
+	}
+	if q.Sort != 0 {
+		c.setSort.Call(uintptr(q.Sort))
+	}
+	if q.Request != 0 {
+		c.setRequestFlags.Call(uintptr(q.Request | RequestFullPathAndFileName))
+	}
+	if q.MatchCase {
+		c.setMatchCase.Call(1)
+	}
+	if q.MatchPath {
+		c.setMatchPath.Call(1)
+	}
+	if q.Regex {
+		c.setRegex.Call(1)
+	}
+	if ret, _, _ := c.query.Call(1); ret == 0 {
+		return c.lastError()
+	}
+	return nil
+}
+
+// 执行查询，返回的Results在Close之前占用这个Client
+func (c *Client) Search(ctx context.Context, q Query) (*Results, error) {
+	limit := uintptr(unlimited)
+	if q.Max > 0 {
+		limit = uintptr(q.Max)
+	}
+
+	c.mu.Lock()
+	if err := c.run(q, limit); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	n, _, _ := c.getNumResults.Call()
+	return &Results{
+		client:  c,
+		ctx:     ctx,
+		request: q.Request,
+		count:   int(n),
+		index:   -1,
+		buffer:  make([]uint16, maxPathLength),
+	}, nil
+}
+
+// 匹配查询的文件数和文件夹数，不取回结果
+func (c *Client) Totals(ctx context.Context, search string) (files, folders int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	if err := c.run(Query{Search: search}, 0); err != nil { // 不取回结果，只需要总数
+		return 0, 0, err
+	}
+	f, _, _ := c.getTotFileResults.Call()
+	d, _, _ := c.getTotFolderResults.Call()
+	return int(f), int(d), nil
+}
+
+// 重新扫描所有文件夹索引
+func (c *Client) UpdateAllFolderIndexes() error {
+	return c.command("Everything_UpdateAllFolderIndexes")
+}
+
+// 重建整个数据库
+func (c *Client) RebuildDB() error {
+	return c.command("Everything_RebuildDB")
+}
+
+func (c *Client) command(proc string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ret, _, _ := c.dll.NewProc(proc).Call(); ret == 0 {
+		return c.lastError()
+	}
+	return nil
+}
+
+// 查询结果迭代器
+type Results struct {
+	client  *Client
+	ctx     context.Context
+	request RequestFlags
+	count   int
+	index   int
+	buffer  []uint16
+	current Result
+	err     error
+	closed  bool
+}
+
+// 本次返回的结果数
+func (r *Results) Len() int {
+	return r.count
+}
+
+// 移到下一个结果，没有更多结果、上下文已取消或已关闭时返回false
+func (r *Results) Next() bool {
+	for !r.closed && r.err == nil {
+		r.index++
+		if r.index >= r.count {
+			return false
+		}
+		if r.index%1000 == 0 {
+			if err := r.ctx.Err(); err != nil {
+				r.err = err
+				return false
+			}
+		}
+		if r.load(uintptr(r.index)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 读取第i个结果，路径为空时返回false
+func (r *Results) load(i uintptr) bool {
+	c := r.client
+	c.getResultFullPath.Call(i, uintptr(unsafe.Pointer(&r.buffer[0])), uintptr(len(r.buffer)))
+	r.current = Result{Path: syscall.UTF16ToString(r.buffer)}
+	if r.current.Path == "" {
+		return false
+	}
+	if r.request == 0 {
+		return true
+	}
+
+	isDir, _, _ := c.isFolder.Call(i)
+	r.current.IsDir = isDir != 0
+	if r.request&RequestSize != 0 {
+		var size int64
+		if ok, _, _ := c.getResultSize.Call(i, uintptr(unsafe.Pointer(&size))); ok != 0 {
+			r.current.Size = size
+		} else if !r.current.IsDir {
+			r.current.Size = -1
+		}
+	}
+	if r.request&RequestDateModified != 0 {
+		var ft syscall.Filetime
+		if ok, _, _ := c.getResultDate.Call(i, uintptr(unsafe.Pointer(&ft))); ok != 0 && ft.Nanoseconds() > 0 {
+			r.current.Modified = time.Unix(0, ft.Nanoseconds())
+		}
+	}
+	return true
+}
+
+// 当前结果
+func (r *Results) Result() Result {
+	return r.current
+}
+
+// 迭代中遇到的错误（上下文取消）
+func (r *Results) Err() error {
+	return r.err
+}
+
+// 释放Client，之后才能执行下一个查询
+func (r *Results) Close() error {
+	if !r.closed {
+		r.closed = true
+		r.client.mu.Unlock()
+	}
+	return nil
+}
+
+// 取回全部结果的完整路径
+func (r *Results) Paths() ([]string, error) {
+	defer r.Close()
+	paths := make([]string, 0, r.count)
+	for r.Next() {
+		paths = append(paths, r.current.Path)
+	}
+	return paths, r.err
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// 客户端偏好设置，按会话保存在服务器端，换浏览器标签或页面时保持一致
+type UserPrefs struct {
+	PageSize     int    `json:"pageSize"`
+	SortBy       string `json:"sortBy"`       // name, size, date, type, rating, natural
+	SortOrder    string `json:"sortOrder"`    // asc, desc
+	ViewMode     string `json:"viewMode"`     // list, compact
+	MuteAutoplay string `json:"muteAutoplay"` // auto（按来源判断）, always, never
+	ShowHidden   bool   `json:"showHidden"`
+	GroupBy      string `json:"groupBy"`      // 搜索结果分组：空或folder
+	AutoPlayNext bool   `json:"autoPlayNext"` // 视频播放完后自动播放同一文件夹中的下一个
+}
+
+const prefsFileName = "prefs.json"
+
+var (
+	userPrefs   = make(map[string]UserPrefs) // 会话ID → 偏好设置
+	prefsMutex  sync.RWMutex
+	prefsLoaded sync.Once
+)
+
+// 默认偏好，与页面原有的默认值一致
+func defaultPrefs() UserPrefs {
+	return UserPrefs{
+		PageSize:     DefaultPageSize,
+		SortBy:       "name",
+		SortOrder:    "asc",
+		ViewMode:     "list",
+		MuteAutoplay: "auto",
+	}
+}
+
+func ensurePrefsLoaded() {
+	prefsLoaded.Do(func() {
+		prefsMutex.Lock()
+		defer prefsMutex.Unlock()
+		if err := loadJSONFile(prefsFileName, &userPrefs); err != nil {
+			log.Printf("加载偏好设置失败: %v", err)
+		}
+		if userPrefs == nil {
+			userPrefs = make(map[string]UserPrefs)
+		}
+	})
+}
+
+// 修正无效的取值
+func normalizePrefs(p UserPrefs) UserPrefs {
+	def := defaultPrefs()
+	if p.PageSize <= 0 || p.PageSize > MaxPageSize {
+		p.PageSize = def.PageSize
+	}
+	switch p.SortBy {
+	case "name", "size", "date", "type", "rating", "natural":
+	default:
+		p.SortBy = def.SortBy
+	}
+	if p.SortOrder != "desc" {
+		p.SortOrder = "asc"
+	}
+	if p.ViewMode != "compact" {
+		p.ViewMode = "list"
+	}
+	switch p.MuteAutoplay {
+	case "auto", "always", "never":
+	default:
+		p.MuteAutoplay = def.MuteAutoplay
+	}
+	if p.GroupBy != "folder" {
+		p.GroupBy = ""
+	}
+	return p
+}
+
+// 获取会话的偏好设置，没有保存过时返回默认值
+func getPrefs(sessionID string) UserPrefs {
+	ensurePrefsLoaded()
+
+	prefsMutex.RLock()
+	defer prefsMutex.RUnlock()
+
+	if p, ok := userPrefs[sessionID]; ok {
+		return normalizePrefs(p)
+	}
+	return defaultPrefs()
+}
+
+// 保存会话的偏好设置
+func savePrefs(sessionID string, p UserPrefs) (UserPrefs, error) {
+	ensurePrefsLoaded()
+
+	p = normalizePrefs(p)
+
+	prefsMutex.Lock()
+	defer prefsMutex.Unlock()
+
+	userPrefs[sessionID] = p
+	return p, saveJSONFile(prefsFileName, userPrefs)
+}
+
+// 不创建新会话，读取请求所属会话的偏好设置
+func requestPrefs(r *http.Request) UserPrefs {
+	sessionID := ""
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		sessionID = cookie.Value
+	}
+	return getPrefs(sessionID)
+}
+
+// 根据偏好和访问来源决定视频是否静音自动播放
+func resolveMuteAutoplay(r *http.Request, fromSearchPage bool) bool {
+	switch requestPrefs(r).MuteAutoplay {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return !fromSearchPage
+	}
+}
+
+// 偏好设置API处理器
+// GET 获取；PUT 整体替换；PATCH 只修改提交的字段
+func apiPrefsHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := getSessionID(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(getPrefs(sessionID))
+
+	case http.MethodPut, http.MethodPatch:
+		prefs := defaultPrefs()
+		if r.Method == http.MethodPatch {
+			prefs = getPrefs(sessionID)
+		}
+		// 解码到已有值上，PATCH时未提交的字段保持不变
+		if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		saved, err := savePrefs(sessionID, prefs)
+		if err != nil {
+			log.Printf("保存偏好设置失败: %v", err)
+			http.Error(w, "保存偏好设置失败", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(saved)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 页面初始化数据：偏好设置和服务器功能开关，页面加载时一次获取
+func apiBootstrapHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"prefs": getPrefs(sessionID),
+		"features": map[string]bool{
+			"ffmpeg":         ffmpegAvailable.Load(),
+			"fileOperations": serverConfig.EnableFileOperations && canAdmin(r),
+			"admin":          canAdmin(r),
+		},
+		"maxPageSize":       MaxPageSize,
+		"previewExtensions": previewExtensionList(),
+		"actions":           adminActionInfos(r),
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 预览提供者
+//
+// 特殊格式（DICOM、STL、PSD等）的预览由提供者按扩展名处理，不需要修改核心代码：
+//   GET /preview/文件路径           由对应的提供者生成预览（HTML页面、JSON或文件流）
+//   GET /api/preview/providers      所有提供者和它们处理的扩展名
+// 内置提供者在init中用registerPreviewProvider注册；config.json的previewCommands声明外部命令，
+// 命令把预览写到标准输出，同一扩展名优先使用外部命令。页面上这些扩展名的文件显示"预览"按钮。
+// Go的plugin包不支持Windows，因此不加载Go插件，外部程序通过previewCommands接入。
+
+const (
+	defaultPreviewTimeout = 30 * time.Second
+	maxPreviewOutput      = 64 << 20
+)
+
+// 预览内容，Body由调用方关闭
+type Preview struct {
+	ContentType string
+	Body        io.ReadCloser
+}
+
+type PreviewProvider interface {
+	Name() string
+	// 处理的扩展名（小写，带点），用于页面上显示预览按钮
+	Extensions() []string
+	// 是否处理该扩展名
+	CanHandle(ext string) bool
+	// 生成文件的预览，请求取消时ctx结束；HTML页面中内联脚本的nonce可以从ctx取得（cspNonceKey）
+	Render(ctx context.Context, path string) (*Preview, error)
+}
+
+// 按扩展名列表实现Extensions和CanHandle，内置提供者嵌入使用
+type previewExtensions []string
+
+func (e previewExtensions) Extensions() []string      { return e }
+func (e previewExtensions) CanHandle(ext string) bool { return slices.Contains(e, ext) }
+
+// 外部命令预览，如 {"name": "stl", "extensions": [".stl"], "command": ["D:\\Tools\\stl2html.exe", "{path}"]}
+type PreviewCommand struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions"`
+	// 程序和参数，参数中的{path}替换为文件的完整路径
+	Command []string `json:"command"`
+	// 命令输出的类型，默认text/html; charset=utf-8
+	ContentType string `json:"contentType,omitempty"`
+	// 超时时间（秒），默认30秒
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+type commandPreviewProvider struct {
+	previewExtensions
+	cmd PreviewCommand
+}
+
+var previewProviders []PreviewProvider // 内置提供者，按注册顺序匹配
+
+// 注册内置提供者，在init中调用
+func registerPreviewProvider(p PreviewProvider) {
+	previewProviders = append(previewProviders, p)
+}
+
+// 配置中声明的外部命令提供者
+func commandPreviewProviders() []PreviewProvider {
+	var providers []PreviewProvider
+	for _, cmd := range serverConfig.PreviewCommands {
+		if len(cmd.Command) == 0 || len(cmd.Extensions) == 0 {
+			continue
+		}
+		exts := make(previewExtensions, len(cmd.Extensions))
+		for i, ext := range cmd.Extensions {
+			exts[i] = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+		}
+		providers = append(providers, commandPreviewProvider{exts, cmd})
+	}
+	return providers
+}
+
+// 所有提供者，外部命令在前
+func allPreviewProviders() []PreviewProvider {
+	return append(commandPreviewProviders(), previewProviders...)
+}
+
+// 处理该扩展名的提供者，没有时返回nil
+func previewProviderFor(ext string) PreviewProvider {
+	ext = strings.ToLower(ext)
+	for _, p := range allPreviewProviders() {
+		if p.CanHandle(ext) {
+			return p
+		}
+	}
+	return nil
+}
+
+// 有预览提供者的扩展名（不带点），供页面显示预览按钮
+func previewExtensionList() []string {
+	exts := []string{}
+	for _, p := range allPreviewProviders() {
+		for _, ext := range p.Extensions() {
+			if ext = strings.TrimPrefix(ext, "."); !slices.Contains(exts, ext) {
+				exts = append(exts, ext)
+			}
+		}
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+func (p commandPreviewProvider) Name() string {
+	if p.cmd.Name != "" {
+		return p.cmd.Name
+	}
+	return filepath.Base(p.cmd.Command[0])
+}
+
+// 执行命令，标准输出作为预览内容
+func (p commandPreviewProvider) Render(ctx context.Context, path string) (*Preview, error) {
+	timeout := defaultPreviewTimeout
+	if p.cmd.TimeoutSeconds > 0 {
+		timeout = time.Duration(p.cmd.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := make([]string, len(p.cmd.Command)-1)
+	for i, arg := range p.cmd.Command[1:] {
+		args[i] = strings.ReplaceAll(arg, "{path}", path)
+	}
+	cmd := exec.CommandContext(ctx, p.cmd.Command[0], args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	out, readErr := io.ReadAll(io.LimitReader(stdout, maxPreviewOutput+1))
+	if len(out) > maxPreviewOutput {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("预览命令输出超过%dMB", maxPreviewOutput>>20)
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("预览命令超时（%v）", timeout)
+		}
+		return nil, fmt.Errorf("预览命令失败: %v, %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	contentType := p.cmd.ContentType
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+	return &Preview{ContentType: contentType, Body: io.NopCloser(bytes.NewReader(out))}, nil
+}
+
+// 预览处理器 /preview/文件路径
+func previewHandler(w http.ResponseWriter, r *http.Request) {
+	filePath := decodeRequestPath(strings.TrimPrefix(r.URL.Path, "/preview/"))
+	if remoteEverythingEnabled() {
+		http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, err := statPath(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "访问文件失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if info.IsDir() {
+		http.Error(w, "不能预览文件夹", http.StatusBadRequest)
+		return
+	}
+	provider := previewProviderFor(filepath.Ext(filePath))
+	if provider == nil {
+		http.Error(w, "没有处理该类型的预览提供者", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	start := time.Now()
+	preview, err := provider.Render(r.Context(), filePath)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		log.Printf("生成预览失败: %s, 提供者: %s, 错误: %v", filePath, provider.Name(), err)
+		http.Error(w, "生成预览失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer preview.Body.Close()
+
+	log.Printf("预览: %s, 提供者: %s, 用时%v, 来源IP: %s", filePath, provider.Name(), time.Since(start).Round(time.Millisecond), r.RemoteAddr)
+	w.Header().Set("Content-Type", preview.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	io.Copy(w, preview.Body)
+}
+
+// 预览提供者列表API处理器
+func apiPreviewProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type providerInfo struct {
+		Name       string   `json:"name"`
+		Extensions []string `json:"extensions"`
+		Source     string   `json:"source"` // builtin或command
+	}
+	list := []providerInfo{}
+	for _, p := range allPreviewProviders() {
+		source := "builtin"
+		if _, ok := p.(commandPreviewProvider); ok {
+			source = "command"
+		}
+		list = append(list, providerInfo{Name: p.Name(), Extensions: p.Extensions(), Source: source})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers": list,
+		"count":     len(list),
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testPreviewProvider struct{ previewExtensions }
+
+func (testPreviewProvider) Name() string { return "test" }
+
+func (testPreviewProvider) Render(ctx context.Context, path string) (*Preview, error) {
+	return &Preview{ContentType: "application/json", Body: io.NopCloser(strings.NewReader(`{"name":"` + filepath.Base(path) + `"}`))}, nil
+}
+
+// 作为外部预览命令运行：输出文件名和大小
+func TestPreviewHelperProcess(t *testing.T) {
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	if len(args) != 2 {
+		return
+	}
+	info, err := os.Stat(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(3)
+	}
+	fmt.Printf("<p>%s %d</p>", info.Name(), info.Size())
+	os.Exit(0)
+}
+
+func TestPreviewProviders(t *testing.T) {
+	dir := t.TempDir()
+	paths := createTestFiles(t, dir, "part.stl", "scan.dcm", "notes.xyz")
+
+	saved := previewProviders
+	defer func() { previewProviders = saved }()
+	registerPreviewProvider(testPreviewProvider{previewExtensions{".stl", ".dcm"}})
+
+	// 外部命令优先于内置提供者
+	serverConfig.PreviewCommands = []PreviewCommand{
+		{Name: "dicom", Extensions: []string{"DCM"}, Command: []string{os.Args[0], "-test.run=TestPreviewHelperProcess", "--", "{path}"}},
+	}
+	defer func() { serverConfig.PreviewCommands = nil }()
+
+	if got := strings.Join(previewExtensionList(), ","); !strings.Contains(got, "dcm") || !strings.Contains(got, "stl") {
+		t.Errorf("previewExtensionList() = %s", got)
+	}
+
+	tests := []struct {
+		path, wantType, wantBody string
+		wantCode                 int
+	}{
+		{paths[0], "application/json", `{"name":"part.stl"}`, http.StatusOK},
+		{paths[1], "text/html; charset=utf-8", "<p>scan.dcm 10</p>", http.StatusOK},
+		{paths[2], "", "", http.StatusUnsupportedMediaType},
+		{filepath.Join(dir, "missing.stl"), "", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := serveTestRequest(previewHandler, httptest.NewRequest(http.MethodGet, "/preview/"+url.PathEscape(tt.path), nil))
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: status = %d, want %d", filepath.Base(tt.path), rec.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantCode == http.StatusOK && (rec.Header().Get("Content-Type") != tt.wantType || rec.Body.String() != tt.wantBody) {
+			t.Errorf("%s: %s %q", filepath.Base(tt.path), rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+	}
+
+	// 命令失败时返回500
+	serverConfig.PreviewCommands[0].Command = []string{filepath.Join(dir, "no-such-command")}
+	rec := serveTestRequest(previewHandler, httptest.NewRequest(http.MethodGet, "/preview/"+url.PathEscape(paths[1]), nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("failing command: status = %d, want 500", rec.Code)
+	}
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// 文件属性
+//
+//   GET /api/properties?path=文件路径
+//
+// 返回文件的大小和修改时间；.exe、.dll、.msi等可执行文件和安装包还返回版本信息（公司、产品名称、
+// 文件版本）、CPU架构和数字签名状态，用来区分多个同名的setup.exe。
+// PE文件读取文件头和版本资源（VS_VERSIONINFO），MSI读取摘要信息和Property表，签名见authenticode.go。
+
+var executableExtensions = []string{".exe", ".dll", ".sys", ".ocx", ".cpl", ".scr", ".efi", ".msi", ".msp", ".msm"}
+
+var errNotExecutable = errors.New("不是有效的可执行文件或安装包")
+
+// 可执行文件或安装包的属性，字段按文件类型填写
+type ExecutableInfo struct {
+	Type            string           `json:"type"` // PE、MSI
+	Architecture    string           `json:"architecture,omitempty"`
+	Subsystem       string           `json:"subsystem,omitempty"` // gui、console、native、efi
+	DLL             bool             `json:"dll,omitempty"`
+	DotNet          bool             `json:"dotnet,omitempty"`
+	LinkTime        *time.Time       `json:"linkTime,omitempty"`
+	FileVersion     string           `json:"fileVersion,omitempty"`
+	ProductVersion  string           `json:"productVersion,omitempty"`
+	CompanyName     string           `json:"companyName,omitempty"`
+	ProductName     string           `json:"productName,omitempty"`
+	FileDescription string           `json:"fileDescription,omitempty"`
+	OriginalName    string           `json:"originalFilename,omitempty"`
+	Copyright       string           `json:"copyright,omitempty"`
+	ProductCode     string           `json:"productCode,omitempty"` // MSI
+	UpgradeCode     string           `json:"upgradeCode,omitempty"`
+	PackageCode     string           `json:"packageCode,omitempty"`
+	Languages       string           `json:"languages,omitempty"`
+	Signature       ExecutableSigner `json:"signature"`
+}
+
+type FileProperties struct {
+	Path       string          `json:"path"`
+	Name       string          `json:"name"`
+	Size       int64           `json:"size"`
+	ModTime    time.Time       `json:"modTime"`
+	Dir        bool            `json:"dir"`
+	Executable *ExecutableInfo `json:"executable,omitempty"`
+	Error      string          `json:"error,omitempty"` // 可执行文件解析失败的原因
+}
+
+func isExecutableFile(path string) bool {
+	return slices.Contains(executableExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// 读取可执行文件或安装包的属性，按文件头判断类型（扩展名不可靠）
+func readExecutableInfo(ctx context.Context, path string) (*ExecutableInfo, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var magic [8]byte
+	if _, err := file.ReadAt(magic[:], 0); err != nil {
+		return nil, errNotExecutable
+	}
+	var info *ExecutableInfo
+	switch {
+	case magic[0] == 'M' && magic[1] == 'Z':
+		info, err = readPEInfo(ctx, file, stat.Size())
+	case string(magic[:]) == cfbSignature:
+		info, err = readMSIInfo(file, stat.Size())
+	default:
+		return nil, errNotExecutable
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 内容校验通过后再由系统检查证书链（只有Windows上可用）
+	if info.Signature.Status == signatureSigned {
+		verifySignatureTrust(path, &info.Signature)
+	}
+	return info, nil
+}
+
+var peMachines = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "x86",
+	pe.IMAGE_FILE_MACHINE_AMD64: "x64",
+	pe.IMAGE_FILE_MACHINE_ARM64: "ARM64",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "ARM",
+	pe.IMAGE_FILE_MACHINE_ARM:   "ARM",
+	pe.IMAGE_FILE_MACHINE_IA64:  "IA64",
+}
+
+var peSubsystems = map[uint16]string{
+	pe.IMAGE_SUBSYSTEM_NATIVE:                  "native",
+	pe.IMAGE_SUBSYSTEM_WINDOWS_GUI:             "gui",
+	pe.IMAGE_SUBSYSTEM_WINDOWS_CUI:             "console",
+	pe.IMAGE_SUBSYSTEM_EFI_APPLICATION:         "efi",
+	pe.IMAGE_SUBSYSTEM_EFI_BOOT_SERVICE_DRIVER: "efi",
+	pe.IMAGE_SUBSYSTEM_EFI_RUNTIME_DRIVER:      "efi",
+}
+
+func readPEInfo(ctx context.Context, file *os.File, size int64) (*ExecutableInfo, error) {
+	f, err := pe.NewFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNotExecutable, err)
+	}
+	info := &ExecutableInfo{Type: "PE", DLL: f.Characteristics&pe.IMAGE_FILE_DLL != 0}
+	info.Architecture = peMachines[f.Machine]
+	if info.Architecture == "" {
+		info.Architecture = fmt.Sprintf("0x%04X", f.Machine)
+	}
+	// 可重现构建的时间戳是哈希值，明显不合理时不显示
+	if t := time.Unix(int64(f.TimeDateStamp), 0).UTC(); f.TimeDateStamp != 0 && t.Before(time.Now().AddDate(1, 0, 0)) {
+		info.LinkTime = &t
+	}
+
+	var dirs []pe.DataDirectory
+	var subsystem uint16
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dirs, subsystem = oh.DataDirectory[:min(oh.NumberOfRvaAndSizes, 16)], oh.Subsystem
+	case *pe.OptionalHeader64:
+		dirs, subsystem = oh.DataDirectory[:min(oh.NumberOfRvaAndSizes, 16)], oh.Subsystem
+	default:
+		return nil, errNotExecutable
+	}
+	info.Subsystem = peSubsystems[subsystem]
+	if len(dirs) > pe.IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR && dirs[pe.IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR].VirtualAddress != 0 {
+		info.DotNet = true
+	}
+	if len(dirs) > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+		if strs, fixed := peVersionInfo(f, dirs[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]); strs != nil || fixed != nil {
+			info.applyVersionInfo(strs, fixed)
+		}
+	}
+	info.Signature = peSignature(ctx, file, size, f, dirs)
+	return info, nil
+}
+
+// 固定版本信息（VS_FIXEDFILEINFO）中的文件版本和产品版本
+type peFixedVersion struct{ file, product string }
+
+func (info *ExecutableInfo) applyVersionInfo(strs map[string]string, fixed *peFixedVersion) {
+	info.CompanyName = strs["CompanyName"]
+	info.ProductName = strs["ProductName"]
+	info.FileDescription = strs["FileDescription"]
+	info.OriginalName = strs["OriginalFilename"]
+	info.Copyright = strs["LegalCopyright"]
+	info.FileVersion = strs["FileVersion"]
+	info.ProductVersion = strs["ProductVersion"]
+	// 数字版本更可靠，字符串版本经常带有构建说明或者没有更新
+	if fixed != nil {
+		info.FileVersion = fixed.file
+		info.ProductVersion = fixed.product
+	}
+}
+
+// 相对虚拟地址所在节的数据
+func peSectionData(f *pe.File, rva, size uint32) []byte {
+	for _, s := range f.Sections {
+		end := s.VirtualAddress + max(s.VirtualSize, s.Size)
+		if rva < s.VirtualAddress || rva >= end {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil
+		}
+		off := int(rva - s.VirtualAddress)
+		if off >= len(data) {
+			return nil
+		}
+		return data[off:min(len(data), off+int(size))]
+	}
+	return nil
+}
+
+// 从资源目录中找到版本资源（RT_VERSION），解析其中的字符串和固定版本信息
+func peVersionInfo(f *pe.File, dir pe.DataDirectory) (map[string]string, *peFixedVersion) {
+	rsrc := peSectionData(f, dir.VirtualAddress, dir.Size)
+	// 资源目录的三层：类型 → 名称 → 语言，后两层取第一项
+	const rtVersion = 16
+	entry, ok := peResourceEntry(rsrc, 0, rtVersion)
+	for level := 0; ok && level < 2; level++ {
+		if entry&0x80000000 == 0 {
+			return nil, nil
+		}
+		entry, ok = peResourceEntry(rsrc, entry&0x7FFFFFFF, -1)
+	}
+	if !ok || entry&0x80000000 != 0 || int(entry)+8 > len(rsrc) {
+		return nil, nil
+	}
+	rva := binary.LittleEndian.Uint32(rsrc[entry:])
+	size := binary.LittleEndian.Uint32(rsrc[entry+4:])
+	data := peSectionData(f, rva, size)
+	root, ok := parseVersionBlock(data)
+	if !ok || root.key != "VS_VERSION_INFO" {
+		return nil, nil
+	}
+
+	var fixed *peFixedVersion
+	if v := root.value; len(v) >= 52 && binary.LittleEndian.Uint32(v) == 0xFEEF04BD {
+		version := func(ms, ls uint32) string {
+			return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xFFFF, ls>>16, ls&0xFFFF)
+		}
+		le := binary.LittleEndian
+		fixed = &peFixedVersion{version(le.Uint32(v[8:]), le.Uint32(v[12:])), version(le.Uint32(v[16:]), le.Uint32(v[20:]))}
+		if fixed.file == "0.0.0.0" && fixed.product == "0.0.0.0" {
+			fixed = nil
+		}
+	}
+
+	// 有多个语言的字符串表时优先中文，其次英文
+	var strs map[string]string
+	rank := 3
+	for _, child := range root.children {
+		if child.key != "StringFileInfo" {
+			continue
+		}
+		for _, table := range child.children {
+			r := 2
+			switch lang := strings.ToLower(table.key); {
+			case strings.HasPrefix(lang, "0804"):
+				r = 0
+			case strings.HasPrefix(lang, "0409"):
+				r = 1
+			}
+			if r >= rank {
+				continue
+			}
+			rank, strs = r, make(map[string]string)
+			for _, s := range table.children {
+				if v := strings.TrimSpace(decodeUTF16Z(s.value)); v != "" {
+					strs[s.key] = v
+				}
+			}
+		}
+	}
+	return strs, fixed
+}
+
+// 在资源目录中查找ID为id的项（id为-1时取第一项），返回项的数据偏移
+func peResourceEntry(rsrc []byte, dirOffset uint32, id int) (uint32, bool) {
+	off := int(dirOffset)
+	if off+16 > len(rsrc) {
+		return 0, false
+	}
+	named := int(binary.LittleEndian.Uint16(rsrc[off+12:]))
+	ids := int(binary.LittleEndian.Uint16(rsrc[off+14:]))
+	for i := 0; i < named+ids; i++ {
+		e := off + 16 + i*8
+		if e+8 > len(rsrc) {
+			break
+		}
+		name := binary.LittleEndian.Uint32(rsrc[e:])
+		if id < 0 || name&0x80000000 == 0 && int(name) == id {
+			return binary.LittleEndian.Uint32(rsrc[e+4:]), true
+		}
+	}
+	return 0, false
+}
+
+// 版本资源中的块：wLength、wValueLength、wType、键名，然后是值和子块，都按4字节对齐
+type versionBlock struct {
+	key      string
+	value    []byte
+	children []versionBlock
+}
+
+func parseVersionBlock(b []byte) (versionBlock, bool) {
+	return parseVersionBlockDepth(b, 0)
+}
+
+func parseVersionBlockDepth(b []byte, depth int) (versionBlock, bool) {
+	if len(b) < 6 || depth > 4 {
+		return versionBlock{}, false
+	}
+	length := int(binary.LittleEndian.Uint16(b))
+	valueLen := int(binary.LittleEndian.Uint16(b[2:]))
+	if length < 6 || length > len(b) {
+		return versionBlock{}, false
+	}
+	b = b[:length]
+	var block versionBlock
+	off := 6
+	for ; off+1 < len(b); off += 2 {
+		if b[off] == 0 && b[off+1] == 0 {
+			break
+		}
+	}
+	block.key = decodeUTF16Z(b[6:off])
+	off = align4(off + 2)
+	// 文本值的长度按字符计算，个别编译器按字节写入，超出块时截断
+	if binary.LittleEndian.Uint16(b[4:]) == 1 {
+		valueLen *= 2
+	}
+	if off < len(b) {
+		block.value = b[off:min(len(b), off+valueLen)]
+	}
+	for off = align4(off + valueLen); off < len(b); {
+		child, ok := parseVersionBlockDepth(b[off:], depth+1)
+		if !ok {
+			break
+		}
+		block.children = append(block.children, child)
+		off = align4(off + int(binary.LittleEndian.Uint16(b[off:])))
+	}
+	return block, true
+}
+
+func align4(n int) int { return (n + 3) &^ 3 }
+
+// UTF-16LE字符串，到第一个NUL为止
+func decodeUTF16Z(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u := binary.LittleEndian.Uint16(b[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units))
+}
+
+func readFileProperties(ctx context.Context, path string) (*FileProperties, error) {
+	info, err := statPath(path)
+	if err != nil {
+		return nil, err
+	}
+	props := &FileProperties{
+		Path:    clientPath(path),
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Dir:     info.IsDir(),
+	}
+	if !info.IsDir() && isExecutableFile(path) {
+		exe, err := readExecutableInfo(ctx, path)
+		if err != nil {
+			props.Error = err.Error()
+		}
+		props.Executable = exe
+	}
+	return props, nil
+}
+
+func apiPropertiesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	clientSrc := r.URL.Query().Get("path")
+	if clientSrc == "" {
+		http.Error(w, "path参数不能为空", http.StatusBadRequest)
+		return
+	}
+	if remoteEverythingEnabled() {
+		http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+		return
+	}
+	path := resolveClientPath(clientSrc)
+	props, err := readFileProperties(r.Context(), path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+			return
+		}
+		log.Printf("读取文件属性失败: %s, 错误: %v", path, err)
+		http.Error(w, "读取文件属性失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if props.Executable != nil {
+		log.Printf("文件属性: %s, %s %s, 签名: %s, 来源IP: %s", path, props.Executable.Type, props.Executable.Architecture, props.Executable.Signature.Status, r.RemoteAddr)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(props)
+}
+
+type executablePreviewProvider struct{ previewExtensions }
+
+func init() {
+	registerPreviewProvider(executablePreviewProvider{previewExtensions{".exe", ".dll", ".msi"}})
+}
+
+func (executablePreviewProvider) Name() string { return "executable" }
+
+func (executablePreviewProvider) Render(ctx context.Context, path string) (*Preview, error) {
+	props, err := readFileProperties(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if props.Executable == nil {
+		return nil, errors.New(props.Error)
+	}
+	page := executablePreviewPage(props)
+	return &Preview{ContentType: "text/html; charset=utf-8", Body: io.NopCloser(strings.NewReader(page))}, nil
+}
+
+var signatureStatusText = map[string]string{
+	signatureUnsigned:  "未签名",
+	signatureSigned:    "已签名（内容完整，未检查证书链）",
+	signatureTrusted:   "已签名，系统信任",
+	signatureUntrusted: "已签名，系统不信任",
+	signatureInvalid:   "签名无效",
+}
+
+func executablePreviewPage(props *FileProperties) string {
+	exe := props.Executable
+	var rows strings.Builder
+	row := func(name, value string) {
+		if value != "" {
+			rows.WriteString("<tr><th>" + name + "</th><td>" + html.EscapeString(value) + "</td></tr>\n")
+		}
+	}
+	kind := exe.Type
+	switch {
+	case exe.Type == "MSI":
+		kind = "Windows Installer安装包"
+	case exe.DLL:
+		kind = "动态链接库"
+	case exe.Subsystem == "console":
+		kind = "控制台程序"
+	case exe.Subsystem == "gui":
+		kind = "图形界面程序"
+	}
+	if exe.DotNet {
+		kind += "（.NET）"
+	}
+	row("类型", kind)
+	row("架构", exe.Architecture)
+	row("产品名称", exe.ProductName)
+	row("公司", exe.CompanyName)
+	row("文件说明", exe.FileDescription)
+	row("文件版本", exe.FileVersion)
+	row("产品版本", exe.ProductVersion)
+	row("原始文件名", exe.OriginalName)
+	row("版权", exe.Copyright)
+	row("ProductCode", exe.ProductCode)
+	row("UpgradeCode", exe.UpgradeCode)
+	row("PackageCode", exe.PackageCode)
+	row("语言", exe.Languages)
+	if exe.LinkTime != nil {
+		row("链接时间", exe.LinkTime.Local().Format("2006-01-02 15:04:05"))
+	}
+	row("大小", formatSize(props.Size))
+	row("修改时间", props.ModTime.Format("2006-01-02 15:04:05"))
+
+	sig := exe.Signature
+	status := signatureStatusText[sig.Status]
+	if sig.Detail != "" {
+		status += "：" + sig.Detail
+	}
+	class := "bad"
+	if sig.Status == signatureSigned || sig.Status == signatureTrusted {
+		class = "good"
+	}
+	rows.WriteString(`<tr><th>数字签名</th><td class="` + class + `">` + html.EscapeString(status) + "</td></tr>\n")
+	row("签名者", sig.Signer)
+	row("颁发者", sig.Issuer)
+	if !sig.NotAfter.IsZero() {
+		row("证书有效期", sig.NotBefore.Format("2006-01-02")+" 至 "+sig.NotAfter.Format("2006-01-02"))
+	}
+	row("摘要算法", sig.DigestAlgorithm)
+
+	return `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + html.EscapeString(props.Name) + ` - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 12px; font-size: 14px; }
+        a { color: #4CAF50; }
+        table { background: white; border-radius: 8px; border-collapse: collapse; font-size: 14px; min-width: 480px; }
+        th, td { border-bottom: 1px solid #eee; padding: 8px 14px; text-align: left; vertical-align: top; word-break: break-all; }
+        th { color: #666; font-weight: normal; white-space: nowrap; }
+        .good { color: #2e7d32; }
+        .bad { color: #c62828; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>` + html.EscapeString(props.Name) + `</strong>
+        <a href="/file/` + url.PathEscape(props.Path) + `">下载</a>
+    </div>
+    <table>
+` + rows.String() + `    </table>
+</body>
+</html>`
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func utf16Z(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s + "\x00")) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// 版本资源中的一个块，text为true时值是字符串
+func testVersionBlock(key string, value []byte, text bool, children ...[]byte) []byte {
+	b := append(make([]byte, 6), utf16Z(key)...)
+	b = append(b, make([]byte, align4(len(b))-len(b))...)
+	b = append(b, value...)
+	for _, c := range children {
+		b = append(b, make([]byte, align4(len(b))-len(b))...)
+		b = append(b, c...)
+	}
+	valueLen := len(value)
+	if text {
+		valueLen /= 2
+		binary.LittleEndian.PutUint16(b[4:], 1)
+	}
+	binary.LittleEndian.PutUint16(b, uint16(len(b)))
+	binary.LittleEndian.PutUint16(b[2:], uint16(valueLen))
+	return b
+}
+
+// 只有.rsrc节的64位PE文件，版本资源的字符串表为中文
+func buildTestPE(strs map[string]string) []byte {
+	le := binary.LittleEndian
+	fixed := make([]byte, 52)
+	le.PutUint32(fixed, 0xFEEF04BD)
+	le.PutUint32(fixed[8:], 1<<16|2)
+	le.PutUint32(fixed[12:], 3<<16|4)
+	le.PutUint32(fixed[16:], 1<<16|2)
+	var entries [][]byte
+	for k, v := range strs {
+		entries = append(entries, testVersionBlock(k, utf16Z(v), true))
+	}
+	table := testVersionBlock("080404b0", nil, true, entries...)
+	ver := testVersionBlock("VS_VERSION_INFO", fixed, false, testVersionBlock("StringFileInfo", nil, true, table))
+
+	// 资源目录：类型16 → 名称1 → 语言0x804 → 数据项
+	rsrc := make([]byte, 88)
+	dir := func(off, id int, target uint32) {
+		le.PutUint16(rsrc[off+14:], 1)
+		le.PutUint32(rsrc[off+16:], uint32(id))
+		le.PutUint32(rsrc[off+20:], target)
+	}
+	dir(0, 16, 0x80000000|24)
+	dir(24, 1, 0x80000000|48)
+	dir(48, 0x804, 72)
+	le.PutUint32(rsrc[72:], 0x1000+88)
+	le.PutUint32(rsrc[76:], uint32(len(ver)))
+	rsrc = append(rsrc, ver...)
+	rawSize := (len(rsrc) + 0x1FF) &^ 0x1FF
+
+	file := make([]byte, 0x400+rawSize)
+	file[0], file[1] = 'M', 'Z'
+	le.PutUint32(file[0x3C:], 0x80)
+	copy(file[0x80:], "PE\x00\x00")
+	le.PutUint16(file[0x84:], 0x8664)
+	le.PutUint16(file[0x86:], 1)
+	le.PutUint32(file[0x88:], uint32(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Unix()))
+	le.PutUint16(file[0x94:], 240)
+	le.PutUint16(file[0x96:], 0x22)
+	opt := file[0x98:]
+	le.PutUint16(opt, 0x20B)
+	le.PutUint64(opt[24:], 0x140000000)
+	le.PutUint32(opt[32:], 0x1000)
+	le.PutUint32(opt[36:], 0x200)
+	le.PutUint32(opt[56:], 0x2000)
+	le.PutUint32(opt[60:], 0x400)
+	le.PutUint16(opt[68:], 2)
+	le.PutUint32(opt[108:], 16)
+	le.PutUint32(opt[112+2*8:], 0x1000)
+	le.PutUint32(opt[112+2*8+4:], uint32(len(rsrc)))
+	section := file[0x98+240:]
+	copy(section, ".rsrc")
+	le.PutUint32(section[8:], uint32(len(rsrc)))
+	le.PutUint32(section[12:], 0x1000)
+	le.PutUint32(section[16:], uint32(rawSize))
+	le.PutUint32(section[20:], 0x400)
+	le.PutUint32(section[36:], 0x40000040)
+	copy(file[0x400:], rsrc)
+	return file
+}
+
+var (
+	testOIDSHA256      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	testOIDSpcIndirect = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+)
+
+func testExplicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func testSet(der []byte) asn1.RawValue {
+	return asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: der}
+}
+
+// 自签名的代码签名证书
+func testSigningCert(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1234),
+		Subject:      pkix.Name{CommonName: "测试发布者"},
+		NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2034, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return key, cert
+}
+
+// Authenticode签名，digest为文件内容的SHA-256摘要
+func testAuthenticode(t *testing.T, key *ecdsa.PrivateKey, cert *x509.Certificate, digest []byte) []byte {
+	var indirect spcIndirectData
+	indirect.Data.FullBytes, _ = asn1.Marshal(struct{ Type asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 15}})
+	indirect.MessageDigest.Algorithm.Algorithm = testOIDSHA256
+	indirect.MessageDigest.Digest = digest
+	content, err := asn1.Marshal(indirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seq asn1.RawValue
+	asn1.Unmarshal(content, &seq)
+	contentDigest := sha256.Sum256(seq.Bytes)
+
+	contentType, _ := asn1.Marshal(testOIDSpcIndirect)
+	messageDigest, _ := asn1.Marshal(contentDigest[:])
+	attrs, _ := asn1.MarshalWithParams([]pkcs7Attribute{
+		{Type: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}, Values: testSet(contentType)},
+		{Type: oidMessageDigest, Values: testSet(messageDigest)},
+	}, "set")
+	attrsDigest := sha256.Sum256(attrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, attrsDigest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attrSet asn1.RawValue
+	asn1.Unmarshal(attrs, &attrSet)
+
+	si := pkcs7SignerInfo{Version: 1, AuthAttributes: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrSet.Bytes}, EncryptedDigest: signature}
+	si.IssuerAndSerial.Issuer.FullBytes = cert.RawIssuer
+	si.IssuerAndSerial.Serial = cert.SerialNumber
+	si.DigestAlgorithm.Algorithm = testOIDSHA256
+	si.DigestEncryptionAlgorithm.Algorithm = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	algs, _ := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: testOIDSHA256})
+	sd, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: testSet(algs),
+		ContentInfo:      pkcs7ContentInfo{ContentType: testOIDSpcIndirect, Content: testExplicit(content)},
+		Certificates:     testExplicit(cert.Raw),
+		SignerInfos:      []pkcs7SignerInfo{si},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(pkcs7ContentInfo{ContentType: oidSignedData, Content: testExplicit(sd)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// 在PE文件末尾加上证书表
+func signTestPE(t *testing.T, file []byte, key *ecdsa.PrivateKey, cert *x509.Certificate) []byte {
+	const opt, securityEntry = 0x98, 0x98 + 112 + 4*8
+	h := sha256.New()
+	h.Write(file[:opt+64])
+	h.Write(file[opt+68 : securityEntry])
+	h.Write(file[securityEntry+8:])
+	der := testAuthenticode(t, key, cert, h.Sum(nil))
+
+	cert8 := make([]byte, (8+len(der)+7)&^7)
+	binary.LittleEndian.PutUint32(cert8, uint32(8+len(der)))
+	binary.LittleEndian.PutUint16(cert8[4:], 0x200)
+	binary.LittleEndian.PutUint16(cert8[6:], 2)
+	copy(cert8[8:], der)
+	binary.LittleEndian.PutUint32(file[securityEntry:], uint32(len(file)))
+	binary.LittleEndian.PutUint32(file[securityEntry+4:], uint32(len(cert8)))
+	return append(file, cert8...)
+}
+
+// MSI流名的编码（msiDecodeName的逆过程）
+func msiEncodeName(name string, table bool) string {
+	const charset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz._"
+	var out []rune
+	if table {
+		out = append(out, 0x4840)
+	}
+	for i := 0; i < len(name); i += 2 {
+		c := rune(strings.IndexByte(charset, name[i]))
+		if i+1 < len(name) {
+			out = append(out, 0x3800+c+rune(strings.IndexByte(charset, name[i+1]))<<6)
+		} else {
+			out = append(out, 0x4800+c)
+		}
+	}
+	return string(out)
+}
+
+// 版本3的复合文档（512字节扇区）：扇区0是FAT，1是迷你FAT，2~3是目录，然后是迷你流和大的流
+func buildTestCFB(streams map[string][]byte) []byte {
+	le := binary.LittleEndian
+	names := make([]string, 0, len(streams))
+	for name := range streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fat := make([]uint32, 128)
+	miniFat := make([]uint32, 128)
+	for i := range fat {
+		fat[i], miniFat[i] = 0xFFFFFFFF, 0xFFFFFFFF
+	}
+	fat[0], fat[1], fat[2], fat[3] = 0xFFFFFFFD, cfbEndOfChain, 3, cfbEndOfChain
+	var sectors [][]byte
+	chain := func(table []uint32, first int, count int) {
+		for i := 0; i < count; i++ {
+			table[first+i] = uint32(first + i + 1)
+		}
+		table[first+count-1] = cfbEndOfChain
+	}
+
+	var mini []byte
+	starts := make([]uint32, len(names))
+	for i, name := range names {
+		data := streams[name]
+		if len(data) >= 4096 {
+			continue
+		}
+		starts[i] = uint32(len(mini) / 64)
+		chain(miniFat, int(starts[i]), (len(data)+63)/64)
+		mini = append(mini, data...)
+		mini = append(mini, make([]byte, (64-len(data)%64)%64)...)
+	}
+	addSectors := func(data []byte) uint32 {
+		first := 4 + len(sectors)
+		count := (len(data) + 511) / 512
+		chain(fat, first, count)
+		for i := 0; i < count; i++ {
+			sec := make([]byte, 512)
+			copy(sec, data[i*512:])
+			sectors = append(sectors, sec)
+		}
+		return uint32(first)
+	}
+	miniStart := addSectors(mini)
+	for i, name := range names {
+		if len(streams[name]) >= 4096 {
+			starts[i] = addSectors(streams[name])
+		}
+	}
+
+	dir := make([]byte, 1024)
+	entry := func(i int, name string, typ byte, start uint32, size int, right, child uint32) {
+		e := dir[i*128:]
+		copy(e, utf16Z(name))
+		le.PutUint16(e[0x40:], uint16(len(utf16Z(name))))
+		e[0x42] = typ
+		le.PutUint32(e[0x44:], 0xFFFFFFFF)
+		le.PutUint32(e[0x48:], right)
+		le.PutUint32(e[0x4C:], child)
+		le.PutUint32(e[0x74:], start)
+		le.PutUint64(e[0x78:], uint64(size))
+	}
+	entry(0, "Root Entry", 5, miniStart, len(mini), 0xFFFFFFFF, 1)
+	for i, name := range names {
+		right := uint32(i + 2)
+		if i == len(names)-1 {
+			right = 0xFFFFFFFF
+		}
+		entry(i+1, name, 2, starts[i], len(streams[name]), right, 0xFFFFFFFF)
+	}
+
+	header := make([]byte, 512)
+	copy(header, cfbSignature)
+	le.PutUint16(header[0x18:], 0x3E)
+	le.PutUint16(header[0x1A:], 3)
+	le.PutUint16(header[0x1C:], 0xFFFE)
+	le.PutUint16(header[0x1E:], 9)
+	le.PutUint16(header[0x20:], 6)
+	le.PutUint32(header[0x2C:], 1)
+	le.PutUint32(header[0x30:], 2)
+	le.PutUint32(header[0x38:], 4096)
+	le.PutUint32(header[0x3C:], 1)
+	le.PutUint32(header[0x40:], 1)
+	le.PutUint32(header[0x44:], cfbEndOfChain)
+	for i := 0; i < 109; i++ {
+		le.PutUint32(header[0x4C+i*4:], 0xFFFFFFFF)
+	}
+	le.PutUint32(header[0x4C:], 0)
+
+	out := append(header, make([]byte, 4*512)...)
+	for i := range fat {
+		le.PutUint32(out[512+i*4:], fat[i])
+		le.PutUint32(out[1024+i*4:], miniFat[i])
+	}
+	copy(out[1536:], dir)
+	for _, sec := range sectors {
+		out = append(out, sec...)
+	}
+	return out
+}
+
+const cfbEndOfChain = 0xFFFFFFFE
+
+// 摘要信息属性集，codepage为65001，其他属性都是字符串
+func testSummaryInformation(props map[uint32]string) []byte {
+	le := binary.LittleEndian
+	ids := []uint32{1}
+	for id := range props {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var values []byte
+	offsets := make([]uint32, len(ids))
+	for i, id := range ids {
+		offsets[i] = uint32(8 + len(ids)*8 + len(values))
+		if id == 1 {
+			values = le.AppendUint32(values, 2)
+			values = le.AppendUint32(values, 65001)
+			continue
+		}
+		s := props[id] + "\x00"
+		values = le.AppendUint32(values, 30)
+		values = le.AppendUint32(values, uint32(len(s)))
+		values = append(values, s...)
+		values = append(values, make([]byte, align4(len(values))-len(values))...)
+	}
+	section := le.AppendUint32(nil, uint32(8+len(ids)*8+len(values)))
+	section = le.AppendUint32(section, uint32(len(ids)))
+	for i, id := range ids {
+		section = le.AppendUint32(section, id)
+		section = le.AppendUint32(section, offsets[i])
+	}
+	section = append(section, values...)
+	header := make([]byte, 48)
+	le.PutUint16(header, 0xFFFE)
+	le.PutUint32(header[24:], 1)
+	le.PutUint32(header[44:], 48)
+	return append(header, section...)
+}
+
+func buildTestMSI(signature []byte) []byte {
+	le := binary.LittleEndian
+	// 字符串3是空项，ID照样计数
+	strs := []string{"ProductName", "测试软件", "", "Manufacturer", "测试公司", "ProductVersion", "2.5.0"}
+	pool := le.AppendUint32(nil, 65001)
+	var data []byte
+	for _, s := range strs {
+		refs := uint16(1)
+		if s == "" {
+			refs = 0
+		}
+		pool = le.AppendUint16(pool, uint16(len(s)))
+		pool = le.AppendUint16(pool, refs)
+		data = append(data, s...)
+	}
+	var property []byte
+	for _, id := range []uint16{1, 4, 6, 2, 5, 7} {
+		property = le.AppendUint16(property, id)
+	}
+	streams := map[string][]byte{
+		msiEncodeName("_StringPool", true):  pool,
+		msiEncodeName("_StringData", true):  data,
+		msiEncodeName("Property", true):     property,
+		"\x05SummaryInformation":            testSummaryInformation(map[uint32]string{4: "旧作者", 7: "x64;2052", 9: "{11111111-2222-3333-4444-555555555555}"}),
+		msiEncodeName("Binary.logo", false): make([]byte, 5000), // 超过4096字节，不在迷你流中
+	}
+	if signature != nil {
+		streams["\x05DigitalSignature"] = signature
+	}
+	return buildTestCFB(streams)
+}
+
+func TestExecutableInfo(t *testing.T) {
+	dir := t.TempDir()
+	key, cert := testSigningCert(t)
+	pe := buildTestPE(map[string]string{"CompanyName": "示例公司", "ProductName": "示例安装程序", "FileVersion": "1.2.3.4 (release)", "FileDescription": "安装程序"})
+	signed := signTestPE(t, buildTestPE(map[string]string{"CompanyName": "示例公司"}), key, cert)
+	tampered := append([]byte(nil), signed...)
+	tampered[0x400+100] ^= 0xFF
+	files := map[string][]byte{
+		"setup.exe":    pe,
+		"signed.exe":   signed,
+		"tampered.exe": tampered,
+		"setup.msi":    buildTestMSI(nil),
+		"signed.msi":   buildTestMSI(testAuthenticode(t, key, cert, make([]byte, 32))),
+	}
+	for name, data := range files {
+		os.WriteFile(filepath.Join(dir, name), data, 0644)
+	}
+
+	info, err := readExecutableInfo(context.Background(), filepath.Join(dir, "setup.exe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != "PE" || info.Architecture != "x64" || info.Subsystem != "gui" || info.CompanyName != "示例公司" || info.ProductName != "示例安装程序" || info.FileDescription != "安装程序" {
+		t.Errorf("pe = %+v", info)
+	}
+	// 使用数字版本，不是字符串版本
+	if info.FileVersion != "1.2.3.4" || info.ProductVersion != "1.2.0.0" || info.LinkTime == nil || info.LinkTime.Year() != 2024 {
+		t.Errorf("fileVersion = %s, productVersion = %s, linkTime = %v", info.FileVersion, info.ProductVersion, info.LinkTime)
+	}
+	if info.Signature.Status != signatureUnsigned {
+		t.Errorf("unsigned status = %s", info.Signature.Status)
+	}
+
+	info, err = readExecutableInfo(context.Background(), filepath.Join(dir, "signed.exe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Windows上自签名证书不受信任
+	sig := info.Signature
+	if sig.Status != signatureSigned && sig.Status != signatureUntrusted || !sig.DigestChecked || sig.Signer != "测试发布者" || sig.DigestAlgorithm != "SHA-256" || sig.NotAfter.Year() != 2034 {
+		t.Errorf("signed = %+v", sig)
+	}
+	if info.CompanyName != "示例公司" {
+		t.Errorf("signed companyName = %q", info.CompanyName)
+	}
+	info, _ = readExecutableInfo(context.Background(), filepath.Join(dir, "tampered.exe"))
+	if info == nil || info.Signature.Status != signatureInvalid {
+		t.Errorf("tampered = %+v", info)
+	}
+
+	info, err = readExecutableInfo(context.Background(), filepath.Join(dir, "setup.msi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Property表优先于摘要信息
+	if info.Type != "MSI" || info.Architecture != "x64" || info.ProductName != "测试软件" || info.CompanyName != "测试公司" || info.ProductVersion != "2.5.0" || info.Languages != "2052" || info.PackageCode != "{11111111-2222-3333-4444-555555555555}" || info.Signature.Status != signatureUnsigned {
+		t.Errorf("msi = %+v", info)
+	}
+	info, err = readExecutableInfo(context.Background(), filepath.Join(dir, "signed.msi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig := info.Signature; sig.Status == signatureUnsigned || sig.Status == signatureInvalid || sig.DigestChecked || sig.Signer != "测试发布者" {
+		t.Errorf("signed msi = %+v", sig)
+	}
+}
+
+func TestPropertiesHandler(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "setup.exe")
+	os.WriteFile(exe, buildTestPE(map[string]string{"ProductName": "示例安装程序"}), 0644)
+	files := createTestFiles(t, dir, "broken.exe", "notes.txt")
+
+	rec := serveTestRequest(apiPropertiesHandler, httptest.NewRequest(http.MethodGet, "/api/properties?path="+url.QueryEscape(exe), nil))
+	var props FileProperties
+	decodeTestJSON(t, rec, &props)
+	if props.Name != "setup.exe" || props.Executable == nil || props.Executable.ProductName != "示例安装程序" {
+		t.Errorf("props = %+v", props)
+	}
+
+	// 扩展名是exe但不是PE文件时返回错误原因，不是可执行文件时没有executable
+	for i, wantError := range []bool{true, false} {
+		rec = serveTestRequest(apiPropertiesHandler, httptest.NewRequest(http.MethodGet, "/api/properties?path="+url.QueryEscape(files[i]), nil))
+		props = FileProperties{}
+		decodeTestJSON(t, rec, &props)
+		if props.Size != 10 || props.Executable != nil || (props.Error != "") != wantError {
+			t.Errorf("%s: props = %+v", files[i], props)
+		}
+	}
+
+	rec = serveTestRequest(apiPropertiesHandler, httptest.NewRequest(http.MethodGet, "/api/properties?path="+url.QueryEscape(filepath.Join(dir, "missing.exe")), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing: status = %d, want 404", rec.Code)
+	}
+	rec = serveTestRequest(previewHandler, httptest.NewRequest(http.MethodGet, "/preview/"+url.PathEscape(exe), nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "示例安装程序") || !strings.Contains(rec.Body.String(), "未签名") {
+		t.Errorf("preview: status %d", rec.Code)
+	}
+}
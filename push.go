@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 手机推送（ntfy、Gotify）
+//
+// 在config.json中配置ntfy或gotify后，通知渠道可以使用 {"type":"ntfy"}（可用topic指定主题）
+// 和 {"type":"gotify"}，不需要SMTP服务器。
+
+const defaultNtfyServer = "https://ntfy.sh"
+
+type NtfyConfig struct {
+	Server   string `json:"server"` // 默认https://ntfy.sh，可使用自建服务器
+	Topic    string `json:"topic"`
+	Token    string `json:"token"`    // 访问令牌，受保护的主题需要
+	Priority int    `json:"priority"` // 1-5，默认3
+}
+
+type GotifyConfig struct {
+	URL      string `json:"url"`      // 如 http://192.168.1.20:8080
+	Token    string `json:"token"`    // 应用令牌
+	Priority int    `json:"priority"` // 默认5
+}
+
+// 通过ntfy发布，使用JSON格式以支持中文标题
+func notifyNtfy(ctx context.Context, ch NotifyChannel, n Notification) error {
+	cfg := serverConfig.Ntfy
+	topic := ch.Topic
+	if topic == "" {
+		topic = cfg.Topic
+	}
+	if topic == "" {
+		return fmt.Errorf("没有指定ntfy主题")
+	}
+	server := cfg.Server
+	if server == "" {
+		server = defaultNtfyServer
+	}
+	priority := cfg.Priority
+	if priority == 0 {
+		priority = 3
+	}
+
+	header := http.Header{}
+	if cfg.Token != "" {
+		header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	return postNotificationJSON(ctx, strings.TrimRight(server, "/"), map[string]interface{}{
+		"topic":    topic,
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": priority,
+		"tags":     []string{n.Source},
+	}, header)
+}
+
+func notifyGotify(ctx context.Context, ch NotifyChannel, n Notification) error {
+	cfg := serverConfig.Gotify
+	if cfg.URL == "" || cfg.Token == "" {
+		return fmt.Errorf("未配置Gotify")
+	}
+	priority := cfg.Priority
+	if priority == 0 {
+		priority = 5
+	}
+
+	header := http.Header{}
+	header.Set("X-Gotify-Key", cfg.Token)
+	return postNotificationJSON(ctx, strings.TrimRight(cfg.URL, "/")+"/message", map[string]interface{}{
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": priority,
+	}, header)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"everything-web-server/pkg/everything"
+)
+
+// Everything排序和结果数上限
+//
+// 搜索API的sort为name、path、size、date、ext时由Everything排序（order=desc为降序），
+// max限制Everything返回的结果数。这些选项通过context传给搜索后端：SDK（Everything_SetSort、
+// Everything_SetMax）、es.exe（-sort、-n）和远程Everything（sort、ascending）按相同的方式处理，
+// 不支持排序的后端（Everything 1.5实例、locate等）取回结果后按名称、路径或扩展名排序，
+// 按大小和日期排序时保持原有顺序。分页在缓存的结果上进行，max为所有页的结果总数上限。
+
+type everythingQueryOptions struct {
+	Sort       string // name, path, size, date, ext，为空时使用Everything的默认顺序
+	Descending bool
+	Max        int // 0表示不限制
+}
+
+type queryOptionsKey struct{}
+
+// 由Everything排序的sort参数
+var everythingSorts = map[string]bool{"name": true, "path": true, "size": true, "date": true, "ext": true}
+
+// 解析搜索API的sort、order和max参数中由Everything处理的部分
+func parseEverythingQueryOptions(r *http.Request) everythingQueryOptions {
+	var opts everythingQueryOptions
+	if sortBy := r.URL.Query().Get("sort"); everythingSorts[sortBy] {
+		opts.Sort = sortBy
+		opts.Descending = r.URL.Query().Get("order") == "desc"
+	}
+	if max, err := strconv.Atoi(r.URL.Query().Get("max")); err == nil && max > 0 {
+		opts.Max = max
+	}
+	return opts
+}
+
+func withQueryOptions(ctx context.Context, opts everythingQueryOptions) context.Context {
+	if opts == (everythingQueryOptions{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, queryOptionsKey{}, opts)
+}
+
+func queryOptionsFrom(ctx context.Context) everythingQueryOptions {
+	opts, _ := ctx.Value(queryOptionsKey{}).(everythingQueryOptions)
+	return opts
+}
+
+// 搜索缓存的键，不同的排序和上限分别缓存
+func searchCacheKey(ctx context.Context, query string) string {
+	opts := queryOptionsFrom(ctx)
+	if opts == (everythingQueryOptions{}) {
+		return query
+	}
+	return query + "\x00" + opts.Sort + "," + strconv.FormatBool(opts.Descending) + "," + strconv.Itoa(opts.Max)
+}
+
+// Everything SDK的排序方式
+func (o everythingQueryOptions) sdkSort() everything.Sort {
+	ascending := map[string]everything.Sort{
+		"name": everything.SortNameAscending,
+		"path": everything.SortPathAscending,
+		"size": everything.SortSizeAscending,
+		"date": everything.SortDateModifiedAscending,
+		"ext":  everything.SortExtensionAscending,
+	}
+	s, ok := ascending[o.Sort]
+	if !ok {
+		return 0
+	}
+	if o.Descending {
+		s++ // 降序总是紧跟在升序之后
+	}
+	return s
+}
+
+// es.exe的排序和结果数参数
+func (o everythingQueryOptions) esExeArgs() []string {
+	var args []string
+	names := map[string]string{"name": "name", "path": "path", "size": "size", "date": "date-modified", "ext": "extension"}
+	if name, ok := names[o.Sort]; ok {
+		if o.Descending {
+			args = append(args, "-sort", name+"-descending")
+		} else {
+			args = append(args, "-sort", name+"-ascending")
+		}
+	}
+	if o.Max > 0 {
+		args = append(args, "-n", strconv.Itoa(o.Max))
+	}
+	return args
+}
+
+// 远程Everything（HTTP服务器）的排序参数，不支持的排序方式返回false
+func (o everythingQueryOptions) setRemoteParams(params url.Values) bool {
+	names := map[string]string{"name": "name", "path": "path", "size": "size", "date": "date_modified"}
+	if o.Sort == "" {
+		return true
+	}
+	name, ok := names[o.Sort]
+	if !ok {
+		return false
+	}
+	params.Set("sort", name)
+	if o.Descending {
+		params.Set("ascending", "0")
+	} else {
+		params.Set("ascending", "1")
+	}
+	return true
+}
+
+// 不支持排序和上限的后端：取回结果后排序并截断
+func applyQueryOptions(paths []string, opts everythingQueryOptions) []string {
+	var key func(string) string
+	switch opts.Sort {
+	case "name":
+		key = func(p string) string { return strings.ToLower(filepath.Base(p)) }
+	case "path":
+		key = strings.ToLower
+	case "ext":
+		key = func(p string) string { return strings.ToLower(filepath.Ext(p)) }
+	case "size", "date":
+		log.Printf("当前搜索后端不支持按%s排序，保持原有顺序", opts.Sort)
+	}
+	if key != nil {
+		sort.SliceStable(paths, func(i, j int) bool {
+			if opts.Descending {
+				return key(paths[i]) > key(paths[j])
+			}
+			return key(paths[i]) < key(paths[j])
+		})
+	}
+	if opts.Max > 0 && len(paths) > opts.Max {
+		paths = paths[:opts.Max]
+	}
+	return paths
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 文件的评分和评论
+type FileFeedback struct {
+	Path     string         `json:"path"`
+	Ratings  map[string]int `json:"ratings"` // 会话ID → 评分(1-5)
+	Comments []FileComment  `json:"comments"`
+}
+
+// 文件评论
+type FileComment struct {
+	ID      string `json:"id"`
+	Author  string `json:"author"`
+	Text    string `json:"text"`
+	Created string `json:"created"`
+	Session string `json:"session,omitempty"` // 发表评论的会话，只有同一会话可以删除
+	Own     bool   `json:"own,omitempty"`     // 仅在API响应中使用
+}
+
+// 评分和评论API的响应
+type FeedbackResponse struct {
+	Path        string        `json:"path"`
+	Rating      float64       `json:"rating"`
+	RatingCount int           `json:"ratingCount"`
+	MyRating    int           `json:"myRating"`
+	Comments    []FileComment `json:"comments"`
+}
+
+const (
+	feedbackFileName = "ratings.json"
+	maxCommentLength = 2000
+	maxAuthorLength  = 40
+)
+
+var (
+	feedback       = make(map[string]*FileFeedback) // 小写路径 → 评分和评论
+	feedbackMutex  sync.RWMutex
+	feedbackLoaded sync.Once
+)
+
+func ensureFeedbackLoaded() {
+	feedbackLoaded.Do(func() {
+		feedbackMutex.Lock()
+		defer feedbackMutex.Unlock()
+		if err := loadJSONFile(feedbackFileName, &feedback); err != nil {
+			log.Printf("加载评分和评论失败: %v", err)
+		}
+		if feedback == nil {
+			feedback = make(map[string]*FileFeedback)
+		}
+	})
+}
+
+// 获取文件的平均评分和评分人数
+func getRatingSummary(path string) (float64, int) {
+	ensureFeedbackLoaded()
+
+	feedbackMutex.RLock()
+	defer feedbackMutex.RUnlock()
+
+	entry, ok := feedback[tagKey(path)]
+	if !ok || len(entry.Ratings) == 0 {
+		return 0, 0
+	}
+	return averageRating(entry.Ratings), len(entry.Ratings)
+}
+
+func averageRating(ratings map[string]int) float64 {
+	if len(ratings) == 0 {
+		return 0
+	}
+	total := 0
+	for _, rating := range ratings {
+		total += rating
+	}
+	// 保留一位小数
+	return float64(total*10/len(ratings)) / 10
+}
+
+// 填充结果的评分信息
+func applyRating(result *SearchResult, path string) {
+	result.Rating, result.RatingCount = getRatingSummary(path)
+}
+
+// 生成会话可见的评分和评论（调用方需持有读锁）
+func buildFeedbackResponse(path, sessionID string) FeedbackResponse {
+	response := FeedbackResponse{
+		Path:     clientPath(path),
+		Comments: []FileComment{},
+	}
+
+	entry, ok := feedback[tagKey(path)]
+	if !ok {
+		return response
+	}
+
+	response.Rating = averageRating(entry.Ratings)
+	response.RatingCount = len(entry.Ratings)
+	response.MyRating = entry.Ratings[sessionID]
+	for _, comment := range entry.Comments {
+		comment.Own = comment.Session == sessionID
+		comment.Session = ""
+		response.Comments = append(response.Comments, comment)
+	}
+	return response
+}
+
+// 修改文件的评分和评论并保存
+func updateFeedback(path string, update func(*FileFeedback)) error {
+	ensureFeedbackLoaded()
+
+	feedbackMutex.Lock()
+	defer feedbackMutex.Unlock()
+
+	key := tagKey(path)
+	entry, ok := feedback[key]
+	if !ok {
+		entry = &FileFeedback{
+			Path:    clientPath(path),
+			Ratings: make(map[string]int),
+		}
+	}
+
+	update(entry)
+
+	if len(entry.Ratings) == 0 && len(entry.Comments) == 0 {
+		delete(feedback, key)
+	} else {
+		feedback[key] = entry
+	}
+	return saveJSONFile(feedbackFileName, feedback)
+}
+
+func writeFeedbackResponse(w http.ResponseWriter, path, sessionID string) {
+	feedbackMutex.RLock()
+	response := buildFeedbackResponse(path, sessionID)
+	feedbackMutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(response)
+}
+
+// 评分API处理器
+// GET ?path= 获取评分和评论；POST {"path","rating"} 设置当前会话的评分，rating为0时取消评分
+func apiRatingsHandler(w http.ResponseWriter, r *http.Request) {
+	ensureFeedbackLoaded()
+	sessionID := getSessionID(w, r)
+
+	switch r.Method {
+	case http.MethodGet:
+		path := resolveClientPath(r.URL.Query().Get("path"))
+		if path == "" {
+			http.Error(w, "路径参数不能为空", http.StatusBadRequest)
+			return
+		}
+		writeFeedbackResponse(w, path, sessionID)
+
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Path   string `json:"path"`
+			Rating int    `json:"rating"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+			http.Error(w, "请求格式错误，需要path参数", http.StatusBadRequest)
+			return
+		}
+		if req.Rating < 0 || req.Rating > 5 {
+			http.Error(w, "评分必须在1-5之间", http.StatusBadRequest)
+			return
+		}
+		path := resolveClientPath(req.Path)
+
+		err := updateFeedback(path, func(entry *FileFeedback) {
+			if req.Rating == 0 {
+				delete(entry.Ratings, sessionID)
+			} else {
+				entry.Ratings[sessionID] = req.Rating
+			}
+		})
+		if err != nil {
+			log.Printf("保存评分失败: %v", err)
+			http.Error(w, "保存评分失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("设置评分: %s, 评分: %d, 来源IP: %s", path, req.Rating, r.RemoteAddr)
+		writeFeedbackResponse(w, path, sessionID)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 评论API处理器
+// POST {"path","author","text"} 发表评论；DELETE ?path=&id= 删除自己的评论
+func apiCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	ensureFeedbackLoaded()
+	sessionID := getSessionID(w, r)
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Path   string `json:"path"`
+			Author string `json:"author"`
+			Text   string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+			http.Error(w, "请求格式错误，需要path参数", http.StatusBadRequest)
+			return
+		}
+		req.Text = strings.TrimSpace(req.Text)
+		if req.Text == "" {
+			http.Error(w, "评论内容不能为空", http.StatusBadRequest)
+			return
+		}
+		if len([]rune(req.Text)) > maxCommentLength {
+			http.Error(w, "评论内容过长", http.StatusBadRequest)
+			return
+		}
+		author := strings.TrimSpace(req.Author)
+		if author == "" {
+			author = "匿名"
+		}
+		if runes := []rune(author); len(runes) > maxAuthorLength {
+			author = string(runes[:maxAuthorLength])
+		}
+		path := resolveClientPath(req.Path)
+
+		err := updateFeedback(path, func(entry *FileFeedback) {
+			entry.Comments = append(entry.Comments, FileComment{
+				ID:      newRandomID(8),
+				Author:  author,
+				Text:    req.Text,
+				Created: time.Now().Format("2006-01-02 15:04:05"),
+				Session: sessionID,
+			})
+		})
+		if err != nil {
+			log.Printf("保存评论失败: %v", err)
+			http.Error(w, "保存评论失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("发表评论: %s, 作者: %s, 来源IP: %s", path, author, r.RemoteAddr)
+		writeFeedbackResponse(w, path, sessionID)
+
+	case http.MethodDelete:
+		path := resolveClientPath(r.URL.Query().Get("path"))
+		id := r.URL.Query().Get("id")
+		if path == "" || id == "" {
+			http.Error(w, "path和id参数不能为空", http.StatusBadRequest)
+			return
+		}
+
+		found := false
+		err := updateFeedback(path, func(entry *FileFeedback) {
+			kept := entry.Comments[:0]
+			for _, comment := range entry.Comments {
+				if comment.ID == id && comment.Session == sessionID {
+					found = true
+					continue
+				}
+				kept = append(kept, comment)
+			}
+			entry.Comments = kept
+		})
+		if err != nil {
+			http.Error(w, "保存评论失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "评论不存在或不是自己发表的", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("删除评论: %s, ID: %s, 来源IP: %s", path, id, r.RemoteAddr)
+		writeFeedbackResponse(w, path, sessionID)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
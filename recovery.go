@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// 处理器panic恢复
+//
+// 单个请求中的panic（如某个异常路径触发的越界）只让该请求返回500，不影响整个服务器：
+// 响应为JSON错误，日志记录请求信息和调用栈，/api/status 的panics中统计次数和最近一次的位置，
+// 并通过服务器告警渠道通知（每小时最多一次）。
+
+const panicAlertInterval = time.Hour
+
+type panicRecord struct {
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Error  string `json:"error"`
+	Source string `json:"source"`
+	Time   string `json:"time"`
+}
+
+var (
+	panicCount     int64
+	lastPanic      *panicRecord
+	lastPanicAlert time.Time
+	panicMutex     sync.Mutex
+)
+
+// 捕获处理器中的panic，返回500 JSON错误
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// 客户端断开时标准库用于中止响应的panic，不是错误
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			recordPanic(r, err, debug.Stack())
+
+			// 响应已经开始写出时状态码无法再修改，客户端只会收到不完整的内容
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Del("Content-Length")
+			w.Header().Del("Content-Encoding")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "服务器内部错误",
+				"path":  r.URL.Path,
+			})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func recordPanic(r *http.Request, err interface{}, stack []byte) {
+	record := &panicRecord{
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Error:  fmt.Sprint(err),
+		Source: r.RemoteAddr,
+		Time:   time.Now().Format("2006-01-02 15:04:05"),
+	}
+	log.Printf("处理请求时发生panic: %s %s?%s, 错误: %v，来源IP: %s\n%s", r.Method, r.URL.Path, r.URL.RawQuery, err, r.RemoteAddr, stack)
+
+	panicMutex.Lock()
+	panicCount++
+	lastPanic = record
+	alert := time.Since(lastPanicAlert) >= panicAlertInterval
+	if alert {
+		lastPanicAlert = time.Now()
+	}
+	panicMutex.Unlock()
+
+	if alert {
+		sendAlert("处理请求时发生panic: "+record.Path, fmt.Sprintf("%s?%s\n错误: %s\n来源IP: %s", record.Path, record.Query, record.Error, record.Source))
+	}
+}
+
+// panic统计，供状态API返回
+func panicStatus() map[string]interface{} {
+	panicMutex.Lock()
+	defer panicMutex.Unlock()
+	return map[string]interface{}{
+		"count": panicCount,
+		"last":  lastPanic,
+	}
+}
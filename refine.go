@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 在搜索结果中筛选
+//
+// GET /api/refine?q=原查询&filter=关键词&regex=1&in=name|path&page=&pageSize=&sort=
+// 在q的缓存结果上按关键词（不区分大小写）或正则表达式再次过滤，不重新查询Everything，
+// 也不把组合后的查询写入搜索缓存。缓存已过期时先执行一次原查询。
+
+type RefineResponse struct {
+	SearchResponse
+	Filter    string `json:"filter"`
+	Regex     bool   `json:"regex,omitempty"`
+	In        string `json:"in"`        // name或path
+	BaseCount int    `json:"baseCount"` // 筛选前的结果数
+}
+
+// 根据参数生成匹配函数
+func newRefineMatcher(filter string, regex bool) (func(string) bool, error) {
+	if regex {
+		re, err := regexp.Compile("(?i)" + filter)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		return re.MatchString, nil
+	}
+	lower := strings.ToLower(filter)
+	return func(s string) bool {
+		return strings.Contains(strings.ToLower(s), lower)
+	}, nil
+}
+
+// 筛选路径列表，matchPath为false时只匹配文件名
+func refinePaths(paths *pathList, match func(string) bool, matchPath bool) *pathList {
+	var matched []string
+	for i := 0; i < paths.Len(); i++ {
+		path := paths.At(i)
+		target := path
+		if !matchPath {
+			target = filepath.Base(path)
+		}
+		if match(target) {
+			matched = append(matched, path)
+		}
+	}
+	return newPathList(matched)
+}
+
+// 结果筛选API处理器
+func apiRefineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	filter := r.URL.Query().Get("filter")
+	if query == "" || filter == "" {
+		http.Error(w, "q和filter参数不能为空", http.StatusBadRequest)
+		return
+	}
+	regex := r.URL.Query().Get("regex") == "1" || r.URL.Query().Get("regex") == "true"
+	in := r.URL.Query().Get("in")
+	if in != "path" {
+		in = "name"
+	}
+	match, err := newRefineMatcher(filter, regex)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, pageSize := parsePageParams(r)
+	sortBy := parseSearchSort(r)
+
+	ctx := withQueryOptions(r.Context(), parseEverythingQueryOptions(r))
+	basePaths, fromCache, err := getSearchPaths(ctx, query, sortBy)
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消筛选: %s", query)
+		return
+	}
+	if err != nil {
+		log.Printf("搜索失败: %v", err)
+		http.Error(w, "搜索失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	start := time.Now()
+	paths := refinePaths(basePaths, match, in == "path")
+	groupBy := parseGroupBy(r)
+	if groupBy == "folder" {
+		paths = groupPathsByFolder(paths)
+	}
+	log.Printf("筛选搜索结果: query=%s, filter=%s, %d → %d个路径, 用时%v, 缓存: %v, 来源IP: %s",
+		query, filter, basePaths.Len(), paths.Len(), time.Since(start).Round(time.Millisecond), fromCache, r.RemoteAddr)
+
+	results, totalCount := pagePathResults(r.Context(), paths, page, pageSize)
+
+	response := &RefineResponse{
+		SearchResponse: SearchResponse{
+			Results:    results,
+			Count:      len(results),
+			TotalCount: totalCount,
+			Query:      query,
+			Page:       page,
+			PageSize:   pageSize,
+			TotalPages: (totalCount + pageSize - 1) / pageSize,
+			Sort:       sortBy,
+			GroupBy:    groupBy,
+		},
+		Filter:    filter,
+		Regex:     regex,
+		In:        in,
+		BaseCount: basePaths.Len(),
+	}
+
+	if groupBy == "folder" {
+		response.Groups = pageFolderGroups(paths, page, pageSize)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(response)
+}
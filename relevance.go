@@ -0,0 +1,149 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 按相关度排序
+//
+// 根据查询中的关键词给每个结果打分：文件名（不含扩展名）与关键词完全相同 > 文件名以关键词开头 >
+// 文件名包含全部关键词 > 只有路径包含关键词。同一档中修改时间较新的在前，再按文件夹层级浅的在前。
+// 修改时间需要读取文件信息，只对排在前面的结果读取，其余结果按层级排序。
+
+const (
+	relevanceStatLimit   = 2000            // 读取修改时间的结果数上限
+	relevanceStatTimeout = 3 * time.Second // 读取修改时间的总时间上限
+)
+
+const (
+	relevancePath      = 1 // 路径包含关键词
+	relevanceSubstring = 2 // 文件名包含全部关键词
+	relevancePrefix    = 3 // 文件名以关键词开头
+	relevanceExact     = 4 // 文件名与关键词相同
+)
+
+// 查询中用于打分的关键词（小写），忽略函数、修饰符和排除条件
+func relevanceTerms(query string) []string {
+	if rest, fuzzy := splitFuzzyQuery(query); fuzzy {
+		query = rest
+	} else if _, rest, py := splitPinyinQuery(query); py {
+		query = rest
+	}
+
+	var terms []string
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		if strings.HasPrefix(field, "!") || strings.Contains(field, ":") {
+			continue
+		}
+		field = strings.Trim(field, "\"<>|")
+		if field != "" {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+// 单个结果的相关度档次，不相关时返回0
+func relevanceScore(terms []string, path string) int {
+	lowerPath := strings.ToLower(path)
+	name := filepath.Base(lowerPath)
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	phrase := strings.Join(terms, " ")
+
+	switch {
+	case stem == phrase || name == phrase:
+		return relevanceExact
+	case strings.HasPrefix(name, phrase) || strings.HasPrefix(name, terms[0]):
+		return relevancePrefix
+	}
+	inName := true
+	for _, term := range terms {
+		if !strings.Contains(name, term) {
+			inName = false
+			break
+		}
+	}
+	if inName {
+		return relevanceSubstring
+	}
+	for _, term := range terms {
+		if strings.Contains(lowerPath, term) {
+			return relevancePath
+		}
+	}
+	return 0
+}
+
+// 文件夹层级
+func pathDepth(path string) int {
+	return strings.Count(strings.TrimRight(path, `\/`), string(filepath.Separator))
+}
+
+// 搜索结果按相关度排序，排序结果保存在搜索缓存（键为key）中，翻页时不重复排序
+// 查询中没有关键词时保持原有顺序
+func relevanceSortedPaths(key, query string, paths *pathList) *pathList {
+	cacheMutex.RLock()
+	cache, ok := searchCache[key]
+	if ok && cache.Paths == paths && cache.Relevance != nil {
+		sorted := cache.Relevance
+		cacheMutex.RUnlock()
+		return sorted
+	}
+	cacheMutex.RUnlock()
+
+	terms := relevanceTerms(query)
+	if len(terms) == 0 {
+		return paths
+	}
+
+	start := time.Now()
+	type ranked struct {
+		path     string
+		score    int
+		depth    int
+		modified time.Time
+	}
+	list := make([]ranked, paths.Len())
+	for i := range list {
+		path := paths.At(i)
+		list[i] = ranked{path: path, score: relevanceScore(terms, path), depth: pathDepth(path)}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].score != list[j].score {
+			return list[i].score > list[j].score
+		}
+		return list[i].depth < list[j].depth
+	})
+
+	// 只读取前面结果的修改时间，未读取的结果时间为零，排在同档已读取的结果之后
+	deadline := time.Now().Add(relevanceStatTimeout)
+	for i := 0; i < len(list) && i < relevanceStatLimit && time.Now().Before(deadline); i++ {
+		if info, err := statPath(list[i].path); err == nil {
+			list[i].modified = info.ModTime()
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].score != list[j].score {
+			return list[i].score > list[j].score
+		}
+		return list[i].modified.After(list[j].modified)
+	})
+
+	sortedPaths := make([]string, len(list))
+	for i, r := range list {
+		sortedPaths[i] = r.path
+	}
+	sorted := newPathList(sortedPaths)
+	log.Printf("搜索结果按相关度排序: query=%s, 关键词=%v, %d个路径, 用时%v", query, terms, sorted.Len(), time.Since(start).Round(time.Millisecond))
+
+	cacheMutex.Lock()
+	if cache, ok := searchCache[key]; ok && cache.Paths == paths {
+		cache.Relevance = sorted
+	}
+	cacheMutex.Unlock()
+	return sorted
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 远程Everything HTTP服务器
+//
+// Everything自带可选的HTTP服务器（工具 → 选项 → HTTP服务器）。配置remoteEverything后，
+// 搜索、浏览和文件信息都通过该服务器获取，文件内容（下载、视频流）由本服务器转发，
+// 这样可以为另一台机器（如NAS虚拟机）上的Everything提供这个网页界面。
+//
+// 需要读取文件内容的功能（缩略图、文本预览、转码、批量操作等）只对本机文件有效。
+
+const (
+	remotePageSize       = 1000   // 每次请求的结果数
+	remoteEntryCacheSize = 200000 // 缓存的文件信息条数上限
+	remoteRequestTimeout = 30 * time.Second
+)
+
+type RemoteEverythingConfig struct {
+	// 服务器地址，如 http://192.168.1.10:8080
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Everything HTTP服务器的JSON结果，size和date_modified为字符串
+type remoteSearchResponse struct {
+	TotalResults int `json:"totalResults"`
+	Results      []struct {
+		Type         string `json:"type"`
+		Name         string `json:"name"`
+		Path         string `json:"path"`
+		Size         string `json:"size"`
+		DateModified string `json:"date_modified"`
+	} `json:"results"`
+}
+
+type remoteCachedEntry struct {
+	entry everythingEntry
+	at    time.Time
+}
+
+var (
+	remoteEntryCache = make(map[string]remoteCachedEntry) // 小写路径 → 最近一次查询到的文件信息
+	remoteEntryMutex sync.Mutex
+
+	// 转发文件内容不限制总时长，只限制连接和等待响应头的时间
+	remoteClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+			ResponseHeaderTimeout: remoteRequestTimeout,
+			MaxIdleConnsPerHost:   8,
+		},
+	}
+)
+
+// 是否使用远程Everything
+func remoteEverythingEnabled() bool {
+	return serverConfig.RemoteEverything.URL != ""
+}
+
+// 创建发往远程Everything的请求
+func newRemoteRequest(ctx context.Context, rawPath string, query url.Values) (*http.Request, error) {
+	base := strings.TrimRight(serverConfig.RemoteEverything.URL, "/")
+	target := base + rawPath
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if serverConfig.RemoteEverything.Username != "" {
+		req.SetBasicAuth(serverConfig.RemoteEverything.Username, serverConfig.RemoteEverything.Password)
+	}
+	return req, nil
+}
+
+// 请求一页搜索结果
+func fetchRemotePage(ctx context.Context, query string, offset int) (*remoteSearchResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteRequestTimeout)
+	defer cancel()
+
+	params := url.Values{
+		"search":               {query},
+		"json":                 {"1"},
+		"path_column":          {"1"},
+		"size_column":          {"1"},
+		"date_modified_column": {"1"},
+		"offset":               {strconv.Itoa(offset)},
+		"count":                {strconv.Itoa(remotePageSize)},
+	}
+	queryOptionsFrom(ctx).setRemoteParams(params)
+	req, err := newRemoteRequest(ctx, "/", params)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("连接远程Everything失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("远程Everything返回状态%d", resp.StatusCode)
+	}
+
+	var page remoteSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("解析远程Everything结果失败: %v", err)
+	}
+	return &page, nil
+}
+
+// 在远程Everything中搜索，返回带大小和修改时间的结果，并缓存这些信息供之后获取文件信息
+func queryRemoteEntries(ctx context.Context, query string) ([]everythingEntry, error) {
+	var entries []everythingEntry
+	for {
+		page, err := fetchRemotePage(ctx, query, len(entries))
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.Results {
+			entry := everythingEntry{
+				Path:  filepath.Join(r.Path, r.Name),
+				IsDir: r.Type == "folder",
+				Size:  -1,
+			}
+			if size, err := strconv.ParseInt(r.Size, 10, 64); err == nil {
+				entry.Size = size
+			} else if entry.IsDir {
+				entry.Size = 0
+			}
+			// 修改时间为FILETIME（100纳秒）
+			if ft, err := strconv.ParseInt(r.DateModified, 10, 64); err == nil && ft > 0 {
+				entry.Modified = filetimeToTime(ft)
+			}
+			entries = append(entries, entry)
+		}
+		if len(page.Results) == 0 || len(entries) >= page.TotalResults {
+			break
+		}
+		if max := queryOptionsFrom(ctx).Max; max > 0 && len(entries) >= max {
+			entries = entries[:max]
+			break
+		}
+	}
+
+	cacheRemoteEntries(entries)
+	return entries, nil
+}
+
+// 在远程Everything中搜索，只返回路径
+func searchWithRemoteEverything(ctx context.Context, query string) ([]string, error) {
+	log.Printf("使用远程Everything搜索: %s", query)
+	entries, err := queryRemoteEntries(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = entry.Path
+	}
+	log.Printf("远程Everything返回%d个结果", len(paths))
+	if opts := queryOptionsFrom(ctx); !opts.setRemoteParams(url.Values{}) {
+		// 远程Everything不支持的排序方式（扩展名）
+		paths = applyQueryOptions(paths, opts)
+	}
+	return paths, nil
+}
+
+func cacheRemoteEntries(entries []everythingEntry) {
+	remoteEntryMutex.Lock()
+	defer remoteEntryMutex.Unlock()
+	if len(remoteEntryCache)+len(entries) > remoteEntryCacheSize {
+		remoteEntryCache = make(map[string]remoteCachedEntry)
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		remoteEntryCache[strings.ToLower(entry.Path)] = remoteCachedEntry{entry: entry, at: now}
+	}
+}
+
+// 远程文件的信息，优先使用最近一次搜索的结果
+func statRemote(path string) (os.FileInfo, error) {
+	path = filepath.Clean(path)
+	if volume := filepath.VolumeName(path); volume != "" && len(strings.TrimRight(path, `\`)) == len(volume) {
+		return remoteFileInfo{everythingEntry{Path: path, IsDir: true}}, nil
+	}
+
+	remoteEntryMutex.Lock()
+	cached, ok := remoteEntryCache[strings.ToLower(path)]
+	remoteEntryMutex.Unlock()
+	if ok && time.Since(cached.at) < cacheExpiry {
+		return remoteFileInfo{cached.entry}, nil
+	}
+
+	entries, err := queryRemoteEntries(context.Background(), `wfn:"`+path+`"`)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: path, Err: err}
+	}
+	if len(entries) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+	return remoteFileInfo{entries[0]}, nil
+}
+
+// 远程文件夹的内容，按名称排序
+func readDirRemote(path string) ([]os.DirEntry, error) {
+	entries, err := queryRemoteEntries(context.Background(), `parent:"`+strings.TrimRight(filepath.Clean(path), `\`)+`"`)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: path, Err: err}
+	}
+	list := make([]os.DirEntry, len(entries))
+	for i, entry := range entries {
+		list[i] = fs.FileInfoToDirEntry(remoteFileInfo{entry})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// 转发远程文件内容，Range和条件请求头原样传递
+func proxyRemoteFile(w http.ResponseWriter, r *http.Request, path string) {
+	// Everything HTTP服务器的文件地址为 /C%3A/文件夹/文件
+	segments := strings.Split(strings.ReplaceAll(path, `\`, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	req, err := newRemoteRequest(r.Context(), "/"+strings.Join(segments, "/"), nil)
+	if err != nil {
+		http.Error(w, "无效的路径", http.StatusBadRequest)
+		return
+	}
+	for _, name := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if value := r.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		log.Printf("转发远程文件失败: %s, 错误: %v", path, err)
+		http.Error(w, "连接远程Everything失败", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, name := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"} {
+		if value := resp.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	}
+	w.WriteHeader(resp.StatusCode)
+	written, err := io.Copy(w, resp.Body)
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("转发远程文件中断: %s, 已传输%d字节, 错误: %v", path, written, err)
+	}
+}
+
+// 远程条目的os.FileInfo
+type remoteFileInfo struct {
+	entry everythingEntry
+}
+
+func (fi remoteFileInfo) Name() string       { return filepath.Base(fi.entry.Path) }
+func (fi remoteFileInfo) Size() int64        { return max(fi.entry.Size, 0) }
+func (fi remoteFileInfo) ModTime() time.Time { return fi.entry.Modified }
+func (fi remoteFileInfo) IsDir() bool        { return fi.entry.IsDir }
+
+func (fi remoteFileInfo) Mode() fs.FileMode {
+	if fi.entry.IsDir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (fi remoteFileInfo) Sys() any {
+	return nil
+}
+
+// 返回文件属性，避免getFileAttributes读取本机同名路径的属性
+func (fi remoteFileInfo) FileAttributes() uint32 {
+	if fi.entry.IsDir {
+		return fileAttributeDirectory
+	}
+	return fileAttributeArchive
+}
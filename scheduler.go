@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 定时维护任务调度
+//
+// 每个任务按cron表达式定时执行，同一任务上一次还没结束时跳过本次。
+// 默认计划见defaultSchedule，config.json中的schedule按任务名覆盖。
+
+type maintenanceTask struct {
+	Description string
+	Run         func(ctx context.Context) error
+}
+
+var maintenanceTasks = map[string]maintenanceTask{
+	"expire-search-cache": {"清理过期的搜索缓存", func(ctx context.Context) error { cleanExpiredCache(); return nil }},
+	"purge-caches":        {"缓存目录超过容量上限时删除最旧的文件", purgeCacheDirs},
+	"refresh-searches":    {"重新执行warmQueries中的搜索，刷新缓存", refreshWarmQueries},
+	"rotate-logs":         {"轮转日志文件", rotateLogs},
+	"recheck-tools":       {"重新检测ffmpeg和Everything是否可用", recheckTools},
+	"check-monitors":      {"检查到期的查询监视器，出现新文件时发送通知", checkDueMonitors},
+}
+
+var defaultSchedule = []ScheduleEntry{
+	{Task: "expire-search-cache", Cron: "@every 5m"},
+	{Task: "purge-caches", Cron: "30 3 * * *"},
+	{Task: "refresh-searches", Cron: "0 5 * * *"},
+	{Task: "rotate-logs", Cron: "0 0 * * *"},
+	{Task: "recheck-tools", Cron: "@every 10m"},
+	{Task: "check-monitors", Cron: "@every 1m"},
+}
+
+const maxTaskDuration = 30 * time.Minute // 单次任务的最长执行时间
+
+// 任务的运行状态
+type ScheduledTask struct {
+	Task         string `json:"task"`
+	Description  string `json:"description"`
+	Cron         string `json:"cron"`
+	Running      bool   `json:"running"`
+	NextRun      string `json:"nextRun,omitempty"`
+	LastRun      string `json:"lastRun,omitempty"`
+	LastDuration string `json:"lastDuration,omitempty"`
+	LastError    string `json:"lastError,omitempty"`
+	RunCount     int    `json:"runCount"`
+
+	schedule *cronSchedule
+	run      func(ctx context.Context) error
+	trigger  chan struct{}
+}
+
+var (
+	scheduledTasks = make(map[string]*ScheduledTask)
+	scheduleMutex  sync.Mutex
+)
+
+// 合并默认计划和配置，启动所有任务
+func startScheduler() {
+	entries := make(map[string]string)
+	for _, e := range defaultSchedule {
+		entries[e.Task] = e.Cron
+	}
+	for _, e := range serverConfig.Schedule {
+		if _, ok := maintenanceTasks[e.Task]; !ok {
+			log.Printf("未知的定时任务: %s", e.Task)
+			continue
+		}
+		entries[e.Task] = e.Cron
+	}
+
+	for name, spec := range entries {
+		if spec == "" || spec == "off" {
+			log.Printf("定时任务已停用: %s", name)
+			continue
+		}
+		schedule, err := parseCron(spec)
+		if err != nil {
+			log.Printf("定时任务%s的时间表达式无效，已停用: %v", name, err)
+			continue
+		}
+
+		task := &ScheduledTask{
+			Task:        name,
+			Description: maintenanceTasks[name].Description,
+			Cron:        spec,
+			schedule:    schedule,
+			run:         maintenanceTasks[name].Run,
+			trigger:     make(chan struct{}, 1),
+		}
+		scheduleMutex.Lock()
+		scheduledTasks[name] = task
+		scheduleMutex.Unlock()
+
+		go task.loop()
+		log.Printf("定时任务: %s (%s)", name, spec)
+	}
+}
+
+// 等待下一次执行时间或手动触发
+func (t *ScheduledTask) loop() {
+	for {
+		next := t.schedule.next(time.Now())
+		if next.IsZero() {
+			log.Printf("定时任务%s没有下一次执行时间", t.Task)
+			return
+		}
+
+		scheduleMutex.Lock()
+		t.NextRun = next.Format("2006-01-02 15:04:05")
+		scheduleMutex.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-t.trigger:
+			timer.Stop()
+		}
+		t.execute()
+	}
+}
+
+func (t *ScheduledTask) execute() {
+	scheduleMutex.Lock()
+	if t.Running {
+		scheduleMutex.Unlock()
+		log.Printf("定时任务%s仍在执行，跳过本次", t.Task)
+		return
+	}
+	t.Running = true
+	scheduleMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxTaskDuration)
+	defer cancel()
+
+	start := time.Now()
+	err := t.run(ctx)
+	elapsed := time.Since(start)
+
+	scheduleMutex.Lock()
+	t.Running = false
+	t.RunCount++
+	t.LastRun = start.Format("2006-01-02 15:04:05")
+	t.LastDuration = elapsed.Round(time.Millisecond).String()
+	t.LastError = ""
+	if err != nil {
+		t.LastError = err.Error()
+	}
+	scheduleMutex.Unlock()
+
+	if err != nil {
+		log.Printf("定时任务%s失败(%v): %v", t.Task, elapsed.Round(time.Millisecond), err)
+	} else if elapsed > time.Second {
+		log.Printf("定时任务%s完成，用时%v", t.Task, elapsed.Round(time.Millisecond))
+	}
+}
+
+// 定时任务API处理器
+// GET 列出任务和运行状态；POST {"task"} 立即执行一次
+func apiScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		scheduleMutex.Lock()
+		list := make([]ScheduledTask, 0, len(scheduledTasks))
+		for _, task := range scheduledTasks {
+			list = append(list, *task)
+		}
+		scheduleMutex.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Task < list[j].Task })
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tasks": list,
+			"count": len(list),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Task string `json:"task"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Task == "" {
+			http.Error(w, "请求格式错误，需要task参数", http.StatusBadRequest)
+			return
+		}
+
+		scheduleMutex.Lock()
+		task, ok := scheduledTasks[req.Task]
+		scheduleMutex.Unlock()
+		if !ok {
+			http.Error(w, "定时任务不存在或未启用: "+req.Task, http.StatusNotFound)
+			return
+		}
+
+		select {
+		case task.trigger <- struct{}{}:
+		default: // 已经有一次待执行的触发
+		}
+		log.Printf("手动执行定时任务: %s, 来源IP: %s", req.Task, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"task":    req.Task,
+		})
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// 脚本钩子
+//
+// 数据目录下scripts文件夹中的.js文件在启动时加载（goja解释器，ES5.1和大部分ES6），脚本可以注册：
+//   onQuery(function(query) { return 新的查询 })        改写搜索查询，返回undefined时不改变
+//   onResult(function(path) { return false })          返回false时从搜索结果中去掉该路径
+//   onDownload(function(path, ip) { return "原因" })    返回false或字符串时拒绝读取文件内容（见withDownloadVeto）
+//   schedule("@every 10m", function() { ... })        按cron表达式定时执行
+// 脚本中还可以使用log(...)写入服务器日志，search(查询, 数量)返回Everything的搜索结果路径。
+// 每个脚本有独立的解释器，同一时刻只执行一个调用；钩子执行超时时中断，出错时按没有钩子处理。
+// 定时任务在脚本的另一个解释器中运行，不阻塞钩子；顶层代码在两个解释器中各执行一次，两边的变量互不相通。
+// POST /api/scripts/reload 重新加载全部脚本并清除搜索缓存，GET /api/scripts 查看加载状态。
+
+const (
+	scriptsDirName     = "scripts"
+	scriptLoadTimeout  = 5 * time.Second
+	scriptHookTimeout  = 2 * time.Second
+	scriptBatchTimeout = 10 * time.Second // onResult处理一次搜索的全部路径
+	scriptTaskTimeout  = 5 * time.Minute
+	maxScriptSearch    = 10000
+)
+
+type scriptSchedule struct {
+	spec     string
+	schedule *cronSchedule
+	fn       goja.Callable
+}
+
+type userScript struct {
+	name string
+	mu   sync.Mutex // goja.Runtime不能并发使用
+	vm   *goja.Runtime
+
+	// 定时任务在单独的解释器中运行，长时间的任务不阻塞钩子
+	taskMu sync.Mutex
+	taskVM *goja.Runtime
+
+	onQuery    []goja.Callable
+	onResult   []goja.Callable
+	onDownload []goja.Callable
+	schedules  []scriptSchedule
+
+	loadError string
+	statsMu   sync.Mutex // 两个解释器都会更新调用统计
+	lastError string
+	lastRun   time.Time
+	calls     int
+}
+
+// 脚本的加载和运行状态
+type ScriptStatus struct {
+	Name       string   `json:"name"`
+	Loaded     bool     `json:"loaded"`
+	Error      string   `json:"error,omitempty"`
+	OnQuery    int      `json:"onQuery"`
+	OnResult   int      `json:"onResult"`
+	OnDownload int      `json:"onDownload"`
+	Schedules  []string `json:"schedules"`
+	Calls      int      `json:"calls"`
+	LastError  string   `json:"lastError,omitempty"`
+	LastRun    string   `json:"lastRun,omitempty"`
+}
+
+var (
+	loadedScripts []*userScript
+	scriptsMutex  sync.RWMutex
+	stopScripts   context.CancelFunc = func() {} // 停止上一次加载的定时脚本
+)
+
+func scriptsDir() string {
+	return filepath.Join(getDataDir(), scriptsDirName)
+}
+
+// 加载scripts文件夹中的全部脚本，替换已加载的脚本
+func loadScripts() []ScriptStatus {
+	entries, err := os.ReadDir(scriptsDir())
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("读取脚本文件夹失败: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var scripts []*userScript
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".js") {
+			continue
+		}
+		s := newUserScript(entry.Name())
+		if err := s.load(filepath.Join(scriptsDir(), entry.Name())); err != nil {
+			s.loadError = err.Error()
+			log.Printf("加载脚本失败: %s, 错误: %v", s.name, err)
+		} else {
+			log.Printf("已加载脚本: %s（onQuery %d个, onResult %d个, onDownload %d个, 定时%d个）",
+				s.name, len(s.onQuery), len(s.onResult), len(s.onDownload), len(s.schedules))
+			for _, sch := range s.schedules {
+				go s.runSchedule(ctx, sch)
+			}
+		}
+		scripts = append(scripts, s)
+	}
+
+	scriptsMutex.Lock()
+	stopScripts()
+	loadedScripts, stopScripts = scripts, cancel
+	scriptsMutex.Unlock()
+	return scriptStatuses()
+}
+
+func newUserScript(name string) *userScript {
+	s := &userScript{name: name, vm: goja.New(), taskVM: goja.New()}
+	s.setupRuntime(s.vm, true)
+	s.setupRuntime(s.taskVM, false)
+	return s
+}
+
+// 设置解释器的全局函数。钩子只注册到钩子解释器，定时任务只注册到任务解释器，
+// 两个解释器都检查参数，加载出错时一样报告
+func (s *userScript) setupRuntime(vm *goja.Runtime, hooks bool) {
+	register := func(list *[]goja.Callable) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			fn, ok := goja.AssertFunction(call.Argument(0))
+			if !ok {
+				panic(vm.NewTypeError("参数应为函数"))
+			}
+			if hooks {
+				*list = append(*list, fn)
+			}
+			return goja.Undefined()
+		}
+	}
+	vm.Set("onQuery", register(&s.onQuery))
+	vm.Set("onResult", register(&s.onResult))
+	vm.Set("onDownload", register(&s.onDownload))
+	vm.Set("schedule", func(call goja.FunctionCall) goja.Value {
+		spec := call.Argument(0).String()
+		schedule, err := parseCron(spec)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		fn, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			panic(vm.NewTypeError("schedule的第二个参数应为函数"))
+		}
+		if !hooks {
+			s.schedules = append(s.schedules, scriptSchedule{spec, schedule, fn})
+		}
+		return goja.Undefined()
+	})
+	vm.Set("log", func(call goja.FunctionCall) goja.Value {
+		parts := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			parts[i] = arg.String()
+		}
+		log.Printf("[脚本%s] %s", s.name, strings.Join(parts, " "))
+		return goja.Undefined()
+	})
+	vm.Set("search", func(call goja.FunctionCall) goja.Value {
+		limit := maxScriptSearch
+		if n := int(call.Argument(1).ToInteger()); n > 0 && n < limit {
+			limit = n
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		paths, err := runEverythingQuery(ctx, call.Argument(0).String())
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		paths = filterExcludedPaths(paths)
+		return vm.ToValue(paths[:min(len(paths), limit)])
+	})
+}
+
+// 在两个解释器中分别执行脚本文件的顶层代码，注册钩子和定时任务
+func (s *userScript) load(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	run := func(mu *sync.Mutex, vm *goja.Runtime) error {
+		mu.Lock()
+		defer mu.Unlock()
+		timer := time.AfterFunc(scriptLoadTimeout, func() { vm.Interrupt("加载超时") })
+		_, err := vm.RunScript(s.name, string(src))
+		timer.Stop()
+		vm.ClearInterrupt()
+		return err
+	}
+	if err := run(&s.mu, s.vm); err != nil {
+		return err
+	}
+	return run(&s.taskMu, s.taskVM)
+}
+
+// 调用脚本函数，调用方持有vm对应的锁；超时后中断
+func (s *userScript) call(vm *goja.Runtime, fn goja.Callable, timeout time.Duration, args ...interface{}) (goja.Value, error) {
+	values := make([]goja.Value, len(args))
+	for i, arg := range args {
+		values[i] = vm.ToValue(arg)
+	}
+	timer := time.AfterFunc(timeout, func() { vm.Interrupt(fmt.Sprintf("执行超过%v", timeout)) })
+	result, err := fn(goja.Undefined(), values...)
+	timer.Stop()
+	vm.ClearInterrupt()
+
+	s.statsMu.Lock()
+	s.calls++
+	s.lastRun = time.Now()
+	if err != nil {
+		s.lastError = err.Error()
+	}
+	s.statsMu.Unlock()
+	if err != nil {
+		log.Printf("脚本%s执行出错: %v", s.name, err)
+	}
+	return result, err
+}
+
+func (s *userScript) runSchedule(ctx context.Context, sch scriptSchedule) {
+	for {
+		next := sch.schedule.next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.runTask(sch)
+	}
+}
+
+// 在任务解释器中执行一次定时任务
+func (s *userScript) runTask(sch scriptSchedule) {
+	s.taskMu.Lock()
+	defer s.taskMu.Unlock()
+	s.call(s.taskVM, sch.fn, scriptTaskTimeout)
+}
+
+// 已加载的脚本，没有时返回nil
+func currentScripts() []*userScript {
+	scriptsMutex.RLock()
+	defer scriptsMutex.RUnlock()
+	return loadedScripts
+}
+
+// 依次经过各脚本的onQuery钩子
+func scriptRewriteQuery(query string) string {
+	for _, s := range currentScripts() {
+		s.mu.Lock()
+		for _, fn := range s.onQuery {
+			result, err := s.call(s.vm, fn, scriptHookTimeout, query)
+			if err == nil && result != nil && !goja.IsUndefined(result) && !goja.IsNull(result) {
+				if rewritten := result.String(); rewritten != query {
+					log.Printf("脚本%s改写查询: %s → %s", s.name, query, rewritten)
+					query = rewritten
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+	return query
+}
+
+// 去掉onResult钩子返回false的路径；钩子出错或超时时保留剩余的路径
+func scriptFilterPaths(paths []string) []string {
+	for _, s := range currentScripts() {
+		s.mu.Lock()
+		for _, fn := range s.onResult {
+			start := time.Now()
+			kept := paths[:0:0]
+			for i, path := range paths {
+				result, err := s.call(s.vm, fn, scriptHookTimeout, path)
+				if err != nil || time.Since(start) > scriptBatchTimeout {
+					kept = append(kept, paths[i:]...)
+					break
+				}
+				if keep, ok := result.Export().(bool); ok && !keep {
+					continue
+				}
+				kept = append(kept, path)
+			}
+			if removed := len(paths) - len(kept); removed > 0 {
+				log.Printf("脚本%s过滤了%d个路径", s.name, removed)
+			}
+			paths = kept
+		}
+		s.mu.Unlock()
+	}
+	return paths
+}
+
+// onDownload钩子拒绝下载时返回原因，允许时返回空
+func scriptVetoDownload(path, ip string) string {
+	for _, s := range currentScripts() {
+		s.mu.Lock()
+		for _, fn := range s.onDownload {
+			result, err := s.call(s.vm, fn, scriptHookTimeout, path, ip)
+			if err != nil || result == nil {
+				continue
+			}
+			switch v := result.Export().(type) {
+			case bool:
+				if !v {
+					s.mu.Unlock()
+					return "脚本" + s.name + "拒绝下载"
+				}
+			case string:
+				if v != "" {
+					s.mu.Unlock()
+					return v
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+	return ""
+}
+
+// 提供文件内容的路由，文件路径在前缀之后
+var downloadVetoPrefixes = []string{
+	"/file/", "/stream/", "/transcode/", "/thumbnail/", "/resize/", "/storyboard/",
+	"/textview/", "/preview/", "/svg/", "/subtitle/", "/disc/", "/model/",
+}
+
+// 提供文件内容的API，文件路径在path查询参数中
+var downloadVetoQueryRoutes = []string{"/api/text", "/api/clip", "/api/animation", "/api/sqlite", "/api/download-plan"}
+
+// 所有提供文件内容的路由都经过这里调用onDownload钩子，请求的任何一个文件被拒绝时返回403
+func withDownloadVeto(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(currentScripts()) > 0 {
+			for _, path := range downloadRequestPaths(r) {
+				if reason := scriptVetoDownload(path, r.RemoteAddr); reason != "" {
+					log.Printf("脚本拒绝下载: %s, 原因: %s, 来源IP: %s", path, reason, r.RemoteAddr)
+					http.Error(w, "下载被拒绝: "+reason, http.StatusForbidden)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 请求要读取内容的文件路径，无法解析时返回nil，由处理器返回相应的错误
+func downloadRequestPaths(r *http.Request) []string {
+	for _, prefix := range downloadVetoPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return []string{decodeRequestPath(r.URL.Path[len(prefix):])}
+		}
+	}
+	if slices.Contains(downloadVetoQueryRoutes, r.URL.Path) {
+		if path := r.URL.Query().Get("path"); path != "" {
+			return []string{resolveClientPath(path)}
+		}
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/download/"):
+		// ZIP分卷中的全部文件
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+		index, err := strconv.Atoi(parts[len(parts)-1])
+		downloadBundlesMutex.RLock()
+		defer downloadBundlesMutex.RUnlock()
+		bundle, ok := downloadBundles[parts[0]]
+		if len(parts) != 2 || !ok || err != nil || index < 1 || index > len(bundle.Parts) {
+			return nil
+		}
+		var paths []string
+		for _, entry := range bundle.Parts[index-1].entries {
+			if !entry.isDir {
+				paths = append(paths, entry.path)
+			}
+		}
+		return paths
+	case strings.HasPrefix(r.URL.Path, "/segment/"):
+		token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/segment/"), "/")
+		downloadPlansMutex.RLock()
+		defer downloadPlansMutex.RUnlock()
+		if plan, ok := downloadPlans[token]; ok {
+			return []string{plan.path}
+		}
+	case r.URL.Path == "/api/sync/pull" && r.Body != nil:
+		// 读出请求体后放回，处理器照常解析
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var req struct {
+			Path  string   `json:"path"`
+			Files []string `json:"files"`
+		}
+		if err != nil || json.Unmarshal(body, &req) != nil || len(req.Files) > maxSyncPullFiles {
+			return nil
+		}
+		root, err := syncRoot(req.Path)
+		if err != nil {
+			return nil
+		}
+		var paths []string
+		for _, rel := range req.Files {
+			if path, err := syncFilePath(root, rel); err == nil {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
+func scriptStatuses() []ScriptStatus {
+	list := []ScriptStatus{}
+	for _, s := range currentScripts() {
+		s.statsMu.Lock()
+		status := ScriptStatus{
+			Name:       s.name,
+			Loaded:     s.loadError == "",
+			Error:      s.loadError,
+			OnQuery:    len(s.onQuery),
+			OnResult:   len(s.onResult),
+			OnDownload: len(s.onDownload),
+			Schedules:  []string{},
+			Calls:      s.calls,
+			LastError:  s.lastError,
+		}
+		for _, sch := range s.schedules {
+			status.Schedules = append(status.Schedules, sch.spec)
+		}
+		if !s.lastRun.IsZero() {
+			status.LastRun = s.lastRun.Format("2006-01-02 15:04:05")
+		}
+		s.statsMu.Unlock()
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// 脚本API处理器：GET 加载状态
+func apiScriptsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	scripts := scriptStatuses()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dir":     scriptsDir(),
+		"scripts": scripts,
+		"count":   len(scripts),
+	})
+}
+
+// 重新加载脚本API处理器，钩子变化后已缓存的搜索结果不再适用，一并清除
+func apiScriptsReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	scripts := loadScripts()
+
+	cacheMutex.Lock()
+	searchCache = make(map[string]*SearchCache)
+	cacheMutex.Unlock()
+
+	log.Printf("重新加载了%d个脚本，来源IP: %s", len(scripts), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"scripts": scripts,
+		"count":   len(scripts),
+	})
+}
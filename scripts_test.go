@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadTestScript(t *testing.T, name, src string) *userScript {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newUserScript(name)
+	if err := s.load(path); err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return s
+}
+
+func TestScriptHooks(t *testing.T) {
+	s := loadTestScript(t, "rules.js", `
+		onQuery(function(q) { if (q.indexOf("movies:") === 0) return q.slice(7) + " ext:mkv;mp4"; });
+		onResult(function(p) { return p.toLowerCase().indexOf("private") < 0; });
+		onDownload(function(p, ip) { if (/\.key$/i.test(p)) return "不允许下载密钥文件"; });
+		schedule("@every 1h", function() {});
+	`)
+	saved := loadedScripts
+	loadedScripts = []*userScript{s}
+	defer func() { loadedScripts = saved }()
+
+	if got := scriptRewriteQuery("movies:dune"); got != "dune ext:mkv;mp4" {
+		t.Errorf("scriptRewriteQuery = %q", got)
+	}
+	if got := scriptRewriteQuery("dune"); got != "dune" {
+		t.Errorf("unchanged query = %q", got)
+	}
+
+	paths := scriptFilterPaths([]string{`C:\a.txt`, `C:\Private\b.txt`, `D:\c.txt`})
+	if !slices.Equal(paths, []string{`C:\a.txt`, `D:\c.txt`}) {
+		t.Errorf("scriptFilterPaths = %v", paths)
+	}
+
+	dir := t.TempDir()
+	files := createTestFiles(t, dir, "server.key", "readme.txt")
+	handler := withDownloadVeto(http.HandlerFunc(fileHandler)).ServeHTTP
+	rec := serveTestRequest(handler, httptest.NewRequest(http.MethodGet, "/file/"+url.PathEscape(files[0]), nil))
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "不允许下载密钥文件") {
+		t.Errorf("vetoed download: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	rec = serveTestRequest(handler, httptest.NewRequest(http.MethodGet, "/file/"+url.PathEscape(files[1]), nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("allowed download: status = %d", rec.Code)
+	}
+
+	statuses := scriptStatuses()
+	if len(statuses) != 1 || !statuses[0].Loaded || statuses[0].OnQuery != 1 || !slices.Equal(statuses[0].Schedules, []string{"@every 1h"}) {
+		t.Errorf("scriptStatuses = %+v", statuses)
+	}
+}
+
+func TestDownloadVeto(t *testing.T) {
+	s := loadTestScript(t, "veto.js", `onDownload(function(p, ip) { if (/\.key$/i.test(p)) return "不允许下载密钥文件"; });`)
+	saved := loadedScripts
+	loadedScripts = []*userScript{s}
+	defer func() { loadedScripts = saved }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/download/", downloadPartHandler)
+	mux.HandleFunc("/api/sync/pull", apiSyncPullHandler)
+	mux.HandleFunc("/api/text", textPreviewHandler)
+	handler := withDownloadVeto(mux).ServeHTTP
+
+	dir := t.TempDir()
+	files := createTestFiles(t, dir, "keys/server.key", "docs/readme.txt")
+	check := func(name string, req *http.Request, wantVeto bool) {
+		t.Helper()
+		rec := serveTestRequest(handler, req)
+		vetoed := rec.Code == http.StatusForbidden && strings.Contains(rec.Body.String(), "不允许下载密钥文件")
+		if vetoed != wantVeto || (!wantVeto && rec.Code != http.StatusOK) {
+			t.Errorf("%s: status %d, body %q", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 打包下载：文件夹中的文件在分卷下载时检查
+	for _, tc := range []struct {
+		path     string
+		wantVeto bool
+	}{{filepath.Dir(files[0]), true}, {filepath.Dir(files[1]), false}} {
+		bundle, err := createDownloadBundle("veto-test", "", []string{tc.path}, 1<<30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			downloadBundlesMutex.Lock()
+			delete(downloadBundles, bundle.ID)
+			downloadBundlesMutex.Unlock()
+		}()
+		check("zip "+tc.path, httptest.NewRequest(http.MethodGet, bundle.Parts[0].URL, nil), tc.wantVeto)
+	}
+
+	// 同步拉取：请求体中的任何一个文件被拒绝时整个请求被拒绝
+	pull := func(names ...string) *http.Request {
+		body, _ := json.Marshal(map[string]interface{}{"path": dir, "files": names})
+		return httptest.NewRequest(http.MethodPost, "/api/sync/pull", bytes.NewReader(body))
+	}
+	check("sync pull key", pull("docs/readme.txt", "keys/server.key"), true)
+	check("sync pull readme", pull("docs/readme.txt"), false)
+
+	check("text key", httptest.NewRequest(http.MethodGet, "/api/text?path="+url.QueryEscape(files[0]), nil), true)
+	check("text readme", httptest.NewRequest(http.MethodGet, "/api/text?path="+url.QueryEscape(files[1]), nil), false)
+}
+
+func TestScriptHookTimeout(t *testing.T) {
+	s := loadTestScript(t, "loop.js", `onQuery(function(q) { for (;;) {} });`)
+	saved := loadedScripts
+	loadedScripts = []*userScript{s}
+	defer func() { loadedScripts = saved }()
+
+	// 死循环的钩子超时后中断，按没有钩子处理
+	start := time.Now()
+	if got := scriptRewriteQuery("dune"); got != "dune" {
+		t.Errorf("scriptRewriteQuery = %q", got)
+	}
+	if elapsed := time.Since(start); elapsed > scriptHookTimeout+time.Second {
+		t.Errorf("hook ran for %v", elapsed)
+	}
+	if status := scriptStatuses()[0]; status.LastError == "" {
+		t.Errorf("LastError not recorded: %+v", status)
+	}
+}
+
+func TestScriptTaskDoesNotBlockHooks(t *testing.T) {
+	s := loadTestScript(t, "slow.js", `
+		var rewrites = 0;
+		onQuery(function(q) { rewrites++; return q + " ext:txt"; });
+		schedule("@every 1h", function() { for (;;) {} });
+	`)
+	saved := loadedScripts
+	loadedScripts = []*userScript{s}
+	defer func() { loadedScripts = saved }()
+
+	done := make(chan struct{})
+	go func() {
+		s.runTask(s.schedules[0])
+		close(done)
+	}()
+	// 等待任务开始运行
+	for s.taskMu.TryLock() {
+		s.taskMu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	if got := scriptRewriteQuery("dune"); got != "dune ext:txt" {
+		t.Errorf("scriptRewriteQuery = %q", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("hook waited %v for the scheduled task", elapsed)
+	}
+
+	s.taskVM.Interrupt("测试结束")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduled task not interrupted")
+	}
+	if status := scriptStatuses()[0]; status.Calls != 2 || status.LastError == "" {
+		t.Errorf("scriptStatuses = %+v", status)
+	}
+}
+
+func TestScriptLoadError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.js")
+	os.WriteFile(path, []byte(`schedule("not a cron", function() {});`), 0644)
+	if err := newUserScript("bad.js").load(path); err == nil {
+		t.Error("invalid schedule: expected error")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// 安全响应头
+//
+// 每个请求生成一个随机nonce，写入Content-Security-Policy，页面中的<script>标签都带上这个nonce，
+// 文件名等内容即使被注入为<script>标签也不会执行。不允许onclick等内联事件属性，
+// 注入的<img onerror>同样不会执行；页面按钮的事件由pageActionsScript分发。
+// 页面只加载本站的资源，图片和视频允许data:和blob:（缩略图、截取片段）。
+
+type cspNonceKey struct{}
+
+// 生成Content-Security-Policy
+func contentSecurityPolicy(nonce string) string {
+	return "default-src 'self'; " +
+		"script-src 'self' 'nonce-" + nonce + "'; " +
+		"style-src 'self' 'unsafe-inline'; " +
+		"img-src 'self' data: blob:; " +
+		"media-src 'self' blob:; " +
+		"connect-src 'self'; " +
+		"object-src 'none'; " +
+		"base-uri 'none'; " +
+		"form-action 'self'; " +
+		"frame-ancestors 'self'"
+}
+
+// 添加安全响应头，nonce放入请求的context供页面使用
+func withSecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce := newRandomID(16)
+		header := w.Header()
+		if !serverConfig.DisableCSP {
+			header.Set("Content-Security-Policy", contentSecurityPolicy(nonce))
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "same-origin")
+		header.Set("X-Frame-Options", "SAMEORIGIN")
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce)))
+	})
+}
+
+// 在策略的指令中追加来源（如地图页面加载的外部脚本和瓦片）
+func addCSPSources(policy, directive string, sources ...string) string {
+	parts := strings.Split(policy, "; ")
+	for i, part := range parts {
+		if strings.HasPrefix(part, directive+" ") {
+			parts[i] = part + " " + strings.Join(sources, " ")
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// 页面<script>标签的nonce
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// 字符串在内联脚本中的字面量（带引号）。json.Marshal会转义引号、换行和<、>、&，
+// 文件名中的 </script> 不会提前结束脚本
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// 页面按钮的事件分发，放在页面带nonce的<script>中。元素用data-click="名称"等属性
+// 指定处理函数，参数放在data-path等属性里（经过HTML转义），不再把文件名拼进JS代码。
+// 在捕获阶段监听，不冒泡的事件（图片error、视频play等）也能收到，和内联属性一样只交给事件目标本身；
+// 冒泡的事件只分发给最内层带属性的元素，属性值为空时阻止外层元素的处理。
+// 处理函数返回false时阻止默认行为。多次调用时各自生效
+const pageActionsScript = `
+        function bindPageActions(actions) {
+            ['click', 'change', 'input', 'keyup', 'mousedown', 'error', 'load', 'loadstart', 'loadedmetadata', 'canplay', 'play', 'pause', 'stalled', 'abort'].forEach(function(type) {
+                const selector = '[data-' + type + ']';
+                document.addEventListener(type, function(event) {
+                    const target = event.target instanceof Element ? event.target : null;
+                    const el = !target ? null : event.bubbles ? target.closest(selector) : target.matches(selector) ? target : null;
+                    const action = el && actions[el.getAttribute('data-' + type)];
+                    if (action && action.call(el, el, event) === false) event.preventDefault();
+                }, true);
+            });
+        }
+`
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 分段并行下载
+//
+// 高延迟链路上单个TCP连接跑不满带宽，大文件可以分成若干段用多个连接同时下载再拼接：
+//   GET /api/download-plan?path=文件&segments=8   返回各段的范围和下载地址，以及一个令牌
+//   GET /segment/令牌/序号                          下载一段，段内同样支持Range续传
+// 令牌绑定计划创建时文件的大小和修改时间，文件变化后下载分段返回409，需要重新获取计划，
+// 保证拼接出的文件来自同一个版本。也可以不使用令牌，直接对 /file 发送Range请求并带If-Range: ETag。
+// 内置的fetch子命令（见fetch.go）按计划并行下载。
+
+const (
+	defaultDownloadSegments = 8
+	maxDownloadSegments     = 64
+	minDownloadSegmentSize  = 1 << 20 // 每段至少1MB
+	downloadPlanExpiry      = 24 * time.Hour
+)
+
+type DownloadSegment struct {
+	Index  int    `json:"index"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"` // 包含
+	Length int64  `json:"length"`
+	Range  string `json:"range"` // 对 /file 请求时使用的Range头
+	URL    string `json:"url"`
+}
+
+type DownloadPlan struct {
+	Token    string            `json:"token"`
+	Path     string            `json:"path"`
+	Name     string            `json:"name"`
+	Size     int64             `json:"size"`
+	Modified string            `json:"modified"`
+	ETag     string            `json:"etag"`
+	FileURL  string            `json:"fileUrl"` // 整个文件的地址
+	Segments []DownloadSegment `json:"segments"`
+	Expires  string            `json:"expires"`
+
+	path      string
+	modTime   time.Time
+	createdAt time.Time
+}
+
+var (
+	downloadPlans      = make(map[string]*DownloadPlan)
+	downloadPlansMutex sync.RWMutex
+)
+
+// 把size字节分成最多n段，每段不小于minDownloadSegmentSize，前面的段可能多1字节
+func splitSegments(size int64, n int) [][2]int64 {
+	if size <= 0 {
+		return nil
+	}
+	count := max(1, min(int64(n), (size+minDownloadSegmentSize-1)/minDownloadSegmentSize))
+	segments := make([][2]int64, 0, count)
+	base, extra := size/count, size%count
+	var start int64
+	for i := int64(0); i < count; i++ {
+		length := base
+		if i < extra {
+			length++
+		}
+		segments = append(segments, [2]int64{start, start + length - 1})
+		start += length
+	}
+	return segments
+}
+
+// 为文件创建下载计划
+func newDownloadPlan(clientSrc string, segments int) (*DownloadPlan, error) {
+	path := resolveClientPath(clientSrc)
+	info, err := statPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("不能分段下载文件夹")
+	}
+
+	now := time.Now()
+	plan := &DownloadPlan{
+		Token:     newRandomID(16),
+		Path:      clientPath(path),
+		Name:      filepath.Base(path),
+		Size:      info.Size(),
+		Modified:  info.ModTime().Format("2006-01-02 15:04:05"),
+		ETag:      fileETag(info),
+		FileURL:   "/file/" + url.PathEscape(clientPath(path)) + "?download=1",
+		Segments:  []DownloadSegment{},
+		Expires:   now.Add(downloadPlanExpiry).Format("2006-01-02 15:04:05"),
+		path:      path,
+		modTime:   info.ModTime(),
+		createdAt: now,
+	}
+	for i, rg := range splitSegments(info.Size(), segments) {
+		plan.Segments = append(plan.Segments, DownloadSegment{
+			Index:  i + 1,
+			Start:  rg[0],
+			End:    rg[1],
+			Length: rg[1] - rg[0] + 1,
+			Range:  fmt.Sprintf("bytes=%d-%d", rg[0], rg[1]),
+			URL:    fmt.Sprintf("/segment/%s/%d", plan.Token, i+1),
+		})
+	}
+
+	downloadPlansMutex.Lock()
+	for token, p := range downloadPlans {
+		if now.Sub(p.createdAt) > downloadPlanExpiry {
+			delete(downloadPlans, token)
+		}
+	}
+	downloadPlans[plan.Token] = plan
+	downloadPlansMutex.Unlock()
+
+	return plan, nil
+}
+
+// 下载计划API处理器
+func apiDownloadPlanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path参数不能为空", http.StatusBadRequest)
+		return
+	}
+	if remoteEverythingEnabled() {
+		http.Error(w, "使用远程Everything时不支持分段下载，请直接对/file发送Range请求", http.StatusBadRequest)
+		return
+	}
+
+	segments := defaultDownloadSegments
+	if s := r.URL.Query().Get("segments"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxDownloadSegments {
+			http.Error(w, fmt.Sprintf("segments参数应为1-%d之间的整数", maxDownloadSegments), http.StatusBadRequest)
+			return
+		}
+		segments = n
+	}
+
+	plan, err := newDownloadPlan(path, segments)
+	if err != nil {
+		log.Printf("创建分段下载计划失败: %s, 错误: %v", path, err)
+		status := http.StatusBadRequest
+		if os.IsNotExist(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, "创建下载计划失败: "+err.Error(), status)
+		return
+	}
+
+	log.Printf("分段下载计划: %s, 大小%d, %d段, 来源IP: %s", plan.Path, plan.Size, len(plan.Segments), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// 分段下载处理器 /segment/令牌/序号，文件在计划创建后变化时返回409
+func segmentHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/segment/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	index, err := strconv.Atoi(parts[1])
+
+	downloadPlansMutex.RLock()
+	plan, ok := downloadPlans[parts[0]]
+	downloadPlansMutex.RUnlock()
+	if !ok || err != nil || index < 1 || index > len(plan.Segments) {
+		http.Error(w, "下载计划不存在或已过期", http.StatusNotFound)
+		return
+	}
+	segment := plan.Segments[index-1]
+
+	file, err := openPath(plan.path)
+	if err != nil {
+		http.Error(w, "无法打开文件: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.Size() != plan.Size || !info.ModTime().Equal(plan.modTime) {
+		log.Printf("分段下载时文件已变化: %s", plan.path)
+		http.Error(w, "文件已变化，请重新获取下载计划", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", fmt.Sprintf(`%s-%d"`, strings.TrimSuffix(plan.ETag, `"`), index))
+	w.Header().Set("X-Segment-Range", fmt.Sprintf("%d-%d/%d", segment.Start, segment.End, plan.Size))
+
+	tw := newTransferWriter(w, r)
+	http.ServeContent(tw, r, "", plan.modTime, io.NewSectionReader(file, segment.Start, segment.Length))
+	log.Printf("分段下载: %s 第%d/%d段, Range: %s, 状态%d, 传输了%d字节, 来源IP: %s",
+		plan.Name, index, len(plan.Segments), r.Header.Get("Range"), tw.status, tw.written, r.RemoteAddr)
+}
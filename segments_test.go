@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitSegments(t *testing.T) {
+	tests := []struct {
+		size int64
+		n    int
+		want string
+	}{
+		{0, 8, "[]"},
+		{100, 8, "[[0 99]]"}, // 每段至少1MB
+		{3<<20 + 1, 3, fmt.Sprintf("[[0 %d] [%d %d] [%d %d]]", 1<<20, 1<<20+1, 2<<20, 2<<20+1, 3<<20)},
+		{2 << 20, 8, fmt.Sprintf("[[0 %d] [%d %d]]", 1<<20-1, 1<<20, 2<<20-1)},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(splitSegments(tt.size, tt.n)); got != tt.want {
+			t.Errorf("splitSegments(%d, %d) = %s, want %s", tt.size, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentedFetch(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "big.bin")
+	data := make([]byte, 5<<20+123)
+	rand.New(rand.NewSource(1)).Read(data)
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/download-plan", apiDownloadPlanHandler)
+	mux.HandleFunc("/segment/", segmentHandler)
+	mux.HandleFunc("/file/", fileHandler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// 并行下载并与服务器的校验和比较
+	out := filepath.Join(dir, "out.bin")
+	if code := runFetch([]string{"-url", server.URL, "-c", "4", "-o", out, "-checksum", "sha256", src}); code != 0 {
+		t.Fatalf("fetch exit code = %d", code)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("downloaded file differs from source (err: %v, %d bytes)", err, len(got))
+	}
+
+	// 段内续传
+	plan, err := newDownloadPlan(src, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, plan.Segments[1].URL, nil)
+	req.Header.Set("Range", "bytes=10-19")
+	rec := serveTestRequest(segmentHandler, req)
+	if start := plan.Segments[1].Start; rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), data[start+10:start+20]) {
+		t.Errorf("segment range: status = %d, body %d bytes", rec.Code, rec.Body.Len())
+	}
+
+	// 计划创建后文件变化
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(src, later, later); err != nil {
+		t.Fatal(err)
+	}
+	rec = serveTestRequest(segmentHandler, httptest.NewRequest(http.MethodGet, plan.Segments[0].URL, nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("changed file: status = %d, want 409", rec.Code)
+	}
+}
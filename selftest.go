@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 自检和诊断页面
+//
+// 启动时在后台检查运行环境，失败的项目连同修复方法写入日志。GET /api/selftest 重新检查并返回结果，
+// /selftest 页面逐项显示检查结果和需要做的修改。
+
+const (
+	selfTestQueryTimeout = 5 * time.Second
+	selfTestMinFreeSpace = 2 << 30 // 缓存所在磁盘的最小剩余空间（转码结果可能很大）
+
+	// 查询一个不存在的文件名，只检查Everything能否响应
+	selfTestQuery = "everything-web-server-selftest"
+)
+
+const (
+	checkOK      = "ok"
+	checkWarning = "warning"
+	checkError   = "error"
+	checkSkipped = "skipped"
+)
+
+type SelfTestCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // ok, warning, error, skipped
+	Detail string `json:"detail,omitempty"`
+	Fix    string `json:"fix,omitempty"` // 如何修复
+}
+
+type SelfTestReport struct {
+	Checks    []SelfTestCheck `json:"checks"`
+	Errors    int             `json:"errors"`
+	Warnings  int             `json:"warnings"`
+	CheckedAt string          `json:"checkedAt"`
+	ElapsedMs int64           `json:"elapsedMs"`
+}
+
+var selfTestMutex sync.Mutex // 同一时间只进行一次自检
+
+// 运行全部检查
+func runSelfTest(ctx context.Context) SelfTestReport {
+	selfTestMutex.Lock()
+	defer selfTestMutex.Unlock()
+
+	start := time.Now()
+	var report SelfTestReport
+	add := func(check SelfTestCheck) {
+		switch check.Status {
+		case checkError:
+			report.Errors++
+		case checkWarning:
+			report.Warnings++
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	add(checkEverythingSDK())
+	add(checkEverythingQuery(ctx))
+	add(checkESExe())
+	add(checkToolVersion("ffmpeg", ffmpegBinary(), "ffmpegPath"))
+	add(checkToolVersion("ffprobe", ffprobeBinary(), "ffprobePath"))
+	add(checkCacheDirs())
+	add(checkFreeSpace())
+	add(checkFirewall())
+
+	report.CheckedAt = time.Now().Format("2006-01-02 15:04:05")
+	report.ElapsedMs = time.Since(start).Milliseconds()
+	return report
+}
+
+func checkEverythingSDK() SelfTestCheck {
+	check := SelfTestCheck{Name: "Everything SDK"}
+	switch {
+	case remoteEverythingEnabled():
+		check.Status = checkSkipped
+		check.Detail = "使用远程Everything: " + serverConfig.RemoteEverything.URL
+	case runtime.GOOS != "windows":
+		check.Status = checkSkipped
+		check.Detail = "Everything SDK只支持Windows"
+	case everythingInstanceName() != "":
+		if everything3Available() {
+			check.Status = checkOK
+			check.Detail = "已连接Everything实例: " + everythingInstanceName()
+		} else {
+			check.Status = checkError
+			check.Detail = "无法连接Everything实例: " + everythingInstanceName()
+			check.Fix = "确认Everything 1.5以 -instance " + everythingInstanceName() + " 运行，并将Everything3_x64.dll放在程序所在文件夹或everythingDir中"
+		}
+	default:
+		if err := initEverythingSDK(); err != nil {
+			check.Status = checkWarning
+			check.Detail = err.Error()
+			check.Fix = "将Everything64.dll（Everything SDK）放在程序所在文件夹；没有SDK时搜索使用较慢的es.exe"
+		} else {
+			check.Status = checkOK
+			check.Detail = "已加载: " + everythingDLLPath
+		}
+	}
+	return check
+}
+
+func checkEverythingQuery(ctx context.Context) SelfTestCheck {
+	check := SelfTestCheck{Name: "搜索"}
+	ctx, cancel := context.WithTimeout(ctx, selfTestQueryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := searchBackend.Search(ctx, selfTestQuery)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		check.Status = checkError
+		check.Detail = err.Error()
+		switch {
+		case remoteEverythingEnabled():
+			check.Fix = "确认远程Everything的HTTP服务器已启用，地址、用户名和密码正确"
+		case runtime.GOOS == "windows":
+			check.Fix = "启动Everything，并确认索引已加载完成"
+		default:
+			check.Fix = "安装plocate（或locate、fd）并运行updatedb，或配置远程Everything"
+		}
+		return check
+	}
+	check.Status = checkOK
+	check.Detail = fmt.Sprintf("查询用时%v", elapsed)
+	if elapsed > selfTestQueryTimeout/2 {
+		check.Status = checkWarning
+		check.Fix = "搜索较慢，确认Everything正在运行（未运行时回退到es.exe）"
+	}
+	return check
+}
+
+func checkESExe() SelfTestCheck {
+	check := SelfTestCheck{Name: "es.exe"}
+	if runtime.GOOS != "windows" || remoteEverythingEnabled() {
+		check.Status = checkSkipped
+		return check
+	}
+	if path := esExePath(); path != "" {
+		check.Status = checkOK
+		check.Detail = path
+		return check
+	}
+	check.Status = checkWarning
+	check.Detail = "找不到es.exe，SDK不可用时无法搜索"
+	check.Fix = "从voidtools.com下载Everything命令行工具，将es.exe放在程序所在文件夹"
+	return check
+}
+
+// 检查ffmpeg或ffprobe能否运行，返回版本
+func checkToolVersion(name, binary, configKey string) SelfTestCheck {
+	check := SelfTestCheck{Name: name}
+	output, err := exec.Command(binary, "-version").Output()
+	if err != nil {
+		check.Status = checkWarning
+		check.Detail = err.Error()
+		check.Fix = fmt.Sprintf("安装ffmpeg并加入PATH，或在data\\config.json中设置%s；没有%s时不能转码、生成预览图和获取视频时长", configKey, name)
+		return check
+	}
+	version, _, _ := strings.Cut(string(output), "\n")
+	check.Status = checkOK
+	check.Detail = strings.TrimSpace(version)
+	return check
+}
+
+// 检查数据目录和各缓存目录能否写入
+func checkCacheDirs() SelfTestCheck {
+	check := SelfTestCheck{Name: "缓存目录"}
+	dirs := []string{getDataDir()}
+	for _, name := range []string{"icons", "images", "jobs", "storyboard"} {
+		dirs = append(dirs, getCacheDir(name))
+	}
+
+	var failed []string
+	for _, dir := range dirs {
+		f, err := os.CreateTemp(dir, ".selftest-*")
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if len(failed) > 0 {
+		check.Status = checkError
+		check.Detail = strings.Join(failed, "; ")
+		check.Fix = "确认运行服务器的用户对 " + getDataDir() + " 有写入权限，或把程序放到可写的文件夹中"
+		return check
+	}
+	check.Status = checkOK
+	check.Detail = filepath.Join(getDataDir(), cacheDirName)
+	return check
+}
+
+// 检查缓存所在磁盘的剩余空间
+func checkFreeSpace() SelfTestCheck {
+	check := SelfTestCheck{Name: "磁盘空间"}
+	dir := getCacheDir("jobs")
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		check.Status = checkSkipped
+		check.Detail = err.Error()
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s 剩余 %s", filepath.VolumeName(dir)+string(filepath.Separator), formatSize(int64(free)))
+	if free < selfTestMinFreeSpace {
+		check.Status = checkWarning
+		check.Fix = fmt.Sprintf("缓存所在磁盘剩余空间不足%s，转码和预览图可能失败；清理磁盘或减小cacheQuotaMB", formatSize(selfTestMinFreeSpace))
+		return check
+	}
+	check.Status = checkOK
+	return check
+}
+
+// 启动时自检，只记录日志
+func runStartupSelfTest() {
+	report := runSelfTest(context.Background())
+	for _, check := range report.Checks {
+		switch check.Status {
+		case checkError, checkWarning:
+			log.Printf("自检 [%s] %s: %s；%s", check.Status, check.Name, check.Detail, check.Fix)
+		}
+	}
+	log.Printf("自检完成: %d个错误，%d个警告，用时%dms，详情见 /selftest", report.Errors, report.Warnings, report.ElapsedMs)
+}
+
+// 自检API处理器
+func apiSelfTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	log.Printf("运行自检，来源IP: %s", r.RemoteAddr)
+	report := runSelfTest(r.Context())
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(report)
+}
+
+// 诊断页面
+func selfTestPageHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>诊断 - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .container { max-width: 900px; margin: 0 auto; }
+        .check { background: white; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); padding: 12px 20px; margin-bottom: 10px; border-left: 6px solid #ccc; }
+        .check h3 { margin: 0 0 4px; font-size: 16px; }
+        .check.ok { border-left-color: #4CAF50; }
+        .check.warning { border-left-color: #ff9800; }
+        .check.error { border-left-color: #f44336; }
+        .detail { color: #555; font-size: 13px; word-break: break-all; }
+        .fix { margin-top: 6px; padding: 6px 10px; background: #fff3e0; border-radius: 4px; font-size: 13px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; }
+        button { padding: 6px 14px; background: #4CAF50; color: white; border: none; border-radius: 4px; cursor: pointer; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h2>🩺 诊断</h2>
+        <p><a href="/">← 返回首页</a> <button data-click="reload">重新检查</button> <span class="meta" id="info">检查中...</span></p>
+        <div id="content"></div>
+    </div>
+    <script nonce="` + nonce + `">` + pageActionsScript + `
+        bindPageActions({ reload: () => load() });
+
+        const icons = { ok: '✅', warning: '⚠️', error: '❌', skipped: '➖' };
+
+        function escapeHtml(s) {
+            return String(s).replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
+        }
+
+        async function load() {
+            document.getElementById('info').textContent = '检查中...';
+            try {
+                const response = await fetch('/api/selftest');
+                if (!response.ok) throw new Error(await response.text());
+                const report = await response.json();
+                document.getElementById('info').textContent = report.errors + '个错误，' + report.warnings + '个警告（' + report.checkedAt + '，用时' + report.elapsedMs + 'ms）';
+                document.getElementById('content').innerHTML = report.checks.map(c =>
+                    '<div class="check ' + c.status + '"><h3>' + icons[c.status] + ' ' + escapeHtml(c.name) + '</h3>' +
+                    (c.detail ? '<div class="detail">' + escapeHtml(c.detail) + '</div>' : '') +
+                    (c.fix ? '<div class="fix">🔧 ' + escapeHtml(c.fix) + '</div>' : '') + '</div>').join('');
+            } catch (error) {
+                document.getElementById('info').textContent = '检查失败: ' + error.message;
+            }
+        }
+
+        load();
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// 路径所在文件系统当前用户可用的剩余空间
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// 其他系统的防火墙（iptables、nftables、pf等）配置方式各不相同，不做检查
+func checkFirewall() SelfTestCheck {
+	return SelfTestCheck{Name: "防火墙", Status: checkSkipped, Detail: "只检查Windows防火墙"}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// 路径所在磁盘当前用户可用的剩余空间
+func freeDiskSpace(path string) (uint64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	if ret, _, err := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(&free)), 0, 0); ret == 0 {
+		return 0, err
+	}
+	return free, nil
+}
+
+// 检查Windows防火墙：防火墙开启且没有放行规则时，局域网无法访问
+func checkFirewall() SelfTestCheck {
+	check := SelfTestCheck{Name: "防火墙"}
+	fix := fmt.Sprintf(`以管理员身份运行 netsh advfirewall firewall add rule name="Everything Web Server" dir=in action=allow protocol=TCP localport=%s`, serverPort)
+
+	output, err := exec.Command("netsh", "advfirewall", "show", "currentprofile", "state").Output()
+	if err != nil {
+		check.Status = checkSkipped
+		check.Detail = "无法获取防火墙状态: " + err.Error()
+		return check
+	}
+	// 输出随系统语言不同，只判断是否包含OFF/关闭
+	state := strings.ToUpper(string(output))
+	if strings.Contains(state, "OFF") || strings.Contains(state, "关闭") {
+		check.Status = checkOK
+		check.Detail = "防火墙已关闭"
+		return check
+	}
+
+	if err := exec.Command("netsh", "advfirewall", "firewall", "show", "rule", "name=Everything Web Server").Run(); err == nil {
+		check.Status = checkOK
+		check.Detail = "防火墙已开启，已有放行规则 Everything Web Server"
+		return check
+	}
+	check.Status = checkWarning
+	check.Detail = "防火墙已开启，没有名为 Everything Web Server 的放行规则，局域网中的其他设备可能无法访问"
+	check.Fix = fix
+	return check
+}
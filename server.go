@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTP服务器参数
+const (
+	serverReadHeaderTimeout = 10 * time.Second  // 读取请求头的超时，防止慢速连接占用资源
+	serverReadTimeout       = 60 * time.Second  // 读取整个请求（含请求体）的超时
+	serverIdleTimeout       = 120 * time.Second // keep-alive空闲连接的超时
+	serverMaxHeaderBytes    = 64 << 10          // 请求头最大64KB
+	apiWriteTimeout         = 60 * time.Second  // 非流式响应的写超时
+	http2MaxStreams         = 256               // 单个HTTP/2连接的最大并发流，满足一页200张缩略图
+)
+
+// 需要长时间写出的路径（视频流、转码、文件下载、同步拉取、事件推送），不设置写超时
+var longWritePrefixes = []string{
+	"/file/",
+	"/stream/",
+	"/transcode/",
+	"/download/",
+	"/api/sync/pull", // tar流包含整个文件，大文件在慢速网络上需要很长时间
+	"/segment/",      // 分段下载的每段可能有几GB
+	"/disc/",         // 从光盘映像中提取的单个文件
+	"/api/batch/events",
+	"/api/notifications/events",
+}
+
+// 创建HTTP服务器
+//
+// 服务器级别不设置WriteTimeout，否则会中断长时间的视频播放；
+// 改为对普通API和页面单独设置写超时，避免已断开的客户端一直占用连接
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           withRecovery(withWriteTimeout(withRequestTimeout(handler))),
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		IdleTimeout:       serverIdleTimeout,
+		MaxHeaderBytes:    serverMaxHeaderBytes,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: http2MaxStreams,
+		},
+	}
+
+	// TLS模式下浏览器会自动协商HTTP/2；明文模式同时接受HTTP/1.1和h2c（已知支持HTTP/2的客户端）
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server.Protocols = protocols
+
+	return server
+}
+
+// 为非流式响应设置写超时
+func withWriteTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if timeout := writeTimeout(r.URL.Path); timeout > 0 {
+			http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 请求的写超时，0表示不限制。写超时从请求开始计算，包含了处理时间，
+// 有时间预算的路由（见timeouts.go）在用完预算后仍有apiWriteTimeout写出结果
+func writeTimeout(path string) time.Duration {
+	for _, prefix := range longWritePrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return 0
+		}
+	}
+	return findRouteBudget(path).timeout + apiWriteTimeout
+}
+
+// 耗时的处理完成后重新计算写超时：请求开始时设置的写超时也包含了处理时间（录屏、ffmpeg生成等），
+// 处理超过apiWriteTimeout时结果已无法写出。d为0时不限制（之后要写出大文件）
+func restartWriteDeadline(w http.ResponseWriter, d time.Duration) {
+	var deadline time.Time
+	if d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	http.NewResponseController(w).SetWriteDeadline(deadline)
+}
+
+// 在已监听的端口上启动服务器，配置了证书时使用HTTPS
+func startHTTPServer(server *http.Server, listener net.Listener) error {
+	if serverConfig.TLSCertFile != "" && serverConfig.TLSKeyFile != "" {
+		return server.ServeTLS(listener, serverConfig.TLSCertFile, serverConfig.TLSKeyFile)
+	}
+	return server.Serve(listener)
+}
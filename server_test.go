@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 记录处理器设置的写超时（http.ResponseController调用SetWriteDeadline）
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadline time.Time
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	d.deadline = deadline
+	return nil
+}
+
+func TestWriteTimeout(t *testing.T) {
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/api/favorites", apiWriteTimeout},
+		{"/api/search", 30*time.Second + apiWriteTimeout},
+		// 处理预算超过apiWriteTimeout的路由，结果仍能写出
+		{"/api/du", 120*time.Second + apiWriteTimeout},
+		{"/api/cleanup-suggestions", 120*time.Second + apiWriteTimeout},
+		{"/file/D:/a.iso", 0},
+		{"/api/sync/pull", 0},
+		{"/segment/token/0", 0},
+		{"/disc/D:/a.iso", 0},
+		{"/api/disc", apiWriteTimeout},
+		{"/api/actions", apiWriteTimeout},       // 命令运行完成后重新计算，见下
+		{"/api/sync/manifest", apiWriteTimeout}, // 生成清单后重新计算
+	}
+	for _, tt := range tests {
+		if got := writeTimeout(tt.path); got != tt.want {
+			t.Errorf("writeTimeout(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// 处理时间可能超过apiWriteTimeout的请求，处理完成后重新设置写超时
+	dir := t.TempDir()
+	paths := createTestFiles(t, dir, "a.zip")
+	serverConfig.Actions = []FileAction{{ID: "list", Label: "列出", Command: []string{os.Args[0], "-test.run=TestActionHelperProcess", "--", "{name}"}}}
+	defer func() { serverConfig.Actions = nil }()
+	actionBody, _ := json.Marshal(map[string]string{"id": "list", "path": paths[0]})
+
+	restarts := []struct {
+		handler http.HandlerFunc
+		req     *http.Request
+	}{
+		{apiActionsHandler, httptest.NewRequest(http.MethodPost, "/api/actions", strings.NewReader(string(actionBody)))},
+		{apiSyncManifestHandler, httptest.NewRequest(http.MethodGet, "/api/sync/manifest?hash=sha256&path="+url.QueryEscape(dir), nil)},
+	}
+	for _, tt := range restarts {
+		rec := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		tt.handler(rec, tt.req)
+		done := time.Now()
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, body: %s", tt.req.URL.Path, rec.Code, rec.Body.String())
+		}
+		if rec.deadline.Before(done.Add(apiWriteTimeout-5*time.Second)) || rec.deadline.After(done.Add(apiWriteTimeout)) {
+			t.Errorf("%s: write deadline %v is not restarted after processing (done at %v)", tt.req.URL.Path, rec.deadline, done)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// 客户端会话Cookie，用于区分不同浏览器的收藏、偏好等数据（没有登录系统时相当于“用户”）
+const (
+	sessionCookieName   = "ew_session"
+	sessionCookieMaxAge = 10 * 365 * 24 * 60 * 60 // 10年
+)
+
+// 生成随机ID
+func newRandomID(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// 获取当前请求的会话ID，没有时生成新的并写入Cookie
+func getSessionID(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && len(cookie.Value) == 32 {
+		return cookie.Value
+	}
+
+	id := newRandomID(16)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   sessionCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
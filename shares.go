@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 网络共享凭据（密码使用DPAPI加密后保存）
+type ShareCredential struct {
+	Share             string `json:"share"` // \\server\share
+	Username          string `json:"username"`
+	EncryptedPassword string `json:"encryptedPassword"`
+	Added             string `json:"added"`
+}
+
+// 网络共享状态（返回给客户端，不包含密码）
+type ShareStatus struct {
+	Share     string `json:"share"`
+	Username  string `json:"username"`
+	Added     string `json:"added"`
+	Connected bool   `json:"connected"`
+	Error     string `json:"error,omitempty"`
+}
+
+const sharesFileName = "shares.json"
+
+var (
+	shareCredentials = make(map[string]*ShareCredential)
+	shareErrors      = make(map[string]string)
+	sharesMutex      sync.RWMutex
+)
+
+// 判断是否为UNC路径
+func isUNCPath(path string) bool {
+	return strings.HasPrefix(path, `\\`) && !strings.HasPrefix(path, `\\?\`)
+}
+
+// 从UNC路径中提取共享根路径，如 \\server\share\dir → \\server\share
+func getShareRoot(path string) string {
+	if !isUNCPath(path) {
+		return ""
+	}
+	return filepath.VolumeName(path)
+}
+
+// 加载已保存的共享凭据
+func loadShareCredentials() {
+	var saved []*ShareCredential
+	if err := loadJSONFile(sharesFileName, &saved); err != nil {
+		log.Printf("加载网络共享凭据失败: %v", err)
+		return
+	}
+
+	sharesMutex.Lock()
+	defer sharesMutex.Unlock()
+	for _, cred := range saved {
+		shareCredentials[strings.ToLower(cred.Share)] = cred
+	}
+	log.Printf("已加载%d个网络共享凭据", len(saved))
+}
+
+// 保存共享凭据，调用方需持有sharesMutex
+func saveShareCredentialsLocked() error {
+	saved := make([]*ShareCredential, 0, len(shareCredentials))
+	for _, cred := range shareCredentials {
+		saved = append(saved, cred)
+	}
+	return saveJSONFile(sharesFileName, saved)
+}
+
+// 使用已保存的凭据连接共享
+func connectSavedShare(cred *ShareCredential) error {
+	encrypted, err := base64.StdEncoding.DecodeString(cred.EncryptedPassword)
+	if err != nil {
+		return fmt.Errorf("凭据格式错误: %v", err)
+	}
+	password, err := dpapiDecrypt(encrypted)
+	if err != nil {
+		return err
+	}
+	return connectShare(cred.Share, cred.Username, string(password))
+}
+
+// 启动时重新连接所有已保存的共享
+func restoreShareConnections() {
+	loadShareCredentials()
+
+	sharesMutex.RLock()
+	creds := make([]*ShareCredential, 0, len(shareCredentials))
+	for _, cred := range shareCredentials {
+		creds = append(creds, cred)
+	}
+	sharesMutex.RUnlock()
+
+	for _, cred := range creds {
+		err := connectSavedShare(cred)
+
+		sharesMutex.Lock()
+		if err != nil {
+			log.Printf("重新连接网络共享失败: %s, 错误: %v", cred.Share, err)
+			shareErrors[strings.ToLower(cred.Share)] = err.Error()
+		} else {
+			log.Printf("已重新连接网络共享: %s", cred.Share)
+			delete(shareErrors, strings.ToLower(cred.Share))
+		}
+		sharesMutex.Unlock()
+	}
+}
+
+// 获取所有已保存共享的根路径
+func getSavedShareRoots() []string {
+	sharesMutex.RLock()
+	defer sharesMutex.RUnlock()
+
+	roots := make([]string, 0, len(shareCredentials))
+	for _, cred := range shareCredentials {
+		roots = append(roots, cred.Share)
+	}
+	return roots
+}
+
+// 网络共享API处理器
+// GET 列出已保存的共享；POST 添加凭据并连接；DELETE 断开并删除凭据
+func apiSharesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sharesMutex.RLock()
+		statuses := []ShareStatus{}
+		for key, cred := range shareCredentials {
+			status := ShareStatus{
+				Share:    cred.Share,
+				Username: cred.Username,
+				Added:    cred.Added,
+				Error:    shareErrors[key],
+			}
+			status.Connected = status.Error == ""
+			statuses = append(statuses, status)
+		}
+		sharesMutex.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"shares": statuses,
+			"count":  len(statuses),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Share    string `json:"share"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		share := getShareRoot(strings.ReplaceAll(strings.TrimRight(req.Share, `\/`), "/", "\\"))
+		if share == "" {
+			http.Error(w, "共享路径格式应为 \\\\server\\share", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("添加网络共享: %s, 用户: %s, 来源IP: %s", share, req.Username, r.RemoteAddr)
+
+		if err := connectShare(share, req.Username, req.Password); err != nil {
+			log.Printf("连接网络共享失败: %s, 错误: %v", share, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		encrypted, err := dpapiEncrypt([]byte(req.Password))
+		if err != nil {
+			log.Printf("加密共享凭据失败: %v", err)
+			http.Error(w, "加密凭据失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sharesMutex.Lock()
+		key := strings.ToLower(share)
+		shareCredentials[key] = &ShareCredential{
+			Share:             share,
+			Username:          req.Username,
+			EncryptedPassword: base64.StdEncoding.EncodeToString(encrypted),
+			Added:             time.Now().Format("2006-01-02 15:04:05"),
+		}
+		delete(shareErrors, key)
+		err = saveShareCredentialsLocked()
+		sharesMutex.Unlock()
+
+		if err != nil {
+			log.Printf("保存网络共享凭据失败: %v", err)
+			http.Error(w, "保存凭据失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sendAlert("已添加网络共享凭据: "+share, fmt.Sprintf("用户: %s\n来源IP: %s", req.Username, r.RemoteAddr))
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"share":   share,
+			"message": "已连接网络共享: " + share,
+		})
+
+	case http.MethodDelete:
+		share := getShareRoot(r.URL.Query().Get("share"))
+		if share == "" {
+			http.Error(w, "共享路径格式应为 \\\\server\\share", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("删除网络共享: %s, 来源IP: %s", share, r.RemoteAddr)
+
+		if err := disconnectShare(share); err != nil {
+			// 可能本来就未连接，仍然删除凭据
+			log.Printf("断开网络共享失败: %s, 错误: %v", share, err)
+		}
+
+		sharesMutex.Lock()
+		key := strings.ToLower(share)
+		_, existed := shareCredentials[key]
+		delete(shareCredentials, key)
+		delete(shareErrors, key)
+		err := saveShareCredentialsLocked()
+		sharesMutex.Unlock()
+
+		if err != nil {
+			http.Error(w, "保存凭据失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": existed,
+			"share":   share,
+		})
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// 网络共享连接和DPAPI只在Windows上可用，其他系统请先在系统中挂载共享
+
+var errSharesUnsupported = errors.New("当前系统不支持连接网络共享")
+
+func dpapiEncrypt(plain []byte) ([]byte, error) {
+	return nil, errSharesUnsupported
+}
+
+func dpapiDecrypt(encrypted []byte) ([]byte, error) {
+	return nil, errSharesUnsupported
+}
+
+func connectShare(share, username, password string) error {
+	return errSharesUnsupported
+}
+
+func disconnectShare(share string) error {
+	return errSharesUnsupported
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Windows网络连接和DPAPI函数
+var (
+	mprDLL                 = syscall.NewLazyDLL("mpr.dll")
+	wnetAddConnection2     = mprDLL.NewProc("WNetAddConnection2W")
+	wnetCancelConnection2  = mprDLL.NewProc("WNetCancelConnection2W")
+	crypt32DLL             = syscall.NewLazyDLL("crypt32.dll")
+	cryptProtectDataProc   = crypt32DLL.NewProc("CryptProtectData")
+	cryptUnprotectDataProc = crypt32DLL.NewProc("CryptUnprotectData")
+)
+
+const (
+	resourceTypeDisk               = 1
+	errorSessionCredentialConflict = 1219
+)
+
+type netResource struct {
+	Scope       uint32
+	Type        uint32
+	DisplayType uint32
+	Usage       uint32
+	LocalName   *uint16
+	RemoteName  *uint16
+	Comment     *uint16
+	Provider    *uint16
+}
+
+type dataBlob struct {
+	Size uint32
+	Data *byte
+}
+
+func newDataBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+func (b *dataBlob) bytes() []byte {
+	out := make([]byte, b.Size)
+	copy(out, unsafe.Slice(b.Data, b.Size))
+	return out
+}
+
+// 使用DPAPI加密（只有运行服务器的Windows用户可以解密）
+func dpapiEncrypt(plain []byte) ([]byte, error) {
+	var out dataBlob
+	ret, _, err := cryptProtectDataProc.Call(
+		uintptr(unsafe.Pointer(newDataBlob(plain))),
+		0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if ret == 0 {
+		return nil, fmt.Errorf("CryptProtectData失败: %v", err)
+	}
+	defer syscall.LocalFree(syscall.Handle(unsafe.Pointer(out.Data)))
+	return out.bytes(), nil
+}
+
+// 使用DPAPI解密
+func dpapiDecrypt(encrypted []byte) ([]byte, error) {
+	var out dataBlob
+	ret, _, err := cryptUnprotectDataProc.Call(
+		uintptr(unsafe.Pointer(newDataBlob(encrypted))),
+		0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if ret == 0 {
+		return nil, fmt.Errorf("CryptUnprotectData失败: %v", err)
+	}
+	defer syscall.LocalFree(syscall.Handle(unsafe.Pointer(out.Data)))
+	return out.bytes(), nil
+}
+
+// 使用凭据连接网络共享
+func connectShare(share, username, password string) error {
+	remoteName, err := syscall.UTF16PtrFromString(share)
+	if err != nil {
+		return err
+	}
+
+	resource := netResource{
+		Type:       resourceTypeDisk,
+		RemoteName: remoteName,
+	}
+
+	var userPtr, passPtr *uint16
+	if username != "" {
+		if userPtr, err = syscall.UTF16PtrFromString(username); err != nil {
+			return err
+		}
+	}
+	if password != "" {
+		if passPtr, err = syscall.UTF16PtrFromString(password); err != nil {
+			return err
+		}
+	}
+
+	ret, _, _ := wnetAddConnection2.Call(
+		uintptr(unsafe.Pointer(&resource)),
+		uintptr(unsafe.Pointer(passPtr)),
+		uintptr(unsafe.Pointer(userPtr)),
+		0,
+	)
+	if ret != 0 {
+		if ret == errorSessionCredentialConflict {
+			return fmt.Errorf("已使用其他凭据连接到该服务器，请先断开 (错误码: %d)", ret)
+		}
+		return fmt.Errorf("连接网络共享失败: %v (错误码: %d)", syscall.Errno(ret), ret)
+	}
+	return nil
+}
+
+// 断开网络共享
+func disconnectShare(share string) error {
+	namePtr, err := syscall.UTF16PtrFromString(share)
+	if err != nil {
+		return err
+	}
+
+	ret, _, _ := wnetCancelConnection2.Call(uintptr(unsafe.Pointer(namePtr)), 0, 1)
+	if ret != 0 {
+		return fmt.Errorf("断开网络共享失败: %v (错误码: %d)", syscall.Errno(ret), ret)
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// Windows快捷方式（.lnk）解析
+//
+// 按Shell Link二进制格式（MS-SHLLINK）直接读取目标路径，不需要COM。
+// 搜索和浏览结果中的快捷方式返回 linkType: "shortcut" 和目标路径，
+// 页面上的"打开"等操作作用于目标；GET /api/shortcut?path= 返回完整信息。
+
+const LinkTypeShortcut = "shortcut"
+
+const (
+	maxShortcutSize = 1 << 20 // 正常的快捷方式只有几KB
+
+	slHasTargetIDList = 0x1
+	slHasLinkInfo     = 0x2
+	slHasName         = 0x4
+	slHasRelativePath = 0x8
+	slHasWorkingDir   = 0x10
+	slHasArguments    = 0x20
+	slHasIconLocation = 0x40
+	slIsUnicode       = 0x80
+
+	linkInfoVolumeIDAndLocalBasePath = 0x1
+	linkInfoCommonNetworkRelative    = 0x2
+	envVariableDataBlock             = 0xA0000001
+)
+
+type ShortcutInfo struct {
+	Target       string `json:"target"`
+	TargetIsDir  bool   `json:"targetIsDir"`
+	TargetExists bool   `json:"targetExists"`
+	Arguments    string `json:"arguments,omitempty"`
+	WorkingDir   string `json:"workingDir,omitempty"`
+	Description  string `json:"description,omitempty"`
+	IconLocation string `json:"iconLocation,omitempty"`
+}
+
+// 读取字节的辅助类型，越界时记录错误而不是panic
+type lnkReader struct {
+	data []byte
+	err  error
+}
+
+func (r *lnkReader) u16(off int) int {
+	if off < 0 || off+2 > len(r.data) {
+		r.err = errors.New("快捷方式文件格式错误")
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(r.data[off:]))
+}
+
+func (r *lnkReader) u32(off int) int {
+	if off < 0 || off+4 > len(r.data) {
+		r.err = errors.New("快捷方式文件格式错误")
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(r.data[off:]))
+}
+
+// 以NUL结尾的ANSI字符串（系统代码页）
+func (r *lnkReader) ansiString(off int) string {
+	if off < 0 || off >= len(r.data) {
+		r.err = errors.New("快捷方式文件格式错误")
+		return ""
+	}
+	end := off
+	for end < len(r.data) && r.data[end] != 0 {
+		end++
+	}
+	return decodeANSI(r.data[off:end])
+}
+
+// 以NUL结尾的UTF-16字符串
+func (r *lnkReader) unicodeString(off int) string {
+	var chars []uint16
+	for {
+		c := r.u16(off)
+		if r.err != nil || c == 0 {
+			break
+		}
+		chars = append(chars, uint16(c))
+		off += 2
+	}
+	return string(utf16.Decode(chars))
+}
+
+// 展开 %VAR% 形式的环境变量，未定义的保持原样
+func expandWindowsEnv(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "%")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start+1:], "%")
+		if end < 0 {
+			break
+		}
+		name := s[start+1 : start+1+end]
+		b.WriteString(s[:start])
+		if value, ok := os.LookupEnv(name); ok && name != "" {
+			b.WriteString(value)
+		} else {
+			b.WriteString("%" + name + "%")
+		}
+		s = s[start+end+2:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// 解析快捷方式文件内容，lnkPath用于解析相对路径
+func parseShortcut(data []byte, lnkPath string) (*ShortcutInfo, error) {
+	r := &lnkReader{data: data}
+	if r.u32(0) != 0x4C {
+		return nil, errors.New("不是有效的快捷方式文件")
+	}
+	flags := r.u32(0x14)
+	attrs := r.u32(0x18)
+	info := &ShortcutInfo{TargetIsDir: attrs&fileAttributeDirectory != 0}
+
+	pos := 0x4C
+	if flags&slHasTargetIDList != 0 {
+		pos += 2 + r.u16(pos)
+	}
+
+	if flags&slHasLinkInfo != 0 {
+		info.Target = parseLinkInfo(r, pos)
+		pos += r.u32(pos)
+	}
+
+	// StringData：依次为说明、相对路径、起始位置、参数、图标位置
+	readString := func() string {
+		count := r.u16(pos)
+		pos += 2
+		if flags&slIsUnicode != 0 {
+			if pos+count*2 > len(data) {
+				r.err = errors.New("快捷方式文件格式错误")
+				return ""
+			}
+			chars := make([]uint16, count)
+			for i := range chars {
+				chars[i] = binary.LittleEndian.Uint16(data[pos+i*2:])
+			}
+			pos += count * 2
+			return string(utf16.Decode(chars))
+		}
+		if pos+count > len(data) {
+			r.err = errors.New("快捷方式文件格式错误")
+			return ""
+		}
+		s := decodeANSI(data[pos : pos+count])
+		pos += count
+		return s
+	}
+	var relativePath string
+	if flags&slHasName != 0 {
+		info.Description = readString()
+	}
+	if flags&slHasRelativePath != 0 {
+		relativePath = readString()
+	}
+	if flags&slHasWorkingDir != 0 {
+		info.WorkingDir = expandWindowsEnv(readString())
+	}
+	if flags&slHasArguments != 0 {
+		info.Arguments = readString()
+	}
+	if flags&slHasIconLocation != 0 {
+		info.IconLocation = expandWindowsEnv(readString())
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// ExtraData中的环境变量路径，例如 %windir%\notepad.exe
+	if info.Target == "" {
+		for r.err == nil && pos+8 <= len(data) {
+			size := r.u32(pos)
+			if size < 8 {
+				break
+			}
+			if r.u32(pos+4) == envVariableDataBlock && size >= 8+260+520 {
+				target := r.unicodeString(pos + 8 + 260)
+				if target == "" {
+					target = r.ansiString(pos + 8)
+				}
+				info.Target = expandWindowsEnv(target)
+				break
+			}
+			pos += size
+		}
+	}
+
+	if info.Target == "" && relativePath != "" {
+		info.Target = filepath.Clean(filepath.Join(filepath.Dir(lnkPath), relativePath))
+	}
+	if info.Target == "" {
+		return nil, errors.New("快捷方式没有指向文件系统中的路径")
+	}
+	return info, nil
+}
+
+// 从LinkInfo中取出本地路径或网络路径
+func parseLinkInfo(r *lnkReader, p int) string {
+	headerSize := r.u32(p + 4)
+	linkFlags := r.u32(p + 8)
+	suffix := ""
+	if headerSize >= 0x24 && r.u32(p+32) != 0 {
+		suffix = r.unicodeString(p + r.u32(p+32))
+	} else if off := r.u32(p + 24); off != 0 {
+		suffix = r.ansiString(p + off)
+	}
+
+	if linkFlags&linkInfoVolumeIDAndLocalBasePath != 0 {
+		base := ""
+		if headerSize >= 0x24 && r.u32(p+28) != 0 {
+			base = r.unicodeString(p + r.u32(p+28))
+		} else {
+			base = r.ansiString(p + r.u32(p+16))
+		}
+		if r.err == nil && base != "" {
+			return joinLinkPath(base, suffix)
+		}
+	}
+
+	if linkFlags&linkInfoCommonNetworkRelative != 0 {
+		q := p + r.u32(p+20)
+		netNameOffset := r.u32(q + 8)
+		netName := ""
+		if netNameOffset > 0x14 {
+			netName = r.unicodeString(q + r.u32(q+20))
+		} else {
+			netName = r.ansiString(q + netNameOffset)
+		}
+		if r.err == nil && netName != "" {
+			return joinLinkPath(netName, suffix)
+		}
+	}
+	return ""
+}
+
+func joinLinkPath(base, suffix string) string {
+	if suffix == "" {
+		return base
+	}
+	if strings.HasSuffix(base, `\`) {
+		return base + suffix
+	}
+	return base + `\` + suffix
+}
+
+// 读取并解析快捷方式，本地目标会检查是否存在（网络路径可能很慢，视为存在）
+func resolveShortcut(lnkPath string) (*ShortcutInfo, error) {
+	file, err := openPath(lnkPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxShortcutSize))
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseShortcut(data, lnkPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info.TargetExists = true
+	if !strings.HasPrefix(info.Target, `\\`) {
+		if stat, err := statPath(info.Target); err == nil {
+			info.TargetIsDir = stat.IsDir()
+		} else {
+			info.TargetExists = false
+		}
+	}
+	return info, nil
+}
+
+// 将快捷方式信息写入结果（仅处理.lnk文件）
+func applyShortcutInfo(result *SearchResult, path string) {
+	if result.IsDir || !strings.EqualFold(filepath.Ext(path), ".lnk") {
+		return
+	}
+	info, err := resolveShortcut(path)
+	if err != nil {
+		return
+	}
+	result.LinkType = LinkTypeShortcut
+	result.LinkTarget = clientPath(info.Target)
+	result.LinkTargetDir = info.TargetIsDir
+	result.LinkBroken = !info.TargetExists
+}
+
+// 快捷方式API处理器
+func apiShortcutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lnkPath := resolveClientPath(r.URL.Query().Get("path"))
+	if !strings.EqualFold(filepath.Ext(lnkPath), ".lnk") {
+		http.Error(w, "不是快捷方式文件", http.StatusBadRequest)
+		return
+	}
+
+	info, err := resolveShortcut(lnkPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "解析快捷方式失败: "+err.Error(), http.StatusUnprocessableEntity)
+		}
+		return
+	}
+	info.Target = clientPath(info.Target)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// 键盘快捷键清单
+//
+// 页面按这份清单绑定按键，以后的电视遥控器或纯键盘界面也使用同一份定义，
+// 保证搜索和浏览视图中的操作一致。按键名称使用KeyboardEvent.key的取值。
+
+type Shortcut struct {
+	Keys        []string `json:"keys"`
+	Action      string   `json:"action"`
+	Description string   `json:"description"`
+	Scope       string   `json:"scope"` // results: 结果列表中可用；global: 任何时候可用
+}
+
+var shortcutManifest = []Shortcut{
+	{Keys: []string{"ArrowDown", "j"}, Action: "next", Description: "下一项", Scope: "results"},
+	{Keys: []string{"ArrowUp", "k"}, Action: "prev", Description: "上一项", Scope: "results"},
+	{Keys: []string{"Home"}, Action: "first", Description: "第一项", Scope: "results"},
+	{Keys: []string{"End"}, Action: "last", Description: "最后一项", Scope: "results"},
+	{Keys: []string{"Enter"}, Action: "open", Description: "打开选中项", Scope: "results"},
+	{Keys: []string{" "}, Action: "toggleSelect", Description: "勾选/取消勾选选中项", Scope: "results"},
+	{Keys: []string{"Backspace"}, Action: "parent", Description: "返回上级文件夹", Scope: "results"},
+	{Keys: []string{"ArrowRight", "PageDown"}, Action: "nextPage", Description: "下一页", Scope: "results"},
+	{Keys: []string{"ArrowLeft", "PageUp"}, Action: "prevPage", Description: "上一页", Scope: "results"},
+	{Keys: []string{"/"}, Action: "focusSearch", Description: "聚焦搜索框", Scope: "global"},
+	{Keys: []string{"Escape"}, Action: "blur", Description: "离开输入框，回到结果列表", Scope: "global"},
+}
+
+// 输入其他可打印字符时按名称前缀跳转（type-ahead），两次按键间隔超过此值时重新开始
+const typeAheadResetMs = 1000
+
+// 快捷键清单API处理器
+func apiShortcutsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shortcuts":        shortcutManifest,
+		"typeAhead":        true,
+		"typeAheadResetMs": typeAheadResetMs,
+	})
+}
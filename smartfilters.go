@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 智能筛选
+//
+// 常用的查询预设（截图、相机照片、安装包、文档、大视频），只根据文件名、路径、扩展名、大小和日期，
+// 不分析文件内容。查询模板中的{days}等参数有默认值，请求时可以用同名参数覆盖（只接受正整数）：
+//   GET /api/smart-filters                       所有筛选，query为按默认参数展开的查询
+//   GET /api/smart-filters?id=documents&days=30  一个筛选，按给定参数展开
+// 首页搜索框下方显示为一排按钮，点击后按该查询和排序搜索。
+// data\config.json 中的smartFilters与内置筛选按id合并：同id的覆盖内置筛选，"hidden": true 隐藏。
+// 用户自己保存的筛选（/api/filters）排在最后，带有"user": true。
+
+const maxSmartFilterParam = 1000000
+
+type SmartFilter struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Icon        string         `json:"icon,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Template    string         `json:"template"`           // Everything查询，{参数名}替换为参数值
+	Params      map[string]int `json:"params,omitempty"`   // 参数默认值
+	Sort        string         `json:"sort,omitempty"`     // 首页排序：name、size、date等
+	Order       string         `json:"order,omitempty"`    // asc、desc
+	ViewMode    string         `json:"viewMode,omitempty"` // 首页视图：list、compact，为空时不改变
+	Hidden      bool           `json:"hidden,omitempty"`
+	User        bool           `json:"user,omitempty"` // 用户自定义的筛选（见filters.go），由服务器填写
+	Query       string         `json:"query"`          // 展开后的查询，由服务器填写
+}
+
+var builtinSmartFilters = []SmartFilter{
+	{
+		ID: "screenshots", Name: "截图", Icon: "🖼️",
+		Description: "文件名或所在文件夹表明是截图的图片",
+		Template:    `file: ext:png;jpg;jpeg;webp <screenshot|截图|屏幕截图|"screen shot"|snipaste|\screenshots\> dm:last{days}days`,
+		Params:      map[string]int{"days": 365},
+		Sort:        "date", Order: "desc",
+	},
+	{
+		ID: "camera", Name: "相机照片", Icon: "📷",
+		Description: "相机和手机命名规则的照片和视频（IMG_、DSC、PXL_等）以及DCIM、相机上传文件夹中的文件",
+		Template:    `file: ext:jpg;jpeg;heic;png;dng;mp4;mov < regex:"^(IMG|DSC|DSCN|DSCF|PXL|MVIMG|VID)[_-]?\d" | \DCIM\ | "\Camera Uploads\" | \相机胶卷\ > dm:last{days}days`,
+		Params:      map[string]int{"days": 3650},
+		Sort:        "date", Order: "desc",
+	},
+	{
+		ID: "installers", Name: "安装包", Icon: "📦",
+		Description: "安装程序和软件包，不含已安装的程序目录",
+		Template:    `file: < ext:msi;msix;msixbundle;appx;dmg;pkg;deb;rpm | ext:exe <setup|install|安装> > !\Windows\ !"\Program Files" !"\Program Files (x86)" !\AppData\ size:>{minSizeMB}mb`,
+		Params:      map[string]int{"minSizeMB": 1},
+		Sort:        "size", Order: "desc",
+	},
+	{
+		ID: "documents", Name: "最近的文档", Icon: "📄",
+		Description: "最近修改的Office、PDF、WPS和文本文档",
+		Template:    `file: ext:doc;docx;xls;xlsx;ppt;pptx;pdf;odt;ods;odp;rtf;wps;et;dps;md;txt !\AppData\ !~$ dm:last{days}days`,
+		Params:      map[string]int{"days": 90},
+		Sort:        "date", Order: "desc",
+	},
+	{
+		ID: "large-videos", Name: "大视频", Icon: "🎬",
+		Description: "超过指定大小的视频文件",
+		Template:    `file: ext:mp4;mkv;avi;mov;wmv;flv;webm;ts;m2ts size:>{minSizeGB}gb`,
+		Params:      map[string]int{"minSizeGB": 1},
+		Sort:        "size", Order: "desc",
+	},
+}
+
+// 内置和配置的筛选，按内置顺序排列，配置中新增的排在后面
+func smartFilters() []SmartFilter {
+	filters := make([]SmartFilter, 0, len(builtinSmartFilters)+len(serverConfig.SmartFilters))
+	index := make(map[string]int)
+	for _, f := range append(append([]SmartFilter{}, builtinSmartFilters...), serverConfig.SmartFilters...) {
+		if i, ok := index[f.ID]; ok {
+			filters[i] = f
+			continue
+		}
+		index[f.ID] = len(filters)
+		filters = append(filters, f)
+	}
+
+	visible := filters[:0]
+	for _, f := range filters {
+		if !f.Hidden && f.Template != "" {
+			visible = append(visible, f)
+		}
+	}
+	return visible
+}
+
+// 按参数展开查询模板，overrides中没有的参数使用默认值
+func expandSmartFilter(f SmartFilter, overrides map[string]int) string {
+	names := make([]string, 0, len(f.Params))
+	for name := range f.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	query := f.Template
+	for _, name := range names {
+		value := f.Params[name]
+		if v, ok := overrides[name]; ok {
+			value = v
+		}
+		query = strings.ReplaceAll(query, "{"+name+"}", strconv.Itoa(value))
+	}
+	return query
+}
+
+// 从请求中读取筛选参数的覆盖值
+func smartFilterOverrides(r *http.Request, f SmartFilter) (map[string]int, error) {
+	overrides := make(map[string]int)
+	for name := range f.Params {
+		s := r.URL.Query().Get(name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > maxSmartFilterParam {
+			return nil, fmt.Errorf("%s参数应为1-%d之间的整数", name, maxSmartFilterParam)
+		}
+		overrides[name] = v
+	}
+	return overrides, nil
+}
+
+// 智能筛选API处理器
+func apiSmartFiltersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filters := append(smartFilters(), getUserFilters(getSessionID(w, r))...)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if id := r.URL.Query().Get("id"); id != "" {
+		for _, f := range filters {
+			if f.ID != id {
+				continue
+			}
+			overrides, err := smartFilterOverrides(r, f)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.Query = expandSmartFilter(f, overrides)
+			json.NewEncoder(w).Encode(f)
+			return
+		}
+		http.Error(w, "筛选不存在: "+id, http.StatusNotFound)
+		return
+	}
+
+	for i := range filters {
+		filters[i].Query = expandSmartFilter(filters[i], nil)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"filters": filters,
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSmartFilters(t *testing.T) {
+	serverConfig.SmartFilters = []SmartFilter{
+		{ID: "installers", Hidden: true},
+		{ID: "large-videos", Name: "超大视频", Template: "ext:mkv size:>{minSizeGB}gb", Params: map[string]int{"minSizeGB": 10}},
+		{ID: "raw", Name: "RAW照片", Template: "ext:cr2;nef;arw"},
+	}
+	defer func() { serverConfig.SmartFilters = nil }()
+
+	rec := httptest.NewRecorder()
+	apiSmartFiltersHandler(rec, httptest.NewRequest(http.MethodGet, "/api/smart-filters", nil))
+	var list struct{ Filters []SmartFilter }
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	queries := make(map[string]string)
+	for _, f := range list.Filters {
+		ids = append(ids, f.ID)
+		queries[f.ID] = f.Query
+	}
+	if got, want := strings.Join(ids, ","), "screenshots,camera,documents,large-videos,raw"; got != want {
+		t.Errorf("filters = %s, want %s", got, want)
+	}
+	if got := queries["large-videos"]; got != "ext:mkv size:>10gb" {
+		t.Errorf("large-videos query = %q", got)
+	}
+	if strings.Contains(queries["documents"], "{") {
+		t.Errorf("documents query not expanded: %q", queries["documents"])
+	}
+
+	rec = httptest.NewRecorder()
+	apiSmartFiltersHandler(rec, httptest.NewRequest(http.MethodGet, "/api/smart-filters?id=documents&days=30", nil))
+	var one SmartFilter
+	if err := json.NewDecoder(rec.Body).Decode(&one); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(one.Query, "dm:last30days") {
+		t.Errorf("documents?days=30 query = %q", one.Query)
+	}
+
+	for _, target := range []string{"/api/smart-filters?id=documents&days=-1", "/api/smart-filters?id=documents&days=1%20|%20x"} {
+		rec = httptest.NewRecorder()
+		apiSmartFiltersHandler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	apiSmartFiltersHandler(rec, httptest.NewRequest(http.MethodGet, "/api/smart-filters?id=installers", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("hidden filter: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 搜索结果快照
+//
+// GET    /api/snapshots                        列出快照
+// POST   /api/snapshots {"name":"下载","query":"D:\\Downloads\\"}  执行查询并保存路径列表
+// GET    /api/snapshots/diff?name=下载[&update=1]  重新执行同一查询，返回新增和消失的文件；update=1时用新结果替换快照
+// DELETE /api/snapshots?name=下载
+//
+// 快照的名称、查询和时间保存在snapshots.json，路径列表较大，每个快照单独保存在snapshots目录下。
+
+const (
+	snapshotsFileName = "snapshots.json"
+	snapshotsDirName  = "snapshots"
+	maxSnapshotDiff   = 1000 // 差异中列出的路径数上限，超出时只返回数量
+)
+
+type Snapshot struct {
+	Name    string `json:"name"`
+	Query   string `json:"query"`
+	Count   int    `json:"count"`
+	Created string `json:"created"`
+	File    string `json:"file"` // snapshots目录下保存路径列表的文件
+}
+
+type SnapshotDiff struct {
+	Name         string   `json:"name"`
+	Query        string   `json:"query"`
+	SnapshotTime string   `json:"snapshotTime"`
+	CheckedAt    string   `json:"checkedAt"`
+	Count        int      `json:"count"`
+	Added        []string `json:"added"`
+	Removed      []string `json:"removed"`
+	AddedCount   int      `json:"addedCount"`
+	RemovedCount int      `json:"removedCount"`
+	Truncated    bool     `json:"truncated,omitempty"`
+	Updated      bool     `json:"updated,omitempty"`
+}
+
+var (
+	snapshots       = make(map[string]Snapshot) // 小写名称 → 快照
+	snapshotsMutex  sync.Mutex
+	snapshotsLoaded sync.Once
+)
+
+func ensureSnapshotsLoaded() {
+	snapshotsLoaded.Do(func() {
+		snapshotsMutex.Lock()
+		defer snapshotsMutex.Unlock()
+		if err := loadJSONFile(snapshotsFileName, &snapshots); err != nil {
+			log.Printf("加载快照失败: %v", err)
+		}
+		if snapshots == nil {
+			snapshots = make(map[string]Snapshot)
+		}
+	})
+}
+
+// 保存快照的路径列表并更新索引；调用方需持有snapshotsMutex
+func storeSnapshot(snap Snapshot, paths []string) (Snapshot, error) {
+	if snap.File == "" {
+		snap.File = newRandomID(8) + ".json"
+	}
+	if err := os.MkdirAll(filepath.Join(getDataDir(), snapshotsDirName), 0755); err != nil {
+		return snap, err
+	}
+	if err := saveJSONFile(filepath.Join(snapshotsDirName, snap.File), paths); err != nil {
+		return snap, err
+	}
+	snap.Count = len(paths)
+	snap.Created = time.Now().Format("2006-01-02 15:04:05")
+	snapshots[strings.ToLower(snap.Name)] = snap
+	return snap, saveJSONFile(snapshotsFileName, snapshots)
+}
+
+// 读取快照的路径列表
+func loadSnapshotPaths(snap Snapshot) ([]string, error) {
+	var paths []string
+	err := loadJSONFile(filepath.Join(snapshotsDirName, snap.File), &paths)
+	return paths, err
+}
+
+// 比较两个路径列表，返回新增和消失的路径（不区分大小写，按名称排序）
+func diffPaths(before, after []string) (added, removed []string) {
+	old := make(map[string]bool, len(before))
+	for _, path := range before {
+		old[strings.ToLower(path)] = true
+	}
+	current := make(map[string]bool, len(after))
+	for _, path := range after {
+		key := strings.ToLower(path)
+		current[key] = true
+		if !old[key] {
+			added = append(added, path)
+		}
+	}
+	for _, path := range before {
+		if !current[strings.ToLower(path)] {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// 转换为客户端路径，超过上限时截断
+func snapshotDiffList(paths []string) ([]string, bool) {
+	truncated := len(paths) > maxSnapshotDiff
+	if truncated {
+		paths = paths[:maxSnapshotDiff]
+	}
+	list := make([]string, len(paths))
+	for i, path := range paths {
+		list[i] = clientPath(path)
+	}
+	return list, truncated
+}
+
+// 快照API处理器
+func apiSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	ensureSnapshotsLoaded()
+
+	switch r.Method {
+	case http.MethodGet:
+		snapshotsMutex.Lock()
+		list := make([]Snapshot, 0, len(snapshots))
+		for _, snap := range snapshots {
+			list = append(list, snap)
+		}
+		snapshotsMutex.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"snapshots": list,
+			"count":     len(list),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Name  string `json:"name"`
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" || req.Query == "" {
+			http.Error(w, "请求格式错误，需要name和query参数", http.StatusBadRequest)
+			return
+		}
+
+		paths, err := runEverythingQuery(r.Context(), req.Query)
+		if err != nil {
+			log.Printf("创建快照失败: %s, 错误: %v", req.Query, err)
+			http.Error(w, "搜索失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		snapshotsMutex.Lock()
+		snap := snapshots[strings.ToLower(req.Name)]
+		snap.Name = req.Name
+		snap.Query = req.Query
+		snap, err = storeSnapshot(snap, paths)
+		snapshotsMutex.Unlock()
+		if err != nil {
+			log.Printf("保存快照失败: %v", err)
+			http.Error(w, "保存快照失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("保存快照: %s（%s），%d个结果，来源IP: %s", snap.Name, snap.Query, snap.Count, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(snap)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		snapshotsMutex.Lock()
+		snap, ok := snapshots[strings.ToLower(name)]
+		if ok {
+			delete(snapshots, strings.ToLower(name))
+			if err := saveJSONFile(snapshotsFileName, snapshots); err != nil {
+				log.Printf("保存快照索引失败: %v", err)
+			}
+			os.Remove(filepath.Join(getDataDir(), snapshotsDirName, snap.File))
+		}
+		snapshotsMutex.Unlock()
+		if !ok {
+			http.Error(w, "快照不存在", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("删除快照: %s，来源IP: %s", snap.Name, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 快照差异API处理器
+func apiSnapshotDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	ensureSnapshotsLoaded()
+
+	name := r.URL.Query().Get("name")
+	snapshotsMutex.Lock()
+	snap, ok := snapshots[strings.ToLower(name)]
+	snapshotsMutex.Unlock()
+	if !ok {
+		http.Error(w, "快照不存在", http.StatusNotFound)
+		return
+	}
+
+	before, err := loadSnapshotPaths(snap)
+	if err != nil {
+		log.Printf("读取快照失败: %s, 错误: %v", snap.Name, err)
+		http.Error(w, "读取快照失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	after, err := runEverythingQuery(r.Context(), snap.Query)
+	if err != nil {
+		log.Printf("快照比较搜索失败: %s, 错误: %v", snap.Query, err)
+		http.Error(w, "搜索失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	added, removed := diffPaths(before, after)
+	diff := SnapshotDiff{
+		Name:         snap.Name,
+		Query:        snap.Query,
+		SnapshotTime: snap.Created,
+		CheckedAt:    time.Now().Format("2006-01-02 15:04:05"),
+		Count:        len(after),
+		AddedCount:   len(added),
+		RemovedCount: len(removed),
+	}
+	var truncatedAdded, truncatedRemoved bool
+	diff.Added, truncatedAdded = snapshotDiffList(added)
+	diff.Removed, truncatedRemoved = snapshotDiffList(removed)
+	diff.Truncated = truncatedAdded || truncatedRemoved
+
+	if r.URL.Query().Get("update") == "1" {
+		snapshotsMutex.Lock()
+		_, err = storeSnapshot(snap, after)
+		snapshotsMutex.Unlock()
+		if err != nil {
+			log.Printf("更新快照失败: %v", err)
+		} else {
+			diff.Updated = true
+		}
+	}
+
+	log.Printf("快照比较: %s，新增%d个，消失%d个，来源IP: %s", snap.Name, diff.AddedCount, diff.RemovedCount, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(diff)
+}
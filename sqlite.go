@@ -0,0 +1,799 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// SQLite数据库浏览（只读）
+//
+//   GET /api/sqlite?path=数据库                           表、行数和列名
+//   GET /api/sqlite?path=数据库&table=表名&page=1&pageSize=50   分页读取表的内容
+// 按SQLite文件格式直接读取B树页面，不执行SQL，不写入也不加锁，其他程序正在使用的数据库也能打开。
+// 因此不读取-wal文件中尚未合并的修改（响应中的walPending提示），WITHOUT ROWID表只统计行数。
+// .db、.sqlite、.sqlite3文件的预览页面（见preview.go）调用这个接口。
+
+const (
+	maxSQLiteCellText = 4096 // 文本超过这个长度时截断
+	maxSQLiteCellBlob = 32   // BLOB只显示开头的字节
+	maxSQLiteDepth    = 64   // B树的最大深度，防止损坏的文件造成死循环
+)
+
+var (
+	errNotSQLite       = errors.New("不是SQLite数据库")
+	errSQLiteCorrupt   = errors.New("数据库文件已损坏")
+	errSQLiteNoTable   = errors.New("没有这个表")
+	errSQLiteNoRowid   = errors.New("不支持读取WITHOUT ROWID表的内容")
+	errSQLiteNoContent = errors.New("虚拟表和视图没有可读取的内容")
+)
+
+type sqliteFile struct {
+	r         io.ReaderAt
+	pageSize  int
+	usable    int // 页面大小减去保留字节
+	pageCount int
+	encoding  int // 1 UTF-8，2 UTF-16LE，3 UTF-16BE
+}
+
+type SQLiteTable struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"` // table或view
+	Columns      []string `json:"columns"`
+	Rows         int64    `json:"rows"`
+	WithoutRowid bool     `json:"withoutRowid,omitempty"`
+	Virtual      bool     `json:"virtual,omitempty"`
+	SQL          string   `json:"sql,omitempty"`
+
+	rootPage   int
+	rowidAlias int // INTEGER PRIMARY KEY列的位置，记录中该列为NULL，值为rowid；没有时为-1
+}
+
+func openSQLiteFile(r io.ReaderAt, size int64) (*sqliteFile, error) {
+	header := make([]byte, 100)
+	if _, err := r.ReadAt(header, 0); err != nil || !bytes.HasPrefix(header, []byte("SQLite format 3\x00")) {
+		return nil, errNotSQLite
+	}
+	db := &sqliteFile{r: r, pageSize: int(binary.BigEndian.Uint16(header[16:]))}
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	if db.pageSize < 512 || db.pageSize&(db.pageSize-1) != 0 {
+		return nil, errSQLiteCorrupt
+	}
+	db.usable = db.pageSize - int(header[20])
+	db.encoding = int(binary.BigEndian.Uint32(header[56:]))
+	// 文件头中的页数只在两个修改计数器一致时有效，否则按文件大小计算
+	db.pageCount = int(binary.BigEndian.Uint32(header[28:]))
+	if db.pageCount == 0 || !bytes.Equal(header[24:28], header[92:96]) {
+		db.pageCount = int(size / int64(db.pageSize))
+	}
+	return db, nil
+}
+
+func (db *sqliteFile) page(n int) ([]byte, error) {
+	if n < 1 || n > db.pageCount {
+		return nil, errSQLiteCorrupt
+	}
+	buf := make([]byte, db.pageSize)
+	if _, err := db.r.ReadAt(buf, int64(n-1)*int64(db.pageSize)); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// SQLite的变长整数，返回值和占用的字节数
+func sqliteVarint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return int64(v<<8 | uint64(b[i])), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7F)
+		if b[i] < 0x80 {
+			return int64(v), i + 1
+		}
+	}
+	return int64(v), len(b)
+}
+
+// B树页面：类型、单元格偏移和最右子页面
+type sqlitePage struct {
+	data      []byte
+	kind      byte // 2索引内部 5表内部 10索引叶子 13表叶子
+	cells     []int
+	rightmost int
+}
+
+func (db *sqliteFile) btreePage(n int) (*sqlitePage, error) {
+	data, err := db.page(n)
+	if err != nil {
+		return nil, err
+	}
+	offset := 0
+	if n == 1 {
+		offset = 100 // 第一页开头是文件头
+	}
+	p := &sqlitePage{data: data, kind: data[offset]}
+	headerSize := 8
+	switch p.kind {
+	case 2, 5:
+		headerSize = 12
+		p.rightmost = int(binary.BigEndian.Uint32(data[offset+8:]))
+	case 10, 13:
+	default:
+		return nil, errSQLiteCorrupt
+	}
+	count := int(binary.BigEndian.Uint16(data[offset+3:]))
+	start := offset + headerSize
+	if start+count*2 > len(data) {
+		return nil, errSQLiteCorrupt
+	}
+	p.cells = make([]int, count)
+	for i := range p.cells {
+		p.cells[i] = int(binary.BigEndian.Uint16(data[start+i*2:]))
+		if p.cells[i] >= len(data) {
+			return nil, errSQLiteCorrupt
+		}
+	}
+	return p, nil
+}
+
+func (p *sqlitePage) leaf() bool { return p.kind == 10 || p.kind == 13 }
+
+// 内部页面单元格的左子页面
+func (p *sqlitePage) child(i int) int {
+	return int(binary.BigEndian.Uint32(p.data[p.cells[i]:]))
+}
+
+// 读取表B树叶子单元格的负载，超出页面的部分在溢出页链表中
+func (db *sqliteFile) payload(p *sqlitePage, offset int, size int64) ([]byte, error) {
+	u := db.usable
+	maxLocal := u - 35
+	minLocal := (u-12)*32/255 - 23
+	if size < 0 || size > 1<<30 {
+		return nil, errSQLiteCorrupt
+	}
+	local := int(size)
+	if local > maxLocal {
+		local = minLocal + int((size-int64(minLocal))%int64(u-4))
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if offset+local > len(p.data) {
+		return nil, errSQLiteCorrupt
+	}
+	data := append([]byte(nil), p.data[offset:offset+local]...)
+	if int64(local) == size {
+		return data, nil
+	}
+	if offset+local+4 > len(p.data) {
+		return nil, errSQLiteCorrupt
+	}
+	next := int(binary.BigEndian.Uint32(p.data[offset+local:]))
+	for hops := 0; int64(len(data)) < size; hops++ {
+		if next == 0 || hops > db.pageCount {
+			return nil, errSQLiteCorrupt
+		}
+		overflow, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = int(binary.BigEndian.Uint32(overflow))
+		n := min(int64(u-4), size-int64(len(data)))
+		data = append(data, overflow[4:4+n]...)
+	}
+	return data, nil
+}
+
+// 按顺序遍历表B树的叶子单元格，skip为跳过的行数（整页跳过时不读取单元格），fn返回false时停止
+func (db *sqliteFile) walkTable(ctx context.Context, root int, skip int64, fn func(rowid int64, payload []byte) bool) error {
+	var walk func(n, depth int) (bool, error)
+	walk = func(n, depth int) (bool, error) {
+		if depth > maxSQLiteDepth {
+			return false, errSQLiteCorrupt
+		}
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		p, err := db.btreePage(n)
+		if err != nil {
+			return false, err
+		}
+		switch p.kind {
+		case 5:
+			for i := range p.cells {
+				if ok, err := walk(p.child(i), depth+1); !ok || err != nil {
+					return ok, err
+				}
+			}
+			return walk(p.rightmost, depth+1)
+		case 13:
+			if skip >= int64(len(p.cells)) {
+				skip -= int64(len(p.cells))
+				return true, nil
+			}
+			for _, offset := range p.cells[skip:] {
+				size, n1 := sqliteVarint(p.data[offset:])
+				rowid, n2 := sqliteVarint(p.data[offset+n1:])
+				data, err := db.payload(p, offset+n1+n2, size)
+				if err != nil {
+					return false, err
+				}
+				if !fn(rowid, data) {
+					return false, nil
+				}
+			}
+			skip = 0
+			return true, nil
+		}
+		return false, errSQLiteCorrupt
+	}
+	_, err := walk(root, 0)
+	return err
+}
+
+// 表的行数：表B树只读取叶子页面的单元格数，索引B树要数内部页面的单元格
+func (db *sqliteFile) countRows(ctx context.Context, root int) (int64, error) {
+	var count int64
+	var walk func(n, depth int) error
+	walk = func(n, depth int) error {
+		if depth > maxSQLiteDepth {
+			return errSQLiteCorrupt
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p, err := db.btreePage(n)
+		if err != nil {
+			return err
+		}
+		if p.leaf() {
+			count += int64(len(p.cells))
+			return nil
+		}
+		if p.kind == 2 {
+			count += int64(len(p.cells))
+		}
+		for i := range p.cells {
+			if err := walk(p.child(i), depth+1); err != nil {
+				return err
+			}
+		}
+		return walk(p.rightmost, depth+1)
+	}
+	err := walk(root, 0)
+	return count, err
+}
+
+// 解码记录，返回各列的值（nil、int64、float64、string、[]byte）
+func (db *sqliteFile) record(data []byte) ([]interface{}, error) {
+	headerSize, n := sqliteVarint(data)
+	if headerSize < int64(n) || headerSize > int64(len(data)) {
+		return nil, errSQLiteCorrupt
+	}
+	var types []int64
+	for pos := n; pos < int(headerSize); {
+		t, n := sqliteVarint(data[pos:int(headerSize)])
+		types = append(types, t)
+		pos += n
+	}
+	values := make([]interface{}, len(types))
+	body := data[headerSize:]
+	for i, t := range types {
+		var size int
+		switch {
+		case t >= 1 && t <= 4:
+			size = int(t)
+		case t == 5:
+			size = 6
+		case t == 6 || t == 7:
+			size = 8
+		case t >= 12:
+			size = int((t - 12) / 2)
+		}
+		if size > len(body) {
+			return nil, errSQLiteCorrupt
+		}
+		field := body[:size]
+		body = body[size:]
+		switch {
+		case t == 0:
+			values[i] = nil
+		case t >= 1 && t <= 6:
+			// 大端有符号整数
+			v := int64(int8(field[0]))
+			for _, b := range field[1:] {
+				v = v<<8 | int64(b)
+			}
+			values[i] = v
+		case t == 7:
+			values[i] = math.Float64frombits(binary.BigEndian.Uint64(field))
+		case t == 8, t == 9:
+			values[i] = t - 8
+		case t >= 12 && t%2 == 0:
+			values[i] = field
+		case t >= 13:
+			values[i] = db.text(field)
+		default:
+			return nil, errSQLiteCorrupt
+		}
+	}
+	return values, nil
+}
+
+func (db *sqliteFile) text(b []byte) string {
+	if db.encoding != 2 && db.encoding != 3 {
+		return strings.ToValidUTF8(string(b), "�")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if db.encoding == 3 {
+		order = binary.BigEndian
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = order.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// 按CREATE TABLE语句取得列名，表级约束（PRIMARY KEY(...)等）不是列
+func parseSQLiteColumns(sql string) (columns []string, rowidAlias int, withoutRowid bool) {
+	rowidAlias = -1
+	open, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if open < 0 || end < open {
+		return nil, -1, false
+	}
+	withoutRowid = strings.Contains(strings.ToUpper(sql[end:]), "WITHOUT ROWID")
+
+	var defs []string
+	depth, start, quote := 0, open+1, byte(0)
+	for i := open + 1; i < end; i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, sql[start:i])
+			start = i + 1
+		}
+	}
+	defs = append(defs, sql[start:end])
+
+	for _, def := range defs {
+		def = strings.TrimSpace(def)
+		keyword := strings.ToUpper(def)
+		if i := strings.IndexFunc(keyword, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' }); i >= 0 {
+			keyword = keyword[:i]
+		}
+		switch keyword {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		if def == "" {
+			continue
+		}
+		name, rest := def, ""
+		if q := def[0]; q == '"' || q == '`' || q == '[' {
+			closing := q
+			if q == '[' {
+				closing = ']'
+			}
+			if i := strings.IndexByte(def[1:], closing); i >= 0 {
+				name, rest = def[1:i+1], def[i+2:]
+			}
+		} else if i := strings.IndexAny(def, " \t\r\n"); i >= 0 {
+			name, rest = def[:i], def[i:]
+		}
+		// 类型为INTEGER的主键列是rowid的别名
+		fields := strings.Fields(strings.ToUpper(rest))
+		if len(fields) > 0 && fields[0] == "INTEGER" && strings.Contains(strings.Join(fields, " "), "PRIMARY KEY") && !withoutRowid {
+			rowidAlias = len(columns)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rowidAlias, withoutRowid
+}
+
+// 读取sqlite_master中的表和视图
+func (db *sqliteFile) tables(ctx context.Context) ([]SQLiteTable, error) {
+	var tables []SQLiteTable
+	var recordErr error
+	err := db.walkTable(ctx, 1, 0, func(_ int64, payload []byte) bool {
+		values, err := db.record(payload)
+		if err != nil {
+			recordErr = err
+			return false
+		}
+		if len(values) < 5 {
+			return true
+		}
+		kind, _ := values[0].(string)
+		if kind != "table" && kind != "view" {
+			return true
+		}
+		t := SQLiteTable{Type: kind, rowidAlias: -1}
+		t.Name, _ = values[1].(string)
+		root, _ := values[3].(int64)
+		t.rootPage = int(root)
+		t.SQL, _ = values[4].(string)
+		t.Columns, t.rowidAlias, t.WithoutRowid = parseSQLiteColumns(t.SQL)
+		t.Virtual = kind == "table" && strings.HasPrefix(strings.ToUpper(strings.TrimSpace(t.SQL)), "CREATE VIRTUAL")
+		if t.Columns == nil {
+			t.Columns = []string{}
+		}
+		tables = append(tables, t)
+		return true
+	})
+	if err == nil {
+		err = recordErr
+	}
+	return tables, err
+}
+
+// JSON中显示的值：超过2^53的整数为字符串，BLOB显示大小和开头的字节
+func sqliteDisplayValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64:
+		if v > 1<<53 || v < -(1<<53) {
+			return fmt.Sprint(v)
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Sprint(v)
+		}
+	case string:
+		if len(v) > maxSQLiteCellText {
+			cut := maxSQLiteCellText
+			for cut > 0 && !utf8.RuneStart(v[cut]) {
+				cut--
+			}
+			return v[:cut] + "…"
+		}
+	case []byte:
+		s := fmt.Sprintf("[BLOB %d字节] %s", len(v), hex.EncodeToString(v[:min(len(v), maxSQLiteCellBlob)]))
+		if len(v) > maxSQLiteCellBlob {
+			s += "…"
+		}
+		return s
+	}
+	return v
+}
+
+type SQLiteRowsResponse struct {
+	Path       string          `json:"path"`
+	Table      string          `json:"table"`
+	Columns    []string        `json:"columns"`
+	Rows       [][]interface{} `json:"rows"`
+	Rowids     []int64         `json:"rowids"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"pageSize"`
+	TotalRows  int64           `json:"totalRows"`
+	TotalPages int64           `json:"totalPages"`
+}
+
+// 读取表的一页内容
+func (db *sqliteFile) tableRows(ctx context.Context, t SQLiteTable, page, pageSize int) (*SQLiteRowsResponse, error) {
+	switch {
+	case t.Virtual || t.Type != "table" || t.rootPage == 0:
+		return nil, errSQLiteNoContent
+	case t.WithoutRowid:
+		return nil, errSQLiteNoRowid
+	}
+	total, err := db.countRows(ctx, t.rootPage)
+	if err != nil {
+		return nil, err
+	}
+	resp := &SQLiteRowsResponse{
+		Table: t.Name, Columns: t.Columns, Rows: [][]interface{}{}, Rowids: []int64{},
+		Page: page, PageSize: pageSize, TotalRows: total,
+		TotalPages: (total + int64(pageSize) - 1) / int64(pageSize),
+	}
+	var recordErr error
+	err = db.walkTable(ctx, t.rootPage, int64(page-1)*int64(pageSize), func(rowid int64, payload []byte) bool {
+		values, err := db.record(payload)
+		if err != nil {
+			recordErr = err
+			return false
+		}
+		// ALTER TABLE ADD COLUMN之前写入的行缺少后面的列
+		row := make([]interface{}, max(len(t.Columns), len(values)))
+		for i, v := range values {
+			row[i] = sqliteDisplayValue(v)
+		}
+		if t.rowidAlias >= 0 && t.rowidAlias < len(row) && row[t.rowidAlias] == nil {
+			row[t.rowidAlias] = rowid
+		}
+		resp.Rows = append(resp.Rows, row)
+		resp.Rowids = append(resp.Rowids, rowid)
+		return len(resp.Rows) < pageSize
+	})
+	if err == nil {
+		err = recordErr
+	}
+	return resp, err
+}
+
+// SQLite数据库浏览API处理器
+func apiSQLiteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	clientSrc := r.URL.Query().Get("path")
+	if clientSrc == "" {
+		http.Error(w, "path参数不能为空", http.StatusBadRequest)
+		return
+	}
+	if remoteEverythingEnabled() {
+		http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+		return
+	}
+	path := resolveClientPath(clientSrc)
+	file, err := openPath(path)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if os.IsNotExist(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, "打开数据库失败: "+err.Error(), status)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "不能打开文件夹", http.StatusBadRequest)
+		return
+	}
+
+	db, err := openSQLiteFile(file, info.Size())
+	var tables []SQLiteTable
+	if err == nil {
+		tables, err = db.tables(r.Context())
+	}
+	if err != nil {
+		sqliteError(w, r, path, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	name := r.URL.Query().Get("table")
+	if name == "" {
+		for i := range tables {
+			if tables[i].Type == "table" && !tables[i].Virtual && tables[i].rootPage > 0 {
+				if tables[i].Rows, err = db.countRows(r.Context(), tables[i].rootPage); err != nil {
+					sqliteError(w, r, path, err)
+					return
+				}
+			}
+		}
+		wal, _ := statPath(path + "-wal")
+		log.Printf("SQLite数据库: %s, %d个表, 来源IP: %s", path, len(tables), r.RemoteAddr)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":       clientPath(path),
+			"pageSize":   db.pageSize,
+			"pageCount":  db.pageCount,
+			"tables":     tables,
+			"count":      len(tables),
+			"walPending": wal != nil && wal.Size() > 0,
+		})
+		return
+	}
+
+	for _, t := range tables {
+		if t.Name != name {
+			continue
+		}
+		page, pageSize := parsePageParams(r)
+		resp, err := db.tableRows(r.Context(), t, page, pageSize)
+		if err != nil {
+			sqliteError(w, r, path, err)
+			return
+		}
+		resp.Path = clientPath(path)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	sqliteError(w, r, path, errSQLiteNoTable)
+}
+
+func sqliteError(w http.ResponseWriter, r *http.Request, path string, err error) {
+	if r.Context().Err() != nil {
+		return
+	}
+	w.Header().Del("Content-Type")
+	switch {
+	case errors.Is(err, errNotSQLite), errors.Is(err, errSQLiteCorrupt):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, errSQLiteNoTable):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errSQLiteNoRowid), errors.Is(err, errSQLiteNoContent):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		log.Printf("读取SQLite数据库失败: %s, 错误: %v", path, err)
+		http.Error(w, "读取数据库失败: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type sqlitePreviewProvider struct{ previewExtensions }
+
+func init() {
+	registerPreviewProvider(sqlitePreviewProvider{previewExtensions{".db", ".sqlite", ".sqlite3"}})
+}
+
+func (sqlitePreviewProvider) Name() string { return "sqlite" }
+
+// 预览页面，表和内容由页面通过/api/sqlite读取
+func (sqlitePreviewProvider) Render(ctx context.Context, path string) (*Preview, error) {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	fileName := filepath.Base(path)
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + html.EscapeString(fileName) + ` - SQLite - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 12px; font-size: 14px; }
+        .meta { color: #888; font-size: 13px; }
+        .warn { color: #e65100; font-size: 13px; }
+        a { color: #4CAF50; }
+        .layout { display: flex; gap: 16px; align-items: flex-start; }
+        #tables { background: white; border-radius: 8px; padding: 8px 0; min-width: 200px; max-height: calc(100vh - 100px); overflow: auto; }
+        #tables div { padding: 6px 14px; cursor: pointer; font-size: 14px; word-break: break-all; }
+        #tables div:hover { background: #f0f0f0; }
+        #tables div.active { background: #e8f5e9; font-weight: 500; }
+        #tables small { color: #888; margin-left: 6px; }
+        #content { flex: 1; min-width: 0; background: white; border-radius: 8px; padding: 12px; overflow: auto; max-height: calc(100vh - 100px); }
+        table { border-collapse: collapse; font-size: 13px; }
+        th, td { border: 1px solid #e0e0e0; padding: 4px 8px; text-align: left; vertical-align: top; max-width: 400px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+        th { background: #fafafa; position: sticky; top: 0; }
+        td.null { color: #bbb; font-style: italic; }
+        td.num { text-align: right; font-variant-numeric: tabular-nums; }
+        .pager { display: flex; gap: 8px; align-items: center; margin-bottom: 10px; font-size: 14px; }
+        pre { white-space: pre-wrap; font-size: 12px; color: #555; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>🗄 ` + html.EscapeString(fileName) + `</strong>
+        <span class="meta" id="info">加载中...</span>
+        <span class="warn" id="warn"></span>
+    </div>
+    <div class="layout">
+        <div id="tables"></div>
+        <div id="content"></div>
+    </div>
+    <script nonce="` + nonce + `">
+        const dbPath = ` + jsString(clientPath(path)) + `;
+        const info = document.getElementById('info');
+        const tablesEl = document.getElementById('tables');
+        const content = document.getElementById('content');
+        let tables = [];
+
+        async function api(params) {
+            const response = await fetch('/api/sqlite?' + new URLSearchParams(Object.assign({ path: dbPath }, params)));
+            if (!response.ok) throw new Error(await response.text());
+            return response.json();
+        }
+
+        async function loadTables() {
+            try {
+                const data = await api({});
+                tables = data.tables;
+                info.textContent = data.count + '个表和视图，' + data.pageCount + '页 × ' + data.pageSize + '字节';
+                if (data.walPending) document.getElementById('warn').textContent = '⚠ 有未合并的WAL日志，最近的修改可能不显示';
+                tables.forEach(t => {
+                    const div = document.createElement('div');
+                    div.textContent = t.name;
+                    const small = document.createElement('small');
+                    small.textContent = t.type === 'view' ? '视图' : t.virtual ? '虚拟表' : t.rows.toLocaleString() + '行';
+                    div.appendChild(small);
+                    div.addEventListener('click', () => showTable(t, 1, div));
+                    tablesEl.appendChild(div);
+                });
+                const first = tables.findIndex(t => t.type === 'table' && !t.virtual);
+                if (first >= 0) showTable(tables[first], 1, tablesEl.children[first]);
+            } catch (error) {
+                info.textContent = '加载失败: ' + error.message;
+            }
+        }
+
+        async function showTable(t, page, item) {
+            tablesEl.querySelectorAll('.active').forEach(el => el.classList.remove('active'));
+            if (item) item.classList.add('active');
+            content.textContent = '加载中...';
+            const sql = document.createElement('pre');
+            sql.textContent = t.sql || '';
+            try {
+                if (t.type === 'view' || t.virtual || t.withoutRowid) {
+                    content.textContent = t.withoutRowid ? 'WITHOUT ROWID表只显示结构和行数（' + t.rows + '行）' : '视图和虚拟表没有存储的内容';
+                    content.appendChild(sql);
+                    return;
+                }
+                const data = await api({ table: t.name, page: page, pageSize: 100 });
+                content.textContent = '';
+
+                const pager = document.createElement('div');
+                pager.className = 'pager';
+                const prev = document.createElement('button');
+                prev.textContent = '上一页';
+                prev.disabled = page <= 1;
+                prev.addEventListener('click', () => showTable(t, page - 1, item));
+                const next = document.createElement('button');
+                next.textContent = '下一页';
+                next.disabled = page >= data.totalPages;
+                next.addEventListener('click', () => showTable(t, page + 1, item));
+                const status = document.createElement('span');
+                status.className = 'meta';
+                status.textContent = '第' + page + '/' + Math.max(data.totalPages, 1) + '页，共' + data.totalRows.toLocaleString() + '行';
+                pager.append(prev, next, status);
+                content.appendChild(pager);
+
+                const table = document.createElement('table');
+                const head = table.createTHead().insertRow();
+                const width = Math.max(data.columns.length, ...data.rows.map(r => r.length));
+                for (let i = 0; i < width; i++) {
+                    const th = document.createElement('th');
+                    th.textContent = data.columns[i] || ('列' + (i + 1));
+                    head.appendChild(th);
+                }
+                const body = table.createTBody();
+                data.rows.forEach(row => {
+                    const tr = body.insertRow();
+                    for (let i = 0; i < width; i++) {
+                        const td = tr.insertCell();
+                        const v = row[i];
+                        if (v === null || v === undefined) {
+                            td.textContent = 'NULL';
+                            td.className = 'null';
+                        } else {
+                            td.textContent = v;
+                            td.title = v;
+                            if (typeof v === 'number') td.className = 'num';
+                        }
+                    }
+                });
+                content.appendChild(table);
+                content.appendChild(sql);
+            } catch (error) {
+                content.textContent = '读取失败: ' + error.message;
+            }
+        }
+
+        loadTables();
+    </script>
+</body>
+</html>`
+	return &Preview{ContentType: "text/html; charset=utf-8", Body: io.NopCloser(strings.NewReader(page))}, nil
+}
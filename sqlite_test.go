@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSQLitePageSize = 512
+
+// 大端变长整数，除最后一个字节外都设置最高位
+func sqliteTestVarint(v int64) []byte {
+	out := []byte{byte(v & 0x7F)}
+	for v >>= 7; v > 0; v >>= 7 {
+		out = append([]byte{byte(v&0x7F) | 0x80}, out...)
+	}
+	return out
+}
+
+// 按SQLite记录格式编码：int64为8字节整数，float64，string为文本，[]byte为BLOB，nil为NULL
+func sqliteTestRecord(values ...interface{}) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = append(types, 0)
+		case int64:
+			types = append(types, 6)
+			body = binary.BigEndian.AppendUint64(body, uint64(v))
+		case float64:
+			types = append(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types = append(types, sqliteTestVarint(int64(13+2*len(v)))...)
+			body = append(body, v...)
+		case []byte:
+			types = append(types, sqliteTestVarint(int64(12+2*len(v)))...)
+			body = append(body, v...)
+		}
+	}
+	return append(append([]byte{byte(len(types) + 1)}, types...), body...)
+}
+
+// 简化的SQLite文件：页号从1开始，单元格从页面末尾向前存放
+type sqliteTestDB struct{ pages [][]byte }
+
+func sqliteTestPage(pageNum int, kind byte, rightmost int, cells [][]byte) []byte {
+	page := make([]byte, testSQLitePageSize)
+	offset := 0
+	if pageNum == 1 {
+		offset = 100
+	}
+	page[offset] = kind
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	headerSize := 8
+	if kind == 5 {
+		headerSize = 12
+		binary.BigEndian.PutUint32(page[offset+8:], uint32(rightmost))
+	}
+	end := len(page)
+	for i, cell := range cells {
+		end -= len(cell)
+		copy(page[end:], cell)
+		binary.BigEndian.PutUint16(page[offset+headerSize+i*2:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(end))
+	return page
+}
+
+func (b *sqliteTestDB) addPage(kind byte, rightmost int, cells [][]byte) int {
+	b.pages = append(b.pages, sqliteTestPage(len(b.pages)+1, kind, rightmost, cells))
+	return len(b.pages)
+}
+
+// 表叶子单元格，负载超过页面时写入溢出页
+func (b *sqliteTestDB) leafCell(rowid int64, payload []byte) []byte {
+	cell := append(sqliteTestVarint(int64(len(payload))), sqliteTestVarint(rowid)...)
+	u := testSQLitePageSize
+	maxLocal, minLocal := u-35, (u-12)*32/255-23
+	if len(payload) <= maxLocal {
+		return append(cell, payload...)
+	}
+	local := minLocal + (len(payload)-minLocal)%(u-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, payload[:local]...)
+	rest := payload[local:]
+	first := len(b.pages) + 1
+	for len(rest) > 0 {
+		page := make([]byte, u)
+		n := copy(page[4:], rest)
+		rest = rest[n:]
+		if len(rest) > 0 {
+			binary.BigEndian.PutUint32(page, uint32(len(b.pages)+2))
+		}
+		b.pages = append(b.pages, page)
+	}
+	return binary.BigEndian.AppendUint32(cell, uint32(first))
+}
+
+func (b *sqliteTestDB) bytes() []byte {
+	header := b.pages[0]
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], testSQLitePageSize)
+	header[18], header[19], header[21], header[22], header[23] = 1, 1, 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1)
+	binary.BigEndian.PutUint32(header[28:], uint32(len(b.pages)))
+	binary.BigEndian.PutUint32(header[56:], 1)
+	binary.BigEndian.PutUint32(header[92:], 1)
+	var data []byte
+	for _, p := range b.pages {
+		data = append(data, p...)
+	}
+	return data
+}
+
+// items表：第2页为内部页面，三个叶子页面共8行，第5行的name需要溢出页
+func buildTestSQLite() []byte {
+	// 第1页（sqlite_master）和第2页（items根页面）最后填充
+	b := &sqliteTestDB{pages: make([][]byte, 2)}
+
+	var leaves []int
+	for _, rows := range [][]int64{{1, 2, 3}, {4, 5, 6}, {7, 8}} {
+		var cells [][]byte
+		for _, id := range rows {
+			name := fmt.Sprintf("item%d", id)
+			if id == 5 {
+				name = strings.Repeat("长文本", 200)
+			}
+			record := sqliteTestRecord(nil, name, []byte{0xde, 0xad}, float64(id)/2)
+			if id == 8 {
+				record = sqliteTestRecord(nil, name) // ALTER TABLE之前写入的行缺少后面的列
+			}
+			cells = append(cells, b.leafCell(id, record))
+		}
+		leaves = append(leaves, b.addPage(13, 0, cells))
+	}
+	interiorCell := func(child int, key int64) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(child)), sqliteTestVarint(key)...)
+	}
+	b.pages[1] = sqliteTestPage(2, 5, leaves[2], [][]byte{interiorCell(leaves[0], 3), interiorCell(leaves[1], 6)})
+
+	sql := `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL, "raw data" BLOB, score REAL, CONSTRAINT uq UNIQUE(name))`
+	b.pages[0] = sqliteTestPage(1, 13, 0, [][]byte{
+		b.leafCell(1, sqliteTestRecord("table", "items", "items", int64(2), sql)),
+		b.leafCell(2, sqliteTestRecord("view", "recent", "recent", int64(0), "CREATE VIEW recent AS SELECT * FROM items")),
+	})
+	return b.bytes()
+}
+
+func TestSQLiteBrowse(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "app.db")
+	os.WriteFile(db, buildTestSQLite(), 0644)
+	notDB := createTestFiles(t, dir, "Thumbs.db")[0]
+
+	get := func(params url.Values) *httptest.ResponseRecorder {
+		return serveTestRequest(apiSQLiteHandler, httptest.NewRequest(http.MethodGet, "/api/sqlite?"+params.Encode(), nil))
+	}
+
+	rec := get(url.Values{"path": {db}})
+	var list struct {
+		Tables []SQLiteTable `json:"tables"`
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status %d, %s", rec.Code, rec.Body.String())
+	}
+	decodeTestJSON(t, rec, &list)
+	if len(list.Tables) != 2 || list.Tables[0].Name != "items" || list.Tables[0].Rows != 8 || list.Tables[1].Type != "view" {
+		t.Fatalf("tables = %+v", list.Tables)
+	}
+	if got := strings.Join(list.Tables[0].Columns, ","); got != "id,name,raw data,score" {
+		t.Errorf("columns = %s", got)
+	}
+
+	// 每页3行的第2页跨越两个叶子页面
+	rec = get(url.Values{"path": {db}, "table": {"items"}, "page": {"2"}, "pageSize": {"3"}})
+	var rows SQLiteRowsResponse
+	decodeTestJSON(t, rec, &rows)
+	if rows.TotalRows != 8 || rows.TotalPages != 3 || len(rows.Rows) != 3 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if id, name := rows.Rows[0][0], rows.Rows[1][1]; id != float64(4) || name != strings.Repeat("长文本", 200) {
+		t.Errorf("row 4 id = %v, row 5 name has %d bytes", id, len(name.(string)))
+	}
+	if blob, score := rows.Rows[0][2], rows.Rows[0][3]; blob != "[BLOB 2字节] dead" || score != 2.0 {
+		t.Errorf("blob = %v, score = %v", blob, score)
+	}
+
+	rec = get(url.Values{"path": {db}, "table": {"items"}, "page": {"3"}, "pageSize": {"3"}})
+	decodeTestJSON(t, rec, &rows)
+	if len(rows.Rows) != 2 || rows.Rows[1][0] != float64(8) || rows.Rows[1][2] != nil {
+		t.Errorf("last page = %+v", rows.Rows)
+	}
+
+	tests := []struct {
+		params url.Values
+		want   int
+	}{
+		{url.Values{"path": {db}, "table": {"missing"}}, http.StatusNotFound},
+		{url.Values{"path": {db}, "table": {"recent"}}, http.StatusNotImplemented},
+		{url.Values{"path": {notDB}}, http.StatusUnprocessableEntity},
+		{url.Values{"path": {filepath.Join(dir, "missing.db")}}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := get(tt.params); rec.Code != tt.want {
+			t.Errorf("%v: status = %d, want %d", tt.params, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestParseSQLiteColumns(t *testing.T) {
+	tests := []struct {
+		sql          string
+		want         string
+		alias        int
+		withoutRowid bool
+	}{
+		{`CREATE TABLE t(a, b INTEGER PRIMARY KEY, [c d] TEXT DEFAULT 'x,y')`, "a,b,c d", 1, false},
+		{"CREATE TABLE kv (k TEXT, v, primary_key_id INT, PRIMARY KEY (k)) WITHOUT ROWID", "k,v,primary_key_id", -1, true},
+		{"CREATE TABLE `n`(`x` INTEGER primary key autoincrement, y CHECK(y > 0))", "x,y", 0, false},
+	}
+	for _, tt := range tests {
+		columns, alias, withoutRowid := parseSQLiteColumns(tt.sql)
+		if got := strings.Join(columns, ","); got != tt.want || alias != tt.alias || withoutRowid != tt.withoutRowid {
+			t.Errorf("%s: columns %s, alias %d, withoutRowid %v", tt.sql, got, alias, withoutRowid)
+		}
+	}
+}
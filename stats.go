@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"everything-web-server/pkg/everything"
+)
+
+// 查询或文件夹的统计信息
+//
+// GET /api/stats?q=搜索关键词  或  GET /api/stats?path=文件夹路径
+// 返回文件总数和总大小、按扩展名的数量和大小、最大的文件、最旧和最新的文件。
+// 大小和修改时间直接从Everything索引读取，不逐个访问文件；SDK不可用时退回逐个获取文件信息。
+
+const (
+	statsTopFiles      = 20 // 最大的文件列出的数量
+	statsTopExtensions = 50 // 按扩展名统计列出的数量
+)
+
+// 带大小和修改时间的Everything结果
+type everythingEntry struct {
+	Path     string
+	Size     int64
+	Modified time.Time
+	IsDir    bool
+}
+
+// 用Everything SDK查询并取回每个结果的大小和修改时间
+func queryEverythingEntries(ctx context.Context, query string) ([]everythingEntry, error) {
+	if remoteEverythingEnabled() {
+		return queryRemoteEntries(ctx, query)
+	}
+	if err := initEverythingSDK(); err != nil {
+		return nil, err
+	}
+
+	results, err := everythingClient.Search(ctx, everything.Query{
+		Search:  query,
+		Request: everything.RequestFullPathAndFileName | everything.RequestSize | everything.RequestDateModified,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	// 索引中没有大小时Size为-1
+	entries := make([]everythingEntry, 0, results.Len())
+	for results.Next() {
+		result := results.Result()
+		entries = append(entries, everythingEntry{
+			Path:     result.Path,
+			Size:     result.Size,
+			Modified: result.Modified,
+			IsDir:    result.IsDir,
+		})
+	}
+	if err := results.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// 获取查询结果的元数据，优先使用Everything索引，索引中缺少的信息逐个获取
+func collectEntries(ctx context.Context, query string) ([]everythingEntry, error) {
+	entries, err := queryEverythingEntries(ctx, query)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("Everything SDK获取元数据失败，改为逐个获取文件信息: %v", err)
+		paths, err := runEverythingQuery(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		entries = make([]everythingEntry, len(paths))
+		for i, path := range paths {
+			entries[i] = everythingEntry{Path: path, Size: -1}
+		}
+	}
+
+	for i := range entries {
+		if entries[i].Size >= 0 && (entries[i].IsDir || !entries[i].Modified.IsZero()) {
+			continue
+		}
+		if i%1000 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if info, err := statPath(entries[i].Path); err == nil {
+			entries[i].IsDir = info.IsDir()
+			entries[i].Size = info.Size()
+			entries[i].Modified = info.ModTime()
+		} else if entries[i].Size < 0 {
+			entries[i].Size = 0
+		}
+	}
+	return entries, nil
+}
+
+// 文件夹中所有内容（含子文件夹）对应的Everything查询
+func folderQuery(folder string) string {
+	folder = strings.TrimRight(folder, `\`)
+	return `"` + folder + `\"`
+}
+
+type StatsFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Modified string `json:"modified,omitempty"`
+}
+
+type ExtensionStats struct {
+	Ext   string `json:"ext"` // 小写，不含点；没有扩展名时为空
+	Count int    `json:"count"`
+	Size  int64  `json:"size"`
+}
+
+type StatsResponse struct {
+	Query       string           `json:"query,omitempty"`
+	Path        string           `json:"path,omitempty"`
+	FileCount   int              `json:"fileCount"`
+	FolderCount int              `json:"folderCount"`
+	TotalSize   int64            `json:"totalSize"`
+	Extensions  []ExtensionStats `json:"extensions"` // 按总大小从大到小
+	Largest     []StatsFile      `json:"largest"`
+	Oldest      *StatsFile       `json:"oldest,omitempty"`
+	Newest      *StatsFile       `json:"newest,omitempty"`
+	ElapsedMs   int64            `json:"elapsedMs"`
+}
+
+func newStatsFile(e everythingEntry) *StatsFile {
+	f := &StatsFile{Path: clientPath(e.Path), Size: e.Size}
+	if !e.Modified.IsZero() {
+		f.Modified = e.Modified.Format("2006-01-02 15:04:05")
+	}
+	return f
+}
+
+// 汇总统计信息
+func computeStats(entries []everythingEntry) *StatsResponse {
+	resp := &StatsResponse{Extensions: []ExtensionStats{}, Largest: []StatsFile{}}
+	byExt := make(map[string]*ExtensionStats)
+	var files []everythingEntry
+	var oldest, newest *everythingEntry
+
+	for i := range entries {
+		e := &entries[i]
+		if e.IsDir {
+			resp.FolderCount++
+			continue
+		}
+		resp.FileCount++
+		resp.TotalSize += e.Size
+		files = append(files, *e)
+
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(e.Path)), ".")
+		s, ok := byExt[ext]
+		if !ok {
+			s = &ExtensionStats{Ext: ext}
+			byExt[ext] = s
+		}
+		s.Count++
+		s.Size += e.Size
+
+		if !e.Modified.IsZero() {
+			if oldest == nil || e.Modified.Before(oldest.Modified) {
+				oldest = e
+			}
+			if newest == nil || e.Modified.After(newest.Modified) {
+				newest = e
+			}
+		}
+	}
+
+	for _, s := range byExt {
+		resp.Extensions = append(resp.Extensions, *s)
+	}
+	sort.Slice(resp.Extensions, func(i, j int) bool { return resp.Extensions[i].Size > resp.Extensions[j].Size })
+	if len(resp.Extensions) > statsTopExtensions {
+		resp.Extensions = resp.Extensions[:statsTopExtensions]
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	for i := 0; i < len(files) && i < statsTopFiles; i++ {
+		resp.Largest = append(resp.Largest, *newStatsFile(files[i]))
+	}
+	if oldest != nil {
+		resp.Oldest = newStatsFile(*oldest)
+		resp.Newest = newStatsFile(*newest)
+	}
+	return resp
+}
+
+// 统计API处理器
+func apiStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	folder := resolveClientPath(r.URL.Query().Get("path"))
+	if query == "" && folder == "" {
+		http.Error(w, "需要q或path参数", http.StatusBadRequest)
+		return
+	}
+	if folder != "" {
+		if info, err := statPath(folder); err != nil || !info.IsDir() {
+			if err != nil && !os.IsNotExist(err) {
+				http.Error(w, "访问文件夹失败: "+err.Error(), http.StatusInternalServerError)
+			} else {
+				http.Error(w, "文件夹不存在", http.StatusNotFound)
+			}
+			return
+		}
+		query = folderQuery(folder)
+	}
+
+	start := time.Now()
+	entries, err := collectEntries(r.Context(), query)
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消统计: %s", query)
+		return
+	}
+	if err != nil {
+		log.Printf("统计失败: %s, 错误: %v", query, err)
+		http.Error(w, "统计失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := computeStats(entries)
+	if folder != "" {
+		resp.Path = clientPath(folder)
+	} else {
+		resp.Query = query
+	}
+	resp.ElapsedMs = time.Since(start).Milliseconds()
+	log.Printf("统计完成: %s，%d个文件，%d个文件夹，共%s，用时%dms，来源IP: %s",
+		query, resp.FileCount, resp.FolderCount, formatSize(resp.TotalSize), resp.ElapsedMs, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 数据目录名，位于可执行文件同目录下，用于保存凭据、收藏等持久化数据
+const dataDirName = "data"
+
+var (
+	dataDirOnce sync.Once
+	dataDir     string
+)
+
+// 获取数据目录，不存在时自动创建
+func getDataDir() string {
+	dataDirOnce.Do(func() {
+		baseDir := "."
+		if exePath, err := os.Executable(); err == nil {
+			baseDir = filepath.Dir(exePath)
+		}
+		dataDir = filepath.Join(baseDir, dataDirName)
+
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			log.Printf("创建数据目录失败: %s, 错误: %v", dataDir, err)
+		}
+	})
+	return dataDir
+}
+
+// 从数据目录读取JSON文件，文件不存在时不修改v并返回nil
+func loadJSONFile(name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(getDataDir(), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("解析%s失败: %v", name, err)
+	}
+	return nil
+}
+
+// 将v写入数据目录下的JSON文件，先写临时文件再替换，避免写到一半时损坏
+func saveJSONFile(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(getDataDir(), name)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 拖动进度条时的预览缩略图（storyboard）
+//
+// 用ffmpeg从视频中按固定间隔取帧拼成一张雪碧图，配合WebVTT描述每段时间对应的区域：
+//   GET /storyboard/视频路径            WebVTT，每条cue指向 ?sprite=1#xywh=x,y,w,h
+//   GET /storyboard/视频路径?sprite=1   雪碧图（JPEG）
+// 结果缓存在 data\cache\storyboard，视频文件变化后重新生成。
+
+const (
+	storyboardTileWidth   = 160
+	storyboardTileHeight  = 90
+	storyboardColumns     = 10
+	storyboardMaxTiles    = 100 // 最多取帧数，长视频相应增大间隔
+	storyboardMinInterval = 2.0 // 最小取帧间隔（秒）
+)
+
+// 确保storyboard已生成，返回雪碧图和WebVTT文件路径
+func ensureStoryboard(ctx context.Context, videoPath string, info os.FileInfo) (string, string, error) {
+	key := fileCacheKey(videoPath, info, "")
+	dir := getCacheDir("storyboard")
+	sprite := filepath.Join(dir, key+".jpg")
+	vtt := filepath.Join(dir, key+".vtt")
+
+	// WebVTT最后写入，存在即表示生成完成
+	generate := func(ctx context.Context, tmp string) error {
+		return generateStoryboard(ctx, videoPath, sprite, tmp)
+	}
+	err := ensureCachedFile(ctx, vtt, generate)
+	if err != nil {
+		return sprite, vtt, err
+	}
+	// 容量清理可能只删除了雪碧图，这时重新生成
+	if info, statErr := os.Stat(sprite); statErr == nil {
+		touchCacheFile(sprite, info)
+	} else {
+		os.Remove(vtt)
+		err = ensureCachedFile(ctx, vtt, generate)
+	}
+	return sprite, vtt, err
+}
+
+func generateStoryboard(ctx context.Context, videoPath, sprite, vtt string) error {
+	start := time.Now()
+
+	duration, err := probeDuration(ctx, videoPath)
+	if err != nil {
+		return err
+	}
+
+	interval := math.Max(duration/storyboardMaxTiles, storyboardMinInterval)
+	count := int(math.Ceil(duration / interval))
+	if count < 1 {
+		count = 1
+	}
+	rows := (count + storyboardColumns - 1) / storyboardColumns
+
+	// 只解码关键帧，长视频也能很快完成；固定每格大小，不同比例的视频加黑边
+	filter := fmt.Sprintf("fps=1/%.3f,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,tile=%dx%d",
+		interval, storyboardTileWidth, storyboardTileHeight, storyboardTileWidth, storyboardTileHeight, storyboardColumns, rows)
+	tmpSprite := sprite + ".tmp.jpg"
+	cmd := exec.CommandContext(ctx, ffmpegBinary(), "-skip_frame", "nokey", "-i", videoPath,
+		"-vf", filter, "-an", "-frames:v", "1", "-q:v", "5", "-y", tmpSprite)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpSprite)
+		return ffmpegError(err, out)
+	}
+	if err := os.Rename(tmpSprite, sprite); err != nil {
+		os.Remove(tmpSprite)
+		return err
+	}
+
+	spriteURL := "/storyboard/" + url.QueryEscape(videoPath) + "?sprite=1"
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i := 0; i < count; i++ {
+		from := float64(i) * interval
+		to := math.Min(from+interval, duration)
+		x := (i % storyboardColumns) * storyboardTileWidth
+		y := (i / storyboardColumns) * storyboardTileHeight
+		fmt.Fprintf(&b, "%s --> %s\n%s#xywh=%d,%d,%d,%d\n\n",
+			vttTimestamp(from), vttTimestamp(to), spriteURL, x, y, storyboardTileWidth, storyboardTileHeight)
+	}
+
+	if err := os.WriteFile(vtt, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+
+	log.Printf("生成预览图: %s，%d帧，间隔%.1fs，用时%v", videoPath, count, interval, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// WebVTT时间格式 hh:mm:ss.mmm
+func vttTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// 预览缩略图处理器
+func storyboardHandler(w http.ResponseWriter, r *http.Request) {
+	filePath := decodeRequestPath(r.URL.Path[len("/storyboard/"):])
+
+	info, err := statPath(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "访问文件失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !isVideoFile(strings.ToLower(filepath.Ext(filePath))) {
+		http.Error(w, "不是视频文件", http.StatusBadRequest)
+		return
+	}
+	if !ffmpegAvailable.Load() {
+		http.Error(w, "ffmpeg不可用，无法生成预览图", http.StatusServiceUnavailable)
+		return
+	}
+
+	sprite, vtt, err := ensureStoryboard(r.Context(), filePath, info)
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("生成预览图失败: %s, 错误: %v", filePath, err)
+			http.Error(w, "生成预览图失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", thumbnailCacheControl)
+	if r.URL.Query().Get("sprite") != "" {
+		w.Header().Set("Content-Type", "image/jpeg")
+		serveFileContent(w, r, sprite)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	serveFileContent(w, r, vtt)
+}
+
+// 播放页中进度条预览的样式和脚本，放在video所在的.video-container里
+// 原生控件不显示WebVTT缩略图，鼠标移到视频底部的进度条区域时按横坐标估算时间
+func storyboardPreview(filePath, nonce string) string {
+	return `<div id="storyboardPreview" style="display: none; position: absolute; bottom: 60px; width: ` + fmt.Sprint(storyboardTileWidth) + `px; pointer-events: none; z-index: 5;">
+                <div id="storyboardFrame" style="box-sizing: content-box; width: ` + fmt.Sprint(storyboardTileWidth) + `px; height: ` + fmt.Sprint(storyboardTileHeight) + `px; border: 2px solid #fff; border-radius: 4px; background-color: #000; background-repeat: no-repeat;"></div>
+                <div id="storyboardTime" style="text-align: center; font-size: 12px; text-shadow: 0 0 3px #000;"></div>
+            </div>
+            <script nonce="` + nonce + `">
+            (function() {
+                const video = document.querySelector('.video-container video');
+                const preview = document.getElementById('storyboardPreview');
+                const frame = document.getElementById('storyboardFrame');
+                const label = document.getElementById('storyboardTime');
+                let cues = null;
+                let loading = false;
+
+                function parseTime(s) {
+                    const p = s.split(':');
+                    return parseInt(p[0]) * 3600 + parseInt(p[1]) * 60 + parseFloat(p[2]);
+                }
+
+                function loadCues() {
+                    loading = true;
+                    fetch('/storyboard/` + url.QueryEscape(filePath) + `')
+                        .then(r => r.ok ? r.text() : Promise.reject(r.status))
+                        .then(text => {
+                            cues = [];
+                            const re = /([\d:.]+) --> ([\d:.]+)\n(\S+)#xywh=(\d+),(\d+),(\d+),(\d+)/g;
+                            let m;
+                            while ((m = re.exec(text)) !== null) {
+                                cues.push({ start: parseTime(m[1]), end: parseTime(m[2]), url: m[3], x: +m[4], y: +m[5] });
+                            }
+                        })
+                        .catch(() => { cues = []; });
+                }
+
+                function formatTime(t) {
+                    const h = Math.floor(t / 3600), m = Math.floor(t / 60) % 60, s = Math.floor(t % 60);
+                    return (h > 0 ? h + ':' + String(m).padStart(2, '0') : m) + ':' + String(s).padStart(2, '0');
+                }
+
+                video.addEventListener('mousemove', function(e) {
+                    const rect = video.getBoundingClientRect();
+                    // 只在进度条所在的底部区域显示
+                    if (e.clientY < rect.bottom - 50 || !isFinite(video.duration)) {
+                        preview.style.display = 'none';
+                        return;
+                    }
+                    if (cues === null) {
+                        if (!loading) loadCues();
+                        return;
+                    }
+                    const t = Math.max(0, Math.min(1, (e.clientX - rect.left) / rect.width)) * video.duration;
+                    const cue = cues.find(c => t >= c.start && t < c.end) || cues[cues.length - 1];
+                    if (!cue) return;
+
+                    frame.style.backgroundImage = 'url("' + cue.url + '")';
+                    frame.style.backgroundPosition = (-cue.x) + 'px ' + (-cue.y) + 'px';
+                    label.textContent = formatTime(t);
+                    const left = Math.max(0, Math.min(rect.width - preview.offsetWidth, e.clientX - rect.left - preview.offsetWidth / 2));
+                    preview.style.left = (video.offsetLeft + left) + 'px';
+                    preview.style.display = 'block';
+                });
+                video.addEventListener('mouseleave', function() { preview.style.display = 'none'; });
+            })();
+            </script>`
+}
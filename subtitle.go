@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 字幕文件
+//
+// .srt、.ass/.ssa和.vtt文件预览为带时间的文本，并可以找到同名的视频，用这个字幕打开播放器：
+//
+//   GET /subtitle/字幕文件                     转换为WebVTT，供播放器的<track>加载
+//   GET /api/subtitle/video?path=字幕文件      通过Everything查找同名的视频（同一文件夹的排在前面）
+//   GET /video/视频文件?subtitle=字幕文件      播放器预先加载字幕
+
+const maxSubtitleSize = 10 << 20 // 字幕文件的最大大小
+
+var (
+	errNotSubtitle = errors.New("无法解析字幕文件")
+
+	srtTimeRange = regexp.MustCompile(`^\s*(\d+):(\d{1,2}):(\d{1,2})[,.](\d{1,3})\s*-->\s*(\d+):(\d{1,2}):(\d{1,2})[,.](\d{1,3})`)
+	vttTimeRange = regexp.MustCompile(`^\s*(?:(\d+):)?(\d{1,2}):(\d{1,2})\.(\d{1,3})\s*-->\s*(?:(\d+):)?(\d{1,2}):(\d{1,2})\.(\d{1,3})`)
+	assOverride  = regexp.MustCompile(`\{[^}]*\}`)
+	subtitleTag  = regexp.MustCompile(`</?(?:[bius]|font)(?:\s[^>]*)?>`)
+	// 字幕文件名中视频名之后的语言等标记，如movie.chs.srt、movie.en.forced.srt
+	subtitleSuffix = regexp.MustCompile(`(?i)\.(?:[a-z]{2,3}(?:[-_][a-z0-9]{2,4})?|chs|cht|sc|tc|gb|big5|forced|sdh|default)$`)
+)
+
+var subtitleExtensions = []string{".srt", ".ass", ".ssa", ".vtt"}
+
+func isSubtitleFile(ext string) bool {
+	return slices.Contains(subtitleExtensions, ext)
+}
+
+type subtitleCue struct {
+	Start   time.Duration `json:"start"` // 纳秒
+	End     time.Duration `json:"end"`
+	Speaker string        `json:"speaker,omitempty"` // ASS的Name字段
+	Text    string        `json:"text"`
+}
+
+func parseSubtitle(ext, text string) ([]subtitleCue, error) {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	var cues []subtitleCue
+	switch ext {
+	case ".ass", ".ssa":
+		cues = parseASS(text)
+	case ".vtt":
+		cues = parseTimedBlocks(text, vttTimeRange)
+	default:
+		cues = parseTimedBlocks(text, srtTimeRange)
+	}
+	if len(cues) == 0 {
+		return nil, errNotSubtitle
+	}
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+	return cues, nil
+}
+
+func subtitleTime(h, m, s, ms string) time.Duration {
+	hours, _ := strconv.Atoi(h)
+	minutes, _ := strconv.Atoi(m)
+	seconds, _ := strconv.Atoi(s)
+	// 毫秒部分不足三位时按小数处理，如0:00:01.5；可以没有毫秒部分，如ASS的0:00:01
+	millis, _ := strconv.Atoi((ms + "000")[:3])
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second + time.Duration(millis)*time.Millisecond
+}
+
+// SRT和WebVTT：时间行之后到空行为止是字幕文本，序号、WEBVTT头和NOTE块被忽略
+func parseTimedBlocks(text string, timeRange *regexp.Regexp) []subtitleCue {
+	var cues []subtitleCue
+	var current *subtitleCue
+	var lines []string
+	flush := func() {
+		if current != nil {
+			current.Text = cleanSubtitleText(strings.Join(lines, "\n"))
+			if current.Text != "" {
+				cues = append(cues, *current)
+			}
+		}
+		current, lines = nil, nil
+	}
+	all := strings.Split(text, "\n")
+	for i, line := range all {
+		// 没有空行分隔时，下一条字幕的序号紧接在文本之后
+		if _, err := strconv.Atoi(strings.TrimSpace(line)); err == nil && i+1 < len(all) && timeRange.MatchString(all[i+1]) {
+			continue
+		}
+		if m := timeRange.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &subtitleCue{Start: subtitleTime(m[1], m[2], m[3], m[4]), End: subtitleTime(m[5], m[6], m[7], m[8])}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if current != nil {
+			lines = append(lines, line)
+		}
+	}
+	flush()
+	return cues
+}
+
+// 去掉SRT/WebVTT的格式标签
+func cleanSubtitleText(s string) string {
+	s = subtitleTag.ReplaceAllString(s, "")
+	s = assOverride.ReplaceAllString(s, "") // 部分SRT中也有ASS的{\an8}等标记
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// ASS/SSA：[Events]节中按Format行的字段顺序读取Dialogue行，Text是最后一个字段（可以包含逗号）
+func parseASS(text string) []subtitleCue {
+	var cues []subtitleCue
+	inEvents := false
+	format := []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !inEvents || !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "format":
+			format = strings.Split(strings.ToLower(strings.ReplaceAll(value, " ", "")), ",")
+		case "dialogue":
+			fields := strings.SplitN(strings.TrimSpace(value), ",", len(format))
+			if len(fields) != len(format) {
+				continue
+			}
+			cue := subtitleCue{}
+			valid := 0
+			for i, name := range format {
+				switch name {
+				case "start", "end":
+					h, rest, _ := strings.Cut(strings.TrimSpace(fields[i]), ":")
+					m, rest, _ := strings.Cut(rest, ":")
+					s, cs, _ := strings.Cut(rest, ".")
+					if _, err := strconv.Atoi(h + m + s + cs); err != nil {
+						continue
+					}
+					if name == "start" {
+						cue.Start = subtitleTime(h, m, s, cs)
+					} else {
+						cue.End = subtitleTime(h, m, s, cs)
+					}
+					valid++
+				case "name":
+					cue.Speaker = strings.TrimSpace(fields[i])
+				case "text":
+					t := assOverride.ReplaceAllString(fields[i], "")
+					t = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(t)
+					cue.Text = strings.TrimSpace(t)
+				}
+			}
+			if valid == 2 && cue.Text != "" {
+				cues = append(cues, cue)
+			}
+		}
+	}
+	return cues
+}
+
+func formatVTTTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+func writeWebVTT(w io.Writer, cues []subtitleCue) error {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		// 空行会结束cue，<和&在WebVTT中有特殊含义，转义>后文本中不会出现-->
+		text := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\n\n", "\n").Replace(cue.Text)
+		if cue.Speaker != "" {
+			text = "<v " + strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(cue.Speaker) + ">" + text
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", formatVTTTime(cue.Start), formatVTTTime(cue.End), text)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func readSubtitleFile(path string) ([]subtitleCue, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSubtitleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSubtitleSize {
+		return nil, fmt.Errorf("文件超过%dMB", maxSubtitleSize>>20)
+	}
+	return parseSubtitle(strings.ToLower(filepath.Ext(path)), decodeTextFile(data))
+}
+
+// 字幕转换为WebVTT
+func subtitleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	filePath := decodeRequestPath(strings.TrimPrefix(r.URL.Path, "/subtitle/"))
+	if remoteEverythingEnabled() {
+		http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+		return
+	}
+	if !isSubtitleFile(strings.ToLower(filepath.Ext(filePath))) {
+		http.Error(w, "不是字幕文件", http.StatusUnsupportedMediaType)
+		return
+	}
+	cues, err := readSubtitleFile(filePath)
+	switch {
+	case os.IsNotExist(err):
+		http.Error(w, "文件不存在", http.StatusNotFound)
+		return
+	case errors.Is(err, errNotSubtitle):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		log.Printf("读取字幕失败: %s, 错误: %v", filePath, err)
+		http.Error(w, "读取字幕失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("字幕: %s, %d条, 来源IP: %s", filePath, len(cues), r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	writeWebVTT(w, cues)
+}
+
+// 字幕对应的视频名（不含扩展名）：先是完整的文件名，然后依次去掉语言等标记
+func subtitleVideoStems(subtitlePath string) []string {
+	name := filepath.Base(subtitlePath)
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	stems := []string{stem}
+	for i := 0; i < 2; i++ {
+		shorter := subtitleSuffix.ReplaceAllString(stem, "")
+		if shorter == stem || shorter == "" {
+			break
+		}
+		stem = shorter
+		stems = append(stems, stem)
+	}
+	return stems
+}
+
+// 通过Everything查找与字幕同名的视频，同一文件夹中的排在前面，然后按路径排序
+func findSubtitleVideos(ctx context.Context, subtitlePath string) ([]string, error) {
+	stems := subtitleVideoStems(subtitlePath)
+	terms := make([]string, 0, len(stems))
+	for _, stem := range stems {
+		terms = append(terms, `wfn:"`+stem+`.*"`)
+	}
+	paths, err := runEverythingQuery(ctx, strings.Join(terms, " | "))
+	if err != nil {
+		return nil, err
+	}
+
+	// 匹配较长的名称（movie.chs.srt对应movie.chs.mp4）优先于去掉标记后的名称
+	rank := func(p string) int {
+		name := filepath.Base(p)
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		for i, s := range stems {
+			if strings.EqualFold(stem, s) {
+				return i
+			}
+		}
+		return -1
+	}
+	dir := filepath.Dir(subtitlePath)
+	var videos []string
+	for _, p := range scriptFilterPaths(filterExcludedPaths(paths)) {
+		if isVideoFile(strings.ToLower(filepath.Ext(p))) && rank(p) >= 0 {
+			videos = append(videos, p)
+		}
+	}
+	sort.SliceStable(videos, func(i, j int) bool {
+		si, sj := strings.EqualFold(filepath.Dir(videos[i]), dir), strings.EqualFold(filepath.Dir(videos[j]), dir)
+		if si != sj {
+			return si
+		}
+		if ri, rj := rank(videos[i]), rank(videos[j]); ri != rj {
+			return ri < rj
+		}
+		return videos[i] < videos[j]
+	})
+	return videos, nil
+}
+
+func apiSubtitleVideoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	clientSrc := r.URL.Query().Get("path")
+	if clientSrc == "" {
+		http.Error(w, "path参数不能为空", http.StatusBadRequest)
+		return
+	}
+	path := resolveClientPath(clientSrc)
+	videos, err := findSubtitleVideos(r.Context(), path)
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("查找字幕对应的视频失败: %s, 错误: %v", path, err)
+			http.Error(w, "搜索失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	type videoMatch struct {
+		Path      string `json:"path"`
+		SameDir   bool   `json:"sameDir"`
+		PlayerURL string `json:"playerUrl"`
+	}
+	matches := make([]videoMatch, 0, len(videos))
+	for _, v := range videos {
+		matches = append(matches, videoMatch{
+			Path:      clientPath(v),
+			SameDir:   strings.EqualFold(filepath.Dir(v), filepath.Dir(path)),
+			PlayerURL: subtitlePlayerURL(v, path),
+		})
+	}
+	log.Printf("查找字幕对应的视频: %s, 找到%d个, 来源IP: %s", path, len(matches), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":   clientPath(path),
+		"stems":  subtitleVideoStems(path),
+		"videos": matches,
+		"count":  len(matches),
+	})
+}
+
+func subtitlePlayerURL(video, subtitle string) string {
+	return "/video/" + url.PathEscape(clientPath(video)) + "?subtitle=" + url.QueryEscape(clientPath(subtitle))
+}
+
+// 播放器中的<track>，字幕来自?subtitle=参数
+func subtitleTrack(r *http.Request) string {
+	clientSrc := r.URL.Query().Get("subtitle")
+	if clientSrc == "" {
+		return ""
+	}
+	path := resolveClientPath(clientSrc)
+	if !isSubtitleFile(strings.ToLower(filepath.Ext(path))) {
+		return ""
+	}
+	return `<track kind="subtitles" src="/subtitle/` + html.EscapeString(url.PathEscape(clientPath(path))) + `" label="` + html.EscapeString(filepath.Base(path)) + `" default>`
+}
+
+type subtitlePreviewProvider struct{ previewExtensions }
+
+func init() {
+	registerPreviewProvider(subtitlePreviewProvider{previewExtensions(subtitleExtensions)})
+}
+
+func (subtitlePreviewProvider) Name() string { return "subtitle" }
+
+// 带时间的字幕文本，可以搜索，并查找同名视频用这个字幕播放
+func (subtitlePreviewProvider) Render(ctx context.Context, path string) (*Preview, error) {
+	cues, err := readSubtitleFile(path)
+	if err != nil {
+		return nil, err
+	}
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	data, _ := json.Marshal(cues)
+	fileName := filepath.Base(path)
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + html.EscapeString(fileName) + ` - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 12px; font-size: 14px; }
+        .toolbar input { padding: 6px 10px; border: 1px solid #ccc; border-radius: 4px; min-width: 240px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; }
+        button { padding: 6px 14px; border: none; border-radius: 4px; background: #4CAF50; color: white; cursor: pointer; }
+        button:disabled { background: #aaa; }
+        #videos a { display: block; font-size: 13px; word-break: break-all; }
+        #cues { background: white; border-radius: 8px; padding: 8px 0; }
+        .cue { display: flex; gap: 16px; padding: 6px 16px; font-size: 14px; line-height: 1.5; }
+        .cue:nth-child(even) { background: #fafafa; }
+        .cue.hidden { display: none; }
+        .time { color: #888; font-family: monospace; white-space: nowrap; }
+        .speaker { color: #1976D2; font-weight: 500; margin-right: 6px; }
+        .text { white-space: pre-wrap; }
+        mark { background: #fff59d; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>💬 ` + html.EscapeString(fileName) + `</strong>
+        <span class="meta" id="count"></span>
+        <input type="search" id="filter" placeholder="搜索字幕文本">
+        <button id="play">用这个字幕播放视频</button>
+        <span class="meta" id="playStatus"></span>
+    </div>
+    <div id="videos"></div>
+    <div id="cues"></div>
+    <script nonce="` + nonce + `">
+        const subtitlePath = ` + jsString(clientPath(path)) + `;
+        const cues = ` + string(data) + `;
+        const container = document.getElementById('cues');
+
+        function formatTime(ns) {
+            const ms = Math.floor(ns / 1e6);
+            const pad = (n, w) => String(n).padStart(w || 2, '0');
+            return pad(Math.floor(ms / 3600000)) + ':' + pad(Math.floor(ms / 60000) % 60) + ':' + pad(Math.floor(ms / 1000) % 60) + '.' + pad(ms % 1000, 3);
+        }
+
+        cues.forEach(cue => {
+            const row = document.createElement('div');
+            row.className = 'cue';
+            const time = document.createElement('span');
+            time.className = 'time';
+            time.textContent = formatTime(cue.start) + ' → ' + formatTime(cue.end);
+            const text = document.createElement('span');
+            text.className = 'text';
+            if (cue.speaker) {
+                const speaker = document.createElement('span');
+                speaker.className = 'speaker';
+                speaker.textContent = cue.speaker + ':';
+                text.appendChild(speaker);
+            }
+            text.appendChild(document.createTextNode(cue.text));
+            row.append(time, text);
+            container.appendChild(row);
+        });
+        document.getElementById('count').textContent = cues.length + '条字幕，时长' + formatTime(cues.length ? cues[cues.length - 1].end : 0).slice(0, 8);
+
+        document.getElementById('filter').addEventListener('input', event => {
+            const keyword = event.target.value.trim().toLowerCase();
+            Array.from(container.children).forEach((row, i) => {
+                const match = !keyword || (cues[i].text + ' ' + (cues[i].speaker || '')).toLowerCase().includes(keyword);
+                row.classList.toggle('hidden', !match);
+            });
+        });
+
+        document.getElementById('play').addEventListener('click', async event => {
+            const button = event.target;
+            const status = document.getElementById('playStatus');
+            const list = document.getElementById('videos');
+            button.disabled = true;
+            status.textContent = '正在通过Everything查找同名视频...';
+            list.textContent = '';
+            try {
+                const response = await fetch('/api/subtitle/video?' + new URLSearchParams({ path: subtitlePath }));
+                if (!response.ok) throw new Error(await response.text());
+                const result = await response.json();
+                if (result.count === 0) {
+                    status.textContent = '没有找到名为 ' + result.stems.join(' 或 ') + ' 的视频';
+                } else if (result.count === 1 || result.videos[0].sameDir && !result.videos[1].sameDir) {
+                    status.textContent = '';
+                    window.open(result.videos[0].playerUrl, '_blank');
+                } else {
+                    status.textContent = '找到' + result.count + '个同名视频，请选择：';
+                    result.videos.forEach(v => {
+                        const a = document.createElement('a');
+                        a.href = v.playerUrl;
+                        a.target = '_blank';
+                        a.textContent = '▶ ' + v.path;
+                        list.appendChild(a);
+                    });
+                }
+            } catch (error) {
+                status.textContent = '查找失败: ' + error.message;
+            } finally {
+                button.disabled = false;
+            }
+        });
+    </script>
+</body>
+</html>`
+	return &Preview{ContentType: "text/html; charset=utf-8", Body: io.NopCloser(strings.NewReader(page))}, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSubtitle(t *testing.T) {
+	// 第二条和第三条之间没有空行
+	srt := "1\r\n00:00:01,500 --> 00:00:03,000\r\n<i>Hello</i> &amp; {\\an8}welcome\r\n\r\n2\r\n00:01:02,000 --> 00:01:04,250\r\n第二行\r\n3\r\n01:00:00,000 --> 01:00:01,000\r\nBye\r\n"
+	cues, err := parseSubtitle(".srt", srt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []subtitleCue{
+		{Start: 1500 * time.Millisecond, End: 3 * time.Second, Text: "Hello & welcome"},
+		{Start: 62 * time.Second, End: 64250 * time.Millisecond, Text: "第二行"},
+		{Start: time.Hour, End: time.Hour + time.Second, Text: "Bye"},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("srt cues = %+v", cues)
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("srt cue %d = %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+
+	ass := `[Script Info]
+Title: demo
+
+[V4+ Styles]
+Format: Name, Fontname
+Style: Default,Arial
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Comment: 0,0:00:00.00,0:00:01.00,Default,,0,0,0,,note
+Dialogue: 0,0:00:05.20,0:00:07.00,Default,Alice,0,0,0,,{\b1}Hi,\Nthere
+Dialogue: 0,0:00:02.00,0:00:04.00,Default,,0,0,0,,First
+`
+	cues, err = parseSubtitle(".ass", ass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cues) != 2 || cues[0].Text != "First" || cues[1] != (subtitleCue{Start: 5200 * time.Millisecond, End: 7 * time.Second, Speaker: "Alice", Text: "Hi,\nthere"}) {
+		t.Errorf("ass cues = %+v", cues)
+	}
+
+	// 没有百分秒的ASS时间
+	cues, err = parseSubtitle(".ass", "[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\nDialogue: 0,0:00:01,0:00:02,Default,,0,0,0,,Hi\n")
+	if err != nil || len(cues) != 1 || cues[0] != (subtitleCue{Start: time.Second, End: 2 * time.Second, Text: "Hi"}) {
+		t.Errorf("ass cues without centiseconds = %+v, err = %v", cues, err)
+	}
+
+	cues, err = parseSubtitle(".vtt", "WEBVTT\n\nNOTE comment\n\nintro\n00:05.000 --> 00:06.500\nShort form\n")
+	if err != nil || len(cues) != 1 || cues[0].Start != 5*time.Second || cues[0].Text != "Short form" {
+		t.Errorf("vtt cues = %+v, err = %v", cues, err)
+	}
+
+	if _, err := parseSubtitle(".srt", "not a subtitle"); err == nil {
+		t.Error("expected error for invalid subtitle")
+	}
+
+	var b strings.Builder
+	writeWebVTT(&b, []subtitleCue{{Start: 3723004 * time.Millisecond, End: 3724 * time.Second, Speaker: "A<B", Text: "x --> y <z>\n\nw"}})
+	if got := b.String(); got != "WEBVTT\n\n01:02:03.004 --> 01:02:04.000\n<v A&lt;B>x --&gt; y &lt;z&gt;\nw\n\n" {
+		t.Errorf("webvtt = %q", got)
+	}
+}
+
+func TestSubtitleVideoStems(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"Movie.2019.srt", "Movie.2019"},
+		{"Movie.2019.chs.srt", "Movie.2019.chs|Movie.2019"},
+		{"Show.S01E02.en.forced.ass", "Show.S01E02.en.forced|Show.S01E02.en|Show.S01E02"},
+		{"ep01.zh-CN.vtt", "ep01.zh-CN|ep01"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(subtitleVideoStems(tt.name), "|"); got != tt.want {
+			t.Errorf("%s: stems = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSubtitlePlayback(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "Movie", "Movie.chs.srt")
+	os.MkdirAll(filepath.Dir(sub), 0755)
+	os.WriteFile(sub, []byte("1\n00:00:01,000 --> 00:00:02,000\n你好\n"), 0644)
+	videos := createTestFiles(t, dir, filepath.Join("Other", "Movie.mp4"), filepath.Join("Movie", "Movie.mkv"), filepath.Join("Movie", "Movie.nfo"))
+
+	previous := searchBackend
+	searchBackend = wfnBackend(append(videos, sub))
+	t.Cleanup(func() { searchBackend = previous })
+
+	rec := serveTestRequest(subtitleHandler, httptest.NewRequest(http.MethodGet, "/subtitle/"+url.PathEscape(sub), nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/vtt; charset=utf-8" || !strings.Contains(rec.Body.String(), "00:00:01.000 --> 00:00:02.000\n你好") {
+		t.Errorf("webvtt: status %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	// 同一文件夹中的视频排在前面，不是视频的同名文件被忽略
+	rec = serveTestRequest(apiSubtitleVideoHandler, httptest.NewRequest(http.MethodGet, "/api/subtitle/video?path="+url.QueryEscape(sub), nil))
+	var result struct {
+		Videos []struct {
+			Path      string `json:"path"`
+			SameDir   bool   `json:"sameDir"`
+			PlayerURL string `json:"playerUrl"`
+		} `json:"videos"`
+	}
+	decodeTestJSON(t, rec, &result)
+	if len(result.Videos) != 2 || result.Videos[0].Path != videos[1] || !result.Videos[0].SameDir || result.Videos[1].Path != videos[0] {
+		t.Fatalf("videos = %+v", result.Videos)
+	}
+
+	player := httptest.NewRequest(http.MethodGet, result.Videos[0].PlayerURL, nil)
+	rec = serveTestRequest(videoPlayerHandler, player)
+	if want := `<track kind="subtitles" src="/subtitle/` + url.PathEscape(sub) + `"`; rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("player: status %d, missing %s", rec.Code, want)
+	}
+
+	rec = serveTestRequest(subtitleHandler, httptest.NewRequest(http.MethodGet, "/subtitle/"+url.PathEscape(videos[2]), nil))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("nfo: status = %d, want 415", rec.Code)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SVG净化
+//
+// SVG可以包含脚本、事件属性和外部引用，按原类型内联返回会在本站执行脚本（见disposition.go），
+// 因此/file/把SVG作为纯文本返回。GET /svg/文件路径 在服务器上净化后按image/svg+xml返回，
+// 供图片预览和图片查看器显示：
+//   - 去掉script、foreignObject、iframe等元素及其内容，以及修改链接的set/animate
+//   - 去掉on*事件属性；href只保留本文件内的#引用和内嵌的位图（data:image/png等）
+//   - 样式和属性中的url()只保留#引用，去掉@import、处理指令（如xml-stylesheet）和DOCTYPE
+// 同时设置不允许任何脚本和外部资源的CSP，净化遗漏时浏览器也不会执行。
+
+const maxSVGSize = 20 << 20
+
+// 去掉元素及其全部内容
+var svgDroppedElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"audio":         true,
+	"video":         true,
+	"handler":       true,
+	"listener":      true,
+}
+
+var (
+	svgCSSURL     = regexp.MustCompile(`(?i)url\(\s*(['"]?)([^'")]*)['"]?\s*\)`)
+	svgCSSImport  = regexp.MustCompile(`(?i)@import[^;]*;?`)
+	svgSafeDataRe = regexp.MustCompile(`(?i)^data:image/(png|jpeg|gif|webp|avif);`)
+)
+
+// 引用是否安全：本文件内的#引用或内嵌的位图
+func isSafeSVGRef(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	return strings.HasPrefix(ref, "#") || svgSafeDataRe.MatchString(ref)
+}
+
+// 去掉CSS中的@import和外部url()
+func sanitizeSVGStyle(css string) string {
+	css = svgCSSImport.ReplaceAllString(css, "")
+	return svgCSSURL.ReplaceAllStringFunc(css, func(m string) string {
+		if isSafeSVGRef(svgCSSURL.FindStringSubmatch(m)[2]) {
+			return m
+		}
+		return "none"
+	})
+}
+
+// 属性是否保留，值为净化后的值
+func sanitizeSVGAttr(attr xml.Attr) (string, bool) {
+	name := strings.ToLower(attr.Name.Local)
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return "", false
+	case name == "href" || name == "src" || name == "base" && attr.Name.Space == "xml":
+		return attr.Value, isSafeSVGRef(attr.Value)
+	}
+	return sanitizeSVGStyle(attr.Value), true
+}
+
+// 修改链接或事件属性的动画可以把href改为javascript:
+func isDangerousSVGAnimation(start xml.StartElement) bool {
+	switch strings.ToLower(start.Name.Local) {
+	case "set", "animate", "animatemotion", "animatetransform", "animatecolor":
+	default:
+		return false
+	}
+	for _, attr := range start.Attr {
+		if strings.EqualFold(attr.Name.Local, "attributeName") {
+			target := strings.ToLower(attr.Value)
+			target = target[strings.LastIndex(target, ":")+1:]
+			return target == "href" || target == "src" || strings.HasPrefix(target, "on")
+		}
+	}
+	return false
+}
+
+func svgName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// 净化SVG文档，根元素不是svg或不是有效的XML时返回错误
+func sanitizeSVG(r io.Reader) ([]byte, error) {
+	dec := xml.NewDecoder(r)
+	dec.Entity = xml.HTMLEntity // 手写的SVG中常有&nbsp;等HTML实体
+	var out bytes.Buffer
+	var open []xml.Name // 未结束的元素，RawToken不检查开始和结束标签是否匹配
+	dropDepth := 0      // 正在跳过的元素所在深度，0表示没有跳过
+	inStyle := false
+	rootSeen := false
+
+	for {
+		// RawToken保留原来的命名空间前缀，输出时不需要重新生成xmlns声明
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			open = append(open, t.Name)
+			depth := len(open)
+			if dropDepth > 0 {
+				continue
+			}
+			if depth == 1 {
+				if rootSeen || !strings.EqualFold(t.Name.Local, "svg") {
+					return nil, fmt.Errorf("根元素不是svg")
+				}
+				rootSeen = true
+			}
+			if svgDroppedElements[strings.ToLower(t.Name.Local)] || isDangerousSVGAnimation(t) {
+				dropDepth = depth
+				continue
+			}
+			inStyle = strings.EqualFold(t.Name.Local, "style")
+			out.WriteString("<" + svgName(t.Name))
+			for _, attr := range t.Attr {
+				if value, ok := sanitizeSVGAttr(attr); ok {
+					out.WriteString(" " + svgName(attr.Name) + `="`)
+					xml.EscapeText(&out, []byte(value))
+					out.WriteString(`"`)
+				}
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return nil, fmt.Errorf("结束标签</%s>与开始标签不匹配", svgName(t.Name))
+			}
+			depth := len(open)
+			open = open[:depth-1]
+			if dropDepth > 0 {
+				if depth == dropDepth {
+					dropDepth = 0
+				}
+				continue
+			}
+			inStyle = false
+			out.WriteString("</" + svgName(t.Name) + ">")
+		case xml.CharData:
+			if dropDepth > 0 || len(open) == 0 {
+				continue
+			}
+			text := []byte(t)
+			if inStyle {
+				text = []byte(sanitizeSVGStyle(string(text)))
+			}
+			xml.EscapeText(&out, text)
+		}
+		// 注释、处理指令和DOCTYPE都不输出
+	}
+	if !rootSeen {
+		return nil, fmt.Errorf("不是SVG文件")
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("元素<%s>没有结束", svgName(open[len(open)-1]))
+	}
+	return out.Bytes(), nil
+}
+
+// 净化后的SVG /svg/文件路径
+func svgHandler(w http.ResponseWriter, r *http.Request) {
+	filePath := decodeRequestPath(strings.TrimPrefix(r.URL.Path, "/svg/"))
+	if remoteEverythingEnabled() {
+		http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, err := statPath(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "文件不存在", http.StatusNotFound)
+		} else {
+			http.Error(w, "访问文件失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if info.IsDir() || !strings.EqualFold(filepath.Ext(filePath), ".svg") {
+		http.Error(w, "不是SVG文件", http.StatusUnsupportedMediaType)
+		return
+	}
+	if info.Size() > maxSVGSize {
+		http.Error(w, fmt.Sprintf("SVG文件超过%dMB", maxSVGSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	file, err := openPath(filePath)
+	if err != nil {
+		http.Error(w, "无法打开文件", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	data, err := sanitizeSVG(file)
+	if err != nil {
+		log.Printf("SVG净化失败: %s, 错误: %v", filePath, err)
+		http.Error(w, "无法解析SVG: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:; sandbox")
+	w.Header().Set("Content-Disposition", contentDisposition("inline", filepath.Base(filePath)))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	src := `<?xml version="1.0"?>
+<?xml-stylesheet href="http://evil.example/x.css"?>
+<!DOCTYPE svg>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" onload="alert(1)" width="10">
+  <style>@import url(http://evil.example/a.css); .a { fill: url(#grad); background: url('http://evil.example/t.png') }</style>
+  <script>alert(2)</script>
+  <foreignObject><div xmlns="http://www.w3.org/1999/xhtml"><script>alert(3)</script></div></foreignObject>
+  <defs><linearGradient id="grad"><stop offset="0"/></linearGradient></defs>
+  <a xlink:href="javascript:alert(4)"><rect class="a" fill="url(https://evil.example/f)" width="5" height="5"/></a>
+  <a href="#grad"><set attributeName="xlink:href" to="javascript:alert(5)"/><text>A &amp; B&nbsp;</text></a>
+  <image href="data:image/png;base64,iVBORw0KGgo=" />
+  <use href="http://evil.example/sprite.svg#icon"/>
+  <animate attributeName="fill" values="red;blue" dur="1s"/>
+</svg>`
+	out, err := sanitizeSVG(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, bad := range []string{"alert", "evil.example", "onload", "<script", "foreignObject", "<set", "xml-stylesheet", "DOCTYPE", "@import"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("sanitized SVG still contains %q:\n%s", bad, got)
+		}
+	}
+	for _, want := range []string{`xmlns:xlink="http://www.w3.org/1999/xlink"`, "url(#grad)", `<a href="#grad">`, "A &amp; B\u00a0", `href="data:image/png;base64,iVBORw0KGgo="`, `<animate attributeName="fill"`, `width="10"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("sanitized SVG lost %q:\n%s", want, got)
+		}
+	}
+
+	for _, bad := range []string{"<html><body/></html>", "<svg><g></svg>", "<svg></svg><a/>", "plain text"} {
+		if _, err := sanitizeSVG(strings.NewReader(bad)); err == nil {
+			t.Errorf("sanitizeSVG(%q): expected error", bad)
+		}
+	}
+}
+
+func TestSVGHandler(t *testing.T) {
+	dir := t.TempDir()
+	svg := filepath.Join(dir, "logo.svg")
+	os.WriteFile(svg, []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script><circle r="4"/></svg>`), 0644)
+	paths := createTestFiles(t, dir, "broken.svg", "photo.png")
+
+	rec := serveTestRequest(svgHandler, httptest.NewRequest(http.MethodGet, "/svg/"+url.PathEscape(svg), nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("status %d, type %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body := rec.Body.String(); strings.Contains(body, "script") || !strings.Contains(body, `<circle r="4">`) {
+		t.Errorf("body = %s", body)
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'none'") {
+		t.Errorf("CSP = %q", csp)
+	}
+
+	if rec = serveTestRequest(svgHandler, httptest.NewRequest(http.MethodGet, "/svg/"+url.PathEscape(paths[0]), nil)); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("broken svg: status = %d, want 422", rec.Code)
+	}
+	if rec = serveTestRequest(svgHandler, httptest.NewRequest(http.MethodGet, "/svg/"+url.PathEscape(paths[1]), nil)); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("png: status = %d, want 415", rec.Code)
+	}
+
+	// 图片查看器显示净化后的SVG
+	rec = serveTestRequest(imageViewerHandler, httptest.NewRequest(http.MethodGet, "/imageview/"+url.PathEscape(svg), nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `src="/svg/`+url.QueryEscape(svg)+`"`) {
+		t.Errorf("imageview: status %d, svg not loaded from /svg/", rec.Code)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 文件夹同步（类似rsync的差异清单）
+//
+//   GET  /api/sync/manifest?path=文件夹&hash=sha256   递归列出文件夹中所有文件的相对路径、大小和修改时间，
+//                                                   hash参数时附带校验和（使用校验和缓存，见checksum.go）
+//   POST /api/sync/pull {"path": "文件夹", "files": ["相对路径", ...]}   把这些文件打包为tar流返回
+// 客户端比较清单和本地文件，只拉取新增或变化的文件；tar中保留修改时间，下次比较时不会被当作变化。
+// 排除规则同样适用。内置的mirror子命令（见mirror.go）使用这两个接口镜像文件夹。
+
+const (
+	maxSyncManifestEntries = 200000
+	maxSyncPullFiles       = 10000
+)
+
+type SyncEntry struct {
+	Path  string `json:"path"` // 相对路径，使用/分隔
+	Size  int64  `json:"size"`
+	MTime int64  `json:"mtime"` // Unix秒
+	Dir   bool   `json:"dir,omitempty"`
+	Hash  string `json:"hash,omitempty"`
+}
+
+type SyncManifest struct {
+	Root      string      `json:"root"`
+	Hash      string      `json:"hash,omitempty"` // 校验和算法
+	Entries   []SyncEntry `json:"entries"`
+	Files     int         `json:"files"`
+	TotalSize int64       `json:"totalSize"`
+	Errors    []string    `json:"errors,omitempty"`
+	Generated string      `json:"generated"`
+}
+
+var (
+	errSyncTooManyEntries = fmt.Errorf("文件数超过%d，请同步更小的文件夹", maxSyncManifestEntries)
+	errSyncStreamBroken   = errors.New("已写出部分内容，tar流无法继续")
+)
+
+// 同步的根文件夹
+func syncRoot(clientRoot string) (string, error) {
+	if clientRoot == "" {
+		return "", fmt.Errorf("path参数不能为空")
+	}
+	if remoteEverythingEnabled() {
+		return "", errRemoteFile
+	}
+	root := resolveClientPath(clientRoot)
+	info, err := statPath(root)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("不是文件夹: %s", clientRoot)
+	}
+	return root, nil
+}
+
+// 把相对路径解析为根文件夹中的路径，拒绝绝对路径和跳出根文件夹的路径
+func syncFilePath(root, rel string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if rel == "" || filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("无效的相对路径: %s", rel)
+	}
+	return filepath.Join(root, clean), nil
+}
+
+// 递归生成文件夹的清单
+func buildSyncManifest(r *http.Request, root, algo string) (*SyncManifest, error) {
+	manifest := &SyncManifest{Root: clientPath(root), Hash: algo, Entries: []SyncEntry{}}
+	ctx := r.Context()
+
+	err := filepath.Walk(extendedPath(root), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			manifest.Errors = append(manifest.Errors, fmt.Sprintf("%s: %v", displayPath(path), err))
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		full := displayPath(path)
+		rel, err := filepath.Rel(root, full)
+		if err != nil || rel == "." {
+			return nil
+		}
+		if isExcludedPath(full) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(manifest.Entries) >= maxSyncManifestEntries {
+			return errSyncTooManyEntries
+		}
+
+		entry := SyncEntry{Path: filepath.ToSlash(rel), MTime: info.ModTime().Unix()}
+		switch {
+		case info.IsDir():
+			entry.Dir = true
+		case info.Mode().IsRegular():
+			entry.Size = info.Size()
+			if algo != "" {
+				sum, err := fileChecksum(ctx, full, info, algo)
+				if err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					manifest.Errors = append(manifest.Errors, fmt.Sprintf("%s: %v", full, err))
+				}
+				entry.Hash = sum
+			}
+			manifest.Files++
+			manifest.TotalSize += entry.Size
+		default:
+			return nil // 符号链接、设备等不同步
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(manifest.Entries, func(i, j int) bool { return manifest.Entries[i].Path < manifest.Entries[j].Path })
+	manifest.Generated = time.Now().Format("2006-01-02 15:04:05")
+	return manifest, nil
+}
+
+// 同步清单API处理器
+func apiSyncManifestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	root, err := syncRoot(r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	algo := r.URL.Query().Get("hash")
+	if algo != "" && checksumAlgorithms[algo] == nil {
+		http.Error(w, "不支持的校验算法: "+algo, http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	manifest, err := buildSyncManifest(r, root, algo)
+	// 大文件夹的遍历和计算校验和会超过请求开始时设置的写超时
+	restartWriteDeadline(w, apiWriteTimeout)
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消生成同步清单: %s", root)
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSyncTooManyEntries) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, "生成同步清单失败: "+err.Error(), status)
+		return
+	}
+
+	log.Printf("同步清单: %s, %d个文件, 总大小%d, 用时%v, 来源IP: %s", root, manifest.Files, manifest.TotalSize, time.Since(start).Round(time.Millisecond), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// 批量拉取API处理器：按请求的顺序把文件写入tar流，无法读取的文件跳过，客户端比较后重新拉取
+func apiSyncPullHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Path  string   `json:"path"`
+		Files []string `json:"files"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Files) == 0 {
+		http.Error(w, "请求格式错误，需要path和files参数", http.StatusBadRequest)
+		return
+	}
+	if len(req.Files) > maxSyncPullFiles {
+		http.Error(w, fmt.Sprintf("每次最多拉取%d个文件", maxSyncPullFiles), http.StatusBadRequest)
+		return
+	}
+	root, err := syncRoot(req.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	paths := make([]string, len(req.Files))
+	for i, rel := range req.Files {
+		if paths[i], err = syncFilePath(root, rel); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	log.Printf("同步拉取: %s, %d个文件, 来源IP: %s", root, len(paths), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Cache-Control", "no-store")
+
+	tw := tar.NewWriter(newTransferWriter(w, r))
+	var sent, skipped int
+	var written int64
+	for i, path := range paths {
+		if r.Context().Err() != nil {
+			log.Printf("客户端已断开，取消同步拉取: %s", root)
+			return
+		}
+		n, err := writeSyncTarEntry(tw, path, filepath.ToSlash(filepath.Clean(filepath.FromSlash(req.Files[i]))))
+		if errors.Is(err, errSyncStreamBroken) {
+			// 中断连接，客户端比较后重新拉取
+			log.Printf("同步拉取中断: %s, 错误: %v", path, err)
+			return
+		}
+		if err != nil {
+			skipped++
+			continue
+		}
+		sent++
+		written += n
+	}
+	if err := tw.Close(); err != nil {
+		log.Printf("同步拉取结束时出错: %v", err)
+		return
+	}
+	log.Printf("同步拉取完成: %s, 发送%d个文件(%d字节), 跳过%d个", root, sent, written, skipped)
+}
+
+// 把一个文件写入tar流，返回写出的内容字节数；写出头部后出错时返回errSyncStreamBroken
+func writeSyncTarEntry(tw *tar.Writer, path, name string) (int64, error) {
+	if isExcludedPath(path) {
+		return 0, os.ErrPermission
+	}
+	file, err := openPath(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, fmt.Errorf("不是普通文件: %s", path)
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     info.Size(),
+		Mode:     0644,
+		ModTime:  info.ModTime(),
+		Format:   tar.FormatPAX, // 支持长文件名和非ASCII文件名
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return 0, fmt.Errorf("%w: %v", errSyncStreamBroken, err)
+	}
+	n, err := io.CopyN(tw, file, info.Size())
+	if err != nil {
+		return n, fmt.Errorf("%w: %v", errSyncStreamBroken, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncFilePath(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"", "..", "../x", "a/../../x", "/etc/passwd"} {
+		if _, err := syncFilePath(root, rel); err == nil {
+			t.Errorf("syncFilePath(%q) accepted, want error", rel)
+		}
+	}
+	if got, err := syncFilePath(root, "a/./b.txt"); err != nil || got != filepath.Join(root, "a", "b.txt") {
+		t.Errorf("syncFilePath(a/./b.txt) = %q, %v", got, err)
+	}
+}
+
+func TestMirrorSync(t *testing.T) {
+	remote := filepath.Join(t.TempDir(), "remote")
+	local := filepath.Join(t.TempDir(), "local")
+	createTestFiles(t, remote, "a.txt", "sub/b.txt", "sub/deep/c.txt")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/sync/manifest", apiSyncManifestHandler)
+	mux.HandleFunc("/api/sync/pull", apiSyncPullHandler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// 首次镜像复制全部文件并保留修改时间
+	if code := runMirror([]string{"-url", server.URL, remote, local}); code != 0 {
+		t.Fatalf("first mirror exit code = %d", code)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/deep/c.txt"} {
+		src, _ := os.Stat(filepath.Join(remote, name))
+		dst, err := os.Stat(filepath.Join(local, name))
+		if err != nil || dst.Size() != src.Size() || dst.ModTime().Unix() != src.ModTime().Unix() {
+			t.Errorf("%s not mirrored (err: %v)", name, err)
+		}
+	}
+
+	// 修改一个文件、添加一个本地多余的文件，第二次只拉取变化的文件
+	later := time.Now().Add(time.Hour)
+	changed := filepath.Join(remote, "sub", "b.txt")
+	os.WriteFile(changed, []byte("changed"), 0644)
+	os.Chtimes(changed, later, later)
+	os.WriteFile(filepath.Join(local, "extra.txt"), []byte("x"), 0644)
+
+	root, err := syncRoot(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := buildSyncManifest(httptest.NewRequest(http.MethodGet, "/", nil), root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pull []string
+	scanned := scanMirrorLocal(local)
+	for _, e := range manifest.Entries {
+		if info, ok := scanned[e.Path]; !e.Dir && (!ok || mirrorChanged(filepath.Join(local, e.Path), info, e, "")) {
+			pull = append(pull, e.Path)
+		}
+	}
+	if strings.Join(pull, ",") != "sub/b.txt" {
+		t.Errorf("changed files = %v, want [sub/b.txt]", pull)
+	}
+
+	if code := runMirror([]string{"-url", server.URL, "-delete", "-hash", "sha256", remote, local}); code != 0 {
+		t.Fatalf("second mirror exit code = %d", code)
+	}
+	if data, _ := os.ReadFile(filepath.Join(local, "sub", "b.txt")); string(data) != "changed" {
+		t.Errorf("sub/b.txt = %q, want %q", data, "changed")
+	}
+	if _, err := os.Stat(filepath.Join(local, "extra.txt")); !os.IsNotExist(err) {
+		t.Errorf("extra.txt should be deleted, err = %v", err)
+	}
+
+	// 跳出根文件夹的路径
+	body, _ := json.Marshal(map[string]interface{}{"path": remote, "files": []string{"../secret.txt"}})
+	rec := serveTestRequest(apiSyncPullHandler, httptest.NewRequest(http.MethodPost, "/api/sync/pull", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("path traversal: status = %d, want 400", rec.Code)
+	}
+}
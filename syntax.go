@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"everything-web-server/pkg/everything"
+)
+
+// 搜索语法说明和自动补全
+//
+// GET /api/syntax                 当前Everything版本支持的运算符、修饰符、函数和宏
+// GET /api/autocomplete?q=输入内容  补全建议：搜索历史、语法关键字、常见扩展名（ext:之后）和文件夹名
+//
+// 补全只针对最后一个词：输入"D:\Vid"补全为文件夹路径，"ext:m"补全扩展名，"dm:"补全日期常量，
+// 其他词在Everything中查找以它开头的文件夹名。
+
+const maxAutocomplete = 15
+
+type SyntaxItem struct {
+	Syntax      string `json:"syntax"`
+	Kind        string `json:"kind"` // operator, modifier, function, macro, constant
+	Description string `json:"description"`
+	Example     string `json:"example,omitempty"`
+	Since       string `json:"since,omitempty"` // 需要的Everything版本，为空时1.4即可
+}
+
+var everythingSyntax = []SyntaxItem{
+	{"空格", "operator", "与（AND）", "abc 123", ""},
+	{"|", "operator", "或（OR）", "*.jpg | *.png", ""},
+	{"!", "operator", "非（NOT）", "!temp", ""},
+	{"< >", "operator", "分组", "<*.jpg | *.png> photo", ""},
+	{`" "`, "operator", "搜索包含空格的词组", `"my documents"`, ""},
+	{"*", "operator", "匹配零个或多个字符", "*.mp4", ""},
+	{"?", "operator", "匹配一个字符", "img_????.jpg", ""},
+	{"case:", "modifier", "区分大小写", "case:README", ""},
+	{"file:", "modifier", "只匹配文件", "file:report", ""},
+	{"folder:", "modifier", "只匹配文件夹", "folder:photos", ""},
+	{"path:", "modifier", "匹配完整路径", `path:D:\Music`, ""},
+	{"regex:", "modifier", "正则表达式", `regex:^IMG_\d+\.jpg$`, ""},
+	{"wfn:", "modifier", "匹配完整文件名", "wfn:desktop.ini", ""},
+	{"wholeword:", "modifier", "匹配整个单词", "ww:log", ""},
+	{"diacritics:", "modifier", "区分变音符号", "diacritics:café", ""},
+	{"nopath:", "modifier", "不匹配路径", "nopath:backup", ""},
+	{"ext:", "function", "扩展名，多个用分号分隔", "ext:mp4;mkv", ""},
+	{"size:", "function", "文件大小", "size:>1gb", ""},
+	{"dm:", "function", "修改日期", "dm:thisweek", ""},
+	{"dc:", "function", "创建日期", "dc:2024", ""},
+	{"da:", "function", "访问日期", "da:today", ""},
+	{"attrib:", "function", "文件属性（R只读 H隐藏 S系统 D文件夹 A存档）", "attrib:H", ""},
+	{"parent:", "function", "直接位于该文件夹中（不含子文件夹）", `parent:D:\Downloads`, ""},
+	{"child:", "function", "包含指定名称子项的文件夹", "child:.git", ""},
+	{"childcount:", "function", "子项数量", "folder: childcount:0", ""},
+	{"empty:", "function", "空文件夹", "empty:", ""},
+	{"dupe:", "function", "文件名重复的文件", "dupe: ext:jpg", ""},
+	{"len:", "function", "文件名长度", "len:>100", ""},
+	{"startwith:", "function", "文件名以指定内容开头", "startwith:IMG", ""},
+	{"endwith:", "function", "文件名以指定内容结尾", "endwith:_final", ""},
+	{"depth:", "function", "路径层级", "depth:2", ""},
+	{"root:", "function", "位于卷根目录", "root:", ""},
+	{"width:", "function", "图片宽度", "width:>=1920", ""},
+	{"height:", "function", "图片高度", "height:>=1080", ""},
+	{"audio:", "macro", "音频文件", "audio: beethoven", ""},
+	{"video:", "macro", "视频文件", "video: dm:thismonth", ""},
+	{"pic:", "macro", "图片文件", "pic: size:>5mb", ""},
+	{"doc:", "macro", "文档文件", "doc: report", ""},
+	{"zip:", "macro", "压缩文件", "zip: backup", ""},
+	{"exe:", "macro", "可执行文件", "exe: setup", ""},
+	{"today", "constant", "今天", "dm:today", ""},
+	{"yesterday", "constant", "昨天", "dm:yesterday", ""},
+	{"thisweek", "constant", "本周", "dm:thisweek", ""},
+	{"thismonth", "constant", "本月", "dm:thismonth", ""},
+	{"thisyear", "constant", "今年", "dm:thisyear", ""},
+	{"last2weeks", "constant", "最近两周（可换成其他数字和单位）", "dm:last2weeks", ""},
+	{"empty", "constant", "空文件（大小）", "size:empty", ""},
+	{"tiny", "constant", "0-10KB", "size:tiny", ""},
+	{"small", "constant", "10-100KB", "size:small", ""},
+	{"medium", "constant", "100KB-1MB", "size:medium", ""},
+	{"large", "constant", "1-16MB", "size:large", ""},
+	{"huge", "constant", "16-128MB", "size:huge", ""},
+	{"gigantic", "constant", "大于128MB", "size:gigantic", ""},
+	{"length:", "function", "音视频时长", "length:>1:00:00", "1.5"},
+	{"bitrate:", "function", "音视频码率", "bitrate:>320kbps", "1.5"},
+	{"artist:", "function", "艺术家", "artist:beatles", "1.5"},
+	{"album:", "function", "专辑", "album:abbey", "1.5"},
+	{"title:", "function", "标题", "title:yesterday", "1.5"},
+	{"content:", "function", "文件内容（需要读取文件，较慢）", "ext:txt content:password", ""},
+	{"sort:", "modifier", "结果排序", "sort:size-descending", "1.5"},
+	{"tag:", "function", "本服务器中设置的标签（由服务器处理）", "tag:work", ""},
+	{"fuzzy:", "modifier", "模糊搜索，容忍拼写错误，结果按相似度排序（由服务器处理，需放在开头）", "fuzzy:vaction photos", ""},
+	{"py:", "modifier", "拼音首字母搜索中文文件名，其余部分为普通查询（由服务器处理，需放在开头）", "py:bjld ext:mp3", ""},
+}
+
+// 日期函数（dm:、dc:、da:）和大小函数（size:）之后可用的常量
+var syntaxConstants = map[string][]string{
+	"dm:":   {"today", "yesterday", "thisweek", "lastweek", "thismonth", "lastmonth", "thisyear", "lastyear"},
+	"dc:":   {"today", "yesterday", "thisweek", "lastweek", "thismonth", "lastmonth", "thisyear", "lastyear"},
+	"da:":   {"today", "yesterday", "thisweek", "lastweek", "thismonth", "lastmonth", "thisyear", "lastyear"},
+	"size:": {"empty", "tiny", "small", "medium", "large", "huge", "gigantic", ">1mb", ">100mb", ">1gb"},
+}
+
+var commonExtensions = []string{
+	"mp4", "mkv", "avi", "mov", "wmv", "flv", "webm", "ts", "m4v",
+	"mp3", "flac", "wav", "aac", "m4a", "ogg",
+	"jpg", "jpeg", "png", "gif", "bmp", "webp", "heic", "raw", "psd", "svg",
+	"pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "txt", "md", "epub",
+	"zip", "rar", "7z", "iso", "tar", "gz",
+	"exe", "msi", "bat", "ps1", "lnk", "dll",
+	"go", "py", "js", "html", "css", "json", "xml", "log", "ini", "torrent", "srt", "ass",
+}
+
+// 当前Everything是否为1.5
+func everythingIs15() bool {
+	status := getToolStatus().Everything
+	return strings.HasPrefix(status.Version, "1.5") || status.Properties
+}
+
+// 语法说明API处理器
+func apiSyntaxHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	is15 := everythingIs15()
+	items := make([]SyntaxItem, 0, len(everythingSyntax))
+	for _, item := range everythingSyntax {
+		if item.Since == "1.5" && !is15 {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"version": getToolStatus().Everything.Version,
+		"items":   items,
+		"count":   len(items),
+	})
+}
+
+type Suggestion struct {
+	Text   string `json:"text"`             // 补全后的完整查询
+	Label  string `json:"label"`            // 显示的内容
+	Kind   string `json:"kind"`             // history, syntax, extension, folder
+	Detail string `json:"detail,omitempty"` // 说明
+}
+
+// 在Everything中查找以prefix开头的文件夹，最多返回limit个
+func findFolderNames(ctx context.Context, prefix string, limit int) []string {
+	query := `folder: startwith:"` + prefix + `"`
+	var paths []string
+	if !remoteEverythingEnabled() && everythingInstanceName() == "" && initEverythingSDK() == nil {
+		// 同名文件夹去重后可能不足，多取一些
+		if results, err := everythingClient.Search(ctx, everything.Query{Search: query, Max: limit * 4}); err == nil {
+			paths, _ = results.Paths()
+		}
+	} else {
+		paths, _ = runEverythingQuery(ctx, query)
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			names = append(names, name)
+			if len(names) >= limit {
+				break
+			}
+		}
+	}
+	return names
+}
+
+// 补全路径：列出父文件夹中以输入的名称开头的子文件夹
+func completePath(token string, limit int) []string {
+	dir, prefix := filepath.Split(token)
+	if dir == "" {
+		return nil
+	}
+	entries, err := readDirPath(dir)
+	if err != nil {
+		return nil
+	}
+	var list []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(strings.ToLower(entry.Name()), strings.ToLower(prefix)) {
+			list = append(list, dir+entry.Name()+`\`)
+			if len(list) >= limit {
+				break
+			}
+		}
+	}
+	return list
+}
+
+// 生成补全建议
+func buildSuggestions(ctx context.Context, input string, history []SearchHistoryEntry) []Suggestion {
+	var suggestions []Suggestion
+	seen := make(map[string]bool)
+	add := func(s Suggestion) {
+		if key := strings.ToLower(s.Text); !seen[key] && !strings.EqualFold(s.Text, input) && len(suggestions) < maxAutocomplete {
+			seen[key] = true
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	lowerInput := strings.ToLower(input)
+	for _, h := range history {
+		if strings.HasPrefix(strings.ToLower(h.Query), lowerInput) {
+			add(Suggestion{Text: h.Query, Label: h.Query, Kind: "history", Detail: h.Searched})
+		}
+	}
+
+	// 只补全最后一个词
+	cut := strings.LastIndexAny(input, " <|!") + 1
+	head, token := input[:cut], input[cut:]
+	lowerToken := strings.ToLower(token)
+	if token == "" {
+		return suggestions
+	}
+
+	switch {
+	case strings.HasPrefix(lowerToken, "ext:"):
+		// 多个扩展名用分号分隔，补全最后一个
+		exts := token[4:]
+		semi := strings.LastIndex(exts, ";") + 1
+		for _, ext := range commonExtensions {
+			if strings.HasPrefix(ext, strings.ToLower(exts[semi:])) {
+				add(Suggestion{Text: head + token[:4+semi] + ext, Label: "ext:" + exts[:semi] + ext, Kind: "extension"})
+			}
+		}
+
+	case strings.Contains(token, `\`):
+		for _, path := range completePath(strings.Trim(token, `"`), maxAutocomplete) {
+			text := path
+			if strings.Contains(path, " ") {
+				text = `"` + path + `"`
+			}
+			add(Suggestion{Text: head + text, Label: path, Kind: "folder"})
+		}
+
+	default:
+		if colon := strings.Index(lowerToken, ":"); colon >= 0 {
+			if constants, ok := syntaxConstants[lowerToken[:colon+1]]; ok {
+				for _, c := range constants {
+					if strings.HasPrefix(c, lowerToken[colon+1:]) {
+						add(Suggestion{Text: head + token[:colon+1] + c, Label: lowerToken[:colon+1] + c, Kind: "syntax"})
+					}
+				}
+			}
+			return suggestions
+		}
+
+		is15 := everythingIs15()
+		for _, item := range everythingSyntax {
+			if item.Kind != "constant" && strings.HasSuffix(item.Syntax, ":") && strings.HasPrefix(item.Syntax, lowerToken) &&
+				(item.Since == "" || is15) {
+				add(Suggestion{Text: head + item.Syntax, Label: item.Syntax, Kind: "syntax", Detail: item.Description})
+			}
+		}
+		if len([]rune(token)) >= 2 && len(suggestions) < maxAutocomplete {
+			for _, name := range findFolderNames(ctx, token, maxAutocomplete-len(suggestions)) {
+				text := name
+				if strings.Contains(name, " ") {
+					text = `"` + name + `"`
+				}
+				add(Suggestion{Text: head + text, Label: name, Kind: "folder"})
+			}
+		}
+	}
+	return suggestions
+}
+
+// 自动补全API处理器
+func apiAutocompleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	input := r.URL.Query().Get("q")
+	history := getHistory(getSessionID(w, r)).Searches
+	suggestions := buildSuggestions(r.Context(), input, history)
+	if suggestions == nil {
+		suggestions = []Suggestion{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":       input,
+		"suggestions": suggestions,
+	})
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 文件或文件夹的标签
+type TagEntry struct {
+	Path    string   `json:"path"`
+	Tags    []string `json:"tags"`
+	Updated string   `json:"updated"`
+}
+
+// 标签统计
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// 标签和收藏、评分等一样保存在数据目录的JSON文件中，没有使用SQLite：
+// 可用的SQLite驱动需要cgo（Windows上要另装gcc，build.bat的go build无法直接编译）或引入大量依赖。
+// 每次修改都重写整个文件（先写临时文件再改名，不会写出半个文件），几万条标签时也只有几MB
+const tagsFileName = "tags.json"
+
+var (
+	tagStore       = make(map[string]*TagEntry) // 小写路径 → 标签
+	tagsMutex      sync.RWMutex
+	tagStoreLoaded sync.Once
+)
+
+func tagKey(path string) string {
+	return strings.ToLower(filepath.Clean(path))
+}
+
+func ensureTagsLoaded() {
+	tagStoreLoaded.Do(func() {
+		tagsMutex.Lock()
+		defer tagsMutex.Unlock()
+		if err := loadJSONFile(tagsFileName, &tagStore); err != nil {
+			log.Printf("加载标签失败: %v", err)
+		}
+		if tagStore == nil {
+			tagStore = make(map[string]*TagEntry)
+		}
+	})
+}
+
+// 规范化标签：去掉首尾空格、转为小写、去重并排序
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// 获取路径的标签
+func getTags(path string) []string {
+	ensureTagsLoaded()
+
+	tagsMutex.RLock()
+	defer tagsMutex.RUnlock()
+
+	if entry, ok := tagStore[tagKey(path)]; ok {
+		return entry.Tags
+	}
+	return nil
+}
+
+// 修改路径的标签并保存，update返回新的标签列表
+func updateTags(path string, update func([]string) []string) (*TagEntry, error) {
+	ensureTagsLoaded()
+
+	tagsMutex.Lock()
+	defer tagsMutex.Unlock()
+
+	key := tagKey(path)
+	var current []string
+	if entry, ok := tagStore[key]; ok {
+		current = entry.Tags
+	}
+
+	entry := &TagEntry{
+		Path:    clientPath(path),
+		Tags:    normalizeTags(update(append([]string(nil), current...))),
+		Updated: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if len(entry.Tags) == 0 {
+		delete(tagStore, key)
+	} else {
+		tagStore[key] = entry
+	}
+
+	// 标签变化后，包含tag:条件的搜索缓存已过期
+	clearTagQueryCache()
+
+	return entry, saveJSONFile(tagsFileName, tagStore)
+}
+
+// 获取带有全部指定标签的路径
+func pathsWithTags(tags []string) []string {
+	ensureTagsLoaded()
+
+	tagsMutex.RLock()
+	defer tagsMutex.RUnlock()
+
+	var paths []string
+	for _, entry := range tagStore {
+		if hasAllTags(entry.Tags, tags) {
+			paths = append(paths, resolveClientPath(entry.Path))
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func hasAllTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// 从搜索词中分离 tag: 条件，如 "ext:mp4 tag:watch-later" → "ext:mp4", ["watch-later"]
+func splitTagQuery(query string) (string, []string) {
+	var rest []string
+	var tags []string
+	for _, field := range strings.Fields(query) {
+		if len(field) > 4 && strings.EqualFold(field[:4], "tag:") {
+			tags = append(tags, field[4:])
+		} else {
+			rest = append(rest, field)
+		}
+	}
+	return strings.Join(rest, " "), normalizeTags(tags)
+}
+
+// 执行带标签条件的搜索：没有其他条件时直接返回带标签的路径，否则在Everything结果中过滤
+func searchWithTags(ctx context.Context, rest string, tags []string) ([]string, error) {
+	tagged := pathsWithTags(tags)
+	if rest == "" || len(tagged) == 0 {
+		return tagged, nil
+	}
+
+	allPaths, err := runEverythingQuery(ctx, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	taggedSet := make(map[string]bool, len(tagged))
+	for _, path := range tagged {
+		taggedSet[tagKey(path)] = true
+	}
+
+	var paths []string
+	for _, path := range allPaths {
+		if taggedSet[tagKey(path)] {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// 清除包含tag:条件的搜索缓存
+func clearTagQueryCache() {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	for query := range searchCache {
+		if strings.Contains(strings.ToLower(query), "tag:") {
+			delete(searchCache, query)
+		}
+	}
+}
+
+// 标签API处理器
+// GET ?path= 获取路径的标签，不带path时返回所有标签及数量
+// POST {"paths":[...],"tags":[...]} 添加标签；PUT {"path","tags"} 替换标签
+// DELETE ?path=&tag= 删除标签，不带tag时删除该路径的所有标签
+func apiTagsHandler(w http.ResponseWriter, r *http.Request) {
+	ensureTagsLoaded()
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		if path := resolveClientPath(r.URL.Query().Get("path")); path != "" {
+			tags := getTags(path)
+			if tags == nil {
+				tags = []string{}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"path": clientPath(path),
+				"tags": tags,
+			})
+			return
+		}
+
+		tagsMutex.RLock()
+		counts := make(map[string]int)
+		for _, entry := range tagStore {
+			for _, tag := range entry.Tags {
+				counts[tag]++
+			}
+		}
+		tagsMutex.RUnlock()
+
+		list := []TagCount{}
+		for tag, count := range counts {
+			list = append(list, TagCount{Tag: tag, Count: count})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+			return list[i].Tag < list[j].Tag
+		})
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tags":  list,
+			"count": len(list),
+		})
+
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Path  string   `json:"path"`
+			Paths []string `json:"paths"`
+			Tags  []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Path != "" {
+			req.Paths = append(req.Paths, req.Path)
+		}
+		if len(req.Paths) == 0 {
+			http.Error(w, "需要path或paths参数", http.StatusBadRequest)
+			return
+		}
+
+		replace := r.Method == http.MethodPut
+		var entries []*TagEntry
+		for _, p := range req.Paths {
+			path := resolveClientPath(p)
+			entry, err := updateTags(path, func(current []string) []string {
+				if replace {
+					return req.Tags
+				}
+				return append(current, req.Tags...)
+			})
+			if err != nil {
+				log.Printf("保存标签失败: %v", err)
+				http.Error(w, "保存标签失败: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			entries = append(entries, entry)
+		}
+
+		log.Printf("设置标签: %d个路径, 标签: %v, 替换: %t, 来源IP: %s", len(req.Paths), req.Tags, replace, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"entries": entries,
+			"count":   len(entries),
+		})
+
+	case http.MethodDelete:
+		path := resolveClientPath(r.URL.Query().Get("path"))
+		if path == "" {
+			http.Error(w, "路径参数不能为空", http.StatusBadRequest)
+			return
+		}
+		removeTag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
+
+		entry, err := updateTags(path, func(current []string) []string {
+			if removeTag == "" {
+				return nil
+			}
+			var kept []string
+			for _, tag := range current {
+				if tag != removeTag {
+					kept = append(kept, tag)
+				}
+			}
+			return kept
+		})
+		if err != nil {
+			http.Error(w, "保存标签失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("删除标签: %s, 标签: %s, 来源IP: %s", path, removeTag, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(entry)
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 缩略图生成和预生成
+//
+// /thumbnail/ 返回长边不超过320像素的JPEG：图片按比例缩小，视频取开头一段中最有代表性的一帧。
+// 结果缓存在 data\cache\thumbnails，源文件变化后重新生成。较小的图片直接返回原图；
+// ffmpeg不可用或生成失败时，图片返回原图，视频返回404（页面显示图标）。
+//
+// 浏览文件夹时在后台为其中的图片和视频预生成缩略图，从当前页开始排队，列表滚动时不必逐个等待生成。
+// 队列有上限，已满时丢弃剩余的文件；已缓存的文件直接跳过；后台只用一个生成名额，不影响页面请求。
+// data\config.json 中设置 disableThumbnailPregen 可以关闭预生成。
+
+const (
+	thumbnailSize        = 320      // 缩略图长边上限（像素）
+	thumbnailMinBytes    = 64 << 10 // 小于此大小的图片直接返回原图
+	thumbnailQueueSize   = 256
+	thumbnailFolderLimit = 200 // 每次浏览最多加入队列的文件数
+)
+
+var (
+	thumbnailQueue      = make(chan string, thumbnailQueueSize)
+	thumbnailPending    = make(map[string]bool) // 已在队列中的文件，避免重复排队
+	thumbnailMutex      sync.Mutex
+	thumbnailWorkerOnce sync.Once
+
+	thumbnailGenerated atomic.Int64
+	thumbnailSkipped   atomic.Int64
+	thumbnailFailed    atomic.Int64
+	thumbnailDropped   atomic.Int64
+)
+
+// 缩略图缓存文件路径
+func thumbnailPath(src string, info os.FileInfo) string {
+	return filepath.Join(getCacheDir("thumbnails"), fileCacheKey(src, info, fmt.Sprintf("thumb%d", thumbnailSize))+".jpg")
+}
+
+// 是否需要生成缩略图（否则直接返回原图）
+func needsThumbnail(src string, info os.FileInfo) bool {
+	if !ffmpegAvailable.Load() || remoteEverythingEnabled() {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(src))
+	return isVideoFile(ext) || isImageFile(ext) && info.Size() >= thumbnailMinBytes
+}
+
+// 生成（或从缓存获取）缩略图，返回缓存文件路径
+func generateThumbnail(ctx context.Context, src string, info os.FileInfo) (string, error) {
+	target := thumbnailPath(src, info)
+	err := ensureCachedFile(ctx, target, func(ctx context.Context, tmp string) error {
+		scale := fmt.Sprintf("scale='min(%d,iw)':'min(%d,ih)':force_original_aspect_ratio=decrease", thumbnailSize, thumbnailSize)
+		if isVideoFile(strings.ToLower(filepath.Ext(src))) {
+			// thumbnail滤镜从开头的若干帧中选出最有代表性的一帧，避开黑屏和转场
+			scale = "thumbnail," + scale
+		}
+		cmd := exec.CommandContext(ctx, ffmpegBinary(), "-i", src, "-vf", scale, "-an", "-frames:v", "1", "-q:v", "5", "-y", tmp)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return ffmpegError(err, out)
+		}
+		return nil
+	})
+	return target, err
+}
+
+// 把文件夹中的图片和视频加入预生成队列，从当前页的第一项start开始，到末尾后再从头补齐
+func queueFolderThumbnails(results []SearchResult, start int) {
+	if serverConfig.DisableThumbnailPregen || !ffmpegAvailable.Load() || remoteEverythingEnabled() {
+		return
+	}
+	thumbnailWorkerOnce.Do(func() { go thumbnailWorker() })
+
+	queued, dropped := 0, 0
+	for i := 0; i < len(results) && queued+dropped < thumbnailFolderLimit; i++ {
+		result := results[(start+i)%len(results)]
+		if result.Type != "image" && result.Type != "video" {
+			continue
+		}
+		path := resolveClientPath(result.Path)
+
+		thumbnailMutex.Lock()
+		if thumbnailPending[path] {
+			thumbnailMutex.Unlock()
+			continue
+		}
+		select {
+		case thumbnailQueue <- path:
+			thumbnailPending[path] = true
+			queued++
+		default:
+			dropped++
+		}
+		thumbnailMutex.Unlock()
+	}
+
+	if dropped > 0 {
+		thumbnailDropped.Add(int64(dropped))
+		log.Printf("缩略图队列已满，丢弃%d个文件", dropped)
+	}
+}
+
+// 后台依次生成队列中的缩略图
+func thumbnailWorker() {
+	for path := range thumbnailQueue {
+		pregenerateThumbnail(path)
+
+		thumbnailMutex.Lock()
+		delete(thumbnailPending, path)
+		thumbnailMutex.Unlock()
+	}
+}
+
+func pregenerateThumbnail(path string) {
+	info, err := statPath(path)
+	if err != nil || !needsThumbnail(path, info) {
+		thumbnailSkipped.Add(1)
+		return
+	}
+	if _, err := os.Stat(thumbnailPath(path, info)); err == nil {
+		thumbnailSkipped.Add(1)
+		return
+	}
+
+	if _, err := generateThumbnail(context.Background(), path, info); err != nil {
+		thumbnailFailed.Add(1)
+		log.Printf("预生成缩略图失败: %s, 错误: %v", path, err)
+		return
+	}
+	thumbnailGenerated.Add(1)
+}
+
+// 预生成统计，供状态API返回
+func thumbnailPregenStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled":   !serverConfig.DisableThumbnailPregen,
+		"queued":    len(thumbnailQueue),
+		"generated": thumbnailGenerated.Load(),
+		"skipped":   thumbnailSkipped.Load(),
+		"failed":    thumbnailFailed.Load(),
+		"dropped":   thumbnailDropped.Load(),
+	}
+}
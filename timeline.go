@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 照片时间线
+//
+// GET /api/timeline?path=文件夹&path=文件夹2        按年、月分组的照片数量和每月的代表照片
+// GET /api/timeline?path=文件夹&month=2024-06&page=1  某个月的照片，按时间从新到旧分页
+// GET /timeline?path=文件夹                          时间线页面
+// 用Everything列出文件夹中（含子文件夹）的所有图片，拍摄时间已缓存（见exif.go）时按拍摄时间，
+// 否则按修改时间分组，未缓存的照片在后台读取EXIF，之后的请求逐渐改为按拍摄时间。
+// 不带path时使用配置中的timelineFolders。
+
+const timelineSamples = 4 // 每月的代表照片数
+
+var timelineExtensions = []string{"jpg", "jpeg", "png", "gif", "bmp", "webp", "avif"}
+
+type TimelinePhoto struct {
+	Path   string `json:"path"`
+	Taken  string `json:"taken"`
+	Source string `json:"source"` // exif或mtime
+	taken  time.Time
+}
+
+type TimelineMonth struct {
+	Month   string          `json:"month"` // 2024-06
+	Count   int             `json:"count"`
+	Samples []TimelinePhoto `json:"samples"`
+}
+
+type TimelineYear struct {
+	Year   int             `json:"year"`
+	Count  int             `json:"count"`
+	Months []TimelineMonth `json:"months"` // 从新到旧
+}
+
+type TimelineResponse struct {
+	Folders     []string       `json:"folders"`
+	Years       []TimelineYear `json:"years"` // 从新到旧
+	Total       int            `json:"total"`
+	EXIFCount   int            `json:"exifCount"`   // 按拍摄时间分组的照片数
+	PendingEXIF int            `json:"pendingExif"` // 后台尚未读取拍摄时间的照片数
+	ElapsedMs   int64          `json:"elapsedMs"`
+}
+
+type TimelineMonthResponse struct {
+	Folders    []string        `json:"folders"`
+	Month      string          `json:"month"`
+	Photos     []TimelinePhoto `json:"photos"`
+	Count      int             `json:"count"`
+	TotalCount int             `json:"totalCount"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"pageSize"`
+	TotalPages int             `json:"totalPages"`
+}
+
+// 多个文件夹中图片文件对应的Everything查询
+func timelineQuery(folders []string) string {
+	parts := make([]string, len(folders))
+	for i, folder := range folders {
+		parts[i] = folderQuery(folder)
+	}
+	query := parts[0]
+	if len(parts) > 1 {
+		query = "<" + strings.Join(parts, "|") + ">"
+	}
+	return query + " file: ext:" + strings.Join(timelineExtensions, ";")
+}
+
+// 确定每张照片的时间，按时间从新到旧排列；返回按拍摄时间确定的数量
+func timelinePhotos(entries []everythingEntry) ([]TimelinePhoto, int) {
+	photos := make([]TimelinePhoto, 0, len(entries))
+	exifCount, dropped := 0, 0
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		taken, cached := cachedCaptureDate(e)
+		source := "exif"
+		if taken.IsZero() {
+			if e.Modified.IsZero() {
+				continue
+			}
+			taken, source = e.Modified, "mtime"
+			if !cached && !remoteEverythingEnabled() && !queueEXIFScan(e) {
+				dropped++
+			}
+		} else {
+			exifCount++
+		}
+		photos = append(photos, TimelinePhoto{
+			Path:   clientPath(e.Path),
+			Taken:  taken.Format("2006-01-02 15:04:05"),
+			Source: source,
+			taken:  taken,
+		})
+	}
+	if dropped > 0 {
+		log.Printf("EXIF读取队列已满，%d张照片暂时按修改时间分组", dropped)
+	}
+
+	sort.SliceStable(photos, func(i, j int) bool { return photos[i].taken.After(photos[j].taken) })
+	return photos, exifCount
+}
+
+// 按年、月分组，每月均匀选出几张代表照片
+func groupTimeline(photos []TimelinePhoto) []TimelineYear {
+	years := []TimelineYear{}
+	for start := 0; start < len(photos); {
+		month := photos[start].taken.Format("2006-01")
+		end := start
+		for end < len(photos) && photos[end].taken.Format("2006-01") == month {
+			end++
+		}
+
+		group := photos[start:end]
+		samples := make([]TimelinePhoto, 0, timelineSamples)
+		for i := 0; i < timelineSamples && i < len(group); i++ {
+			samples = append(samples, group[i*len(group)/min(timelineSamples, len(group))])
+		}
+
+		year := photos[start].taken.Year()
+		if len(years) == 0 || years[len(years)-1].Year != year {
+			years = append(years, TimelineYear{Year: year})
+		}
+		y := &years[len(years)-1]
+		y.Count += len(group)
+		y.Months = append(y.Months, TimelineMonth{Month: month, Count: len(group), Samples: samples})
+		start = end
+	}
+	return years
+}
+
+// 请求中的文件夹，没有时使用配置的timelineFolders
+func timelineFolders(r *http.Request) ([]string, int, error) {
+	var folders []string
+	for _, p := range r.URL.Query()["path"] {
+		if folder := resolveClientPath(p); folder != "" {
+			folders = append(folders, folder)
+		}
+	}
+	if len(folders) == 0 {
+		folders = serverConfig.TimelineFolders
+	}
+	if len(folders) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("需要path参数，或在配置中设置timelineFolders")
+	}
+
+	for _, folder := range folders {
+		if info, err := statPath(folder); err != nil || !info.IsDir() {
+			if err != nil && !os.IsNotExist(err) {
+				return nil, http.StatusInternalServerError, fmt.Errorf("访问文件夹失败: %v", err)
+			}
+			return nil, http.StatusNotFound, fmt.Errorf("文件夹不存在: %s", folder)
+		}
+	}
+	return folders, http.StatusOK, nil
+}
+
+// 时间线API处理器
+func apiTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	folders, status, err := timelineFolders(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	month := r.URL.Query().Get("month")
+	if month != "" {
+		if _, err := time.Parse("2006-01", month); err != nil {
+			http.Error(w, "month参数格式应为YYYY-MM", http.StatusBadRequest)
+			return
+		}
+	}
+
+	start := time.Now()
+	query := timelineQuery(folders)
+	entries, err := collectEntries(r.Context(), query)
+	if r.Context().Err() != nil {
+		log.Printf("客户端已断开，取消时间线: %s", query)
+		return
+	}
+	if err != nil {
+		log.Printf("时间线查询失败: %s, 错误: %v", query, err)
+		http.Error(w, "时间线查询失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	photos, exifCount := timelinePhotos(entries)
+
+	clientFolders := make([]string, len(folders))
+	for i, folder := range folders {
+		clientFolders[i] = clientPath(folder)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if month != "" {
+		var inMonth []TimelinePhoto
+		for _, photo := range photos {
+			if strings.HasPrefix(photo.Taken, month) {
+				inMonth = append(inMonth, photo)
+			}
+		}
+		page, pageSize := parsePageParams(r)
+		from, to := pageBounds(len(inMonth), page, pageSize)
+		resp := TimelineMonthResponse{
+			Folders:    clientFolders,
+			Month:      month,
+			Photos:     append([]TimelinePhoto{}, inMonth[from:to]...),
+			Count:      to - from,
+			TotalCount: len(inMonth),
+			Page:       page,
+			PageSize:   pageSize,
+			TotalPages: (len(inMonth) + pageSize - 1) / pageSize,
+		}
+		log.Printf("时间线: %s %s，%d张照片，来源IP: %s", query, month, len(inMonth), r.RemoteAddr)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	resp := TimelineResponse{
+		Folders:     clientFolders,
+		Years:       groupTimeline(photos),
+		Total:       len(photos),
+		EXIFCount:   exifCount,
+		PendingEXIF: pendingEXIFScans(),
+		ElapsedMs:   time.Since(start).Milliseconds(),
+	}
+	log.Printf("时间线: %s，%d张照片，%d张按拍摄时间，用时%dms，来源IP: %s", query, resp.Total, exifCount, resp.ElapsedMs, r.RemoteAddr)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 时间线页面
+func timelinePageHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := cspNonce(r)
+	folders := r.URL.Query()["path"]
+	foldersJSON, _ := json.Marshal(folders)
+
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>时间线 - ` + html.EscapeString(strings.Join(folders, "; ")) + ` - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 10px; font-size: 14px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; }
+        .layout { display: flex; gap: 16px; }
+        #years { width: 90px; flex-shrink: 0; position: sticky; top: 10px; align-self: flex-start; font-size: 14px; }
+        #years a { display: block; padding: 2px 0; text-decoration: none; }
+        #timeline { flex: 1; min-width: 0; }
+        h2 { margin: 20px 0 8px; font-size: 20px; }
+        .month { background: white; border-radius: 8px; padding: 10px 12px; margin-bottom: 10px; }
+        .month-title { cursor: pointer; font-weight: bold; margin-bottom: 8px; }
+        .month-title small { color: #888; font-weight: normal; }
+        .photos { display: grid; grid-template-columns: repeat(auto-fill, minmax(140px, 1fr)); gap: 6px; }
+        .photos a { display: block; aspect-ratio: 1; background: #eee; border-radius: 4px; overflow: hidden; }
+        .photos img { width: 100%; height: 100%; object-fit: cover; }
+        .more { margin-top: 8px; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>📅 时间线</strong>
+        <span id="crumb"></span>
+        <a id="mapLink" href="#">🗺 地图</a>
+        <span class="meta" id="info">加载中...</span>
+    </div>
+    <div class="layout">
+        <div id="years"></div>
+        <div id="timeline"></div>
+    </div>
+    <script nonce="` + nonce + `">
+        const folders = ` + string(foldersJSON) + `;
+        const query = folders.map(f => 'path=' + encodeURIComponent(f)).join('&');
+        document.getElementById('mapLink').href = '/map?' + query;
+
+        function escapeHtml(s) {
+            return String(s).replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
+        }
+
+        function photoHtml(photo) {
+            const path = encodeURIComponent(photo.path);
+            return '<a href="/imageview/' + path + '" target="_blank" title="' + escapeHtml(photo.path + '\n' + photo.taken + (photo.source === 'exif' ? '（拍摄时间）' : '（修改时间）')) + '">' +
+                '<img src="/thumbnail/' + path + '" loading="lazy"></a>';
+        }
+
+        async function load() {
+            try {
+                const response = await fetch('/api/timeline?' + query);
+                if (!response.ok) throw new Error(await response.text());
+                const data = await response.json();
+                document.getElementById('crumb').textContent = data.folders.join('; ');
+                document.getElementById('info').textContent = data.total + '张照片，' + data.exifCount + '张按拍摄时间' +
+                    (data.pendingExif ? '，后台正在读取' + data.pendingExif + '张照片的拍摄时间，稍后刷新' : '') + '，用时' + data.elapsedMs + 'ms';
+                document.getElementById('years').innerHTML = data.years.map(y => '<a href="#y' + y.year + '">' + y.year + ' <small>(' + y.count + ')</small></a>').join('');
+                document.getElementById('timeline').innerHTML = data.years.map(y =>
+                    '<h2 id="y' + y.year + '">' + y.year + '</h2>' + y.months.map(m =>
+                        '<div class="month" data-month="' + m.month + '"><div class="month-title">' + m.month + ' <small>' + m.count + '张，点击展开</small></div>' +
+                        '<div class="photos">' + m.samples.map(photoHtml).join('') + '</div></div>').join('')).join('');
+                document.querySelectorAll('.month-title').forEach(title => {
+                    title.onclick = () => showMonth(title.parentElement, 1);
+                });
+            } catch (error) {
+                document.getElementById('info').textContent = '加载失败: ' + error.message;
+            }
+        }
+
+        // 展开一个月的全部照片，分页追加
+        async function showMonth(container, page) {
+            const month = container.dataset.month;
+            try {
+                const response = await fetch('/api/timeline?' + query + '&month=' + month + '&page=' + page + '&pageSize=200');
+                if (!response.ok) throw new Error(await response.text());
+                const data = await response.json();
+                const grid = container.querySelector('.photos');
+                if (page === 1) grid.innerHTML = '';
+                grid.insertAdjacentHTML('beforeend', data.photos.map(photoHtml).join(''));
+                container.querySelector('.month-title small').textContent = data.totalCount + '张';
+                const more = container.querySelector('.more');
+                if (more) more.remove();
+                if (page < data.totalPages) {
+                    const button = document.createElement('button');
+                    button.className = 'more';
+                    button.textContent = '更多（' + (data.totalCount - page * data.pageSize) + '张）';
+                    button.onclick = () => showMonth(container, page + 1);
+                    container.appendChild(button);
+                }
+            } catch (error) {
+                alert('加载失败: ' + error.message);
+            }
+        }
+
+        load();
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 构造只含DateTimeOriginal的JPEG
+func jpegWithEXIF(taken string) []byte {
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00")
+	tiff = le.AppendUint32(tiff, 8)
+	// IFD0：ExifIFD指针
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, 0x8769)
+	tiff = le.AppendUint16(tiff, 4)
+	tiff = le.AppendUint32(tiff, 1)
+	tiff = le.AppendUint32(tiff, 26)
+	tiff = le.AppendUint32(tiff, 0)
+	// ExifIFD：DateTimeOriginal，字符串在偏移44处
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, 0x9003)
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = le.AppendUint32(tiff, uint32(len(taken)+1))
+	tiff = le.AppendUint32(tiff, 44)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, taken+"\x00"...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, 0xFF, 0xDA, 0, 2)
+}
+
+func TestReadCaptureDate(t *testing.T) {
+	dir := t.TempDir()
+	withEXIF := filepath.Join(dir, "photo.jpg")
+	if err := os.WriteFile(withEXIF, jpegWithEXIF("2021:07:15 10:30:00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	taken, err := readCaptureDate(withEXIF)
+	if err != nil {
+		t.Fatalf("readCaptureDate: %v", err)
+	}
+	if want := time.Date(2021, 7, 15, 10, 30, 0, 0, time.Local); !taken.Equal(want) {
+		t.Errorf("taken = %v, want %v", taken, want)
+	}
+
+	withoutEXIF := filepath.Join(dir, "plain.jpg")
+	if err := os.WriteFile(withoutEXIF, jpegHeader, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readCaptureDate(withoutEXIF); err == nil {
+		t.Error("expected error for JPEG without EXIF")
+	}
+}
+
+func TestGroupTimeline(t *testing.T) {
+	var entries []everythingEntry
+	add := func(name string, year int, month time.Month, day int) {
+		entries = append(entries, everythingEntry{
+			Path:     filepath.Join(t.TempDir(), name),
+			Size:     1,
+			Modified: time.Date(year, month, day, 12, 0, 0, 0, time.Local),
+		})
+	}
+	for day := 1; day <= 10; day++ {
+		add("june.jpg", 2024, time.June, day)
+	}
+	add("may.jpg", 2024, time.May, 3)
+	add("old.jpg", 2019, time.December, 31)
+	entries = append(entries, everythingEntry{Path: t.TempDir(), IsDir: true})
+
+	photos, exifCount := timelinePhotos(entries)
+	if len(photos) != 12 || exifCount != 0 {
+		t.Fatalf("photos = %d, exifCount = %d, want 12, 0", len(photos), exifCount)
+	}
+	if photos[0].Source != "mtime" {
+		t.Errorf("source = %q, want mtime", photos[0].Source)
+	}
+
+	years := groupTimeline(photos)
+	if len(years) != 2 || years[0].Year != 2024 || years[1].Year != 2019 {
+		t.Fatalf("years = %+v", years)
+	}
+	if years[0].Count != 11 || len(years[0].Months) != 2 {
+		t.Fatalf("2024 = %+v", years[0])
+	}
+	june := years[0].Months[0]
+	if june.Month != "2024-06" || june.Count != 10 || len(june.Samples) != timelineSamples {
+		t.Errorf("june = %s, %d photos, %d samples", june.Month, june.Count, len(june.Samples))
+	}
+	if june.Samples[0].Taken != "2024-06-10 12:00:00" || june.Samples[3].Taken != "2024-06-03 12:00:00" {
+		t.Errorf("samples should spread across the month, got %s ... %s", june.Samples[0].Taken, june.Samples[3].Taken)
+	}
+}
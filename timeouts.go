@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 按路由的请求超时和慢请求记录
+//
+// 不同API的耗时差别很大，每类请求有自己的时间预算：超过timeout时取消请求的上下文
+// （Everything查询、逐个获取文件信息等会随之停止），超过slow时记录为慢请求。
+// 视频流、文件下载等长时间请求（longWritePrefixes）不受限制。
+// 写超时（server.go）在时间预算之外再留apiWriteTimeout，预算内完成的结果都能写出。
+// 慢请求的统计和最近的记录在 /api/status 的requests中返回，用于找出拖慢服务器的文件夹或查询。
+
+type routeBudget struct {
+	prefix  string
+	timeout time.Duration // 超过后取消请求，0表示不限制
+	slow    time.Duration // 超过后记录为慢请求
+}
+
+// 按路径前缀匹配，第一个匹配的生效
+var routeBudgets = []routeBudget{
+	{"/api/search", 30 * time.Second, 3 * time.Second},
+	{"/api/refine", 30 * time.Second, 3 * time.Second},
+	{"/api/autocomplete", 5 * time.Second, time.Second},
+	{"/api/snapshots", 60 * time.Second, 10 * time.Second},
+	{"/api/browse", 30 * time.Second, 3 * time.Second},
+	{"/api/tree", 15 * time.Second, 2 * time.Second},
+	{"/api/siblings", 15 * time.Second, 2 * time.Second},
+	{"/api/neighbors", 15 * time.Second, 2 * time.Second},
+	{"/api/stats", 60 * time.Second, 10 * time.Second},
+	{"/api/du", 120 * time.Second, 20 * time.Second},
+	{"/api/cleanup-suggestions", 120 * time.Second, 20 * time.Second},
+	{"/api/text", 15 * time.Second, 2 * time.Second},
+	{"/api/download", 60 * time.Second, 10 * time.Second}, // 创建打包任务时逐个获取文件信息
+	{"/api/selftest", 30 * time.Second, 10 * time.Second},
+}
+
+// 其他请求不限制时间，超过此时长记录为慢请求
+const defaultSlowRequest = 5 * time.Second
+
+const maxRecentSlowRequests = 50
+
+// 每类请求的统计
+type routeTiming struct {
+	Count    int64  `json:"count"`
+	Slow     int64  `json:"slow"`
+	TimedOut int64  `json:"timedOut"`
+	Max      string `json:"max"`
+	max      time.Duration
+}
+
+type slowRequest struct {
+	Path     string `json:"path"`
+	Query    string `json:"query,omitempty"`
+	Duration string `json:"duration"`
+	TimedOut bool   `json:"timedOut,omitempty"`
+	Source   string `json:"source"`
+	Time     string `json:"time"`
+}
+
+var (
+	routeTimings       = make(map[string]*routeTiming)
+	recentSlowRequests []slowRequest
+	routeTimingsMutex  sync.Mutex
+)
+
+// 请求路径对应的时间预算
+func findRouteBudget(path string) routeBudget {
+	for _, budget := range routeBudgets {
+		if strings.HasPrefix(path, budget.prefix) {
+			return budget
+		}
+	}
+	return routeBudget{prefix: "其他", slow: defaultSlowRequest}
+}
+
+// 为请求设置超时并记录耗时
+func withRequestTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range longWritePrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		budget := findRouteBudget(r.URL.Path)
+		ctx := r.Context()
+		if budget.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, budget.timeout)
+			defer cancel()
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, r.WithContext(ctx))
+		elapsed := time.Since(start)
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		recordRequestTiming(budget, r, elapsed, timedOut)
+	})
+}
+
+func recordRequestTiming(budget routeBudget, r *http.Request, elapsed time.Duration, timedOut bool) {
+	slow := elapsed >= budget.slow || timedOut
+
+	routeTimingsMutex.Lock()
+	defer routeTimingsMutex.Unlock()
+
+	t := routeTimings[budget.prefix]
+	if t == nil {
+		t = &routeTiming{}
+		routeTimings[budget.prefix] = t
+	}
+	t.Count++
+	if elapsed > t.max {
+		t.max = elapsed
+		t.Max = elapsed.Round(time.Millisecond).String()
+	}
+	if !slow {
+		return
+	}
+	t.Slow++
+	if timedOut {
+		t.TimedOut++
+		log.Printf("请求超时(%v): %s?%s，来源IP: %s", budget.timeout, r.URL.Path, r.URL.RawQuery, r.RemoteAddr)
+	} else {
+		log.Printf("慢请求(%v): %s?%s，来源IP: %s", elapsed.Round(time.Millisecond), r.URL.Path, r.URL.RawQuery, r.RemoteAddr)
+	}
+
+	recentSlowRequests = append(recentSlowRequests, slowRequest{
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Duration: elapsed.Round(time.Millisecond).String(),
+		TimedOut: timedOut,
+		Source:   r.RemoteAddr,
+		Time:     time.Now().Format("2006-01-02 15:04:05"),
+	})
+	if len(recentSlowRequests) > maxRecentSlowRequests {
+		recentSlowRequests = recentSlowRequests[len(recentSlowRequests)-maxRecentSlowRequests:]
+	}
+}
+
+// 请求耗时统计，供状态API返回
+func requestTimingStatus() map[string]interface{} {
+	routeTimingsMutex.Lock()
+	defer routeTimingsMutex.Unlock()
+
+	routes := make(map[string]routeTiming, len(routeTimings))
+	for prefix, t := range routeTimings {
+		routes[prefix] = *t
+	}
+	// 最近的在前
+	recent := make([]slowRequest, 0, len(recentSlowRequests))
+	for i := len(recentSlowRequests) - 1; i >= 0; i-- {
+		recent = append(recent, recentSlowRequests[i])
+	}
+
+	return map[string]interface{}{
+		"routes":       routes,
+		"slowRequests": recent,
+	}
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 种子文件预览
+//
+// 在服务器上解析.torrent文件（BitTorrent v1、v2和混合种子），显示名称、总大小、文件列表和Tracker，
+// 并可以通过Everything按文件名查找种子中的文件是否已经下载到本机：
+//
+//   GET /api/torrent?path=种子文件         种子信息
+//   GET /api/torrent/check?path=种子文件   查找本机的对应文件，文件名和大小都相同时视为已存在
+
+const (
+	maxTorrentSize       = 32 << 20 // 种子文件的最大大小
+	maxBencodeDepth      = 64       // 嵌套的最大层数，防止恶意文件造成栈溢出
+	maxTorrentCheckFiles = 5000     // 一次检查的最多文件数
+	torrentCheckBatch    = 50       // 每次Everything查询包含的文件名数
+)
+
+var errNotTorrent = errors.New("不是有效的种子文件")
+
+// bencode解码：整数为int64，字符串为string（原始字节），列表为[]interface{}，字典为map[string]interface{}
+type bencodeDecoder struct {
+	data    []byte
+	pos     int
+	infoRaw []byte // 顶层info字典的原始字节，用于计算info hash
+}
+
+func decodeBencode(data []byte) (interface{}, []byte, error) {
+	d := &bencodeDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.pos != len(data) {
+		return nil, nil, fmt.Errorf("%w: 位置%d后有多余的数据", errNotTorrent, d.pos)
+	}
+	return v, d.infoRaw, nil
+}
+
+func (d *bencodeDecoder) fail(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: 位置%d: %s", errNotTorrent, d.pos, fmt.Sprintf(format, args...))
+}
+
+func (d *bencodeDecoder) value(depth int) (interface{}, error) {
+	if depth > maxBencodeDepth {
+		return nil, d.fail("嵌套层数过多")
+	}
+	if d.pos >= len(d.data) {
+		return nil, d.fail("数据不完整")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		end := d.pos + 1
+		for end < len(d.data) && d.data[end] != 'e' {
+			end++
+		}
+		if end >= len(d.data) {
+			return nil, d.fail("整数没有结束")
+		}
+		n, err := strconv.ParseInt(string(d.data[d.pos+1:end]), 10, 64)
+		if err != nil {
+			return nil, d.fail("无效的整数")
+		}
+		d.pos = end + 1
+		return n, nil
+	case c >= '0' && c <= '9':
+		return d.string()
+	case c == 'l':
+		d.pos++
+		list := []interface{}{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		if d.pos >= len(d.data) {
+			return nil, d.fail("列表没有结束")
+		}
+		d.pos++
+		return list, nil
+	case c == 'd':
+		d.pos++
+		dict := map[string]interface{}{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			key, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			start := d.pos
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if depth == 0 && key == "info" {
+				d.infoRaw = d.data[start:d.pos]
+			}
+			dict[key] = v
+		}
+		if d.pos >= len(d.data) {
+			return nil, d.fail("字典没有结束")
+		}
+		d.pos++
+		return dict, nil
+	default:
+		return nil, d.fail("无效的类型%q", c)
+	}
+}
+
+func (d *bencodeDecoder) string() (string, error) {
+	colon := d.pos
+	for colon < len(d.data) && d.data[colon] != ':' {
+		colon++
+	}
+	n, err := strconv.Atoi(string(d.data[d.pos:colon]))
+	if colon >= len(d.data) || err != nil || n < 0 || n > len(d.data)-colon-1 {
+		return "", d.fail("无效的字符串长度")
+	}
+	d.pos = colon + 1 + n
+	return string(d.data[colon+1 : d.pos]), nil
+}
+
+// 种子中的一个文件，Path为相对于种子根目录的路径（以/分隔）
+type TorrentFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+type TorrentInfo struct {
+	Path         string        `json:"path"`
+	Name         string        `json:"name"`
+	InfoHash     string        `json:"infoHash,omitempty"`   // v1的SHA-1
+	InfoHashV2   string        `json:"infoHashV2,omitempty"` // v2的SHA-256
+	Magnet       string        `json:"magnet"`
+	TotalSize    int64         `json:"totalSize"`
+	PieceLength  int64         `json:"pieceLength"`
+	Private      bool          `json:"private"`
+	Comment      string        `json:"comment,omitempty"`
+	CreatedBy    string        `json:"createdBy,omitempty"`
+	CreationDate *time.Time    `json:"creationDate,omitempty"`
+	Trackers     []string      `json:"trackers"`
+	WebSeeds     []string      `json:"webSeeds,omitempty"`
+	SingleFile   bool          `json:"singleFile"` // 单文件种子的文件直接以Name命名，不在文件夹中
+	Files        []TorrentFile `json:"files"`
+}
+
+// 字符串优先使用key.utf-8，不是UTF-8时按系统代码页解码（旧客户端生成的种子）
+func torrentString(dict map[string]interface{}, key string) string {
+	if s, ok := dict[key+".utf-8"].(string); ok && utf8.ValidString(s) {
+		return s
+	}
+	s, _ := dict[key].(string)
+	if !utf8.ValidString(s) {
+		return decodeANSI([]byte(s))
+	}
+	return s
+}
+
+func torrentPathParts(dict map[string]interface{}) []string {
+	list, ok := dict["path.utf-8"].([]interface{})
+	if !ok {
+		list, _ = dict["path"].([]interface{})
+	}
+	parts := make([]string, 0, len(list))
+	for _, p := range list {
+		s, _ := p.(string)
+		if !utf8.ValidString(s) {
+			s = decodeANSI([]byte(s))
+		}
+		parts = append(parts, s)
+	}
+	return parts
+}
+
+func parseTorrent(data []byte) (*TorrentInfo, error) {
+	v, infoRaw, err := decodeBencode(data)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errNotTorrent
+	}
+	info, ok := root["info"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: 缺少info字典", errNotTorrent)
+	}
+
+	t := &TorrentInfo{Name: torrentString(info, "name"), Trackers: []string{}, Files: []TorrentFile{}}
+	t.PieceLength, _ = info["piece length"].(int64)
+	private, _ := info["private"].(int64)
+	t.Private = private == 1
+	t.Comment = torrentString(root, "comment")
+	t.CreatedBy = torrentString(root, "created by")
+	if ts, ok := root["creation date"].(int64); ok && ts > 0 {
+		created := time.Unix(ts, 0)
+		t.CreationDate = &created
+	}
+
+	version, _ := info["meta version"].(int64)
+	switch files, ok := info["files"].([]interface{}); {
+	case ok:
+		for _, f := range files {
+			file, ok := f.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: 无效的文件列表", errNotTorrent)
+			}
+			// 混合种子中用于对齐的填充文件不是实际内容
+			if attr, _ := file["attr"].(string); strings.Contains(attr, "p") {
+				continue
+			}
+			size, _ := file["length"].(int64)
+			t.Files = append(t.Files, TorrentFile{Path: strings.Join(torrentPathParts(file), "/"), Size: size})
+		}
+	case info["length"] != nil:
+		size, _ := info["length"].(int64)
+		t.Files = append(t.Files, TorrentFile{Path: t.Name, Size: size})
+		t.SingleFile = true
+	case version == 2:
+		// v2的文件树不包含name这一层
+		tree, _ := info["file tree"].(map[string]interface{})
+		walkTorrentFileTree(tree, "", &t.Files)
+		// v2的单文件种子：文件树中只有一个与name同名的文件
+		t.SingleFile = len(t.Files) == 1 && t.Files[0].Path == t.Name
+	default:
+		return nil, fmt.Errorf("%w: 缺少文件信息", errNotTorrent)
+	}
+	for _, f := range t.Files {
+		t.TotalSize += f.Size
+	}
+
+	if version != 2 || info["pieces"] != nil {
+		sum := sha1.Sum(infoRaw)
+		t.InfoHash = hex.EncodeToString(sum[:])
+	}
+	if version == 2 {
+		sum := sha256.Sum256(infoRaw)
+		t.InfoHashV2 = hex.EncodeToString(sum[:])
+	}
+
+	seen := map[string]bool{}
+	addTracker := func(v interface{}) {
+		if s, ok := v.(string); ok && s != "" && !seen[s] {
+			seen[s] = true
+			t.Trackers = append(t.Trackers, s)
+		}
+	}
+	addTracker(root["announce"])
+	if tiers, ok := root["announce-list"].([]interface{}); ok {
+		for _, tier := range tiers {
+			list, _ := tier.([]interface{})
+			for _, tracker := range list {
+				addTracker(tracker)
+			}
+		}
+	}
+	switch seeds := root["url-list"].(type) {
+	case string:
+		t.WebSeeds = []string{seeds}
+	case []interface{}:
+		for _, s := range seeds {
+			if s, ok := s.(string); ok {
+				t.WebSeeds = append(t.WebSeeds, s)
+			}
+		}
+	}
+	t.Magnet = torrentMagnet(t)
+	return t, nil
+}
+
+// v2的文件树：每一层是以名称为键的字典，文件节点包含键为空字符串的字典
+func walkTorrentFileTree(tree map[string]interface{}, prefix string, files *[]TorrentFile) {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node, ok := tree[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if leaf, ok := node[""].(map[string]interface{}); ok {
+			size, _ := leaf["length"].(int64)
+			*files = append(*files, TorrentFile{Path: prefix + name, Size: size})
+			continue
+		}
+		walkTorrentFileTree(node, prefix+name+"/", files)
+	}
+}
+
+func torrentMagnet(t *TorrentInfo) string {
+	params := url.Values{}
+	params.Set("dn", t.Name)
+	params["tr"] = t.Trackers
+	var xt []string
+	if t.InfoHash != "" {
+		xt = append(xt, "xt=urn:btih:"+t.InfoHash)
+	}
+	if t.InfoHashV2 != "" {
+		xt = append(xt, "xt=urn:btmh:1220"+t.InfoHashV2)
+	}
+	return "magnet:?" + strings.Join(xt, "&") + "&" + params.Encode()
+}
+
+// 种子中的文件在本机的查找结果
+type TorrentFileMatch struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Status    string `json:"status"`              // found、sizeMismatch（只有同名文件）或missing
+	LocalPath string `json:"localPath,omitempty"` // 大小相同的本机文件
+}
+
+type TorrentCheckResult struct {
+	Path       string             `json:"path"`
+	Name       string             `json:"name"`
+	TotalFiles int                `json:"totalFiles"`
+	FoundFiles int                `json:"foundFiles"`
+	FoundSize  int64              `json:"foundSize"`
+	TotalSize  int64              `json:"totalSize"`
+	Folder     string             `json:"folder,omitempty"` // 大多数文件所在的本机下载文件夹
+	Truncated  bool               `json:"truncated"`
+	Files      []TorrentFileMatch `json:"files"`
+}
+
+// 按文件名分批查询Everything，文件名和大小都相同时视为已存在
+func checkTorrentFiles(ctx context.Context, t *TorrentInfo) (*TorrentCheckResult, error) {
+	files := t.Files
+	result := &TorrentCheckResult{Name: t.Name, TotalFiles: len(files), Files: []TorrentFileMatch{}}
+	if len(files) > maxTorrentCheckFiles {
+		files = files[:maxTorrentCheckFiles]
+		result.Truncated = true
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, f := range files {
+		name := strings.ToLower(torrentBaseName(f.Path))
+		// Windows文件名不能包含双引号，这样的文件不可能存在于本机
+		if name != "" && !seen[name] && !strings.Contains(name, `"`) {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	candidates := map[string][]string{}
+	for start := 0; start < len(names); start += torrentCheckBatch {
+		batch := names[start:min(start+torrentCheckBatch, len(names))]
+		terms := make([]string, len(batch))
+		for i, name := range batch {
+			terms[i] = `wfn:"` + name + `"`
+		}
+		paths, err := runEverythingQuery(ctx, strings.Join(terms, " | "))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range scriptFilterPaths(filterExcludedPaths(paths)) {
+			name := strings.ToLower(filepath.Base(p))
+			candidates[name] = append(candidates[name], p)
+		}
+	}
+
+	// 本机路径以种子中的相对路径结尾时，去掉相对路径得到下载文件夹
+	folders := map[string]int{}
+	for _, f := range files {
+		result.TotalSize += f.Size
+		match := TorrentFileMatch{Path: f.Path, Size: f.Size, Status: "missing"}
+		for _, p := range candidates[strings.ToLower(torrentBaseName(f.Path))] {
+			info, err := os.Stat(p)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if info.Size() != f.Size {
+				match.Status = "sizeMismatch"
+				continue
+			}
+			match.Status, match.LocalPath = "found", clientPath(p)
+			rel := filepath.FromSlash(f.Path)
+			if !t.SingleFile {
+				rel = filepath.Join(t.Name, rel)
+			}
+			if len(p) > len(rel) && strings.EqualFold(p[len(p)-len(rel):], rel) && os.IsPathSeparator(p[len(p)-len(rel)-1]) {
+				folders[clientPath(p[:len(p)-len(rel)-1])]++
+			}
+			break
+		}
+		if match.Status == "found" {
+			result.FoundFiles++
+			result.FoundSize += f.Size
+		}
+		result.Files = append(result.Files, match)
+	}
+	for folder, n := range folders {
+		if n > folders[result.Folder] || (n == folders[result.Folder] && folder < result.Folder) {
+			result.Folder = folder
+		}
+	}
+	return result, nil
+}
+
+func torrentBaseName(p string) string {
+	return p[strings.LastIndex(p, "/")+1:]
+}
+
+func readTorrentFile(path string) (*TorrentInfo, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxTorrentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTorrentSize {
+		return nil, fmt.Errorf("%w: 文件超过%dMB", errNotTorrent, maxTorrentSize>>20)
+	}
+	t, err := parseTorrent(data)
+	if err != nil {
+		return nil, err
+	}
+	t.Path = clientPath(path)
+	return t, nil
+}
+
+// 读取请求中的种子文件，出错时写入响应并返回nil
+func requestTorrent(w http.ResponseWriter, r *http.Request) (*TorrentInfo, string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return nil, ""
+	}
+	clientSrc := r.URL.Query().Get("path")
+	if clientSrc == "" {
+		http.Error(w, "path参数不能为空", http.StatusBadRequest)
+		return nil, ""
+	}
+	if remoteEverythingEnabled() {
+		http.Error(w, errRemoteFile.Error(), http.StatusBadRequest)
+		return nil, ""
+	}
+	path := resolveClientPath(clientSrc)
+	t, err := readTorrentFile(path)
+	switch {
+	case err == nil:
+		return t, path
+	case os.IsNotExist(err):
+		http.Error(w, "文件不存在", http.StatusNotFound)
+	case errors.Is(err, errNotTorrent):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		log.Printf("读取种子文件失败: %s, 错误: %v", path, err)
+		http.Error(w, "读取种子文件失败: "+err.Error(), http.StatusInternalServerError)
+	}
+	return nil, ""
+}
+
+func apiTorrentHandler(w http.ResponseWriter, r *http.Request) {
+	t, path := requestTorrent(w, r)
+	if t == nil {
+		return
+	}
+	log.Printf("种子信息: %s, %d个文件, 来源IP: %s", path, len(t.Files), r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(t)
+}
+
+func apiTorrentCheckHandler(w http.ResponseWriter, r *http.Request) {
+	t, path := requestTorrent(w, r)
+	if t == nil {
+		return
+	}
+	result, err := checkTorrentFiles(r.Context(), t)
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("检查种子文件失败: %s, 错误: %v", path, err)
+			http.Error(w, "搜索失败: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	result.Path = t.Path
+	log.Printf("检查种子文件: %s, 本机已有%d/%d个文件, 来源IP: %s", path, result.FoundFiles, result.TotalFiles, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(result)
+}
+
+type torrentPreviewProvider struct{ previewExtensions }
+
+func init() {
+	registerPreviewProvider(torrentPreviewProvider{previewExtensions{".torrent"}})
+}
+
+func (torrentPreviewProvider) Name() string { return "torrent" }
+
+// 预览页面，种子信息由页面通过/api/torrent读取
+func (torrentPreviewProvider) Render(ctx context.Context, path string) (*Preview, error) {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	fileName := filepath.Base(path)
+	page := `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + html.EscapeString(fileName) + ` - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 12px; font-size: 14px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; }
+        .panel { background: white; border-radius: 8px; padding: 12px 16px; margin-bottom: 12px; }
+        dl { display: grid; grid-template-columns: max-content 1fr; gap: 6px 16px; margin: 0; font-size: 14px; }
+        dt { color: #888; }
+        dd { margin: 0; word-break: break-all; white-space: pre-line; }
+        code { font-size: 12px; }
+        table { border-collapse: collapse; font-size: 13px; width: 100%; }
+        th, td { border-bottom: 1px solid #eee; padding: 4px 8px; text-align: left; word-break: break-all; }
+        td.size { text-align: right; white-space: nowrap; font-variant-numeric: tabular-nums; }
+        tr.found td:first-child::before { content: '✅ '; }
+        tr.sizeMismatch td:first-child::before { content: '⚠️ '; }
+        tr.missing td:first-child::before { content: '❌ '; }
+        button { padding: 6px 14px; border: none; border-radius: 4px; background: #4CAF50; color: white; cursor: pointer; }
+        button:disabled { background: #aaa; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>🧲 ` + html.EscapeString(fileName) + `</strong>
+        <span class="meta" id="status">加载中...</span>
+    </div>
+    <div class="panel"><dl id="info"></dl></div>
+    <div class="panel">
+        <div class="toolbar">
+            <strong id="fileCount"></strong>
+            <button id="check">检查本机是否已有</button>
+            <span class="meta" id="checkStatus"></span>
+        </div>
+        <table><thead><tr><th>文件</th><th>大小</th><th>本机文件</th></tr></thead><tbody id="files"></tbody></table>
+    </div>
+    <script nonce="` + nonce + `">
+        const torrentPath = ` + jsString(clientPath(path)) + `;
+        const status = document.getElementById('status');
+        const params = new URLSearchParams({ path: torrentPath });
+
+        function formatSize(bytes) {
+            const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+            let i = 0;
+            while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
+            return (i === 0 ? bytes : bytes.toFixed(2)) + ' ' + units[i];
+        }
+
+        function row(label, value, isCode) {
+            if (!value) return;
+            const dt = document.createElement('dt');
+            dt.textContent = label;
+            const dd = document.createElement('dd');
+            if (value instanceof Node) dd.appendChild(value);
+            else if (isCode) dd.appendChild(Object.assign(document.createElement('code'), { textContent: value }));
+            else dd.textContent = value;
+            document.getElementById('info').append(dt, dd);
+        }
+
+        function renderFiles(files) {
+            const body = document.getElementById('files');
+            body.textContent = '';
+            files.forEach(f => {
+                const tr = body.insertRow();
+                if (f.status) tr.className = f.status;
+                tr.insertCell().textContent = f.path;
+                const size = tr.insertCell();
+                size.className = 'size';
+                size.textContent = formatSize(f.size);
+                const local = tr.insertCell();
+                if (f.localPath) {
+                    const a = document.createElement('a');
+                    a.href = '/file/' + encodeURIComponent(f.localPath);
+                    a.textContent = f.localPath;
+                    local.appendChild(a);
+                } else if (f.status === 'sizeMismatch') {
+                    local.textContent = '只有同名文件，大小不同';
+                }
+            });
+        }
+
+        async function load() {
+            const response = await fetch('/api/torrent?' + params);
+            if (!response.ok) throw new Error(await response.text());
+            const t = await response.json();
+            status.textContent = t.files.length + '个文件，共' + formatSize(t.totalSize);
+            row('名称', t.name);
+            row('总大小', formatSize(t.totalSize) + '（' + t.totalSize.toLocaleString() + '字节）');
+            row('分块大小', t.pieceLength ? formatSize(t.pieceLength) : '');
+            row('Info Hash', t.infoHash, true);
+            row('Info Hash v2', t.infoHashV2, true);
+            const magnet = document.createElement('a');
+            magnet.href = t.magnet;
+            magnet.textContent = '磁力链接';
+            row('磁力链接', magnet);
+            row('私有种子', t.private ? '是' : '');
+            row('创建时间', t.creationDate ? new Date(t.creationDate).toLocaleString() : '');
+            row('创建工具', t.createdBy);
+            row('注释', t.comment);
+            row('Tracker', t.trackers.join('\n'));
+            row('Web种子', (t.webSeeds || []).join('\n'));
+            document.getElementById('fileCount').textContent = '文件（' + t.files.length + '）';
+            renderFiles(t.files);
+        }
+
+        document.getElementById('check').addEventListener('click', async event => {
+            const button = event.target;
+            const checkStatus = document.getElementById('checkStatus');
+            button.disabled = true;
+            checkStatus.textContent = '正在通过Everything查找...';
+            try {
+                const response = await fetch('/api/torrent/check?' + params);
+                if (!response.ok) throw new Error(await response.text());
+                const result = await response.json();
+                checkStatus.textContent = '本机已有' + result.foundFiles + '/' + result.totalFiles + '个文件（' +
+                    formatSize(result.foundSize) + ' / ' + formatSize(result.totalSize) + '）' +
+                    (result.folder ? '，位于 ' + result.folder : '') + (result.truncated ? '，只检查了前' + result.files.length + '个文件' : '');
+                renderFiles(result.files);
+            } catch (error) {
+                checkStatus.textContent = '检查失败: ' + error.message;
+            } finally {
+                button.disabled = false;
+            }
+        });
+
+        load().catch(error => { status.textContent = '加载失败: ' + error.message; });
+    </script>
+</body>
+</html>`
+	return &Preview{ContentType: "text/html; charset=utf-8", Body: io.NopCloser(strings.NewReader(page))}, nil
+}
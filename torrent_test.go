@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 测试用的bencode编码，字典的键按字节序排列
+func bencode(v interface{}) string {
+	switch v := v.(type) {
+	case int:
+		return fmt.Sprintf("i%de", v)
+	case string:
+		return fmt.Sprintf("%d:%s", len(v), v)
+	case []interface{}:
+		s := "l"
+		for _, item := range v {
+			s += bencode(item)
+		}
+		return s + "e"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		s := "d"
+		for _, k := range keys {
+			s += bencode(k) + bencode(v[k])
+		}
+		return s + "e"
+	}
+	panic(fmt.Sprintf("unsupported type %T", v))
+}
+
+type bdict = map[string]interface{}
+type blist = []interface{}
+
+func testTorrentInfo() bdict {
+	return bdict{
+		"name":         "Show",
+		"piece length": 16384,
+		"pieces":       strings.Repeat("x", 20),
+		"files": blist{
+			bdict{"length": 10, "path": blist{"Season 1", "ep1.mkv"}},
+			bdict{"length": 6, "path": blist{".pad", "6"}, "attr": "p"},
+			bdict{"length": 20, "path": blist{"Season 1", "ep2.mkv"}},
+			bdict{"length": 30, "path": blist{"ep3.mkv"}},
+		},
+	}
+}
+
+func TestParseTorrent(t *testing.T) {
+	info := testTorrentInfo()
+	data := bencode(bdict{
+		"announce":      "udp://tracker.example:80",
+		"announce-list": blist{blist{"udp://tracker.example:80", "http://backup.example/announce"}},
+		"creation date": 1700000000,
+		"comment":       "测试",
+		"info":          info,
+	})
+	torrent, err := parseTorrent([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum([]byte(bencode(info)))
+	if torrent.InfoHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("infoHash = %s", torrent.InfoHash)
+	}
+	if torrent.Name != "Show" || torrent.TotalSize != 60 || len(torrent.Files) != 3 || torrent.SingleFile {
+		t.Errorf("torrent = %+v", torrent)
+	}
+	if torrent.Files[1].Path != "Season 1/ep2.mkv" {
+		t.Errorf("file path = %s", torrent.Files[1].Path)
+	}
+	if len(torrent.Trackers) != 2 || !strings.HasPrefix(torrent.Magnet, "magnet:?xt=urn:btih:"+torrent.InfoHash+"&dn=Show&tr=") {
+		t.Errorf("trackers = %v, magnet = %s", torrent.Trackers, torrent.Magnet)
+	}
+
+	// 单文件种子，name.utf-8优先
+	single, err := parseTorrent([]byte(bencode(bdict{"info": bdict{"name": "a.iso", "name.utf-8": "光盘.iso", "length": 42, "private": 1}})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !single.SingleFile || !single.Private || single.Files[0] != (TorrentFile{Path: "光盘.iso", Size: 42}) {
+		t.Errorf("single = %+v", single)
+	}
+
+	// v2的文件树
+	v2, err := parseTorrent([]byte(bencode(bdict{"info": bdict{"name": "album", "meta version": 2, "file tree": bdict{
+		"cd1":       bdict{"01.flac": bdict{"": bdict{"length": 5}}},
+		"cover.jpg": bdict{"": bdict{"length": 7}},
+	}}})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.InfoHash != "" || len(v2.InfoHashV2) != 64 || len(v2.Files) != 2 || v2.Files[0].Path != "cd1/01.flac" || v2.TotalSize != 12 {
+		t.Errorf("v2 = %+v", v2)
+	}
+
+	for _, bad := range []string{"", "d4:infoi1ee", "d4:info", "l" + strings.Repeat("l", 100) + strings.Repeat("e", 101), "d4:infod4:name1:aee", "5:abc"} {
+		if _, err := parseTorrent([]byte(bad)); err == nil {
+			t.Errorf("parseTorrent(%q): expected error", bad)
+		}
+	}
+}
+
+// 按查询中的wfn:"文件名"（可以包含通配符）返回匹配的文件
+type wfnBackend []string
+
+var wfnTerm = regexp.MustCompile(`wfn:"([^"]*)"`)
+
+func (b wfnBackend) Search(ctx context.Context, query string) ([]string, error) {
+	var matched []string
+	for _, m := range wfnTerm.FindAllStringSubmatch(query, -1) {
+		for _, p := range b {
+			if ok, _ := filepath.Match(strings.ToLower(m[1]), strings.ToLower(filepath.Base(p))); ok {
+				matched = append(matched, p)
+			}
+		}
+	}
+	return matched, nil
+}
+
+func TestTorrentCheck(t *testing.T) {
+	dir := t.TempDir()
+	torrent := filepath.Join(dir, "show.torrent")
+	os.WriteFile(torrent, []byte(bencode(bdict{"info": testTorrentInfo()})), 0644)
+	// 每个测试文件10字节：ep1大小相同，ep2大小不同，ep3不存在
+	local := createTestFiles(t, dir, filepath.Join("Downloads", "Show", "Season 1", "ep1.mkv"), filepath.Join("Other", "EP2.mkv"))
+	notTorrent := createTestFiles(t, dir, "broken.torrent")[0]
+
+	previous := searchBackend
+	searchBackend = wfnBackend(local)
+	t.Cleanup(func() { searchBackend = previous })
+
+	rec := serveTestRequest(apiTorrentCheckHandler, httptest.NewRequest(http.MethodGet, "/api/torrent/check?path="+url.QueryEscape(torrent), nil))
+	var result TorrentCheckResult
+	decodeTestJSON(t, rec, &result)
+	if result.FoundFiles != 1 || result.TotalFiles != 3 || result.FoundSize != 10 || result.TotalSize != 60 {
+		t.Errorf("result = %+v", result)
+	}
+	want := []string{"found", "sizeMismatch", "missing"}
+	for i, f := range result.Files {
+		if f.Status != want[i] {
+			t.Errorf("%s: status = %s, want %s", f.Path, f.Status, want[i])
+		}
+	}
+	if result.Files[0].LocalPath != local[0] || result.Folder != filepath.Join(dir, "Downloads") {
+		t.Errorf("localPath = %s, folder = %s", result.Files[0].LocalPath, result.Folder)
+	}
+
+	rec = serveTestRequest(apiTorrentHandler, httptest.NewRequest(http.MethodGet, "/api/torrent?path="+url.QueryEscape(notTorrent), nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid torrent: status = %d, want 422", rec.Code)
+	}
+	rec = serveTestRequest(previewHandler, httptest.NewRequest(http.MethodGet, "/preview/"+url.PathEscape(torrent), nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/api/torrent/check?") {
+		t.Errorf("preview: status %d", rec.Code)
+	}
+}
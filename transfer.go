@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// 文件传输
+//
+// 明文HTTP/1.1连接上，net/http在源为*os.File时会使用操作系统的零拷贝路径
+// （Windows上为TransmitFile），数据不经过用户态缓冲区。
+// HTTPS和HTTP/2无法零拷贝，此时使用可配置大小的缓冲区复制，减少系统调用次数。
+
+const defaultCopyBufferKB = 256
+
+var copyBufferPool = sync.Pool{
+	New: func() interface{} {
+		size := serverConfig.CopyBufferKB
+		if size <= 0 {
+			size = defaultCopyBufferKB
+		}
+		buf := make([]byte, size*1024)
+		return &buf
+	},
+}
+
+// 包装ResponseWriter：保留零拷贝能力，并记录响应状态码和写出字节数
+type transferWriter struct {
+	http.ResponseWriter
+	zeroCopy bool
+	status   int
+	written  int64
+}
+
+func newTransferWriter(w http.ResponseWriter, r *http.Request) *transferWriter {
+	_, canReadFrom := w.(io.ReaderFrom)
+	return &transferWriter{
+		ResponseWriter: w,
+		zeroCopy:       canReadFrom && r.TLS == nil && r.ProtoMajor == 1,
+	}
+}
+
+func (tw *transferWriter) WriteHeader(status int) {
+	if tw.status == 0 {
+		tw.status = status
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *transferWriter) Write(p []byte) (int, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	n, err := tw.ResponseWriter.Write(p)
+	tw.written += int64(n)
+	return n, err
+}
+
+// io.Copy/io.CopyN会优先调用ReadFrom
+func (tw *transferWriter) ReadFrom(src io.Reader) (int64, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+
+	var n int64
+	var err error
+	if tw.zeroCopy {
+		n, err = tw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	} else {
+		buf := copyBufferPool.Get().(*[]byte)
+		// 只暴露Write/Read方法，确保io.CopyBuffer使用这里的缓冲区
+		n, err = io.CopyBuffer(struct{ io.Writer }{tw.ResponseWriter}, struct{ io.Reader }{src}, *buf)
+		copyBufferPool.Put(buf)
+	}
+	tw.written += n
+	return n, err
+}
+
+func (tw *transferWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
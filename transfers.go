@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 断点续传记录
+//
+// /file 下载支持Range（http.ServeContent处理，包括If-Range），下载工具中断后可以从断点继续。
+// 作为附件下载（download=1）或带Range的请求按客户端IP和文件记录已传输的字节范围，多次续传的范围合并计算，
+// 覆盖整个文件后标记为完成；文件大小或修改时间变化时重新开始记录。
+//   GET /api/transfers   最近24小时的下载，最多保留transferRecordLimit条
+
+const (
+	transferRecordLimit = 200
+	transferRecordTTL   = 24 * time.Hour
+)
+
+type TransferRecord struct {
+	Path     string `json:"path"`
+	Client   string `json:"client"`
+	Size     int64  `json:"size"`
+	Received int64  `json:"received"` // 已传输过的不重复字节数
+	Sent     int64  `json:"sent"`     // 实际发送的字节数，包括重复传输的部分
+	Requests int    `json:"requests"`
+	Complete bool   `json:"complete"`
+	Started  string `json:"started"`
+	Updated  string `json:"updated"`
+
+	modified time.Time
+	updated  time.Time
+	ranges   [][2]int64 // 已传输的范围 [start, end)，按start排序且不重叠
+}
+
+var (
+	transferRecords = make(map[string]*TransferRecord) // 客户端|小写路径 → 记录
+	transfersMutex  sync.Mutex
+)
+
+// 把[start, end)合并到已排序的范围列表中
+func mergeRange(ranges [][2]int64, start, end int64) [][2]int64 {
+	if start >= end {
+		return ranges
+	}
+	merged := make([][2]int64, 0, len(ranges)+1)
+	inserted := false
+	for _, rg := range ranges {
+		switch {
+		case rg[1] < start:
+			merged = append(merged, rg)
+		case end < rg[0]:
+			if !inserted {
+				merged = append(merged, [2]int64{start, end})
+				inserted = true
+			}
+			merged = append(merged, rg)
+		default:
+			start, end = min(start, rg[0]), max(end, rg[1])
+		}
+	}
+	if !inserted {
+		merged = append(merged, [2]int64{start, end})
+	}
+	return merged
+}
+
+func rangesLength(ranges [][2]int64) int64 {
+	var n int64
+	for _, rg := range ranges {
+		n += rg[1] - rg[0]
+	}
+	return n
+}
+
+// 响应实际传输的范围起点：206时取Content-Range，200时为0；多范围响应无法确定时返回false
+func servedRangeStart(tw *transferWriter) (int64, bool) {
+	switch tw.status {
+	case http.StatusOK:
+		return 0, true
+	case http.StatusPartialContent:
+		spec, ok := strings.CutPrefix(tw.Header().Get("Content-Range"), "bytes ")
+		if !ok {
+			return 0, false
+		}
+		first, _, _ := strings.Cut(spec, "-")
+		start, err := strconv.ParseInt(first, 10, 64)
+		return start, err == nil
+	}
+	return 0, false
+}
+
+// 记录一次文件传输
+func recordTransfer(r *http.Request, path string, info os.FileInfo, tw *transferWriter) {
+	start, ok := servedRangeStart(tw)
+	if !ok && tw.status != http.StatusPartialContent {
+		return // 304、416等没有传输内容
+	}
+
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	now := time.Now()
+	key := client + "|" + strings.ToLower(path)
+
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+
+	rec := transferRecords[key]
+	if rec == nil || rec.Size != info.Size() || !rec.modified.Equal(info.ModTime()) {
+		rec = &TransferRecord{
+			Path:     clientPath(path),
+			Client:   client,
+			Size:     info.Size(),
+			Started:  now.Format("2006-01-02 15:04:05"),
+			modified: info.ModTime(),
+		}
+		transferRecords[key] = rec
+	}
+	rec.Requests++
+	rec.Sent += tw.written
+	if ok {
+		rec.ranges = mergeRange(rec.ranges, start, min(start+tw.written, rec.Size))
+	}
+	rec.Received = rangesLength(rec.ranges)
+	rec.Complete = rec.Received >= rec.Size
+	rec.updated = now
+	rec.Updated = now.Format("2006-01-02 15:04:05")
+
+	pruneTransferRecords(now)
+}
+
+// 删除过期的记录，超过数量上限时删除最早更新的
+func pruneTransferRecords(now time.Time) {
+	for key, rec := range transferRecords {
+		if now.Sub(rec.updated) > transferRecordTTL {
+			delete(transferRecords, key)
+		}
+	}
+	for len(transferRecords) > transferRecordLimit {
+		oldestKey := ""
+		for key, rec := range transferRecords {
+			if oldestKey == "" || rec.updated.Before(transferRecords[oldestKey].updated) {
+				oldestKey = key
+			}
+		}
+		delete(transferRecords, oldestKey)
+	}
+}
+
+// 提供文件内容；作为附件下载或续传时记录传输的范围
+func serveFileTransfer(w http.ResponseWriter, r *http.Request, path string, info os.FileInfo, download bool) {
+	tw := newTransferWriter(w, r)
+	serveFileContent(tw, r, path)
+	if r.Method != http.MethodHead && (download || r.Header.Get("Range") != "") {
+		recordTransfer(r, path, info, tw)
+	}
+}
+
+// 下载记录API处理器
+func apiTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	transfersMutex.Lock()
+	pruneTransferRecords(time.Now())
+	list := make([]TransferRecord, 0, len(transferRecords))
+	for _, rec := range transferRecords {
+		list = append(list, *rec)
+	}
+	transfersMutex.Unlock()
+
+	// 最近更新的在前
+	sort.Slice(list, func(i, j int) bool { return list[i].updated.After(list[j].updated) })
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transfers": list,
+		"count":     len(list),
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeRange(t *testing.T) {
+	var ranges [][2]int64
+	for _, rg := range [][2]int64{{20, 30}, {0, 10}, {40, 50}, {10, 15}, {25, 45}} {
+		ranges = mergeRange(ranges, rg[0], rg[1])
+	}
+	if got := fmt.Sprint(ranges); got != "[[0 15] [20 50]]" {
+		t.Errorf("ranges = %s, want [[0 15] [20 50]]", got)
+	}
+	if n := rangesLength(ranges); n != 45 {
+		t.Errorf("length = %d, want 45", n)
+	}
+}
+
+func TestFileResumeAndChecksum(t *testing.T) {
+	dir := t.TempDir()
+	path := createTestFiles(t, dir, "resume.bin")[0]
+	target := "/file/" + url.PathEscape(filepath.ToSlash(path)) + "?download=1"
+	get := func(query, rangeHeader, wantDigest string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target+query, nil)
+		req.RemoteAddr = "192.0.2.7:5000"
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		if wantDigest != "" {
+			req.Header.Set("Want-Digest", wantDigest)
+		}
+		return serveTestRequest(fileHandler, req)
+	}
+
+	// 第一次中断在第4字节，续传剩余部分
+	rec := get("&checksum=sha256", "bytes=0-3", "")
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("status = %d, Accept-Ranges = %q", rec.Code, rec.Header().Get("Accept-Ranges"))
+	}
+	sum := sha256.Sum256([]byte("0123456789"))
+	if got, want := rec.Header().Get("X-Checksum"), "sha256="+hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("X-Checksum = %q, want %q", got, want)
+	}
+	if got, want := rec.Header().Get("Digest"), "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("Digest = %q, want %q", got, want)
+	}
+
+	rec0 := testTransferRecord(t, "192.0.2.7")
+	if rec0.Received != 4 || rec0.Complete {
+		t.Errorf("after first request: received = %d, complete = %t", rec0.Received, rec0.Complete)
+	}
+
+	rec = get("", "bytes=4-", "md5;q=0.5, sha-256;q=0")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "456789" {
+		t.Fatalf("resume: status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	md5sum := md5.Sum([]byte("0123456789"))
+	if got, want := rec.Header().Get("Digest"), "MD5="+base64.StdEncoding.EncodeToString(md5sum[:]); got != want {
+		t.Errorf("Want-Digest md5: Digest = %q, want %q", got, want)
+	}
+
+	rec1 := testTransferRecord(t, "192.0.2.7")
+	if rec1.Received != 10 || !rec1.Complete || rec1.Requests != 2 || rec1.Sent != 10 {
+		t.Errorf("after resume: %+v", rec1)
+	}
+}
+
+func testTransferRecord(t *testing.T, client string) TransferRecord {
+	t.Helper()
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+	for _, rec := range transferRecords {
+		if rec.Client == client {
+			return *rec
+		}
+	}
+	t.Fatalf("no transfer record for %s", client)
+	return TransferRecord{}
+}
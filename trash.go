@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 安全删除：删除的文件先移动到暂存区，在撤销期限内可以通过 /api/undo 恢复，
+// 期限过后再永久删除（或移入回收站）
+
+// 暂存的已删除条目
+type TrashEntry struct {
+	ID           string `json:"id"`
+	OriginalPath string `json:"originalPath"`
+	StagedPath   string `json:"stagedPath"`
+	IsDir        bool   `json:"isDir"`
+	Deleted      string `json:"deleted"`
+	Expires      string `json:"expires"`
+
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+const (
+	trashFileName      = "trash.json"
+	trashDirName       = ".everything-web-trash" // 非数据目录所在卷上的暂存文件夹
+	defaultUndoWindow  = 10                      // 默认撤销期限（分钟）
+	trashPurgeInterval = 1 * time.Minute
+)
+
+var (
+	trashEntries = make(map[string]*TrashEntry)
+	trashMutex   sync.Mutex
+	trashLoaded  sync.Once
+)
+
+func ensureTrashLoaded() {
+	trashLoaded.Do(func() {
+		trashMutex.Lock()
+		defer trashMutex.Unlock()
+		if err := loadJSONFile(trashFileName, &trashEntries); err != nil {
+			log.Printf("加载删除记录失败: %v", err)
+		}
+		if trashEntries == nil {
+			trashEntries = make(map[string]*TrashEntry)
+		}
+	})
+}
+
+// 撤销期限
+func undoWindow() time.Duration {
+	minutes := serverConfig.UndoWindowMinutes
+	if minutes <= 0 {
+		minutes = defaultUndoWindow
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// 获取路径所在卷的暂存文件夹，与数据目录同卷时使用数据目录下的trash文件夹，
+// 其他卷使用卷根目录下的隐藏文件夹，保证移动操作只是重命名
+func trashDirFor(path string) string {
+	volume := filepath.VolumeName(path)
+	if volume == "" || strings.EqualFold(volume, filepath.VolumeName(getDataDir())) {
+		return filepath.Join(getDataDir(), "trash")
+	}
+	return filepath.Join(volume+`\`, trashDirName)
+}
+
+// 将文件或文件夹移到暂存区，返回删除记录
+func safeDelete(ctx context.Context, path string) (*TrashEntry, error) {
+	ensureTrashLoaded()
+
+	info, err := lstatPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	id := newRandomID(8)
+	trashDir := trashDirFor(path)
+	stagingDir := filepath.Join(trashDir, id)
+	if err := os.MkdirAll(extendedPath(stagingDir), 0755); err != nil {
+		return nil, fmt.Errorf("创建暂存文件夹失败: %v", err)
+	}
+	if filepath.Base(trashDir) == trashDirName {
+		setHiddenAttribute(trashDir)
+	}
+
+	staged, err := movePath(ctx, path, stagingDir)
+	if err != nil {
+		os.Remove(extendedPath(stagingDir))
+		return nil, err
+	}
+
+	now := time.Now()
+	entry := &TrashEntry{
+		ID:           id,
+		OriginalPath: clientPath(path),
+		StagedPath:   staged,
+		IsDir:        info.IsDir(),
+		Deleted:      now.Format("2006-01-02 15:04:05"),
+		ExpiresAt:    now.Add(undoWindow()),
+	}
+	entry.Expires = entry.ExpiresAt.Format("2006-01-02 15:04:05")
+
+	trashMutex.Lock()
+	trashEntries[id] = entry
+	err = saveJSONFile(trashFileName, trashEntries)
+	trashMutex.Unlock()
+	if err != nil {
+		log.Printf("保存删除记录失败: %v", err)
+	}
+
+	log.Printf("已移到暂存区: %s → %s, 可撤销至 %s", path, staged, entry.Expires)
+	return entry, nil
+}
+
+// 恢复已删除的条目
+func restoreTrashEntry(id string) (*TrashEntry, error) {
+	ensureTrashLoaded()
+
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	entry, ok := trashEntries[id]
+	if !ok {
+		return nil, fmt.Errorf("删除记录不存在或已过期")
+	}
+
+	original := resolveClientPath(entry.OriginalPath)
+	if _, err := lstatPath(original); err == nil {
+		return nil, fmt.Errorf("原位置已存在同名文件: %s", original)
+	}
+
+	if _, err := movePath(context.Background(), entry.StagedPath, filepath.Dir(original)); err != nil {
+		return nil, fmt.Errorf("恢复失败: %v", err)
+	}
+	os.Remove(extendedPath(filepath.Dir(entry.StagedPath)))
+
+	delete(trashEntries, id)
+	if err := saveJSONFile(trashFileName, trashEntries); err != nil {
+		log.Printf("保存删除记录失败: %v", err)
+	}
+
+	log.Printf("已恢复: %s", original)
+	return entry, nil
+}
+
+// 永久删除超过撤销期限的条目，配置了useRecycleBin时移入回收站
+func purgeExpiredTrash() {
+	ensureTrashLoaded()
+
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	changed := false
+	for id, entry := range trashEntries {
+		if time.Now().Before(entry.ExpiresAt) {
+			continue
+		}
+
+		var err error
+		if serverConfig.UseRecycleBin {
+			err = moveToRecycleBin(entry.StagedPath)
+		} else {
+			err = os.RemoveAll(extendedPath(entry.StagedPath))
+		}
+		if err != nil {
+			log.Printf("清理暂存区失败: %s, 错误: %v", entry.StagedPath, err)
+			continue
+		}
+		os.Remove(extendedPath(filepath.Dir(entry.StagedPath)))
+
+		delete(trashEntries, id)
+		changed = true
+		log.Printf("撤销期限已过，永久删除: %s", entry.OriginalPath)
+	}
+
+	if changed {
+		if err := saveJSONFile(trashFileName, trashEntries); err != nil {
+			log.Printf("保存删除记录失败: %v", err)
+		}
+	}
+}
+
+// 启动定期清理暂存区的协程
+func startTrashPurger() {
+	purgeExpiredTrash()
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purgeExpiredTrash()
+		}
+	}()
+}
+
+// 撤销删除API处理器
+// GET 列出可撤销的删除；POST {"id"} 恢复指定条目，不带id时恢复最近一次删除
+func apiUndoHandler(w http.ResponseWriter, r *http.Request) {
+	ensureTrashLoaded()
+
+	switch r.Method {
+	case http.MethodGet:
+		trashMutex.Lock()
+		list := []*TrashEntry{}
+		for _, entry := range trashEntries {
+			list = append(list, entry)
+		}
+		trashMutex.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Deleted > list[j].Deleted })
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"entries":     list,
+			"count":       len(list),
+			"undoMinutes": int(undoWindow().Minutes()),
+		})
+
+	case http.MethodPost:
+		if !serverConfig.EnableFileOperations {
+			http.Error(w, "文件操作未启用", http.StatusForbidden)
+			return
+		}
+
+		var req struct {
+			ID string `json:"id"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if req.ID == "" {
+			trashMutex.Lock()
+			var latest *TrashEntry
+			for _, entry := range trashEntries {
+				if latest == nil || entry.Deleted > latest.Deleted {
+					latest = entry
+				}
+			}
+			trashMutex.Unlock()
+			if latest == nil {
+				http.Error(w, "没有可撤销的删除", http.StatusNotFound)
+				return
+			}
+			req.ID = latest.ID
+		}
+
+		entry, err := restoreTrashEntry(req.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		log.Printf("撤销删除: %s, 来源IP: %s", entry.OriginalPath, r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"entry":   entry,
+		})
+
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
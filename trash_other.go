@@ -0,0 +1,74 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 以.开头的文件夹本身就是隐藏的
+func setHiddenAttribute(path string) {}
+
+// 移入系统回收站：macOS为 ~/.Trash，其他系统按freedesktop规范移入 ~/.local/share/Trash，
+// 回收站与文件不在同一个文件系统时直接删除
+func moveToRecycleBin(path string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	var filesDir, infoDir string
+	if runtime.GOOS == "darwin" {
+		filesDir = filepath.Join(home, ".Trash")
+	} else {
+		trashDir := filepath.Join(home, ".local", "share", "Trash")
+		if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+			trashDir = filepath.Join(dataHome, "Trash")
+		}
+		filesDir = filepath.Join(trashDir, "files")
+		infoDir = filepath.Join(trashDir, "info")
+	}
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return err
+	}
+
+	// 回收站中已有同名文件时加序号
+	base := filepath.Base(path)
+	name := base
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(filepath.Join(filesDir, name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s.%d", base, i)
+	}
+
+	if infoDir != "" {
+		if err := os.MkdirAll(infoDir, 0700); err != nil {
+			return err
+		}
+		info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			strings.ReplaceAll(url.PathEscape(path), "%2F", "/"), time.Now().Format("2006-01-02T15:04:05"))
+		if err := os.WriteFile(filepath.Join(infoDir, name+".trashinfo"), []byte(info), 0600); err != nil {
+			return err
+		}
+	}
+
+	err = os.Rename(path, filepath.Join(filesDir, name))
+	if errors.Is(err, syscall.EXDEV) {
+		log.Printf("回收站不在同一个文件系统，直接删除: %s", path)
+		if infoDir != "" {
+			os.Remove(filepath.Join(infoDir, name+".trashinfo"))
+		}
+		return os.RemoveAll(path)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	foDelete          = 0x0003
+	fofSilent         = 0x0004
+	fofNoConfirmation = 0x0010
+	fofAllowUndo      = 0x0040
+	fofNoErrorUI      = 0x0400
+)
+
+var (
+	shell32              = syscall.NewLazyDLL("shell32.dll")
+	shFileOperationWProc = shell32.NewProc("SHFileOperationW")
+)
+
+// SHFILEOPSTRUCTW
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+// 设置隐藏属性
+func setHiddenAttribute(path string) {
+	if p, err := syscall.UTF16PtrFromString(extendedPath(path)); err == nil {
+		syscall.SetFileAttributes(p, syscall.FILE_ATTRIBUTE_HIDDEN)
+	}
+}
+
+// 使用SHFileOperation把文件移入回收站
+func moveToRecycleBin(path string) error {
+	// pFrom需要以两个NUL结尾，且不支持 \\?\ 前缀
+	from, err := syscall.UTF16FromString(displayPath(path))
+	if err != nil {
+		return err
+	}
+	from = append(from, 0)
+
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: fofAllowUndo | fofNoConfirmation | fofSilent | fofNoErrorUI,
+	}
+	ret, _, _ := shFileOperationWProc.Call(uintptr(unsafe.Pointer(&op)))
+	if ret != 0 {
+		return fmt.Errorf("SHFileOperation错误码: 0x%x", ret)
+	}
+	if op.fAnyOperationsAborted != 0 {
+		return fmt.Errorf("移入回收站被中止")
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 文件夹树节点（只包含子文件夹，用于侧边栏）
+type TreeNode struct {
+	Name        string     `json:"name"`
+	Path        string     `json:"path"`
+	HasChildren bool       `json:"hasChildren"`
+	LinkType    string     `json:"linkType,omitempty"`   // symlink, junction, mountpoint
+	LinkTarget  string     `json:"linkTarget,omitempty"` // 链接指向的真实路径
+	Cycle       bool       `json:"cycle,omitempty"`      // 链接指回上级目录，不再展开
+	Children    []TreeNode `json:"children,omitempty"`
+}
+
+type TreeResponse struct {
+	Path  string     `json:"path"`
+	Depth int        `json:"depth"`
+	Nodes []TreeNode `json:"nodes"`
+}
+
+const (
+	DefaultTreeDepth = 1 // 默认只展开一层
+	MaxTreeDepth     = 3 // 最多展开三层，避免遍历整个磁盘
+)
+
+// 列出文件夹下的子文件夹，按名称排序
+func listSubfolders(folderPath string) ([]os.DirEntry, error) {
+	entries, err := readDirPath(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []os.DirEntry
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry)
+		}
+	}
+
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.ToLower(dirs[i].Name()) < strings.ToLower(dirs[j].Name())
+	})
+
+	return dirs, nil
+}
+
+// 判断文件夹是否包含子文件夹（读取失败视为没有）
+func hasSubfolders(folderPath string) bool {
+	f, err := openPath(folderPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	for {
+		entries, err := f.ReadDir(64)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				return true
+			}
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// 递归构建文件夹树，depth为剩余展开层数
+// realPath是文件夹解析链接后的真实路径，ancestors记录当前分支上已展开的真实路径，用于防止链接循环
+func buildTreeNode(name, folderPath, realPath string, depth int, ancestors map[string]bool) TreeNode {
+	node := TreeNode{
+		Name: name,
+		Path: clientPath(folderPath),
+	}
+
+	if ancestors[realPath] {
+		log.Printf("检测到链接循环，停止展开: %s", folderPath)
+		node.Cycle = true
+		return node
+	}
+
+	if depth <= 0 {
+		node.HasChildren = hasSubfolders(folderPath)
+		return node
+	}
+
+	dirs, err := listSubfolders(folderPath)
+	if err != nil {
+		log.Printf("读取子文件夹失败: %s, 错误: %v", folderPath, err)
+		return node
+	}
+
+	ancestors[realPath] = true
+	defer delete(ancestors, realPath)
+
+	node.HasChildren = len(dirs) > 0
+	for _, dir := range dirs {
+		childPath := filepath.Join(folderPath, dir.Name())
+		childReal := filepath.Join(realPath, strings.ToLower(dir.Name()))
+
+		// 只有带重解析属性的文件夹才需要解析链接
+		var linkType, linkTarget string
+		if info, err := dir.Info(); err == nil && getFileAttributes(childPath, info)&fileAttributeReparsePoint != 0 {
+			linkType, linkTarget = resolveLink(childPath)
+			childReal = realFolderPath(childPath)
+		}
+
+		child := buildTreeNode(dir.Name(), childPath, childReal, depth-1, ancestors)
+		child.LinkType = linkType
+		if linkTarget != "" {
+			child.LinkTarget = clientPath(linkTarget)
+		}
+		node.Children = append(node.Children, child)
+	}
+
+	return node
+}
+
+// 文件夹树API处理器
+func apiTreeHandler(w http.ResponseWriter, r *http.Request) {
+	folderPath := resolveClientPath(r.URL.Query().Get("path"))
+
+	depth := DefaultTreeDepth
+	if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+		if d, err := strconv.Atoi(depthStr); err == nil && d > 0 {
+			depth = d
+		}
+	}
+	if depth > MaxTreeDepth {
+		depth = MaxTreeDepth
+	}
+
+	log.Printf("文件夹树请求: path=%s, depth=%d, IP=%s", folderPath, depth, r.RemoteAddr)
+
+	response := TreeResponse{
+		Path:  clientPath(folderPath),
+		Depth: depth,
+		Nodes: []TreeNode{},
+	}
+
+	if folderPath == "" {
+		// 未指定路径时返回所有盘符
+		for _, root := range getDriveRoots() {
+			response.Nodes = append(response.Nodes, buildTreeNode(root, root, realFolderPath(root), depth-1, map[string]bool{}))
+		}
+	} else {
+		fileInfo, err := statPath(folderPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				log.Printf("文件夹不存在: %s", folderPath)
+				http.Error(w, "文件夹不存在", http.StatusNotFound)
+			} else {
+				log.Printf("访问文件夹失败: %s, 错误: %v", folderPath, err)
+				http.Error(w, "访问文件夹失败: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if !fileInfo.IsDir() {
+			log.Printf("路径不是文件夹: %s", folderPath)
+			http.Error(w, "路径不是文件夹", http.StatusBadRequest)
+			return
+		}
+
+		root := buildTreeNode(filepath.Base(folderPath), folderPath, realFolderPath(folderPath), depth, map[string]bool{})
+		if root.Children != nil {
+			response.Nodes = root.Children
+		}
+	}
+
+	log.Printf("文件夹树完成: %s, 返回%d个节点", folderPath, len(response.Nodes))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(response)
+}
+
+// 同级文件夹（用于面包屑下拉菜单）
+type SiblingFolder struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Current bool   `json:"current"`
+}
+
+type SiblingsResponse struct {
+	Path       string          `json:"path"`
+	ParentPath string          `json:"parentPath"`
+	Siblings   []SiblingFolder `json:"siblings"`
+}
+
+// 同级文件夹API处理器，返回与path同一父目录下的所有文件夹
+// 盘符根目录的同级为所有盘符
+func apiSiblingsHandler(w http.ResponseWriter, r *http.Request) {
+	folderPath := resolveClientPath(r.URL.Query().Get("path"))
+	if folderPath == "" {
+		http.Error(w, "路径参数不能为空", http.StatusBadRequest)
+		return
+	}
+	showHidden, _ := strconv.ParseBool(r.URL.Query().Get("showHidden"))
+
+	folderPath = filepath.Clean(folderPath)
+	parentPath := filepath.Dir(folderPath)
+
+	log.Printf("同级文件夹请求: path=%s, IP=%s", folderPath, r.RemoteAddr)
+
+	response := SiblingsResponse{
+		Path:     clientPath(folderPath),
+		Siblings: []SiblingFolder{},
+	}
+
+	if parentPath == folderPath && isUNCPath(folderPath) {
+		// 网络共享根目录的同级为所有已保存的共享
+		for _, root := range getSavedShareRoots() {
+			response.Siblings = append(response.Siblings, SiblingFolder{
+				Name:    root,
+				Path:    root + "\\",
+				Current: strings.EqualFold(root, getShareRoot(folderPath)),
+			})
+		}
+	} else if parentPath == folderPath {
+		// 已经是盘符根目录
+		for _, root := range getDriveRoots() {
+			response.Siblings = append(response.Siblings, SiblingFolder{
+				Name:    root,
+				Path:    root,
+				Current: strings.EqualFold(root, folderPath),
+			})
+		}
+	} else {
+		dirs, err := listSubfolders(parentPath)
+		if err != nil {
+			log.Printf("读取同级文件夹失败: %s, 错误: %v", parentPath, err)
+			http.Error(w, "读取文件夹失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response.ParentPath = clientPath(parentPath)
+		for _, dir := range dirs {
+			siblingPath := filepath.Join(parentPath, dir.Name())
+			current := strings.EqualFold(siblingPath, folderPath)
+
+			if !showHidden && !current {
+				if info, err := dir.Info(); err == nil {
+					attrs := getFileAttributes(siblingPath, info)
+					if attrs&(fileAttributeHidden|fileAttributeSystem) != 0 {
+						continue
+					}
+				}
+			}
+
+			response.Siblings = append(response.Siblings, SiblingFolder{
+				Name:    dir.Name(),
+				Path:    clientPath(siblingPath),
+				Current: current,
+			})
+		}
+	}
+
+	log.Printf("同级文件夹完成: %s, 返回%d个文件夹", folderPath, len(response.Siblings))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 注册表导出文件（.reg）和INI文件的结构化预览
+//
+// 解析为可折叠的键/值树，页面上可以在键名、值名和数据中搜索，匹配项所在的键自动展开。
+// .reg的二进制值按类型解码：dword、qword显示为十进制和十六进制，hex(2)、hex(7)解码为字符串，
+// 删除键（[-HKEY_...]）和删除值（"名称"=-）单独标记。INI中第一个节之前的键放在根节点下。
+
+const maxTreePreviewSize = 10 << 20
+
+type treeValue struct {
+	Name    string `json:"name"`
+	Type    string `json:"type,omitempty"`
+	Data    string `json:"data"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+type treeNode struct {
+	Name     string      `json:"name"`
+	Values   []treeValue `json:"values,omitempty"`
+	Children []*treeNode `json:"children,omitempty"`
+	Deleted  bool        `json:"deleted,omitempty"`
+}
+
+// 名称为name的子节点，没有时创建
+func (n *treeNode) child(name string) *treeNode {
+	for _, c := range n.Children {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	c := &treeNode{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// 按BOM或内容判断编码：UTF-16（.reg导出的默认编码）、UTF-8，其他按系统代码页
+func decodeTextFile(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		var order binary.ByteOrder = binary.LittleEndian
+		if data[0] == 0xFE {
+			order = binary.BigEndian
+		}
+		units := make([]uint16, (len(data)-2)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2+i*2:])
+		}
+		return string(utf16.Decode(units))
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	case utf8.Valid(data):
+		return string(data)
+	}
+	return decodeANSI(data)
+}
+
+// 去掉首尾的引号并处理\\和\"转义，返回字符串和引号后剩余的部分
+func unquoteRegString(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", s, false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+			b.WriteByte(s[i])
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", s, false
+}
+
+// 逗号分隔的十六进制字节
+func parseRegHex(s string) ([]byte, error) {
+	var data []byte
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		b, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("无效的十六进制字节 %s", part)
+		}
+		data = append(data, byte(b))
+	}
+	return data, nil
+}
+
+func decodeRegUTF16(data []byte) []string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return strings.Split(strings.TrimRight(string(utf16.Decode(units)), "\x00"), "\x00")
+}
+
+var regHexTypes = map[string]string{
+	"":  "REG_BINARY",
+	"0": "REG_NONE",
+	"1": "REG_SZ",
+	"2": "REG_EXPAND_SZ",
+	"3": "REG_BINARY",
+	"4": "REG_DWORD",
+	"5": "REG_DWORD_BIG_ENDIAN",
+	"7": "REG_MULTI_SZ",
+	"b": "REG_QWORD",
+}
+
+// 解析值的数据部分（等号右边）
+func parseRegData(raw string) (treeValue, error) {
+	if raw == "-" {
+		return treeValue{Deleted: true}, nil
+	}
+	if s, _, ok := unquoteRegString(raw); ok {
+		return treeValue{Type: "REG_SZ", Data: s}, nil
+	}
+	if v, ok := strings.CutPrefix(strings.ToLower(raw), "dword:"); ok {
+		n, err := strconv.ParseUint(v, 16, 32)
+		if err != nil {
+			return treeValue{}, fmt.Errorf("无效的dword值 %s", v)
+		}
+		return treeValue{Type: "REG_DWORD", Data: fmt.Sprintf("%d (0x%08x)", n, n)}, nil
+	}
+	typ, hex, ok := strings.Cut(raw, ":")
+	typ = strings.ToLower(typ)
+	if !ok || !strings.HasPrefix(typ, "hex") {
+		return treeValue{}, fmt.Errorf("无法识别的值 %s", raw)
+	}
+	kind := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(typ, "hex"), "("), ")")
+	data, err := parseRegHex(hex)
+	if err != nil {
+		return treeValue{}, err
+	}
+	value := treeValue{Type: regHexTypes[kind]}
+	if value.Type == "" {
+		value.Type = "hex(" + kind + ")"
+	}
+	switch {
+	case (kind == "1" || kind == "2") && len(data)%2 == 0:
+		value.Data = decodeRegUTF16(data)[0]
+	case kind == "7" && len(data)%2 == 0:
+		value.Data = strings.Join(decodeRegUTF16(data), "\n")
+	case kind == "4" && len(data) == 4:
+		n := binary.LittleEndian.Uint32(data)
+		value.Data = fmt.Sprintf("%d (0x%08x)", n, n)
+	case kind == "b" && len(data) == 8:
+		n := binary.LittleEndian.Uint64(data)
+		value.Data = fmt.Sprintf("%d (0x%016x)", n, n)
+	default:
+		parts := make([]string, len(data))
+		for i, b := range data {
+			parts[i] = fmt.Sprintf("%02x", b)
+		}
+		value.Data = strings.Join(parts, " ")
+	}
+	return value, nil
+}
+
+// 解析注册表导出文件，键按路径组成树
+func parseRegFile(text string) (*treeNode, error) {
+	root := &treeNode{}
+	var current *treeNode
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		// 十六进制值很长时以\结尾续行
+		for strings.HasSuffix(line, `\`) && strings.Contains(line, "=hex") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, `\`) + strings.TrimSpace(lines[i])
+		}
+		switch {
+		case line == "" || strings.HasPrefix(line, ";"):
+			continue
+		case i == 0 && (strings.HasPrefix(line, "Windows Registry Editor") || line == "REGEDIT4"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			path := line[1 : len(line)-1]
+			deleted := strings.HasPrefix(path, "-")
+			current = root
+			for _, part := range strings.Split(strings.TrimPrefix(path, "-"), `\`) {
+				if part != "" {
+					current = current.child(part)
+				}
+			}
+			current.Deleted = deleted
+		default:
+			if current == nil {
+				return nil, fmt.Errorf("第%d行: 值不在任何键下", lineNo)
+			}
+			var name, rest string
+			if strings.HasPrefix(line, "@") {
+				name, rest = "(默认)", strings.TrimPrefix(line, "@")
+			} else {
+				var ok bool
+				if name, rest, ok = unquoteRegString(line); !ok {
+					return nil, fmt.Errorf("第%d行: 无法识别 %s", lineNo, line)
+				}
+			}
+			rest, ok := strings.CutPrefix(strings.TrimSpace(rest), "=")
+			if !ok {
+				return nil, fmt.Errorf("第%d行: 缺少等号", lineNo)
+			}
+			value, err := parseRegData(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("第%d行: %v", lineNo, err)
+			}
+			value.Name = name
+			current.Values = append(current.Values, value)
+		}
+	}
+	return root, nil
+}
+
+// 解析INI文件，节作为根节点的子节点
+func parseINIFile(text string) *treeNode {
+	root := &treeNode{}
+	current := root
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = root.child(strings.TrimSpace(line[1 : len(line)-1]))
+		default:
+			name, value, ok := strings.Cut(line, "=")
+			if !ok {
+				// 没有等号的行（如插件列表）作为只有名称的项
+				current.Values = append(current.Values, treeValue{Name: line})
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = value[1 : len(value)-1]
+			}
+			current.Values = append(current.Values, treeValue{Name: strings.TrimSpace(name), Data: value})
+		}
+	}
+	return root
+}
+
+type treePreviewProvider struct{ previewExtensions }
+
+func init() {
+	registerPreviewProvider(treePreviewProvider{previewExtensions{".reg", ".ini"}})
+}
+
+func (treePreviewProvider) Name() string { return "tree" }
+
+func (treePreviewProvider) Render(ctx context.Context, path string) (*Preview, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxTreePreviewSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTreePreviewSize {
+		return nil, fmt.Errorf("文件超过%dMB", maxTreePreviewSize>>20)
+	}
+
+	text := decodeTextFile(data)
+	var root *treeNode
+	if strings.EqualFold(filepath.Ext(path), ".reg") {
+		if root, err = parseRegFile(text); err != nil {
+			return nil, err
+		}
+	} else {
+		root = parseINIFile(text)
+	}
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	page := treePreviewPage(filepath.Base(path), clientPath(path), root, nonce)
+	return &Preview{ContentType: "text/html; charset=utf-8", Body: io.NopCloser(strings.NewReader(page))}, nil
+}
+
+func treePreviewPage(fileName, filePath string, root *treeNode, nonce string) string {
+	tree, _ := json.Marshal(root)
+	return `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + html.EscapeString(fileName) + ` - Everything Web Server</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background: #f5f5f5; margin: 0; padding: 20px; color: #333; }
+        .toolbar { display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 12px; font-size: 14px; }
+        .toolbar input { padding: 6px 10px; border: 1px solid #ccc; border-radius: 4px; min-width: 240px; }
+        .meta { color: #888; font-size: 13px; }
+        a { color: #4CAF50; }
+        #tree { background: white; border-radius: 8px; padding: 12px 16px; font-size: 14px; }
+        details { margin-left: 18px; }
+        #tree > details { margin-left: 0; }
+        summary { cursor: pointer; padding: 2px 0; word-break: break-all; }
+        summary.deleted, tr.deleted td { color: #c62828; text-decoration: line-through; }
+        table { border-collapse: collapse; margin: 4px 0 6px 18px; font-size: 13px; }
+        td { border-bottom: 1px solid #eee; padding: 3px 10px 3px 0; vertical-align: top; white-space: pre-wrap; word-break: break-all; }
+        td.type { color: #888; white-space: nowrap; }
+        mark { background: #fff59d; }
+        .hidden { display: none; }
+    </style>
+</head>
+<body>
+    <div class="toolbar">
+        <a href="/">← 返回首页</a>
+        <strong>` + html.EscapeString(fileName) + `</strong>
+        <input type="search" id="filter" placeholder="搜索键、值名和数据">
+        <button id="expandAll">全部展开</button>
+        <button id="collapseAll">全部折叠</button>
+        <a href="/textview/` + url.PathEscape(filePath) + `">查看原文</a>
+        <span class="meta" id="info"></span>
+    </div>
+    <div id="tree"></div>
+    <script nonce="` + nonce + `">
+        const root = ` + string(tree) + `;
+        const container = document.getElementById('tree');
+        const info = document.getElementById('info');
+        let keyCount = 0, valueCount = 0;
+
+        function valueTable(values) {
+            const table = document.createElement('table');
+            values.forEach(v => {
+                const tr = table.insertRow();
+                if (v.deleted) tr.className = 'deleted';
+                [v.name, v.type || '', v.deleted ? '（删除）' : v.data].forEach((text, i) => {
+                    const td = tr.insertCell();
+                    td.textContent = text;
+                    if (i === 1) td.className = 'type';
+                });
+                tr.dataset.search = (v.name + '\n' + v.data).toLowerCase();
+                valueCount++;
+            });
+            return table;
+        }
+
+        function build(node) {
+            const details = document.createElement('details');
+            const summary = document.createElement('summary');
+            summary.textContent = node.name + (node.values ? '（' + node.values.length + '）' : '');
+            if (node.deleted) summary.className = 'deleted';
+            details.dataset.search = node.name.toLowerCase();
+            details.appendChild(summary);
+            if (node.values) details.appendChild(valueTable(node.values));
+            (node.children || []).forEach(c => details.appendChild(build(c)));
+            keyCount++;
+            return details;
+        }
+
+        if (root.values) container.appendChild(valueTable(root.values));
+        (root.children || []).forEach(c => container.appendChild(build(c)));
+        container.querySelectorAll(':scope > details').forEach(d => { d.open = true; });
+        info.textContent = keyCount + '个键，' + valueCount + '个值';
+
+        // 显示匹配的键和值以及它们的上级，其他隐藏
+        function applyFilter(term) {
+            term = term.trim().toLowerCase();
+            let matches = 0;
+            function visit(el) {
+                let visible = false;
+                el.querySelectorAll(':scope > table tr').forEach(tr => {
+                    const hit = !term || tr.dataset.search.includes(term);
+                    tr.classList.toggle('hidden', !hit);
+                    if (hit && term) matches++;
+                    visible = visible || hit;
+                });
+                el.querySelectorAll(':scope > details').forEach(d => {
+                    const selfHit = !!term && d.dataset.search.includes(term);
+                    if (selfHit) matches++;
+                    // 键名匹配时显示它的全部内容
+                    const childVisible = selfHit ? (applyAll(d), true) : visit(d);
+                    d.classList.toggle('hidden', !!term && !childVisible);
+                    if (term) d.open = childVisible;
+                    visible = visible || childVisible;
+                });
+                return visible || !term;
+            }
+            function applyAll(el) {
+                el.querySelectorAll('.hidden').forEach(x => x.classList.remove('hidden'));
+            }
+            visit(container);
+            info.textContent = term ? matches + '个匹配' : keyCount + '个键，' + valueCount + '个值';
+        }
+
+        let timer = null;
+        document.getElementById('filter').addEventListener('input', e => {
+            clearTimeout(timer);
+            timer = setTimeout(() => applyFilter(e.target.value), 200);
+        });
+        document.getElementById('expandAll').addEventListener('click', () => container.querySelectorAll('details').forEach(d => { d.open = true; }));
+        document.getElementById('collapseAll').addEventListener('click', () => container.querySelectorAll('details').forEach(d => { d.open = false; }));
+    </script>
+</body>
+</html>`
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// regedit默认以带BOM的UTF-16LE导出
+func utf16File(s string) []byte {
+	data := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		data = binary.LittleEndian.AppendUint16(data, u)
+	}
+	return data
+}
+
+const testRegFile = `Windows Registry Editor Version 5.00
+
+; 测试
+[HKEY_CURRENT_USER\Software\Demo]
+@="默认值"
+"Path"="C:\\Program Files\\Demo\\"
+"Quote"="say \"hi\""
+"Count"=dword:0000002a
+"Big"=hex(b):00,01,00,00,00,00,00,00
+"Expand"=hex(2):25,00,54,00,45,00,4d,00,50,00,25,00,00,00
+"Multi"=hex(7):61,00,00,00,62,00,00,00,00,00
+"Blob"=hex:de,ad,\
+  be,ef
+"Old"=-
+
+[-HKEY_CURRENT_USER\Software\Demo\Obsolete]
+`
+
+func TestParseRegFile(t *testing.T) {
+	root, err := parseRegFile(decodeTextFile(utf16File(strings.ReplaceAll(testRegFile, "\n", "\r\n"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := root
+	for _, name := range []string{"HKEY_CURRENT_USER", "Software", "Demo"} {
+		if len(key.Children) != 1 || key.Children[0].Name != name {
+			t.Fatalf("expected single child %s, got %+v", name, key.Children)
+		}
+		key = key.Children[0]
+	}
+	want := []treeValue{
+		{Name: "(默认)", Type: "REG_SZ", Data: "默认值"},
+		{Name: "Path", Type: "REG_SZ", Data: `C:\Program Files\Demo\`},
+		{Name: "Quote", Type: "REG_SZ", Data: `say "hi"`},
+		{Name: "Count", Type: "REG_DWORD", Data: "42 (0x0000002a)"},
+		{Name: "Big", Type: "REG_QWORD", Data: "256 (0x0000000000000100)"},
+		{Name: "Expand", Type: "REG_EXPAND_SZ", Data: "%TEMP%"},
+		{Name: "Multi", Type: "REG_MULTI_SZ", Data: "a\nb"},
+		{Name: "Blob", Type: "REG_BINARY", Data: "de ad be ef"},
+		{Name: "Old", Deleted: true},
+	}
+	if len(key.Values) != len(want) {
+		t.Fatalf("got %d values: %+v", len(key.Values), key.Values)
+	}
+	for i, v := range want {
+		if key.Values[i] != v {
+			t.Errorf("value %d = %+v, want %+v", i, key.Values[i], v)
+		}
+	}
+	if len(key.Children) != 1 || !key.Children[0].Deleted {
+		t.Errorf("deleted key not marked: %+v", key.Children)
+	}
+
+	for _, bad := range []string{"\"Orphan\"=\"x\"\n", "[HKEY_CURRENT_USER\\A]\n\"Bad\"=dword:zz\n", "[HKEY_CURRENT_USER\\A]\nnot a value\n"} {
+		if _, err := parseRegFile(bad); err == nil {
+			t.Errorf("parseRegFile(%q): expected error", bad)
+		}
+	}
+}
+
+func TestParseINIFile(t *testing.T) {
+	root := parseINIFile("top=1\n; comment\n[General]\nName = \"Demo App\"\n# other comment\nplugin.dll\n[general]\nTheme=dark\n")
+	if len(root.Values) != 1 || root.Values[0] != (treeValue{Name: "top", Data: "1"}) {
+		t.Errorf("root values = %+v", root.Values)
+	}
+	// 同名的节（不区分大小写）合并
+	if len(root.Children) != 1 {
+		t.Fatalf("sections = %+v", root.Children)
+	}
+	want := []treeValue{{Name: "Name", Data: "Demo App"}, {Name: "plugin.dll"}, {Name: "Theme", Data: "dark"}}
+	got := root.Children[0].Values
+	if len(got) != len(want) {
+		t.Fatalf("values = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("value %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTreePreview(t *testing.T) {
+	dir := t.TempDir()
+	reg := filepath.Join(dir, "demo.reg")
+	os.WriteFile(reg, utf16File(testRegFile), 0644)
+	bad := filepath.Join(dir, "bad.reg")
+	os.WriteFile(bad, []byte("garbage"), 0644)
+
+	rec := serveTestRequest(previewHandler, httptest.NewRequest(http.MethodGet, "/preview/"+url.PathEscape(reg), nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status %d, type %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `"name":"Count","type":"REG_DWORD"`) {
+		t.Errorf("tree data missing from page")
+	}
+
+	// 值中的</script>不能结束页面中的脚本
+	ini := filepath.Join(dir, "evil.ini")
+	os.WriteFile(ini, []byte("[a]\nx=</script><script>alert(1)</script>\n"), 0644)
+	rec = serveTestRequest(previewHandler, httptest.NewRequest(http.MethodGet, "/preview/"+url.PathEscape(ini), nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "<script>alert") {
+		t.Errorf("ini: status %d, value not escaped", rec.Code)
+	}
+	if rec = serveTestRequest(previewHandler, httptest.NewRequest(http.MethodGet, "/preview/"+url.PathEscape(bad), nil)); rec.Code != http.StatusInternalServerError {
+		t.Errorf("invalid .reg: status = %d, want 500", rec.Code)
+	}
+}